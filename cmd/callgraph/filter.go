@@ -0,0 +1,263 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// This file defines the restriction of a call graph to the functions of
+// some packages, to the functions reachable from or reaching others,
+// and the enumeration of the call paths between them.
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A listFlag is the value of a flag that is a comma-separated list,
+// and may be repeated.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(s string) error {
+	for _, elem := range strings.Split(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			*l = append(*l, elem)
+		}
+	}
+	return nil
+}
+
+// A funcsFlag is the value of a flag that names a function, and may be
+// repeated. Function names may contain commas, e.g. F[int, string].
+type funcsFlag []string
+
+func (f *funcsFlag) String() string { return strings.Join(*f, " ") }
+
+func (f *funcsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// filterPackages deletes from cg the nodes of the functions of the
+// packages that match none of the include patterns, if there are any,
+// or match an exclude pattern. The nodes of functions of no package,
+// such as the root, are kept.
+func filterPackages(cg *callgraph.Graph, include, exclude []string) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return
+	}
+	matchAny := func(patterns []string, path string) bool {
+		for _, pattern := range patterns {
+			if matchPattern(pattern, path) {
+				return true
+			}
+		}
+		return false
+	}
+	for fn, n := range cg.Nodes {
+		path := funcPackage(fn)
+		if path == "" {
+			continue
+		}
+		if len(include) > 0 && !matchAny(include, path) || matchAny(exclude, path) {
+			cg.DeleteNode(n)
+		}
+	}
+}
+
+// matchPattern reports whether the package path matches the pattern,
+// in which "..." matches any string, as in the patterns of the go
+// command: "net/..." matches net and the packages below it.
+func matchPattern(pattern, path string) bool {
+	re := regexp.QuoteMeta(pattern)
+	if strings.HasSuffix(re, `/\.\.\.`) {
+		re = strings.TrimSuffix(re, `/\.\.\.`) + `(/\.\.\.)?`
+	}
+	re = strings.Replace(re, `\.\.\.`, `.*`, -1)
+	return regexp.MustCompile(`^` + re + `$`).MatchString(path)
+}
+
+// funcPackage returns the path of the package of the function, or of
+// its generic function or method, or "" if it has none.
+func funcPackage(fn *ssa.Function) string {
+	if fn == nil {
+		return ""
+	}
+	if fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	if fn.Pkg != nil {
+		return fn.Pkg.Pkg.Path()
+	}
+	if obj := fn.Object(); obj != nil && obj.Pkg() != nil {
+		return obj.Pkg().Path() // e.g. a wrapper
+	}
+	return ""
+}
+
+// lookupFuncs returns the nodes of cg of the named functions, as
+// they are printed. A name may denote several functions, such as the
+// main function of a package and that of its test variant.
+func lookupFuncs(cg *callgraph.Graph, names []string) ([]*callgraph.Node, error) {
+	byName := make(map[string][]*callgraph.Node)
+	for _, n := range cg.Nodes {
+		name := "<root>"
+		if n.Func != nil {
+			name = n.Func.String()
+		}
+		byName[name] = append(byName[name], n)
+	}
+	var nodes []*callgraph.Node
+	for _, name := range names {
+		if byName[name] == nil {
+			return nil, fmt.Errorf("no function %s in the call graph", name)
+		}
+		nodes = append(nodes, byName[name]...)
+	}
+	sortNodes(nodes)
+	return nodes, nil
+}
+
+// distances returns the nodes reachable from the start nodes, by their
+// edges or, if reverse, against them, in at most depth steps, or any
+// number of them if depth is zero, and the least number of steps from
+// the start nodes to each.
+func distances(start []*callgraph.Node, depth int, reverse bool) map[*callgraph.Node]int {
+	dist := make(map[*callgraph.Node]int)
+	queue := append([]*callgraph.Node(nil), start...)
+	for _, n := range start {
+		dist[n] = 0
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if depth > 0 && dist[n] == depth {
+			continue
+		}
+		edges := n.Out
+		if reverse {
+			edges = n.In
+		}
+		for _, e := range edges {
+			m := e.Callee
+			if reverse {
+				m = e.Caller
+			}
+			if _, ok := dist[m]; !ok {
+				dist[m] = dist[n] + 1
+				queue = append(queue, m)
+			}
+		}
+	}
+	return dist
+}
+
+// restrict deletes from cg the nodes of the functions that are not on
+// a call path, of at most depth calls if depth is not zero, from one of
+// the from nodes, if any, to one of the to nodes, if any.
+// It returns the distances of the remaining nodes to the to nodes.
+func restrict(cg *callgraph.Graph, from, to []*callgraph.Node, depth int) map[*callgraph.Node]int {
+	var fromDist, toDist map[*callgraph.Node]int
+	if from != nil {
+		fromDist = distances(from, depth, false)
+	}
+	if to != nil {
+		toDist = distances(to, depth, true)
+	}
+	for _, n := range cg.Nodes {
+		keep := true
+		if from != nil {
+			_, ok := fromDist[n]
+			keep = keep && ok
+		}
+		if to != nil {
+			_, ok := toDist[n]
+			keep = keep && ok
+		}
+		if keep && from != nil && to != nil && depth > 0 {
+			keep = fromDist[n]+toDist[n] <= depth
+		}
+		if !keep {
+			cg.DeleteNode(n)
+		}
+	}
+	return toDist
+}
+
+// printPaths prints the call paths of cg from the from nodes to the to
+// nodes, one per line, that have at most depth calls if depth is not
+// zero and visit no function twice. The distances of the nodes to the
+// to nodes are those that restrict returns.
+func printPaths(w io.Writer, from, to []*callgraph.Node, toDist map[*callgraph.Node]int, depth int) {
+	isTo := make(map[*callgraph.Node]bool)
+	for _, n := range to {
+		isTo[n] = true
+	}
+
+	var path []*callgraph.Node
+	onPath := make(map[*callgraph.Node]bool)
+	var visit func(n *callgraph.Node)
+	visit = func(n *callgraph.Node) {
+		path = append(path, n)
+		onPath[n] = true
+		defer func() {
+			path = path[:len(path)-1]
+			onPath[n] = false
+		}()
+
+		if isTo[n] {
+			names := make([]string, len(path))
+			for i, n := range path {
+				names[i] = nodeName(n)
+			}
+			fmt.Fprintln(w, strings.Join(names, " --> "))
+			return
+		}
+		var callees []*callgraph.Node
+		for _, e := range n.Out {
+			callee := e.Callee
+			if d, ok := toDist[callee]; !ok || onPath[callee] || depth > 0 && len(path)+d > depth {
+				continue
+			}
+			callees = append(callees, callee)
+		}
+		sortNodes(callees)
+		for i, callee := range callees {
+			if i > 0 && callee == callees[i-1] {
+				continue // several calls
+			}
+			visit(callee)
+		}
+	}
+	for _, n := range from {
+		if _, ok := toDist[n]; ok {
+			visit(n)
+		}
+	}
+}
+
+func nodeName(n *callgraph.Node) string {
+	if n.Func == nil {
+		return "<root>"
+	}
+	return n.Func.String()
+}
+
+// sortNodes sorts the nodes by the names and positions of their
+// functions.
+func sortNodes(nodes []*callgraph.Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		x, y := nodes[i], nodes[j]
+		if nx, ny := nodeName(x), nodeName(y); nx != ny {
+			return nx < ny
+		}
+		return x.Func != nil && y.Func != nil && x.Func.Pos() < y.Func.Pos()
+	})
+}
@@ -15,7 +15,6 @@ package main // import "golang.org/x/tools/cmd/callgraph"
 //   - unreachable functions (use digraph tool?)
 //   - dynamic (runtime) types
 //   - indexed output (numbered nodes)
-//   - JSON output
 //   - additional template fields:
 //     callee file/line/col
 
@@ -35,6 +34,7 @@ import (
 	"golang.org/x/tools/go/buildutil"
 	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/export"
 	"golang.org/x/tools/go/callgraph/rta"
 	"golang.org/x/tools/go/callgraph/static"
 	"golang.org/x/tools/go/callgraph/vta"
@@ -58,17 +58,32 @@ var (
 
 	ptalogFlag = flag.String("ptalog", "",
 		"Location of the points-to analysis log file, or empty to disable logging.")
+
+	includeFlag  listFlag
+	excludeFlag  listFlag
+	collapseFlag = flag.Bool("collapse", false,
+		"Collapse the functions of each package into a single node")
+	fromFlag  funcsFlag
+	toFlag    funcsFlag
+	depthFlag = flag.Int("depth", 0,
+		"Maximum number of calls from the -from functions or to the -to functions, or 0 for no limit")
 )
 
 func init() {
 	flag.Var((*buildutil.TagsFlag)(&build.Default.BuildTags), "tags", buildutil.TagsFlagDoc)
+	flag.Var(&includeFlag, "include", "Comma-separated list of patterns of the packages whose functions to include")
+	flag.Var(&excludeFlag, "exclude", "Comma-separated list of patterns of the packages whose functions to exclude")
+	flag.Var(&fromFlag, "from", "Include only the functions called, directly or not, by this function (may be repeated)")
+	flag.Var(&toFlag, "to", "Include only the functions that call, directly or not, this function (may be repeated)")
 }
 
 const Usage = `callgraph: display the call graph of a Go program.
 
 Usage:
 
-  callgraph [-algo=static|cha|rta|vta|pta] [-test] [-format=...] package...
+  callgraph [-algo=static|cha|rta|vta|pta] [-test] [-format=...]
+            [-include=patterns] [-exclude=patterns] [-collapse]
+            [-from=func] [-to=func] [-depth=n] package...
 
 Flags:
 
@@ -87,12 +102,45 @@ Flags:
 
 -test      Include the package's tests in the analysis.
 
+-include   Restricts the graph to the functions of the packages that
+           match one of the comma-separated patterns, in which "..."
+           matches any string, as in the patterns of the go command.
+
+-exclude   Removes from the graph the functions of the packages that
+           match one of the comma-separated patterns, e.g.
+           -exclude=runtime,internal/...
+
+-collapse  Collapses the functions of each package into a single node,
+           named by the package path, and the calls between functions
+           of two packages into a single edge.  It requires one of the
+           digraph, graphviz, dot and json formats.
+
+-from      Restricts the graph to the functions called, directly or
+           indirectly, by the named function, as printed
+           (e.g. "(*net/http.Server).Serve").  May be repeated.
+
+-to        Restricts the graph to the functions that call, directly or
+           indirectly, the named function.  May be repeated.  With both
+           -from and -to, the graph has the functions of the call paths
+           from the ones to the others.
+
+-depth     Limits the number of calls from the -from functions, to the
+           -to functions, or of the call paths from the ones to the
+           others.  Zero, the default, is no limit.
+
 -format    Specifies the format in which each call graph edge is displayed.
            One of:
 
             digraph     output suitable for input to
                         golang.org/x/tools/cmd/digraph.
             graphviz    output in AT&T GraphViz (.dot) format.
+            dot         output in GraphViz format, with the functions of
+                        each package in a cluster.
+            json        output in JSON format, with a list of nodes and a
+                        list of edges; see golang.org/x/tools/go/callgraph/export.
+            paths       the call paths from the -from functions to the -to
+                        functions, one per line, that visit no function
+                        twice.  It is the default with -from and -to.
 
            All other values are interpreted using text/template syntax.
            The default value is:
@@ -142,6 +190,17 @@ Examples:
 
     callgraph -format=digraph golang.org/x/tools/cmd/callgraph |
       digraph succs golang.org/x/tools/cmd/callgraph.main
+
+  Show how the callgraph tool's main function reaches the pointer analysis:
+
+    callgraph -from=golang.org/x/tools/cmd/callgraph.main \
+      -to=golang.org/x/tools/go/pointer.Analyze golang.org/x/tools/cmd/callgraph
+
+  Show the calls between the packages of the tool, outside the standard
+  library, as a GraphViz graph:
+
+    callgraph -include=golang.org/x/tools/... -exclude=golang.org/x/tools/internal/... \
+      -collapse -format=dot golang.org/x/tools/cmd/callgraph | dot -Tsvg >cg.svg
 `
 
 func init() {
@@ -158,7 +217,11 @@ func init() {
 
 func main() {
 	flag.Parse()
-	if err := doCallgraph("", "", *algoFlag, *formatFlag, *testFlag, flag.Args()); err != nil {
+	format := *formatFlag
+	if fromFlag != nil && toFlag != nil && !isFlagSet("format") {
+		format = "paths"
+	}
+	if err := doCallgraph("", "", *algoFlag, format, *testFlag, flag.Args()); err != nil {
 		fmt.Fprintf(os.Stderr, "callgraph: %s\n", err)
 		os.Exit(1)
 	}
@@ -262,8 +325,57 @@ func doCallgraph(dir, gopath, algo, format string, tests bool, args []string) er
 
 	cg.DeleteSyntheticNodes()
 
+	// -- filtering --------------------------------------------------------
+
+	filterPackages(cg, includeFlag, excludeFlag)
+
+	var from, to []*callgraph.Node
+	if fromFlag != nil {
+		if from, err = lookupFuncs(cg, fromFlag); err != nil {
+			return err
+		}
+	}
+	if toFlag != nil {
+		if to, err = lookupFuncs(cg, toFlag); err != nil {
+			return err
+		}
+	}
+	if from == nil && to == nil && *depthFlag != 0 {
+		return fmt.Errorf("-depth requires -from or -to")
+	}
+	toDist := restrict(cg, from, to, *depthFlag)
+
 	// -- output------------------------------------------------------------
 
+	switch format {
+	case "paths":
+		if from == nil || to == nil {
+			return fmt.Errorf("-format=paths requires -from and -to")
+		}
+		if *collapseFlag {
+			return fmt.Errorf("-collapse requires one of the digraph, graphviz, dot and json formats")
+		}
+		printPaths(stdout, from, to, toDist, *depthFlag)
+		return nil
+
+	case "dot", "json":
+		g := export.New(cg, prog.Fset, &export.Options{CollapsePackages: *collapseFlag})
+		if format == "dot" {
+			return g.WriteDOT(stdout)
+		}
+		return g.WriteJSON(stdout)
+
+	case "digraph", "graphviz":
+		if *collapseFlag {
+			return writeCollapsed(cg, prog.Fset, format)
+		}
+
+	default:
+		if *collapseFlag {
+			return fmt.Errorf("-collapse requires one of the digraph, graphviz, dot and json formats")
+		}
+	}
+
 	var before, after string
 
 	// Pre-canned formats.
@@ -309,6 +421,43 @@ func doCallgraph(dir, gopath, algo, format string, tests bool, args []string) er
 	return nil
 }
 
+// writeCollapsed writes the calls between the packages of cg in the
+// digraph or graphviz format.
+func writeCollapsed(cg *callgraph.Graph, fset *token.FileSet, format string) error {
+	g := export.New(cg, fset, &export.Options{CollapsePackages: true})
+	if format == "graphviz" {
+		fmt.Fprint(stdout, "digraph callgraph {\n")
+	}
+	seen := make(map[[2]int]bool) // (the edges of calls of different kinds are distinct)
+	for _, e := range g.Edges {
+		if seen[[2]int{e.Caller, e.Callee}] {
+			continue
+		}
+		seen[[2]int{e.Caller, e.Callee}] = true
+		caller, callee := g.Nodes[e.Caller].Name, g.Nodes[e.Callee].Name
+		if format == "graphviz" {
+			fmt.Fprintf(stdout, "  %q -> %q\n", caller, callee)
+		} else {
+			fmt.Fprintf(stdout, "%q %q\n", caller, callee)
+		}
+	}
+	if format == "graphviz" {
+		fmt.Fprint(stdout, "}\n")
+	}
+	return nil
+}
+
+// isFlagSet reports whether the named flag was set on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 // mainPackages returns the main packages to analyze.
 // Each resulting package is named "main" and has a main function.
 func mainPackages(pkgs []*ssa.Package) ([]*ssa.Package, error) {
@@ -16,10 +16,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 
 	"golang.org/x/tools/internal/testenv"
+	"golang.org/x/tools/internal/typeparams"
 )
 
 func init() {
@@ -120,3 +122,135 @@ func TestCallgraph(t *testing.T) {
 		}
 	}
 }
+
+func TestCallgraphGenerics(t *testing.T) {
+	if !typeparams.Enabled {
+		t.Skip("type parameters are not enabled")
+	}
+	testenv.NeedsTool(t, "go")
+
+	gopath, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const format = "{{.Caller}} --> {{.Callee}}"
+	stdout = new(bytes.Buffer)
+	if err := doCallgraph("testdata/src", gopath, "vta", format, false, []string{"generic"}); err != nil {
+		t.Fatal(err)
+	}
+
+	edges := make(map[string]bool)
+	for _, line := range strings.Split(fmt.Sprint(stdout), "\n") {
+		edges[line] = true
+	}
+	// The instantiations are synthetic, so their callers are joined to
+	// their callees, such as the anonymous function of apply[A].
+	for _, edge := range []string{
+		`generic.main --> generic.apply[generic.A]$1`,
+		`generic.apply[generic.A]$1 --> (generic.A).f`,
+		`generic.main --> (generic.B).f`,
+	} {
+		if !edges[edge] {
+			t.Errorf("missing edge: %s", edge)
+		}
+	}
+	// Unlike cha, vta knows that dynamic[B] only calls (B).f.
+	if edge := `generic.main --> (generic.A).f`; edges[edge] {
+		t.Errorf("imprecise edge: %s", edge)
+	}
+	if t.Failed() {
+		t.Log("got:\n", stdout)
+	}
+}
+
+func TestCallgraphFilters(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	gopath, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		includeFlag, excludeFlag, fromFlag, toFlag = nil, nil, nil, nil
+		*collapseFlag = false
+		*depthFlag = 0
+	}()
+
+	for _, test := range []struct {
+		name     string
+		include  listFlag
+		from, to funcsFlag
+		depth    int
+		collapse bool
+		format   string
+		want     string // the complete output
+	}{
+		{
+			name: "paths", from: funcsFlag{"pkg.main"}, to: funcsFlag{"(pkg.D).f"},
+			format: "paths",
+			// rta imprecisely calls (pkg.D).f from main.
+			want: "pkg.main --> (pkg.D).f\n" +
+				"pkg.main --> pkg.main2 --> (pkg.D).f\n",
+		},
+		{
+			name: "paths with depth", from: funcsFlag{"pkg.main"}, to: funcsFlag{"(pkg.D).f"},
+			depth: 1, format: "paths",
+			want: "pkg.main --> (pkg.D).f\n",
+		},
+		{
+			name: "from with depth", from: funcsFlag{"pkg.main2"},
+			depth: 1, format: "{{.Caller}} --> {{.Callee}}",
+			want: "pkg.main2 --> (pkg.C).f\n" +
+				"pkg.main2 --> (pkg.D).f\n",
+		},
+		{
+			name: "to", to: funcsFlag{"pkg.main2"}, include: listFlag{"pkg"},
+			format: "digraph",
+			want:   "\"pkg.main\" \"pkg.main2\"\n",
+		},
+		{
+			name: "collapse", include: listFlag{"pkg"}, collapse: true,
+			format: "digraph",
+			want:   "\"pkg\" \"pkg\"\n",
+		},
+	} {
+		includeFlag, fromFlag, toFlag = test.include, test.from, test.to
+		*depthFlag = test.depth
+		*collapseFlag = test.collapse
+
+		var out bytes.Buffer
+		stdout = &out
+		if err := doCallgraph("testdata/src", gopath, "rta", test.format, false, []string{"pkg"}); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		// The order of the edges of the template formats is that of a map.
+		lines := strings.SplitAfter(out.String(), "\n")
+		sort.Strings(lines)
+		if got := strings.Join(lines, ""); got != test.want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"fmt", "fmt", true},
+		{"fmt", "fmt/x", false},
+		{"net/...", "net", true},
+		{"net/...", "net/http", true},
+		{"net/...", "network", false},
+		{"...", "example.com/x", true},
+		{"internal/...", "internal/abi", true},
+		{"golang.org/x/.../internal", "golang.org/x/tools/internal", true},
+		{"golang.org/x/.../internal", "golang.org/x/tools/internal/imports", false},
+	} {
+		if got := matchPattern(test.pattern, test.path); got != test.want {
+			t.Errorf("matchPattern(%q, %q) = %t, want %t", test.pattern, test.path, got, test.want)
+		}
+	}
+}
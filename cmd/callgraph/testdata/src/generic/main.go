@@ -0,0 +1,27 @@
+package main
+
+type I interface{ f() }
+
+type A struct{}
+
+func (A) f() {}
+
+type B struct{}
+
+func (B) f() {}
+
+// apply calls f in an anonymous function of its instantiations.
+func apply[T I](x T) {
+	func() { x.f() }()
+}
+
+// dynamic calls f of its argument through an interface.
+func dynamic[T any](x T) {
+	var i interface{} = x
+	i.(I).f()
+}
+
+func main() {
+	apply(A{})
+	dynamic(B{})
+}
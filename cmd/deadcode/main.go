@@ -0,0 +1,210 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// deadcode: a tool for reporting the unreachable functions of a Go
+// program. See Usage for details, or run with -help.
+package main // import "golang.org/x/tools/cmd/deadcode"
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/callgraph/deadcode"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// flags
+var (
+	testFlag = flag.Bool("test", false,
+		"Include the tests of the packages, whose functions are entry points")
+
+	exportedFlag = flag.Bool("exported", false,
+		"Consider the exported API of the non-main packages an entry point")
+
+	vtaFlag = flag.Bool("vta", false,
+		"Use Variable Type Analysis, rather than Rapid Type Analysis")
+
+	configFlag = flag.String("config", "",
+		"Location of a JSON file naming the functions used by reflection")
+
+	confidenceFlag = flag.String("confidence", "low",
+		"The lowest confidence level of the functions reported (low, medium, high)")
+
+	jsonFlag = flag.Bool("json", false,
+		"Report the functions in JSON")
+)
+
+func init() {
+	flag.Var((*buildutil.TagsFlag)(&build.Default.BuildTags), "tags", buildutil.TagsFlagDoc)
+}
+
+const Usage = `deadcode: report the unreachable functions of a Go program.
+
+Usage:
+
+  deadcode [-test] [-exported] [-vta] [-config=file] [-confidence=level] [-json] package...
+
+The entry points of the program are the main and init functions of the
+main packages among the packages named, and optionally those of their
+tests, the exported API of the other packages, and the functions that
+the configuration file names. Each declared function of the packages
+named that is unreachable from the entry points is reported, with the
+confidence level of the report: exported functions may be used by
+other programs, and exported methods by reflection.
+
+Flags:
+
+-test       Include the tests of the packages, whose functions are entry
+            points.
+
+-exported   Consider the exported functions and methods of the packages
+            that are not main packages to be entry points, as those of
+            a library are.
+
+-vta        Find the reachable functions by Variable Type Analysis, which
+            is more precise but more costly than the default, Rapid Type
+            Analysis.
+
+-config     Location of a JSON file naming the functions that are used by
+            reflection, and so are entry points, e.g.
+
+              {"reflected": ["example.com/p.Handler", "(*example.com/p.T).*"]}
+
+            A name ending in "*" stands for all the functions whose names
+            begin with the rest.
+
+-confidence The lowest confidence level of the functions reported: low
+            (the default), medium, or high.
+
+-json       Report the functions as a JSON array of objects with the
+            fields Name, Pos and Confidence.
+
+Example:
+
+  Report the unreachable functions of a command:
+
+    deadcode golang.org/x/tools/cmd/deadcode
+`
+
+// A config is the contents of the configuration file.
+type config struct {
+	Reflected []string `json:"reflected"`
+}
+
+// A jsonFunc is the JSON form of an unreachable function.
+type jsonFunc struct {
+	Name       string
+	Pos        string
+	Confidence string
+}
+
+func main() {
+	flag.Parse()
+	if err := doDeadcode("", "", *testFlag, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "deadcode: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+var stdout io.Writer = os.Stdout
+
+func doDeadcode(dir, gopath string, tests bool, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, Usage)
+		return nil
+	}
+
+	var minConfidence deadcode.Confidence
+	switch *confidenceFlag {
+	case "low":
+		minConfidence = deadcode.Low
+	case "medium":
+		minConfidence = deadcode.Medium
+	case "high":
+		minConfidence = deadcode.High
+	default:
+		return fmt.Errorf("invalid -confidence level %q", *confidenceFlag)
+	}
+
+	var conf config
+	if *configFlag != "" {
+		data, err := ioutil.ReadFile(*configFlag)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &conf); err != nil {
+			return fmt.Errorf("%s: %v", *configFlag, err)
+		}
+	}
+
+	cfg := &packages.Config{
+		Mode:  packages.LoadAllSyntax,
+		Tests: tests,
+		Dir:   dir,
+	}
+	if gopath != "" {
+		cfg.Env = append(os.Environ(), "GOPATH="+gopath) // to enable testing
+	}
+	initial, err := packages.Load(cfg, args...)
+	if err != nil {
+		return err
+	}
+	if packages.PrintErrors(initial) > 0 {
+		return fmt.Errorf("packages contain errors")
+	}
+
+	// Create and build SSA-form program representation.
+	prog, pkgs := ssautil.AllPackages(initial, ssa.InstantiateGenerics)
+	prog.Build()
+
+	dc := &deadcode.Config{
+		Mains:     ssautil.MainPackages(pkgs),
+		Reflected: conf.Reflected,
+		VTA:       *vtaFlag,
+	}
+	for _, pkg := range pkgs {
+		if pkg == nil {
+			continue
+		}
+		dc.Packages = append(dc.Packages, pkg)
+		if *exportedFlag && pkg.Pkg.Name() != "main" {
+			dc.Exported = append(dc.Exported, pkg)
+		}
+	}
+	if len(dc.Mains) == 0 && len(dc.Exported) == 0 {
+		return fmt.Errorf("no main packages, and no -exported API")
+	}
+
+	var funcs []jsonFunc
+	for _, fn := range deadcode.Analyze(prog, dc) {
+		if fn.Confidence < minConfidence {
+			continue
+		}
+		if *jsonFlag {
+			funcs = append(funcs, jsonFunc{
+				Name:       fn.Func.String(),
+				Pos:        fn.Posn.String(),
+				Confidence: fn.Confidence.String(),
+			})
+		} else {
+			fmt.Fprintf(stdout, "%s: unreachable func: %s (%s confidence)\n", fn.Posn, fn.Func, fn.Confidence)
+		}
+	}
+	if *jsonFlag {
+		data, err := json.MarshalIndent(funcs, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s\n", data)
+	}
+	return nil
+}
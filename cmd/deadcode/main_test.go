@@ -0,0 +1,70 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// No testdata on Android.
+
+//go:build !android
+// +build !android
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/testenv"
+)
+
+func init() {
+	// This test currently requires GOPATH mode.
+	if err := os.Setenv("GO111MODULE", "off"); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Setenv("GOPROXY", "off"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func TestDeadcode(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	gopath, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		confidence string
+		want       []string
+	}{
+		{"low", []string{
+			"pkg.go:11:10: unreachable func: (pkg.D).f (high confidence)",
+			"pkg.go:21:6: unreachable func: pkg.dead (high confidence)",
+			"pkg.go:23:6: unreachable func: pkg.Exported (medium confidence)",
+		}},
+		{"high", []string{
+			"pkg.go:11:10: unreachable func: (pkg.D).f (high confidence)",
+			"pkg.go:21:6: unreachable func: pkg.dead (high confidence)",
+		}},
+	} {
+		*confidenceFlag = test.confidence
+		stdout = new(bytes.Buffer)
+		if err := doDeadcode("testdata/src", gopath, false, []string{"pkg"}); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(stdout.(*bytes.Buffer).String()), "\n") {
+			got = append(got, filepath.Base(line))
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("-confidence=%s: got\n%s\nwant\n%s", test.confidence, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+type I interface{ f() }
+
+type C int
+
+func (C) f() {}
+
+type D int
+
+func (D) f() {}
+
+func main() {
+	var i I = C(0)
+	i.f()
+	used()
+}
+
+func used() {}
+
+func dead() {}
+
+func Exported() {}
@@ -31,12 +31,20 @@ The support commands are:
 		the list of nodes on some arbitrary path from the first node to the second
 	allpaths <node> <node>
 		the set of nodes on all paths from the first node to the second
+	shortestpath <node> <node>
+		the list of nodes on a shortest path from the first node to the second
 	sccs
 		all strongly connected components (one per line)
 	scc <node>
 		the set of nodes strongly connected to the specified one
+	condensation
+		the graph of the strongly connected components
+	cycles
+		all elementary cycles (one per line)
 	focus <node>
 		the subgraph containing all directed paths that pass through the specified node
+	subgraph <node> ...
+		the subgraph induced by the specified nodes
 
 Input format:
 
@@ -75,6 +83,16 @@ Show the transitive closure of imports of the digraph tool itself:
 Show which clothes (see above) must be donned before a jacket:
 
 	$ digraph reverse jacket
+
+Show the import cycles of a set of packages, and the shortest chain of
+imports from one package to another:
+
+	$ go list -f '{{.ImportPath}} {{join .Imports " "}}' ./... | digraph cycles
+	$ go list -f '{{.ImportPath}} {{join .Imports " "}}' -deps . | digraph shortestpath example.com/a example.com/b
+
+In the output of the condensation command, each strongly connected
+component of several nodes is a node named by the list of its nodes,
+which is quoted, so that the output is itself a graph.
 */
 package main // import "golang.org/x/tools/cmd/digraph"
 
@@ -121,13 +139,22 @@ The support commands are:
 		the list of nodes on some arbitrary path from the first node to the second
 	allpaths <node> <node>
 		the set of nodes on all paths from the first node to the second
+	shortestpath <node> <node>
+		the list of nodes on a shortest path from the first node to the second
 	sccs
 		all non-trivial strongly connected components, one per line
 		(single-node components are only printed for nodes with self-loops)
 	scc <node>
 		the set of nodes nodes strongly connected to the specified one
+	condensation
+		the graph of the strongly connected components, in which each
+		component of several nodes is a node named by the list of them
+	cycles
+		all elementary cycles, one per line, each from its least node
 	focus <node>
 		the subgraph containing all directed paths that pass through the specified node
+	subgraph <node> ...
+		the subgraph induced by the specified nodes
 `)
 	os.Exit(2)
 }
@@ -226,7 +253,23 @@ func (g graph) transpose() graph {
 	return rev
 }
 
+// sccs returns the non-trivial strongly connected components of g:
+// those of several nodes, or of a node with a self-loop.
 func (g graph) sccs() []nodeset {
+	var sccs []nodeset
+	for _, scc := range g.allSCCs() {
+		if len(scc) == 1 {
+			if node := scc.sort()[0]; !g[node][node] {
+				continue
+			}
+		}
+		sccs = append(sccs, scc)
+	}
+	return sccs
+}
+
+// allSCCs returns the strongly connected components of g.
+func (g graph) allSCCs() []nodeset {
 	// Kosaraju's algorithm---Tarjan is overkill here.
 
 	// Forward pass.
@@ -267,9 +310,6 @@ func (g graph) sccs() []nodeset {
 		if !seen[top] {
 			scc = make(nodeset)
 			rvisit(top)
-			if len(scc) == 1 && !g[top][top] {
-				continue
-			}
 			sccs = append(sccs, scc)
 		}
 	}
@@ -347,6 +387,212 @@ func (g graph) somepath(from, to string) error {
 	return nil
 }
 
+// shortestpath prints the edges of a shortest path from one node to
+// another, the first in the order of the nodes if there are several.
+func (g graph) shortestpath(from, to string) error {
+	// Breadth-first search, from the least successors first.
+	pred := map[string]string{from: ""}
+	reached := func(node string) bool {
+		_, ok := pred[node]
+		return ok
+	}
+	queue := []string{from}
+	for len(queue) > 0 && !reached(to) {
+		node := queue[0]
+		queue = queue[1:]
+		for _, succ := range g[node].sort() {
+			if _, ok := pred[succ]; !ok {
+				pred[succ] = node
+				queue = append(queue, succ)
+			}
+		}
+	}
+	if !reached(to) {
+		return fmt.Errorf("no path from %q to %q", from, to)
+	}
+
+	var path nodelist
+	for node := to; node != from; node = pred[node] {
+		path = append(path, node)
+	}
+	path = append(path, from)
+	for i := len(path) - 1; i > 0; i-- {
+		fmt.Fprintln(stdout, path[i]+" "+path[i-1])
+	}
+	return nil
+}
+
+// cycles calls f for each elementary cycle of g, with its nodes from
+// the least, in the order of their least nodes, and then of the nodes
+// that follow.
+func (g graph) cycles(f func(cycle nodelist)) {
+	// Johnson's algorithm, "Finding all the elementary circuits of a
+	// directed graph" (1975): the cycles of each least node s are
+	// those of the component of s in the subgraph of the nodes from s.
+	nodes := make(nodeset)
+	for node := range g {
+		nodes[node] = true
+	}
+	order := nodes.sort()
+	index := make(map[string]int)
+	for i, node := range order {
+		index[node] = i
+	}
+	succs := make([][]int, len(order))
+	preds := make([][]int, len(order))
+	for i, node := range order {
+		for _, succ := range g[node].sort() {
+			succs[i] = append(succs[i], index[succ])
+			preds[index[succ]] = append(preds[index[succ]], i)
+		}
+	}
+
+	blocked := make([]bool, len(order))
+	blockers := make([]map[int]bool, len(order)) // the B sets of the paper
+	for i := range blockers {
+		blockers[i] = make(map[int]bool)
+	}
+	var unblock func(v int)
+	unblock = func(v int) {
+		blocked[v] = false
+		for w := range blockers[v] {
+			delete(blockers[v], w)
+			if blocked[w] {
+				unblock(w)
+			}
+		}
+	}
+
+	for s := range order {
+		// The component of s in the subgraph of the nodes from s is
+		// that of the nodes that both reach s and are reached from it.
+		reach := func(edges [][]int) map[int]bool {
+			seen := map[int]bool{s: true}
+			stack := []int{s}
+			for len(stack) > 0 {
+				v := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				for _, w := range edges[v] {
+					if w >= s && !seen[w] {
+						seen[w] = true
+						stack = append(stack, w)
+					}
+				}
+			}
+			return seen
+		}
+		fwd, rev := reach(succs), reach(preds)
+		inComponent := func(v int) bool { return fwd[v] && rev[v] }
+
+		for v := range order {
+			blocked[v] = false
+			blockers[v] = make(map[int]bool)
+		}
+		var stack []int
+		var circuit func(v int) bool
+		circuit = func(v int) bool {
+			found := false
+			stack = append(stack, v)
+			blocked[v] = true
+			for _, w := range succs[v] {
+				if !inComponent(w) {
+					continue
+				}
+				if w == s {
+					cycle := make(nodelist, len(stack))
+					for i, v := range stack {
+						cycle[i] = order[v]
+					}
+					f(cycle)
+					found = true
+				} else if !blocked[w] && circuit(w) {
+					found = true
+				}
+			}
+			if found {
+				unblock(v)
+			} else {
+				for _, w := range succs[v] {
+					if inComponent(w) {
+						blockers[w][v] = true
+					}
+				}
+			}
+			stack = stack[:len(stack)-1]
+			return found
+		}
+		circuit(s)
+	}
+}
+
+// condensation prints the graph of the strongly connected components
+// of g, in which each component of several nodes is a node named by the
+// quoted list of them.
+func (g graph) condensation() {
+	names := make(map[string]string) // node -> name of its component
+	for _, scc := range g.allSCCs() {
+		nodes := scc.sort()
+		name := nodes[0]
+		if len(nodes) > 1 {
+			name = strconv.Quote(strings.Join(nodes, " "))
+		}
+		for _, node := range nodes {
+			names[node] = name
+		}
+	}
+	c := make(graph)
+	for node, succs := range g {
+		c.addNode(names[node])
+		for succ := range succs {
+			if names[succ] != names[node] {
+				c.addEdges(names[node], names[succ])
+			}
+		}
+	}
+	c.println()
+}
+
+// subgraph prints the subgraph of g induced by the nodes.
+func (g graph) subgraph(nodes nodeset) {
+	sub := make(graph)
+	for node := range nodes {
+		sub.addNode(node)
+		for succ := range g[node] {
+			if nodes[succ] {
+				sub.addEdges(node, succ)
+			}
+		}
+	}
+	sub.println()
+}
+
+// println prints g, as edges, one per line, and then the nodes without
+// edges, each in order.
+func (g graph) println() {
+	var edges []string
+	hasEdges := make(nodeset)
+	for node, succs := range g {
+		for succ := range succs {
+			edges = append(edges, node+" "+succ)
+			hasEdges[node] = true
+			hasEdges[succ] = true
+		}
+	}
+	sort.Strings(edges)
+	for _, e := range edges {
+		fmt.Fprintln(stdout, e)
+	}
+	isolated := make(nodeset)
+	for node := range g {
+		if !hasEdges[node] {
+			isolated[node] = true
+		}
+	}
+	for _, node := range isolated.sort() {
+		fmt.Fprintln(stdout, node)
+	}
+}
+
 func parse(rd io.Reader) (graph, error) {
 	g := make(graph)
 
@@ -485,6 +731,21 @@ func digraph(cmd string, args []string) error {
 			return err
 		}
 
+	case "shortestpath":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: digraph shortestpath <from> <to>")
+		}
+		from, to := args[0], args[1]
+		if g[from] == nil {
+			return fmt.Errorf("no such 'from' node %q", from)
+		}
+		if g[to] == nil {
+			return fmt.Errorf("no such 'to' node %q", to)
+		}
+		if err := g.shortestpath(from, to); err != nil {
+			return err
+		}
+
 	case "sccs":
 		if len(args) != 0 {
 			return fmt.Errorf("usage: digraph sccs")
@@ -515,6 +776,31 @@ func digraph(cmd string, args []string) error {
 			}
 		}
 
+	case "condensation":
+		if len(args) != 0 {
+			return fmt.Errorf("usage: digraph condensation")
+		}
+		g.condensation()
+
+	case "cycles":
+		if len(args) != 0 {
+			return fmt.Errorf("usage: digraph cycles")
+		}
+		g.cycles(func(cycle nodelist) { cycle.println(" ") })
+
+	case "subgraph":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: digraph subgraph <node> ...")
+		}
+		nodes := make(nodeset)
+		for _, node := range args {
+			if g[node] == nil {
+				return fmt.Errorf("no such node %q", node)
+			}
+			nodes[node] = true
+		}
+		g.subgraph(nodes)
+
 	case "focus":
 		if len(args) != 1 {
 			return fmt.Errorf("usage: digraph focus <node>")
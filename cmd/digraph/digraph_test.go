@@ -28,6 +28,12 @@ b d
 c d
 d c
 e e
+`
+
+	const g3 = `
+a b c
+b a c
+c a b
 `
 
 	for _, test := range []struct {
@@ -47,6 +53,15 @@ e e
 		{"succs", g2, "succs", []string{"a"}, "b\nc\n"},
 		{"preds", g2, "preds", []string{"c"}, "a\nd\n"},
 		{"preds multiple args", g2, "preds", []string{"c", "d"}, "a\nb\nc\nd\n"},
+		{"cycles", g2, "cycles", nil, "c d\ne\n"},
+		{"cycles complete", g3, "cycles", nil, "a b\na b c\na c\na c b\nb c\n"},
+		{"cycles acyclic", g1, "cycles", nil, ""},
+		{"condensation", g2, "condensation", nil, "a \"c d\"\na b\nb \"c d\"\ne\n"},
+		{"condensation acyclic", g1, "condensation", nil, "pants belt\npants shoes\nshirt sweater\nshirt tie\nshorts pants\nsocks shoes\nsweater jacket\nhat\n"},
+		{"shortestpath", g2, "shortestpath", []string{"a", "d"}, "a b\nb d\n"},
+		{"shortestpath cycle", g2, "shortestpath", []string{"d", "d"}, ""},
+		{"subgraph", g2, "subgraph", []string{"a", "b", "e"}, "a b\ne e\n"},
+		{"subgraph isolated", g1, "subgraph", []string{"shirt", "hat", "jacket"}, "hat\njacket\nshirt\n"},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			stdin = strings.NewReader(test.input)
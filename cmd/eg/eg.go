@@ -8,39 +8,55 @@
 package main // import "golang.org/x/tools/cmd/eg"
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"go/ast"
 	"go/format"
-	"go/parser"
 	"go/token"
-	"go/types"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"strings"
 
 	exec "golang.org/x/sys/execabs"
 	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/edits"
 	"golang.org/x/tools/refactor/eg"
 )
 
 var (
 	beforeeditFlag = flag.String("beforeedit", "", "A command to exec before each file is edited (e.g. chmod, checkout).  Whitespace delimits argument words.  The string '{}' is replaced by the file name.")
 	helpFlag       = flag.Bool("help", false, "show detailed help message")
-	templateFlag   = flag.String("t", "", "template.go file specifying the refactoring")
+	templateFlag   templateFiles
 	transitiveFlag = flag.Bool("transitive", false, "apply refactoring to all dependencies too")
 	writeFlag      = flag.Bool("w", false, "rewrite input files in place (by default, the results are printed to standard output)")
+	diffFlag       = flag.Bool("diff", false, "print the changes to the input files as unified diffs instead of the results")
 	verboseFlag    = flag.Bool("v", false, "show verbose matcher diagnostics")
 )
 
+func init() {
+	flag.Var(&templateFlag, "t", "template.go file specifying the refactoring; may be repeated")
+}
+
+// templateFiles is the value of the repeatable -t flag.
+type templateFiles []string
+
+func (t *templateFiles) String() string { return strings.Join(*t, ",") }
+
+func (t *templateFiles) Set(file string) error {
+	*t = append(*t, file)
+	return nil
+}
+
 const usage = `eg: an example-based refactoring tool.
 
-Usage: eg -t template.go [-w] [-transitive] <packages>
+Usage: eg -t template.go [-t template2.go ...] [-w] [-diff] [-transitive] <packages>
 
 -help            show detailed help message
--t template.go	 specifies the template file (use -help to see explanation)
+-t template.go	 specifies a template file (use -help to see explanation).
+                 Several templates are applied in the order of their -t flags.
 -w          	 causes files to be re-written in place.
+-diff            causes the changes to be printed as unified diffs.
 -transitive 	 causes all dependencies to be refactored too.
 -v               show verbose matcher diagnostics
 -beforeedit cmd  a command to exec before each file is modified.
@@ -69,19 +85,10 @@ func doMain() error {
 		os.Exit(1)
 	}
 
-	if *templateFlag == "" {
+	if len(templateFlag) == 0 {
 		return fmt.Errorf("no -t template.go file specified")
 	}
 
-	tAbs, err := filepath.Abs(*templateFlag)
-	if err != nil {
-		return err
-	}
-	template, err := ioutil.ReadFile(tAbs)
-	if err != nil {
-		return err
-	}
-
 	cfg := &packages.Config{
 		Fset:  token.NewFileSet(),
 		Mode:  packages.NeedTypesInfo | packages.NeedName | packages.NeedTypes | packages.NeedSyntax | packages.NeedImports | packages.NeedDeps | packages.NeedCompiledGoFiles,
@@ -93,35 +100,13 @@ func doMain() error {
 		return err
 	}
 
-	tFile, err := parser.ParseFile(cfg.Fset, tAbs, template, parser.ParseComments)
-	if err != nil {
-		return err
-	}
-
-	// Type-check the template.
-	tInfo := types.Info{
-		Types:      make(map[ast.Expr]types.TypeAndValue),
-		Defs:       make(map[*ast.Ident]types.Object),
-		Uses:       make(map[*ast.Ident]types.Object),
-		Implicits:  make(map[ast.Node]types.Object),
-		Selections: make(map[*ast.SelectorExpr]*types.Selection),
-		Scopes:     make(map[ast.Node]*types.Scope),
-	}
-	conf := types.Config{
-		Importer: pkgsImporter(pkgs),
-	}
-	tPkg, err := conf.Check("egtemplate", cfg.Fset, []*ast.File{tFile}, &tInfo)
+	// Parse, type-check and analyze the templates.
+	xforms, err := eg.LoadTemplates(cfg, pkgs, *verboseFlag, templateFlag...)
 	if err != nil {
 		return err
 	}
 
-	// Analyze the template.
-	xform, err := eg.NewTransformer(cfg.Fset, tPkg, tFile, &tInfo, *verboseFlag)
-	if err != nil {
-		return err
-	}
-
-	// Apply it to the input packages.
+	// Apply them to the input packages.
 	var all []*packages.Package
 	if *transitiveFlag {
 		packages.Visit(pkgs, nil, func(p *packages.Package) { all = append(all, p) })
@@ -129,43 +114,39 @@ func doMain() error {
 		all = pkgs
 	}
 	var hadErrors bool
-	for _, pkg := range pkgs {
-		for i, filename := range pkg.CompiledGoFiles {
-			if filename == tAbs {
-				// Don't rewrite the template file.
-				continue
+	for _, tf := range eg.TransformPackages(all, xforms) {
+		filename, file := tf.Filename, tf.File
+		fmt.Fprintf(os.Stderr, "=== %s (%d matches)\n", filename, tf.Matches)
+		if *diffFlag {
+			if err := printDiff(cfg.Fset, filename, file); err != nil {
+				fmt.Fprintf(os.Stderr, "eg: %s\n", err)
+				hadErrors = true
 			}
-			file := pkg.Syntax[i]
-			n := xform.Transform(pkg.TypesInfo, pkg.Types, file)
-			if n == 0 {
-				continue
-			}
-			fmt.Fprintf(os.Stderr, "=== %s (%d matches)\n", filename, n)
-			if *writeFlag {
-				// Run the before-edit command (e.g. "chmod +w",  "checkout") if any.
-				if *beforeeditFlag != "" {
-					args := strings.Fields(*beforeeditFlag)
-					// Replace "{}" with the filename, like find(1).
-					for i := range args {
-						if i > 0 {
-							args[i] = strings.Replace(args[i], "{}", filename, -1)
-						}
-					}
-					cmd := exec.Command(args[0], args[1:]...)
-					cmd.Stdout = os.Stdout
-					cmd.Stderr = os.Stderr
-					if err := cmd.Run(); err != nil {
-						fmt.Fprintf(os.Stderr, "Warning: edit hook %q failed (%s)\n",
-							args, err)
+		}
+		if *writeFlag {
+			// Run the before-edit command (e.g. "chmod +w",  "checkout") if any.
+			if *beforeeditFlag != "" {
+				args := strings.Fields(*beforeeditFlag)
+				// Replace "{}" with the filename, like find(1).
+				for i := range args {
+					if i > 0 {
+						args[i] = strings.Replace(args[i], "{}", filename, -1)
 					}
 				}
-				if err := eg.WriteAST(cfg.Fset, filename, file); err != nil {
-					fmt.Fprintf(os.Stderr, "eg: %s\n", err)
-					hadErrors = true
+				cmd := exec.Command(args[0], args[1:]...)
+				cmd.Stdout = os.Stdout
+				cmd.Stderr = os.Stderr
+				if err := cmd.Run(); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: edit hook %q failed (%s)\n",
+						args, err)
 				}
-			} else {
-				format.Node(os.Stdout, cfg.Fset, file)
 			}
+			if err := eg.WriteAST(cfg.Fset, filename, file); err != nil {
+				fmt.Fprintf(os.Stderr, "eg: %s\n", err)
+				hadErrors = true
+			}
+		} else if !*diffFlag {
+			format.Node(os.Stdout, cfg.Fset, file)
 		}
 	}
 	if hadErrors {
@@ -175,18 +156,17 @@ func doMain() error {
 	return nil
 }
 
-type pkgsImporter []*packages.Package
-
-func (p pkgsImporter) Import(path string) (tpkg *types.Package, err error) {
-	packages.Visit([]*packages.Package(p), func(pkg *packages.Package) bool {
-		if pkg.PkgPath == path {
-			tpkg = pkg.Types
-			return false
-		}
-		return true
-	}, nil)
-	if tpkg != nil {
-		return tpkg, nil
+// printDiff prints the changes to the named file that its transformed
+// syntax tree represents, as a unified diff.
+func printDiff(fset *token.FileSet, filename string, file *ast.File) error {
+	before, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var after bytes.Buffer
+	if err := format.Node(&after, fset, file); err != nil {
+		return err
 	}
-	return nil, fmt.Errorf("package %q not found", path)
+	wd, _ := os.Getwd()
+	return edits.WriteUnifiedDiff(os.Stdout, wd, filename, before, after.Bytes())
 }
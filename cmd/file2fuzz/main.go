@@ -3,20 +3,28 @@
 // license that can be found in the LICENSE file.
 
 // file2fuzz converts binary files, such as those used by go-fuzz, to the Go
-// fuzzing corpus format.
+// fuzzing corpus format, and back.
 //
 // Usage:
 //
-//	file2fuzz [-o output] [input...]
+//	file2fuzz [-r] [-o output] [input...]
 //
 // The default behavior is to read input from stdin and write the converted
 // output to stdout. If any position arguments are provided stdin is ignored
-// and the arguments are assumed to be input files to convert.
+// and the arguments are assumed to be input files to convert. An argument
+// that is a directory stands for all the files in it and its
+// subdirectories, other than those whose names begin with a dot.
 //
 // The -o flag provides an path to write output files to. If only one positional
 // argument is specified it may be a file path or an existing directory, if there are
-// multiple inputs specified it must be a directory. If a directory is provided
-// the name of the file will be the SHA-256 hash of its contents.
+// multiple inputs specified, or a directory, it must be a directory. If a directory
+// is provided the name of the file will be the SHA-256 hash of its contents, so
+// that inputs with the same contents are written once, and files already in the
+// directory are not written again.
+//
+// The -r flag reverses the conversion: the inputs are Go fuzzing corpus files,
+// each of a single []byte or string value, and the outputs are the values, as
+// raw bytes.
 package main
 
 import (
@@ -24,11 +32,16 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 // encVersion1 is version 1 Go fuzzer corpus encoding.
@@ -38,16 +51,58 @@ func encodeByteSlice(b []byte) []byte {
 	return []byte(fmt.Sprintf("%s\n[]byte(%q)", encVersion1, b))
 }
 
+// decodeByteSlice returns the value of a corpus file of a single []byte
+// or string value.
+func decodeByteSlice(b []byte) ([]byte, error) {
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	if lines[0] != encVersion1 {
+		return nil, fmt.Errorf("not a Go fuzzing corpus file: missing %q header", encVersion1)
+	}
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("corpus file has %d values, want 1", len(lines)-1)
+	}
+	expr, err := parser.ParseExpr(lines[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed corpus value: %v", err)
+	}
+	if call, ok := expr.(*ast.CallExpr); ok && len(call.Args) == 1 && isByteSliceOrString(call.Fun) {
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return nil, fmt.Errorf("malformed corpus value: %v", err)
+			}
+			return []byte(s), nil
+		}
+	}
+	return nil, fmt.Errorf("corpus value %s is not a []byte or string", lines[1])
+}
+
+// isByteSliceOrString reports whether the expression is []byte or string.
+func isByteSliceOrString(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name == "string"
+	case *ast.ArrayType:
+		elem, ok := e.Elt.(*ast.Ident)
+		return e.Len == nil && ok && (elem.Name == "byte" || elem.Name == "uint8")
+	}
+	return false
+}
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: file2fuzz [-o output] [input...]\nconverts files to Go fuzzer corpus format\n")
-	fmt.Fprintf(os.Stderr, "\tinput: files to convert\n")
+	fmt.Fprintf(os.Stderr, "usage: file2fuzz [-r] [-o output] [input...]\nconverts files to Go fuzzer corpus format\n")
+	fmt.Fprintf(os.Stderr, "\tinput: files or directories to convert\n")
 	fmt.Fprintf(os.Stderr, "\t-o: where to write converted file(s)\n")
+	fmt.Fprintf(os.Stderr, "\t-r: convert Go fuzzer corpus files back to their contents\n")
 	os.Exit(2)
 }
 func dirWriter(dir string) func([]byte) error {
 	return func(b []byte) error {
 		sum := fmt.Sprintf("%x", sha256.Sum256(b))
 		name := filepath.Join(dir, sum)
+		if _, err := os.Stat(name); err == nil {
+			return nil // a duplicate
+		}
 		if err := os.MkdirAll(dir, 0777); err != nil {
 			return err
 		}
@@ -59,29 +114,52 @@ func dirWriter(dir string) func([]byte) error {
 	}
 }
 
-func convert(inputArgs []string, outputArg string) error {
-	var input []io.Reader
-	if args := inputArgs; len(args) == 0 {
-		input = []io.Reader{os.Stdin}
-	} else {
-		for _, a := range args {
-			f, err := os.Open(a)
+// inputFiles returns the names of the files of the input arguments, in
+// which a directory stands for the files in it and its subdirectories,
+// and reports whether there is a directory among them.
+func inputFiles(args []string) (files []string, hasDir bool, err error) {
+	for _, a := range args {
+		fi, err := os.Stat(a)
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to open %q: %s", a, err)
+		}
+		if !fi.IsDir() {
+			files = append(files, a)
+			continue
+		}
+		hasDir = true
+		err = filepath.Walk(a, func(name string, fi os.FileInfo, err error) error {
 			if err != nil {
-				return fmt.Errorf("unable to open %q: %s", a, err)
+				return err
 			}
-			defer f.Close()
-			if fi, err := f.Stat(); err != nil {
-				return fmt.Errorf("unable to open %q: %s", a, err)
-			} else if fi.IsDir() {
-				return fmt.Errorf("%q is a directory, not a file", a)
+			if name != a && strings.HasPrefix(fi.Name(), ".") {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if fi.Mode().IsRegular() {
+				files = append(files, name)
 			}
-			input = append(input, f)
+			return nil
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to read directory %q: %s", a, err)
 		}
 	}
+	return files, hasDir, nil
+}
+
+func convert(inputArgs []string, outputArg string, reverse bool) error {
+	files, hasDir, err := inputFiles(inputArgs)
+	if err != nil {
+		return err
+	}
+	multiple := len(inputArgs) > 1 || hasDir
 
 	var output func([]byte) error
 	if outputArg == "" {
-		if len(inputArgs) > 1 {
+		if multiple {
 			return errors.New("-o required with multiple input files")
 		}
 		output = func(b []byte) error {
@@ -89,7 +167,7 @@ func convert(inputArgs []string, outputArg string) error {
 			return err
 		}
 	} else {
-		if len(inputArgs) > 1 {
+		if multiple {
 			output = dirWriter(outputArg)
 		} else {
 			if fi, err := os.Stat(outputArg); err != nil && !os.IsNotExist(err) {
@@ -104,12 +182,23 @@ func convert(inputArgs []string, outputArg string) error {
 		}
 	}
 
-	for _, f := range input {
-		b, err := ioutil.ReadAll(f)
+	names := files
+	if len(inputArgs) == 0 {
+		names = []string{"stdin"}
+	}
+	for _, name := range names {
+		b, err := readInput(name, len(inputArgs) == 0)
 		if err != nil {
-			return fmt.Errorf("unable to read input: %s", err)
+			return err
 		}
-		if err := output(encodeByteSlice(b)); err != nil {
+		if reverse {
+			if b, err = decodeByteSlice(b); err != nil {
+				return fmt.Errorf("unable to convert %s: %s", name, err)
+			}
+		} else {
+			b = encodeByteSlice(b)
+		}
+		if err := output(b); err != nil {
 			return fmt.Errorf("unable to write output: %s", err)
 		}
 	}
@@ -117,15 +206,36 @@ func convert(inputArgs []string, outputArg string) error {
 	return nil
 }
 
+// readInput returns the content of the named input file, or of the
+// standard input if stdin is set. Each file is closed once it is read,
+// so that any number of them may be converted.
+func readInput(name string, stdin bool) ([]byte, error) {
+	var f io.Reader = os.Stdin
+	if !stdin {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open %q: %s", name, err)
+		}
+		defer file.Close()
+		f = file
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read input: %s", err)
+	}
+	return b, nil
+}
+
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("file2fuzz: ")
 
 	output := flag.String("o", "", "where to write converted file(s)")
+	reverse := flag.Bool("r", false, "convert Go fuzzer corpus files back to their contents")
 	flag.Usage = usage
 	flag.Parse()
 
-	if err := convert(flag.Args(), *output); err != nil {
+	if err := convert(flag.Args(), *output, *reverse); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -63,6 +63,7 @@ func TestFile2Fuzz(t *testing.T) {
 		inputFiles     []file
 		expectedStdout string
 		expectedFiles  []file
+		expectedCount  int // the number of output files in the output directory, if not zero
 		expectedError  string
 	}{
 		{
@@ -104,6 +105,54 @@ func TestFile2Fuzz(t *testing.T) {
 				{name: "output/28059db30ce420ff65b2c29b749804c69c601aeca21b3cbf0644244ff080d7a5", content: "go test fuzz v1\n[]byte(\"hello :)\")"},
 			},
 		},
+		{
+			name: "input directory, output directory",
+			args: []string{"-o", "output", "input"},
+			inputFiles: []file{
+				{name: "output", dir: true},
+				{name: "input", dir: true},
+				{name: "input/a", content: "hello"},
+				{name: "input/sub", dir: true},
+				{name: "input/sub/b", content: "hello :)"},
+				{name: "input/sub/c", content: "hello"},
+				{name: "input/.hidden", content: "ignored"},
+			},
+			expectedFiles: []file{
+				{name: "output/ffc7b87a0377262d4f77926bd235551d78e6037bbe970d81ec39ac1d95542f7b", content: "go test fuzz v1\n[]byte(\"hello\")"},
+				{name: "output/28059db30ce420ff65b2c29b749804c69c601aeca21b3cbf0644244ff080d7a5", content: "go test fuzz v1\n[]byte(\"hello :)\")"},
+			},
+			expectedCount: 2,
+		},
+		{
+			name:          "input directory, no output",
+			args:          []string{"input"},
+			inputFiles:    []file{{name: "input", dir: true}, {name: "input/a", content: "hello"}},
+			expectedError: "file2fuzz: -o required with multiple input files\n",
+		},
+		{
+			name:           "reverse, stdin, stdout",
+			args:           []string{"-r"},
+			stdin:          "go test fuzz v1\n[]byte(\"hello\\x00\")\n",
+			expectedStdout: "hello\x00",
+		},
+		{
+			name:          "reverse, input files, output directory",
+			args:          []string{"-r", "-o", "output", "input", "input-2"},
+			inputFiles:    []file{{name: "output", dir: true}, {name: "input", content: "go test fuzz v1\n[]byte(\"hello\")"}, {name: "input-2", content: "go test fuzz v1\nstring(`hello :)`)\n"}},
+			expectedFiles: []file{{name: "output/2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", content: "hello"}, {name: "output/f034f9986ae0fa2e3de3b40fcc378bacf6a5a01d269af841121501f610ddc65b", content: "hello :)"}},
+		},
+		{
+			name:          "reverse, several values",
+			args:          []string{"-r", "input"},
+			inputFiles:    []file{{name: "input", content: "go test fuzz v1\n[]byte(\"a\")\nint(1)\n"}},
+			expectedError: "file2fuzz: unable to convert input: corpus file has 2 values, want 1\n",
+		},
+		{
+			name:          "reverse, not a []byte",
+			args:          []string{"-r", "input"},
+			inputFiles:    []file{{name: "input", content: "go test fuzz v1\nint(1)\n"}},
+			expectedError: "file2fuzz: unable to convert input: corpus value int(1) is not a []byte or string\n",
+		},
 		{
 			name:          "input files, no output",
 			args:          []string{"input", "input-2"},
@@ -150,6 +199,15 @@ func TestFile2Fuzz(t *testing.T) {
 					t.Fatalf("expected output file %q contains unexpected content: got %s, want %s", f.name, string(c), f.content)
 				}
 			}
+			if tc.expectedCount > 0 {
+				fis, err := ioutil.ReadDir(filepath.Join(tmp, "output"))
+				if err != nil {
+					t.Fatalf("failed to read output directory: %s", err)
+				}
+				if len(fis) != tc.expectedCount {
+					t.Fatalf("got %d output files, want %d", len(fis), tc.expectedCount)
+				}
+			}
 		})
 	}
 }
@@ -0,0 +1,155 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The goanalyze command applies all the analyzers of
+// golang.org/x/tools/go/analysis/passes to the specified packages of Go
+// source code. It is the reference driver of the analysis framework: a
+// project can use it as is, instead of writing its own multichecker.
+//
+// Usage:
+//
+//	goanalyze [-flag] [package]
+//
+// Run 'goanalyze help' for the list of analyzers, and 'goanalyze help
+// name' for the documentation and flags of one of them.
+//
+// The analyzers to run, their flags and the severity of their
+// diagnostics are set by the nearest .staticanalysis.toml file, or that
+// of the -config flag, and by the -enable, -disable and -severity
+// flags. For example,
+//
+//	goanalyze -disable 'fieldalignment,shadow' ./...
+//
+// runs all the analyzers but fieldalignment and shadow.
+//
+// A project that adopts an analyzer may record its existing
+// diagnostics in a baseline file, and report only new ones:
+//
+//	goanalyze -baseline=analysis-baseline.json -write-baseline ./...
+//	goanalyze -baseline=analysis-baseline.json ./...
+//
+// The -diff-base flag restricts the analysis to the packages affected by
+// the changes since a git revision, and -diff-lines to the diagnostics
+// on the changed lines, as on a pull request:
+//
+//	goanalyze -diff-base=origin/master -diff-lines ./...
+//
+// The -sarif flag prints the diagnostics as a SARIF log, which code
+// scanning services display, and -json as JSON. The -fix flag applies
+// the suggested fixes.
+//
+// Like the other multicheckers, goanalyze may be run by go vet too:
+//
+//	go vet -vettool=$(which goanalyze) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/multichecker"
+
+	"golang.org/x/tools/go/analysis/passes/apicompat"
+	"golang.org/x/tools/go/analysis/passes/asmdecl"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/atomic"
+	"golang.org/x/tools/go/analysis/passes/atomicalign"
+	"golang.org/x/tools/go/analysis/passes/bodyclose"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/buildtag"
+	"golang.org/x/tools/go/analysis/passes/cgocall"
+	"golang.org/x/tools/go/analysis/passes/composite"
+	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/deepequal"
+	"golang.org/x/tools/go/analysis/passes/deepequalerrors"
+	"golang.org/x/tools/go/analysis/passes/deprecated"
+	"golang.org/x/tools/go/analysis/passes/embeddirective"
+	"golang.org/x/tools/go/analysis/passes/errcheck"
+	"golang.org/x/tools/go/analysis/passes/errorsas"
+	"golang.org/x/tools/go/analysis/passes/exhaustive"
+	"golang.org/x/tools/go/analysis/passes/fieldalignment"
+	"golang.org/x/tools/go/analysis/passes/framepointer"
+	"golang.org/x/tools/go/analysis/passes/goroutineleak"
+	"golang.org/x/tools/go/analysis/passes/httpresponse"
+	"golang.org/x/tools/go/analysis/passes/ifaceassert"
+	"golang.org/x/tools/go/analysis/passes/ineffassign"
+	"golang.org/x/tools/go/analysis/passes/lockorder"
+	"golang.org/x/tools/go/analysis/passes/loopclosure"
+	"golang.org/x/tools/go/analysis/passes/lostcancel"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/reflectvaluecompare"
+	"golang.org/x/tools/go/analysis/passes/resourceclose"
+	"golang.org/x/tools/go/analysis/passes/secrets"
+	"golang.org/x/tools/go/analysis/passes/shadow"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/sigchanyzer"
+	"golang.org/x/tools/go/analysis/passes/sortslice"
+	"golang.org/x/tools/go/analysis/passes/sqlinjection"
+	"golang.org/x/tools/go/analysis/passes/stdmethods"
+	"golang.org/x/tools/go/analysis/passes/stringintconv"
+	"golang.org/x/tools/go/analysis/passes/structtag"
+	"golang.org/x/tools/go/analysis/passes/testhelper"
+	"golang.org/x/tools/go/analysis/passes/testinggoroutine"
+	"golang.org/x/tools/go/analysis/passes/tests"
+	"golang.org/x/tools/go/analysis/passes/timeformat"
+	"golang.org/x/tools/go/analysis/passes/unmarshal"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unsafeptr"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+	"golang.org/x/tools/go/analysis/passes/unusedwrite"
+)
+
+func main() {
+	multichecker.Main(
+		apicompat.Analyzer,
+		asmdecl.Analyzer,
+		assign.Analyzer,
+		atomic.Analyzer,
+		atomicalign.Analyzer,
+		bodyclose.Analyzer,
+		bools.Analyzer,
+		buildtag.Analyzer,
+		cgocall.Analyzer,
+		composite.Analyzer,
+		copylock.Analyzer,
+		deepequal.Analyzer,
+		deepequalerrors.Analyzer,
+		deprecated.Analyzer,
+		embeddirective.Analyzer,
+		errcheck.Analyzer,
+		errorsas.Analyzer,
+		exhaustive.Analyzer,
+		fieldalignment.Analyzer,
+		framepointer.Analyzer,
+		goroutineleak.Analyzer,
+		httpresponse.Analyzer,
+		ifaceassert.Analyzer,
+		ineffassign.Analyzer,
+		lockorder.Analyzer,
+		loopclosure.Analyzer,
+		lostcancel.Analyzer,
+		nilfunc.Analyzer,
+		nilness.Analyzer,
+		printf.Analyzer,
+		reflectvaluecompare.Analyzer,
+		resourceclose.Analyzer,
+		secrets.Analyzer,
+		shadow.Analyzer,
+		shift.Analyzer,
+		sigchanyzer.Analyzer,
+		sortslice.Analyzer,
+		sqlinjection.Analyzer,
+		stdmethods.Analyzer,
+		stringintconv.Analyzer,
+		structtag.Analyzer,
+		testhelper.Analyzer,
+		testinggoroutine.Analyzer,
+		tests.Analyzer,
+		timeformat.Analyzer,
+		unmarshal.Analyzer,
+		unreachable.Analyzer,
+		unsafeptr.Analyzer,
+		unusedresult.Analyzer,
+		unusedwrite.Analyzer,
+	)
+}
@@ -41,6 +41,49 @@ the configuration file to ignore when scanning. No globbing or regex
 patterns are allowed. Use the "-v" verbose flag to verify it's
 working and see what goimports is doing.
 
+To resolve the identifiers of a file, goimports also considers the
+other files of its package, whose globals the file may refer to and
+whose imports it prefers. It skips the globals of the files that are
+never built together with the file because of their build constraints,
+such as the _windows.go files of a package when fixing a _linux.go
+file, and prefers their imports only to those of packages outside the
+standard library.
+
+In module mode, goimports keeps an index of the packages of the module
+cache in the user cache directory, so that the module cache is walked
+only when modules have been added to or removed from it. In either mode,
+it also caches there the exports of the packages it has parsed to
+resolve identifiers, which are parsed again only when their files
+change. The "-noindex" flag disables the index and the cache.
+
+The "-offline" flag prevents goimports from running the go command, and
+so from accessing the network: the environment is then taken from the
+OS environment and the file written by "go env -w", and the modules in
+scope from the go.mod and go.work files of the current directory and
+its parents. Only the modules that the go.mod files require directly
+are in scope, at the versions they require, and only if they are in the
+module cache.
+
+To avoid the cost of finding the packages that files may import each
+time goimports runs, an editor may instead run a goimports server,
+which keeps what it has found from one file to the next. The
+"-serve=addr" flag starts a server that listens on the unix socket
+addr, or reads requests from its standard input if addr is "-". Each
+request and each response is a JSON value on one line:
+
+	{"Filename": "/abs/file.go", "Src": "<base64>", "LocalPrefix": "...", "FormatOnly": false, "AllErrors": false, "Fragment": false}
+	{"Src": "<base64>", "Error": "..."}
+
+where Src is the contents of the file, encoded in base64, Filename
+determines the package and module of the file as -srcdir does,
+LocalPrefix, FormatOnly and AllErrors are the options of the -local,
+-format-only and -e flags, and Fragment permits the file to be a
+fragment of a Go file, as the standard input may be. The "-remote=addr" flag makes goimports process the files
+of its arguments with the server on the unix socket addr, or by itself
+if there is none. A server refreshes what it knows of the packages of
+each module in the background, and starts over when the module's
+go.mod file changes.
+
 File bugs or feature requests at:
 
 	https://golang.org/issues/new?title=x/tools/cmd/goimports:+
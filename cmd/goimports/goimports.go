@@ -27,10 +27,11 @@ import (
 
 var (
 	// main operation modes
-	list   = flag.Bool("l", false, "list files whose formatting differs from goimport's")
-	write  = flag.Bool("w", false, "write result to (source) file instead of stdout")
-	doDiff = flag.Bool("d", false, "display diffs instead of rewriting files")
-	srcdir = flag.String("srcdir", "", "choose imports as if source code is from `dir`. When operating on a single file, dir may instead be the complete file name.")
+	list    = flag.Bool("l", false, "list files whose formatting differs from goimport's")
+	write   = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	doDiff  = flag.Bool("d", false, "display diffs instead of rewriting files")
+	srcdir  = flag.String("srcdir", "", "choose imports as if source code is from `dir`. When operating on a single file, dir may instead be the complete file name.")
+	noIndex = flag.Bool("noindex", false, "don't keep a persistent index of the module cache, and a cache of package exports, in the user cache directory")
 
 	verbose bool // verbose logging
 
@@ -54,6 +55,7 @@ func init() {
 	flag.BoolVar(&options.AllErrors, "e", false, "report all errors (not just the first 10 on different lines)")
 	flag.StringVar(&options.LocalPrefix, "local", "", "put imports beginning with this string after 3rd-party packages; comma-separated list")
 	flag.BoolVar(&options.FormatOnly, "format-only", false, "if true, don't fix imports and only format. In this mode, goimports is effectively gofmt, with the addition that imports are grouped into sections.")
+	flag.BoolVar(&options.Env.Offline, "offline", false, "never run the go command, or access the network; find modules from the go.mod and go.work files instead")
 }
 
 func report(err error) {
@@ -139,7 +141,7 @@ func processFile(filename string, in io.Reader, out io.Writer, argType argumentT
 		}
 	}
 
-	res, err := imports.Process(target, src, opt)
+	res, err := process(target, src, opt)
 	if err != nil {
 		return err
 	}
@@ -263,12 +265,25 @@ func gofmtMain() {
 		log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 		options.Env.Logf = log.Printf
 	}
+	if !*noIndex {
+		if dir, err := os.UserCacheDir(); err == nil {
+			options.Env.IndexDir = filepath.Join(dir, "goimports")
+		}
+	}
 	if options.TabWidth < 0 {
 		fmt.Fprintf(os.Stderr, "negative tabwidth %d\n", options.TabWidth)
 		exitCode = 2
 		return
 	}
 
+	if *serveAddr != "" {
+		if err := serve(*serveAddr); err != nil {
+			log.Print(err)
+			exitCode = 2
+		}
+		return
+	}
+
 	if len(paths) == 0 {
 		if err := processFile("<standard input>", os.Stdin, os.Stdout, fromStdin); err != nil {
 			report(err)
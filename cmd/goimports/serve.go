@@ -0,0 +1,300 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// This file defines the goimports server, which processes the files
+// that editors send it and keeps what it knows of the packages they may
+// import from one request to the next, and its client.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/imports"
+)
+
+var (
+	serveAddr  = flag.String("serve", "", "serve requests on the unix socket `addr`, or on the standard input and output if addr is \"-\", instead of processing files")
+	remoteAddr = flag.String("remote", "", "process files with the goimports server on the unix socket `addr`, or locally if there is none")
+)
+
+// A request asks the server to process a file. Requests and responses
+// are encoded as JSON values, one per line.
+type request struct {
+	Filename string // the absolute name of the file, which determines its package and module
+	Src      []byte // the contents of the file
+
+	// The options of the client.
+	LocalPrefix string `json:",omitempty"`
+	FormatOnly  bool   `json:",omitempty"`
+	AllErrors   bool   `json:",omitempty"`
+	Fragment    bool   `json:",omitempty"`
+}
+
+// A response holds the processed file, or the error that processing it
+// reported.
+type response struct {
+	Src   []byte `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+// A server processes requests with the environments of the modules of
+// their files.
+type server struct {
+	mu      sync.Mutex
+	modules map[string]*moduleState // by root directory
+}
+
+// A moduleState holds the environment of the files of one module, or
+// of the files outside any module, whose caches persist across
+// requests. It is refreshed in the background after requests, as
+// gopls refreshes the environments of its views.
+type moduleState struct {
+	dir string // the root directory of the module
+
+	mu              sync.Mutex
+	env             *imports.ProcessEnv
+	modFile         []byte // the contents of go.mod when env was last cleared, or nil
+	refreshDuration time.Duration
+	refreshTimer    *time.Timer
+}
+
+// serve serves requests on the unix socket addr, or on the standard
+// input and output if addr is "-", until the standard input is closed.
+func serve(addr string) error {
+	s := &server{modules: make(map[string]*moduleState)}
+	if addr == "-" {
+		return s.serveConn(os.Stdin, os.Stdout)
+	}
+
+	if conn, err := net.Dial("unix", addr); err == nil {
+		conn.Close()
+		return fmt.Errorf("a server is already listening on %s", addr)
+	}
+	// Remove the socket of a server that is no longer running,
+	// but nothing else, such as a file named by mistake.
+	if fi, err := os.Lstat(addr); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", addr)
+		}
+		if err := os.Remove(addr); err != nil {
+			return err
+		}
+	}
+	l, err := net.Listen("unix", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := s.serveConn(conn, conn); err != nil && verbose {
+				log.Printf("serving %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// serveConn serves the requests read from r, in order, until r is
+// exhausted.
+func (s *server) serveConn(r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for {
+		var req request
+		if err := dec.Decode(&req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var resp response
+		if res, err := s.process(&req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Src = res
+		}
+		if err := enc.Encode(&resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) process(req *request) ([]byte, error) {
+	if !filepath.IsAbs(req.Filename) {
+		return nil, fmt.Errorf("file name %q is not absolute", req.Filename)
+	}
+	m := s.module(filepath.Dir(req.Filename))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkModFile()
+
+	opt := *options
+	opt.Env = m.env
+	opt.LocalPrefix = req.LocalPrefix
+	opt.FormatOnly = req.FormatOnly
+	opt.AllErrors = req.AllErrors
+	opt.Fragment = req.Fragment
+	res, err := imports.Process(req.Filename, req.Src, &opt)
+
+	if m.refreshTimer == nil {
+		// Don't refresh more than twice per minute, or spend more
+		// than a couple percent of the time refreshing.
+		delay := 30 * time.Second
+		if adaptive := 50 * m.refreshDuration; adaptive > delay {
+			delay = adaptive
+		}
+		m.refreshTimer = time.AfterFunc(delay, m.refresh)
+	}
+	return res, err
+}
+
+// module returns the state of the module whose files include those of
+// dir.
+func (s *server) module(dir string) *moduleState {
+	root := ""
+	for d := dir; ; {
+		if fi, err := os.Stat(filepath.Join(d, "go.mod")); err == nil && !fi.IsDir() {
+			root = d
+			break
+		}
+		parent := filepath.Dir(d)
+		if len(parent) >= len(d) {
+			break // reached top of file system, no go.mod
+		}
+		d = parent
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.modules[root]
+	if !ok {
+		m = &moduleState{dir: root}
+		if root == "" {
+			// The files outside any module share one environment,
+			// whose go command runs in the first of their directories.
+			m.dir = dir
+		}
+		m.env = m.newEnv()
+		s.modules[root] = m
+	}
+	return m
+}
+
+func (m *moduleState) newEnv() *imports.ProcessEnv {
+	env := options.Env.CopyConfig()
+	env.WorkingDir = m.dir
+	return env
+}
+
+// checkModFile clears the caches of the environment if go.mod has
+// changed since they were last cleared. m.mu must be held.
+func (m *moduleState) checkModFile() {
+	data, _ := ioutil.ReadFile(filepath.Join(m.dir, "go.mod"))
+	if m.modFile != nil && !bytes.Equal(data, m.modFile) {
+		if verbose {
+			log.Printf("%s changed; clearing caches", filepath.Join(m.dir, "go.mod"))
+		}
+		resolver, err := m.env.GetResolver()
+		if modResolver, ok := resolver.(*imports.ModuleResolver); err == nil && ok && data != nil {
+			modResolver.ClearForNewMod()
+		} else {
+			m.env = m.newEnv()
+		}
+	}
+	m.modFile = data
+}
+
+// refresh rescans the packages of the environment, to find those added
+// since the last scan.
+func (m *moduleState) refresh() {
+	start := time.Now()
+
+	m.mu.Lock()
+	env := m.env
+	if resolver, err := env.GetResolver(); err == nil {
+		resolver.ClearForNewScan()
+	}
+	m.mu.Unlock()
+
+	err := imports.PrimeCache(context.Background(), env)
+	if verbose {
+		log.Printf("refreshed %s after %v (err=%v)", m.dir, time.Since(start), err)
+	}
+
+	m.mu.Lock()
+	m.refreshDuration = time.Since(start)
+	m.refreshTimer = nil
+	m.mu.Unlock()
+}
+
+// A remoteError is an error that the server reported for a request.
+type remoteError string
+
+func (e remoteError) Error() string { return string(e) }
+
+// process processes the file as imports.Process does, with the server
+// on *remoteAddr if there is one.
+func process(filename string, src []byte, opt *imports.Options) ([]byte, error) {
+	if *remoteAddr != "" {
+		res, err := processRemote(*remoteAddr, filename, src, opt)
+		if _, ok := err.(remoteError); err == nil || ok {
+			return res, err
+		}
+		if verbose {
+			log.Printf("processing %s locally: %v", filename, err)
+		}
+	}
+	return imports.Process(filename, src, opt)
+}
+
+// processRemote asks the server on the unix socket addr to process the
+// file.
+func processRemote(addr, filename string, src []byte, opt *imports.Options) ([]byte, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := &request{
+		Filename:    abs,
+		Src:         src,
+		LocalPrefix: opt.LocalPrefix,
+		FormatOnly:  opt.FormatOnly,
+		AllErrors:   opt.AllErrors,
+		Fragment:    opt.Fragment,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, remoteError(resp.Error)
+	}
+	return resp.Src, nil
+}
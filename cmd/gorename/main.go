@@ -16,8 +16,11 @@ import (
 	"go/build"
 	"log"
 	"os"
+	"strings"
 
+	exec "golang.org/x/sys/execabs"
 	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/refactor/rename"
 )
 
@@ -33,7 +36,7 @@ func init() {
 	flag.BoolVar(&rename.Force, "force", false, "proceed, even if conflicts were reported")
 	flag.BoolVar(&rename.Verbose, "v", false, "print verbose information")
 	flag.BoolVar(&rename.Diff, "d", false, "display diffs instead of rewriting files")
-	flag.StringVar(&rename.DiffCmd, "diffcmd", "diff", "diff command invoked when using -d")
+	flag.StringVar(&rename.DiffCmd, "diffcmd", "diff", "diff command invoked when using -d in GOPATH mode")
 }
 
 func main() {
@@ -49,10 +52,35 @@ func main() {
 		return
 	}
 
-	if err := rename.Main(&build.Default, *offsetFlag, *fromFlag, *toFlag); err != nil {
+	var err error
+	if moduleMode() {
+		cfg := &packages.Config{}
+		if len(build.Default.BuildTags) > 0 {
+			cfg.BuildFlags = []string{"-tags=" + strings.Join(build.Default.BuildTags, ",")}
+		}
+		err = rename.ModuleMain(cfg, *offsetFlag, *fromFlag, *toFlag)
+	} else {
+		err = rename.Main(&build.Default, *offsetFlag, *fromFlag, *toFlag)
+	}
+	if err != nil {
 		if err != rename.ConflictError {
 			log.Fatal(err)
 		}
 		os.Exit(1)
 	}
 }
+
+// moduleMode reports whether the go command runs in module mode, with
+// a main module or workspace, in the working directory.
+func moduleMode() bool {
+	out, err := exec.Command("go", "env", "GOMOD", "GOWORK").Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" && line != os.DevNull {
+			return true
+		}
+	}
+	return false
+}
@@ -79,7 +79,11 @@ func initTemplates(base string) error {
 
 		// Read and parse the input.
 		tmpl := present.Template()
-		tmpl = tmpl.Funcs(template.FuncMap{"playable": playable})
+		tmpl = tmpl.Funcs(template.FuncMap{
+			"playable":  playable,
+			"static":    staticResource,
+			"exporting": exporting,
+		})
 		if _, err := tmpl.ParseFiles(actionTmpl, contentTmpl); err != nil {
 			return err
 		}
@@ -47,6 +47,16 @@ the generated output. The supported formats are:
 	.slide        // HTML5 slide presentation
 	.article      // article format, such as a blog post
 
+The -export flag renders the presentations of the -content directory, or
+the files named on the command line, to static HTML files in a directory,
+to be published on any web server without running present:
+
+	present -export=public
+
+Each foo.slide or foo.article file becomes foo.html, with the scripts and
+style sheets inlined and the code snippets shown but not runnable; the
+other files of the content directory, such as images, are copied along.
+
 The present file format is documented by the present package:
 https://pkg.go.dev/golang.org/x/tools/present
 */
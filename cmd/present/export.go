@@ -0,0 +1,152 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// This file defines the -export mode, which renders the presentations
+// to static HTML files, to be published without the present server.
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var exportDir = flag.String("export", "", "write the presentations of the -content directory, or the named files, as static HTML files to `dir`, instead of serving them")
+
+// exporting reports whether the presentations are being exported, in
+// which case the static resources are inlined into them.
+func exporting() bool { return *exportDir != "" }
+
+// staticResource returns the HTML that loads the named static resource,
+// a script or a style sheet: a reference to it, or, when exporting, its
+// contents.
+func staticResource(name string) (template.HTML, error) {
+	ext := filepath.Ext(name)
+	if !exporting() {
+		url := template.HTMLEscapeString("/static/" + name)
+		switch ext {
+		case ".js":
+			return template.HTML("<script src='" + url + "'></script>"), nil
+		case ".css":
+			return template.HTML(`<link type="text/css" rel="stylesheet" href="` + url + `">`), nil
+		}
+		return "", fmt.Errorf("unknown kind of static resource %s", name)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(*basePath, "static", name))
+	if err != nil {
+		return "", err
+	}
+	switch ext {
+	case ".js":
+		// Don't let the script end the element early.
+		js := strings.Replace(string(data), "</script", `<\/script`, -1)
+		return template.HTML("<script>\n" + js + "\n</script>"), nil
+	case ".css":
+		css := strings.Replace(string(data), "</style", `<\/style`, -1)
+		return template.HTML("<style>\n" + css + "\n</style>"), nil
+	}
+	return "", fmt.Errorf("unknown kind of static resource %s", name)
+}
+
+// export renders the named presentation files, or, if there are none,
+// those of the content directory, as HTML files in dir, and copies
+// the other files of the content directory, such as images, there too.
+// The code snippets are rendered as they are, with the playground
+// disabled.
+func export(dir string, files []string) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	written := make(map[string]string) // the presentation of each output file
+	render := func(name, out string) error {
+		if prev, ok := written[out]; ok {
+			return fmt.Errorf("%s and %s would both be exported to %s", prev, name, out)
+		}
+		written[out] = name
+		var buf bytes.Buffer
+		if err := renderDoc(&buf, name); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(out), 0777); err != nil {
+			return err
+		}
+		log.Printf("exported %s to %s", name, out)
+		return ioutil.WriteFile(out, buf.Bytes(), 0666)
+	}
+	htmlName := func(name string) string {
+		return strings.TrimSuffix(name, filepath.Ext(name)) + ".html"
+	}
+
+	if len(files) > 0 {
+		for _, name := range files {
+			if !isDoc(name) {
+				return fmt.Errorf("%s is not a .slide or .article file", name)
+			}
+			if err := render(name, filepath.Join(dir, htmlName(filepath.Base(name)))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	root := filepath.Clean(*contentPath)
+	return filepath.Walk(root, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if abs, err := filepath.Abs(name); err == nil && abs == absDir {
+				return filepath.SkipDir // the output of a previous export
+			}
+			if name != root && !showDir(fi.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(fi.Name(), ".") {
+			return nil
+		}
+		if isDoc(name) {
+			return render(name, filepath.Join(dir, htmlName(rel)))
+		}
+		return copyFile(filepath.Join(dir, rel), name)
+	})
+}
+
+// copyFile copies the file src to dst.
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
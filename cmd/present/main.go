@@ -59,11 +59,22 @@ func main() {
 		}
 		*basePath = p.Dir
 	}
+	if exporting() {
+		// The exported code snippets can't be run.
+		present.PlayEnabled = false
+	}
 	err := initTemplates(*basePath)
 	if err != nil {
 		log.Fatalf("Failed to parse templates: %v", err)
 	}
 
+	if exporting() {
+		if err := export(*exportDir, flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	ln, err := net.Listen("tcp", *httpAddr)
 	if err != nil {
 		log.Fatal(err)
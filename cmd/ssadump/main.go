@@ -131,10 +131,8 @@ func doMain() error {
 		return fmt.Errorf("packages contain errors")
 	}
 
-	// Turn on instantiating generics during build if the program will be run.
-	if *runFlag {
-		mode |= ssa.InstantiateGenerics
-	}
+	// The interpreter builds the instantiations of generics that the
+	// program calls, so the program need not instantiate them.
 
 	// Create SSA-form program representation.
 	prog, pkgs := ssautil.AllPackages(initial, mode)
@@ -0,0 +1,142 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package main
+
+// This file defines the classification of failures and the summary of
+// the runs.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// A summary summarizes the runs so far. It is written as JSON by the
+// -json flag.
+type summary struct {
+	Command  []string
+	Elapsed  float64 // in seconds
+	Runs     int
+	Passes   int
+	Failures int // including the timeouts
+	Timeouts int
+	Kinds    []*failureKind // in the order of their first failures
+}
+
+// A failureKind is a class of failures with the same signature.
+type failureKind struct {
+	Signature string
+	Count     int
+	Log       string // the log file of the first failure
+}
+
+// writeSummary writes the summary to the -json file, if any.
+func writeSummary(sum *summary) {
+	if *flagJSON == "" {
+		return
+	}
+	kinds := append([]*failureKind(nil), sum.Kinds...)
+	sort.SliceStable(kinds, func(i, j int) bool { return kinds[i].Count > kinds[j].Count })
+	s := *sum
+	s.Kinds = kinds
+	data, err := json.MarshalIndent(&s, "", "\t")
+	if err != nil {
+		panic(err) // can't happen
+	}
+	// Write the file atomically, so that a dashboard never reads half
+	// of a summary.
+	tmp := *flagJSON + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0666); err != nil {
+		fmt.Printf("failed to write summary: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmp, *flagJSON); err != nil {
+		fmt.Printf("failed to write summary: %v\n", err)
+	}
+}
+
+var (
+	goroutineRe = regexp.MustCompile(`^goroutine \d+ (gp=.* )?\[.*\]:$`)
+	failRe      = regexp.MustCompile(`^\s*--- FAIL: (\S+)`)
+	logRe       = regexp.MustCompile(`^\s+([\w.-]+\.go:\d+): `)
+	argsRe      = regexp.MustCompile(`\([^()]*\)$`)
+)
+
+// failureSignature returns the signature of the failure whose output is
+// out, by which failures are classified: the first stack frame of the
+// first goroutine of a panic or a dump of the goroutines, skipping those
+// of the runtime, the testing package and the main function of a test,
+// and those of the standard library if there are others; or else the
+// first test that failed and the position of its first message; or else
+// the last line of the output.
+func failureSignature(out []byte) string {
+	lines := strings.Split(string(out), "\n")
+	goroot := filepath.ToSlash(filepath.Join(runtime.GOROOT(), "src")) + "/"
+	for i, line := range lines {
+		if !goroutineRe.MatchString(line) {
+			continue
+		}
+		// Each frame is the function and its arguments, then the
+		// file and line, indented.
+		std := ""
+		for j := i + 1; j+1 < len(lines) && strings.HasPrefix(lines[j+1], "\t"); j += 2 {
+			fn := lines[j]
+			if strings.HasPrefix(fn, "runtime.") || strings.HasPrefix(fn, "internal/runtime/") ||
+				strings.HasPrefix(fn, "panic(") || strings.HasPrefix(fn, "testing.") ||
+				strings.HasPrefix(fn, "created by ") {
+				continue
+			}
+			loc := strings.TrimSpace(lines[j+1])
+			if k := strings.LastIndex(loc, " +0x"); k >= 0 {
+				loc = loc[:k]
+			}
+			if strings.Contains(loc, "_testmain.go:") {
+				continue // the main function of a test
+			}
+			frame := argsRe.ReplaceAllString(fn, "") + " at " + loc
+			if strings.HasPrefix(filepath.ToSlash(loc), goroot) {
+				if std == "" {
+					std = frame // e.g. time.Sleep, in a goroutine that hangs
+				}
+				continue
+			}
+			return frame
+		}
+		if std != "" {
+			return std
+		}
+	}
+	for i, line := range lines {
+		m := failRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		// The messages of a test follow its --- FAIL line.
+		for _, line := range lines[i+1:] {
+			if l := logRe.FindStringSubmatch(line); l != nil {
+				return m[1] + " at " + l[1]
+			}
+			if failRe.MatchString(line) {
+				break
+			}
+		}
+		return m[1]
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return "no output"
+}
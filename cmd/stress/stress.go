@@ -15,6 +15,17 @@
 // instruct the utility to not kill hanged processes for gdb attach;
 // or specify the failure output you are looking for (if you want to
 // ignore some other sporadic failures).
+//
+// A process that runs longer than the -timeout is sent SIGQUIT, which
+// makes a Go program print the stacks of its goroutines, and killed if
+// it does not exit; it is reported as a timeout, with the stacks.
+//
+// Failures are classified by their first stack frame outside the
+// runtime and the testing package, or by the test that failed, and
+// only the output of the first failure of each kind is printed. The
+// -json flag writes a summary of the runs and the kinds of failures to
+// a file, for dashboards of flaky tests, and -count stops after a
+// number of runs.
 package main
 
 import (
@@ -23,9 +34,11 @@ import (
 	exec "golang.org/x/sys/execabs"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -37,6 +50,8 @@ var (
 	flagFailure = flag.String("failure", "", "fail only if output matches `regexp`")
 	flagIgnore  = flag.String("ignore", "", "ignore failure if output matches `regexp`")
 	flagOutput  = flag.String("o", defaultPrefix(), "output failure logs to `path` plus a unique suffix")
+	flagCount   = flag.Int("count", 0, "stop after `N` runs, or never if zero")
+	flagJSON    = flag.String("json", "", "write a JSON summary of the runs to `file` periodically and on exit")
 )
 
 func init() {
@@ -78,62 +93,59 @@ func main() {
 			os.Exit(1)
 		}
 	}
-	res := make(chan []byte)
+	var started int64
+	res := make(chan result)
 	for i := 0; i < *flagP; i++ {
 		go func() {
-			for {
-				cmd := exec.Command(flag.Args()[0], flag.Args()[1:]...)
-				done := make(chan bool)
-				if *flagTimeout > 0 {
-					go func() {
-						select {
-						case <-done:
-							return
-						case <-time.After(*flagTimeout):
-						}
-						if !*flagKill {
-							fmt.Printf("process %v timed out\n", cmd.Process.Pid)
-							return
-						}
-						cmd.Process.Signal(syscall.SIGABRT)
-						select {
-						case <-done:
-							return
-						case <-time.After(10 * time.Second):
-						}
-						cmd.Process.Kill()
-					}()
-				}
-				out, err := cmd.CombinedOutput()
-				close(done)
-				if err != nil && (failureRe == nil || failureRe.Match(out)) && (ignoreRe == nil || !ignoreRe.Match(out)) {
-					out = append(out, fmt.Sprintf("\n\nERROR: %v\n", err)...)
-				} else {
-					out = []byte{}
-				}
-				res <- out
+			for *flagCount <= 0 || atomic.AddInt64(&started, 1) <= int64(*flagCount) {
+				res <- run(failureRe, ignoreRe)
 			}
 		}()
 	}
-	runs, fails := 0, 0
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	sum := &summary{Command: flag.Args()}
+	kinds := make(map[string]*failureKind)
 	start := time.Now()
 	ticker := time.NewTicker(5 * time.Second).C
-	for {
+	for *flagCount <= 0 || sum.Runs < *flagCount {
 		select {
-		case out := <-res:
-			runs++
-			if len(out) == 0 {
+		case r := <-res:
+			sum.Runs++
+			if r.out == nil {
+				sum.Passes++
 				continue
 			}
-			fails++
+			sum.Failures++
+			if r.timedOut {
+				sum.Timeouts++
+			}
 			dir, path := filepath.Split(*flagOutput)
 			f, err := ioutil.TempFile(dir, path)
 			if err != nil {
 				fmt.Printf("failed to create temp file: %v\n", err)
 				os.Exit(1)
 			}
-			f.Write(out)
+			f.Write(r.out)
 			f.Close()
+
+			sig := failureSignature(r.out)
+			if r.timedOut {
+				sig = "timeout: " + sig
+			}
+			kind := kinds[sig]
+			if kind == nil {
+				kind = &failureKind{Signature: sig, Log: f.Name()}
+				kinds[sig] = kind
+				sum.Kinds = append(sum.Kinds, kind)
+			}
+			kind.Count++
+			if kind.Count > 1 {
+				fmt.Printf("\n%s\nanother failure of %s (%d so far)\n", f.Name(), sig, kind.Count)
+				continue
+			}
+			out := r.out
 			if len(out) > 2<<10 {
 				out := out[:2<<10]
 				fmt.Printf("\n%s\n%s\n…\n", f.Name(), out)
@@ -141,12 +153,82 @@ func main() {
 				fmt.Printf("\n%s\n%s\n", f.Name(), out)
 			}
 		case <-ticker:
-			elapsed := time.Since(start).Truncate(time.Second)
-			var pct string
-			if fails > 0 {
-				pct = fmt.Sprintf(" (%0.2f%%)", 100.0*float64(fails)/float64(runs))
-			}
-			fmt.Printf("%v: %v runs so far, %v failures%s\n", elapsed, runs, fails, pct)
+			sum.Elapsed = time.Since(start).Seconds()
+			printStatus(sum)
+			writeSummary(sum)
+		case <-interrupt:
+			sum.Elapsed = time.Since(start).Seconds()
+			printStatus(sum)
+			writeSummary(sum)
+			os.Exit(1)
 		}
 	}
+	sum.Elapsed = time.Since(start).Seconds()
+	printStatus(sum)
+	writeSummary(sum)
+	if sum.Failures > 0 {
+		os.Exit(1)
+	}
+}
+
+// A result is the result of a run: its output, with the error, if it
+// failed, or nil.
+type result struct {
+	out      []byte
+	timedOut bool
+}
+
+// run runs the process once.
+func run(failureRe, ignoreRe *regexp.Regexp) result {
+	cmd := exec.Command(flag.Args()[0], flag.Args()[1:]...)
+	done := make(chan bool)
+	var timedOut int32
+	if *flagTimeout > 0 {
+		go func() {
+			select {
+			case <-done:
+				return
+			case <-time.After(*flagTimeout):
+			}
+			if !*flagKill {
+				fmt.Printf("process %v timed out\n", cmd.Process.Pid)
+				return
+			}
+			// SIGQUIT makes a Go program print the stacks of its
+			// goroutines before it exits.
+			atomic.StoreInt32(&timedOut, 1)
+			cmd.Process.Signal(syscall.SIGQUIT)
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Second):
+			}
+			cmd.Process.Kill()
+		}()
+	}
+	out, err := cmd.CombinedOutput()
+	close(done)
+	r := result{timedOut: atomic.LoadInt32(&timedOut) != 0}
+	switch {
+	case r.timedOut && (ignoreRe == nil || !ignoreRe.Match(out)):
+		r.out = append(out, fmt.Sprintf("\n\nERROR: timed out after %v: %v\n", *flagTimeout, err)...)
+	case err != nil && (failureRe == nil || failureRe.Match(out)) && (ignoreRe == nil || !ignoreRe.Match(out)):
+		r.out = append(out, fmt.Sprintf("\n\nERROR: %v\n", err)...)
+	default:
+		r.timedOut = false
+	}
+	return r
+}
+
+func printStatus(sum *summary) {
+	elapsed := time.Duration(sum.Elapsed * float64(time.Second)).Truncate(time.Second)
+	var pct, timeouts, kinds string
+	if sum.Failures > 0 {
+		pct = fmt.Sprintf(" (%0.2f%%)", 100.0*float64(sum.Failures)/float64(sum.Runs))
+		kinds = fmt.Sprintf(" of %v kinds", len(sum.Kinds))
+	}
+	if sum.Timeouts > 0 {
+		timeouts = fmt.Sprintf(", %v timeouts", sum.Timeouts)
+	}
+	fmt.Printf("%v: %v runs so far, %v failures%s%s%s\n", elapsed, sum.Runs, sum.Failures, pct, kinds, timeouts)
 }
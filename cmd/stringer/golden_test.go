@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"text/template"
 
 	"golang.org/x/tools/internal/testenv"
 )
@@ -449,6 +450,386 @@ func (i Token) String() string {
 }
 `
 
+// GoldenMethods represents a test case for the flags that change the
+// generated methods.
+type GoldenMethods struct {
+	name        string
+	bitmask     bool
+	marshalText bool
+	marshalJSON bool
+	input       string
+	output      string
+}
+
+var goldenMethods = []GoldenMethods{
+	{"bitmask", true, false, false, bitmask_in, bitmask_out},
+	{"marshal", false, true, true, marshal_in, marshal_out},
+	{"bitmaskjson", true, false, true, bitmaskjson_in, bitmaskjson_out},
+}
+
+// Bit flags, with a named combination and a named zero value.
+const bitmask_in = `type Perm uint
+const (
+	Read Perm = 1 << iota
+	Write
+	Exec
+	None Perm = 0
+	ReadWrite Perm = Read | Write
+)
+`
+
+const bitmask_out = `func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[Read-1]
+	_ = x[Write-2]
+	_ = x[Exec-4]
+	_ = x[None-0]
+	_ = x[ReadWrite-3]
+}
+
+const _Perm_name = "NoneReadWriteReadWriteExec"
+
+var _Perm_map = map[Perm]string{
+	0: _Perm_name[0:4],
+	1: _Perm_name[4:8],
+	2: _Perm_name[8:13],
+	3: _Perm_name[13:22],
+	4: _Perm_name[22:26],
+}
+
+var _Perm_bits = [...]Perm{1, 2, 4}
+
+func (i Perm) String() string {
+	if str, ok := _Perm_map[i]; ok {
+		return str
+	}
+	var b []byte
+	for _, bit := range _Perm_bits {
+		if i&bit != 0 {
+			if len(b) > 0 {
+				b = append(b, '|')
+			}
+			b = append(b, _Perm_map[bit]...)
+			i &^= bit
+		}
+	}
+	if i != 0 || len(b) == 0 {
+		if len(b) > 0 {
+			b = append(b, '|')
+		}
+		b = append(b, "Perm("+strconv.FormatInt(int64(i), 10)+")"...)
+	}
+	return string(b)
+}
+`
+
+// Marshaling methods for an enumeration.
+const marshal_in = `type Size int
+const (
+	Small Size = iota
+	Large
+)
+`
+
+const marshal_out = `func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[Small-0]
+	_ = x[Large-1]
+}
+
+const _Size_name = "SmallLarge"
+
+var _Size_index = [...]uint8{0, 5, 10}
+
+func (i Size) String() string {
+	if i < 0 || i >= Size(len(_Size_index)-1) {
+		return "Size(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _Size_name[_Size_index[i]:_Size_index[i+1]]
+}
+
+var _Size_values = map[string]Size{
+	"Small": 0,
+	"Large": 1,
+}
+
+func _Size_parse(s string) (Size, error) {
+	if i, ok := _Size_values[s]; ok {
+		return i, nil
+	}
+	if strings.HasPrefix(s, "Size(") && strings.HasSuffix(s, ")") {
+		if i, err := strconv.ParseInt(s[len("Size("):len(s)-1], 10, 64); err == nil {
+			return Size(i), nil
+		}
+	}
+	return 0, errors.New("invalid Size: " + strconv.Quote(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (i Size) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (i *Size) UnmarshalText(text []byte) error {
+	v, err := _Size_parse(string(text))
+	if err != nil {
+		return err
+	}
+	*i = v
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (i Size) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (i *Size) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("Size should be a string: " + err.Error())
+	}
+	v, err := _Size_parse(s)
+	if err != nil {
+		return err
+	}
+	*i = v
+	return nil
+}
+`
+
+// Marshaling methods for bit flags.
+const bitmaskjson_in = `type Opt int8
+const (
+	A Opt = 1 << iota
+	B
+)
+`
+
+const bitmaskjson_out = `func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[A-1]
+	_ = x[B-2]
+}
+
+const _Opt_name = "AB"
+
+var _Opt_map = map[Opt]string{
+	1: _Opt_name[0:1],
+	2: _Opt_name[1:2],
+}
+
+var _Opt_bits = [...]Opt{1, 2}
+
+func (i Opt) String() string {
+	if str, ok := _Opt_map[i]; ok {
+		return str
+	}
+	var b []byte
+	for _, bit := range _Opt_bits {
+		if i&bit != 0 {
+			if len(b) > 0 {
+				b = append(b, '|')
+			}
+			b = append(b, _Opt_map[bit]...)
+			i &^= bit
+		}
+	}
+	if i != 0 || len(b) == 0 {
+		if len(b) > 0 {
+			b = append(b, '|')
+		}
+		b = append(b, "Opt("+strconv.FormatInt(int64(i), 10)+")"...)
+	}
+	return string(b)
+}
+
+var _Opt_values = map[string]Opt{
+	"A": 1,
+	"B": 2,
+}
+
+func _Opt_parse(s string) (Opt, error) {
+	if i, ok := _Opt_values[s]; ok {
+		return i, nil
+	}
+	var i Opt
+	for _, name := range strings.Split(s, "|") {
+		if bit, ok := _Opt_values[name]; ok {
+			i |= bit
+			continue
+		}
+		if strings.HasPrefix(name, "Opt(") && strings.HasSuffix(name, ")") {
+			if bits, err := strconv.ParseInt(name[len("Opt("):len(name)-1], 10, 64); err == nil {
+				i |= Opt(bits)
+				continue
+			}
+		}
+		return 0, errors.New("invalid Opt: " + strconv.Quote(s))
+	}
+	return i, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (i Opt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (i *Opt) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("Opt should be a string: " + err.Error())
+	}
+	v, err := _Opt_parse(s)
+	if err != nil {
+		return err
+	}
+	*i = v
+	return nil
+}
+`
+
+// GoldenCustom represents a test case for the flags that customize the
+// generated code.
+type GoldenCustom struct {
+	name     string
+	method   string
+	receiver string
+	template string
+	input    string
+	output   string
+}
+
+var goldenCustom = []GoldenCustom{
+	{"names", "Name", "c", "", names_in, names_out},
+	{"template", "", "", values_tmpl, template_in, template_out},
+}
+
+// A method and receiver with other names, in all the methods.
+const names_in = `type Color int
+const (
+	Red Color = iota + 1
+	Green
+	Blue
+)
+`
+
+const names_out = `func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[Red-1]
+	_ = x[Green-2]
+	_ = x[Blue-3]
+}
+
+const _Color_name = "RedGreenBlue"
+
+var _Color_index = [...]uint8{0, 3, 8, 12}
+
+func (c Color) Name() string {
+	c -= 1
+	if c < 0 || c >= Color(len(_Color_index)-1) {
+		return "Color(" + strconv.FormatInt(int64(c+1), 10) + ")"
+	}
+	return _Color_name[_Color_index[c]:_Color_index[c+1]]
+}
+
+var _Color_values = map[string]Color{
+	"Red":   1,
+	"Green": 2,
+	"Blue":  3,
+}
+
+func _Color_parse(s string) (Color, error) {
+	if i, ok := _Color_values[s]; ok {
+		return i, nil
+	}
+	if strings.HasPrefix(s, "Color(") && strings.HasSuffix(s, ")") {
+		if i, err := strconv.ParseInt(s[len("Color("):len(s)-1], 10, 64); err == nil {
+			return Color(i), nil
+		}
+	}
+	return 0, errors.New("invalid Color: " + strconv.Quote(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c Color) MarshalText() ([]byte, error) {
+	return []byte(c.Name()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Color) UnmarshalText(text []byte) error {
+	v, err := _Color_parse(string(text))
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+`
+
+// A template that adds a function to the generated code.
+const values_tmpl = `{{.Code}}
+// {{.Type}}Values returns the values of {{.Type}}.
+func {{.Type}}Values() []{{.Type}} {
+	return []{{.Type}}{ {{- range .Values}}{{.Name}}, {{end -}} }
+}
+
+// {{.Type}}Texts returns the texts of the values of {{.Type}}.
+func {{.Type}}Texts() []string {
+	return []string{ {{- range .Values}}{{printf "%q" .Text}}, {{end -}} }
+}
+`
+
+const template_in = `type Fruit uint8
+const (
+	Apple Fruit = iota
+	Banana
+	Plantain Fruit = Banana
+)
+`
+
+const template_out = `func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[Apple-0]
+	_ = x[Banana-1]
+	_ = x[Plantain-1]
+}
+
+const _Fruit_name = "AppleBanana"
+
+var _Fruit_index = [...]uint8{0, 5, 11}
+
+func (i Fruit) String() string {
+	if i >= Fruit(len(_Fruit_index)-1) {
+		return "Fruit(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _Fruit_name[_Fruit_index[i]:_Fruit_index[i+1]]
+}
+
+// FruitValues returns the values of Fruit.
+func FruitValues() []Fruit {
+	return []Fruit{Apple, Banana}
+}
+
+// FruitTexts returns the texts of the values of Fruit.
+func FruitTexts() []string {
+	return []string{"Apple", "Banana"}
+}
+`
+
 func TestGolden(t *testing.T) {
 	testenv.NeedsTool(t, "go")
 
@@ -463,24 +844,120 @@ func TestGolden(t *testing.T) {
 			trimPrefix:  test.trimPrefix,
 			lineComment: test.lineComment,
 		}
-		input := "package test\n" + test.input
-		file := test.name + ".go"
-		absFile := filepath.Join(dir, file)
-		err := ioutil.WriteFile(absFile, []byte(input), 0644)
-		if err != nil {
-			t.Error(err)
+		testGolden(t, dir, &g, test.name, test.input, test.output)
+	}
+}
+
+// testGolden runs the generator on the input, as test name, and checks
+// that it generates the output.
+func testGolden(t *testing.T, dir string, g *Generator, name, input, output string) {
+	t.Helper()
+	src := "package test\n" + input
+	file := name + ".go"
+	absFile := filepath.Join(dir, file)
+	err := ioutil.WriteFile(absFile, []byte(src), 0644)
+	if err != nil {
+		t.Error(err)
+	}
+
+	g.parsePackage([]string{absFile}, nil)
+	// Extract the name and type of the constant from the first line.
+	tokens := strings.SplitN(input, " ", 3)
+	if len(tokens) != 3 {
+		t.Fatalf("%s: need type declaration on first line", name)
+	}
+	g.generate(tokens[1])
+	got := string(g.format())
+	if got != output {
+		t.Errorf("%s: got(%d)\n====\n%q====\nexpected(%d)\n====%q", name, len(got), got, len(output), output)
+	}
+}
+
+func TestGoldenMethods(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	dir, err := ioutil.TempDir("", "stringer")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range goldenMethods {
+		g := Generator{
+			bitmask:     test.bitmask,
+			marshalText: test.marshalText,
+			marshalJSON: test.marshalJSON,
 		}
+		testGolden(t, dir, &g, test.name, test.input, test.output)
+	}
+}
 
-		g.parsePackage([]string{absFile}, nil)
-		// Extract the name and type of the constant from the first line.
-		tokens := strings.SplitN(test.input, " ", 3)
-		if len(tokens) != 3 {
-			t.Fatalf("%s: need type declaration on first line", test.name)
+func TestGoldenCustom(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	dir, err := ioutil.TempDir("", "stringer")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range goldenCustom {
+		g := Generator{
+			method:      test.method,
+			receiver:    test.receiver,
+			marshalText: test.template == "",
+		}
+		if test.template != "" {
+			g.template = template.Must(template.New(test.name).Parse(test.template))
 		}
-		g.generate(tokens[1])
-		got := string(g.format())
-		if got != test.output {
-			t.Errorf("%s: got(%d)\n====\n%q====\nexpected(%d)\n====%q", test.name, len(got), got, len(test.output), test.output)
+		testGolden(t, dir, &g, test.name, test.input, test.output)
+	}
+}
+
+func TestExpandTypes(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	dir, err := ioutil.TempDir("", "stringer")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const src = `package test
+
+type (
+	AKind int
+	BKind uint
+	CKind int // no constants
+	DKind = AKind
+	Kinds []AKind
+	Other int
+)
+
+const (
+	A AKind = 0
+	B BKind = 0
+	O Other = 0
+)
+`
+	file := filepath.Join(dir, "kinds.go")
+	if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var g Generator
+	g.parsePackage([]string{file}, nil)
+	for _, test := range []struct {
+		patterns string
+		want     string
+	}{
+		{"Other", "Other"},
+		{"*Kind", "AKind,BKind"},
+		{"Other,*", "Other,AKind,BKind"},
+		{"[AB]Kind,BKind", "AKind,BKind"},
+	} {
+		got := strings.Join(g.expandTypes(strings.Split(test.patterns, ",")), ",")
+		if got != test.want {
+			t.Errorf("expandTypes(%s) = %s, want %s", test.patterns, got, test.want)
 		}
 	}
 }
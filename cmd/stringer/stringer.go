@@ -12,8 +12,8 @@
 // It has helpful defaults designed for use with go generate.
 //
 // Stringer works best with constants that are consecutive values such as created using iota,
-// but creates good code regardless. In the future it might also provide custom support for
-// constant sets that are bit patterns.
+// but creates good code regardless. For constant sets that are bit patterns, see the
+// -bitmask flag below.
 //
 // For example, given this snippet,
 //
@@ -54,9 +54,15 @@
 // or a set of Go source files that represent a single Go package.
 //
 // The -type flag accepts a comma-separated list of types so a single run can
-// generate methods for multiple types. The default output file is t_string.go,
-// where t is the lower-cased name of the first type listed. It can be overridden
-// with the -output flag.
+// generate methods for multiple types. An element of the list may also be a
+// pattern, in the syntax of path.Match, that matches the names of the integer
+// types of the package that have constants: -type='*' generates methods for all
+// of them. The default output file is t_string.go, where t is the lower-cased
+// name of the first type listed. It can be overridden with the -output flag.
+//
+// The -method and -receiver flags set the name of the generated method, which
+// is String by default, and the name of the receiver of the generated methods,
+// which is i by default.
 //
 // The -linecomment flag tells stringer to generate the text of any line comment, trimmed
 // of leading spaces, instead of the constant name. For instance, if the constants above had a
@@ -65,6 +71,57 @@
 //	PillAspirin // Aspirin
 //
 // to suppress it in the output.
+//
+// The -bitmask flag tells stringer that the constants of the types are flags: powers of
+// two, and perhaps named combinations of them, that are or'ed together. The String method
+// of such a type renders a value that no constant names as the names of its bits joined
+// by '|', so that given
+//
+//	type Perm uint
+//
+//	const (
+//		Read Perm = 1 << iota
+//		Write
+//		Exec
+//	)
+//
+// Read|Write prints as "Read|Write". Stringer reports an error if a constant is
+// neither zero nor a combination of the powers of two among the constants.
+//
+// The -text and -json flags tell stringer to generate, in addition, MarshalText and
+// UnmarshalText methods (implementing encoding.TextMarshaler and encoding.TextUnmarshaler),
+// and MarshalJSON and UnmarshalJSON methods, that encode a value as its String and decode
+// any string that String may return.
+//
+// The -template flag names a file holding a text/template that replaces the
+// code generated for each type, so that the generated code may follow other
+// conventions than stringer's. The template is executed once per type, after
+// the header and imports of the output file, with a value of this type:
+//
+//	struct {
+//		Type     string // The name of the type.
+//		Method   string // The name of the method, as set by -method.
+//		Receiver string // The name of the receiver, as set by -receiver.
+//		Values   []struct {
+//			Name  string // The name of the constant.
+//			Text  string // The text that the method returns for it.
+//			Value string // Its value, as a Go literal.
+//		}
+//		Code string // The code that stringer would have generated.
+//	}
+//
+// Values lists the constants in increasing order of value, with the first of
+// the constants that share a value. A template that needs other imports than
+// those of the code it replaces may define a template named "imports" that
+// lists their paths, separated by white space; imports that the output does
+// not use are removed. For example, this template adds a Values function to
+// the String method of each type:
+//
+//	{{.Code}}
+//	// {{.Type}}Values returns the values of {{.Type}}.
+//	func {{.Type}}Values() []{{.Type}} {
+//		return []{{.Type}}{ {{- range .Values}}{{.Name}}, {{end -}} }
+//	}
 package main // import "golang.org/x/tools/cmd/stringer"
 
 import (
@@ -74,15 +131,20 @@ import (
 	"go/ast"
 	"go/constant"
 	"go/format"
+	"go/parser"
 	"go/token"
 	"go/types"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
 )
 
@@ -92,6 +154,12 @@ var (
 	trimprefix  = flag.String("trimprefix", "", "trim the `prefix` from the generated constant names")
 	linecomment = flag.Bool("linecomment", false, "use line comment text as printed text when present")
 	buildTags   = flag.String("tags", "", "comma-separated list of build tags to apply")
+	bitmask     = flag.Bool("bitmask", false, "the constants are bit flags; render combinations of them as \"A|B\"")
+	marshalText = flag.Bool("text", false, "also generate MarshalText and UnmarshalText methods")
+	marshalJSON = flag.Bool("json", false, "also generate MarshalJSON and UnmarshalJSON methods")
+	method      = flag.String("method", "String", "the `name` of the generated method")
+	receiver    = flag.String("receiver", "i", "the `name` of the receiver of the generated methods")
+	tmplFile    = flag.String("template", "", "the `file` of a text/template of the code to generate for each type")
 )
 
 // Usage is a replacement usage function for the flags package.
@@ -114,7 +182,9 @@ func main() {
 		flag.Usage()
 		os.Exit(2)
 	}
-	types := strings.Split(*typeNames, ",")
+	if !token.IsIdentifier(*method) {
+		log.Fatalf("-method: %q is not an identifier", *method)
+	}
 	var tags []string
 	if len(*buildTags) > 0 {
 		tags = strings.Split(*buildTags, ",")
@@ -132,6 +202,18 @@ func main() {
 	g := Generator{
 		trimPrefix:  *trimprefix,
 		lineComment: *linecomment,
+		bitmask:     *bitmask,
+		marshalText: *marshalText,
+		marshalJSON: *marshalJSON,
+		method:      *method,
+		receiver:    *receiver,
+	}
+	if *tmplFile != "" {
+		tmpl, err := template.ParseFiles(*tmplFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g.template = tmpl
 	}
 	// TODO(suzmue): accept other patterns for packages (directories, list of files, import paths, etc).
 	if len(args) == 1 && isDirectory(args[0]) {
@@ -144,18 +226,25 @@ func main() {
 	}
 
 	g.parsePackage(args, tags)
+	types := g.expandTypes(strings.Split(*typeNames, ","))
+	if err := checkReceiver(*receiver, types); err != nil {
+		log.Fatalf("-receiver: %v", err)
+	}
 
 	// Print the header and package clause.
 	g.Printf("// Code generated by \"stringer %s\"; DO NOT EDIT.\n", strings.Join(os.Args[1:], " "))
 	g.Printf("\n")
 	g.Printf("package %s", g.pkg.name)
 	g.Printf("\n")
-	g.Printf("import \"strconv\"\n") // Used by all methods.
+	g.printImports()
 
 	// Run generate for each type.
 	for _, typeName := range types {
 		g.generate(typeName)
 	}
+	if g.template != nil {
+		g.removeUnusedImports()
+	}
 
 	// Format the output.
 	src := g.format()
@@ -189,12 +278,102 @@ type Generator struct {
 
 	trimPrefix  string
 	lineComment bool
+	bitmask     bool               // The constants are bit flags.
+	marshalText bool               // Generate MarshalText and UnmarshalText.
+	marshalJSON bool               // Generate MarshalJSON and UnmarshalJSON.
+	method      string             // The name of the String method, if not "String".
+	receiver    string             // The name of the receiver of the methods, if not "i".
+	template    *template.Template // The template of the code for each type, if any.
+}
+
+// methodName returns the name of the String method.
+func (g *Generator) methodName() string {
+	if g.method == "" {
+		return "String"
+	}
+	return g.method
+}
+
+// recv returns the name of the receiver of the generated methods.
+func (g *Generator) recv() string {
+	if g.receiver == "" {
+		return "i"
+	}
+	return g.receiver
 }
 
 func (g *Generator) Printf(format string, args ...interface{}) {
 	fmt.Fprintf(&g.buf, format, args...)
 }
 
+// printImports prints the imports of the generated methods.
+func (g *Generator) printImports() {
+	imports := []string{"strconv"} // Used by all methods.
+	if g.marshalText || g.marshalJSON {
+		imports = append(imports, "errors", "strings")
+	}
+	if g.marshalJSON {
+		imports = append(imports, "encoding/json")
+	}
+	if t := g.template; t != nil && t.Lookup("imports") != nil {
+		var buf bytes.Buffer
+		if err := t.ExecuteTemplate(&buf, "imports", nil); err != nil {
+			log.Fatal(err)
+		}
+		for _, imp := range strings.Fields(buf.String()) {
+			if !contains(imports, imp) {
+				imports = append(imports, imp)
+			}
+		}
+	}
+	if len(imports) == 1 {
+		g.Printf("import %q\n", imports[0])
+		return
+	}
+	sort.Strings(imports)
+	g.Printf("import (\n")
+	for _, imp := range imports {
+		g.Printf("\t%q\n", imp)
+	}
+	g.Printf(")\n")
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// removeUnusedImports removes the imports that the generated code does
+// not use from the buffer, which holds a complete file.
+func (g *Generator) removeUnusedImports() {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", g.buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return // format reports the error
+	}
+	var unused []string
+	for _, imp := range f.Imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err == nil && !astutil.UsesImport(f, path) {
+			unused = append(unused, path)
+		}
+	}
+	if len(unused) == 0 {
+		return
+	}
+	for _, path := range unused {
+		astutil.DeleteImport(fset, f, path)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return
+	}
+	g.buf = buf
+}
+
 // File holds a single parsed file and associated data.
 type File struct {
 	pkg  *Package  // Package to which this file belongs.
@@ -211,6 +390,7 @@ type Package struct {
 	name  string
 	defs  map[*ast.Ident]types.Object
 	files []*File
+	types *types.Package
 }
 
 // parsePackage analyzes the single package constructed from the patterns and tags.
@@ -239,6 +419,7 @@ func (g *Generator) addPackage(pkg *packages.Package) {
 		name:  pkg.Name,
 		defs:  pkg.TypesInfo.Defs,
 		files: make([]*File, len(pkg.Syntax)),
+		types: pkg.Types,
 	}
 
 	for i, file := range pkg.Syntax {
@@ -251,8 +432,95 @@ func (g *Generator) addPackage(pkg *packages.Package) {
 	}
 }
 
+// expandTypes returns the names of the types that the elements of the
+// -type flag denote: a type name, or a pattern that matches the names
+// of the integer types of the package that have constants.
+func (g *Generator) expandTypes(patterns []string) []string {
+	var names []string
+	add := func(name string) {
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, `*?[\`) {
+			add(pattern)
+			continue
+		}
+		matched := false
+		for _, name := range g.pkg.types.Scope().Names() {
+			ok, err := path.Match(pattern, name)
+			if err != nil {
+				log.Fatalf("bad type pattern %q: %v", pattern, err)
+			}
+			if ok && g.pkg.hasConstants(name) {
+				add(name)
+				matched = true
+			}
+		}
+		if !matched {
+			log.Fatalf("no types with constants match %s", pattern)
+		}
+	}
+	return names
+}
+
+// hasConstants reports whether name is an integer type of the package
+// that has constants.
+func (pkg *Package) hasConstants(name string) bool {
+	scope := pkg.types.Scope()
+	tn, ok := scope.Lookup(name).(*types.TypeName)
+	if !ok || tn.IsAlias() {
+		return false
+	}
+	if basic, ok := tn.Type().Underlying().(*types.Basic); !ok || basic.Info()&types.IsInteger == 0 {
+		return false
+	}
+	for _, n := range scope.Names() {
+		if c, ok := scope.Lookup(n).(*types.Const); ok && c.Type() == tn.Type() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReceiver returns an error if the receiver name would conflict
+// with the names that the generated methods use.
+func checkReceiver(name string, typeNames []string) error {
+	if !token.IsIdentifier(name) || name == "_" {
+		return fmt.Errorf("%q is not an identifier", name)
+	}
+	switch name {
+	case "str", "ok", "b", "bit", "text", "data", "s", "v", "err",
+		"strconv", "strings", "errors", "json":
+		return fmt.Errorf("%s is used by the generated methods", name)
+	}
+	if contains(typeNames, name) {
+		return fmt.Errorf("%s is the name of a type", name)
+	}
+	return nil
+}
+
+// A templateValue describes a constant to the template of the code for
+// its type.
+type templateValue struct {
+	Name  string // The name of the constant.
+	Text  string // The text that the method returns for it.
+	Value string // Its value, as a Go literal.
+}
+
+// templateData is the value of the template of the code for a type.
+type templateData struct {
+	Type     string
+	Method   string
+	Receiver string
+	Values   []templateValue
+	Code     string // The code that stringer would have generated.
+}
+
 // generate produces the String method for the named type.
 func (g *Generator) generate(typeName string) {
+	start := g.buf.Len()
 	values := make([]Value, 0, 100)
 	for _, file := range g.pkg.files {
 		// Set the state for this run of the walker.
@@ -287,9 +555,11 @@ func (g *Generator) generate(typeName string) {
 	// rather than use yet another algorithm such as binary search,
 	// we punt and use a map. In any case, the likelihood of a map
 	// being necessary for any realistic example other than bitmasks
-	// is very low. And bitmasks probably deserve their own analysis,
-	// to be done some other day.
+	// is very low. Bitmasks have their own pattern.
 	switch {
+	case g.bitmask:
+		checkBitmask(runs, typeName)
+		g.buildBitmask(runs, typeName)
 	case len(runs) == 1:
 		g.buildOneRun(runs, typeName)
 	case len(runs) <= 10:
@@ -297,6 +567,34 @@ func (g *Generator) generate(typeName string) {
 	default:
 		g.buildMap(runs, typeName)
 	}
+	if g.marshalText || g.marshalJSON {
+		g.buildParse(runs, typeName)
+	}
+	if g.marshalText {
+		g.Printf(textMethods, typeName, g.recv(), g.methodName())
+	}
+	if g.marshalJSON {
+		g.Printf(jsonMethods, typeName, g.recv(), g.methodName())
+	}
+
+	if g.template != nil {
+		// Replace the code with that of the template.
+		data := templateData{
+			Type:     typeName,
+			Method:   g.methodName(),
+			Receiver: g.recv(),
+			Code:     g.buf.String()[start:],
+		}
+		for _, run := range runs {
+			for _, v := range run {
+				data.Values = append(data.Values, templateValue{v.originalName, v.name, v.str})
+			}
+		}
+		g.buf.Truncate(start)
+		if err := g.template.Execute(&g.buf, data); err != nil {
+			log.Fatalf("generating code for %s: %v", typeName, err)
+		}
+	}
 }
 
 // splitIntoRuns breaks the values into runs of contiguous sequences.
@@ -560,43 +858,46 @@ func (g *Generator) buildOneRun(runs [][]Value, typeName string) {
 	g.Printf("\n")
 	g.declareIndexAndNameVar(values, typeName)
 	// The generated code is simple enough to write as a Printf format.
+	recv := g.recv()
 	lessThanZero := ""
 	if values[0].signed {
-		lessThanZero = "i < 0 || "
+		lessThanZero = recv + " < 0 || "
 	}
 	if values[0].value == 0 { // Signed or unsigned, 0 is still 0.
-		g.Printf(stringOneRun, typeName, usize(len(values)), lessThanZero)
+		g.Printf(stringOneRun, typeName, recv, g.methodName(), lessThanZero)
 	} else {
-		g.Printf(stringOneRunWithOffset, typeName, values[0].String(), usize(len(values)), lessThanZero)
+		g.Printf(stringOneRunWithOffset, typeName, recv, g.methodName(), values[0].String(), lessThanZero)
 	}
 }
 
 // Arguments to format are:
 //
 //	[1]: type name
-//	[2]: size of index element (8 for uint8 etc.)
-//	[3]: less than zero check (for signed types)
-const stringOneRun = `func (i %[1]s) String() string {
-	if %[3]si >= %[1]s(len(_%[1]s_index)-1) {
-		return "%[1]s(" + strconv.FormatInt(int64(i), 10) + ")"
+//	[2]: receiver name
+//	[3]: method name
+//	[4]: less than zero check (for signed types)
+const stringOneRun = `func (%[2]s %[1]s) %[3]s() string {
+	if %[4]s%[2]s >= %[1]s(len(_%[1]s_index)-1) {
+		return "%[1]s(" + strconv.FormatInt(int64(%[2]s), 10) + ")"
 	}
-	return _%[1]s_name[_%[1]s_index[i]:_%[1]s_index[i+1]]
+	return _%[1]s_name[_%[1]s_index[%[2]s]:_%[1]s_index[%[2]s+1]]
 }
 `
 
 // Arguments to format are:
 //	[1]: type name
-//	[2]: lowest defined value for type, as a string
-//	[3]: size of index element (8 for uint8 etc.)
-//	[4]: less than zero check (for signed types)
+//	[2]: receiver name
+//	[3]: method name
+//	[4]: lowest defined value for type, as a string
+//	[5]: less than zero check (for signed types)
 /*
  */
-const stringOneRunWithOffset = `func (i %[1]s) String() string {
-	i -= %[2]s
-	if %[4]si >= %[1]s(len(_%[1]s_index)-1) {
-		return "%[1]s(" + strconv.FormatInt(int64(i + %[2]s), 10) + ")"
+const stringOneRunWithOffset = `func (%[2]s %[1]s) %[3]s() string {
+	%[2]s -= %[4]s
+	if %[5]s%[2]s >= %[1]s(len(_%[1]s_index)-1) {
+		return "%[1]s(" + strconv.FormatInt(int64(%[2]s + %[4]s), 10) + ")"
 	}
-	return _%[1]s_name[_%[1]s_index[i] : _%[1]s_index[i+1]]
+	return _%[1]s_name[_%[1]s_index[%[2]s] : _%[1]s_index[%[2]s+1]]
 }
 `
 
@@ -605,28 +906,29 @@ const stringOneRunWithOffset = `func (i %[1]s) String() string {
 func (g *Generator) buildMultipleRuns(runs [][]Value, typeName string) {
 	g.Printf("\n")
 	g.declareIndexAndNameVars(runs, typeName)
-	g.Printf("func (i %s) String() string {\n", typeName)
+	recv := g.recv()
+	g.Printf("func (%s %s) %s() string {\n", recv, typeName, g.methodName())
 	g.Printf("\tswitch {\n")
 	for i, values := range runs {
 		if len(values) == 1 {
-			g.Printf("\tcase i == %s:\n", &values[0])
+			g.Printf("\tcase %s == %s:\n", recv, &values[0])
 			g.Printf("\t\treturn _%s_name_%d\n", typeName, i)
 			continue
 		}
 		if values[0].value == 0 && !values[0].signed {
 			// For an unsigned lower bound of 0, "0 <= i" would be redundant.
-			g.Printf("\tcase i <= %s:\n", &values[len(values)-1])
+			g.Printf("\tcase %s <= %s:\n", recv, &values[len(values)-1])
 		} else {
-			g.Printf("\tcase %s <= i && i <= %s:\n", &values[0], &values[len(values)-1])
+			g.Printf("\tcase %s <= %s && %s <= %s:\n", &values[0], recv, recv, &values[len(values)-1])
 		}
 		if values[0].value != 0 {
-			g.Printf("\t\ti -= %s\n", &values[0])
+			g.Printf("\t\t%s -= %s\n", recv, &values[0])
 		}
-		g.Printf("\t\treturn _%s_name_%d[_%s_index_%d[i]:_%s_index_%d[i+1]]\n",
-			typeName, i, typeName, i, typeName, i)
+		g.Printf("\t\treturn _%s_name_%d[_%s_index_%d[%s]:_%s_index_%d[%s+1]]\n",
+			typeName, i, typeName, i, recv, typeName, i, recv)
 	}
 	g.Printf("\tdefault:\n")
-	g.Printf("\t\treturn \"%s(\" + strconv.FormatInt(int64(i), 10) + \")\"\n", typeName)
+	g.Printf("\t\treturn \"%s(\" + strconv.FormatInt(int64(%s), 10) + \")\"\n", typeName, recv)
 	g.Printf("\t}\n")
 	g.Printf("}\n")
 }
@@ -645,14 +947,204 @@ func (g *Generator) buildMap(runs [][]Value, typeName string) {
 		}
 	}
 	g.Printf("}\n\n")
-	g.Printf(stringMap, typeName)
+	g.Printf(stringMap, typeName, g.recv(), g.methodName())
 }
 
-// Argument to format is the type name.
-const stringMap = `func (i %[1]s) String() string {
-	if str, ok := _%[1]s_map[i]; ok {
+// Arguments to format are:
+//
+//	[1]: type name
+//	[2]: receiver name
+//	[3]: method name
+const stringMap = `func (%[2]s %[1]s) %[3]s() string {
+	if str, ok := _%[1]s_map[%[2]s]; ok {
+		return str
+	}
+	return "%[1]s(" + strconv.FormatInt(int64(%[2]s), 10) + ")"
+}
+`
+
+// checkBitmask exits if the values of the runs are not bit flags: if a
+// value other than zero is not a combination of the values that are
+// powers of two.
+func checkBitmask(runs [][]Value, typeName string) {
+	var bits uint64
+	for _, run := range runs {
+		for _, v := range run {
+			if !v.isNegative() && v.value&(v.value-1) == 0 {
+				bits |= v.value
+			}
+		}
+	}
+	for _, run := range runs {
+		for _, v := range run {
+			if v.isNegative() || v.value&^bits != 0 {
+				log.Fatalf("%s is not a bitmask: %s (%s) is not a combination of its powers of two", typeName, v.originalName, v.str)
+			}
+		}
+	}
+}
+
+func (v *Value) isNegative() bool {
+	return v.signed && int64(v.value) < 0
+}
+
+// buildBitmask generates the variables and String method for bit flags.
+// The names of the values are in a map, as for sparse values, and a
+// value that none names is rendered as the names of its bits.
+func (g *Generator) buildBitmask(runs [][]Value, typeName string) {
+	g.Printf("\n")
+	g.declareNameVars(runs, typeName, "")
+	g.Printf("\nvar _%s_map = map[%s]string{\n", typeName, typeName)
+	var bits []Value
+	n := 0
+	for _, values := range runs {
+		for _, value := range values {
+			g.Printf("\t%s: _%s_name[%d:%d],\n", &value, typeName, n, n+len(value.name))
+			n += len(value.name)
+			if value.value != 0 && value.value&(value.value-1) == 0 {
+				bits = append(bits, value)
+			}
+		}
+	}
+	g.Printf("}\n\n")
+	g.Printf("var _%s_bits = [...]%s{", typeName, typeName)
+	for i := range bits {
+		if i > 0 {
+			g.Printf(", ")
+		}
+		g.Printf("%s", &bits[i])
+	}
+	g.Printf("}\n\n")
+	g.Printf(stringBitmask, typeName, g.recv(), g.methodName())
+}
+
+// Arguments to format are:
+//
+//	[1]: type name
+//	[2]: receiver name
+//	[3]: method name
+const stringBitmask = `func (%[2]s %[1]s) %[3]s() string {
+	if str, ok := _%[1]s_map[%[2]s]; ok {
 		return str
 	}
-	return "%[1]s(" + strconv.FormatInt(int64(i), 10) + ")"
+	var b []byte
+	for _, bit := range _%[1]s_bits {
+		if %[2]s&bit != 0 {
+			if len(b) > 0 {
+				b = append(b, '|')
+			}
+			b = append(b, _%[1]s_map[bit]...)
+			%[2]s &^= bit
+		}
+	}
+	if %[2]s != 0 || len(b) == 0 {
+		if len(b) > 0 {
+			b = append(b, '|')
+		}
+		b = append(b, "%[1]s("+strconv.FormatInt(int64(%[2]s), 10)+")"...)
+	}
+	return string(b)
+}
+`
+
+// buildParse generates the function that parses the strings that the
+// String method returns, for the unmarshaling methods.
+func (g *Generator) buildParse(runs [][]Value, typeName string) {
+	g.Printf("\nvar _%s_values = map[string]%s{\n", typeName, typeName)
+	for _, values := range runs {
+		for _, value := range values {
+			g.Printf("\t%q: %s,\n", value.name, &value)
+		}
+	}
+	g.Printf("}\n\n")
+	if g.bitmask {
+		g.Printf(parseBitmask, typeName)
+	} else {
+		g.Printf(parseValue, typeName)
+	}
+}
+
+// Argument to format is the type name.
+const parseValue = `func _%[1]s_parse(s string) (%[1]s, error) {
+	if i, ok := _%[1]s_values[s]; ok {
+		return i, nil
+	}
+	if strings.HasPrefix(s, "%[1]s(") && strings.HasSuffix(s, ")") {
+		if i, err := strconv.ParseInt(s[len("%[1]s("):len(s)-1], 10, 64); err == nil {
+			return %[1]s(i), nil
+		}
+	}
+	return 0, errors.New("invalid %[1]s: " + strconv.Quote(s))
+}
+`
+
+// Argument to format is the type name.
+const parseBitmask = `func _%[1]s_parse(s string) (%[1]s, error) {
+	if i, ok := _%[1]s_values[s]; ok {
+		return i, nil
+	}
+	var i %[1]s
+	for _, name := range strings.Split(s, "|") {
+		if bit, ok := _%[1]s_values[name]; ok {
+			i |= bit
+			continue
+		}
+		if strings.HasPrefix(name, "%[1]s(") && strings.HasSuffix(name, ")") {
+			if bits, err := strconv.ParseInt(name[len("%[1]s("):len(name)-1], 10, 64); err == nil {
+				i |= %[1]s(bits)
+				continue
+			}
+		}
+		return 0, errors.New("invalid %[1]s: " + strconv.Quote(s))
+	}
+	return i, nil
+}
+`
+
+// Arguments to format are:
+//
+//	[1]: type name
+//	[2]: receiver name
+//	[3]: method name
+const textMethods = `
+// MarshalText implements encoding.TextMarshaler.
+func (%[2]s %[1]s) MarshalText() ([]byte, error) {
+	return []byte(%[2]s.%[3]s()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (%[2]s *%[1]s) UnmarshalText(text []byte) error {
+	v, err := _%[1]s_parse(string(text))
+	if err != nil {
+		return err
+	}
+	*%[2]s = v
+	return nil
+}
+`
+
+// Arguments to format are:
+//
+//	[1]: type name
+//	[2]: receiver name
+//	[3]: method name
+const jsonMethods = `
+// MarshalJSON implements json.Marshaler.
+func (%[2]s %[1]s) MarshalJSON() ([]byte, error) {
+	return json.Marshal(%[2]s.%[3]s())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (%[2]s *%[1]s) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("%[1]s should be a string: " + err.Error())
+	}
+	v, err := _%[1]s_parse(s)
+	if err != nil {
+		return err
+	}
+	*%[2]s = v
+	return nil
 }
 `
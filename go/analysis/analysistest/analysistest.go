@@ -7,6 +7,7 @@ package analysistest
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"go/format"
 	"go/token"
@@ -15,10 +16,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"testing"
 	"text/scanner"
 
 	"golang.org/x/tools/go/analysis"
@@ -53,6 +56,44 @@ func WriteFiles(filemap map[string]string) (dir string, cleanup func(), err erro
 	return gopath, cleanup, nil
 }
 
+// RunFiles behaves like Run, but analyzes a GOPATH-style project
+// populated from filemap, which maps file names relative to the
+// project's src directory to their contents. It allows small,
+// table-driven tests to be self-contained:
+//
+//	analysistest.RunFiles(t, map[string]string{
+//		"a/a.go": "package a; func f() { println() } // want `call of println`",
+//	}, findcall.Analyzer, "a")
+//
+// The project is written to a temporary directory, which is deleted
+// when the test and its subtests are done.
+func RunFiles(t Testing, filemap map[string]string, a *analysis.Analyzer, patterns ...string) []*Result {
+	return new(Options).RunFiles(t, filemap, a, patterns...)
+}
+
+// RunFiles is like the package-level function of the same name,
+// but uses the options in opts.
+func (opts *Options) RunFiles(t Testing, filemap map[string]string, a *analysis.Analyzer, patterns ...string) []*Result {
+	dir, cleanup, err := WriteFiles(filemap)
+	if err != nil {
+		t.Errorf("writing files: %v", err)
+		return nil
+	}
+	return opts.runInTempDir(t, dir, cleanup, a, patterns...)
+}
+
+// runInTempDir runs opts.Run on the project in the temporary
+// directory dir, and calls cleanup once t and its subtests are done,
+// since subtests may consult the project files after Run returns.
+func (opts *Options) runInTempDir(t Testing, dir string, cleanup func(), a *analysis.Analyzer, patterns ...string) []*Result {
+	if c, ok := t.(interface{ Cleanup(func()) }); ok {
+		c.Cleanup(cleanup)
+	} else {
+		defer cleanup()
+	}
+	return opts.Run(t, dir, a, patterns...)
+}
+
 // TestData returns the effective filename of
 // the program's "testdata" directory.
 // This function may be overridden by projects using
@@ -71,6 +112,139 @@ type Testing interface {
 	Errorf(format string, args ...interface{})
 }
 
+// subtester is implemented by a Testing, such as *testing.T,
+// that supports subtests.
+type subtester interface {
+	Run(name string, f func(t *testing.T)) bool
+}
+
+// Options controls the behavior of the Run functions.
+// The zero value is ready to use, and yields the behavior
+// of the package-level functions.
+type Options struct {
+	// PerFile causes the expectations of each source file to be
+	// checked in a separate subtest of its package's subtest,
+	// so that each file's failures are reported individually.
+	PerFile bool
+
+	// Parallel marks the subtest of each package as parallel
+	// (see testing.T.Parallel). The analysis itself is done
+	// before the subtests are started.
+	Parallel bool
+
+	// NormalizeLineEndings causes CRLF line endings to be treated
+	// as LF when comparing suggested fixes and results against
+	// golden files, so that tests pass on checkouts whose files
+	// were converted to Windows line endings, as by git's autocrlf.
+	NormalizeLineEndings bool
+
+	// CheckPositions enables checks of the positions of analyzer
+	// output, which could otherwise escape the expectations: each
+	// diagnostic must denote a valid range within one of the files
+	// of the analyzed package, and each piece of its related
+	// information must denote a valid range. Diagnostics and facts
+	// that match no expectation are always errors.
+	CheckPositions bool
+
+	// CheckDependencies causes the expectations in the dependencies
+	// of the named packages to be checked too. Dependencies are
+	// analyzed only if the analyzer uses facts, and only those within
+	// the project directory are checked; their diagnostics and facts
+	// are otherwise silently ignored.
+	CheckDependencies bool
+
+	// CheckRequired causes the diagnostics and facts of the analyzers
+	// required, directly or indirectly, by the analyzer under test to
+	// be checked against the expectations too, as if the analyzer
+	// under test had reported them. Their results are available
+	// through the Required method of each Result.
+	CheckRequired bool
+
+	// UpdateGolden causes golden files that do not match the actual
+	// suggested fixes or results to be rewritten, instead of reported
+	// as errors, and missing golden files to be created. It is
+	// implied by a non-empty UPDATE_GOLDEN environment variable, as in:
+	//
+	//	$ UPDATE_GOLDEN=1 go test ./...
+	//
+	// Review the changes to the golden files before committing them.
+	UpdateGolden bool
+
+	// Fakes associates analyzers required, directly or indirectly, by
+	// the analyzer under test with functions to be called instead of
+	// their Run functions, so that the analyzer under test can be
+	// isolated from the behavior of its requirements. A fake must
+	// return a result of the analyzer's ResultType.
+	Fakes map[*analysis.Analyzer]func(*analysis.Pass) (interface{}, error)
+}
+
+// update reports whether golden files are to be updated.
+func (opts *Options) update() bool {
+	return opts.UpdateGolden || os.Getenv("UPDATE_GOLDEN") != ""
+}
+
+// readGolden reads and parses the golden file filename,
+// normalizing its line endings if requested.
+func (opts *Options) readGolden(filename string) (*txtar.Archive, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return txtar.Parse(opts.normalize(data)), nil
+}
+
+// normalize returns data, with CRLF line endings
+// replaced by LF if opts.NormalizeLineEndings is set.
+func (opts *Options) normalize(data []byte) []byte {
+	if opts.NormalizeLineEndings {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	return data
+}
+
+// subtest calls f with a subtest of t named name, if t supports
+// subtests, and with t itself otherwise.
+func (opts *Options) subtest(t Testing, name string, parallel bool, f func(t Testing)) {
+	st, ok := t.(subtester)
+	if !ok {
+		f(t)
+		return
+	}
+	st.Run(name, func(t *testing.T) {
+		if parallel {
+			t.Parallel()
+		}
+		f(t)
+	})
+}
+
+// report reports errs to t, grouped into
+// per-file subtests if opts.PerFile is set.
+func (opts *Options) report(t Testing, errs []checkError) {
+	if !opts.PerFile {
+		for _, err := range errs {
+			t.Errorf("%s", err.msg)
+		}
+		return
+	}
+	var files []string
+	byFile := make(map[string][]checkError)
+	for _, err := range errs {
+		if _, ok := byFile[err.file]; !ok {
+			files = append(files, err.file)
+		}
+		byFile[err.file] = append(byFile[err.file], err)
+	}
+	for _, file := range files {
+		errs := byFile[file]
+		opts.subtest(t, file, false, func(t Testing) {
+			for _, err := range errs {
+				t.Errorf("%s", err.msg)
+			}
+		})
+	}
+}
+
 // RunWithSuggestedFixes behaves like Run, but additionally verifies suggested fixes.
 // It uses golden files placed alongside the source code under analysis:
 // suggested fixes for code in example.go will be compared against example.go.golden.
@@ -99,8 +273,17 @@ type Testing interface {
 //			println()
 //		}
 //	}
+//
+// Golden files can be regenerated from the actual suggested fixes by
+// running the test with UPDATE_GOLDEN=1; see Options.UpdateGolden.
 func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns ...string) []*Result {
-	r := Run(t, dir, a, patterns...)
+	return new(Options).RunWithSuggestedFixes(t, dir, a, patterns...)
+}
+
+// RunWithSuggestedFixes is like the package-level function of the
+// same name, but uses the options in opts.
+func (opts *Options) RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns ...string) []*Result {
+	r := opts.Run(t, dir, a, patterns...)
 
 	// Process each result (package) separately, matching up the suggested
 	// fixes into a diff, which we will compare to the .golden file.  We have
@@ -130,9 +313,10 @@ func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns
 					}
 					file, endfile := act.Pass.Fset.File(edit.Pos), act.Pass.Fset.File(edit.End)
 					if file == nil || endfile == nil || file != endfile {
+						// Either file may be nil, so report the positions.
 						t.Errorf(
 							"diagnostic for analysis %v contains Suggested Fix with malformed spanning files %v and %v",
-							act.Pass.Analyzer.Name, file.Name(), endfile.Name())
+							act.Pass.Analyzer.Name, act.Pass.Fset.Position(edit.Pos), act.Pass.Fset.Position(edit.End))
 						continue
 					}
 					if _, ok := fileContents[file]; !ok {
@@ -167,11 +351,16 @@ func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns
 			}
 
 			// Get the golden file and read the contents.
-			ar, err := txtar.ParseFile(file.Name() + ".golden")
+			golden := file.Name() + ".golden"
+			ar, err := opts.readGolden(golden)
 			if err != nil {
-				t.Errorf("error reading %s.golden: %v", file.Name(), err)
-				continue
+				if !(opts.update() && os.IsNotExist(err)) {
+					t.Errorf("error reading %s: %v", golden, err)
+					continue
+				}
+				ar = new(txtar.Archive) // created below
 			}
+			updated := false
 
 			if len(ar.Files) > 0 {
 				// one virtual file per kind of suggested fix
@@ -184,34 +373,54 @@ func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns
 					continue
 				}
 
-				for sf, edits := range fixes {
-					found := false
-					for _, vf := range ar.Files {
-						if vf.Name == sf {
-							found = true
-							out := diff.ApplyEdits(string(orig), edits)
-							// the file may contain multiple trailing
-							// newlines if the user places empty lines
-							// between files in the archive. normalize
-							// this to a single newline.
-							want := string(bytes.TrimRight(vf.Data, "\n")) + "\n"
-							formatted, err := format.Source([]byte(out))
-							if err != nil {
-								t.Errorf("%s: error formatting edited source: %v\n%s", file.Name(), err, out)
-								continue
-							}
-							if want != string(formatted) {
-								d, err := myers.ComputeEdits("", want, string(formatted))
-								if err != nil {
-									t.Errorf("failed to compute suggested fix diff: %v", err)
-								}
-								t.Errorf("suggested fixes failed for %s:\n%s", file.Name(), diff.ToUnified(fmt.Sprintf("%s.golden [%s]", file.Name(), sf), "actual", want, d))
-							}
+				// Visit the fixes in a deterministic order,
+				// in case new sections are added.
+				var messages []string
+				for sf := range fixes {
+					messages = append(messages, sf)
+				}
+				sort.Strings(messages)
+
+				for _, sf := range messages {
+					var vf *txtar.File
+					for i := range ar.Files {
+						if ar.Files[i].Name == sf {
+							vf = &ar.Files[i]
 							break
 						}
 					}
-					if !found {
+					if vf == nil && !opts.update() {
 						t.Errorf("no section for suggested fix %q in %s.golden", sf, file.Name())
+						continue
+					}
+					out := diff.ApplyEdits(string(orig), fixes[sf])
+					formatted, err := format.Source([]byte(out))
+					if err != nil {
+						t.Errorf("%s: error formatting edited source: %v\n%s", file.Name(), err, out)
+						continue
+					}
+					formatted = opts.normalize(formatted)
+					if vf == nil {
+						ar.Files = append(ar.Files, txtar.File{Name: sf, Data: formatted})
+						updated = true
+						continue
+					}
+					// the file may contain multiple trailing
+					// newlines if the user places empty lines
+					// between files in the archive. normalize
+					// this to a single newline.
+					want := string(bytes.TrimRight(vf.Data, "\n")) + "\n"
+					if want != string(formatted) {
+						if opts.update() {
+							vf.Data = formatted
+							updated = true
+							continue
+						}
+						d, err := myers.ComputeEdits("", want, string(formatted))
+						if err != nil {
+							t.Errorf("failed to compute suggested fix diff: %v", err)
+						}
+						t.Errorf("suggested fixes failed for %s:\n%s", file.Name(), diff.ToUnified(fmt.Sprintf("%s.golden [%s]", file.Name(), sf), "actual", want, d))
 					}
 				}
 			} else {
@@ -230,12 +439,24 @@ func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns
 					t.Errorf("%s: error formatting resulting source: %v\n%s", file.Name(), err, out)
 					continue
 				}
+				formatted = opts.normalize(formatted)
 				if want != string(formatted) {
-					d, err := myers.ComputeEdits("", want, string(formatted))
-					if err != nil {
-						t.Errorf("%s: failed to compute suggested fix diff: %s", file.Name(), err)
+					if opts.update() {
+						ar.Comment = formatted
+						updated = true
+					} else {
+						d, err := myers.ComputeEdits("", want, string(formatted))
+						if err != nil {
+							t.Errorf("%s: failed to compute suggested fix diff: %s", file.Name(), err)
+						}
+						t.Errorf("suggested fixes failed for %s:\n%s", file.Name(), diff.ToUnified(file.Name()+".golden", "actual", want, d))
 					}
-					t.Errorf("suggested fixes failed for %s:\n%s", file.Name(), diff.ToUnified(file.Name()+".golden", "actual", want, d))
+				}
+			}
+
+			if updated {
+				if err := ioutil.WriteFile(golden, txtar.Format(ar), 0666); err != nil {
+					t.Errorf("error updating %s: %v", golden, err)
 				}
 			}
 		}
@@ -275,37 +496,352 @@ func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns
 //	// want "diag" "diag2" x:"fact1" x:"fact2" y:"fact3"
 //
 // Unexpected diagnostics and facts, and unmatched expectations, are
-// reported as errors to the Testing.
+// reported as errors to the Testing. If the Testing supports subtests,
+// as *testing.T does, the errors for each package are reported in a
+// separate subtest named after the package, such as "a" or
+// "a [a.test]", so that a single package can be selected with -run.
 //
 // Run reports an error to the Testing if loading or analysis failed.
 // Run also returns a Result for each package for which analysis was
 // attempted, even if unsuccessful. It is safe for a test to ignore all
 // the results, but a test may use it to perform additional checks.
 func Run(t Testing, dir string, a *analysis.Analyzer, patterns ...string) []*Result {
+	return new(Options).Run(t, dir, a, patterns...)
+}
+
+// Run is like the package-level function of the same name,
+// but uses the options in opts.
+func (opts *Options) Run(t Testing, dir string, a *analysis.Analyzer, patterns ...string) []*Result {
 	if t, ok := t.(testenv.Testing); ok {
 		testenv.NeedsGoPackages(t)
 	}
 
+	if err := checkFakes(a, opts.Fakes); err != nil {
+		t.Errorf("%v", err)
+		return nil
+	}
+
 	pkgs, err := loadPackages(a, dir, patterns...)
 	if err != nil {
 		t.Errorf("loading %s: %v", patterns, err)
 		return nil
 	}
 
-	results := checker.TestAnalyzer(a, pkgs)
+	results, deps := checker.TestAnalyzerDeps(a, pkgs, opts.Fakes)
 	for _, result := range results {
+		opts.checkResult(t, dir, result)
+	}
+	if opts.CheckDependencies {
+		for _, result := range deps {
+			// Check only the dependencies within the project,
+			// not those in GOROOT.
+			if result.Pass != nil && len(result.Pass.Files) > 0 {
+				filename := result.Pass.Fset.File(result.Pass.Files[0].Pos()).Name()
+				if sanitize(dir, filename) != filepath.ToSlash(filename) {
+					opts.checkResult(t, dir, result)
+				}
+			}
+		}
+	}
+	return results
+}
+
+// checkResult checks the result of analyzing one package against
+// its expectations, in a subtest if t supports them.
+func (opts *Options) checkResult(t Testing, dir string, result *Result) {
+	if result.Pass == nil {
+		// A prerequisite failed before the pass was created.
+		t.Errorf("error analyzing package: %v", result.Err)
+		return
+	}
+	opts.subtest(t, packageID(result.Pass), opts.Parallel, func(t Testing) {
 		if result.Err != nil {
 			t.Errorf("error analyzing %s: %v", result.Pass, result.Err)
-		} else {
-			check(t, dir, result.Pass, result.Diagnostics, result.Facts)
+			return
+		}
+		diagnostics, facts := result.Diagnostics, result.Facts
+		if opts.CheckRequired {
+			diagnostics = append([]analysis.Diagnostic(nil), diagnostics...)
+			facts = make(map[types.Object][]analysis.Fact)
+			for obj, fs := range result.Facts {
+				facts[obj] = append(facts[obj], fs...)
+			}
+			for _, req := range result.Required() {
+				if req.Err != nil {
+					// Required analyzer failed, so result.Err is non-nil.
+					continue
+				}
+				diagnostics = append(diagnostics, req.Diagnostics...)
+				for obj, fs := range req.Facts {
+					facts[obj] = append(facts[obj], fs...)
+				}
+			}
+		}
+		errs := check(dir, result.Pass, diagnostics, facts)
+		if opts.CheckPositions {
+			errs = append(errs, checkPositions(dir, result.Pass, diagnostics)...)
+		}
+		opts.report(t, errs)
+	})
+}
+
+// checkFakes reports an error if fakes has an entry for an analyzer
+// that is not required, directly or indirectly, by a.
+func checkFakes(a *analysis.Analyzer, fakes map[*analysis.Analyzer]func(*analysis.Pass) (interface{}, error)) error {
+	required := make(map[*analysis.Analyzer]bool)
+	var visit func(a *analysis.Analyzer)
+	visit = func(a *analysis.Analyzer) {
+		for _, req := range a.Requires {
+			if !required[req] {
+				required[req] = true
+				visit(req)
+			}
 		}
 	}
-	return results
+	visit(a)
+	for fake := range fakes {
+		if !required[fake] {
+			return fmt.Errorf("fake for analyzer %s, which is not required by %s", fake, a)
+		}
+	}
+	return nil
+}
+
+// checkPositions reports the diagnostics whose positions do not denote
+// a valid range within one of the files of the package, and so cannot
+// be asserted by an expectation, and those whose related information
+// does not denote a valid range.
+func checkPositions(gopath string, pass *analysis.Pass, diagnostics []analysis.Diagnostic) []checkError {
+	files := make(map[*token.File]bool)
+	for _, f := range pass.Files {
+		files[pass.Fset.File(f.Pos())] = true
+	}
+
+	var errs []checkError
+	checkRange := func(what, message string, pos, end token.Pos, local bool) {
+		posn := pass.Fset.Position(pos)
+		posn.Filename = sanitize(gopath, posn.Filename)
+		file := pass.Fset.File(pos)
+		switch {
+		case !pos.IsValid():
+			msg := fmt.Sprintf("%s %q has no position", what, message)
+			errs = append(errs, checkError{"", msg})
+		case local && !files[file]:
+			msg := fmt.Sprintf("%v: %s %q is not in a file of package %s", posn, what, message, pass.Pkg.Path())
+			errs = append(errs, checkError{posn.Filename, msg})
+		case end.IsValid() && (pass.Fset.File(end) != file || end < pos):
+			msg := fmt.Sprintf("%v: %s %q has an invalid end position", posn, what, message)
+			errs = append(errs, checkError{posn.Filename, msg})
+		}
+	}
+	for _, d := range diagnostics {
+		checkRange("diagnostic", d.Message, d.Pos, d.End, true)
+		for _, rel := range d.Related {
+			// Related information may refer to other packages.
+			checkRange("related information", rel.Message, rel.Pos, rel.End, false)
+		}
+	}
+	return errs
+}
+
+// packageID returns the go/packages ID of the package analyzed by
+// pass, which distinguishes the test variants of a package, such as
+// "a", "a [a.test]", "a_test [a.test]" and "a.test".
+func packageID(pass *analysis.Pass) string {
+	path := pass.Pkg.Path()
+	for _, f := range pass.Files {
+		if strings.HasSuffix(pass.Fset.File(f.Pos()).Name(), "_test.go") {
+			return fmt.Sprintf("%s [%s.test]", path, strings.TrimSuffix(path, "_test"))
+		}
+	}
+	return path
 }
 
 // A Result holds the result of applying an analyzer to a package.
 type Result = checker.TestAnalyzerResult
 
+// ResultOf finds the Result for the package with the specified import
+// path among results, as returned by Run, and stores the analyzer's
+// result in the variable pointed to by target, which must be a non-nil
+// pointer to a type to which the analyzer's ResultType is assignable.
+//
+// If several variants of the package were analyzed (such as "p" and
+// "p [p.test]"), the first one is used; this is the non-test variant
+// if there is one.
+//
+// ResultOf reports an error to the Testing and returns false if no
+// package matches, if the analysis of the package failed, or if the
+// result has an unsuitable type.
+func ResultOf(t Testing, results []*Result, pkgpath string, target interface{}) bool {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		t.Errorf("ResultOf: target must be a non-nil pointer, got %T", target)
+		return false
+	}
+	for _, r := range results {
+		if r.Pass == nil || r.Pass.Pkg.Path() != pkgpath {
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("no result of %s for %s: %v", r.Pass.Analyzer.Name, pkgpath, r.Err)
+			return false
+		}
+		elem := ptr.Elem()
+		if r.Result == nil {
+			// A nil result is assignable to any nillable target.
+			switch elem.Kind() {
+			case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+				elem.Set(reflect.Zero(elem.Type()))
+				return true
+			}
+			t.Errorf("result of %s for %s is nil, which cannot be stored in %v",
+				r.Pass.Analyzer.Name, pkgpath, elem.Type())
+			return false
+		}
+		res := reflect.ValueOf(r.Result)
+		if !res.Type().AssignableTo(elem.Type()) {
+			t.Errorf("result of %s for %s has type %v, which is not assignable to %v",
+				r.Pass.Analyzer.Name, pkgpath, res.Type(), elem.Type())
+			return false
+		}
+		elem.Set(res)
+		return true
+	}
+	var paths []string
+	for _, r := range results {
+		if r.Pass != nil {
+			paths = append(paths, r.Pass.Pkg.Path())
+		}
+	}
+	t.Errorf("no result for package %q (analyzed: %s)", pkgpath, strings.Join(paths, ", "))
+	return false
+}
+
+// RunWithGoldenResults behaves like Run, but additionally compares the
+// result of the analyzer for each package against a golden file.
+//
+// The canonical form of a result is the value of its String method if
+// it implements fmt.Stringer, and its indented JSON encoding otherwise.
+// The golden file for the packages in a directory is named after the
+// analyzer, for example printf.result.golden, and is placed alongside
+// the packages' source files. It is a txtar archive with one section
+// per package, named by import path:
+//
+//	-- a --
+//	{"Funcs": ["f", "g"]}
+//	-- a_test --
+//	{"Funcs": ["TestF"]}
+//
+// A golden file with no sections holds the canonical form of the result
+// of the single package in its directory. Only the first analyzed
+// variant of each package is compared; see ResultOf. As with
+// RunWithSuggestedFixes, golden files can be regenerated by running
+// the test with UPDATE_GOLDEN=1.
+func RunWithGoldenResults(t Testing, dir string, a *analysis.Analyzer, patterns ...string) []*Result {
+	return new(Options).RunWithGoldenResults(t, dir, a, patterns...)
+}
+
+// RunWithGoldenResults is like the package-level function of the
+// same name, but uses the options in opts.
+func (opts *Options) RunWithGoldenResults(t Testing, dir string, a *analysis.Analyzer, patterns ...string) []*Result {
+	r := opts.Run(t, dir, a, patterns...)
+
+	seen := make(map[string]bool) // import paths
+	for _, act := range r {
+		if act.Err != nil || len(act.Pass.Files) == 0 {
+			continue
+		}
+		pkgpath := act.Pass.Pkg.Path()
+		if act.Pass.Pkg.Name() == "main" && strings.HasSuffix(pkgpath, ".test") {
+			continue // synthesized test main package
+		}
+		if seen[pkgpath] {
+			continue // another variant of an already compared package
+		}
+		seen[pkgpath] = true
+
+		got, err := formatResult(act.Result)
+		if err != nil {
+			t.Errorf("formatting result of %s for %s: %v", a.Name, pkgpath, err)
+			continue
+		}
+
+		pkgdir := filepath.Dir(act.Pass.Fset.File(act.Pass.Files[0].Pos()).Name())
+		golden := filepath.Join(pkgdir, a.Name+".result.golden")
+		ar, err := opts.readGolden(golden)
+		if err != nil {
+			if !(opts.update() && os.IsNotExist(err)) {
+				t.Errorf("error reading %s: %v", golden, err)
+				continue
+			}
+			ar = new(txtar.Archive) // created below
+		}
+
+		var want string
+		var section *txtar.File // the package's section, if any
+		if len(ar.Files) > 0 {
+			if len(ar.Comment) != 0 {
+				t.Errorf("%s has leading comment; we don't know what to do with it", golden)
+				continue
+			}
+			for i := range ar.Files {
+				if ar.Files[i].Name == pkgpath {
+					section = &ar.Files[i]
+					break
+				}
+			}
+			if section == nil && !opts.update() {
+				t.Errorf("no section for package %q in %s", pkgpath, golden)
+				continue
+			}
+			if section != nil {
+				want = string(section.Data)
+			}
+		} else {
+			want = string(ar.Comment)
+		}
+
+		// Compare modulo trailing newlines, which are hard
+		// to control at the end of a txtar section.
+		want = strings.TrimRight(want, "\n") + "\n"
+		got = strings.TrimRight(string(opts.normalize([]byte(got))), "\n") + "\n"
+		if want != got && opts.update() {
+			switch {
+			case section != nil:
+				section.Data = []byte(got)
+			case len(ar.Files) > 0:
+				ar.Files = append(ar.Files, txtar.File{Name: pkgpath, Data: []byte(got)})
+			default:
+				ar.Comment = []byte(got)
+			}
+			if err := ioutil.WriteFile(golden, txtar.Format(ar), 0666); err != nil {
+				t.Errorf("error updating %s: %v", golden, err)
+			}
+		} else if want != got {
+			d, err := myers.ComputeEdits("", want, got)
+			if err != nil {
+				t.Errorf("failed to compute result diff: %v", err)
+			}
+			t.Errorf("result of %s for %s does not match golden file:\n%s",
+				a.Name, pkgpath, diff.ToUnified(fmt.Sprintf("%s [%s]", golden, pkgpath), "actual", want, d))
+		}
+	}
+	return r
+}
+
+// formatResult returns the canonical form of an analyzer's result:
+// its String method if it has one, and its JSON encoding otherwise.
+func formatResult(res interface{}) (string, error) {
+	if s, ok := res.(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	data, err := json.MarshalIndent(res, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // loadPackages uses go/packages to load a specified packages (from source, with
 // dependencies) from dir, which is the root of a GOPATH-style project
 // tree. It returns an error if any package had an error, or the pattern
@@ -346,16 +882,29 @@ func loadPackages(a *analysis.Analyzer, dir string, patterns ...string) ([]*pack
 	return pkgs, nil
 }
 
+// A checkError is a discrepancy between the expectations in a file
+// and the diagnostics and facts actually reported.
+type checkError struct {
+	file string // sanitized file name
+	msg  string
+}
+
 // check inspects an analysis pass on which the analysis has already
 // been run, and verifies that all reported diagnostics and facts match
 // specified by the contents of "// want ..." comments in the package's
 // source files, which must have been parsed with comments enabled.
-func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis.Diagnostic, facts map[types.Object][]analysis.Fact) {
+// It returns the discrepancies in the order they were found.
+func check(gopath string, pass *analysis.Pass, diagnostics []analysis.Diagnostic, facts map[types.Object][]analysis.Fact) []checkError {
 	type key struct {
 		file string
 		line int
 	}
 
+	var errs []checkError
+	errorf := func(file, format string, args ...interface{}) {
+		errs = append(errs, checkError{file, fmt.Sprintf(format, args...)})
+	}
+
 	want := make(map[key][]expectation)
 
 	// processComment parses expectations out of comments.
@@ -367,7 +916,7 @@ func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis
 		if rest := strings.TrimPrefix(text, "want"); rest != text {
 			lineDelta, expects, err := parseExpectations(rest)
 			if err != nil {
-				t.Errorf("%s:%d: in 'want' comment: %s", filename, linenum, err)
+				errorf(filename, "%s:%d: in 'want' comment: %s", filename, linenum, err)
 				return
 			}
 			if expects != nil {
@@ -412,7 +961,7 @@ func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis
 	for _, filename := range pass.OtherFiles {
 		data, err := ioutil.ReadFile(filename)
 		if err != nil {
-			t.Errorf("can't read '// want' comments from %s: %v", filename, err)
+			errorf(sanitize(gopath, filename), "can't read '// want' comments from %s: %v", filename, err)
 			continue
 		}
 		filename := sanitize(gopath, filename)
@@ -454,9 +1003,9 @@ func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis
 			}
 		}
 		if unmatched == nil {
-			t.Errorf("%v: unexpected %s: %v", posn, kind, message)
+			errorf(posn.Filename, "%v: unexpected %s: %v", posn, kind, message)
 		} else {
-			t.Errorf("%v: %s %q does not match pattern %s",
+			errorf(posn.Filename, "%v: %s %q does not match pattern %s",
 				posn, kind, message, strings.Join(unmatched, " or "))
 		}
 	}
@@ -511,17 +1060,15 @@ func check(t Testing, gopath string, pass *analysis.Pass, diagnostics []analysis
 	// the error message.
 	// TODO(adonovan): print a better error:
 	// "got 2 diagnostics here; each one needs its own expectation".
-	var surplus []string
+	var surplus []checkError
 	for key, expects := range want {
 		for _, exp := range expects {
-			err := fmt.Sprintf("%s:%d: no %s was reported matching %#q", key.file, key.line, exp.kind, exp.rx)
-			surplus = append(surplus, err)
+			msg := fmt.Sprintf("%s:%d: no %s was reported matching %#q", key.file, key.line, exp.kind, exp.rx)
+			surplus = append(surplus, checkError{key.file, msg})
 		}
 	}
-	sort.Strings(surplus)
-	for _, err := range surplus {
-		t.Errorf("%s", err)
-	}
+	sort.Slice(surplus, func(i, j int) bool { return surplus[i].msg < surplus[j].msg })
+	return append(errs, surplus...)
 }
 
 type expectation struct {
@@ -598,7 +1145,10 @@ func parseExpectations(text string) (lineDelta int, expects []expectation, err e
 
 // sanitize removes the GOPATH portion of the filename,
 // typically a gnarly /tmp directory, and returns the rest.
+// Both are compared in slash-separated form, as on Windows
+// the directory may have been specified with either kind of
+// separator.
 func sanitize(gopath, filename string) string {
-	prefix := gopath + string(os.PathSeparator) + "src" + string(os.PathSeparator)
-	return filepath.ToSlash(strings.TrimPrefix(filename, prefix))
+	prefix := filepath.ToSlash(gopath) + "/src/"
+	return strings.TrimPrefix(filepath.ToSlash(filename), prefix)
 }
@@ -6,12 +6,17 @@ package analysistest_test
 
 import (
 	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/analysistest"
 	"golang.org/x/tools/go/analysis/passes/findcall"
 	"golang.org/x/tools/internal/testenv"
@@ -155,6 +160,402 @@ func println(...interface{}) { println_TEST_() } // want println:"found" "call o
 	}
 }
 
+// TestNormalizeLineEndings tests the comparison of suggested
+// fixes against golden files with Windows line endings.
+func TestNormalizeLineEndings(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	findcall.Analyzer.Flags.Set("name", "println")
+
+	crlf := func(s string) string { return strings.ReplaceAll(s, "\n", "\r\n") }
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"a/a.go":        crlf("package a\n\nfunc f() { println() } // want `call of println`\n"),
+		"a/a.go.golden": crlf("-- Add '_TEST_' --\npackage a\n\nfunc f() { println_TEST_() } // want `call of println`\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	opts := &analysistest.Options{NormalizeLineEndings: true}
+	opts.RunWithSuggestedFixes(t, dir, findcall.Analyzer, "a")
+}
+
+// TestCheckPositions tests the checks of the positions of diagnostics.
+func TestCheckPositions(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	badPositions := &analysis.Analyzer{
+		Name: "badpos",
+		Doc:  "report ill-positioned diagnostics",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			f := pass.Files[0]
+			pass.Reportf(token.NoPos, "nowhere")
+			pass.Report(analysis.Diagnostic{Pos: f.End(), End: f.Pos(), Message: "backwards"})
+			return nil, nil
+		},
+	}
+
+	var got []string
+	t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+	opts := &analysistest.Options{CheckPositions: true}
+	opts.RunFiles(t2, map[string]string{
+		"a/a.go": "package a\n\nvar x int // want \"backwards\"\n",
+	}, badPositions, "a")
+
+	want := []string{
+		"-: unexpected diagnostic: nowhere",
+		`diagnostic "nowhere" has no position`,
+		`a/a.go:3:10: diagnostic "backwards" has an invalid end position`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s",
+			strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+}
+
+// TestUnexpectedFact tests that a fact without an expectation is an
+// error, even without CheckPositions.
+func TestUnexpectedFact(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	findcall.Analyzer.Flags.Set("name", "println")
+
+	var got []string
+	t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+	analysistest.RunFiles(t2, map[string]string{
+		"a/a.go": "package a // want package:\"found\"\n\nfunc println() {}\n",
+	}, findcall.Analyzer, "a")
+
+	want := []string{"a/a.go:3:6: unexpected fact: found"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s",
+			strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+}
+
+// TestCheckDependencies tests the checking of
+// expectations in dependencies of the named packages.
+func TestCheckDependencies(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	findcall.Analyzer.Flags.Set("name", "println")
+
+	filemap := map[string]string{
+		"a/a.go": "package a\n\nimport _ \"b\"\n",
+		"b/b.go": "package b\n\nfunc f() { println() } // want `wrong`\n",
+	}
+	for _, check := range []bool{false, true} {
+		var got []string
+		t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+		opts := &analysistest.Options{CheckDependencies: check}
+		opts.RunFiles(t2, filemap, findcall.Analyzer, "a")
+
+		var want []string
+		if check {
+			want = []string{
+				"b/b.go:3:19: diagnostic \"call of println(...)\" does not match pattern `wrong`",
+				"b/b.go:3: no diagnostic was reported matching `wrong`",
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("CheckDependencies=%t: got:\n%s\nwant:\n%s",
+				check,
+				strings.Join(got, "\n"),
+				strings.Join(want, "\n"))
+		}
+	}
+}
+
+// TestFakes tests the replacement of required analyzers
+// by fakes, and access to the results of required analyzers.
+func TestFakes(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	// requirer reports the number of functions
+	// found by funcsAnalyzer, which it requires.
+	requirer := &analysis.Analyzer{
+		Name:     "requirer",
+		Doc:      "count function names",
+		Requires: []*analysis.Analyzer{funcsAnalyzer},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			names := pass.ResultOf[funcsAnalyzer].(funcNames)
+			pass.Reportf(pass.Files[0].Package, "%d funcs", len(names))
+			return nil, nil
+		},
+	}
+	filemap := map[string]string{
+		"a/a.go": "package a // want `2 funcs`\n\nfunc f() {}\n\nfunc g() {}\n",
+	}
+
+	analysistest.RunFiles(t, filemap, requirer, "a")
+
+	opts := &analysistest.Options{
+		Fakes: map[*analysis.Analyzer]func(*analysis.Pass) (interface{}, error){
+			funcsAnalyzer: func(*analysis.Pass) (interface{}, error) {
+				return funcNames{"x", "y", "z"}, nil
+			},
+		},
+	}
+	var got []string
+	t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+	results := opts.RunFiles(t2, filemap, requirer, "a")
+	want := []string{
+		"a/a.go:1:1: diagnostic \"3 funcs\" does not match pattern `2 funcs`",
+		"a/a.go:1: no diagnostic was reported matching `2 funcs`",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with fakes: got:\n%s\nwant:\n%s",
+			strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+	if len(results) > 0 {
+		var names funcNames
+		if analysistest.ResultOf(t, results[0].Required(), "a", &names) && len(names) != 3 {
+			t.Errorf("result of fake funcs analyzer = %v, want [x y z]", names)
+		}
+	}
+
+	got = nil
+	opts = &analysistest.Options{
+		Fakes: map[*analysis.Analyzer]func(*analysis.Pass) (interface{}, error){
+			requirer: nil,
+		},
+	}
+	opts.RunFiles(t2, filemap, funcsAnalyzer, "a")
+	want = []string{"fake for analyzer requirer, which is not required by funcs"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid fakes: got %q, want %q", got, want)
+	}
+}
+
+// TestCheckRequired tests the checking of required
+// analyzers' diagnostics and facts.
+func TestCheckRequired(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	findcall.Analyzer.Flags.Set("name", "println")
+
+	requirer := &analysis.Analyzer{
+		Name:     "requirer",
+		Doc:      "require findcall",
+		Requires: []*analysis.Analyzer{findcall.Analyzer},
+		Run:      func(pass *analysis.Pass) (interface{}, error) { return nil, nil },
+	}
+	opts := &analysistest.Options{CheckRequired: true}
+	opts.RunFiles(t, map[string]string{
+		"a/a.go": `package a // want package:"found"
+
+func f() { println() } // want "call of println"
+
+func println(...interface{}) {} // want println:"found"
+`,
+	}, requirer, "a")
+}
+
+// TestUpdateGolden tests the regeneration of golden files.
+func TestUpdateGolden(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	findcall.Analyzer.Flags.Set("name", "println")
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"a/a.go":        "package a\n\nfunc f() { println() } // want `call of println`\n",
+		"a/a.go.golden": "-- Add '_TEST_' --\npackage a\n\nfunc f() { stale() }\n",
+		"b/b.go":        "package b\n\nfunc f() {}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	opts := &analysistest.Options{UpdateGolden: true}
+	opts.RunWithSuggestedFixes(t, dir, findcall.Analyzer, "a")
+	opts.RunWithGoldenResults(t, dir, funcsAnalyzer, "b")
+
+	for file, want := range map[string]string{
+		"a/a.go.golden":         "-- Add '_TEST_' --\npackage a\n\nfunc f() { println_TEST_() } // want `call of println`\n",
+		"b/funcs.result.golden": "[\n\t\"f\"\n]\n",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "src", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(data); got != want {
+			t.Errorf("updated %s = %q, want %q", file, got, want)
+		}
+	}
+
+	// The updated golden files now match.
+	analysistest.RunWithSuggestedFixes(t, dir, findcall.Analyzer, "a")
+	analysistest.RunWithGoldenResults(t, dir, funcsAnalyzer, "b")
+}
+
+// TestMalformedFix tests the report of a suggested fix whose edit
+// is in no file.
+func TestMalformedFix(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	nowhere := &analysis.Analyzer{
+		Name: "nowhere",
+		Doc:  "suggest a fix without position",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			pass.Report(analysis.Diagnostic{
+				Pos:            pass.Files[0].Name.Pos(),
+				Message:        "fix nowhere",
+				SuggestedFixes: []analysis.SuggestedFix{{Message: "nothing", TextEdits: []analysis.TextEdit{{}}}},
+			})
+			return nil, nil
+		},
+	}
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"a/a.go": "package a // want \"fix nowhere\"\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	var got []string
+	t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+	analysistest.RunWithSuggestedFixes(t2, dir, nowhere, "a")
+
+	want := []string{
+		"diagnostic for analysis nowhere contains Suggested Fix with malformed spanning files - and -",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s",
+			strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+}
+
+// TestSubtests tests that each package is checked in its own subtest.
+func TestSubtests(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	findcall.Analyzer.Flags.Set("name", "println")
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"a/a.go":      "package a\n\nfunc f() { println() } // want `call of println`\n",
+		"a/a_test.go": "package a\n\nfunc g() { println() } // want `call of println`\n",
+		"b/b.go":      "package b\n\nfunc f() { println() } // want `call of println`\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	rec := &subtestRecorder{T: t}
+	opts := &analysistest.Options{PerFile: true, Parallel: true}
+	opts.Run(rec, dir, findcall.Analyzer, "a", "b")
+
+	want := []string{"a", "b", "a [a.test]", "a.test"} // go/packages order
+	if !reflect.DeepEqual(rec.names, want) {
+		t.Errorf("got subtests %q, want %q", rec.names, want)
+	}
+}
+
+// subtestRecorder is a *testing.T that records the names of its subtests.
+type subtestRecorder struct {
+	*testing.T
+	names []string
+}
+
+func (r *subtestRecorder) Run(name string, f func(t *testing.T)) bool {
+	r.names = append(r.names, name)
+	return r.T.Run(name, f)
+}
+
+// funcsAnalyzer is a trivial analyzer whose result
+// is the list of names of the package's functions.
+var funcsAnalyzer = &analysis.Analyzer{
+	Name:       "funcs",
+	Doc:        "list function names",
+	ResultType: reflect.TypeOf(funcNames(nil)),
+	Run: func(pass *analysis.Pass) (interface{}, error) {
+		var names funcNames
+		for _, f := range pass.Files {
+			for _, decl := range f.Decls {
+				if decl, ok := decl.(*ast.FuncDecl); ok {
+					names = append(names, decl.Name.Name)
+				}
+			}
+		}
+		return names, nil
+	},
+}
+
+type funcNames []string
+
+// TestResultOf tests the retrieval of analyzer results.
+func TestResultOf(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"a/a.go": "package a; func f() {}; func g() {}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	results := analysistest.Run(t, dir, funcsAnalyzer, "a")
+
+	var names funcNames
+	if analysistest.ResultOf(t, results, "a", &names) {
+		if want := (funcNames{"f", "g"}); !reflect.DeepEqual(names, want) {
+			t.Errorf("got result %v, want %v", names, want)
+		}
+	}
+
+	var got []string
+	t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+	var wrong map[string]bool
+	analysistest.ResultOf(t2, results, "a", &wrong)
+	analysistest.ResultOf(t2, results, "b", &names)
+	analysistest.ResultOf(t2, results, "a", names)
+	want := []string{
+		"result of funcs for a has type analysistest_test.funcNames, which is not assignable to map[string]bool",
+		`no result for package "b" (analyzed: a)`,
+		"ResultOf: target must be a non-nil pointer, got analysistest_test.funcNames",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s",
+			strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+}
+
+// TestRunWithGoldenResults tests the comparison of
+// analyzer results against golden files.
+func TestRunWithGoldenResults(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"a/a.go":                "package a; func f() {}",
+		"a/funcs.result.golden": "[\n\t\"f\"\n]\n",
+		"b/b.go":                "package b; func f() {}; func g() {}",
+		"b/b_test.go":           "package b_test; func ExampleF() {}",
+		"b/funcs.result.golden": "-- b --\n[\"wrong\"]\n-- b_test --\n[\n\t\"ExampleF\"\n]\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	var got []string
+	t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+	analysistest.RunWithGoldenResults(t2, dir, funcsAnalyzer, "a", "b")
+
+	if len(got) != 1 || !strings.HasPrefix(got[0], "result of funcs for b does not match golden file") {
+		t.Errorf("got errors:\n%s\nwant one mismatch for package b", strings.Join(got, "\n"))
+	}
+}
+
 type errorfunc func(string)
 
 func (f errorfunc) Errorf(format string, args ...interface{}) {
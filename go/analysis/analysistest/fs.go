@@ -0,0 +1,67 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.16
+// +build go1.16
+
+package analysistest
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// WriteFS is like WriteFiles, but populates the GOPATH-style project
+// with the contents of fsys, whose root corresponds to the project's
+// src directory.
+func WriteFS(fsys fs.FS) (dir string, cleanup func(), err error) {
+	gopath, err := ioutil.TempDir("", "analysistest")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(gopath) }
+
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		filename := filepath.Join(gopath, "src", filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(filename), 0777) // ignore error
+		return ioutil.WriteFile(filename, data, 0666)
+	})
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return gopath, cleanup, nil
+}
+
+// RunFS behaves like Run, but analyzes the GOPATH-style project
+// whose src directory is the root of fsys, such as an embed.FS or
+// a testing/fstest.MapFS, instead of a project on disk.
+//
+// The project is written to a temporary directory, which is deleted
+// when the test and its subtests are done.
+func RunFS(t Testing, fsys fs.FS, a *analysis.Analyzer, patterns ...string) []*Result {
+	return new(Options).RunFS(t, fsys, a, patterns...)
+}
+
+// RunFS is like the package-level function of the same name,
+// but uses the options in opts.
+func (opts *Options) RunFS(t Testing, fsys fs.FS, a *analysis.Analyzer, patterns ...string) []*Result {
+	dir, cleanup, err := WriteFS(fsys)
+	if err != nil {
+		t.Errorf("writing files: %v", err)
+		return nil
+	}
+	return opts.runInTempDir(t, dir, cleanup, a, patterns...)
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.16
+// +build go1.16
+
+package analysistest_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/passes/findcall"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestRunFS(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	findcall.Analyzer.Flags.Set("name", "println")
+
+	fsys := fstest.MapFS{
+		"a/a.go": {Data: []byte("package a\n\nfunc f() { println() } // want `call of println`\n")},
+	}
+	analysistest.RunFS(t, fsys, findcall.Analyzer, "a")
+}
+
+func TestRunFiles(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	findcall.Analyzer.Flags.Set("name", "println")
+
+	var got []string
+	t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+	analysistest.RunFiles(t2, map[string]string{
+		"a/a.go": "package a\n\nfunc f() { println() } // want `wrong`\n",
+	}, findcall.Analyzer, "a")
+
+	want := []string{
+		"a/a.go:3:19: diagnostic \"call of println(...)\" does not match pattern `wrong`",
+		"a/a.go:3: no diagnostic was reported matching `wrong`",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s",
+			strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,578 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+// This file defines the configuration file of the multichecker,
+// which is named .staticanalysis.toml and found in the working
+// directory or one of its parents, unless specified by -config.
+// For example:
+//
+//	# Analyzers to run, if not all; and analyzers not to run.
+//	enable = ["printf", "shadow", "nilness"]
+//	disable = ["shadow"]
+//
+//	# Files whose diagnostics are neither reported nor fixed, by path
+//	# relative to this file. A pattern that matches a directory matches all files
+//	# within it.
+//	exclude = ["vendor", "internal/gen/*.go"]
+//
+//	# The severity of each analyzer's diagnostics: "error", the
+//	# default, or "warning", which does not affect the exit status
+//	# unless -fail-on=warning. The -severity flag overrides this table.
+//	[severity]
+//	shadow = "warning"
+//
+//	# Analyzer flags, as if by -printf.funcs=Logf,Warnf. An array of
+//	# strings is a comma-separated list.
+//	[analyzers.printf]
+//	funcs = "Logf,Warnf"
+//	wrappers = ["example.com/log.Info:printf", "example.com/log.Wrap:errorf"]
+//
+// Command-line flags override the settings of the file: the -NAME
+// flags override the enable and disable lists, and the -NAME.FLAG
+// flags override the analyzer flags.
+//
+// The file is in a subset of TOML: tables, and key/value pairs whose
+// values are strings, booleans, integers, or arrays of them.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// ConfigFileName is the name of the configuration file.
+const ConfigFileName = ".staticanalysis.toml"
+
+// Config is the configuration file in use, if any.
+// It is set by ParseWithConfigFile.
+var Config *ConfigFile
+
+// A ConfigFile is a parsed configuration file.
+type ConfigFile struct {
+	Filename  string
+	Enable    []string                     // analyzers to run, if not all
+	Disable   []string                     // analyzers not to run
+	Exclude   []string                     // patterns of files whose diagnostics are suppressed; see ExcludePaths
+	Severity  map[string]string            // "error" or "warning", by analyzer name
+	Analyzers map[string]map[string]string // flag values, by analyzer and flag name
+}
+
+// FindConfigFile returns the configuration file in dir or its nearest
+// parent directory that has one, or nil if there is none.
+func FindConfigFile(dir string) (*ConfigFile, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		filename := filepath.Join(dir, ConfigFileName)
+		if _, err := os.Stat(filename); err == nil {
+			return ReadConfigFile(filename)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// ReadConfigFile reads and parses the named configuration file.
+func ReadConfigFile(filename string) (*ConfigFile, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	filename, err = filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := parseTOML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", filename, err)
+	}
+	cfg, err := decodeConfig(tree)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	cfg.Filename = filename
+	return cfg, nil
+}
+
+func decodeConfig(tree map[string]interface{}) (*ConfigFile, error) {
+	cfg := &ConfigFile{
+		Severity:  make(map[string]string),
+		Analyzers: make(map[string]map[string]string),
+	}
+	stringList := func(key string, v interface{}) ([]string, error) {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s must be an array of strings", key)
+		}
+		var res []string
+		for _, elem := range list {
+			s, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be an array of strings", key)
+			}
+			res = append(res, s)
+		}
+		return res, nil
+	}
+	for _, key := range sortedKeys(tree) {
+		v := tree[key]
+		var err error
+		switch key {
+		case "enable":
+			cfg.Enable, err = stringList(key, v)
+		case "disable":
+			cfg.Disable, err = stringList(key, v)
+		case "exclude":
+			cfg.Exclude, err = stringList(key, v)
+			for _, pattern := range cfg.Exclude {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("invalid exclude pattern %q: %v", pattern, err)
+				}
+			}
+		case "severity":
+			table, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("severity must be a table")
+			}
+			for name, sev := range table {
+				switch sev {
+				case "error", "warning":
+					cfg.Severity[name] = sev.(string)
+				default:
+					return nil, fmt.Errorf("severity of %s must be \"error\" or \"warning\"", name)
+				}
+			}
+		case "analyzers":
+			table, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("analyzers must be a table")
+			}
+			for name, settings := range table {
+				settings, ok := settings.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("analyzers.%s must be a table", name)
+				}
+				m := make(map[string]string)
+				for flag, value := range settings {
+					switch value := value.(type) {
+					case string, bool, int64:
+						m[flag] = fmt.Sprint(value)
+					case []interface{}:
+						// An array of strings is a comma-separated list.
+						list, err := stringList("analyzers."+name+"."+flag, value)
+						if err != nil {
+							return nil, err
+						}
+						m[flag] = strings.Join(list, ",")
+					default:
+						return nil, fmt.Errorf("analyzers.%s.%s must be a string, boolean, integer, or array of strings", name, flag)
+					}
+				}
+				cfg.Analyzers[name] = m
+			}
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// apply sets the flags of the analyzers according to the file,
+// except for those in set, which were set on the command line.
+func (cfg *ConfigFile) apply(analyzers []*analysis.Analyzer, set map[string]bool) error {
+	byName := make(map[string]*analysis.Analyzer)
+	for a := range expand(analyzers) {
+		byName[a.Name] = a
+	}
+	for _, name := range sortedKeys(cfg.Analyzers) {
+		a := byName[name]
+		if a == nil {
+			return fmt.Errorf("%s: unknown analyzer %q", cfg.Filename, name)
+		}
+		settings := cfg.Analyzers[name]
+		for _, key := range sortedKeys(settings) {
+			if set[name+"."+key] {
+				continue // the command line takes precedence
+			}
+			if a.Flags.Lookup(key) == nil {
+				return fmt.Errorf("%s: analyzer %s has no flag %q", cfg.Filename, name, key)
+			}
+			if err := a.Flags.Set(key, settings[key]); err != nil {
+				return fmt.Errorf("%s: invalid value %q for -%s.%s: %v", cfg.Filename, settings[key], name, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// filter returns the analyzers selected by the enable and disable
+// lists of the file.
+func (cfg *ConfigFile) filter(analyzers []*analysis.Analyzer) ([]*analysis.Analyzer, error) {
+	known := make(map[string]bool)
+	for _, a := range analyzers {
+		known[a.Name] = true
+	}
+	set := func(names []string) (map[string]bool, error) {
+		m := make(map[string]bool)
+		for _, name := range names {
+			if !known[name] {
+				return nil, fmt.Errorf("%s: unknown analyzer %q", cfg.Filename, name)
+			}
+			m[name] = true
+		}
+		return m, nil
+	}
+	enable, err := set(cfg.Enable)
+	if err != nil {
+		return nil, err
+	}
+	disable, err := set(cfg.Disable)
+	if err != nil {
+		return nil, err
+	}
+	var keep []*analysis.Analyzer
+	for _, a := range analyzers {
+		if (len(enable) == 0 || enable[a.Name]) && !disable[a.Name] {
+			keep = append(keep, a)
+		}
+	}
+	return keep, nil
+}
+
+// ExcludePaths returns the exclude patterns of the file as absolute
+// glob patterns, in the syntax of filepath.Match and the -exclude-path
+// flag of the checker. It returns nil if cfg is nil.
+func (cfg *ConfigFile) ExcludePaths() []string {
+	if cfg == nil {
+		return nil
+	}
+	dir := escapeGlob(filepath.Dir(cfg.Filename))
+	var patterns []string
+	for _, pattern := range cfg.Exclude {
+		patterns = append(patterns, filepath.Join(dir, filepath.FromSlash(pattern)))
+	}
+	return patterns
+}
+
+// escapeGlob quotes the special characters of filepath.Match in name.
+// On Windows, where a backslash is the separator and not an escape,
+// each is quoted as a character class instead.
+func escapeGlob(name string) string {
+	var buf strings.Builder
+	for _, r := range name {
+		switch {
+		case runtime.GOOS == "windows" && strings.ContainsRune("*?[", r):
+			buf.WriteString("[" + string(r) + "]")
+		case runtime.GOOS != "windows" && strings.ContainsRune("*?[\\", r):
+			buf.WriteString("\\" + string(r))
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+// Warning reports whether the diagnostics of the named analyzer have
+// "warning" severity, which by default does not affect the exit status.
+// It is false if cfg is nil.
+func (cfg *ConfigFile) Warning(analyzer string) bool {
+	return cfg != nil && cfg.Severity[analyzer] == "warning"
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]interface{}:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ---- TOML subset ----
+
+// parseTOML parses the subset of TOML described at the top of this
+// file into a tree of maps. Errors are prefixed by a line number.
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	table := root
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		p := &tomlParser{text: text}
+		start := line
+		switch {
+		case text == "" || text[0] == '#':
+			continue
+
+		case text[0] == '[':
+			p.pos++
+			path, err := p.keyPath(']')
+			if err != nil {
+				return nil, fmt.Errorf("%d: %v", line, err)
+			}
+			if err := p.end(); err != nil {
+				return nil, fmt.Errorf("%d: %v", line, err)
+			}
+			table = root
+			for _, key := range path {
+				sub, ok := table[key]
+				if !ok {
+					sub = make(map[string]interface{})
+					table[key] = sub
+				}
+				if table, ok = sub.(map[string]interface{}); !ok {
+					return nil, fmt.Errorf("%d: %s is not a table", line, strings.Join(path, "."))
+				}
+			}
+
+		default:
+			path, err := p.keyPath('=')
+			if err != nil {
+				return nil, fmt.Errorf("%d: %v", line, err)
+			}
+			// An array may continue on subsequent lines.
+			for p.skipSpace(); strings.HasPrefix(p.text[p.pos:], "[") && !p.balanced() && sc.Scan(); line++ {
+				p.text += "\n" + sc.Text()
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, fmt.Errorf("%d: %v", start, err)
+			}
+			if err := p.end(); err != nil {
+				return nil, fmt.Errorf("%d: %v", start, err)
+			}
+			t := table
+			for _, key := range path[:len(path)-1] {
+				sub, ok := t[key]
+				if !ok {
+					sub = make(map[string]interface{})
+					t[key] = sub
+				}
+				if t, ok = sub.(map[string]interface{}); !ok {
+					return nil, fmt.Errorf("%d: %s is not a table", start, key)
+				}
+			}
+			key := path[len(path)-1]
+			if _, ok := t[key]; ok {
+				return nil, fmt.Errorf("%d: duplicate key %s", start, key)
+			}
+			t[key] = value
+		}
+	}
+	return root, sc.Err()
+}
+
+type tomlParser struct {
+	text string
+	pos  int
+}
+
+func (p *tomlParser) skipSpace() {
+	for p.pos < len(p.text) {
+		switch p.text[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		case '#':
+			for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// end checks that only space and comments remain.
+func (p *tomlParser) end() error {
+	p.skipSpace()
+	if p.pos < len(p.text) {
+		return fmt.Errorf("unexpected %q", p.text[p.pos:])
+	}
+	return nil
+}
+
+// balanced reports whether the brackets outside strings in the rest of
+// the text are balanced.
+func (p *tomlParser) balanced() bool {
+	depth := 0
+	var quote byte
+	for i := p.pos; i < len(p.text); i++ {
+		c := p.text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			for i < len(p.text) && p.text[i] != '\n' {
+				i++
+			}
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// keyPath parses a dotted key followed by the terminator.
+func (p *tomlParser) keyPath(term byte) ([]string, error) {
+	var path []string
+	for {
+		p.skipSpace()
+		var key string
+		if p.pos < len(p.text) && (p.text[p.pos] == '"' || p.text[p.pos] == '\'') {
+			s, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		} else {
+			start := p.pos
+			for p.pos < len(p.text) && isBareKeyChar(p.text[p.pos]) {
+				p.pos++
+			}
+			key = p.text[start:p.pos]
+			if key == "" {
+				return nil, fmt.Errorf("missing key")
+			}
+		}
+		path = append(path, key)
+		p.skipSpace()
+		if p.pos == len(p.text) {
+			return nil, fmt.Errorf("missing %q", term)
+		}
+		switch p.text[p.pos] {
+		case '.':
+			p.pos++
+		case term:
+			p.pos++
+			return path, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q in key", p.text[p.pos])
+		}
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-'
+}
+
+// value parses a string, boolean, integer, or array.
+func (p *tomlParser) value() (interface{}, error) {
+	p.skipSpace()
+	if p.pos == len(p.text) {
+		return nil, fmt.Errorf("missing value")
+	}
+	switch c := p.text[p.pos]; {
+	case c == '"' || c == '\'':
+		return p.str()
+
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		for {
+			p.skipSpace()
+			if p.pos < len(p.text) && p.text[p.pos] == ']' {
+				p.pos++
+				return list, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			p.skipSpace()
+			if p.pos == len(p.text) {
+				return nil, fmt.Errorf("unterminated array")
+			}
+			switch p.text[p.pos] {
+			case ',':
+				p.pos++
+			case ']':
+			default:
+				return nil, fmt.Errorf("unexpected %q in array", p.text[p.pos])
+			}
+		}
+
+	default:
+		start := p.pos
+		for p.pos < len(p.text) && isBareKeyChar(p.text[p.pos]) || p.pos < len(p.text) && p.text[p.pos] == '+' {
+			p.pos++
+		}
+		word := p.text[start:p.pos]
+		switch word {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		if i, err := strconv.ParseInt(strings.Replace(word, "_", "", -1), 10, 64); err == nil {
+			return i, nil
+		}
+		return nil, fmt.Errorf("invalid value %q", word)
+	}
+}
+
+// str parses a basic "string", with escapes, or a literal 'string'.
+func (p *tomlParser) str() (string, error) {
+	quote := p.text[p.pos]
+	for i := p.pos + 1; i < len(p.text); i++ {
+		switch p.text[i] {
+		case '\n':
+			return "", fmt.Errorf("unterminated string")
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			lit := p.text[p.pos : i+1]
+			p.pos = i + 1
+			if quote == '\'' {
+				return lit[1 : len(lit)-1], nil
+			}
+			s, err := strconv.Unquote(lit)
+			if err != nil {
+				return "", fmt.Errorf("invalid string %s", lit)
+			}
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
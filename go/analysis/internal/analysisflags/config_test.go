@@ -0,0 +1,132 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const testConfig = `
+# A comment.
+enable = [
+	"a1",
+	"a2", # trailing comment
+]
+disable = ["a2"]
+exclude = ["vendor", "gen/*.go"]
+
+[severity]
+a1 = "warning"
+
+[analyzers.a1]
+name = "println"
+strict = true
+funcs = ["Logf", "Warnf"]
+`
+
+func TestConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, ConfigFileName), []byte(testConfig), 0666); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0777); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := FindConfigFile(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg == nil {
+		t.Fatalf("FindConfigFile(%s) found no file", sub)
+	}
+
+	if want := []string{"a1", "a2"}; !reflect.DeepEqual(cfg.Enable, want) {
+		t.Errorf("Enable = %q, want %q", cfg.Enable, want)
+	}
+	if want := map[string]string{"name": "println", "strict": "true", "funcs": "Logf,Warnf"}; !reflect.DeepEqual(cfg.Analyzers["a1"], want) {
+		t.Errorf("Analyzers[a1] = %q, want %q", cfg.Analyzers["a1"], want)
+	}
+	if !cfg.Warning("a1") || cfg.Warning("a2") {
+		t.Errorf("Warning(a1), Warning(a2) = %t, %t, want true, false", cfg.Warning("a1"), cfg.Warning("a2"))
+	}
+
+	// The patterns are relative to the directory of the file.
+	if want := []string{filepath.Join(dir, "vendor"), filepath.Join(dir, "gen", "*.go")}; !reflect.DeepEqual(cfg.ExcludePaths(), want) {
+		t.Errorf("ExcludePaths() = %q, want %q", cfg.ExcludePaths(), want)
+	}
+	if patterns := (*ConfigFile)(nil).ExcludePaths(); patterns != nil {
+		t.Errorf("ExcludePaths with no configuration file = %q", patterns)
+	}
+	special := &ConfigFile{Filename: filepath.Join(dir, "x[1]*", ConfigFileName), Exclude: []string{"*.go"}}
+	if ok, err := filepath.Match(special.ExcludePaths()[0], filepath.Join(dir, "x[1]*", "a.go")); !ok || err != nil {
+		t.Errorf("ExcludePaths() = %q does not match a file of its directory (%v)", special.ExcludePaths(), err)
+	}
+
+	// Analyzer flags set on the command line take precedence.
+	a1 := &analysis.Analyzer{Name: "a1", Doc: "a1"}
+	name := a1.Flags.String("name", "", "")
+	strict := a1.Flags.Bool("strict", false, "")
+	funcs := a1.Flags.String("funcs", "", "")
+	a2 := &analysis.Analyzer{Name: "a2", Doc: "a2"}
+	a3 := &analysis.Analyzer{Name: "a3", Doc: "a3"}
+	analyzers := []*analysis.Analyzer{a1, a2, a3}
+	if err := cfg.apply(analyzers, map[string]bool{"a1.name": true}); err != nil {
+		t.Fatal(err)
+	}
+	if *name != "" || !*strict || *funcs != "Logf,Warnf" {
+		t.Errorf("after apply, -a1.name=%q -a1.strict=%t -a1.funcs=%q, want \"\", true and \"Logf,Warnf\"", *name, *strict, *funcs)
+	}
+
+	keep, err := cfg.filter(analyzers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keep) != 1 || keep[0] != a1 {
+		t.Errorf("filter returned %v, want [a1]", keep)
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	for _, test := range []struct {
+		config, want string
+	}{
+		{`enable = "a1"`, "enable must be an array of strings"},
+		{`bogus = 1`, `unknown key "bogus"`},
+		{"[severity]\na1 = \"fatal\"", `severity of a1 must be "error" or "warning"`},
+		{`exclude = ["[x"]`, "invalid exclude pattern"},
+		{"[analyzers.a1]\nfuncs = [1]", "analyzers.a1.funcs must be an array of strings"},
+		{`enable = ["a1"`, ""},
+		{`name = "unterminated`, ""},
+	} {
+		tree, err := parseTOML([]byte(test.config))
+		if err == nil {
+			_, err = decodeConfig(tree)
+		}
+		if err == nil {
+			t.Errorf("%q: no error", test.config)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: got error %q, want %q", test.config, err, test.want)
+		}
+	}
+
+	// Unknown analyzers and flags are reported when the file is applied.
+	cfg := &ConfigFile{Filename: ConfigFileName, Analyzers: map[string]map[string]string{"a1": {"nosuchflag": "1"}}}
+	a1 := &analysis.Analyzer{Name: "a1", Doc: "a1"}
+	if err := cfg.apply([]*analysis.Analyzer{a1}, nil); err == nil || !strings.Contains(err.Error(), `no flag "nosuchflag"`) {
+		t.Errorf("apply: got error %v, want one about nosuchflag", err)
+	}
+	cfg = &ConfigFile{Filename: ConfigFileName, Enable: []string{"a2"}}
+	if _, err := cfg.filter([]*analysis.Analyzer{a1}); err == nil || !strings.Contains(err.Error(), `unknown analyzer "a2"`) {
+		t.Errorf("filter: got error %v, want one about a2", err)
+	}
+}
@@ -30,7 +30,8 @@ var (
 )
 
 // Parse creates a flag for each of the analyzer's flags,
-// including (in multi mode) a flag named after the analyzer,
+// including (in multi mode) a flag named after the analyzer
+// and the -enable and -disable flags,
 // parses the flags, then filters and returns the list of
 // analyzers enabled by flags.
 //
@@ -42,8 +43,25 @@ var (
 // only reachable from dropped analyzers.
 // This is not a particularly elegant API, but this is an internal package.
 func Parse(analyzers []*analysis.Analyzer, multi bool) []*analysis.Analyzer {
+	return parse(analyzers, multi, false)
+}
+
+// ParseWithConfigFile is like Parse in multi mode, but additionally
+// applies the configuration file named by the -config flag or, by
+// default, found in the working directory or one of its parents, and
+// sets Config. Its settings yield to those of the command line.
+func ParseWithConfigFile(analyzers []*analysis.Analyzer) []*analysis.Analyzer {
+	return parse(analyzers, true, true)
+}
+
+func parse(analyzers []*analysis.Analyzer, multi, configFile bool) []*analysis.Analyzer {
 	// Connect each analysis flag to the command line as -analysis.flag.
 	enabled := make(map[*analysis.Analyzer]*triState)
+	var sel selection
+	if multi {
+		flag.Var(selectFlag{&sel, true}, "enable", "enable the analyzers matching a comma-separated `list` of names, globs, \"all\", or \"default\"")
+		flag.Var(selectFlag{&sel, false}, "disable", "disable the analyzers matching a comma-separated `list` of names, globs, \"all\", or \"default\"")
+	}
 	for _, a := range analyzers {
 		var prefix string
 
@@ -68,13 +86,19 @@ func Parse(analyzers []*analysis.Analyzer, multi bool) []*analysis.Analyzer {
 		})
 	}
 
-	// standard flags: -flags, -V.
+	// standard flags: -flags, -V, -list-analyzers.
 	printflags := flag.Bool("flags", false, "print analyzer flags in JSON")
 	addVersionFlag()
+	var list listFlag
+	flag.Var(&list, "list-analyzers", "list the analyzers and whether they are enabled, in the `format` text or json (-list-analyzers=json)")
 
 	// flags common to all checkers
 	flag.BoolVar(&JSON, "json", JSON, "emit JSON output")
 	flag.IntVar(&Context, "c", Context, `display offending line with this many lines of context`)
+	var configFlag *string
+	if configFile {
+		configFlag = flag.String("config", "", "read configuration from `file` (default: nearest "+ConfigFileName+")")
+	}
 
 	// Add shims for legacy vet flags to enable existing
 	// scripts that run vet to continue to work.
@@ -97,6 +121,26 @@ func Parse(analyzers []*analysis.Analyzer, multi bool) []*analysis.Analyzer {
 		os.Exit(0)
 	}
 
+	if configFile {
+		var err error
+		if *configFlag != "" {
+			Config, err = ReadConfigFile(*configFlag)
+		} else {
+			Config, err = FindConfigFile(".")
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if Config != nil {
+			set := make(map[string]bool) // flags set on the command line
+			flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+			if err := Config.apply(analyzers, set); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	all := analyzers
 	everything := expand(analyzers)
 
 	// If any -NAME flag is true,  run only those analyzers. Otherwise,
@@ -127,9 +171,32 @@ func Parse(analyzers []*analysis.Analyzer, multi bool) []*analysis.Analyzer {
 				}
 			}
 			analyzers = keep
+		} else if Config != nil {
+			var err error
+			analyzers, err = Config.filter(analyzers)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		// Apply -enable and -disable to the set selected so far.
+		if len(sel) > 0 {
+			var err error
+			analyzers, err = sel.apply(all, analyzers)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
 	}
 
+	// -list-analyzers: describe the analyzers instead of running them.
+	if list != "" {
+		if err := listAnalyzers(os.Stdout, string(list), all, analyzers, multi); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
 	// Register fact types of skipped analyzers
 	// in case we encounter them in imported files.
 	kept := expand(analyzers)
@@ -171,7 +238,12 @@ func printFlags() {
 		// flags or fix as these have no effect on unitchecker
 		// (as invoked by 'go vet').
 		switch f.Name {
-		case "debug", "cpuprofile", "memprofile", "trace", "fix":
+		case "debug", "cpuprofile", "memprofile", "trace", "fix", "fix-dry-run", "fix-backup", "fix-patch",
+			"report-unused-nolint", "watch", "severity", "fail-on",
+			"assume-filename", "diff-base", "diff-lines",
+			"load-parallelism", "dep-func-bodies", "memory-limit", "timings",
+			"exclude-path", "skip-generated", "list-analyzers", "exit-zero", "max-issues",
+			"build-config", "baseline", "write-baseline", "sarif":
 			return
 		}
 
@@ -339,7 +411,8 @@ func PrintPlain(fset *token.FileSet, diag analysis.Diagnostic) {
 }
 
 // A JSONTree is a mapping from package ID to analysis name to result.
-// Each result is either a jsonError or a list of jsonDiagnostic.
+// Each result is either a jsonError, a list of jsonDiagnostic, or, if
+// the analysis lacked the facts of some dependencies, a jsonIncomplete.
 type JSONTree map[string]map[string]interface{}
 
 // Add adds the result of analysis 'name' on package 'id'.
@@ -379,6 +452,26 @@ func (tree JSONTree) Add(fset *token.FileSet, id, name string, diags []analysis.
 	}
 }
 
+// AddMissingFacts marks the result of analysis 'name' on package 'id'
+// as possibly incomplete for want of the facts of the packages in
+// missing. It must be called after Add, for an analysis that did
+// not fail.
+func (tree JSONTree) AddMissingFacts(id, name string, missing []string) {
+	if len(missing) == 0 {
+		return
+	}
+	type jsonIncomplete struct {
+		Diagnostics  interface{} `json:"diagnostics,omitempty"`
+		MissingFacts []string    `json:"missing_facts"`
+	}
+	m, ok := tree[id]
+	if !ok {
+		m = make(map[string]interface{})
+		tree[id] = m
+	}
+	m[name] = jsonIncomplete{m[name], missing}
+}
+
 func (tree JSONTree) Print() {
 	data, err := json.MarshalIndent(tree, "", "\t")
 	if err != nil {
@@ -386,3 +479,46 @@ func (tree JSONTree) Print() {
 	}
 	fmt.Printf("%s\n", data)
 }
+
+// PrintJSONLine prints the diagnostic, or the error if non-nil, of
+// analysis 'name' on package 'id' as a JSON object on a single line of
+// standard output, so that a sequence of them forms a stream of
+// newline-delimited JSON.
+func PrintJSONLine(fset *token.FileSet, id, name string, diag analysis.Diagnostic, err error) {
+	line := jsonLine{ID: id, Analyzer: name}
+	if err != nil {
+		line.Err = err.Error()
+	} else {
+		line.Category = diag.Category
+		line.Posn = fset.Position(diag.Pos).String()
+		line.Message = diag.Message
+	}
+	line.print()
+}
+
+// PrintJSONMissingFacts prints, in the form of PrintJSONLine, that the
+// results of analysis 'name' on package 'id' may be incomplete for
+// want of the facts of the packages in missing.
+func PrintJSONMissingFacts(id, name string, missing []string) {
+	if len(missing) > 0 {
+		jsonLine{ID: id, Analyzer: name, MissingFacts: missing}.print()
+	}
+}
+
+type jsonLine struct {
+	ID           string   `json:"id"`
+	Analyzer     string   `json:"analyzer"`
+	Err          string   `json:"error,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	Posn         string   `json:"posn,omitempty"`
+	Message      string   `json:"message,omitempty"`
+	MissingFacts []string `json:"missing_facts,omitempty"`
+}
+
+func (line jsonLine) print() {
+	data, err := json.Marshal(line)
+	if err != nil {
+		log.Panicf("internal error: JSON marshaling failed: %v", err)
+	}
+	os.Stdout.Write(append(data, '\n'))
+}
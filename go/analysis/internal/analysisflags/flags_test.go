@@ -5,7 +5,9 @@
 package analysisflags_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"go/token"
 	"os"
 	"os/exec"
 	"runtime"
@@ -50,6 +52,14 @@ func TestExec(t *testing.T) {
 		{"-a1", "[a1]"},
 		{"-a1=1 -a3=1", "[a1 a3]"},
 		{"-a1=1 -a3=0", "[a1]"},
+		{"-disable=a2", "[a1 a3]"},
+		{"-disable=all -enable=a2,a3", "[a2 a3]"},
+		{"-disable=a* -enable=a3", "[a3]"},
+		{"-enable=a3 -disable=a*", "[]"},
+		{"-a1=1 -enable=a2", "[a1 a2]"},
+		{"-a1=1 -disable=all -enable=default", "[a1]"},
+		{"-a1=1 -enable=all -disable=default", "[a2 a3]"},
+		{"-list-analyzers -a2", "a1  disabled  a1\na2  enabled   a2\na3  disabled  a3"},
 	} {
 		cmd := exec.Command(progname, "-test.run=TestExec")
 		cmd.Env = append(os.Environ(), "ANALYSISFLAGS_CHILD=1", "FLAGS="+test.flags)
@@ -65,3 +75,26 @@ func TestExec(t *testing.T) {
 		}
 	}
 }
+
+func TestJSONTreeMissingFacts(t *testing.T) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("a.go", -1, 10)
+	tree := make(analysisflags.JSONTree)
+	tree.Add(fset, "a", "complete", []analysis.Diagnostic{{Pos: file.Pos(1), Message: "m"}}, nil)
+	tree.Add(fset, "a", "partial", []analysis.Diagnostic{{Pos: file.Pos(1), Message: "m"}}, nil)
+	tree.AddMissingFacts("a", "partial", []string{"b"})
+	tree.AddMissingFacts("a", "empty", []string{"b", "c"})
+	tree.AddMissingFacts("a", "complete", nil)
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":{` +
+		`"complete":[{"posn":"a.go:1:2","message":"m"}],` +
+		`"empty":{"missing_facts":["b","c"]},` +
+		`"partial":{"diagnostics":[{"posn":"a.go:1:2","message":"m"}],"missing_facts":["b"]}}}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
@@ -42,6 +42,8 @@ func Help(progname string, analyzers []*analysis.Analyzer, args []string) {
 		fmt.Println("\nBy default all analyzers are run.")
 		fmt.Println("To select specific analyzers, use the -NAME flag for each one,")
 		fmt.Println(" or -NAME=false to run all analyzers not explicitly disabled.")
+		fmt.Println("The -enable and -disable flags, applied in order, adjust that set")
+		fmt.Println(" by comma-separated names, globs, \"all\", or \"default\".")
 
 		// Show only the core command-line flags.
 		fmt.Println("\nCore flags:")
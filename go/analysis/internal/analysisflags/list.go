@@ -0,0 +1,106 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// listFlag is the flag.Value of -list-analyzers, whose value is
+// the format of the listing: "text" (the default) or "json".
+type listFlag string
+
+func (f *listFlag) IsBoolFlag() bool { return true }
+func (f *listFlag) String() string   { return string(*f) }
+
+func (f *listFlag) Set(s string) error {
+	switch s {
+	case "true", "text":
+		*f = "text"
+	case "false":
+		*f = ""
+	case "json":
+		*f = "json"
+	default:
+		return fmt.Errorf("invalid format %q (want text or json)", s)
+	}
+	return nil
+}
+
+// A jsonAnalyzer describes an analyzer in the output of -list-analyzers=json.
+type jsonAnalyzer struct {
+	Name      string
+	Doc       string
+	Flags     []jsonAnalyzerFlag `json:",omitempty"`
+	Requires  []string           `json:",omitempty"` // names of the required analyzers
+	FactTypes []string           `json:",omitempty"` // Go types of the facts
+	Enabled   bool               // whether the analyzer is run, given the other flags
+}
+
+// A jsonAnalyzerFlag describes a flag of an analyzer.
+type jsonAnalyzerFlag struct {
+	Name    string // as on the command line, such as "printf.funcs"
+	Usage   string
+	Default string
+	Bool    bool
+}
+
+// listAnalyzers prints a description of each of the analyzers, in the
+// specified format, and whether it is among the enabled analyzers.
+// The names of the flags are those of a checker in multi mode.
+func listAnalyzers(w io.Writer, format string, analyzers, enabled []*analysis.Analyzer, multi bool) error {
+	isEnabled := make(map[*analysis.Analyzer]bool)
+	for _, a := range enabled {
+		isEnabled[a] = true
+	}
+	var list []jsonAnalyzer
+	for _, a := range analyzers {
+		ja := jsonAnalyzer{Name: a.Name, Doc: a.Doc, Enabled: isEnabled[a]}
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			name := f.Name
+			if multi {
+				name = a.Name + "." + name
+			}
+			b, ok := f.Value.(interface{ IsBoolFlag() bool })
+			ja.Flags = append(ja.Flags, jsonAnalyzerFlag{name, f.Usage, f.DefValue, ok && b.IsBoolFlag()})
+		})
+		for _, req := range a.Requires {
+			ja.Requires = append(ja.Requires, req.Name)
+		}
+		for _, f := range a.FactTypes {
+			ja.FactTypes = append(ja.FactTypes, fmt.Sprintf("%T", f))
+		}
+		list = append(list, ja)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	if format == "json" {
+		data, err := json.MarshalIndent(list, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, ja := range list {
+		status := "disabled"
+		if ja.Enabled {
+			status = "enabled"
+		}
+		title := strings.Split(ja.Doc, "\n\n")[0]
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", ja.Name, status, strings.Replace(title, "\n", " ", -1))
+	}
+	return tw.Flush()
+}
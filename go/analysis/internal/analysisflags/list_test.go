@@ -0,0 +1,63 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+type listFact struct{}
+
+func (*listFact) AFact() {}
+
+func TestListAnalyzers(t *testing.T) {
+	dep := &analysis.Analyzer{Name: "dep", Doc: "a dependency"}
+	a := &analysis.Analyzer{
+		Name:      "a",
+		Doc:       "report a\n\nMore about a.",
+		Requires:  []*analysis.Analyzer{dep},
+		FactTypes: []analysis.Fact{new(listFact)},
+	}
+	a.Flags.Bool("strict", false, "be strict")
+	b := &analysis.Analyzer{Name: "b", Doc: "report b"}
+	analyzers := []*analysis.Analyzer{b, a}
+
+	var buf bytes.Buffer
+	if err := listAnalyzers(&buf, "text", analyzers, []*analysis.Analyzer{a}, true); err != nil {
+		t.Fatal(err)
+	}
+	const wantText = "a  enabled   report a\nb  disabled  report b\n"
+	if got := buf.String(); got != wantText {
+		t.Errorf("text listing:\n%s\nwant:\n%s", got, wantText)
+	}
+
+	buf.Reset()
+	if err := listAnalyzers(&buf, "json", analyzers, []*analysis.Analyzer{a}, true); err != nil {
+		t.Fatal(err)
+	}
+	var got []jsonAnalyzer
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.Bytes())
+	}
+	want := []jsonAnalyzer{
+		{
+			Name:      "a",
+			Doc:       a.Doc,
+			Flags:     []jsonAnalyzerFlag{{Name: "a.strict", Usage: "be strict", Default: "false", Bool: true}},
+			Requires:  []string{"dep"},
+			FactTypes: []string{"*analysisflags.listFact"},
+			Enabled:   true,
+		},
+		{Name: "b", Doc: "report b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON listing:\n%+v\nwant:\n%+v", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// A selection is the sequence of -enable and -disable flags, in
+// command-line order. Each flag's value is a comma-separated list of
+// analyzer names, glob patterns such as "SA*", and the meta-sets "all",
+// of all analyzers, and "default", of those selected by the -NAME flags
+// or the configuration file (by default, all of them).
+type selection []selectOp
+
+type selectOp struct {
+	enable   bool
+	patterns []string
+}
+
+// selectFlag is the flag.Value of -enable or -disable.
+type selectFlag struct {
+	sel    *selection
+	enable bool
+}
+
+func (f selectFlag) String() string { return "" }
+
+func (f selectFlag) Set(s string) error {
+	var patterns []string
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	*f.sel = append(*f.sel, selectOp{f.enable, patterns})
+	return nil
+}
+
+// apply returns the analyzers selected by starting from the default
+// analyzers and applying each -enable or -disable flag in turn, so
+// that a later flag overrides an earlier one: "-disable all -enable
+// printf" selects only printf. A pattern other than "all" and
+// "default" that matches no analyzer is an error, as it is likely to be
+// a mistake.
+func (sel selection) apply(analyzers, defaults []*analysis.Analyzer) ([]*analysis.Analyzer, error) {
+	isDefault := make(map[*analysis.Analyzer]bool)
+	on := make(map[*analysis.Analyzer]bool)
+	for _, a := range defaults {
+		isDefault[a] = true
+		on[a] = true
+	}
+	for _, op := range sel {
+		for _, pattern := range op.patterns {
+			matched := false
+			for _, a := range analyzers {
+				var ok bool
+				switch pattern {
+				case "all":
+					ok = true
+				case "default":
+					ok = isDefault[a]
+				default:
+					ok, _ = path.Match(pattern, a.Name)
+				}
+				if ok {
+					on[a] = op.enable
+					matched = true
+				}
+			}
+			if !matched && pattern != "all" && pattern != "default" {
+				name := "disable"
+				if op.enable {
+					name = "enable"
+				}
+				return nil, fmt.Errorf("-%s: no analyzer matches %q", name, pattern)
+			}
+		}
+	}
+	var keep []*analysis.Analyzer
+	for _, a := range analyzers {
+		if on[a] {
+			keep = append(keep, a)
+		}
+	}
+	return keep, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file defines the baseline of known diagnostics, which lets a
+// project adopt an analyzer without first fixing all that it reports:
+// only the diagnostics that are not in the baseline are reported.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// Baseline is the name of a file of known diagnostics, which
+	// are not reported, if not empty.
+	Baseline string
+
+	// WriteBaseline determines whether to write the diagnostics to
+	// the Baseline file, replacing its contents, instead of
+	// reporting them.
+	WriteBaseline bool
+)
+
+// A baselineFile is the contents of a baseline file, in JSON.
+type baselineFile struct {
+	Diagnostics []*baselineEntry
+}
+
+// A baselineEntry records the diagnostics of an analyzer with the same
+// message on lines with the same text in a file, by its slash-separated
+// name relative to the directory of the baseline file. Line numbers are
+// not recorded, so that the entry survives edits elsewhere in the file.
+type baselineEntry struct {
+	File     string
+	Analyzer string
+	Message  string
+	Source   string // the text of the line, without surrounding space
+	Count    int
+}
+
+type baselineKey struct {
+	file, analyzer, message, source string
+}
+
+// applyBaseline removes from the root actions the diagnostics recorded
+// in the Baseline file or, if WriteBaseline, records them all there.
+func applyBaseline(roots []*action) error {
+	if Baseline == "" {
+		return nil
+	}
+	dir, err := filepath.Abs(filepath.Dir(Baseline))
+	if err != nil {
+		return err
+	}
+
+	known := make(map[baselineKey]int)
+	if !WriteBaseline {
+		data, err := ioutil.ReadFile(Baseline)
+		if err != nil {
+			return err
+		}
+		var f baselineFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("reading baseline %s: %v", Baseline, err)
+		}
+		for _, e := range f.Diagnostics {
+			known[baselineKey{e.File, e.Analyzer, e.Message, e.Source}] += e.Count
+		}
+	}
+
+	// A diagnostic in a file of several packages, such as p and
+	// p [p.test], is counted once, and kept or removed in each.
+	type posKey struct {
+		pos, end token.Position
+		analyzer string
+		message  string
+	}
+	decided := make(map[posKey]bool) // whether the diagnostic is kept
+	lines := make(map[string][][]byte)
+	counts := make(map[baselineKey]int)
+	var keys []baselineKey // in order of appearance
+	for _, root := range roots {
+		for _, act := range root.deps {
+			if !act.isroot {
+				continue
+			}
+			diags := act.diagnostics[:0:0]
+			for _, diag := range act.diagnostics {
+				posn := act.pkg.Fset.Position(diag.Pos)
+				pk := posKey{posn, act.pkg.Fset.Position(diag.End), act.a.Name, diag.Message}
+				keep, ok := decided[pk]
+				if !ok {
+					k := baselineKey{baselineName(dir, posn.Filename), act.a.Name, diag.Message, sourceLine(lines, posn)}
+					if WriteBaseline {
+						if counts[k] == 0 {
+							keys = append(keys, k)
+						}
+						counts[k]++
+					} else if known[k] > 0 {
+						known[k]--
+					} else {
+						keep = true
+					}
+					decided[pk] = keep
+				}
+				if keep {
+					diags = append(diags, diag)
+				}
+			}
+			act.diagnostics = diags
+		}
+	}
+
+	if !WriteBaseline {
+		return nil
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		x, y := keys[i], keys[j]
+		if x.file != y.file {
+			return x.file < y.file
+		}
+		if x.analyzer != y.analyzer {
+			return x.analyzer < y.analyzer
+		}
+		return x.message < y.message
+	})
+	f := baselineFile{Diagnostics: []*baselineEntry{}}
+	n := 0
+	for _, k := range keys {
+		f.Diagnostics = append(f.Diagnostics, &baselineEntry{k.file, k.analyzer, k.message, k.source, counts[k]})
+		n += counts[k]
+	}
+	data, err := json.MarshalIndent(&f, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(Baseline, append(data, '\n'), 0666); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "recorded %d diagnostics in %s\n", n, Baseline)
+	return nil
+}
+
+// baselineName returns the name of the file in a baseline in dir.
+func baselineName(dir, filename string) string {
+	if rel, err := filepath.Rel(dir, filename); err == nil && !strings.HasPrefix(rel, "..") {
+		filename = rel
+	}
+	return filepath.ToSlash(filename)
+}
+
+// sourceLine returns the text of the line of posn, without surrounding
+// space, or "" if it cannot be read. The lines of each file are read
+// once, and memoized in lines.
+func sourceLine(lines map[string][][]byte, posn token.Position) string {
+	if !posn.IsValid() {
+		return ""
+	}
+	l, ok := lines[posn.Filename]
+	if !ok {
+		if data, err := ioutil.ReadFile(posn.Filename); err == nil {
+			l = bytes.Split(data, []byte("\n"))
+		}
+		lines[posn.Filename] = l
+	}
+	if posn.Line < 1 || posn.Line > len(l) {
+		return ""
+	}
+	return string(bytes.TrimSpace(l[posn.Line-1]))
+}
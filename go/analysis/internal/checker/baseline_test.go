@@ -0,0 +1,72 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestBaseline(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"p/a.go": "package p\n\nfunc f() {\n\tvar bar int\n\t_ = bar\n}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	path := filepath.Join(dir, "src", "p", "a.go")
+	baseline := filepath.Join(dir, "baseline.json")
+
+	defer func(fix bool, baseline string, write bool) {
+		checker.Fix, checker.Baseline, checker.WriteBaseline = fix, baseline, write
+	}(checker.Fix, checker.Baseline, checker.WriteBaseline)
+	checker.Fix = false
+	checker.Baseline = baseline
+
+	// Record the diagnostics.
+	checker.WriteBaseline = true
+	var code int
+	stderr := captureStderr(t, func() {
+		code = checker.Run([]string{"file=" + path}, []*analysis.Analyzer{analyzer})
+	})
+	if code != 0 || strings.Contains(stderr, "renaming") {
+		t.Fatalf("-write-baseline: exit code %d, output:\n%s", code, stderr)
+	}
+	data, err := ioutil.ReadFile(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"File": "src/p/a.go"`, `"Source": "var bar int"`, `"Source": "_ = bar"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("baseline does not contain %q:\n%s", want, data)
+		}
+	}
+
+	// Add a diagnostic, and move the recorded ones.
+	checker.WriteBaseline = false
+	src := "package p\n\nfunc g() { var bar int; _ = bar }\n\nfunc f() {\n\tvar bar int\n\t_ = bar\n}\n"
+	if err := ioutil.WriteFile(path, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	stderr = captureStderr(t, func() {
+		code = checker.Run([]string{"file=" + path}, []*analysis.Analyzer{analyzer})
+	})
+	if code != 3 {
+		t.Errorf("-baseline: exit code %d, want 3", code)
+	}
+	if got := strings.Count(stderr, "renaming"); got != 2 || !strings.Contains(stderr, "a.go:3:16: renaming") || !strings.Contains(stderr, "a.go:3:29: renaming") {
+		t.Errorf("-baseline: got %d diagnostics, want the 2 of g:\n%s", got, stderr)
+	}
+}
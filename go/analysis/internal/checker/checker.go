@@ -14,8 +14,6 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"go/format"
-	"go/parser"
 	"go/token"
 	"go/types"
 	"io/ioutil"
@@ -34,6 +32,8 @@ import (
 	"golang.org/x/tools/go/analysis/internal/analysisflags"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/internal/analysisinternal"
+	"golang.org/x/tools/internal/edits"
+	"golang.org/x/tools/internal/packagesinternal"
 	"golang.org/x/tools/internal/span"
 )
 
@@ -43,7 +43,8 @@ var (
 	//	f	show [f]acts as they are created
 	// 	p	disable [p]arallel execution of analyzers
 	//	s	do additional [s]anity checks on fact types and serialization
-	//	t	show [t]iming info (NB: use 'p' flag to avoid GC/scheduler noise)
+	//	t	show [t]iming info, as with -timings, and that of the slowest
+	//		actions (NB: use 'p' flag to avoid GC/scheduler noise)
 	//	v	show [v]erbose logging
 	//  d build a [d]ot visualization in $PWD/dep.gv
 	//
@@ -57,6 +58,27 @@ var (
 
 	// Fix determines whether to apply all suggested fixes.
 	Fix bool
+
+	// FixDryRun determines whether to print the suggested
+	// fixes as a diff instead of applying them.
+	FixDryRun bool
+
+	// FixBackup determines whether to save a copy of each
+	// fixed file, with the suffix .orig, before changing it.
+	FixBackup bool
+
+	// FixPatch is the name of a file to which to write the suggested
+	// fixes as a patch, if not empty, instead of applying them.
+	FixPatch string
+
+	// ExitZero determines whether to exit with status 0, not 3,
+	// when diagnostics are reported. Errors still cause a nonzero
+	// exit status.
+	ExitZero bool
+
+	// MaxIssues, if positive, is the maximum number of diagnostics
+	// to report; the number of the others is printed instead.
+	MaxIssues int
 )
 
 // RegisterFlags registers command-line flags used by the analysis driver.
@@ -72,6 +94,28 @@ func RegisterFlags() {
 	flag.BoolVar(&IncludeTests, "test", IncludeTests, "indicates whether test files should be analyzed, too")
 
 	flag.BoolVar(&Fix, "fix", false, "apply all suggested fixes")
+	flag.BoolVar(&FixDryRun, "fix-dry-run", false, "print the suggested fixes as a diff, without applying them")
+	flag.BoolVar(&FixBackup, "fix-backup", false, "with -fix, save each fixed file as FILE.orig first")
+	flag.StringVar(&FixPatch, "fix-patch", "", "write the suggested fixes as a patch to `file`, without applying them")
+	flag.Var(Severity, "severity", "set the severity of analyzers' diagnostics, as a comma-separated `list` of analyzer=error or analyzer=warning")
+	flag.Var(&FailOn, "fail-on", "exit with status 3 if a diagnostic of this `severity` or greater is reported: error or warning")
+	flag.StringVar(&AssumeFilename, "assume-filename", "", "read the content of the named `file` from standard input, and report only its diagnostics")
+	flag.StringVar(&DiffBase, "diff-base", "", "analyze only the packages affected by changes since the git `revision`")
+	flag.BoolVar(&DiffLines, "diff-lines", false, "with -diff-base, report only diagnostics on changed lines")
+	flag.BoolVar(&Watch, "watch", false, "analyze the packages again whenever their source files change")
+	flag.BoolVar(&ReportUnusedNolint, "report-unused-nolint", false, "report //nolint directives that suppress no diagnostic")
+	flag.Var(&ExcludePaths, "exclude-path", "report no diagnostics in the files matching a comma-separated `list` of glob patterns")
+	flag.BoolVar(&SkipGenerated, "skip-generated", false, "report no diagnostics in generated files")
+	flag.BoolVar(&ExitZero, "exit-zero", false, "exit with status 0 even if diagnostics are reported")
+	flag.IntVar(&MaxIssues, "max-issues", 0, "report at most `n` diagnostics (0 means no limit)")
+	flag.Var(&BuildConfigs, "build-config", "analyze under the build `configuration` GOOS/GOARCH, GOOS/GOARCH:TAG,..., or :TAG,... instead of the default one; may be repeated")
+	flag.BoolVar(&Timings, "timings", false, "print the time spent in each analyzer")
+	flag.IntVar(&LoadParallelism, "load-parallelism", 0, "load at most `n` packages concurrently (0 means no limit)")
+	flag.BoolVar(&DepFuncBodies, "dep-func-bodies", DepFuncBodies, "type-check the function bodies of dependencies when analyzers need facts about them")
+	flag.Var(&MemoryLimit, "memory-limit", "start no analysis while the heap exceeds this `size`, such as 2GiB, unless none is in progress (0 means no limit)")
+	flag.StringVar(&Baseline, "baseline", "", "report only the diagnostics not recorded in the baseline `file`")
+	flag.BoolVar(&WriteBaseline, "write-baseline", false, "record the diagnostics in the -baseline file instead of reporting them")
+	flag.BoolVar(&SARIF, "sarif", false, "emit the diagnostics as a SARIF log")
 }
 
 // Run loads the packages specified by args using go/packages,
@@ -108,6 +152,10 @@ func Run(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
 		}()
 	}
 
+	if MemoryLimit > 0 {
+		setMemoryLimit(uint64(MemoryLimit))
+	}
+
 	if MemProfile != "" {
 		f, err := os.Create(MemProfile)
 		if err != nil {
@@ -123,6 +171,48 @@ func Run(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
 		}()
 	}
 
+	if Watch && analysisflags.JSON {
+		log.Print("-watch is incompatible with -json")
+		return 2
+	}
+	if DiffLines && DiffBase == "" {
+		log.Print("-diff-lines requires -diff-base")
+		return 2
+	}
+	if WriteBaseline && Baseline == "" {
+		log.Print("-write-baseline requires -baseline")
+		return 2
+	}
+	if WriteBaseline && (Watch || DiffBase != "" || AssumeFilename != "" || len(BuildConfigs) > 0) {
+		log.Print("-write-baseline is incompatible with -watch, -diff-base, -assume-filename, and -build-config")
+		return 2
+	}
+	if SARIF && (Watch || analysisflags.JSON || len(BuildConfigs) > 0) {
+		log.Print("-sarif is incompatible with -watch, -json, and -build-config")
+		return 2
+	}
+
+	if len(BuildConfigs) > 0 {
+		if Fix || FixDryRun || FixPatch != "" || Watch || analysisflags.JSON || AssumeFilename != "" {
+			log.Print("-build-config is incompatible with -fix, -fix-dry-run, -fix-patch, -watch, -json, and -assume-filename")
+			return 2
+		}
+		return runConfigs(args, analyzers)
+	}
+
+	// Read the file from standard input, if any.
+	var overlay map[string][]byte
+	var stdinFile string
+	if AssumeFilename != "" {
+		var err error
+		stdinFile, overlay, err = readStdin(args)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		args = []string{"file=" + stdinFile}
+	}
+
 	// Load the packages.
 	if dbg('v') {
 		log.SetPrefix("")
@@ -133,7 +223,7 @@ func Run(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
 	// Optimization: if the selected analyzers don't produce/consume
 	// facts, we need source only for the initial packages.
 	allSyntax := needFacts(analyzers)
-	initial, err := load(args, allSyntax)
+	initial, err := load(args, allSyntax, overlay, nil)
 	if err != nil {
 		if _, ok := err.(typeParseError); !ok {
 			// Fail when some of the errors are not
@@ -144,16 +234,42 @@ func Run(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
 		// TODO: filter analyzers based on RunDespiteError?
 	}
 
+	// Restrict the analysis to the packages affected by changes.
+	var changed map[string][]lineRange
+	if DiffBase != "" {
+		changed, err = changedLines(DiffBase)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		initial = affectedPackages(initial, changed)
+	}
+
 	// Print the results.
-	roots := analyze(initial, analyzers)
+	roots := analyze(initial, analyzers, nil)
+	applyNolint(roots)
+	excludeFiles(roots)
+	if stdinFile != "" {
+		keepFile(roots, stdinFile)
+	}
+	if DiffLines {
+		keepChangedLines(roots, changed)
+	}
+	if err := applyBaseline(roots); err != nil {
+		log.Print(err)
+		return 1
+	}
 
-	if Fix {
+	if Fix || FixDryRun || FixPatch != "" {
 		if err := applyFixes(roots); err != nil {
 			// Fail when applying fixes failed.
 			log.Print(err)
 			return 1
 		}
 	}
+	if Watch {
+		watch(initial, roots, analyzers)
+	}
 	return printDiagnostics(roots)
 }
 
@@ -165,15 +281,20 @@ type typeParseError struct {
 
 // load loads the initial packages. If all loading issues are related to
 // typing and parsing, the returned error is of type typeParseError.
-func load(patterns []string, allSyntax bool) ([]*packages.Package, error) {
+func load(patterns []string, allSyntax bool, overlay map[string][]byte, bc *buildConfig) ([]*packages.Package, error) {
 	mode := packages.LoadSyntax
 	if allSyntax {
 		mode = packages.LoadAllSyntax
 	}
 	conf := packages.Config{
-		Mode:  mode,
-		Tests: IncludeTests,
+		Mode:       mode,
+		Tests:      IncludeTests,
+		Overlay:    overlay,
+		Env:        bc.env(),
+		BuildFlags: bc.buildFlags(),
 	}
+	packagesinternal.SetParallelism(&conf, LoadParallelism)
+	packagesinternal.SetIgnoreDepFuncBodies(&conf, !DepFuncBodies)
 	initial, err := packages.Load(&conf, patterns...)
 	if err == nil {
 		if len(initial) == 0 {
@@ -221,23 +342,68 @@ func loadingError(initial []*packages.Package) error {
 //
 // This entry point is used only by analysistest.
 func TestAnalyzer(a *analysis.Analyzer, pkgs []*packages.Package) []*TestAnalyzerResult {
-	var results []*TestAnalyzerResult
-	for _, act := range analyze(pkgs, []*analysis.Analyzer{a}) {
-		facts := make(map[types.Object][]analysis.Fact)
-		for key, fact := range act.objectFacts {
-			if key.obj.Pkg() == act.pass.Pkg {
-				facts[key.obj] = append(facts[key.obj], fact)
-			}
-		}
-		for key, fact := range act.packageFacts {
-			if key.pkg == act.pass.Pkg {
-				facts[nil] = append(facts[nil], fact)
+	results, _ := TestAnalyzerDeps(a, pkgs, nil)
+	return results
+}
+
+// TestAnalyzerDeps is like TestAnalyzer, but additionally returns the
+// results of applying the analysis to the dependencies of pkgs, which
+// are analyzed too if the analysis uses facts. The dependencies are
+// returned in depth-first order.
+//
+// The fakes map associates analyzers required by a, directly or
+// indirectly, with functions to be called instead of their Run
+// functions, so that a can be tested in isolation from them.
+//
+// This entry point is used only by analysistest.
+func TestAnalyzerDeps(a *analysis.Analyzer, pkgs []*packages.Package, fakes map[*analysis.Analyzer]func(*analysis.Pass) (interface{}, error)) (results, deps []*TestAnalyzerResult) {
+	roots := analyze(pkgs, []*analysis.Analyzer{a}, fakes)
+
+	seen := make(map[*action]bool)
+	for _, root := range roots {
+		// Each root has a single dependency: the action
+		// applying a to the root's package.
+		act := root.deps[0]
+		seen[act] = true
+		results = append(results, testResult(act))
+	}
+	var visit func(act *action)
+	visit = func(act *action) {
+		for _, dep := range act.deps {
+			if dep.a == a && !seen[dep] {
+				seen[dep] = true
+				visit(dep)
+				deps = append(deps, testResult(dep))
 			}
 		}
+	}
+	for _, root := range roots {
+		visit(root.deps[0])
+	}
+	return results, deps
+}
 
-		results = append(results, &TestAnalyzerResult{act.pass, act.diagnostics, facts, act.result, act.err})
+// testResult returns the TestAnalyzerResult of an executed action.
+func testResult(act *action) *TestAnalyzerResult {
+	facts := make(map[types.Object][]analysis.Fact)
+	for key, fact := range act.objectFacts {
+		if key.obj.Pkg() == act.pass.Pkg {
+			facts[key.obj] = append(facts[key.obj], fact)
+		}
+	}
+	for key, fact := range act.packageFacts {
+		if key.pkg == act.pass.Pkg {
+			facts[nil] = append(facts[nil], fact)
+		}
+	}
+	return &TestAnalyzerResult{
+		Pass:        act.pass,
+		Diagnostics: act.diagnostics,
+		Facts:       facts,
+		Result:      act.result,
+		Err:         act.err,
+		act:         act,
 	}
-	return results
 }
 
 type TestAnalyzerResult struct {
@@ -246,9 +412,34 @@ type TestAnalyzerResult struct {
 	Facts       map[types.Object][]analysis.Fact
 	Result      interface{}
 	Err         error
+
+	act *action
 }
 
-func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer) []*action {
+// Required returns the results of the analyzers required, directly or
+// indirectly, by the analyzer of r, applied to the same package.
+// Each analyzer's result precedes the results of those requiring it.
+func (r *TestAnalyzerResult) Required() []*TestAnalyzerResult {
+	var required []*TestAnalyzerResult
+	seen := make(map[*action]bool)
+	var visit func(act *action)
+	visit = func(act *action) {
+		for _, dep := range act.deps {
+			if dep.pkg == act.pkg && !seen[dep] {
+				seen[dep] = true
+				visit(dep)
+				required = append(required, testResult(dep))
+			}
+		}
+	}
+	visit(r.act)
+	return required
+}
+
+// analyze builds and executes the graph of actions that apply the
+// analyzers to pkgs. The fakes map, which may be nil, associates
+// analyzers with functions to be called instead of their Run functions.
+func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer, fakes map[*analysis.Analyzer]func(*analysis.Pass) (interface{}, error)) []*action {
 	// Construct the action graph.
 	if dbg('v') {
 		log.Printf("building graph of analysis passes")
@@ -271,8 +462,8 @@ func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer) []*action
 					return nil, nil
 				},
 			},
-			pkg:    pkg,
-			isroot: true,
+			pkg:       pkg,
+			synthetic: true,
 		}
 	}
 
@@ -283,7 +474,7 @@ func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer) []*action
 		k := key{a, pkg}
 		act, ok := actions[k]
 		if !ok {
-			act = &action{a: a, pkg: pkg}
+			act = &action{a: a, pkg: pkg, run: fakes[a]}
 
 			// Add a dependency on each required analyzers.
 			for _, req := range a.Requires {
@@ -311,14 +502,16 @@ func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer) []*action
 
 	for _, a := range analyzers {
 		for _, pkg := range pkgs {
-			pkgRoots[pkg].deps = append(pkgRoots[pkg].deps, mkAction(a, pkg))
+			act := mkAction(a, pkg)
+			act.isroot = true
+			pkgRoots[pkg].deps = append(pkgRoots[pkg].deps, act)
 		}
 	}
 
-	// Build nodes for initial packages.
+	// Build nodes for initial packages, in the order they were loaded.
 	var roots []*action
-	for _, p := range pkgRoots {
-		roots = append(roots, p)
+	for _, pkg := range pkgs {
+		roots = append(roots, pkgRoots[pkg])
 	}
 
 	if dbg('d') {
@@ -346,7 +539,7 @@ func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer) []*action
 	// execAll(roots, pool)
 	// pool.WaitAndDispose()
 
-	pool := newSmartExecPool(runtime.GOMAXPROCS(0), roots)
+	pool := newSmartExecPool(runtime.GOMAXPROCS(0), uint64(MemoryLimit), roots)
 	pool.SpawnWorkers()
 	pool.WaitAndDispose()
 
@@ -383,162 +576,133 @@ func writeDotForAction(builder *strings.Builder, act *action) {
 	builder.WriteByte('"')
 }
 
+// A fix is a suggested fix whose edits are byte offsets.
+// Its edits must be applied together or not at all.
+type fix struct {
+	analyzer string
+	message  string
+	edits    []edits.Edit
+}
+
+// applyFixes applies the suggested fixes of the diagnostics of the
+// root actions, or, if FixDryRun, prints them as a diff, or, if
+// FixPatch is set, writes them as a patch to that file.
+//
+// Fixes are considered in a deterministic order, by position, analyzer,
+// and message. Edits identical to an already accepted one, as arise
+// when a file belongs to several packages, such as p and p [p.test],
+// are redundant. A fix with an edit that overlaps a different, already
+// accepted edit conflicts with it and is skipped in its entirety, with
+// a warning.
 func applyFixes(roots []*action) error {
+	var fixes []fix
 	visited := make(map[*action]bool)
-	var apply func(*action) error
 	var visitAll func(actions []*action) error
 	visitAll = func(actions []*action) error {
 		for _, act := range actions {
-			if !visited[act] {
-				visited[act] = true
-				if err := visitAll(act.deps); err != nil {
-					return err
-				}
-				if err := apply(act); err != nil {
-					return err
-				}
+			if visited[act] {
+				continue
 			}
-		}
-		return nil
-	}
-
-	// TODO(matloob): Is this tree business too complicated? (After all this is Go!)
-	// Just create a set (map) of edits, sort by pos and call it a day?
-	type offsetedit struct {
-		start, end int
-		newText    []byte
-	} // TextEdit using byteOffsets instead of pos
-	type node struct {
-		edit        offsetedit
-		left, right *node
-	}
-	// Edits x and y are equivalent.
-	equiv := func(x, y offsetedit) bool {
-		return x.start == y.start && x.end == y.end && bytes.Equal(x.newText, y.newText)
-	}
-
-	var insert func(tree **node, edit offsetedit) error
-	insert = func(treeptr **node, edit offsetedit) error {
-		if *treeptr == nil {
-			*treeptr = &node{edit, nil, nil}
-			return nil
-		}
-		tree := *treeptr
-		if edit.end <= tree.edit.start {
-			return insert(&tree.left, edit)
-		} else if edit.start >= tree.edit.end {
-			return insert(&tree.right, edit)
-		}
-		if equiv(edit, tree.edit) { // equivalent edits?
-			// We skip over equivalent edits without considering them
-			// an error. This handles identical edits coming from the
-			// multiple ways of loading a package into a
-			// *go/packages.Packages for testing, e.g. packages "p" and "p [p.test]".
-			return nil
-		}
-
-		// Overlapping text edit.
-		return fmt.Errorf("analyses applying overlapping text edits affecting pos range (%v, %v) and (%v, %v)",
-			edit.start, edit.end, tree.edit.start, tree.edit.end)
-
-	}
-
-	editsForFile := make(map[*token.File]*node)
-
-	apply = func(act *action) error {
-		for _, diag := range act.diagnostics {
-			for _, sf := range diag.SuggestedFixes {
-				for _, edit := range sf.TextEdits {
-					// Validate the edit.
-					if edit.Pos > edit.End {
-						return fmt.Errorf(
-							"diagnostic for analysis %v contains Suggested Fix with malformed edit: pos (%v) > end (%v)",
-							act.a.Name, edit.Pos, edit.End)
-					}
-					file, endfile := act.pkg.Fset.File(edit.Pos), act.pkg.Fset.File(edit.End)
-					if file == nil || endfile == nil || file != endfile {
-						return (fmt.Errorf(
-							"diagnostic for analysis %v contains Suggested Fix with malformed spanning files %v and %v",
-							act.a.Name, file.Name(), endfile.Name()))
+			visited[act] = true
+			if err := visitAll(act.deps); err != nil {
+				return err
+			}
+			if !act.isroot {
+				continue // don't edit the dependencies
+			}
+			for _, diag := range act.diagnostics {
+				for _, sf := range diag.SuggestedFixes {
+					fix := fix{analyzer: act.a.Name, message: sf.Message}
+					for _, edit := range sf.TextEdits {
+						// Validate the edit.
+						if edit.Pos > edit.End {
+							return fmt.Errorf(
+								"diagnostic for analysis %v contains Suggested Fix with malformed edit: pos (%v) > end (%v)",
+								act.a.Name, edit.Pos, edit.End)
+						}
+						file, endfile := act.pkg.Fset.File(edit.Pos), act.pkg.Fset.File(edit.End)
+						if file == nil || endfile == nil || file != endfile {
+							return fmt.Errorf(
+								"diagnostic for analysis %v contains Suggested Fix with malformed spanning files %v and %v",
+								act.a.Name, file.Name(), endfile.Name())
+						}
+						fix.edits = append(fix.edits, edits.Edit{
+							Filename: file.Name(),
+							Start:    file.Offset(edit.Pos),
+							End:      file.Offset(edit.End),
+							New:      string(edit.NewText),
+						})
 					}
-					start, end := file.Offset(edit.Pos), file.Offset(edit.End)
-
-					// TODO(matloob): Validate that edits do not affect other packages.
-					root := editsForFile[file]
-					if err := insert(&root, offsetedit{start, end, edit.NewText}); err != nil {
-						return err
+					if len(fix.edits) > 0 {
+						fixes = append(fixes, fix)
 					}
-					editsForFile[file] = root // In case the root changed
 				}
 			}
 		}
 		return nil
 	}
-
 	if err := visitAll(roots); err != nil {
 		return err
 	}
 
-	fset := token.NewFileSet() // Shared by parse calls below
-	// Now we've got a set of valid edits for each file. Get the new file contents.
-	for f, tree := range editsForFile {
-		contents, err := ioutil.ReadFile(f.Name())
-		if err != nil {
-			return err
+	sort.SliceStable(fixes, func(i, j int) bool {
+		x, y := fixes[i], fixes[j]
+		if x.edits[0].Filename != y.edits[0].Filename {
+			return x.edits[0].Filename < y.edits[0].Filename
 		}
-
-		cur := 0 // current position in the file
-
-		var out bytes.Buffer
-
-		var recurse func(*node)
-		recurse = func(node *node) {
-			if node.left != nil {
-				recurse(node.left)
-			}
-
-			edit := node.edit
-			if edit.start > cur {
-				out.Write(contents[cur:edit.start])
-				out.Write(edit.newText)
-			} else if cur == 0 && edit.start == 0 { // edit starts at first character?
-				out.Write(edit.newText)
-			}
-			cur = edit.end
-
-			if node.right != nil {
-				recurse(node.right)
-			}
+		if x.edits[0].Start != y.edits[0].Start {
+			return x.edits[0].Start < y.edits[0].Start
 		}
-		recurse(tree)
-		// Write out the rest of the file.
-		if cur < len(contents) {
-			out.Write(contents[cur:])
+		if x.analyzer != y.analyzer {
+			return x.analyzer < y.analyzer
 		}
-
-		// Try to format the file.
-		ff, err := parser.ParseFile(fset, f.Name(), out.Bytes(), parser.ParseComments)
-		if err == nil {
-			var buf bytes.Buffer
-			if err = format.Node(&buf, fset, ff); err == nil {
-				out = buf
+		return x.message < y.message
+	})
+	set := edits.Set{Format: true}
+	for _, fix := range fixes {
+		if err := set.Add(fix.edits...); err != nil {
+			conflict, ok := err.(*edits.ConflictError)
+			if !ok {
+				return err
 			}
+			fmt.Fprintf(os.Stderr, "%s: skipping fix %q of %s: it conflicts with another edit of bytes %d-%d\n",
+				conflict.Prev.Filename, fix.message, fix.analyzer, conflict.Prev.Start, conflict.Prev.End)
 		}
+	}
 
-		if err := ioutil.WriteFile(f.Name(), out.Bytes(), 0644); err != nil {
+	if FixDryRun || FixPatch != "" {
+		var patch bytes.Buffer
+		wd, _ := os.Getwd()
+		if err := set.Diff(&patch, wd); err != nil {
+			return err
+		}
+		if FixDryRun {
+			_, err := os.Stdout.Write(patch.Bytes())
 			return err
 		}
+		return ioutil.WriteFile(FixPatch, patch.Bytes(), 0644)
 	}
-	return nil
+	if FixBackup {
+		for _, file := range set.Filenames() {
+			before, _, err := set.Content(file)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(file+".orig", before, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return set.Apply()
 }
 
 // printDiagnostics prints the diagnostics for the root packages in either
-// plain text or JSON format. JSON format also includes errors for any
-// dependencies.
+// plain text, SARIF, or JSON format. JSON format also includes errors for
+// any dependencies.
 //
-// It returns the exitcode: in plain mode, 0 for success, 1 for analysis
-// errors, and 3 for diagnostics. We avoid 2 since the flag package uses
-// it. JSON mode always succeeds at printing errors and diagnostics in a
+// It returns the exitcode: in plain and SARIF mode, 0 for success, 1 for
+// analysis errors, and 3 for diagnostics of at least the FailOn severity,
+// unless ExitZero. We avoid 2 since the flag package uses it. JSON mode always succeeds at printing errors and diagnostics in a
 // structured form to stdout.
 func printDiagnostics(roots []*action) (exitcode int) {
 	// Print the output.
@@ -546,6 +710,7 @@ func printDiagnostics(roots []*action) (exitcode int) {
 	// Print diagnostics only for root packages,
 	// but errors for all packages.
 	printed := make(map[*action]bool)
+	reported, omitted := 0, 0 // numbers of diagnostics, with -max-issues
 	var print func(*action)
 	var visitAll func(actions []*action)
 	visitAll = func(actions []*action) {
@@ -562,9 +727,19 @@ func printDiagnostics(roots []*action) (exitcode int) {
 		// JSON output
 		tree := make(analysisflags.JSONTree)
 		print = func(act *action) {
+			if act.synthetic {
+				return
+			}
 			var diags []analysis.Diagnostic
 			if act.isroot {
-				diags = act.diagnostics
+				for _, diag := range act.diagnostics {
+					if MaxIssues > 0 && reported >= MaxIssues {
+						omitted++
+						continue
+					}
+					reported++
+					diags = append(diags, diag)
+				}
 			}
 			tree.Add(act.pkg.Fset, act.pkg.ID, act.a.Name, diags, act.err)
 		}
@@ -583,8 +758,16 @@ func printDiagnostics(roots []*action) (exitcode int) {
 			message string
 		}
 		seen := make(map[key]bool)
+		worst := 0 // greatest rank of the severity of a diagnostic
+		var sarif *sarifWriter
+		if SARIF {
+			sarif = newSARIFWriter()
+		}
 
 		print = func(act *action) {
+			if act.synthetic {
+				return
+			}
 			if act.err != nil {
 				fmt.Fprintf(os.Stderr, "%s: %v\n", act.a.Name, act.err)
 				exitcode = 1 // analysis failed, at least partially
@@ -602,18 +785,40 @@ func printDiagnostics(roots []*action) (exitcode int) {
 						continue // duplicate
 					}
 					seen[k] = true
+					if rank := severityRank[severity(act.a.Name)]; rank > worst {
+						worst = rank
+					}
+					if MaxIssues > 0 && reported >= MaxIssues {
+						omitted++
+						continue
+					}
+					reported++
 
-					analysisflags.PrintPlain(act.pkg.Fset, diag)
+					if sarif != nil {
+						sarif.add(act.pkg.Fset, act.a, diag)
+					} else {
+						analysisflags.PrintPlain(act.pkg.Fset, diag)
+					}
 				}
 			}
 		}
 		visitAll(roots)
+		if sarif != nil {
+			if err := sarif.write(os.Stdout); err != nil {
+				log.Print(err)
+				exitcode = 1
+			}
+		}
 
-		if exitcode == 0 && len(seen) > 0 {
+		if exitcode == 0 && !ExitZero && worst > 0 && worst >= severityRank[string(FailOn)] {
 			exitcode = 3 // successfully produced diagnostics
 		}
 	}
 
+	if omitted > 0 {
+		fmt.Fprintf(os.Stderr, "%d more diagnostics not reported (-max-issues=%d)\n", omitted, MaxIssues)
+	}
+
 	// Print timing info.
 	if dbg('t') {
 		if !dbg('p') {
@@ -639,6 +844,9 @@ func printDiagnostics(roots []*action) (exitcode int) {
 			}
 		}
 	}
+	if dbg('t') || Timings {
+		printTimings(os.Stderr, printed)
+	}
 
 	return exitcode
 }
@@ -670,9 +878,11 @@ func needFacts(analyzers []*analysis.Analyzer) bool {
 type action struct {
 	once         sync.Once
 	a            *analysis.Analyzer
+	run          func(*analysis.Pass) (interface{}, error) // if non-nil, replaces a.Run
 	pkg          *packages.Package
 	pass         *analysis.Pass
-	isroot       bool
+	isroot       bool // applies a requested analyzer to an initial package
+	synthetic    bool // the root of the actions for an initial package
 	deps         []*action
 	objectFacts  map[objectFactKey]analysis.Fact
 	packageFacts map[packageFactKey]analysis.Fact
@@ -735,7 +945,7 @@ func (act *action) execOnce() {
 	// time is 5x higher than in sequential mode, even with a
 	// semaphore limiting the number of threads here.
 	// So use -debug=tp.
-	if dbg('t') {
+	if dbg('t') || Timings {
 		t0 := time.Now()
 		defer func() { act.duration = time.Since(t0) }()
 	}
@@ -828,7 +1038,11 @@ func (act *action) execOnce() {
 	if act.pkg.IllTyped && !pass.Analyzer.RunDespiteErrors {
 		err = fmt.Errorf("analysis skipped due to errors in package")
 	} else {
-		act.result, err = pass.Analyzer.Run(pass)
+		run := pass.Analyzer.Run
+		if act.run != nil {
+			run = act.run
+		}
+		act.result, err = run(pass)
 		if err == nil {
 			if got, want := reflect.TypeOf(act.result), pass.Analyzer.ResultType; got != want {
 				err = fmt.Errorf(
@@ -0,0 +1,224 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file defines the -build-config mode, which analyzes the packages
+// under several build configurations and merges the diagnostics, so
+// that those that occur only on some platforms or with some build tags
+// are reported too.
+
+import (
+	"fmt"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/internal/analysisflags"
+)
+
+// BuildConfigs is the list of build configurations under which to
+// analyze the packages, if not just the default one.
+var BuildConfigs buildConfigsFlag
+
+// A buildConfig is a build configuration: a target platform, or the
+// default one if goos is empty, and a set of build tags.
+type buildConfig struct {
+	goos, goarch string
+	tags         []string
+}
+
+// parseBuildConfig parses a build configuration of the form
+// GOOS/GOARCH, GOOS/GOARCH:TAG,..., or :TAG,....
+func parseBuildConfig(s string) (*buildConfig, error) {
+	platform, tags := s, ""
+	if colon := strings.IndexByte(s, ':'); colon >= 0 {
+		platform, tags = s[:colon], s[colon+1:]
+	}
+	bc := new(buildConfig)
+	if platform != "" {
+		slash := strings.IndexByte(platform, '/')
+		if slash <= 0 || slash == len(platform)-1 {
+			return nil, fmt.Errorf("invalid build configuration %q: platform is not of the form GOOS/GOARCH", s)
+		}
+		bc.goos, bc.goarch = platform[:slash], platform[slash+1:]
+	}
+	if tags != "" {
+		bc.tags = strings.Split(tags, ",")
+	}
+	if bc.goos == "" && bc.tags == nil {
+		return nil, fmt.Errorf("invalid build configuration %q", s)
+	}
+	return bc, nil
+}
+
+func (bc *buildConfig) String() string {
+	s := ""
+	if bc.goos != "" {
+		s = bc.goos + "/" + bc.goarch
+	}
+	if bc.tags != nil {
+		s += ":" + strings.Join(bc.tags, ",")
+	}
+	return s
+}
+
+// env returns the environment of the go command for the configuration.
+func (bc *buildConfig) env() []string {
+	if bc == nil || bc.goos == "" {
+		return nil
+	}
+	return append(os.Environ(), "GOOS="+bc.goos, "GOARCH="+bc.goarch)
+}
+
+// buildFlags returns the build flags for the configuration.
+func (bc *buildConfig) buildFlags() []string {
+	if bc == nil || bc.tags == nil {
+		return nil
+	}
+	return []string{"-tags=" + strings.Join(bc.tags, ",")}
+}
+
+// buildConfigsFlag is the flag.Value of -build-config, whose value
+// is a build configuration. The flag may be repeated.
+type buildConfigsFlag []*buildConfig
+
+func (f *buildConfigsFlag) String() string {
+	var configs []string
+	for _, bc := range *f {
+		configs = append(configs, bc.String())
+	}
+	return strings.Join(configs, " ")
+}
+
+func (f *buildConfigsFlag) Set(s string) error {
+	bc, err := parseBuildConfig(s)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, bc)
+	return nil
+}
+
+// runConfigs analyzes the packages under each of the BuildConfigs and
+// prints the diagnostics. Those that are not reported under all the
+// configurations are labeled with the configurations that report them.
+// It returns the exit code, as does printDiagnostics.
+func runConfigs(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
+	var changed map[string][]lineRange
+	if DiffBase != "" {
+		var err error
+		changed, err = changedLines(DiffBase)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+
+	// A finding is a diagnostic, regardless of configuration.
+	type finding struct {
+		pos, end token.Position
+		*analysis.Analyzer
+		message string
+	}
+	type report struct {
+		fset    *token.FileSet
+		diag    analysis.Diagnostic
+		configs []string
+	}
+	reports := make(map[finding]*report)
+	var findings []finding // in order of appearance
+
+	allSyntax := needFacts(analyzers)
+	for _, bc := range BuildConfigs {
+		if dbg('v') {
+			log.Printf("load %s for %s", args, bc)
+		}
+		initial, err := load(args, allSyntax, nil, bc)
+		if err != nil {
+			if _, ok := err.(typeParseError); !ok {
+				log.Printf("%s: %v", bc, err)
+				return 1
+			}
+		}
+		if DiffBase != "" {
+			initial = affectedPackages(initial, changed)
+		}
+		roots := analyze(initial, analyzers, nil)
+		applyNolint(roots)
+		excludeFiles(roots)
+		if DiffLines {
+			keepChangedLines(roots, changed)
+		}
+		if err := applyBaseline(roots); err != nil {
+			log.Print(err)
+			return 1
+		}
+
+		seen := make(map[*action]bool)
+		var visitAll func(actions []*action)
+		visitAll = func(actions []*action) {
+			for _, act := range actions {
+				if seen[act] {
+					continue
+				}
+				seen[act] = true
+				visitAll(act.deps)
+				if act.synthetic {
+					continue
+				}
+				if act.err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %s: %v\n", bc, act.a.Name, act.err)
+					exitcode = 1 // analysis failed, at least partially
+					continue
+				}
+				if !act.isroot {
+					continue
+				}
+				for _, diag := range act.diagnostics {
+					posn := act.pkg.Fset.Position(diag.Pos)
+					f := finding{posn, act.pkg.Fset.Position(diag.End), act.a, diag.Message}
+					r := reports[f]
+					if r == nil {
+						r = &report{fset: act.pkg.Fset, diag: diag}
+						reports[f] = r
+						findings = append(findings, f)
+					}
+					if n := len(r.configs); n == 0 || r.configs[n-1] != bc.String() {
+						r.configs = append(r.configs, bc.String()) // not a duplicate
+					}
+				}
+			}
+		}
+		visitAll(roots)
+	}
+
+	worst := 0 // greatest rank of the severity of a diagnostic
+	reported, omitted := 0, 0
+	for _, f := range findings {
+		r := reports[f]
+		if rank := severityRank[severity(f.Name)]; rank > worst {
+			worst = rank
+		}
+		if MaxIssues > 0 && reported >= MaxIssues {
+			omitted++
+			continue
+		}
+		reported++
+		diag := r.diag
+		if len(r.configs) < len(BuildConfigs) {
+			diag.Message += " [" + strings.Join(r.configs, " ") + "]"
+		}
+		analysisflags.PrintPlain(r.fset, diag)
+	}
+	if omitted > 0 {
+		fmt.Fprintf(os.Stderr, "%d more diagnostics not reported (-max-issues=%d)\n", omitted, MaxIssues)
+	}
+	if exitcode == 0 && !ExitZero && worst > 0 && worst >= severityRank[string(FailOn)] {
+		exitcode = 3 // successfully produced diagnostics
+	}
+	return exitcode
+}
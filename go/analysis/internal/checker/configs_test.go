@@ -0,0 +1,66 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestBuildConfigs(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"p/a.go":       "package p\n\nvar bar = 1\n",
+		"p/a_linux.go": "package p\n\nfunc l() { var bar int; _ = bar }\n",
+		"p/a_tag.go":   "//go:build tag\n\npackage p\n\nfunc t() { var bar int; _ = bar }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	t.Setenv("GOPATH", dir)
+	t.Setenv("GO111MODULE", "off")
+	pdir := filepath.Join(dir, "src", "p")
+
+	savedFix, savedConfigs := checker.Fix, checker.BuildConfigs
+	defer func() { checker.Fix, checker.BuildConfigs = savedFix, savedConfigs }()
+	checker.Fix = false
+	checker.BuildConfigs = nil
+	for _, s := range []string{"linux/amd64", "windows/amd64", "windows/amd64:tag"} {
+		if err := checker.BuildConfigs.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = checker.Run([]string{"p"}, []*analysis.Analyzer{analyzer})
+	})
+	if code != 3 {
+		t.Errorf("exit code %d, want 3", code)
+	}
+	for _, want := range []string{
+		filepath.Join(pdir, "a.go") + `:3:5: renaming "bar" to "baz"` + "\n",
+		filepath.Join(pdir, "a_linux.go") + `:3:16: renaming "bar" to "baz" [linux/amd64]`,
+		filepath.Join(pdir, "a_tag.go") + `:5:16: renaming "bar" to "baz" [windows/amd64:tag]`,
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("output does not contain %q:\n%s", want, stderr)
+		}
+	}
+
+	for _, s := range []string{"linux", "linux/", ":", "/amd64:tag"} {
+		if err := checker.BuildConfigs.Set(s); err == nil {
+			t.Errorf("-build-config=%s succeeded, want error", s)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestDiffBase(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+	testenv.NeedsTool(t, "git")
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"unchanged/a.go": "package unchanged\n\nvar bar = 1\n",
+		"changed/a.go":   "package changed\n\nvar bar = 1\n\nvar x = 1\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	t.Setenv("GOPATH", dir)
+	t.Setenv("GO111MODULE", "off")
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	defer func(fix bool) {
+		checker.Fix, checker.DiffBase, checker.DiffLines = fix, "", false
+	}(checker.Fix)
+	checker.Fix = false
+	checker.DiffBase = "HEAD"
+
+	run := func() int {
+		return checker.Run([]string{"unchanged", "changed"}, []*analysis.Analyzer{analyzer})
+	}
+	write := func(content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, "src", "changed", "a.go"), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// No change: nothing is analyzed.
+	if got := run(); got != 0 {
+		t.Errorf("with no change, got exit code %d, want 0", got)
+	}
+
+	// A change to a line without a diagnostic: the package is
+	// analyzed, but with -diff-lines its diagnostic is not reported.
+	write("package changed\n\nvar bar = 1\n\nvar x = 2\n")
+	if got := run(); got != 3 {
+		t.Errorf("with a change, got exit code %d, want 3", got)
+	}
+	checker.DiffLines = true
+	if got := run(); got != 0 {
+		t.Errorf("with a change and -diff-lines, got exit code %d, want 0", got)
+	}
+
+	// A change to the line with the diagnostic.
+	write("package changed\n\nvar bar = 2\n\nvar x = 1\n")
+	if got := run(); got != 3 {
+		t.Errorf("with a change to a diagnostic's line and -diff-lines, got exit code %d, want 3", got)
+	}
+}
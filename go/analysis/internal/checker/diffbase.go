@@ -0,0 +1,186 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file defines the -diff-base mode, for gating a change such as
+// a pull request: only the initial packages affected by the changes
+// since a git revision are analyzed, and, with -diff-lines, only the
+// diagnostics on changed lines are reported.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+var (
+	// DiffBase, if set, is a git revision; only the initial packages
+	// that contain or depend on a file changed since then are analyzed.
+	DiffBase string
+
+	// DiffLines determines whether, with DiffBase, to report only the
+	// diagnostics whose range includes a changed line.
+	DiffLines bool
+)
+
+// A lineRange is an inclusive range of line numbers.
+type lineRange struct{ from, to int }
+
+// changedLines returns the lines of each file, by absolute name, that
+// have been added or changed in the working tree since revision base,
+// including those of untracked files.
+func changedLines(base string) (map[string][]lineRange, error) {
+	out, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(out))
+
+	out, err = git("-C", root, "diff", "--unified=0", "--no-color", "--no-ext-diff", "--no-renames", base, "--")
+	if err != nil {
+		return nil, err
+	}
+	changed, err := parseDiff(out, root)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err = git("-C", root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(string(out), "\n") {
+		if name != "" {
+			filename := filepath.Join(root, filepath.FromSlash(name))
+			changed[filename] = []lineRange{{1, int(^uint(0) >> 1)}} // the whole file
+		}
+	}
+	return changed, nil
+}
+
+func git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+// parseDiff returns the added lines of each file in the unified diff,
+// with no context lines, whose file names are relative to root. A file
+// with only deleted lines is present with no ranges.
+func parseDiff(diff []byte, root string) (map[string][]lineRange, error) {
+	changed := make(map[string][]lineRange)
+	var file string // current file, or "" if deleted
+	sc := bufio.NewScanner(bytes.NewReader(diff))
+	sc.Buffer(nil, 1<<30)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			if name == "/dev/null" {
+				file = ""
+				continue
+			}
+			if unq, err := strconv.Unquote(name); err == nil {
+				name = unq // git quotes unusual names
+			}
+			name = strings.TrimPrefix(name, "b/")
+			file = filepath.Join(root, filepath.FromSlash(name))
+			changed[file] = changed[file] // present even if no lines are added
+
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			// @@ -from[,count] +from[,count] @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("invalid hunk header %q", line)
+			}
+			from, count := fields[2][1:], "1"
+			if comma := strings.IndexByte(from, ','); comma >= 0 {
+				from, count = from[:comma], from[comma+1:]
+			}
+			start, err1 := strconv.Atoi(from)
+			n, err2 := strconv.Atoi(count)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid hunk header %q", line)
+			}
+			if n > 0 {
+				changed[file] = append(changed[file], lineRange{start, start + n - 1})
+			}
+		}
+	}
+	return changed, sc.Err()
+}
+
+// affectedPackages returns the initial packages that contain a changed
+// file or depend on a package that does.
+func affectedPackages(initial []*packages.Package, changed map[string][]lineRange) []*packages.Package {
+	memo := make(map[*packages.Package]bool)
+	var affected func(pkg *packages.Package) bool
+	affected = func(pkg *packages.Package) bool {
+		if a, ok := memo[pkg]; ok {
+			return a
+		}
+		memo[pkg] = false // break cycles
+		a := false
+		for _, file := range pkg.GoFiles {
+			if _, ok := changed[file]; ok {
+				a = true
+				break
+			}
+		}
+		if !a {
+			for _, imp := range pkg.Imports {
+				if affected(imp) {
+					a = true
+					break
+				}
+			}
+		}
+		memo[pkg] = a
+		return a
+	}
+	var res []*packages.Package
+	for _, pkg := range initial {
+		if affected(pkg) {
+			res = append(res, pkg)
+		}
+	}
+	return res
+}
+
+// keepChangedLines removes from the root actions the diagnostics
+// whose range includes no changed line.
+func keepChangedLines(roots []*action, changed map[string][]lineRange) {
+	for _, root := range roots {
+		for _, act := range root.deps {
+			diags := act.diagnostics[:0:0]
+			for _, diag := range act.diagnostics {
+				posn := act.pkg.Fset.Position(diag.Pos)
+				end := posn.Line
+				if diag.End.IsValid() {
+					end = act.pkg.Fset.Position(diag.End).Line
+				}
+				for _, r := range changed[posn.Filename] {
+					if posn.Line <= r.to && r.from <= end {
+						diags = append(diags, diag)
+						break
+					}
+				}
+			}
+			act.diagnostics = diags
+		}
+	}
+}
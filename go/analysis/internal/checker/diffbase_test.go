@@ -0,0 +1,59 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	const diff = `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -3 +3 @@ func f() {
+-	x := 1
++	x := 2
+@@ -10,0 +11,3 @@ func g() {
++	a()
++	b()
++	c()
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+--- a/gone.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package p
+-
+diff --git a/del.go b/del.go
+--- a/del.go
++++ b/del.go
+@@ -4,2 +3,0 @@
+-	y()
+-	z()
+diff --git "a/sp ace.go" "b/sp ace.go"
+--- "a/sp ace.go"
++++ "b/sp ace.go"
+@@ -1 +1,2 @@
+-package p
++package q
++
+`
+	root := filepath.FromSlash("/repo")
+	got, err := parseDiff([]byte(diff), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]lineRange{
+		filepath.Join(root, "a.go"):      {{3, 3}, {11, 13}},
+		filepath.Join(root, "del.go"):    nil,
+		filepath.Join(root, "sp ace.go"): {{1, 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiff returned %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"fmt"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/analysis/internal/analysisflags"
+)
+
+var (
+	// ExcludePaths is a list of glob patterns, in the syntax of
+	// filepath.Match, of the files whose diagnostics are not reported.
+	// Relative patterns are relative to the working directory, and a
+	// pattern that matches a directory matches all files within it.
+	ExcludePaths globsFlag
+
+	// SkipGenerated determines whether to report no diagnostics
+	// in generated files, which have a comment of the form
+	//
+	//	// Code generated ... DO NOT EDIT.
+	//
+	// before their package clause.
+	SkipGenerated bool
+)
+
+// globsFlag is the flag.Value of -exclude-path, whose value is a
+// comma-separated list of glob patterns. The flag may be repeated.
+type globsFlag []string
+
+func (f *globsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *globsFlag) Set(s string) error {
+	for _, pattern := range strings.Split(s, ",") {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		*f = append(*f, pattern)
+	}
+	return nil
+}
+
+// excludePath reports whether the named file
+// is matched by one of the patterns.
+func excludePath(patterns []string, filename string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	rel := filename
+	if wd, err := os.Getwd(); err == nil {
+		if r, err := filepath.Rel(wd, filename); err == nil {
+			rel = r
+		}
+	}
+	for _, pattern := range patterns {
+		name := rel
+		if filepath.IsAbs(pattern) {
+			name = filename
+		}
+		// Try the file and each directory containing it.
+		for {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return true
+			}
+			dir := filepath.Dir(name)
+			if dir == name || dir == "." {
+				break
+			}
+			name = dir
+		}
+	}
+	return false
+}
+
+// generatedRx matches the comment that marks a generated file.
+// See https://golang.org/s/generatedcode.
+var generatedRx = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated reports whether the file is generated.
+func isGenerated(f *ast.File) bool {
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		for _, c := range group.List {
+			if generatedRx.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excludeFiles removes from the root actions the diagnostics in the
+// files excluded by -exclude-path, the exclude patterns of the
+// configuration file, and -skip-generated, so that they are neither
+// reported nor fixed.
+func excludeFiles(roots []*action) {
+	patterns := append(ExcludePaths[:len(ExcludePaths):len(ExcludePaths)], analysisflags.Config.ExcludePaths()...)
+	if len(patterns) == 0 && !SkipGenerated {
+		return
+	}
+	excluded := make(map[string]bool) // memo, by file name
+	for _, root := range roots {
+		if SkipGenerated {
+			for _, f := range root.pkg.Syntax {
+				if isGenerated(f) {
+					excluded[root.pkg.Fset.File(f.Pos()).Name()] = true
+				}
+			}
+		}
+		for _, act := range root.deps {
+			diags := act.diagnostics[:0:0]
+			for _, diag := range act.diagnostics {
+				filename := act.pkg.Fset.Position(diag.Pos).Filename
+				ex, ok := excluded[filename]
+				if !ok {
+					ex = excludePath(patterns, filename)
+					excluded[filename] = ex
+				}
+				if !ex {
+					diags = append(diags, diag)
+				}
+			}
+			act.diagnostics = diags
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/analysisflags"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestExclude(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"p/a.go":   "package p\n\nfunc a() { var bar int; _ = bar }\n",
+		"p/gen.go": "// Code generated by hand. DO NOT EDIT.\n\npackage p\n\nfunc g() { var bar int; _ = bar }\n",
+		"p/b.go":   "package p\n\nfunc b() { var bar int; _ = bar }\n",
+	}
+
+	defer func(fix, skip bool, paths []string, config *analysisflags.ConfigFile) {
+		checker.Fix, checker.SkipGenerated, checker.ExcludePaths, analysisflags.Config = fix, skip, paths, config
+	}(checker.Fix, checker.SkipGenerated, checker.ExcludePaths, analysisflags.Config)
+	checker.Fix = true
+
+	for _, test := range []struct {
+		skipGenerated bool
+		exclude       string // relative to the p directory
+		config        string // exclude pattern of a configuration file in the p directory
+		fixed         string // names of the fixed files
+	}{
+		{false, "", "", "a.go b.go gen.go"},
+		{true, "", "", "a.go b.go"},
+		{false, "b.go", "", "a.go gen.go"},
+		{false, "*.go", "", ""},
+		{false, ".", "", ""}, // the directory
+		{true, "a.*", "", "b.go"},
+		{false, "", "b.go", "a.go gen.go"},
+		{false, "a.go", "gen.go", "b.go"},
+	} {
+		dir, cleanup, err := analysistest.WriteFiles(files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup()
+		t.Setenv("GOPATH", dir)
+		t.Setenv("GO111MODULE", "off")
+		pdir := filepath.Join(dir, "src", "p")
+
+		checker.SkipGenerated = test.skipGenerated
+		checker.ExcludePaths = nil
+		if test.exclude != "" {
+			checker.ExcludePaths.Set(filepath.Join(pdir, test.exclude))
+		}
+		analysisflags.Config = nil
+		if test.config != "" {
+			analysisflags.Config = &analysisflags.ConfigFile{
+				Filename: filepath.Join(pdir, analysisflags.ConfigFileName),
+				Exclude:  []string{test.config},
+			}
+		}
+		checker.Run([]string{"p"}, []*analysis.Analyzer{analyzer})
+
+		var fixed []string
+		for _, name := range []string{"a.go", "b.go", "gen.go"} {
+			data, err := ioutil.ReadFile(filepath.Join(pdir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != files["p/"+name] {
+				fixed = append(fixed, name)
+			}
+		}
+		if got := strings.Join(fixed, " "); got != test.fixed {
+			t.Errorf("-skip-generated=%t -exclude-path=%q, exclude = [%q]: fixed %q, want %q",
+				test.skipGenerated, test.exclude, test.config, got, test.fixed)
+		}
+	}
+}
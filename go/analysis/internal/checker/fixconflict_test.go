@@ -0,0 +1,121 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"go/ast"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/internal/testenv"
+)
+
+// conflictAnalyzer suggests two conflicting fixes for each x:
+// renaming it to a, and renaming it and the following y to b.
+var conflictAnalyzer = &analysis.Analyzer{
+	Name:     "conflict",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run: func(pass *analysis.Pass) (interface{}, error) {
+		inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+		var y *ast.Ident
+		inspect.Preorder([]ast.Node{(*ast.Ident)(nil)}, func(n ast.Node) {
+			if id := n.(*ast.Ident); id.Name == "y" {
+				y = id
+			}
+		})
+		inspect.Preorder([]ast.Node{(*ast.Ident)(nil)}, func(n ast.Node) {
+			id := n.(*ast.Ident)
+			if id.Name != "x" {
+				return
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:     id.Pos(),
+				Message: "x",
+				SuggestedFixes: []analysis.SuggestedFix{
+					{Message: "rename x and y to b", TextEdits: []analysis.TextEdit{
+						{Pos: y.Pos(), End: y.End(), NewText: []byte("b")},
+						{Pos: id.Pos(), End: id.End(), NewText: []byte("b")},
+					}},
+					{Message: "rename x to a", TextEdits: []analysis.TextEdit{
+						{Pos: id.Pos(), End: id.End(), NewText: []byte("a")},
+					}},
+				},
+			})
+		})
+		return nil, nil
+	},
+}
+
+func TestFixConflicts(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	const src = "package conflict\n\nvar x, y = 1, 2\n"
+	const fixed = "package conflict\n\nvar a, y = 1, 2\n"
+
+	defer func(fix, dryRun, backup bool, patch string) {
+		checker.Fix, checker.FixDryRun, checker.FixBackup, checker.FixPatch = fix, dryRun, backup, patch
+	}(checker.Fix, checker.FixDryRun, checker.FixBackup, checker.FixPatch)
+
+	for _, test := range []struct {
+		name                string
+		fix, dryRun, backup bool
+		patch               bool
+		want                string // the content of the file after the run
+	}{
+		{name: "fix", fix: true, want: fixed},
+		{name: "backup", fix: true, backup: true, want: fixed},
+		{name: "dry-run", dryRun: true, want: src},
+		{name: "patch", patch: true, want: src},
+	} {
+		dir, cleanup, err := analysistest.WriteFiles(map[string]string{"conflict/a.go": src})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup()
+		path := filepath.Join(dir, "src", "conflict", "a.go")
+		patch := filepath.Join(dir, "fixes.patch")
+
+		checker.Fix, checker.FixDryRun, checker.FixBackup = test.fix, test.dryRun, test.backup
+		checker.FixPatch = ""
+		if test.patch {
+			checker.FixPatch = patch
+		}
+		// Whatever the order of the fixes, the one whose
+		// first edit comes first is applied.
+		checker.Run([]string{"file=" + path}, []*analysis.Analyzer{conflictAnalyzer})
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(contents); got != test.want {
+			t.Errorf("%s: file contains\n%s\nwant\n%s", test.name, got, test.want)
+		}
+		if test.backup {
+			orig, err := ioutil.ReadFile(path + ".orig")
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			} else if string(orig) != src {
+				t.Errorf("%s: backup contains\n%s\nwant\n%s", test.name, orig, src)
+			}
+		}
+		if test.patch {
+			data, err := ioutil.ReadFile(patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); !strings.Contains(got, "-var x, y = 1, 2\n+var a, y = 1, 2\n") {
+				t.Errorf("%s: patch is\n%s", test.name, got)
+			}
+		}
+	}
+}
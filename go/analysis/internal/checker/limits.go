@@ -0,0 +1,99 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file defines the flags that trade speed for resource usage,
+// for running on machines with few CPUs or little memory.
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// LoadParallelism, if positive, is the maximum number of
+	// packages that are parsed and type-checked concurrently.
+	LoadParallelism int
+
+	// DepFuncBodies determines whether the function bodies of
+	// dependencies are type-checked when analyzers need facts about
+	// them. If false, less memory and time is used for loading, but
+	// the syntax of dependencies has no function bodies, so facts that
+	// depend on them (such as those of printf wrappers) are missed.
+	DepFuncBodies = true
+
+	// MemoryLimit, if positive, is the heap size in bytes above which
+	// the analysis of a package is not started until the memory
+	// used by others is released, unless no other is in progress.
+	MemoryLimit byteSize
+)
+
+// byteSize is the flag.Value of -memory-limit: a number of bytes,
+// optionally followed by one of the suffixes KiB, MiB, or GiB.
+type byteSize uint64
+
+var byteSuffixes = []struct {
+	suffix string
+	scale  uint64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+}
+
+func (b *byteSize) String() string {
+	for i := len(byteSuffixes) - 1; i >= 0; i-- {
+		if s := byteSuffixes[i]; *b > 0 && uint64(*b)%s.scale == 0 {
+			return fmt.Sprintf("%d%s", uint64(*b)/s.scale, s.suffix)
+		}
+	}
+	return strconv.FormatUint(uint64(*b), 10)
+}
+
+func (b *byteSize) Set(s string) error {
+	num, scale := s, uint64(1)
+	for _, suf := range byteSuffixes {
+		if strings.HasSuffix(s, suf.suffix) {
+			num, scale = strings.TrimSuffix(s, suf.suffix), suf.scale
+			break
+		}
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || n > ^uint64(0)/scale {
+		return fmt.Errorf("invalid size %q (want a number of bytes, KiB, MiB, or GiB)", s)
+	}
+	*b = byteSize(n * scale)
+	return nil
+}
+
+// A memoryGate delays the start of analyses while the heap
+// exceeds a limit and other analyses are in progress.
+type memoryGate struct {
+	limit  uint64 // zero means no limit
+	active int32  // number of analyses in progress, updated atomically
+}
+
+// enter waits until an analysis may start, and records its start.
+func (g *memoryGate) enter() {
+	for g.limit > 0 && atomic.LoadInt32(&g.active) > 0 && heapAlloc() > g.limit {
+		time.Sleep(10 * time.Millisecond)
+	}
+	atomic.AddInt32(&g.active, 1)
+}
+
+// exit records the end of an analysis.
+func (g *memoryGate) exit() {
+	atomic.AddInt32(&g.active, -1)
+}
+
+func heapAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
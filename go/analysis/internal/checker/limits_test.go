@@ -0,0 +1,41 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import "testing"
+
+func TestByteSize(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want byteSize
+		str  string
+	}{
+		{"0", 0, "0"},
+		{"1000", 1000, "1000"},
+		{"2048", 2048, "2KiB"},
+		{"512MiB", 512 << 20, "512MiB"},
+		{"3GiB", 3 << 30, "3GiB"},
+		{"1536MiB", 1536 << 20, "1536MiB"},
+	} {
+		var b byteSize
+		if err := b.Set(test.in); err != nil {
+			t.Errorf("Set(%q) failed: %v", test.in, err)
+			continue
+		}
+		if b != test.want {
+			t.Errorf("Set(%q) = %d, want %d", test.in, b, test.want)
+		}
+		if got := b.String(); got != test.str {
+			t.Errorf("Set(%q).String() = %q, want %q", test.in, got, test.str)
+		}
+	}
+
+	for _, in := range []string{"", "MiB", "-1", "1.5GiB", "2GB", "99999999999GiB"} {
+		var b byteSize
+		if err := b.Set(in); err == nil {
+			t.Errorf("Set(%q) succeeded, want error", in)
+		}
+	}
+}
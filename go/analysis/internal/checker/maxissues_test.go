@@ -0,0 +1,82 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestExitZeroMaxIssues(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"p/a.go": "package p\n\nfunc f() {\n\tvar bar int\n\t_ = bar\n\t_ = bar\n}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	path := filepath.Join(dir, "src", "p", "a.go")
+
+	defer func(fix, exitZero bool, maxIssues int) {
+		checker.Fix, checker.ExitZero, checker.MaxIssues = fix, exitZero, maxIssues
+	}(checker.Fix, checker.ExitZero, checker.MaxIssues)
+	checker.Fix = false
+
+	for _, test := range []struct {
+		exitZero  bool
+		maxIssues int
+		code      int
+		reported  int
+		summary   string
+	}{
+		{false, 0, 3, 3, ""},
+		{true, 0, 0, 3, ""},
+		{false, 2, 3, 2, "1 more diagnostics not reported (-max-issues=2)"},
+		{true, 1, 0, 1, "2 more diagnostics not reported (-max-issues=1)"},
+	} {
+		checker.ExitZero, checker.MaxIssues = test.exitZero, test.maxIssues
+		var code int
+		stderr := captureStderr(t, func() {
+			code = checker.Run([]string{"file=" + path}, []*analysis.Analyzer{analyzer})
+		})
+		if code != test.code {
+			t.Errorf("-exit-zero=%t -max-issues=%d: exit code %d, want %d", test.exitZero, test.maxIssues, code, test.code)
+		}
+		if got := strings.Count(stderr, "renaming"); got != test.reported {
+			t.Errorf("-exit-zero=%t -max-issues=%d: reported %d diagnostics, want %d:\n%s", test.exitZero, test.maxIssues, got, test.reported, stderr)
+		}
+		if test.summary != "" && !strings.Contains(stderr, test.summary) {
+			t.Errorf("-exit-zero=%t -max-issues=%d: output does not contain %q:\n%s", test.exitZero, test.maxIssues, test.summary, stderr)
+		}
+	}
+}
+
+// captureStderr returns what f writes to os.Stderr.
+func captureStderr(t *testing.T, f func()) string {
+	tmp, err := ioutil.TempFile(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmp.Close()
+	saved := os.Stderr
+	os.Stderr = tmp
+	f()
+	os.Stderr = saved
+	data, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
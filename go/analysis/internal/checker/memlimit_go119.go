@@ -0,0 +1,16 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.19
+// +build go1.19
+
+package checker
+
+import "runtime/debug"
+
+// setMemoryLimit makes the garbage collector keep
+// the memory of the process under limit, if it can.
+func setMemoryLimit(limit uint64) {
+	debug.SetMemoryLimit(int64(limit))
+}
@@ -0,0 +1,12 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.19
+// +build !go1.19
+
+package checker
+
+// setMemoryLimit is a no-op, as the garbage collector
+// has no memory limit before Go 1.19.
+func setMemoryLimit(limit uint64) {}
@@ -0,0 +1,236 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file defines the processing of //nolint directives, which
+// suppress diagnostics. A directive is a line comment of the form
+//
+//	//nolint
+//	//nolint:name1,name2
+//
+// optionally followed by a space and an explanation. Each name is that
+// of an analyzer, or a diagnostic category; "all", like the bare form,
+// matches every diagnostic. A directive applies to the line it is on
+// and, if it is in the doc comment of a declaration, a type or value
+// spec, or a field, or on the first line of one, to all of it.
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// ReportUnusedNolint determines whether to report //nolint
+// directives that suppress no diagnostic.
+var ReportUnusedNolint bool
+
+// nolintAnalyzer is the pseudo-analyzer of the diagnostics
+// that report unused //nolint directives.
+var nolintAnalyzer = &analysis.Analyzer{
+	Name: "nolint",
+	Doc:  "report unused //nolint directives",
+	Run:  func(*analysis.Pass) (interface{}, error) { return nil, nil },
+}
+
+// A nolint is a //nolint directive.
+type nolint struct {
+	pos      token.Pos // of the comment
+	from, to int       // range of lines to which it applies
+	names    []string  // nil => all
+	used     bool
+}
+
+func (d *nolint) matches(a *analysis.Analyzer, diag analysis.Diagnostic) bool {
+	if d.names == nil {
+		return true
+	}
+	for _, name := range d.names {
+		if name == "all" || name == a.Name || diag.Category != "" && name == diag.Category {
+			return true
+		}
+	}
+	return false
+}
+
+// relevant reports whether d could suppress
+// the diagnostics of an analyzer that ran.
+func (d *nolint) relevant(ran map[string]bool) bool {
+	if d.names == nil {
+		return true
+	}
+	for _, name := range d.names {
+		if name == "all" || ran[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNolint returns the names of a //nolint directive comment,
+// and whether the comment is one.
+func parseNolint(text string) (names []string, ok bool) {
+	rest := strings.TrimPrefix(text, "//nolint")
+	if rest == text {
+		return nil, false
+	}
+	if strings.HasPrefix(rest, ":") {
+		list := rest[1:]
+		if i := strings.IndexAny(list, " \t"); i >= 0 {
+			list = list[:i]
+		}
+		for _, name := range strings.Split(list, ",") {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		return names, names != nil
+	}
+	return nil, rest == "" || rest[0] == ' ' || rest[0] == '\t'
+}
+
+// fileNolints returns the //nolint directives of a file.
+func fileNolints(fset *token.FileSet, f *ast.File) []*nolint {
+	byComment := make(map[*ast.Comment]*nolint)
+	var nolints []*nolint
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			if names, ok := parseNolint(c.Text); ok {
+				posn := fset.Position(c.Slash)
+				d := &nolint{pos: c.Slash, from: posn.Line, to: posn.Line, names: names}
+				byComment[c] = d
+				nolints = append(nolints, d)
+			}
+		}
+	}
+	if len(nolints) == 0 {
+		return nil
+	}
+
+	// Extend the directives in the doc comment or
+	// on the first line of a declaration to all of it.
+	ast.Inspect(f, func(n ast.Node) bool {
+		var doc *ast.CommentGroup
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			doc = n.Doc
+		case *ast.GenDecl:
+			doc = n.Doc
+		case *ast.TypeSpec:
+			doc = n.Doc
+		case *ast.ValueSpec:
+			doc = n.Doc
+		case *ast.Field:
+			doc = n.Doc
+		case *ast.ImportSpec:
+			doc = n.Doc
+		case *ast.File, *ast.FieldList, *ast.StructType, *ast.InterfaceType:
+			return true
+		default:
+			return false // not a declaration, or within one
+		}
+		start := fset.Position(n.Pos()).Line
+		end := fset.Position(n.End()).Line
+		for _, d := range nolints {
+			if d.from == start {
+				d.to = end
+			}
+		}
+		if doc != nil {
+			for _, c := range doc.List {
+				if d := byComment[c]; d != nil {
+					d.to = end
+				}
+			}
+		}
+		return true
+	})
+	return nolints
+}
+
+// applyNolint removes the diagnostics suppressed by //nolint
+// directives in the initial packages from the root actions, before
+// fixes are applied or diagnostics printed. If ReportUnusedNolint,
+// it adds a root action for each package with unused directives,
+// whose diagnostics report them.
+//
+// A directive is unused if it suppresses no diagnostic, and it applies
+// to all analyzers or names one that ran: a directive for an analyzer
+// not in this run (perhaps part of another tool) is not reported.
+func applyNolint(roots []*action) {
+	nolints := make(map[string][]*nolint) // by file
+	ran := make(map[string]bool)          // names of analyzers that ran
+	var files []string                    // in order of the packages
+	fileRoot := make(map[string]*action)  // root of the first package of each file
+	for _, root := range roots {
+		for _, act := range root.deps {
+			ran[act.a.Name] = true
+		}
+		for _, f := range root.pkg.Syntax {
+			filename := root.pkg.Fset.Position(f.Package).Filename
+			if _, ok := fileRoot[filename]; !ok {
+				fileRoot[filename] = root
+				files = append(files, filename)
+				nolints[filename] = fileNolints(root.pkg.Fset, f)
+			}
+		}
+	}
+
+	for _, root := range roots {
+		for _, act := range root.deps {
+			if !act.isroot {
+				continue
+			}
+			diags := act.diagnostics[:0:0]
+			for _, diag := range act.diagnostics {
+				if !suppressed(nolints, act, diag) {
+					diags = append(diags, diag)
+				}
+			}
+			act.diagnostics = diags
+		}
+	}
+
+	if !ReportUnusedNolint {
+		return
+	}
+	unused := make(map[*action]*action) // pseudo-action, by root
+	for _, file := range files {
+		for _, d := range nolints[file] {
+			if d.used || !d.relevant(ran) {
+				continue
+			}
+			root := fileRoot[file]
+			act := unused[root]
+			if act == nil {
+				act = &action{a: nolintAnalyzer, pkg: root.pkg, isroot: true}
+				unused[root] = act
+				root.deps = append(root.deps, act)
+			}
+			msg := "unused //nolint directive"
+			if d.names != nil {
+				msg = "unused //nolint:" + strings.Join(d.names, ",") + " directive"
+			}
+			act.diagnostics = append(act.diagnostics, analysis.Diagnostic{Pos: d.pos, Message: msg})
+		}
+	}
+}
+
+// suppressed reports whether diag of act is suppressed by a
+// directive, and marks the directive used if so.
+func suppressed(nolints map[string][]*nolint, act *action, diag analysis.Diagnostic) bool {
+	if !diag.Pos.IsValid() {
+		return false
+	}
+	posn := act.pkg.Fset.Position(diag.Pos)
+	for _, d := range nolints[posn.Filename] {
+		if d.from <= posn.Line && posn.Line <= d.to && d.matches(act.a, diag) {
+			d.used = true
+			return true
+		}
+	}
+	return false
+}
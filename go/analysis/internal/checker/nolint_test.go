@@ -0,0 +1,78 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestNolint(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	const src = `package nolint
+
+func Foo() {
+	bar := 12 //nolint:rename // bar is intended
+	_ = bar   //nolint
+}
+
+//nolint:rename
+func Bar() {
+	bar := 12
+	_ = bar
+}
+
+type T struct { //nolint:all
+	bar int
+}
+
+var x = 1 //nolint:other
+`
+	files := map[string]string{
+		"nolint/a.go": src,
+		"unused/a.go": src + "\nvar y = 2 //nolint:rename\n",
+	}
+	testdata, cleanup, err := analysistest.WriteFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	defer func(fix, report bool) {
+		checker.Fix, checker.ReportUnusedNolint = fix, report
+	}(checker.Fix, checker.ReportUnusedNolint)
+
+	for _, test := range []struct {
+		dir    string
+		report bool
+		code   int
+	}{
+		{"nolint", false, 0},
+		{"nolint", true, 0},  // the directive for "other" is not reported
+		{"unused", false, 0}, // the unused directive is not reported
+		{"unused", true, 3},
+	} {
+		path := filepath.Join(testdata, "src", test.dir, "a.go")
+		checker.Fix = true // suppressed diagnostics must not be fixed
+		checker.ReportUnusedNolint = test.report
+		if got := checker.Run([]string{"file=" + path}, []*analysis.Analyzer{analyzer}); got != test.code {
+			t.Errorf("%s (report=%t): got exit code %d, want %d", test.dir, test.report, got, test.code)
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(contents); got != files[test.dir+"/a.go"] {
+			t.Errorf("%s: suppressed diagnostics were fixed:\n%s", test.dir, got)
+		}
+	}
+}
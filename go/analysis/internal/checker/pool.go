@@ -37,16 +37,18 @@ func (r *root) execute(act *action) {
 }
 
 func buildRoot(act *action, executed *sync.Map) *root {
-	tree := make(map[int][]*action)
-
-	tree[0] = append(tree[0], act)
-	addActionsToTree(1, act.deps, tree)
+	// Each action is placed at a depth that is greater than that of
+	// every action that depends on it, so that executing from the
+	// deepest level up runs the dependencies first. Using the height
+	// of each action, the length of the longest path to a leaf,
+	// visits each shared dependency once; enumerating the paths from
+	// the root is exponential in the depth of a dependency DAG.
+	heights := make(map[*action]int)
+	depth := height(act, heights)
 
-	depth := 0
-	for k := range tree {
-		if k > depth {
-			depth = k
-		}
+	tree := make(map[int][]*action)
+	for act, h := range heights {
+		tree[depth-h] = append(tree[depth-h], act)
 	}
 
 	return &root{
@@ -56,11 +58,20 @@ func buildRoot(act *action, executed *sync.Map) *root {
 	}
 }
 
-func addActionsToTree(depth int, actions []*action, tree map[int][]*action) {
-	for _, act := range actions {
-		tree[depth] = append(tree[depth], act)
-		addActionsToTree(depth+1, act.deps, tree)
+// height returns the length of the longest path from act to an action
+// with no dependencies, memoizing it in heights.
+func height(act *action, heights map[*action]int) int {
+	if h, ok := heights[act]; ok {
+		return h
+	}
+	h := 0
+	for _, dep := range act.deps {
+		if d := height(dep, heights) + 1; d > h {
+			h = d
+		}
 	}
+	heights[act] = h
+	return h
 }
 
 // smartExecPool is a smart parallel executor for analysis passes. It takes the
@@ -79,15 +90,17 @@ type smartExecPool struct {
 	done     sync.Once
 	wg       sync.WaitGroup
 	executed *sync.Map
+	memory   memoryGate
 }
 
-func newSmartExecPool(workers int, roots []*action) *smartExecPool {
+func newSmartExecPool(workers int, memoryLimit uint64, roots []*action) *smartExecPool {
 	numRoots := len(roots)
 
 	pool := &smartExecPool{
 		workers:  workers,
 		getRoot:  make(chan *root, numRoots),
 		executed: new(sync.Map),
+		memory:   memoryGate{limit: memoryLimit},
 	}
 	pool.wg.Add(numRoots)
 
@@ -107,7 +120,9 @@ func (s *smartExecPool) SpawnWorkers() {
 		for i := 0; i < s.workers; i++ {
 			go func() {
 				for root := range s.getRoot {
+					s.memory.enter()
 					root.exec()
+					s.memory.exit()
 					s.wg.Done()
 				}
 			}()
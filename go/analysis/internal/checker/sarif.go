@@ -0,0 +1,251 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file defines the output of the diagnostics in the Static
+// Analysis Results Interchange Format (SARIF) 2.1.0, which code review
+// and code scanning services display. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// SARIF determines whether to print the diagnostics as a SARIF log
+// instead of plain text.
+var SARIF bool
+
+type sarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []*sarifResult                   `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string       `json:"name"`
+	Rules []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	RuleIndex        int             `json:"ruleIndex"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// A sarifRegion is a range of a file. Its lines are 1-based, as are its
+// columns, which count UTF-16 code units, the default of SARIF, unlike
+// those of token.Position, which count bytes.
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion   `json:"deletedRegion"`
+	InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+}
+
+// srcRoot is the base of the URIs of the files in the working
+// directory, which are relative to it.
+const srcRoot = "%SRCROOT%"
+
+// A sarifWriter accumulates the diagnostics of a SARIF log.
+type sarifWriter struct {
+	run   *sarifRun
+	wd    string
+	rules map[*analysis.Analyzer]int // indexes of the rules
+	lines map[string][][]byte        // memo of sourceLine
+}
+
+func newSARIFWriter() *sarifWriter {
+	w := &sarifWriter{
+		run: &sarifRun{
+			Tool:    sarifTool{sarifDriver{Name: filepath.Base(os.Args[0]), Rules: []*sarifRule{}}},
+			Results: []*sarifResult{},
+		},
+		rules: make(map[*analysis.Analyzer]int),
+		lines: make(map[string][][]byte),
+	}
+	if wd, err := os.Getwd(); err == nil {
+		w.wd = wd
+		w.run.OriginalURIBaseIDs = map[string]sarifArtifactLocation{
+			srcRoot: {URI: fileURI(wd) + "/"},
+		}
+	}
+	return w
+}
+
+// add adds a diagnostic of analyzer a to the log.
+func (w *sarifWriter) add(fset *token.FileSet, a *analysis.Analyzer, diag analysis.Diagnostic) {
+	index, ok := w.rules[a]
+	if !ok {
+		index = len(w.run.Tool.Driver.Rules)
+		w.rules[a] = index
+		short := a.Doc
+		if i := strings.Index(short, "\n"); i >= 0 {
+			short = short[:i]
+		}
+		w.run.Tool.Driver.Rules = append(w.run.Tool.Driver.Rules, &sarifRule{
+			ID:                   a.Name,
+			ShortDescription:     sarifMessage{short},
+			FullDescription:      sarifMessage{a.Doc},
+			DefaultConfiguration: sarifConfiguration{severity(a.Name)},
+		})
+	}
+	res := &sarifResult{
+		RuleID:    a.Name,
+		RuleIndex: index,
+		Level:     severity(a.Name),
+		Message:   sarifMessage{diag.Message},
+		Locations: []sarifLocation{w.location(fset, diag.Pos, diag.End)},
+	}
+	for _, rel := range diag.Related {
+		loc := w.location(fset, rel.Pos, rel.End)
+		loc.Message = &sarifMessage{rel.Message}
+		res.RelatedLocations = append(res.RelatedLocations, loc)
+	}
+	for _, sf := range diag.SuggestedFixes {
+		fix := sarifFix{Description: sarifMessage{sf.Message}}
+		byFile := make(map[string]int) // indexes of the changes
+		for _, edit := range sf.TextEdits {
+			loc := w.location(fset, edit.Pos, edit.End)
+			if loc.PhysicalLocation.Region == nil {
+				continue // invalid position
+			}
+			i, ok := byFile[loc.PhysicalLocation.ArtifactLocation.URI]
+			if !ok {
+				i = len(fix.ArtifactChanges)
+				byFile[loc.PhysicalLocation.ArtifactLocation.URI] = i
+				fix.ArtifactChanges = append(fix.ArtifactChanges, sarifArtifactChange{
+					ArtifactLocation: loc.PhysicalLocation.ArtifactLocation,
+				})
+			}
+			repl := sarifReplacement{DeletedRegion: *loc.PhysicalLocation.Region}
+			if len(edit.NewText) > 0 {
+				repl.InsertedContent = &sarifMessage{string(edit.NewText)}
+			}
+			fix.ArtifactChanges[i].Replacements = append(fix.ArtifactChanges[i].Replacements, repl)
+		}
+		res.Fixes = append(res.Fixes, fix)
+	}
+	w.run.Results = append(w.run.Results, res)
+}
+
+// location returns the location of the range [pos, end), or of pos if
+// end is not valid.
+func (w *sarifWriter) location(fset *token.FileSet, pos, end token.Pos) sarifLocation {
+	posn := fset.Position(pos)
+	art := sarifArtifactLocation{URI: fileURI(posn.Filename)}
+	if rel, err := filepath.Rel(w.wd, posn.Filename); w.wd != "" && err == nil && !strings.HasPrefix(rel, "..") {
+		art = sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: srcRoot}
+	}
+	loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: art}}
+	if posn.Line > 0 {
+		region := &sarifRegion{StartLine: posn.Line, StartColumn: w.column(posn)}
+		if end.IsValid() {
+			endPosn := fset.Position(end)
+			region.EndLine, region.EndColumn = endPosn.Line, w.column(endPosn)
+		}
+		loc.PhysicalLocation.Region = region
+	}
+	return loc
+}
+
+// column returns the column of posn in UTF-16 code units.
+func (w *sarifWriter) column(posn token.Position) int {
+	sourceLine(w.lines, posn) // memoizes the lines of the file
+	l := w.lines[posn.Filename]
+	if posn.Line < 1 || posn.Line > len(l) || posn.Column < 1 || posn.Column-1 > len(l[posn.Line-1]) {
+		return posn.Column
+	}
+	return len(utf16.Encode([]rune(string(l[posn.Line-1][:posn.Column-1])))) + 1
+}
+
+// write writes the log to out.
+func (w *sarifWriter) write(out io.Writer) error {
+	data, err := json.MarshalIndent(&sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []*sarifRun{w.run},
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(data, '\n'))
+	return err
+}
+
+// fileURI returns the file URI of the named file.
+func fileURI(filename string) string {
+	path := filepath.ToSlash(filename)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // e.g. C:/dir
+	}
+	return "file://" + path
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestSARIF(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"p/a.go": "package p\n\nfunc f() {\n\tvar é, bar int\n\t_, _ = é, bar\n}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	path := filepath.Join(dir, "src", "p", "a.go")
+
+	defer func(fix, sarif bool) {
+		checker.Fix, checker.SARIF = fix, sarif
+	}(checker.Fix, checker.SARIF)
+	checker.Fix = false
+	checker.SARIF = true
+
+	var code int
+	stdout := captureStdout(t, func() {
+		code = checker.Run([]string{"file=" + path}, []*analysis.Analyzer{analyzer})
+	})
+	if code != 3 {
+		t.Errorf("exit code %d, want 3", code)
+	}
+
+	type region struct{ StartLine, StartColumn, EndLine, EndColumn int }
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct{ URI string }
+			Region           region
+		}
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Message   struct{ Text string }
+				Locations []location
+				Fixes     []struct {
+					ArtifactChanges []struct {
+						Replacements []struct {
+							InsertedContent struct{ Text string }
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(stdout), &log); err != nil {
+		t.Fatalf("invalid SARIF log: %v\n%s", err, stdout)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("got version %q and %d runs, want 2.1.0 and 1 run:\n%s", log.Version, len(log.Runs), stdout)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "rename" {
+		t.Errorf("got rules %+v, want rename", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("got %d results, want 2:\n%s", len(run.Results), stdout)
+	}
+	res := run.Results[0]
+	if res.RuleID != "rename" || res.Level != "error" || res.Message.Text != `renaming "bar" to "baz"` {
+		t.Errorf("got result %s %s %q", res.RuleID, res.Level, res.Message.Text)
+	}
+	loc := res.Locations[0].PhysicalLocation
+	if want := "file://" + filepath.ToSlash(path); loc.ArtifactLocation.URI != want {
+		t.Errorf("got URI %s, want %s", loc.ArtifactLocation.URI, want)
+	}
+	// The columns count UTF-16 code units: é is one, in two bytes.
+	if want := (region{4, 9, 4, 12}); loc.Region != want {
+		t.Errorf("got region %+v, want %+v", loc.Region, want)
+	}
+	if len(res.Fixes) != 1 || res.Fixes[0].ArtifactChanges[0].Replacements[0].InsertedContent.Text != "baz" {
+		t.Errorf("got fixes %+v, want one inserting baz", res.Fixes)
+	}
+}
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	tmp, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmp.Close()
+	saved := os.Stdout
+	os.Stdout = tmp
+	f()
+	os.Stdout = saved
+	data, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
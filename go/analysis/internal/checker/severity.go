@@ -0,0 +1,85 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis/internal/analysisflags"
+)
+
+// The severities of diagnostics, in increasing order.
+const (
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+var severityRank = map[string]int{severityWarning: 1, severityError: 2}
+
+var (
+	// Severity maps the names of analyzers to the severity of their
+	// diagnostics, overriding the configuration file. The severity
+	// of other analyzers is that of the configuration file, or error.
+	Severity = make(severityFlag)
+
+	// FailOn is the least severity of a diagnostic that causes
+	// an exit status of 3.
+	FailOn failOnFlag = severityError
+)
+
+// severity returns the severity of the diagnostics of the named analyzer.
+func severity(analyzer string) string {
+	if sev, ok := Severity[analyzer]; ok {
+		return sev
+	}
+	if analysisflags.Config.Warning(analyzer) {
+		return severityWarning
+	}
+	return severityError
+}
+
+// severityFlag is the flag.Value of -severity, whose value is a
+// comma-separated list of analyzer=severity pairs.
+// The flag may be repeated.
+type severityFlag map[string]string
+
+func (f severityFlag) String() string {
+	var pairs []string
+	for name, sev := range f {
+		pairs = append(pairs, name+"="+sev)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f severityFlag) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		eq := strings.IndexByte(pair, '=')
+		if eq < 0 {
+			return fmt.Errorf("%q is not of the form analyzer=severity", pair)
+		}
+		name, sev := pair[:eq], pair[eq+1:]
+		if severityRank[sev] == 0 {
+			return fmt.Errorf("invalid severity %q for %s (want error or warning)", sev, name)
+		}
+		f[name] = sev
+	}
+	return nil
+}
+
+// failOnFlag is the flag.Value of -fail-on.
+type failOnFlag string
+
+func (f *failOnFlag) String() string { return string(*f) }
+
+func (f *failOnFlag) Set(s string) error {
+	if severityRank[s] == 0 {
+		return fmt.Errorf("invalid severity %q (want error or warning)", s)
+	}
+	*f = failOnFlag(s)
+	return nil
+}
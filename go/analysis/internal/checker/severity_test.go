@@ -0,0 +1,60 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestSeverity(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"severity/a.go": "package severity\n\nvar bar = 1\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	path := filepath.Join(dir, "src", "severity", "a.go")
+
+	defer func(fix bool, failOn string) {
+		checker.Fix = fix
+		checker.FailOn.Set(failOn)
+		delete(checker.Severity, analyzer.Name)
+	}(checker.Fix, checker.FailOn.String())
+	checker.Fix = false
+
+	for _, test := range []struct {
+		severity, failOn string
+		code             int
+	}{
+		{"error", "error", 3},
+		{"warning", "error", 0},
+		{"warning", "warning", 3},
+		{"error", "warning", 3},
+	} {
+		if err := checker.Severity.Set(analyzer.Name + "=" + test.severity); err != nil {
+			t.Fatal(err)
+		}
+		if err := checker.FailOn.Set(test.failOn); err != nil {
+			t.Fatal(err)
+		}
+		if got := checker.Run([]string{"file=" + path}, []*analysis.Analyzer{analyzer}); got != test.code {
+			t.Errorf("-severity=%s=%s -fail-on=%s: got exit code %d, want %d",
+				analyzer.Name, test.severity, test.failOn, got, test.code)
+		}
+	}
+
+	if err := checker.Severity.Set(analyzer.Name + "=fatal"); err == nil {
+		t.Errorf("-severity accepted an invalid severity")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// AssumeFilename, if set, is the name of a file whose content is read
+// from the standard input, as when an editor analyzes an unsaved
+// buffer. The package containing the file is loaded from disk, and
+// only the diagnostics of that file are reported.
+var AssumeFilename string
+
+// readStdin returns the absolute name of the AssumeFilename file and
+// an overlay that replaces its content by the standard input.
+// The only permitted argument is "-".
+func readStdin(args []string) (string, map[string][]byte, error) {
+	if len(args) > 1 || len(args) == 1 && args[0] != "-" {
+		return "", nil, fmt.Errorf("-assume-filename permits no package arguments other than -")
+	}
+	if Fix || FixDryRun || FixPatch != "" || Watch {
+		return "", nil, fmt.Errorf("-assume-filename is incompatible with -fix and -watch")
+	}
+	filename, err := filepath.Abs(AssumeFilename)
+	if err != nil {
+		return "", nil, err
+	}
+	content, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading standard input: %v", err)
+	}
+	return filename, map[string][]byte{filename: content}, nil
+}
+
+// keepFile removes from the root actions
+// the diagnostics of files other than filename.
+func keepFile(roots []*action, filename string) {
+	for _, root := range roots {
+		for _, act := range root.deps {
+			diags := act.diagnostics[:0:0]
+			for _, diag := range act.diagnostics {
+				if act.pkg.Fset.Position(diag.Pos).Filename == filename {
+					diags = append(diags, diag)
+				}
+			}
+			act.diagnostics = diags
+		}
+	}
+}
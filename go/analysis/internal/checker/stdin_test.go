@@ -0,0 +1,63 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/internal/checker"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestAssumeFilename(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// The on-disk a.go and b.go have diagnostics,
+	// but only those of the buffer for a.go are reported.
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"stdin/a.go": "package stdin\n\nvar bar = 1\n",
+		"stdin/b.go": "package stdin\n\nvar bar2 = bar\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	t.Setenv("GOPATH", dir)
+	t.Setenv("GO111MODULE", "off")
+
+	defer func(fix bool, stdin *os.File) {
+		checker.Fix, checker.AssumeFilename, os.Stdin = fix, "", stdin
+	}(checker.Fix, os.Stdin)
+	checker.Fix = false
+	checker.AssumeFilename = filepath.Join(dir, "src", "stdin", "a.go")
+
+	for _, test := range []struct {
+		buffer string
+		code   int
+	}{
+		{"package stdin\n\nvar baz = 1\nvar bar = baz\n", 3},
+		{"package stdin\n\nvar bar int\n\nfunc init() { _ = bar }\n", 3},
+		{"package stdin\n\n// Declares nothing named b-a-r.\n", 1}, // b.go refers to it
+	} {
+		stdin := filepath.Join(dir, "stdin.txt")
+		if err := ioutil.WriteFile(stdin, []byte(test.buffer), 0666); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(stdin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Stdin = f
+		if got := checker.Run([]string{"-"}, []*analysis.Analyzer{analyzer}); got != test.code {
+			t.Errorf("buffer %q: got exit code %d, want %d", test.buffer, got, test.code)
+		}
+		f.Close()
+	}
+}
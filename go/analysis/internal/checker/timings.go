@@ -0,0 +1,65 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Timings determines whether to print, at the end of the run,
+// the time spent in each analyzer and the number of packages
+// to which it was applied.
+var Timings bool
+
+// printTimings prints a table of the cumulative duration of the
+// actions of each analyzer, slowest first.
+func printTimings(w io.Writer, actions map[*action]bool) {
+	type timing struct {
+		name     string
+		packages int
+		duration time.Duration
+	}
+	byName := make(map[string]*timing)
+	var total time.Duration
+	for act := range actions {
+		if act.synthetic {
+			continue
+		}
+		t := byName[act.a.Name]
+		if t == nil {
+			t = &timing{name: act.a.Name}
+			byName[act.a.Name] = t
+		}
+		t.packages++
+		t.duration += act.duration
+		total += act.duration
+	}
+	var all []*timing
+	for _, t := range byName {
+		all = append(all, t)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].duration != all[j].duration {
+			return all[i].duration > all[j].duration
+		}
+		return all[i].name < all[j].name
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "analyzer\tpackages\ttime\t%%\t\n")
+	for _, t := range all {
+		percent := 0.0
+		if total > 0 {
+			percent = 100 * float64(t.duration) / float64(total)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.1f\t\n", t.name, t.packages, t.duration.Round(time.Microsecond), percent)
+	}
+	fmt.Fprintf(tw, "total\t\t%s\t\t\n", total.Round(time.Microsecond))
+	tw.Flush()
+}
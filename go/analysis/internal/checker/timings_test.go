@@ -0,0 +1,41 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestPrintTimings(t *testing.T) {
+	fast := &analysis.Analyzer{Name: "fast"}
+	slow := &analysis.Analyzer{Name: "slow"}
+	actions := map[*action]bool{
+		{a: fast, duration: 1 * time.Millisecond}: true,
+		{a: fast, duration: 2 * time.Millisecond}: true,
+		{a: slow, duration: 7 * time.Millisecond}: true,
+		{synthetic: true}:                         true,
+	}
+	var buf bytes.Buffer
+	printTimings(&buf, actions)
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, strings.Join(strings.Fields(line), " "))
+	}
+	want := []string{
+		"analyzer packages time %",
+		"slow 1 7ms 70.0",
+		"fast 2 3ms 30.0",
+		"total 10ms",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+// This file defines the -watch mode, in which the driver, having
+// analyzed the packages once, polls their source files for changes and
+// analyzes again those packages affected by a change: initial packages
+// that contain a changed file or depend on a package that does. Only
+// the diagnostics that have appeared since the previous analysis are
+// printed, followed by a summary of the numbers of new and resolved
+// diagnostics.
+//
+// The files are polled, rather than watched by a notification
+// mechanism, to avoid a dependency on a platform-specific library;
+// files in GOROOT are assumed not to change.
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/internal/analysisflags"
+	"golang.org/x/tools/go/packages"
+)
+
+// Watch determines whether to keep analyzing as the source files change.
+var Watch bool
+
+// watchInterval is the period at which source files are polled.
+const watchInterval = 500 * time.Millisecond
+
+// A watchState is the state of -watch mode between analyses.
+type watchState struct {
+	initial []*packages.Package        // current initial packages, in order of ID
+	mtimes  map[string]time.Time       // of each watched file and directory
+	diags   map[string]map[string]bool // printed diagnostics, by package ID
+}
+
+// watch analyzes the packages again whenever their sources change.
+// It does not return.
+func watch(initial []*packages.Package, roots []*action, analyzers []*analysis.Analyzer) {
+	allSyntax := needFacts(analyzers)
+	state := &watchState{diags: make(map[string]map[string]bool)}
+	added, _ := state.update(initial, roots)
+	fmt.Fprintf(os.Stderr, "%d diagnostics; watching %d packages for changes\n", added, len(state.initial))
+
+	for {
+		time.Sleep(watchInterval)
+		changed := state.changed()
+		if len(changed) == 0 {
+			continue
+		}
+		affected := state.affected(changed)
+		if len(affected) == 0 {
+			// The change may add a package, or import one.
+			affected = state.initial
+		}
+		var patterns []string
+		seen := make(map[string]bool)
+		for _, pkg := range affected {
+			if !seen[pkg.PkgPath] {
+				seen[pkg.PkgPath] = true
+				patterns = append(patterns, pkg.PkgPath)
+			}
+		}
+		if dbg('v') {
+			log.Printf("changed %s; reload %s", changed, patterns)
+		}
+
+		pkgs, err := load(patterns, allSyntax, nil, nil)
+		if err != nil {
+			log.Print(err)
+			if _, ok := err.(typeParseError); !ok {
+				state.snapshot() // wait for the next change
+				continue
+			}
+		}
+		roots := analyze(pkgs, analyzers, nil)
+		applyNolint(roots)
+		excludeFiles(roots)
+		if err := applyBaseline(roots); err != nil {
+			log.Print(err)
+		}
+		if Fix || FixDryRun || FixPatch != "" {
+			if err := applyFixes(roots); err != nil {
+				log.Print(err)
+			}
+		}
+		added, resolved := state.update(pkgs, roots)
+		fmt.Fprintf(os.Stderr, "%s: %d new, %d resolved diagnostics\n", strings.Join(patterns, " "), added, resolved)
+	}
+}
+
+// update records the analysis roots of the reloaded packages pkgs,
+// prints their diagnostics that were not printed before, and returns
+// the numbers of new and resolved diagnostics.
+func (state *watchState) update(pkgs []*packages.Package, roots []*action) (added, resolved int) {
+	byID := make(map[string]*packages.Package)
+	for _, pkg := range state.initial {
+		byID[pkg.ID] = pkg
+	}
+	reloaded := make(map[string]bool)
+	for _, pkg := range pkgs {
+		byID[pkg.ID] = pkg
+		reloaded[pkg.PkgPath] = true
+	}
+	// Forget the packages that no longer exist.
+	for id, pkg := range byID {
+		if reloaded[pkg.PkgPath] && !containsPkg(pkgs, id) {
+			delete(byID, id)
+			resolved += len(state.diags[id])
+			delete(state.diags, id)
+		}
+	}
+	state.initial = nil
+	for _, pkg := range byID {
+		state.initial = append(state.initial, pkg)
+	}
+	sort.Slice(state.initial, func(i, j int) bool { return state.initial[i].ID < state.initial[j].ID })
+
+	for _, root := range roots {
+		id := root.pkg.ID
+		old := state.diags[id]
+		cur := make(map[string]bool)
+		for _, act := range root.deps {
+			if act.err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", act.a.Name, act.err)
+				continue
+			}
+			if !act.isroot {
+				continue
+			}
+			for _, diag := range act.diagnostics {
+				posn := act.pkg.Fset.Position(diag.Pos)
+				k := fmt.Sprintf("%s: %s: %s", posn, act.a.Name, diag.Message)
+				if cur[k] {
+					continue // duplicate
+				}
+				cur[k] = true
+				if !old[k] && !printedElsewhere(state.diags, id, k) {
+					analysisflags.PrintPlain(act.pkg.Fset, diag)
+					added++
+				}
+			}
+		}
+		for k := range old {
+			if !cur[k] {
+				resolved++
+			}
+		}
+		state.diags[id] = cur
+	}
+
+	state.snapshot()
+	return added, resolved
+}
+
+// printedElsewhere reports whether diagnostic k belongs to a package
+// other than id, such as the test variant of the same package.
+func printedElsewhere(diags map[string]map[string]bool, id, k string) bool {
+	for other, m := range diags {
+		if other != id && m[k] {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPkg(pkgs []*packages.Package, id string) bool {
+	for _, pkg := range pkgs {
+		if pkg.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot records the modification times of the source files, and
+// their directories, of the initial packages and their dependencies.
+func (state *watchState) snapshot() {
+	goroot := filepath.Clean(runtime.GOROOT()) + string(filepath.Separator)
+	state.mtimes = make(map[string]time.Time)
+	add := func(name string) {
+		if strings.HasPrefix(name, goroot) {
+			return
+		}
+		if _, ok := state.mtimes[name]; ok {
+			return
+		}
+		if info, err := os.Stat(name); err == nil {
+			state.mtimes[name] = info.ModTime()
+		} else {
+			state.mtimes[name] = time.Time{} // missing
+		}
+	}
+	packages.Visit(state.initial, nil, func(pkg *packages.Package) {
+		for _, file := range pkg.GoFiles {
+			add(file)
+			add(filepath.Dir(file)) // to notice added files
+		}
+	})
+}
+
+// changed returns the watched files and directories that have changed
+// since the last snapshot.
+func (state *watchState) changed() []string {
+	var changed []string
+	for name, mtime := range state.mtimes {
+		var cur time.Time
+		if info, err := os.Stat(name); err == nil {
+			cur = info.ModTime()
+		}
+		if !cur.Equal(mtime) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// affected returns the initial packages that contain or depend on a
+// package that contains a changed file or directory.
+func (state *watchState) affected(changed []string) []*packages.Package {
+	isChanged := make(map[string]bool)
+	for _, name := range changed {
+		isChanged[name] = true
+	}
+	memo := make(map[*packages.Package]bool)
+	var dirty func(pkg *packages.Package) bool
+	dirty = func(pkg *packages.Package) bool {
+		if d, ok := memo[pkg]; ok {
+			return d
+		}
+		memo[pkg] = false // break cycles
+		d := false
+		for _, file := range pkg.GoFiles {
+			if isChanged[file] || isChanged[filepath.Dir(file)] {
+				d = true
+				break
+			}
+		}
+		if !d {
+			for _, imp := range pkg.Imports {
+				if dirty(imp) {
+					d = true
+					break
+				}
+			}
+		}
+		memo[pkg] = d
+		return d
+	}
+	var affected []*packages.Package
+	for _, pkg := range state.initial {
+		if dirty(pkg) {
+			affected = append(affected, pkg)
+		}
+	}
+	return affected
+}
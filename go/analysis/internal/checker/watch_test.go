@@ -0,0 +1,63 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestWatchAffected(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name string) string {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte("package "+filepath.Base(filepath.Dir(filename))+"\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	// c imports b; a is independent.
+	b := &packages.Package{ID: "b", PkgPath: "b", GoFiles: []string{file("b/b.go")}}
+	a := &packages.Package{ID: "a", PkgPath: "a", GoFiles: []string{file("a/a.go")}}
+	c := &packages.Package{ID: "c", PkgPath: "c", GoFiles: []string{file("c/c.go")},
+		Imports: map[string]*packages.Package{"b": b}}
+
+	state := &watchState{initial: []*packages.Package{a, b, c}}
+	state.snapshot()
+	if changed := state.changed(); len(changed) > 0 {
+		t.Fatalf("changed before any change: %q", changed)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(b.GoFiles[0], later, later); err != nil {
+		t.Fatal(err)
+	}
+	changed := state.changed()
+	if want := []string{b.GoFiles[0]}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %q, want %q", changed, want)
+	}
+	if got, want := state.affected(changed), []*packages.Package{b, c}; !reflect.DeepEqual(got, want) {
+		t.Errorf("affected = %v, want %v", got, want)
+	}
+
+	// A file added to a package's directory affects it.
+	state.snapshot()
+	file("a/a2.go")
+	if err := os.Chtimes(filepath.Join(dir, "a"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := state.affected(state.changed()), []*packages.Package{a}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after adding a file, affected = %v, want %v", got, want)
+	}
+}
@@ -8,8 +8,10 @@
 // analysis.Pass interface for use in analysis drivers such as "go vet"
 // and other build systems.
 //
-// The serial format is unspecified and may change, so the same version
-// of this package must be used for reading and writing serialized facts.
+// The default serial format is unspecified and may change, so the same
+// version of this package must be used for reading and writing
+// serialized facts. The alternative JSON format of EncodeJSON is
+// documented, for the benefit of tools not written in Go.
 //
 // The handling of facts in the analysis system parallels the handling
 // of type information in the compiler: during compilation of package P,
@@ -154,12 +156,27 @@ type gobFact struct {
 
 // Decode decodes all the facts relevant to the analysis of package pkg.
 // The read function reads serialized fact data from an external source
-// for one of of pkg's direct imports. The empty file is a valid
-// encoding of an empty fact set.
+// for one of of pkg's direct imports, in either encoding produced by
+// Encode or EncodeJSON. The empty file is a valid encoding of an
+// empty fact set.
 //
-// It is the caller's responsibility to call gob.Register on all
+// It is the caller's responsibility to call Register on all
 // necessary fact types.
 func Decode(pkg *types.Package, read func(packagePath string) ([]byte, error)) (*Set, error) {
+	s, _, err := decode(pkg, read, false)
+	return s, err
+}
+
+// DecodePartial is like Decode, except that if the facts of an
+// imported package cannot be read or decoded, it omits them rather
+// than failing. It returns the paths of the imported packages whose
+// facts were omitted.
+func DecodePartial(pkg *types.Package, read func(packagePath string) ([]byte, error)) (*Set, []string) {
+	s, missing, _ := decode(pkg, read, true)
+	return s, missing
+}
+
+func decode(pkg *types.Package, read func(packagePath string) ([]byte, error), partial bool) (_ *Set, missing []string, _ error) {
 	// Compute the import map for this package.
 	// See the package doc comment.
 	packages := importMap(pkg.Imports())
@@ -179,15 +196,30 @@ func Decode(pkg *types.Package, read func(packagePath string) ([]byte, error)) (
 		// Read the gob-encoded facts.
 		data, err := read(imp.Path())
 		if err != nil {
-			return nil, fmt.Errorf("in %s, can't import facts for package %q: %v",
+			if partial {
+				logf("can't import facts: %v", err)
+				missing = append(missing, imp.Path())
+				continue
+			}
+			return nil, nil, fmt.Errorf("in %s, can't import facts for package %q: %v",
 				pkg.Path(), imp.Path(), err)
 		}
 		if len(data) == 0 {
 			continue // no facts
 		}
 		var gobFacts []gobFact
-		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&gobFacts); err != nil {
-			return nil, fmt.Errorf("decoding facts for %q: %v", imp.Path(), err)
+		if bytes.HasPrefix(data, []byte(jsonHeader)) {
+			gobFacts, err = decodeJSON(data[len(jsonHeader):], logf)
+		} else {
+			err = gob.NewDecoder(bytes.NewReader(data)).Decode(&gobFacts)
+		}
+		if err != nil {
+			if partial {
+				logf("can't decode facts: %v", err)
+				missing = append(missing, imp.Path())
+				continue
+			}
+			return nil, nil, fmt.Errorf("decoding facts for %q: %v", imp.Path(), err)
 		}
 		if debug {
 			logf("decoded %d facts: %v", len(gobFacts), gobFacts)
@@ -222,7 +254,7 @@ func Decode(pkg *types.Package, read func(packagePath string) ([]byte, error)) (
 		}
 	}
 
-	return &Set{pkg: pkg, m: m}, nil
+	return &Set{pkg: pkg, m: m}, missing, nil
 }
 
 // Encode encodes a set of facts to a memory buffer.
@@ -234,7 +266,34 @@ func (s *Set) Encode() []byte {
 	// TODO(adonovan): opt: use a more efficient encoding
 	// that avoids repeating PkgPath for each fact.
 
-	// Gather all facts, including those from imported packages.
+	gobFacts := s.sorted()
+
+	var buf bytes.Buffer
+	if len(gobFacts) > 0 {
+		if err := gob.NewEncoder(&buf).Encode(gobFacts); err != nil {
+			// Fact encoding should never fail. Identify the culprit.
+			for _, gf := range gobFacts {
+				if err := gob.NewEncoder(ioutil.Discard).Encode(gf); err != nil {
+					fact := gf.Fact
+					pkgpath := reflect.TypeOf(fact).Elem().PkgPath()
+					log.Panicf("internal error: gob encoding of analysis fact %s failed: %v; please report a bug against fact %T in package %q",
+						fact, err, fact, pkgpath)
+				}
+			}
+		}
+	}
+
+	if debug {
+		log.Printf("package %q: encode %d facts, %d bytes\n",
+			s.pkg.Path(), len(gobFacts), buf.Len())
+	}
+
+	return buf.Bytes()
+}
+
+// sorted returns all the facts of the set, including those from
+// imported packages, in a deterministic order.
+func (s *Set) sorted() []gobFact {
 	var gobFacts []gobFact
 
 	s.mu.Lock()
@@ -277,28 +336,7 @@ func (s *Set) Encode() []byte {
 		}
 		return false // equal
 	})
-
-	var buf bytes.Buffer
-	if len(gobFacts) > 0 {
-		if err := gob.NewEncoder(&buf).Encode(gobFacts); err != nil {
-			// Fact encoding should never fail. Identify the culprit.
-			for _, gf := range gobFacts {
-				if err := gob.NewEncoder(ioutil.Discard).Encode(gf); err != nil {
-					fact := gf.Fact
-					pkgpath := reflect.TypeOf(fact).Elem().PkgPath()
-					log.Panicf("internal error: gob encoding of analysis fact %s failed: %v; please report a bug against fact %T in package %q",
-						fact, err, fact, pkgpath)
-				}
-			}
-		}
-	}
-
-	if debug {
-		log.Printf("package %q: encode %d facts, %d bytes\n",
-			s.pkg.Path(), len(gobFacts), buf.Len())
-	}
-
-	return buf.Bytes()
+	return gobFacts
 }
 
 // String is provided only for debugging, and must not be called
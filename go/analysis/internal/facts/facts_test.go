@@ -13,6 +13,7 @@ import (
 	"reflect"
 	"testing"
 
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/analysistest"
 	"golang.org/x/tools/go/analysis/internal/facts"
 	"golang.org/x/tools/go/packages"
@@ -382,3 +383,54 @@ func TestFactFilter(t *testing.T) {
 		t.Errorf("AllObjectFacts: got %v, want %v", got, wantObjFacts)
 	}
 }
+
+type hiddenFact struct {
+	S      string
+	hidden int
+}
+
+func (f *hiddenFact) String() string { return fmt.Sprintf("hiddenFact(%s, %d)", f.S, f.hidden) }
+func (f *hiddenFact) AFact()         {}
+
+type embeddedFact struct {
+	otherFact
+	Hidden hiddenFact `json:"-"`
+}
+
+func (f *embeddedFact) String() string { return fmt.Sprintf("embeddedFact(%s)", f.S) }
+func (f *embeddedFact) AFact()         {}
+
+func TestEncodeJSONUnexported(t *testing.T) {
+	files := map[string]string{
+		"a/a.go": `package a; type A int`,
+	}
+	dir, cleanup, err := analysistest.WriteFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	pkg, err := load(t, dir, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		fact analysis.Fact
+		ok   bool
+	}{
+		{&myFact{"exported"}, true},
+		{&embeddedFact{otherFact: otherFact{"promoted"}}, true},
+		{&hiddenFact{"unexported", 1}, false},
+	} {
+		s, err := facts.Decode(pkg, func(string) ([]byte, error) { return nil, nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.ExportPackageFact(test.fact)
+		_, err = s.EncodeJSON()
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("EncodeJSON of %v: got error %v, want error: %t", test.fact, err, !test.ok)
+		}
+	}
+}
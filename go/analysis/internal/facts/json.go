@@ -0,0 +1,165 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package facts
+
+// This file defines the JSON encoding of facts, which, unlike the
+// default gob encoding, may be read and written by drivers and fact
+// producers not written in Go. Such a file starts with jsonHeader,
+// followed by a JSON array of objects of the form
+//
+//	{"PkgPath": "fmt", "Object": "Println", "Type": "*example.com/a.fact", "Fact": {...}}
+//
+// where Object is an objectpath.Path, empty for a package fact, and
+// Type identifies a fact type registered with Register.
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+const jsonHeader = "go/analysis facts: json\n"
+
+var factTypes sync.Map // maps type name to reflect.Type of registered facts
+
+// Register registers the type of fact with both the gob and the JSON
+// encodings. It must be called for all fact types before Decode.
+func Register(fact analysis.Fact) {
+	gob.Register(fact)
+	t := reflect.TypeOf(fact)
+	factTypes.Store(typeName(t), t)
+}
+
+// typeName returns the name of a fact type in the JSON encoding,
+// for example "*golang.org/x/tools/go/analysis/passes/printf.isWrapper".
+func typeName(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr && t.Name() == "" {
+		return "*" + typeName(t.Elem())
+	}
+	if t.PkgPath() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+type jsonFact struct {
+	PkgPath string
+	Object  objectpath.Path `json:",omitempty"`
+	Type    string
+	Fact    json.RawMessage
+}
+
+// EncodeJSON is like Encode, but uses the JSON encoding. It fails if
+// the type of a fact has unexported fields, which JSON would discard.
+func (s *Set) EncodeJSON() ([]byte, error) {
+	checked := make(map[reflect.Type]bool)
+	jsonFacts := []jsonFact{} // not nil, so as to encode [] rather than null
+	for _, f := range s.sorted() {
+		if t := reflect.TypeOf(f.Fact); !checked[t] {
+			if err := checkJSON(t, make(map[reflect.Type]bool)); err != nil {
+				return nil, fmt.Errorf("analysis fact type %s cannot be JSON-encoded: %v", typeName(t), err)
+			}
+			checked[t] = true
+		}
+		data, err := json.Marshal(f.Fact)
+		if err != nil {
+			return nil, fmt.Errorf("JSON encoding of analysis fact %s failed: %v", f.Fact, err)
+		}
+		jsonFacts = append(jsonFacts, jsonFact{
+			PkgPath: f.PkgPath,
+			Object:  f.Object,
+			Type:    typeName(reflect.TypeOf(f.Fact)),
+			Fact:    data,
+		})
+	}
+	data, err := json.Marshal(jsonFacts)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(jsonHeader)
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+var jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// checkJSON returns an error if values of type t would lose an
+// unexported struct field in the JSON encoding, unless a type on the
+// way to it encodes itself.
+func checkJSON(t reflect.Type, seen map[reflect.Type]bool) error {
+	if seen[t] || t.Implements(jsonMarshaler) || reflect.PtrTo(t).Implements(jsonMarshaler) {
+		return nil
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return checkJSON(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Tag.Get("json") == "-" {
+				continue // deliberately omitted
+			}
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			// The exported fields of an embedded struct are promoted
+			// even if the struct type is unexported.
+			if field.PkgPath != "" && !(field.Anonymous && ft.Kind() == reflect.Struct) {
+				return fmt.Errorf("field %s of %s is unexported", field.Name, t)
+			}
+			if err := checkJSON(field.Type, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeJSON decodes facts in the JSON encoding, after its header.
+// Facts of types not registered in this process are discarded.
+func decodeJSON(data []byte, logf func(format string, args ...interface{})) ([]gobFact, error) {
+	var jsonFacts []jsonFact
+	if err := json.Unmarshal(data, &jsonFacts); err != nil {
+		return nil, err
+	}
+	var facts []gobFact
+	for _, jf := range jsonFacts {
+		v, ok := factTypes.Load(jf.Type)
+		if !ok {
+			logf("unregistered fact type %s; discarding fact", jf.Type)
+			continue
+		}
+		t := v.(reflect.Type)
+		var ptr reflect.Value // a pointer to a new value of type t
+		if t.Kind() == reflect.Ptr {
+			ptr = reflect.New(t.Elem())
+		} else {
+			ptr = reflect.New(t)
+		}
+		if err := json.Unmarshal(jf.Fact, ptr.Interface()); err != nil {
+			return nil, fmt.Errorf("decoding %s fact: %v", jf.Type, err)
+		}
+		fact := ptr
+		if t.Kind() != reflect.Ptr {
+			fact = ptr.Elem()
+		}
+		facts = append(facts, gobFact{
+			PkgPath: jf.PkgPath,
+			Object:  jf.Object,
+			Fact:    fact.Interface().(analysis.Fact),
+		})
+	}
+	return facts, nil
+}
@@ -5,6 +5,30 @@
 // Package multichecker defines the main function for an analysis driver
 // with several analyzers. This package makes it easy for anyone to build
 // an analysis tool containing just the analyzers they need.
+//
+// The tool reads its settings from the file named by the -config flag
+// or, by default, the nearest file named .staticanalysis.toml in the
+// working directory or one of its parents. The file may enable and
+// disable analyzers, set their flags and severity, and exclude files
+// from reporting; command-line flags take precedence over it.
+//
+// The -enable and -disable flags adjust the set of analyzers to run.
+// Each takes a comma-separated list of analyzer names, glob patterns,
+// "all", and "default" (the analyzers that would otherwise run), and
+// they are applied in command-line order, so that
+//
+//	-disable 'SA*' -enable printf
+//
+// runs the default analyzers except those whose names begin with SA,
+// plus printf.
+//
+// The -baseline flag names a file of known diagnostics, recorded by
+// -write-baseline, which are not reported, and the -sarif flag prints
+// the diagnostics as a SARIF log for code scanning services.
+//
+// The -plugin flag, which may be repeated, adds the analyzers of a Go
+// plugin, or of an external program speaking a JSON protocol on its
+// standard input and output, without recompiling the tool.
 package multichecker
 
 import (
@@ -26,16 +50,24 @@ func Main(analyzers ...*analysis.Analyzer) {
 	log.SetFlags(0)
 	log.SetPrefix(progname + ": ") // e.g. "vet: "
 
+	plugins, err := loadPlugins(pluginArgs(os.Args[1:]))
+	if err != nil {
+		log.Fatal(err)
+	}
+	analyzers = append(analyzers, plugins...)
+
 	if err := analysis.Validate(analyzers); err != nil {
 		log.Fatal(err)
 	}
 
 	checker.RegisterFlags()
+	flag.Var(pluginFlag{}, "plugin", "load additional analyzers from a Go plugin (.so) or an external analyzer program at `path`")
 
-	analyzers = analysisflags.Parse(analyzers, true)
+	analyzers = analysisflags.ParseWithConfigFile(analyzers)
+	selectPlugins(analyzers)
 
 	args := flag.Args()
-	if len(args) == 0 {
+	if len(args) == 0 && checker.AssumeFilename == "" {
 		fmt.Fprintf(os.Stderr, `%[1]s is a tool for static analysis of Go programs.
 
 Usage: %[1]s [-flag] [package]
@@ -46,7 +78,7 @@ Run '%[1]s help' for more detail,
 		os.Exit(1)
 	}
 
-	if args[0] == "help" {
+	if len(args) > 0 && args[0] == "help" {
 		analysisflags.Help(progname, analyzers, args[1:])
 		os.Exit(0)
 	}
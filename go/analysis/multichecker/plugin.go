@@ -0,0 +1,292 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package multichecker
+
+// This file defines the -plugin flag, which adds analyzers that are
+// not compiled into the multichecker. A plugin whose name ends in .so
+// is a Go plugin (see package plugin) that defines a variable
+//
+//	var Analyzers []*analysis.Analyzer
+//
+// and is loaded into the process; any other plugin is an executable
+// that speaks the following protocol.
+//
+// When run with the single argument -describe, the program prints a
+// JSON array of the analyzers it provides to its standard output:
+//
+//	[{"Name": "mycheck", "Doc": "report ..."}]
+//
+// When run with the single argument -analyze, once for each package,
+// the program reads a JSON-encoded pluginRequest from its standard
+// input and prints a JSON-encoded pluginResponse to its standard
+// output. Facts are opaque to the driver: the facts an analyzer
+// returned for each directly imported package are passed back in
+// requests for the importing package, so the facts of a package must
+// include whatever the program needs of its dependencies' facts.
+// A program that exits with a nonzero status, or whose response is
+// malformed, fails the analysis of the package.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"os"
+	"os/exec"
+	"plugin"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// A pluginRequest is the input of an external analyzer program.
+type pluginRequest struct {
+	Analyzers []string // names of the analyzers to apply
+	PkgPath   string
+	Name      string   // package name
+	GoFiles   []string // absolute names of the parsed files
+
+	// Facts holds the facts of the directly imported packages,
+	// by analyzer name and then package path.
+	Facts map[string]map[string][]byte
+}
+
+// A pluginResponse is the output of an external analyzer program.
+type pluginResponse struct {
+	Results map[string]*pluginResult // by analyzer name
+}
+
+// A pluginResult is the result of one analyzer.
+type pluginResult struct {
+	Error       string `json:",omitempty"` // if nonempty, the analysis failed
+	Diagnostics []pluginDiagnostic
+	Facts       []byte // facts about the package
+}
+
+// A pluginDiagnostic is a diagnostic whose range
+// is the bytes [Start, End) of File.
+type pluginDiagnostic struct {
+	File       string
+	Start, End int
+	Category   string `json:",omitempty"`
+	Message    string
+}
+
+// A pluginProgram is an external analyzer program.
+type pluginProgram struct {
+	path      string
+	analyzers []*analysis.Analyzer
+}
+
+// pluginFacts is the fact about each package that holds
+// the facts of all external analyzers, by analyzer name.
+type pluginFacts struct {
+	Facts map[string][]byte
+}
+
+func (*pluginFacts) AFact() {}
+
+func (f *pluginFacts) String() string { return fmt.Sprintf("pluginFacts(%d analyzers)", len(f.Facts)) }
+
+// pluginHost runs the external analyzer programs on each package.
+// Each external analyzer requires it and reports the diagnostics
+// in its result. A single analyzer holds the facts of all external
+// analyzers, as fact types cannot be created for each of them, and a
+// fact type is what orders the analysis of a package after that of
+// its dependencies.
+var pluginHost = &analysis.Analyzer{
+	Name:       "plugin",
+	Doc:        "run external analyzer programs",
+	Run:        runPlugins,
+	ResultType: reflect.TypeOf(map[string]*pluginResult(nil)),
+	FactTypes:  []analysis.Fact{new(pluginFacts)},
+}
+
+var (
+	pluginPrograms []*pluginProgram
+	pluginSelected = make(map[*analysis.Analyzer]bool) // external analyzers to run
+)
+
+// pluginArgs returns the values of the -plugin flags in args, which
+// must be known before the flags are parsed as they define flags.
+func pluginArgs(args []string) []string {
+	var plugins []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.HasPrefix(name, "plugin=") {
+			plugins = append(plugins, name[len("plugin="):])
+		} else if name == "plugin" && i+1 < len(args) {
+			i++
+			plugins = append(plugins, args[i])
+		}
+	}
+	return plugins
+}
+
+// pluginFlag is the flag.Value of -plugin,
+// whose values have already been processed.
+type pluginFlag struct{}
+
+func (pluginFlag) String() string     { return "" }
+func (pluginFlag) Set(s string) error { return nil }
+
+// loadPlugins returns the analyzers of the named plugins.
+func loadPlugins(plugins []string) ([]*analysis.Analyzer, error) {
+	var analyzers []*analysis.Analyzer
+	for _, path := range plugins {
+		if strings.HasSuffix(path, ".so") {
+			p, err := plugin.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			sym, err := p.Lookup("Analyzers")
+			if err != nil {
+				return nil, err
+			}
+			list, ok := sym.(*[]*analysis.Analyzer)
+			if !ok {
+				return nil, fmt.Errorf("%s: Analyzers is a %T, not a []*analysis.Analyzer", path, sym)
+			}
+			analyzers = append(analyzers, *list...)
+			continue
+		}
+
+		out, err := exec.Command(path, "-describe").Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s -describe: %v", path, execError(err))
+		}
+		var described []struct{ Name, Doc string }
+		if err := json.Unmarshal(out, &described); err != nil {
+			return nil, fmt.Errorf("%s -describe: invalid output: %v", path, err)
+		}
+		prog := &pluginProgram{path: path}
+		for _, d := range described {
+			a := &analysis.Analyzer{
+				Name:     d.Name,
+				Doc:      d.Doc,
+				Requires: []*analysis.Analyzer{pluginHost},
+				Run:      runExternal,
+			}
+			prog.analyzers = append(prog.analyzers, a)
+		}
+		pluginPrograms = append(pluginPrograms, prog)
+		analyzers = append(analyzers, prog.analyzers...)
+	}
+	return analyzers, nil
+}
+
+// selectPlugins records which external analyzers are to be run.
+func selectPlugins(analyzers []*analysis.Analyzer) {
+	for _, a := range analyzers {
+		pluginSelected[a] = true
+	}
+}
+
+// runPlugins runs the selected analyzers of each external program
+// on the package, and returns their results by analyzer name.
+func runPlugins(pass *analysis.Pass) (interface{}, error) {
+	facts := make(map[string]map[string][]byte)
+	for _, imp := range pass.Pkg.Imports() {
+		var f pluginFacts
+		if pass.ImportPackageFact(imp, &f) {
+			for name, data := range f.Facts {
+				if facts[name] == nil {
+					facts[name] = make(map[string][]byte)
+				}
+				facts[name][imp.Path()] = data
+			}
+		}
+	}
+	req := pluginRequest{
+		PkgPath: pass.Pkg.Path(),
+		Name:    pass.Pkg.Name(),
+		Facts:   facts,
+	}
+	for _, f := range pass.Files {
+		req.GoFiles = append(req.GoFiles, pass.Fset.File(f.Pos()).Name())
+	}
+
+	results := make(map[string]*pluginResult)
+	out := &pluginFacts{Facts: make(map[string][]byte)}
+	for _, prog := range pluginPrograms {
+		req.Analyzers = nil
+		for _, a := range prog.analyzers {
+			if pluginSelected[a] {
+				req.Analyzers = append(req.Analyzers, a.Name)
+			}
+		}
+		if req.Analyzers == nil {
+			continue
+		}
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		cmd := exec.Command(prog.path, "-analyze")
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stderr = os.Stderr
+		data, err = cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s -analyze: %v", prog.path, execError(err))
+		}
+		var resp pluginResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("%s -analyze: invalid output: %v", prog.path, err)
+		}
+		for _, name := range req.Analyzers {
+			res := resp.Results[name]
+			if res == nil {
+				res = &pluginResult{Error: fmt.Sprintf("%s returned no result", prog.path)}
+			}
+			results[name] = res
+			if res.Facts != nil {
+				out.Facts[name] = res.Facts
+			}
+		}
+	}
+	if len(out.Facts) > 0 {
+		pass.ExportPackageFact(out)
+	}
+	return results, nil
+}
+
+// runExternal reports the diagnostics of an external analyzer.
+func runExternal(pass *analysis.Pass) (interface{}, error) {
+	res := pass.ResultOf[pluginHost].(map[string]*pluginResult)[pass.Analyzer.Name]
+	if res.Error != "" {
+		return nil, fmt.Errorf("%s", res.Error)
+	}
+	files := make(map[string]*token.File)
+	for _, f := range pass.Files {
+		file := pass.Fset.File(f.Pos())
+		files[file.Name()] = file
+	}
+	for _, d := range res.Diagnostics {
+		file := files[d.File]
+		if file == nil || d.Start < 0 || d.Start > d.End || d.End > file.Size() {
+			return nil, fmt.Errorf("diagnostic %q has invalid range %s:%d-%d", d.Message, d.File, d.Start, d.End)
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:      file.Pos(d.Start),
+			End:      file.Pos(d.End),
+			Category: d.Category,
+			Message:  d.Message,
+		})
+	}
+	return nil, nil
+}
+
+// execError returns err, plus the standard error of the command if any.
+func execError(err error) error {
+	if err, ok := err.(*exec.ExitError); ok && len(err.Stderr) > 0 {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(err.Stderr))
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package multichecker_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func init() {
+	if os.Getenv("MULTICHECKER_PLUGIN") == "1" {
+		os.Exit(servePlugin(os.Args[1:]))
+	}
+}
+
+// servePlugin implements an external analyzer program providing the
+// analyzer "deprecated", which reports calls to functions whose doc
+// comment says they are deprecated. Its facts are the deprecated
+// functions of each package and its dependencies.
+func servePlugin(args []string) int {
+	switch {
+	case len(args) == 1 && args[0] == "-describe":
+		fmt.Println(`[{"Name": "deprecated", "Doc": "report calls of deprecated functions"}]`)
+		return 0
+	case len(args) == 1 && args[0] == "-analyze":
+	default:
+		fmt.Fprintf(os.Stderr, "bad arguments %q\n", args)
+		return 2
+	}
+
+	var req struct {
+		PkgPath string
+		Name    string
+		GoFiles []string
+		Facts   map[string]map[string][]byte
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// The facts are a JSON list of names such as "fmt.Println".
+	var deprecated []string
+	for _, data := range req.Facts["deprecated"] {
+		var names []string
+		json.Unmarshal(data, &names)
+		deprecated = append(deprecated, names...)
+	}
+
+	type diagnostic struct {
+		File       string
+		Start, End int
+		Message    string
+	}
+	var diags []diagnostic
+	var own []string
+	fset := token.NewFileSet()
+	for _, filename := range req.GoFiles {
+		f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, decl := range f.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && strings.Contains(decl.Doc.Text(), "Deprecated:") {
+				own = append(own, path.Base(req.PkgPath)+"."+decl.Name.Name)
+			}
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok {
+					name := x.Name + "." + sel.Sel.Name
+					for _, d := range deprecated {
+						if d == name {
+							diags = append(diags, diagnostic{
+								File:    filename,
+								Start:   fset.Position(sel.Pos()).Offset,
+								End:     fset.Position(sel.End()).Offset,
+								Message: "call of deprecated " + name,
+							})
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	facts, _ := json.Marshal(append(own, deprecated...))
+	resp := map[string]interface{}{
+		"Results": map[string]interface{}{
+			"deprecated": map[string]interface{}{"Diagnostics": diags, "Facts": facts},
+		},
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(resp); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// TestPlugin runs the multichecker of TestExitCode with an external
+// analyzer program, which is this test executable.
+func TestPlugin(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("skipping fork/exec test on this platform")
+	}
+	testenv.NeedsTool(t, "go")
+
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"b/b.go": `package b
+
+// Deprecated: use New.
+func Old() {}
+
+func New() {}
+`,
+		"a/a.go": `package a
+
+import "b"
+
+func f() {
+	b.Old()
+	b.New()
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "plugin.sh")
+	content := fmt.Sprintf("#!/bin/sh\nMULTICHECKER_PLUGIN=1 exec %q \"$@\"\n", exe)
+	if err := ioutil.WriteFile(script, []byte(content), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-test.run=TestExitCode", "--", "-plugin=" + script, "-deprecated", "a"}
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), "MULTICHECKER_CHILD=1", "GOPATH="+dir, "GO111MODULE=off", "GOPROXY=off")
+	out, err := cmd.CombinedOutput()
+	var exitcode int
+	if err, ok := err.(*exec.ExitError); ok {
+		exitcode = err.ExitCode()
+	}
+	if exitcode != 3 {
+		t.Errorf("exited %d, want 3; output:\n%s", exitcode, out)
+	}
+	want := filepath.Join(dir, "src", "a", "a.go") + ":6:2: call of deprecated b.Old"
+	if !strings.Contains(string(out), want) {
+		t.Errorf("output does not contain %q:\n%s", want, out)
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package apicompat defines an Analyzer that reports incompatible changes
+// of the exported API of a package with respect to a snapshot of it.
+package apicompat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+const Doc = `check for incompatible changes of the exported API of a package
+
+The apicompat analyzer records the exported API of each package as a
+fact: its exported constants, variables, functions and types, and the
+exported fields and methods of its types, one feature per line, such as
+
+	func Parse func(string) (*Expr, error)
+	method Expr.String (*Expr) func() string
+
+If the directory of the package holds a snapshot of its API, a file
+named by the -snapshot flag that is committed along with the package,
+the analyzer reports the features of the snapshot that the package no
+longer has or that it has changed, as users of the package may depend
+on them. Added features are compatible.
+
+The -update flag writes the API of the package to the snapshot
+instead, for example to accept an intended change.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "apicompat",
+	Doc:       Doc,
+	Run:       run,
+	FactTypes: []analysis.Fact{new(API)},
+}
+
+// flags
+var (
+	snapshot = "api.txt"
+	update   = false
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&snapshot, "snapshot", snapshot, "name of the file of the API snapshot in the directory of each package")
+	Analyzer.Flags.BoolVar(&update, "update", update, "write the API snapshot of each package instead of checking it")
+}
+
+// An API is the fact of the exported API of a package, as a sorted list
+// of features.
+type API struct{ Features []string }
+
+func (*API) AFact() {}
+
+func (api *API) String() string { return fmt.Sprintf("API(%d features)", len(api.Features)) }
+
+// A feature is an element of the API of a package.
+type feature struct {
+	key  string    // the kind and name of the feature, such as "func Parse"
+	desc string    // the rest of its description, such as its type
+	pos  token.Pos // the position of its declaration
+}
+
+func (f feature) String() string { return f.key + " " + f.desc }
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if len(pass.Files) == 0 {
+		return nil, nil
+	}
+	features := apiFeatures(pass)
+	api := &API{Features: make([]string, len(features))}
+	for i, f := range features {
+		api.Features[i] = f.String()
+	}
+	pass.ExportPackageFact(api)
+
+	filename := filepath.Join(filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name()), snapshot)
+	if update {
+		var buf bytes.Buffer
+		for _, f := range api.Features {
+			fmt.Fprintln(&buf, f)
+		}
+		return nil, os.WriteFile(filename, buf.Bytes(), 0666)
+	}
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var snap []feature
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		old, ok := parseFeature(line)
+		if !ok {
+			return nil, fmt.Errorf("%s: malformed feature %q", filename, line)
+		}
+		snap = append(snap, old)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]feature)
+	for _, f := range features {
+		current[f.key] = f
+	}
+	// The fields and methods of removed types are not reported.
+	removed := make(map[string]bool)
+	for _, old := range snap {
+		if _, ok := current[old.key]; !ok && strings.HasPrefix(old.key, "type ") {
+			removed[strings.TrimPrefix(old.key, "type ")] = true
+		}
+	}
+	for _, old := range snap {
+		f, ok := current[old.key]
+		switch {
+		case !ok:
+			if kind, name, _ := cut(old.key, " "); kind == "field" || kind == "method" {
+				if typ, _, _ := cut(name, "."); removed[typ] {
+					continue
+				}
+			}
+			pass.ReportRangef(pass.Files[0].Name, "%s was removed from the API", old.key)
+		case f.desc != old.desc:
+			pass.Reportf(f.pos, "%s changed incompatibly from %s to %s", f.key, old.desc, f.desc)
+		}
+	}
+	return nil, nil
+}
+
+// cut is strings.Cut, which is not available before Go 1.18.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// parseFeature parses the description of a feature in a snapshot.
+func parseFeature(line string) (feature, bool) {
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 {
+		return feature{}, false
+	}
+	return feature{key: fields[0] + " " + fields[1], desc: fields[2]}, true
+}
+
+// apiFeatures returns the features of the exported API of the package,
+// sorted by key. Those declared in test files are not part of it.
+func apiFeatures(pass *analysis.Pass) []feature {
+	var features []feature
+	add := func(obj types.Object, key, desc string) {
+		if !strings.HasSuffix(pass.Fset.File(obj.Pos()).Name(), "_test.go") {
+			features = append(features, feature{key, desc, obj.Pos()})
+		}
+	}
+	qual := types.RelativeTo(pass.Pkg)
+
+	// The types that aliases denote, from their declarations, as the
+	// type of an alias may be represented as the alias itself.
+	aliased := make(map[types.Object]types.Type)
+	for _, f := range pass.Files {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				if spec := spec.(*ast.TypeSpec); spec.Assign.IsValid() {
+					aliased[pass.TypesInfo.Defs[spec.Name]] = pass.TypesInfo.TypeOf(spec.Type)
+				}
+			}
+		}
+	}
+
+	scope := pass.Pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Const:
+			add(obj, "const "+name, types.TypeString(obj.Type(), qual)+" = "+obj.Val().ExactString())
+		case *types.Var:
+			add(obj, "var "+name, types.TypeString(obj.Type(), qual))
+		case *types.Func:
+			add(obj, "func "+name, signature(obj.Type().(*types.Signature), qual))
+		case *types.TypeName:
+			if obj.IsAlias() {
+				if T := aliased[obj]; T != nil {
+					add(obj, "type "+name, "= "+types.TypeString(T, qual))
+				}
+				continue
+			}
+			named, ok := obj.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			add(obj, "type "+name, typeParams(typeparams.ForNamed(named), qual)+underlying(named.Underlying(), qual))
+			if s, ok := named.Underlying().(*types.Struct); ok {
+				for i := 0; i < s.NumFields(); i++ {
+					if field := s.Field(i); field.Exported() {
+						add(field, "field "+name+"."+field.Name(), types.TypeString(field.Type(), qual))
+					}
+				}
+			}
+			if _, ok := named.Underlying().(*types.Interface); ok {
+				continue // the methods are part of the type
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				if m := named.Method(i); m.Exported() {
+					sig := m.Type().(*types.Signature)
+					recv := "(" + name + ") "
+					if _, ok := sig.Recv().Type().(*types.Pointer); ok {
+						recv = "(*" + name + ") "
+					}
+					add(m, "method "+name+"."+m.Name(), recv+signature(sig, qual))
+				}
+			}
+		}
+	}
+	sort.Slice(features, func(i, j int) bool { return features[i].key < features[j].key })
+	return features
+}
+
+// underlying returns the description of the underlying type of a
+// defined type. That of a struct is "struct", as its exported fields
+// are features of their own; that of an interface lists all of its
+// methods, as adding one breaks its implementations.
+func underlying(T types.Type, qual types.Qualifier) string {
+	switch T := T.(type) {
+	case *types.Struct:
+		return "struct"
+	case *types.Interface:
+		var elems []string
+		for i := 0; i < T.NumEmbeddeds(); i++ {
+			if _, ok := T.EmbeddedType(i).Underlying().(*types.Interface); !ok {
+				elems = append(elems, types.TypeString(T.EmbeddedType(i), qual)) // a type set
+			}
+		}
+		for i := 0; i < T.NumMethods(); i++ {
+			m := T.Method(i)
+			elems = append(elems, m.Name()+strings.TrimPrefix(signature(m.Type().(*types.Signature), qual), "func"))
+		}
+		sort.Strings(elems)
+		return "interface{" + strings.Join(elems, "; ") + "}"
+	}
+	return types.TypeString(T, qual)
+}
+
+// signature returns the description of a function signature, without
+// the names of its parameters and results, as they may change.
+func signature(sig *types.Signature, qual types.Qualifier) string {
+	tuple := func(t *types.Tuple, variadic bool) string {
+		var list []string
+		for i := 0; i < t.Len(); i++ {
+			T := t.At(i).Type()
+			if variadic && i == t.Len()-1 {
+				list = append(list, "..."+types.TypeString(T.(*types.Slice).Elem(), qual))
+				continue
+			}
+			list = append(list, types.TypeString(T, qual))
+		}
+		return strings.Join(list, ", ")
+	}
+	s := "func" + typeParams(typeparams.ForSignature(sig), qual) + "(" + tuple(sig.Params(), sig.Variadic()) + ")"
+	switch results := sig.Results(); results.Len() {
+	case 0:
+	case 1:
+		s += " " + tuple(results, false)
+	default:
+		s += " (" + tuple(results, false) + ")"
+	}
+	return s
+}
+
+// typeParams returns the description of a list of type parameters.
+func typeParams(tparams *typeparams.TypeParamList, qual types.Qualifier) string {
+	if tparams.Len() == 0 {
+		return ""
+	}
+	var list []string
+	for i := 0; i < tparams.Len(); i++ {
+		tparam := tparams.At(i)
+		list = append(list, tparam.Obj().Name()+" "+types.TypeString(tparam.Constraint(), qual))
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
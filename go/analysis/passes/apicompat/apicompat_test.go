@@ -0,0 +1,54 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package apicompat_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/passes/apicompat"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+func Test(t *testing.T) {
+	if !typeparams.Enabled {
+		t.Skip("the test package uses generics")
+	}
+	testdata := analysistest.TestData()
+	analysistest.Run(t, testdata, apicompat.Analyzer, "a")
+}
+
+func TestUpdate(t *testing.T) {
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"b/b.go": `package b // want package:"API\\(3 features\\)"
+
+type T struct{ X int }
+
+func (T) M(...string) {}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	apicompat.Analyzer.Flags.Set("update", "true")
+	defer apicompat.Analyzer.Flags.Set("update", "false")
+	analysistest.Run(t, dir, apicompat.Analyzer, "b")
+
+	got, err := os.ReadFile(filepath.Join(dir, "src", "b", "api.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `field T.X int
+method T.M (T) func(...string)
+type T struct
+`
+	if string(got) != want {
+		t.Errorf("snapshot is\n%s\nwant\n%s", got, want)
+	}
+}
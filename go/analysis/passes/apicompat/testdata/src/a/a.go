@@ -0,0 +1,42 @@
+// want package:`API\(12 features\)`
+
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the apicompat checker.
+
+package a // want `type Gone was removed from the API` `func Removed was removed from the API` `method Expr.Close was removed from the API`
+
+import "io"
+
+const Version = 2 // want `const Version changed incompatibly from untyped int = 1 to untyped int = 2`
+
+var Default Expr
+
+func Parse(s string, strict bool) (*Expr, error) { // want `func Parse changed incompatibly from func\(string\) \(\*Expr, error\) to func\(string, bool\) \(\*Expr, error\)`
+	return nil, nil
+}
+
+func Added() {}
+
+type Expr struct {
+	Op   string
+	Args []int // want `field Expr.Args changed incompatibly from \[\]Expr to \[\]int`
+	pos  int
+}
+
+func (e *Expr) String() string { return "" }
+
+func (e Expr) Pos() int { return e.pos } // want `method Expr.Pos changed incompatibly from \(\*Expr\) func\(\) int to \(Expr\) func\(\) int`
+
+type Node interface { // want `type Node changed incompatibly from interface{String\(\) string} to interface{End\(\) int; String\(\) string}`
+	String() string
+	End() int
+}
+
+type Reader = io.Reader
+
+func Map[T any](s []T, f func(T) T) []T { return s }
+
+func unexported() {}
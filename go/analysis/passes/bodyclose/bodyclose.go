@@ -0,0 +1,206 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package bodyclose defines an Analyzer that checks for failure to
+// close the body of an HTTP response.
+package bodyclose
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/analysis/passes/internal/closecheck"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const Doc = `check that the body of an HTTP response is closed
+
+The Body of the http.Response returned by http.Get, http.Post, and the
+methods of http.Client must be closed, or the underlying connection
+cannot be reused:
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+The analyzer reports a response for which there is a path through the
+control-flow graph, from the call to a return statement, that neither
+closes the Body nor passes the response, or its Body, on to code that
+may close it. Paths on which the error is not nil, or the response is
+nil, need not close it.
+
+Where it is safe to do so, the analyzer suggests a fix that defers the
+call of Close just after the check of the error.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "bodyclose",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		inspect.Analyzer,
+		ctrlflow.Analyzer,
+	},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	// Fast path: if the package doesn't import net/http,
+	// skip the traversal.
+	if !analysisutil.Imports(pass.Pkg, "net/http") {
+		return nil, nil
+	}
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	nodeTypes := []ast.Node{
+		(*ast.FuncLit)(nil),
+		(*ast.FuncDecl)(nil),
+	}
+	inspect.Preorder(nodeTypes, func(n ast.Node) {
+		runFunc(pass, n)
+	})
+	return nil, nil
+}
+
+// closer describes the uses of response variables that close their
+// Body.
+var closer = &closecheck.Closer{Harmless: harmless, IsClose: isCloseCall}
+
+func runFunc(pass *analysis.Pass, node ast.Node) {
+	isOpener := func(call *ast.CallExpr) bool { return isHTTPFuncOrMethodOnClient(pass.TypesInfo, call) }
+	discarded := func(call *ast.CallExpr) {
+		pass.ReportRangef(call, "the response returned by %s should be closed, not discarded, to avoid a resource leak", analysisutil.Format(pass.Fset, call.Fun))
+	}
+	resps := closecheck.Find(pass, node, isOpener, discarded)
+	closer.Unclosed(pass, node, resps, func(resp *closecheck.Resource, ret *ast.ReturnStmt, deferable bool) {
+		v := resp.Var
+		lineno := pass.Fset.Position(resp.Stmt.Pos()).Line
+		var fixes []analysis.SuggestedFix
+		if deferable {
+			fixes = closecheck.DeferFix(pass, resp, v.Name()+".Body.Close")
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:            resp.Stmt.Pos(),
+			End:            resp.Stmt.End(),
+			Message:        fmt.Sprintf("the %s.Body is not closed on all paths (possible resource leak)", v.Name()),
+			SuggestedFixes: fixes,
+		})
+		pass.ReportRangef(ret, "this return statement may be reached without closing the %s.Body of the response defined on line %d", v.Name(), lineno)
+	})
+}
+
+// harmless reports whether the use of the response variable at the
+// top of the stack cannot close its Body: a comparison with nil, an
+// assignment to the variable, a selection of another field or method
+// of the response, a call of a method of its Body other than Close, or
+// the use of its Body as an argument that cannot be closed, such as an
+// io.Reader.
+func harmless(pass *analysis.Pass, stack []ast.Node) bool {
+	// parent returns the parent of the expression at stack[i],
+	// skipping parentheses, and its index.
+	parent := func(i int) (ast.Node, int) {
+		for i--; i >= 0; i-- {
+			if _, ok := stack[i].(*ast.ParenExpr); !ok {
+				return stack[i], i
+			}
+		}
+		return nil, -1
+	}
+	id := stack[len(stack)-1].(*ast.Ident)
+	p, i := parent(len(stack) - 1)
+	switch p := p.(type) {
+	case *ast.BinaryExpr:
+		return p.Op == token.EQL || p.Op == token.NEQ
+	case *ast.AssignStmt:
+		for _, lhs := range p.Lhs {
+			if lhs == id {
+				return true
+			}
+		}
+		return false
+	case *ast.SelectorExpr:
+		if p.Sel.Name != "Body" {
+			return true // another field or method of the response
+		}
+	default:
+		return false
+	}
+
+	// A use of the Body.
+	body := stack[i].(*ast.SelectorExpr)
+	p, _ = parent(i)
+	switch p := p.(type) {
+	case *ast.SelectorExpr:
+		return p.Sel.Name != "Close"
+	case *ast.BinaryExpr:
+		return p.Op == token.EQL || p.Op == token.NEQ
+	case *ast.CallExpr:
+		return closecheck.OpaqueArg(pass, p, body)
+	}
+	return false
+}
+
+// isCloseCall reports whether the identifier at the top of the stack
+// is the operand of a call v.Body.Close().
+func isCloseCall(stack []ast.Node) bool {
+	if len(stack) < 4 {
+		return false
+	}
+	body, ok1 := stack[len(stack)-2].(*ast.SelectorExpr)
+	close, ok2 := stack[len(stack)-3].(*ast.SelectorExpr)
+	call, ok3 := stack[len(stack)-4].(*ast.CallExpr)
+	return ok1 && ok2 && ok3 && body.Sel.Name == "Body" && close.X == body && close.Sel.Name == "Close" && call.Fun == close
+}
+
+// isHTTPFuncOrMethodOnClient checks whether the given call expression is on
+// either a function of the net/http package or a method of http.Client that
+// returns (*http.Response, error).
+func isHTTPFuncOrMethodOnClient(info *types.Info, expr *ast.CallExpr) bool {
+	fun, _ := expr.Fun.(*ast.SelectorExpr)
+	sig, _ := info.Types[fun].Type.(*types.Signature)
+	if sig == nil {
+		return false // the call is not of the form x.f()
+	}
+
+	res := sig.Results()
+	if res.Len() != 2 {
+		return false // the function called does not return two values.
+	}
+	if ptr, ok := res.At(0).Type().(*types.Pointer); !ok || !isNamedType(ptr.Elem(), "net/http", "Response") {
+		return false // the first return type is not *http.Response.
+	}
+
+	errorType := types.Universe.Lookup("error").Type()
+	if !types.Identical(res.At(1).Type(), errorType) {
+		return false // the second return type is not error
+	}
+
+	typ := info.Types[fun.X].Type
+	if typ == nil {
+		id, ok := fun.X.(*ast.Ident)
+		return ok && id.Name == "http" // function in net/http package.
+	}
+
+	if isNamedType(typ, "net/http", "Client") {
+		return true // method on http.Client.
+	}
+	ptr, ok := typ.(*types.Pointer)
+	return ok && isNamedType(ptr.Elem(), "net/http", "Client") // method on *http.Client.
+}
+
+// isNamedType reports whether t is the named type path.name.
+func isNamedType(t types.Type, path, name string) bool {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Name() == name && obj.Pkg() != nil && obj.Pkg().Path() == path
+}
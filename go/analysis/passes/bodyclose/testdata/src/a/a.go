@@ -0,0 +1,121 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+var client = new(http.Client)
+
+func closed(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+func notClosed(url string) ([]byte, error) {
+	resp, err := http.Get(url) // want "the resp.Body is not closed on all paths"
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(resp.Body) // want "may be reached without closing the resp.Body"
+}
+
+func earlyReturn(req *http.Request, v interface{}) error {
+	resp, err := client.Do(req) // want "the resp.Body is not closed on all paths"
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil // want "may be reached without closing the resp.Body"
+	}
+	err = json.NewDecoder(resp.Body).Decode(v)
+	resp.Body.Close()
+	return err
+}
+
+func errorBranch(url string) error {
+	var resp, err = client.Head(url) // want "the resp.Body is not closed on all paths"
+	if err == nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil // want "may be reached without closing the resp.Body"
+		}
+		resp.Body.Close()
+	}
+	return err
+}
+
+func nilResponse(url string) {
+	resp, _ := http.Get(url)
+	if resp == nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+func discarded(url string) {
+	_, _ = http.Get(url)         // want "the response returned by http.Get should be closed, not discarded"
+	client.Get(url)              // want "the response returned by client.Get should be closed, not discarded"
+	var _, err = client.Get(url) // want "the response returned by client.Get should be closed, not discarded"
+	_ = err
+}
+
+func returned(url string) (*http.Response, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func passed(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	return closeBody(resp.Body)
+}
+
+func closeBody(rc io.ReadCloser) error { return rc.Close() }
+
+func deferredLiteral(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() { resp.Body.Close() }()
+	return nil
+}
+
+func loop(urls []string) {
+	for _, url := range urls {
+		resp, err := http.Get(url) // want "the resp.Body is not closed on all paths"
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+		resp.Body.Close()
+	}
+} // want "may be reached without closing the resp.Body"
+
+// No fix: the body may be closed by another goroutine.
+func goroutine(url string, ok bool) {
+	resp, err := http.Get(url) // want "the resp.Body is not closed on all paths"
+	if err != nil {
+		return
+	}
+	if ok {
+		go func() { resp.Body.Close() }()
+	}
+} // want "may be reached without closing the resp.Body"
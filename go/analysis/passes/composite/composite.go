@@ -10,8 +10,11 @@ import (
 	"fmt"
 	"go/ast"
 	"go/types"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 
+	"golang.org/x/mod/modfile"
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/passes/inspect"
 	"golang.org/x/tools/go/ast/inspector"
@@ -32,6 +35,16 @@ As an example,
 should be replaced by:
 
 	err = &net.DNSConfigError{Err: err}
+
+The analyzer suggests a fix that adds the field names, when the fields
+are exported.
+
+With the -crossmodule flag, the analyzer reports only the literals of
+struct types of other modules, as the types of the module of a package
+change along with it. It still reports the literals of types of the
+same module that have fewer values than the struct has fields, as
+happens when a field is added to the struct; the suggested fix names
+the fields of the values that the literal has, in order.
 `
 
 var Analyzer = &analysis.Analyzer{
@@ -42,10 +55,15 @@ var Analyzer = &analysis.Analyzer{
 	Run:              run,
 }
 
-var whitelist = true
+// flags
+var (
+	whitelist   = true
+	crossModule = false
+)
 
 func init() {
 	Analyzer.Flags.BoolVar(&whitelist, "whitelist", whitelist, "use composite white list; for testing only")
+	Analyzer.Flags.BoolVar(&crossModule, "crossmodule", crossModule, "only report struct types of other modules, or that gained fields")
 }
 
 // runUnkeyedLiteral checks if a composite literal is a struct literal with
@@ -53,6 +71,11 @@ func init() {
 func run(pass *analysis.Pass) (interface{}, error) {
 	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 
+	var module string
+	if crossModule {
+		module = modulePath(pass)
+	}
+
 	nodeFilter := []ast.Node{
 		(*ast.CompositeLit)(nil),
 	}
@@ -94,9 +117,18 @@ func run(pass *analysis.Pass) (interface{}, error) {
 				continue
 			}
 
+			// An unkeyed literal with fewer values than fields is
+			// not valid, but it is likely that the struct gained
+			// fields since the literal was written.
+			gained := len(cl.Elts) > 0 && len(cl.Elts) < strct.NumFields() && !hasKeys(cl)
+			if module != "" && inModule(module, typ) && !gained {
+				// allow unkeyed literals of types of the same module
+				continue
+			}
+
 			// check if the struct contains an unkeyed field
 			allKeyValue := true
-			var suggestedFixAvailable = len(cl.Elts) == strct.NumFields()
+			var suggestedFixAvailable = len(cl.Elts) == strct.NumFields() || gained
 			var missingKeys []analysis.TextEdit
 			for i, e := range cl.Elts {
 				if _, ok := e.(*ast.KeyValueExpr); !ok {
@@ -128,6 +160,9 @@ func run(pass *analysis.Pass) (interface{}, error) {
 				End:     cl.End(),
 				Message: fmt.Sprintf("%s struct literal uses unkeyed fields", typeName),
 			}
+			if gained {
+				diag.Message = fmt.Sprintf("%s struct literal uses unkeyed fields and has %d values for %d fields", typeName, len(cl.Elts), strct.NumFields())
+			}
 			if suggestedFixAvailable {
 				diag.SuggestedFixes = []analysis.SuggestedFix{{
 					Message:   "Add field names to struct literal",
@@ -141,6 +176,57 @@ func run(pass *analysis.Pass) (interface{}, error) {
 	return nil, nil
 }
 
+// hasKeys reports whether any element of the composite literal is keyed.
+func hasKeys(cl *ast.CompositeLit) bool {
+	for _, e := range cl.Elts {
+		if _, ok := e.(*ast.KeyValueExpr); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// modulePath returns the path of the module of the package, from the
+// go.mod file of the directory of its files, or "" if the package is
+// not in that module, as in GOPATH mode.
+func modulePath(pass *analysis.Pass) string {
+	if len(pass.Files) == 0 {
+		return ""
+	}
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name())
+	for {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			module := modfile.ModulePath(data)
+			if module == "" || !inPath(module, pass.Pkg.Path()) {
+				return ""
+			}
+			return module
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// inModule reports whether the named type (or pointer to one) typ is
+// declared in a package of the module.
+func inModule(module string, typ types.Type) bool {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && inPath(module, named.Obj().Pkg().Path())
+}
+
+// inPath reports whether the package path is that of a package of the
+// module path.
+func inPath(module, path string) bool {
+	return path == module || strings.HasPrefix(path, module+"/")
+}
+
 func deref(typ types.Type) types.Type {
 	for {
 		ptr, ok := typ.(*types.Pointer)
@@ -20,3 +20,12 @@ func Test(t *testing.T) {
 	}
 	analysistest.RunWithSuggestedFixes(t, testdata, composite.Analyzer, pkgs...)
 }
+
+func TestCrossModule(t *testing.T) {
+	testdata := analysistest.TestData()
+	if err := composite.Analyzer.Flags.Set("crossmodule", "true"); err != nil {
+		t.Fatal(err)
+	}
+	defer composite.Analyzer.Flags.Set("crossmodule", "false")
+	analysistest.RunWithSuggestedFixes(t, testdata, composite.Analyzer, "mod/a")
+}
@@ -0,0 +1,21 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the test for unkeyed struct literals with the
+// -crossmodule flag.
+
+package a
+
+import (
+	"go/token"
+
+	"mod/b"
+)
+
+var _ = b.Pair{1, 2}
+var _ = &b.Pair{1, 2}
+var _ = b.Triple{1, 2} // want "mod/b.Triple struct literal uses unkeyed fields and has 2 values for 3 fields"
+var _ = b.Triple{X: 1, Y: 2}
+
+var _ = token.Position{"a.go", 0, 1, 1} // want "go/token.Position struct literal uses unkeyed fields"
@@ -0,0 +1,14 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package b
+
+type Pair struct {
+	X, Y int
+}
+
+// Triple gained the field Z.
+type Triple struct {
+	X, Y, Z int
+}
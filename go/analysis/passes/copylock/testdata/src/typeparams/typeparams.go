@@ -54,3 +54,40 @@ func ApproximationError[Mutex interface {
 	M()
 }](m Mutex) { // want `passes lock by value: .*Mutex contains ~sync.Mutex`
 }
+
+// Locks are copied by values of instantiated types and by calls of
+// instantiated functions.
+
+type Box[T any] struct{ v T }
+
+type Locked[T any] struct {
+	mu sync.Mutex
+	v  T
+}
+
+func take[T any](x T) {}
+
+func TestInstantiations() {
+	var b Box[sync.Mutex]
+	c := b // want `assignment copies lock value to c: typeparams.Box\[sync.Mutex\] contains sync.Mutex`
+	_ = &c
+
+	var mu sync.Mutex
+	take(mu)             // want `call of take copies lock value: sync.Mutex`
+	take[sync.Mutex](mu) // want `call of take\[sync.Mutex\] copies lock value: sync.Mutex`
+	take(&mu)
+
+	var l Locked[int]
+	take(l) // want `call of take copies lock value: typeparams.Locked\[int\] contains sync.Mutex`
+
+	for _, x := range []Box[sync.Mutex]{} { // want `range var x copies lock: typeparams.Box\[sync.Mutex\] contains sync.Mutex`
+		_ = &x
+	}
+}
+
+func TestCoreTypes[P interface{ *sync.Mutex }, S ~[]sync.Mutex](p P, s S) {
+	x := *p // want `assignment copies lock value to x: sync.Mutex`
+	_ = &x
+	y := s[0] // want `assignment copies lock value to y: sync.Mutex`
+	_ = &y
+}
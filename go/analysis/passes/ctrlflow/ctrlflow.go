@@ -19,6 +19,7 @@ import (
 	"golang.org/x/tools/go/ast/inspector"
 	"golang.org/x/tools/go/cfg"
 	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/internal/typeparams"
 )
 
 var Analyzer = &analysis.Analyzer{
@@ -196,6 +197,7 @@ func (c *CFGs) callMayReturn(call *ast.CallExpr) (r bool) {
 	if fn == nil {
 		return true // callee not statically known; be conservative
 	}
+	fn = typeparams.OriginMethod(fn) // a method of an instantiated type
 
 	// Function or method declared in this package?
 	if di, ok := c.funcDecls[fn]; ok {
@@ -62,3 +62,8 @@ func (T[X]) method2() { // (may return)
 		funcs[0]()
 	}
 }
+
+func method3() { // want method3:"noReturn"
+	var t T[int]
+	t.method1()
+}
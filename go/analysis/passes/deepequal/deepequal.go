@@ -0,0 +1,201 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package deepequal defines an Analyzer that reports calls of
+// reflect.DeepEqual whose result is meaningless or better computed
+// otherwise.
+package deepequal
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const Doc = `check for misuses of reflect.DeepEqual
+
+The deepequal analyzer reports calls of reflect.DeepEqual on:
+
+ 1. funcs, or values of types that contain funcs, such as structs with
+    func fields: reflect.DeepEqual of non-nil funcs is always false.
+
+ 2. errors, which are better compared with errors.Is, as in
+
+	if errors.Is(err, io.EOF) { ... }
+
+ 3. values of type time.Time, which are better compared with their
+    Equal method, as reflect.DeepEqual also compares their locations
+    and monotonic clock readings.
+
+For errors and times, it suggests a fix that uses errors.Is or the
+Equal method instead.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "deepequal",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+var errorType = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.File)(nil),
+		(*ast.CallExpr)(nil),
+	}
+	var file *ast.File
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			file = n.(*ast.File)
+			return
+		}
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok || fn.FullName() != "reflect.DeepEqual" || len(call.Args) != 2 {
+			return
+		}
+		x, y := pass.TypesInfo.TypeOf(call.Args[0]), pass.TypesInfo.TypeOf(call.Args[1])
+		if x == nil || y == nil {
+			return
+		}
+
+		switch {
+		case isFunc(x) && isFunc(y):
+			pass.ReportRangef(call, "reflect.DeepEqual of funcs is false unless both are nil")
+
+		case containsFunc(x) && containsFunc(y):
+			pass.ReportRangef(call, "reflect.DeepEqual of values of type %s, which contain funcs, is false unless the funcs are nil", x)
+
+		case isError(x) && isError(y):
+			diag := analysis.Diagnostic{
+				Pos:     call.Pos(),
+				End:     call.End(),
+				Message: "use errors.Is to compare errors instead of reflect.DeepEqual",
+			}
+			if name, edits, ok := importName(pass, file, call.Pos(), "errors"); ok {
+				edits = append(edits, analysis.TextEdit{
+					Pos:     call.Pos(),
+					End:     call.End(),
+					NewText: []byte(name + ".Is(" + analysisutil.Format(pass.Fset, call.Args[0]) + ", " + analysisutil.Format(pass.Fset, call.Args[1]) + ")"),
+				})
+				diag.SuggestedFixes = []analysis.SuggestedFix{{
+					Message:   "Use errors.Is",
+					TextEdits: edits,
+				}}
+			}
+			pass.Report(diag)
+
+		case isTime(x) && isTime(y):
+			recv := analysisutil.Format(pass.Fset, call.Args[0])
+			switch analysisutil.Unparen(call.Args[0]).(type) {
+			case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.CallExpr, *ast.CompositeLit:
+			default:
+				recv = "(" + recv + ")"
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:     call.Pos(),
+				End:     call.End(),
+				Message: "use the Equal method to compare times instead of reflect.DeepEqual",
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message: "Use time.Time.Equal",
+					TextEdits: []analysis.TextEdit{{
+						Pos:     call.Pos(),
+						End:     call.End(),
+						NewText: []byte(recv + ".Equal(" + analysisutil.Format(pass.Fset, call.Args[1]) + ")"),
+					}},
+				}},
+			})
+		}
+	})
+	return nil, nil
+}
+
+// isFunc reports whether T is a func type.
+func isFunc(T types.Type) bool {
+	_, ok := T.Underlying().(*types.Signature)
+	return ok
+}
+
+// containsFunc reports whether values of type T hold funcs, as the
+// fields of a struct or the elements of an array.
+func containsFunc(T types.Type) bool {
+	switch T := T.Underlying().(type) {
+	case *types.Signature:
+		return true
+	case *types.Array:
+		return containsFunc(T.Elem())
+	case *types.Struct:
+		for i := 0; i < T.NumFields(); i++ {
+			if containsFunc(T.Field(i).Type()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isError reports whether T is an error type.
+func isError(T types.Type) bool {
+	return types.Implements(T, errorType)
+}
+
+// isTime reports whether T is time.Time.
+func isTime(T types.Type) bool {
+	named, ok := T.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Time"
+}
+
+// importName returns the name by which the file refers at pos to the
+// standard package of the given path, such as errors, and the edits
+// that import it if it does not.
+func importName(pass *analysis.Pass, file *ast.File, pos token.Pos, path string) (string, []analysis.TextEdit, bool) {
+	for _, imp := range file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != path {
+			continue
+		}
+		switch {
+		case imp.Name == nil:
+			return path, nil, true
+		case imp.Name.Name != "_" && imp.Name.Name != ".":
+			return imp.Name.Name, nil, true
+		}
+	}
+	// The name of the package must not be in use at pos.
+	if _, obj := pass.Pkg.Scope().Innermost(pos).LookupParent(path, pos); obj != nil {
+		return "", nil, false
+	}
+	quoted := strconv.Quote(path)
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			break
+		}
+		if decl.Lparen.IsValid() {
+			return path, []analysis.TextEdit{{
+				Pos:     decl.Lparen + 1,
+				End:     decl.Lparen + 1,
+				NewText: []byte("\n\t" + quoted),
+			}}, true
+		}
+	}
+	return path, []analysis.TextEdit{{
+		Pos:     file.Name.End(),
+		End:     file.Name.End(),
+		NewText: []byte("\n\nimport " + quoted),
+	}}, true
+}
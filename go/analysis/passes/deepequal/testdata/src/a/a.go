@@ -0,0 +1,43 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the deepequal checker.
+
+package a
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+type handler struct {
+	name string
+	fn   func()
+}
+
+type named struct {
+	name string
+	next *named
+}
+
+func Funcs(f, g func(), h, i handler, m, n named) {
+	_ = reflect.DeepEqual(f, g) // want "reflect.DeepEqual of funcs is false unless both are nil"
+	_ = reflect.DeepEqual(h, i) // want "reflect.DeepEqual of values of type a.handler, which contain funcs, is false unless the funcs are nil"
+	_ = reflect.DeepEqual(m, n) // OK
+}
+
+func Errors(err error) {
+	_ = reflect.DeepEqual(err, io.EOF)           // want "use errors.Is to compare errors instead of reflect.DeepEqual"
+	if !reflect.DeepEqual(err, fmt.Errorf("")) { // want "use errors.Is to compare errors instead of reflect.DeepEqual"
+	}
+	_ = reflect.DeepEqual(err, nil) // OK
+}
+
+func Times(t, u time.Time, p *time.Time) {
+	_ = reflect.DeepEqual(t, u)           // want "use the Equal method to compare times instead of reflect.DeepEqual"
+	_ = reflect.DeepEqual(*p, time.Now()) // want "use the Equal method to compare times instead of reflect.DeepEqual"
+	_ = reflect.DeepEqual(p, p)           // OK
+}
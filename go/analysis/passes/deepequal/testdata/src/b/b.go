@@ -0,0 +1,20 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the deepequal checker of files that do
+// not import errors.
+
+package b
+
+import "reflect"
+
+func Errors(err, target error) bool {
+	return reflect.DeepEqual(err, target) // want "use errors.Is to compare errors instead of reflect.DeepEqual"
+}
+
+func Shadowed(err, target error) bool {
+	errors := 0
+	_ = errors
+	return reflect.DeepEqual(err, target) // want "use errors.Is to compare errors instead of reflect.DeepEqual"
+}
@@ -0,0 +1,266 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package deprecated defines an Analyzer that reports uses of
+// deprecated identifiers of other packages.
+package deprecated
+
+import (
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+const Doc = `check for uses of deprecated identifiers
+
+The deprecated analyzer reports uses of the identifiers of other
+packages, and imports of packages, whose doc comment has a paragraph
+that begins with "Deprecated: ", such as:
+
+	// Deprecated: Use NewReader instead.
+	func OpenReader(name string) *Reader
+
+The diagnostic includes the rest of the paragraph. When it says to
+"use X instead", where X names a declaration of the same package, a
+field or method of the same type, or a declaration of a package that
+the file imports, the analyzer suggests a fix that replaces the use.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "deprecated",
+	Doc:       Doc,
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(deprecation)},
+}
+
+// A deprecation is the fact that an object or a package is
+// deprecated, with the message of its doc comment.
+type deprecation struct{ Msg string }
+
+func (*deprecation) AFact() {}
+
+func (d *deprecation) String() string { return "deprecated: " + strconv.Quote(d.Msg) }
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	exportFacts(pass)
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	nodeFilter := []ast.Node{
+		(*ast.File)(nil),
+		(*ast.ImportSpec)(nil),
+		(*ast.Ident)(nil),
+	}
+	var file *ast.File
+	inspect.WithStack(nodeFilter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.File:
+			file = n
+		case *ast.ImportSpec:
+			checkImport(pass, n)
+			return false
+		case *ast.Ident:
+			checkIdent(pass, file, n, stack)
+		}
+		return true
+	})
+	return nil, nil
+}
+
+// exportFacts exports a fact for the package, if deprecated, and for
+// each of its deprecated exported objects.
+func exportFacts(pass *analysis.Pass) {
+	export := func(id *ast.Ident, docs ...*ast.CommentGroup) {
+		if !id.IsExported() {
+			return
+		}
+		if obj := pass.TypesInfo.Defs[id]; obj != nil {
+			for _, doc := range docs {
+				if msg, ok := deprecated(doc); ok {
+					pass.ExportObjectFact(obj, &deprecation{msg})
+					return
+				}
+			}
+		}
+	}
+	// fields exports the facts of the fields or interface methods of
+	// the type expression t.
+	var fields func(t ast.Expr)
+	fields = func(t ast.Expr) {
+		var list *ast.FieldList
+		switch t := t.(type) {
+		case *ast.StructType:
+			list = t.Fields
+		case *ast.InterfaceType:
+			list = t.Methods
+		default:
+			return
+		}
+		for _, field := range list.List {
+			for _, name := range field.Names {
+				export(name, field.Doc, field.Comment)
+			}
+			fields(field.Type)
+		}
+	}
+
+	for _, f := range pass.Files {
+		if msg, ok := deprecated(f.Doc); ok {
+			pass.ExportPackageFact(&deprecation{msg})
+		}
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				export(decl.Name, decl.Doc)
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						export(spec.Name, spec.Doc, decl.Doc)
+						fields(spec.Type)
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							export(name, spec.Doc, decl.Doc)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// deprecated returns the message of the "Deprecated: " paragraph of
+// the doc comment, with its white space normalized.
+func deprecated(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, para := range strings.Split(doc.Text(), "\n\n") {
+		if strings.HasPrefix(para, "Deprecated: ") {
+			return strings.Join(strings.Fields(strings.TrimPrefix(para, "Deprecated: ")), " "), true
+		}
+	}
+	return "", false
+}
+
+func checkImport(pass *analysis.Pass, spec *ast.ImportSpec) {
+	var pkg *types.Package
+	if name, ok := pass.TypesInfo.Implicits[spec].(*types.PkgName); ok {
+		pkg = name.Imported()
+	} else if spec.Name != nil {
+		if name, ok := pass.TypesInfo.Defs[spec.Name].(*types.PkgName); ok {
+			pkg = name.Imported()
+		}
+	}
+	var fact deprecation
+	if pkg != nil && pass.ImportPackageFact(pkg, &fact) {
+		pass.Reportf(spec.Path.Pos(), "package %s is deprecated: %s", pkg.Path(), fact.Msg)
+	}
+}
+
+// checkIdent reports a use of a deprecated object of another package.
+func checkIdent(pass *analysis.Pass, file *ast.File, id *ast.Ident, stack []ast.Node) {
+	obj := pass.TypesInfo.Uses[id]
+	if obj == nil || obj.Pkg() == nil || obj.Pkg() == pass.Pkg {
+		return
+	}
+	if fn, ok := obj.(*types.Func); ok {
+		obj = typeparams.OriginMethod(fn) // a method of an instantiated type
+	}
+	var fact deprecation
+	if !pass.ImportObjectFact(obj, &fact) {
+		return
+	}
+
+	name := obj.Name()
+	var sel *ast.SelectorExpr // the selector of id, if any
+	if s, ok := stack[len(stack)-2].(*ast.SelectorExpr); ok && s.Sel == id {
+		sel = s
+		name = analysisutil.Format(pass.Fset, s)
+	}
+	diag := analysis.Diagnostic{
+		Pos:     id.Pos(),
+		End:     id.End(),
+		Message: name + " is deprecated: " + fact.Msg,
+	}
+	if repl := replacement(pass, file, obj, id, sel, fact.Msg); repl != nil {
+		diag.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "Replace " + name + " with " + string(repl.NewText),
+			TextEdits: []analysis.TextEdit{*repl},
+		}}
+	}
+	pass.Report(diag)
+}
+
+// useRx matches the suggestion of a deprecation message.
+var useRx = regexp.MustCompile(`\b[Uu]se ([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)?)(?:\(\))? instead\b`)
+
+// replacement returns the edit that replaces the use id of the
+// deprecated obj, with selector sel if any, by the suggestion of its
+// message, or nil if none can be made.
+func replacement(pass *analysis.Pass, file *ast.File, obj types.Object, id *ast.Ident, sel *ast.SelectorExpr, msg string) *analysis.TextEdit {
+	m := useRx.FindStringSubmatch(msg)
+	if m == nil {
+		return nil
+	}
+	qual, name := "", m[1]
+	if dot := strings.IndexByte(name, '.'); dot >= 0 {
+		qual, name = name[:dot], name[dot+1:]
+	}
+	// A field or method: Use OtherMethod instead.
+	if sel != nil {
+		if selection, ok := pass.TypesInfo.Selections[sel]; ok {
+			if qual != "" || name == obj.Name() {
+				return nil
+			}
+			if alt, _, _ := types.LookupFieldOrMethod(selection.Recv(), true, pass.Pkg, name); alt == nil || !alt.Exported() {
+				return nil
+			}
+			return &analysis.TextEdit{Pos: sel.Sel.Pos(), End: sel.Sel.End(), NewText: []byte(name)}
+		}
+	}
+	if obj.Parent() != obj.Pkg().Scope() {
+		return nil // not package-level
+	}
+	var use ast.Node = id // a dot-imported name
+	if sel != nil {
+		use = sel
+	}
+
+	// A declaration of the same package: Use Name or pkg.Name instead.
+	if qual == "" || qual == obj.Pkg().Name() {
+		if name == obj.Name() {
+			return nil
+		}
+		if alt := obj.Pkg().Scope().Lookup(name); alt == nil || !alt.Exported() {
+			return nil
+		}
+		return &analysis.TextEdit{Pos: id.Pos(), End: id.End(), NewText: []byte(name)}
+	}
+
+	// A declaration of another package that the file imports:
+	// Use qual.Name instead.
+	for _, imp := range file.Imports {
+		pkgName, ok := pass.TypesInfo.Implicits[imp].(*types.PkgName)
+		if imp.Name != nil {
+			pkgName, ok = pass.TypesInfo.Defs[imp.Name].(*types.PkgName)
+		}
+		if ok && pkgName.Name() == qual {
+			if alt := pkgName.Imported().Scope().Lookup(name); alt != nil && alt.Exported() {
+				return &analysis.TextEdit{Pos: use.Pos(), End: use.End(), NewText: []byte(qual + "." + name)}
+			}
+		}
+	}
+	return nil
+}
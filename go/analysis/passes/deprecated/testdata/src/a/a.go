@@ -0,0 +1,20 @@
+package a
+
+import (
+	"io"
+
+	"b"
+	"c" // want `package c is deprecated: Use package b instead.`
+)
+
+func f(w io.Writer) {
+	b.OpenReader("x")           // want `b.OpenReader is deprecated: Use NewReader instead.`
+	b.Copy(w, b.NewReader("y")) // want `b.Copy is deprecated: As of version 2, use io.Copy instead; it is faster.`
+	_ = b.Unsafe                // want `b.Unsafe is deprecated: Unsafe.`
+	_ = b.A + b.B               // want `b.A is deprecated` `b.B is deprecated`
+	var t b.T
+	_ = t.Label // want `t.Label is deprecated: Use Name instead.`
+	t.Stop()    // want `t.Stop is deprecated: Use Close instead.`
+	b.Gone()    // want `b.Gone is deprecated: Use nothing instead.`
+	c.F()
+}
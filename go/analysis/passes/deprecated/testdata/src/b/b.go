@@ -0,0 +1,45 @@
+package b
+
+import "io"
+
+// Deprecated: Use NewReader instead.
+func OpenReader(name string) io.Reader { return nil } // want OpenReader:`deprecated: "Use NewReader instead."`
+
+func NewReader(name string) io.Reader { return nil }
+
+// Copy copies.
+//
+// Deprecated: As of version 2, use io.Copy instead; it is
+// faster.
+func Copy(dst io.Writer, src io.Reader) (int64, error) { return 0, nil } // want Copy:`deprecated: "As of version 2, use io.Copy instead; it is faster."`
+
+// Deprecated: Unsafe.
+var Unsafe bool // want Unsafe:`deprecated: "Unsafe."`
+
+// Deprecated: Use these no more.
+const (
+	A = iota // want A:`deprecated: "Use these no more."`
+	B        // want B:`deprecated: "Use these no more."`
+)
+
+type T struct {
+	// Deprecated: Use Name instead.
+	Label string // want Label:`deprecated: "Use Name instead."`
+	Name  string
+}
+
+// Deprecated: Use Close instead.
+func (T) Stop() {} // want Stop:`deprecated: "Use Close instead."`
+
+func (T) Close() {}
+
+// Deprecated: Use nothing instead.
+func Gone() {} // want Gone:`deprecated: "Use nothing instead."`
+
+// Deprecated: for internal use.
+func unexported() {}
+
+func use() {
+	OpenReader("x") // not reported in the same package
+	unexported()
+}
@@ -0,0 +1,8 @@
+// want package:`deprecated: "Use package b instead."`
+
+// Package c is old.
+//
+// Deprecated: Use package b instead.
+package c
+
+func F() {}
@@ -0,0 +1,8 @@
+package lib
+
+type G[E any] struct{}
+
+// Deprecated: Use Close instead.
+func (G[E]) Stop() {} // want Stop:`deprecated: "Use Close instead."`
+
+func (G[E]) Close() {}
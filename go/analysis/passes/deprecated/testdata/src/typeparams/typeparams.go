@@ -0,0 +1,9 @@
+package typeparams
+
+import "typeparams/lib"
+
+func _() {
+	var g lib.G[int]
+	g.Stop()               // want `g.Stop is deprecated: Use Close instead.`
+	lib.G[string]{}.Stop() // want `lib.G\[string\]{}.Stop is deprecated: Use Close instead.`
+}
@@ -0,0 +1,295 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package embeddirective defines an Analyzer that checks //go:embed
+// directives.
+package embeddirective
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check //go:embed directives
+
+The embeddirective analyzer reports //go:embed directives that
+
+ - do not immediately precede the declaration of a single package-level
+   variable without an initializer;
+ - are in a file that does not import "embed";
+ - apply to a variable whose type is not string, []byte or embed.FS, or
+   to a variable of type string or []byte with more than one file;
+ - have a pattern that is malformed or matches no file that may be
+   embedded.
+
+Diagnostics are reported at the directive.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:             "embeddirective",
+	Doc:              Doc,
+	Run:              run,
+	RunDespiteErrors: true, // the go command reports embed errors as package errors
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		var directives []*ast.Comment
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				if isDirective(c.Text) {
+					directives = append(directives, c)
+				}
+			}
+		}
+		if len(directives) == 0 {
+			continue
+		}
+		if !imports(f, "embed") {
+			for _, c := range directives {
+				pass.ReportRangef(c, `go:embed only allowed in Go files that import "embed"`)
+			}
+		}
+		dir := filepath.Dir(pass.Fset.File(f.Pos()).Name())
+		for _, c := range directives {
+			checkDirective(pass, f, dir, c)
+		}
+	}
+	return nil, nil
+}
+
+// isDirective reports whether the comment is a //go:embed directive.
+func isDirective(text string) bool {
+	const prefix = "//go:embed"
+	return strings.HasPrefix(text, prefix) &&
+		(len(text) == len(prefix) || text[len(prefix)] == ' ' || text[len(prefix)] == '\t')
+}
+
+// imports reports whether the file imports the package of the path.
+func imports(f *ast.File, path string) bool {
+	for _, imp := range f.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil && p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func checkDirective(pass *analysis.Pass, f *ast.File, dir string, c *ast.Comment) {
+	spec, ok := declaredBy(f, c)
+	if !ok {
+		pass.ReportRangef(c, "misplaced go:embed directive")
+		return
+	}
+	if len(spec.Names) != 1 {
+		pass.ReportRangef(c, "go:embed cannot apply to multiple vars")
+		return
+	}
+	if spec.Values != nil {
+		pass.ReportRangef(c, "go:embed cannot apply to var with initializer")
+		return
+	}
+
+	patterns, err := parsePatterns(c.Text[len("//go:embed"):])
+	if err != nil {
+		pass.ReportRangef(c, "invalid go:embed: %v", err)
+		return
+	}
+	if len(patterns) == 0 {
+		pass.ReportRangef(c, "usage: //go:embed pattern...")
+		return
+	}
+
+	var files int
+	for _, pattern := range patterns {
+		n, err := matchFiles(dir, pattern)
+		if err != nil {
+			pass.ReportRangef(c, "pattern %s: %v", pattern, err)
+			return
+		}
+		files += n
+	}
+
+	obj := pass.TypesInfo.Defs[spec.Names[0]]
+	if obj == nil {
+		return
+	}
+	switch kind(obj.Type()) {
+	case embedFS:
+	case embedFile:
+		if len(patterns) > 1 || files > 1 {
+			pass.ReportRangef(c, "invalid go:embed: multiple files for type %s", obj.Type())
+		}
+	default:
+		pass.ReportRangef(c, "go:embed cannot apply to var of type %s", obj.Type())
+	}
+}
+
+// declaredBy returns the declaration of package-level variables that
+// the directive c precedes, with only blank lines and comments in
+// between.
+func declaredBy(f *ast.File, c *ast.Comment) (*ast.ValueSpec, bool) {
+	for _, decl := range f.Decls {
+		if decl.End() <= c.Pos() {
+			continue
+		}
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.VAR {
+			return nil, false
+		}
+		if decl.Pos() > c.Pos() {
+			// The directive precedes the declaration.
+			if decl.Lparen.IsValid() {
+				return nil, false
+			}
+			return decl.Specs[0].(*ast.ValueSpec), true
+		}
+		// The directive is within a parenthesized declaration.
+		for _, spec := range decl.Specs {
+			if spec.Pos() > c.Pos() {
+				return spec.(*ast.ValueSpec), true
+			}
+			if spec.End() > c.Pos() {
+				return nil, false
+			}
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// parsePatterns returns the patterns of the arguments of a //go:embed
+// directive, which are separated by spaces and may be quoted.
+func parsePatterns(args string) ([]string, error) {
+	var patterns []string
+	for {
+		args = strings.TrimLeft(args, " \t")
+		if args == "" {
+			return patterns, nil
+		}
+		var pattern string
+		switch args[0] {
+		case '"', '`':
+			quoted, err := strconv.QuotedPrefix(args)
+			if err != nil {
+				return nil, err
+			}
+			pattern, _ = strconv.Unquote(quoted)
+			args = args[len(quoted):]
+			if args != "" && args[0] != ' ' && args[0] != '\t' {
+				return nil, strconv.ErrSyntax
+			}
+		default:
+			i := strings.IndexAny(args, " \t")
+			if i < 0 {
+				i = len(args)
+			}
+			pattern, args = args[:i], args[i:]
+		}
+		patterns = append(patterns, pattern)
+	}
+}
+
+// errPattern and errNoMatch are the errors of malformed patterns and
+// of patterns that match nothing.
+var (
+	errPattern = errors.New("invalid pattern syntax")
+	errNoMatch = errors.New("no matching files found")
+)
+
+// matchFiles returns the number of files in dir that the pattern
+// embeds, which must not be zero.
+func matchFiles(dir, pattern string) (int, error) {
+	all := strings.HasPrefix(pattern, "all:")
+	pattern = strings.TrimPrefix(pattern, "all:")
+	if !validPattern(pattern) {
+		return 0, errPattern
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return 0, errPattern
+	}
+	// Files of directories are embedded unless hidden, as are the
+	// files that the pattern names.
+	hidden := func(name string) bool {
+		return !all && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_"))
+	}
+	var n int
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			n++
+			continue
+		}
+		filepath.Walk(match, func(name string, info os.FileInfo, err error) error {
+			if err != nil || name == match {
+				return nil
+			}
+			if hidden(info.Name()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.Mode().IsRegular() {
+				n++
+			}
+			return nil
+		})
+	}
+	if n == 0 {
+		return 0, errNoMatch
+	}
+	return n, nil
+}
+
+// validPattern reports whether the pattern is a well-formed, unrooted
+// path pattern without . or .. elements.
+func validPattern(pattern string) bool {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return false
+	}
+	if pattern == "" || strings.HasPrefix(pattern, "/") || strings.HasSuffix(pattern, "/") {
+		return false
+	}
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	embedInvalid = iota
+	embedFile    // string or []byte
+	embedFS      // embed.FS
+)
+
+// kind returns the kind of content that may be embedded in a variable
+// of type T.
+func kind(T types.Type) int {
+	if named, ok := T.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == "embed" && obj.Name() == "FS" {
+			return embedFS
+		}
+		return embedInvalid
+	}
+	if types.Identical(T, types.Typ[types.String]) || types.Identical(T, types.NewSlice(types.Typ[types.Byte])) {
+		return embedFile
+	}
+	return embedInvalid
+}
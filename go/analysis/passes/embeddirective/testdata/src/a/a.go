@@ -0,0 +1,74 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the embeddirective checker.
+
+package a
+
+import "embed"
+
+//go:embed hello.txt
+var hello string
+
+//go:embed hello.txt
+var helloBytes []byte
+
+//go:embed *.txt static
+var files embed.FS
+
+//go:embed "hello.txt" `world.txt`
+var quoted embed.FS
+
+//go:embed all:static
+var all embed.FS
+
+var (
+	//go:embed hello.txt
+	inBlock string
+)
+
+// want +1 `pattern missing.txt: no matching files found`
+//go:embed missing.txt
+var missing string
+
+// want +1 `pattern _hidden: no matching files found`
+//go:embed _hidden
+var hidden embed.FS
+
+// want +1 `pattern ../a.go: invalid pattern syntax`
+//go:embed ../a.go
+var outside string
+
+// want +1 `invalid go:embed: multiple files for type string`
+//go:embed *.txt
+var many string
+
+// want +1 `go:embed cannot apply to var of type int`
+//go:embed hello.txt
+var wrongType int
+
+// want +1 `go:embed cannot apply to multiple vars`
+//go:embed hello.txt
+var x, y string
+
+// want +1 `go:embed cannot apply to var with initializer`
+//go:embed hello.txt
+var initialized = "hello"
+
+// want +1 `misplaced go:embed directive`
+//go:embed hello.txt
+
+func f() {
+	// want +1 `misplaced go:embed directive`
+	//go:embed hello.txt
+	var local string
+	_ = local
+}
+
+// want +1 `usage: //go:embed pattern...`
+//go:embed
+var none string
+
+//go:embedded is not a directive
+var notDirective string
@@ -0,0 +1,9 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+// want +1 `go:embed only allowed in Go files that import "embed"`
+//go:embed hello.txt
+var noImport string
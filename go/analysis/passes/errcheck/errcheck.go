@@ -0,0 +1,287 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package errcheck defines an Analyzer that reports calls
+// whose error result is discarded.
+package errcheck
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+const Doc = `check for unchecked errors
+
+The errcheck analyzer reports calls of functions and methods that
+return an error when the error is discarded, either because the call
+is a statement by itself or because the error is assigned to the blank
+identifier:
+
+	f.Close()     // error returned by f.Close is not checked
+	_ = f.Close() // error returned by f.Close is assigned to _
+
+Deferred calls and calls in go statements are not reported.
+
+Calls of functions whose errors are commonly ignored, such as
+fmt.Println and (*bytes.Buffer).Write, are not reported; the -exclude
+flag changes this set. Nor are calls of functions that only return nil
+errors, such as methods that satisfy an interface but cannot fail:
+those of other packages are known from facts.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "errcheck",
+	Doc:       Doc,
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(noError)},
+}
+
+// flags
+var exclude stringSetFlag
+
+func init() {
+	exclude.Set("fmt.Print,fmt.Printf,fmt.Println," +
+		"(*bytes.Buffer).Write,(*bytes.Buffer).WriteByte,(*bytes.Buffer).WriteRune,(*bytes.Buffer).WriteString," +
+		"(*strings.Builder).Write,(*strings.Builder).WriteByte,(*strings.Builder).WriteRune,(*strings.Builder).WriteString," +
+		"math/rand.Read,(*math/rand.Rand).Read")
+	Analyzer.Flags.Var(&exclude, "exclude",
+		"comma-separated list of functions whose errors need not be checked, such as (*bytes.Buffer).Write or example.com/assert.True")
+}
+
+// noError is the fact that a function returns only nil errors.
+type noError struct{}
+
+func (*noError) AFact() {}
+
+func (*noError) String() string { return "noError" }
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	// Find the functions of this package that return only nil errors.
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(n ast.Node) {
+		decl := n.(*ast.FuncDecl)
+		fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func)
+		if ok && decl.Body != nil && returnsNilErrors(pass.TypesInfo, decl.Body, fn.Type().(*types.Signature)) {
+			pass.ExportObjectFact(fn, new(noError))
+		}
+	})
+
+	nodeFilter := []ast.Node{
+		(*ast.ExprStmt)(nil),
+		(*ast.AssignStmt)(nil),
+	}
+	inspect.WithStack(nodeFilter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		switch stmt := n.(type) {
+		case *ast.ExprStmt:
+			call, ok := analysisutil.Unparen(stmt.X).(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if is, ok := unchecked(pass, call); ok && len(is) > 0 {
+				pass.Report(analysis.Diagnostic{
+					Pos:            call.Pos(),
+					End:            call.End(),
+					Message:        fmt.Sprintf("error returned by %s is not checked", analysisutil.Format(pass.Fset, analysisutil.Unparen(call.Fun))),
+					SuggestedFixes: checkFix(pass, stmt, call, is, stack),
+				})
+			}
+
+		case *ast.AssignStmt:
+			if len(stmt.Rhs) == 1 && len(stmt.Lhs) > 1 {
+				// x, _ = f()
+				call, ok := analysisutil.Unparen(stmt.Rhs[0]).(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if is, ok := unchecked(pass, call); ok {
+					for _, i := range is {
+						if isBlank(stmt.Lhs[i]) {
+							pass.ReportRangef(call, "error returned by %s is assigned to _", analysisutil.Format(pass.Fset, analysisutil.Unparen(call.Fun)))
+							break
+						}
+					}
+				}
+				return true
+			}
+			// _ = f(); _, _ = f(), g()
+			for i, rhs := range stmt.Rhs {
+				call, ok := analysisutil.Unparen(rhs).(*ast.CallExpr)
+				if !ok || i >= len(stmt.Lhs) || !isBlank(stmt.Lhs[i]) {
+					continue
+				}
+				if is, ok := unchecked(pass, call); ok && len(is) > 0 {
+					var fixes []analysis.SuggestedFix
+					if len(stmt.Lhs) == 1 {
+						fixes = checkFix(pass, stmt, call, is, stack)
+					}
+					pass.Report(analysis.Diagnostic{
+						Pos:            call.Pos(),
+						End:            call.End(),
+						Message:        fmt.Sprintf("error returned by %s is assigned to _", analysisutil.Format(pass.Fset, analysisutil.Unparen(call.Fun))),
+						SuggestedFixes: fixes,
+					})
+				}
+			}
+		}
+		return true
+	})
+	return nil, nil
+}
+
+// unchecked returns the indices of the error results of the call,
+// and whether they should be checked at all.
+func unchecked(pass *analysis.Pass, call *ast.CallExpr) ([]int, bool) {
+	if tv, ok := pass.TypesInfo.Types[call.Fun]; !ok || tv.IsType() || tv.IsBuiltin() {
+		return nil, false // a conversion or a call of a builtin
+	}
+	if fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func); ok {
+		fn = typeparams.OriginMethod(fn) // a method of an instantiated type
+		if exclude[fn.FullName()] || pass.ImportObjectFact(fn, new(noError)) {
+			return nil, false
+		}
+	}
+	var is []int
+	switch t := pass.TypesInfo.TypeOf(call).(type) {
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if types.Identical(t.At(i).Type(), errorType) {
+				is = append(is, i)
+			}
+		}
+	default:
+		if t != nil && types.Identical(t, errorType) {
+			is = append(is, 0)
+		}
+	}
+	return is, true
+}
+
+// returnsNilErrors reports whether the function body returns only
+// nil as the error results of the signature, if there are any.
+func returnsNilErrors(info *types.Info, body *ast.BlockStmt, sig *types.Signature) bool {
+	var is []int
+	for i := 0; i < sig.Results().Len(); i++ {
+		if types.Identical(sig.Results().At(i).Type(), errorType) {
+			is = append(is, i)
+		}
+	}
+	if is == nil {
+		return false
+	}
+	nilErrors := true
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false // the returns of another function
+		case *ast.ReturnStmt:
+			if len(n.Results) != sig.Results().Len() {
+				nilErrors = false // a naked return, or return f()
+				return false
+			}
+			for _, i := range is {
+				if !info.Types[n.Results[i]].IsNil() {
+					nilErrors = false
+				}
+			}
+		}
+		return nilErrors
+	})
+	return nilErrors
+}
+
+// checkFix returns a fix that replaces stmt, which discards the error
+// result of call, with an if statement that checks it, if there is a
+// single error result and stmt is a statement of a block, where an if
+// statement may replace it.
+func checkFix(pass *analysis.Pass, stmt ast.Stmt, call *ast.CallExpr, is []int, stack []ast.Node) []analysis.SuggestedFix {
+	if len(is) != 1 || analysisutil.StatementList(stack) == nil {
+		return nil
+	}
+	vars := []string{"err"}
+	if t, ok := pass.TypesInfo.TypeOf(call).(*types.Tuple); ok {
+		vars = make([]string, t.Len())
+		for i := range vars {
+			vars[i] = "_"
+		}
+		vars[is[0]] = "err"
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, pass.Fset, call); err != nil {
+		return nil
+	}
+
+	// In a function whose only result is an error, return it.
+	handle := "// TODO: handle the error."
+	var sig *types.Signature
+	for i := len(stack) - 1; i >= 0 && sig == nil; i-- {
+		switch f := stack[i].(type) {
+		case *ast.FuncDecl:
+			if fn, ok := pass.TypesInfo.Defs[f.Name].(*types.Func); ok {
+				sig = fn.Type().(*types.Signature)
+			}
+		case *ast.FuncLit:
+			sig, _ = pass.TypesInfo.TypeOf(f).(*types.Signature)
+		}
+	}
+	if sig != nil && sig.Results().Len() == 1 && types.Identical(sig.Results().At(0).Type(), errorType) {
+		handle = "return err"
+	}
+
+	text := fmt.Sprintf("if %s := %s; err != nil {\n%s\n}", strings.Join(vars, ", "), buf.String(), handle)
+	return []analysis.SuggestedFix{{
+		Message: "Check the error",
+		TextEdits: []analysis.TextEdit{{
+			Pos:     stmt.Pos(),
+			End:     stmt.End(),
+			NewText: []byte(text),
+		}},
+	}}
+}
+
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+type stringSetFlag map[string]bool
+
+func (ss *stringSetFlag) String() string {
+	var items []string
+	for item := range *ss {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (ss *stringSetFlag) Set(s string) error {
+	m := make(map[string]bool) // clobber previous value
+	if s != "" {
+		for _, name := range strings.Split(s, ",") {
+			if name != "" {
+				m[name] = true
+			}
+		}
+	}
+	*ss = m
+	return nil
+}
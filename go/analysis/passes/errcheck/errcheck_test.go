@@ -2,21 +2,21 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package embeddirective
+package errcheck_test
 
 import (
 	"testing"
 
 	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/passes/errcheck"
 	"golang.org/x/tools/internal/typeparams"
 )
 
 func Test(t *testing.T) {
 	testdata := analysistest.TestData()
-	tests := []string{"a"}
+	pkgs := []string{"a"}
 	if typeparams.Enabled {
-		tests = append(tests)
+		pkgs = append(pkgs, "typeparams")
 	}
-
-	analysistest.RunWithSuggestedFixes(t, testdata, Analyzer, tests...)
+	analysistest.RunWithSuggestedFixes(t, testdata, errcheck.Analyzer, pkgs...)
 }
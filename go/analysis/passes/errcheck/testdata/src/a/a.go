@@ -0,0 +1,72 @@
+package a
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"b"
+)
+
+func local() error { return os.ErrNotExist }
+
+func localNil() error { return nil } // want localNil:"noError"
+
+func stmts() {
+	b.Fails() // want `error returned by b.Fails is not checked`
+	b.NeverFails()
+	b.Assert(true)
+	b.Pair() // want `error returned by b.Pair is not checked`
+	local()  // want `error returned by local is not checked`
+	localNil()
+	(local)() // want `error returned by local is not checked`
+	f := local
+	f() // want `error returned by f is not checked`
+
+	fmt.Println("ok")
+	var buf bytes.Buffer
+	buf.WriteString("ok")
+	fmt.Fprintln(os.Stderr, "failed") // want `error returned by fmt.Fprintln is not checked`
+	_ = error(nil)
+
+	defer local()
+	go local()
+}
+
+func blanks() {
+	_ = b.Fails()       // want `error returned by b.Fails is assigned to _`
+	x, _ := b.Pair()    // want `error returned by b.Pair is assigned to _`
+	_, _ = b.Fails(), x // want `error returned by b.Fails is assigned to _`
+	_, err := b.Pair()
+	_ = err
+	_ = b.NeverFails()
+}
+
+func returns() error {
+	b.Fails() // want `error returned by b.Fails is not checked`
+	return local()
+}
+
+func literal() {
+	_ = func() error {
+		_ = b.Fails() // want `error returned by b.Fails is assigned to _`
+		return nil
+	}
+}
+
+func inits(n int) {
+	// A statement that is not in a block cannot be replaced by an
+	// if statement.
+	if local(); n > 0 { // want `error returned by local is not checked`
+	}
+	for local(); n > 0; { // want `error returned by local is not checked`
+		n--
+	}
+	for ; n > 0; local() { // want `error returned by local is not checked`
+		n--
+	}
+	switch local(); n { // want `error returned by local is not checked`
+	case 0:
+		local() // want `error returned by local is not checked`
+	}
+}
@@ -0,0 +1,26 @@
+package b
+
+import "errors"
+
+// Fails may return a non-nil error.
+func Fails() error {
+	return errors.New("failed")
+}
+
+// NeverFails satisfies an interface, but returns only nil errors.
+func NeverFails() error {
+	return nil
+}
+
+// Assert panics instead of returning an error.
+func Assert(ok bool) error {
+	if !ok {
+		panic("assertion failed")
+	}
+	return nil
+}
+
+// Pair returns a value and an error.
+func Pair() (int, error) {
+	return 0, Fails()
+}
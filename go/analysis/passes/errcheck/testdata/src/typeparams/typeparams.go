@@ -0,0 +1,24 @@
+package typeparams
+
+type box[T any] struct{ v T }
+
+func (b *box[T]) set(v T) error { // want set:"noError"
+	b.v = v
+	return nil
+}
+
+func (b *box[T]) check() error { return nil } // want check:"noError"
+
+func get[T any](b *box[T]) (T, error) { // want get:"noError"
+	return b.v, nil
+}
+
+func failing[T any]() error { return nil } // want failing:"noError"
+
+func _() {
+	var b box[int]
+	b.set(1)
+	b.check()
+	get(&b)
+	failing[string]()
+}
@@ -0,0 +1,233 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package exhaustive defines an Analyzer that reports switch statements
+// on enum types that do not handle every constant of the type.
+package exhaustive
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+const Doc = `check for switch statements that do not handle every constant of an enum type
+
+An enum type is a defined type whose underlying type is an integer or
+a string, such as
+
+	type Color int
+
+	const (
+		Red Color = iota
+		Green
+		Blue
+	)
+
+whose package declares at least two constants of the type. The
+exhaustive analyzer reports a switch statement on an enum type that
+has no default clause and does not handle each value of the constants
+of the type, and suggests a fix that adds a case clause for them.
+Constants of other packages that are not exported need not be handled.
+Switches with case expressions that are not constant are not checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "exhaustive",
+	Doc:       Doc,
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(enum)},
+}
+
+// An enum is the fact that a type is an enum type, with its constants
+// in the order of declaration.
+type enum struct{ Members []member }
+
+// A member is a constant of an enum type.
+type member struct {
+	Name  string
+	Value string // the exact string of its value
+}
+
+func (*enum) AFact() {}
+
+func (e *enum) String() string {
+	names := make([]string, len(e.Members))
+	for i, m := range e.Members {
+		names[i] = m.Name
+	}
+	return "enum(" + strings.Join(names, ", ") + ")"
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	exportFacts(pass)
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	nodeFilter := []ast.Node{
+		(*ast.File)(nil),
+		(*ast.SwitchStmt)(nil),
+	}
+	var file *ast.File
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.File:
+			file = n
+		case *ast.SwitchStmt:
+			checkSwitch(pass, file, n)
+		}
+	})
+	return nil, nil
+}
+
+// exportFacts exports an enum fact for each enum type of the package.
+func exportFacts(pass *analysis.Pass) {
+	enums := make(map[*types.TypeName]*enum)
+	var order []*types.TypeName
+	scope := pass.Pkg.Scope()
+	for _, f := range pass.Files {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					c, ok := pass.TypesInfo.Defs[name].(*types.Const)
+					if !ok || c.Parent() != scope {
+						continue
+					}
+					tname := enumType(c.Type())
+					if tname == nil || tname.Pkg() != pass.Pkg {
+						continue
+					}
+					e := enums[tname]
+					if e == nil {
+						e = new(enum)
+						enums[tname] = e
+						order = append(order, tname)
+					}
+					e.Members = append(e.Members, member{c.Name(), c.Val().ExactString()})
+				}
+			}
+		}
+	}
+	for _, tname := range order {
+		if e := enums[tname]; len(e.Members) >= 2 {
+			pass.ExportObjectFact(tname, e)
+		}
+	}
+}
+
+// enumType returns the name of T if it may be an enum type: a defined,
+// non-generic type whose underlying type is an integer or a string.
+func enumType(T types.Type) *types.TypeName {
+	named, ok := T.(*types.Named)
+	if !ok || typeparams.NamedTypeArgs(named).Len() > 0 {
+		return nil
+	}
+	basic, ok := named.Underlying().(*types.Basic)
+	if !ok || basic.Info()&(types.IsInteger|types.IsString) == 0 {
+		return nil
+	}
+	return named.Obj()
+}
+
+func checkSwitch(pass *analysis.Pass, file *ast.File, stmt *ast.SwitchStmt) {
+	if stmt.Tag == nil {
+		return
+	}
+	tname := enumType(pass.TypesInfo.TypeOf(stmt.Tag))
+	if tname == nil {
+		return
+	}
+	var fact enum
+	if !pass.ImportObjectFact(tname, &fact) {
+		return
+	}
+
+	handled := make(map[string]bool)
+	for _, clause := range stmt.Body.List {
+		clause := clause.(*ast.CaseClause)
+		if clause.List == nil {
+			return // default
+		}
+		for _, expr := range clause.List {
+			v := pass.TypesInfo.Types[expr].Value
+			if v == nil {
+				return // not constant
+			}
+			handled[v.ExactString()] = true
+		}
+	}
+
+	// The names of the missing constants, one per value.
+	var missing []string
+	for _, m := range fact.Members {
+		if handled[m.Value] || tname.Pkg() != pass.Pkg && !token.IsExported(m.Name) {
+			continue
+		}
+		handled[m.Value] = true
+		missing = append(missing, m.Name)
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	qual, ok := qualifier(pass, file, tname.Pkg())
+	for i, name := range missing {
+		missing[i] = qual + name
+	}
+	diag := analysis.Diagnostic{
+		Pos:     stmt.Pos(),
+		End:     stmt.Body.Lbrace,
+		Message: fmt.Sprintf("missing cases in switch of type %s: %s", types.TypeString(tname.Type(), (*types.Package).Name), strings.Join(missing, ", ")),
+	}
+	if ok {
+		diag.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "Add the missing cases",
+			TextEdits: []analysis.TextEdit{addCase(stmt, missing)},
+		}}
+	}
+	pass.Report(diag)
+}
+
+// qualifier returns the prefix with which the file refers to the
+// declarations of pkg, or false if it cannot refer to them.
+func qualifier(pass *analysis.Pass, file *ast.File, pkg *types.Package) (string, bool) {
+	if pkg == pass.Pkg {
+		return "", true
+	}
+	for _, imp := range file.Imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err != nil || path != pkg.Path() {
+			continue
+		}
+		switch {
+		case imp.Name == nil:
+			return pkg.Name() + ".", true
+		case imp.Name.Name == ".":
+			return "", true
+		case imp.Name.Name != "_":
+			return imp.Name.Name + ".", true
+		}
+	}
+	return "", false
+}
+
+// addCase returns the edit that adds a case clause for the names at the
+// end of the switch statement.
+func addCase(stmt *ast.SwitchStmt, names []string) analysis.TextEdit {
+	return analysis.TextEdit{
+		Pos:     stmt.Body.Rbrace,
+		End:     stmt.Body.Rbrace,
+		NewText: []byte(fmt.Sprintf("case %s:\n", strings.Join(names, ", "))),
+	}
+}
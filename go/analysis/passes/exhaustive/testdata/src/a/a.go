@@ -0,0 +1,59 @@
+package a
+
+type Color int // want Color:`enum\(Red, Green, Blue\)`
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+type Level string // want Level:`enum\(Debug, Info, Warn, Warning, hidden\)`
+
+const (
+	Debug   Level = "debug"
+	Info    Level = "info"
+	Warn    Level = "warn"
+	Warning       = Warn
+	hidden  Level = "hidden"
+)
+
+// One constant is not an enum.
+type Flag int
+
+const Only Flag = 1
+
+func _(c Color, l Level, f Flag) {
+	switch c { // want `missing cases in switch of type a.Color: Green, Blue`
+	case Red:
+	}
+
+	switch c { // want `missing cases in switch of type a.Color: Blue`
+	case Red, Green:
+		println()
+	}
+
+	switch c {
+	case Red, Green, Blue:
+	}
+
+	switch c {
+	case Red:
+	default:
+	}
+
+	switch c {
+	case Red, Color(f):
+	}
+
+	switch l { // want `missing cases in switch of type a.Level: Info, hidden`
+	case Debug, Warning:
+	}
+
+	switch f {
+	}
+
+	switch {
+	case c == Red:
+	}
+}
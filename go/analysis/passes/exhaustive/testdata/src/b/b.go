@@ -0,0 +1,24 @@
+package b
+
+import (
+	"a"
+	col "a"
+)
+
+func _(c a.Color, l a.Level) {
+	switch c { // want `missing cases in switch of type a.Color: a.Red`
+	case a.Green, a.Blue:
+	}
+
+	switch l { // want `missing cases in switch of type a.Level: a.Debug, a.Info`
+	case a.Warn:
+	}
+
+	switch l {
+	case a.Debug, a.Info, a.Warn: // OK: hidden is not exported
+	}
+
+	switch c := c; c { // want `missing cases in switch of type a.Color: a.Green, a.Blue`
+	case col.Red:
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"go/format"
 	"go/token"
 	"go/types"
+	"io/ioutil"
 	"sort"
 
 	"golang.org/x/tools/go/analysis"
@@ -23,7 +24,9 @@ import (
 const Doc = `find structs that would use less memory if their fields were sorted
 
 This analyzer find structs that can be rearranged to use less memory, and provides
-a suggested edit with the most compact order.
+a suggested edit with the most compact order. The edit moves the comments of each
+field with it, and keeps the groups of fields separated by blank lines when sorting
+the fields of each group is as compact.
 
 Note that there are two different diagnostics reported. One checks struct size,
 and the other reports "pointer bytes" used. Pointer bytes is how many bytes of the
@@ -93,23 +96,44 @@ func fieldalignment(pass *analysis.Pass, node *ast.StructType, typ *types.Struct
 		return
 	}
 
+	pass.Report(analysis.Diagnostic{
+		Pos:     node.Pos(),
+		End:     node.Pos() + token.Pos(len("struct")),
+		Message: message,
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "Rearrange fields",
+			TextEdits: rearrange(pass, node, typ, &s, optimal, indexes),
+		}},
+	})
+}
+
+// rearrange returns the edits that reorder the fields of node, whose
+// type is typ, in the optimal order of indexes.
+//
+// If each field is on lines of its own, the fields are moved with
+// their doc comments, line comments, and the comments and blank lines
+// that precede them. Blank lines separate groups of fields: if
+// sorting the fields within each group is as good as the optimal
+// order, the groups are kept.
+func rearrange(pass *analysis.Pass, node *ast.StructType, typ *types.Struct, s *gcSizes, optimal *types.Struct, indexes []int) []analysis.TextEdit {
+	if edits := rearrangeLines(pass, node, typ, s, optimal, indexes); edits != nil {
+		return edits
+	}
+
 	// Flatten the ast node since it could have multiple field names per list item while
 	// *types.Struct only have one item per field.
-	// TODO: Preserve multi-named fields instead of flattening.
+	// Comments are lost; see https://github.com/golang/go/issues/20744.
 	var flat []*ast.Field
 	for _, f := range node.Fields.List {
-		// TODO: Preserve comment, for now get rid of them.
-		//       See https://github.com/golang/go/issues/20744
-		f.Comment = nil
-		f.Doc = nil
 		if len(f.Names) <= 1 {
-			flat = append(flat, f)
+			flat = append(flat, &ast.Field{Names: f.Names, Type: f.Type, Tag: f.Tag})
 			continue
 		}
 		for _, name := range f.Names {
 			flat = append(flat, &ast.Field{
 				Names: []*ast.Ident{name},
 				Type:  f.Type,
+				Tag:   f.Tag,
 			})
 		}
 	}
@@ -129,22 +153,157 @@ func fieldalignment(pass *analysis.Pass, node *ast.StructType, typ *types.Struct
 	// Write the newly aligned struct node to get the content for suggested fixes.
 	var buf bytes.Buffer
 	if err := format.Node(&buf, token.NewFileSet(), newStr); err != nil {
-		return
+		return nil
 	}
-
-	pass.Report(analysis.Diagnostic{
+	return []analysis.TextEdit{{
 		Pos:     node.Pos(),
-		End:     node.Pos() + token.Pos(len("struct")),
-		Message: message,
-		SuggestedFixes: []analysis.SuggestedFix{{
-			Message: "Rearrange fields",
-			TextEdits: []analysis.TextEdit{{
-				Pos:     node.Pos(),
-				End:     node.End(),
-				NewText: buf.Bytes(),
-			}},
-		}},
-	})
+		End:     node.End(),
+		NewText: buf.Bytes(),
+	}}
+}
+
+// A chunk is the source text of a field that is on lines of its own,
+// with the comments that precede it.
+type chunk struct {
+	field       *ast.Field
+	first, last token.Pos // of the first comment, and of the end of the line comment
+	blank       bool      // preceded by a blank line that separates it from the previous field
+}
+
+// rearrangeLines returns the edits of rearrange that preserve comments,
+// or nil if the fields are not on lines of their own.
+func rearrangeLines(pass *analysis.Pass, node *ast.StructType, typ *types.Struct, s *gcSizes, optimal *types.Struct, indexes []int) []analysis.TextEdit {
+	tf := pass.Fset.File(node.Pos())
+	var file *ast.File
+	for _, f := range pass.Files {
+		if f.Pos() <= node.Pos() && node.Pos() < f.End() {
+			file = f
+		}
+	}
+	if tf == nil || file == nil || len(node.Fields.List) == 0 {
+		return nil
+	}
+	src, err := ioutil.ReadFile(tf.Name())
+	if err != nil || tf.Size() != len(src) {
+		return nil
+	}
+
+	// Split the fields and the comments that precede them into chunks.
+	var chunks []chunk
+	prev := node.Fields.Opening // the end of the previous chunk
+	for _, f := range node.Fields.List {
+		c := chunk{field: f, first: f.Pos(), last: f.End()}
+		if f.Comment != nil {
+			c.last = f.Comment.End()
+		}
+		for _, cg := range file.Comments {
+			if tf.Line(prev) < tf.Line(cg.Pos()) && cg.End() <= f.Pos() && cg.Pos() < c.first {
+				c.first = cg.Pos()
+			}
+		}
+		if tf.Line(c.first) <= tf.Line(prev) {
+			return nil // shares a line with the previous field or brace
+		}
+		c.blank = len(chunks) > 0 && tf.Line(c.first) > tf.Line(prev)+1
+		chunks = append(chunks, c)
+		prev = c.last
+	}
+	if tf.Line(prev) >= tf.Line(node.Fields.Closing) {
+		return nil // shares a line with the closing brace
+	}
+
+	// Map each field of typ to its chunk and name.
+	type flatField struct {
+		chunk int
+		name  int // index within the names of the field
+	}
+	var flat []flatField
+	for i, c := range chunks {
+		for j := 0; j < len(c.field.Names) || j == 0; j++ {
+			flat = append(flat, flatField{i, j})
+		}
+	}
+	if len(flat) != typ.NumFields() {
+		return nil
+	}
+
+	// Prefer to sort the fields within each group, if that is optimal.
+	var grouped []int
+	for i := 0; i < len(flat); {
+		j := i + 1
+		for j < len(flat) && (flat[j].chunk == flat[j-1].chunk || !chunks[flat[j].chunk].blank) {
+			j++
+		}
+		fields := make([]*types.Var, j-i)
+		for k := range fields {
+			fields[k] = typ.Field(i + k)
+		}
+		_, order := optimalOrder(types.NewStruct(fields, nil), s)
+		for _, k := range order {
+			grouped = append(grouped, i+k)
+		}
+		i = j
+	}
+	fields := make([]*types.Var, len(grouped))
+	for i, index := range grouped {
+		fields[i] = typ.Field(index)
+	}
+	if str := types.NewStruct(fields, nil); s.Sizeof(str) == s.Sizeof(optimal) && s.ptrdata(str) == s.ptrdata(optimal) {
+		indexes = grouped
+	}
+
+	// Write the chunks in the new order, splitting a field with
+	// several names whose fields do not stay together.
+	text := func(from, to token.Pos) []byte { return src[tf.Offset(from):tf.Offset(to)] }
+	lineStart := func(pos token.Pos) token.Pos { return tf.LineStart(tf.Line(pos)) }
+	var buf bytes.Buffer
+	for i := 0; i < len(indexes); {
+		ff := flat[indexes[i]]
+		c := chunks[ff.chunk]
+		if i > 0 {
+			buf.WriteByte('\n')
+			if c.blank {
+				buf.WriteByte('\n')
+			}
+		}
+
+		n := len(c.field.Names)
+		together := n <= 1 || i+n <= len(indexes)
+		for j := 0; j < n && together; j++ {
+			together = flat[indexes[i+j]] == flatField{ff.chunk, j}
+		}
+		if together {
+			buf.Write(text(lineStart(c.first), c.last))
+			i += max(n, 1)
+			continue
+		}
+
+		// name type `tag`, with the comments for the first name.
+		f := c.field
+		if ff.name == 0 {
+			buf.Write(text(lineStart(c.first), lineStart(f.Pos())))
+		}
+		buf.Write(text(lineStart(f.Pos()), f.Pos()))
+		buf.WriteString(f.Names[ff.name].Name + " ")
+		buf.Write(text(f.Type.Pos(), f.End()))
+		if ff.name == 0 && f.Comment != nil {
+			buf.Write(text(f.End(), c.last))
+		}
+		i++
+	}
+
+	return []analysis.TextEdit{{
+		Pos:     lineStart(chunks[0].first),
+		End:     chunks[len(chunks)-1].last,
+		NewText: buf.Bytes(),
+	}}
+}
+
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
 }
 
 func optimalOrder(str *types.Struct, sizes *gcSizes) (*types.Struct, []int) {
@@ -44,3 +44,22 @@ type WithComments struct { // want "struct of size 8 could be 4"
 
 	// and a last comment
 }
+
+type Groups struct { // want "struct of size 16 could be 12"
+	// header
+	x byte
+	y int32 // y comment
+
+	// counts
+	m byte
+	n int32
+}
+
+type FloatingComments struct { // want "struct of size 12 could be 8"
+	x byte
+
+	// A comment that is not the doc comment of y.
+
+	y int32
+	z byte
+}
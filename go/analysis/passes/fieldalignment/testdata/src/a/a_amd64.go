@@ -46,3 +46,13 @@ type Issue43233 struct { // want "struct with 88 pointer bytes could be 80"
 	BaseURL       string    `mapstructure:"base_url"`
 	AccessToken   string    `mapstructure:"access_token"`
 }
+
+type KeepGroups struct { // want "struct of size 32 could be 24"
+	// header
+	a byte
+	b int64
+	c byte // c comment
+
+	// counts of things
+	d int64
+}
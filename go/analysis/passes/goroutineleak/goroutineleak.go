@@ -0,0 +1,642 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package goroutineleak defines an Analyzer that reports common
+// shapes of code that leak goroutines or timers.
+package goroutineleak
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const Doc = `check for goroutines that may block forever
+
+The goroutineleak analyzer reports common shapes of code that leak
+goroutines, or the timers of a loop:
+
+A goroutine that sends on, or receives from, a channel made by the
+function that starts it, when no other goroutine receives from, or
+sends on or closes, that channel:
+
+	ch := make(chan int)
+	go func() {
+		ch <- compute() // goroutine blocks forever sending on ch
+	}()
+
+A goroutine that sends the only value of an unbuffered channel that
+is received from by a select statement with other cases, such as a
+timeout: if the select takes another case, the goroutine blocks
+forever. The suggested fix gives the channel a buffer of one value:
+
+	ch := make(chan int)
+	go func() { ch <- compute() }()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		return -1
+	}
+
+A call of time.After in a select statement in a loop, which creates a
+new timer on each iteration that is not released until it fires.
+
+A goroutine started just after a call of a sync.WaitGroup's Add method
+that does not call its Done method, or returns on some path without
+calling it, so that a call of Wait blocks forever.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "goroutineleak",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		inspect.Analyzer,
+		ctrlflow.Analyzer,
+		buildssa.Analyzer,
+	},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+	ssainput := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+
+	checkChannels(pass, inspect, ssainput)
+
+	nodeFilter := []ast.Node{
+		(*ast.CallExpr)(nil),
+		(*ast.GoStmt)(nil),
+	}
+	inspect.WithStack(nodeFilter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			checkTimeAfter(pass, n, stack)
+		case *ast.GoStmt:
+			checkWaitGroup(pass, cfgs, n, stack)
+		}
+		return true
+	})
+	return nil, nil
+}
+
+// -- channels --
+
+// elsewhere is the goroutine of a function literal that may be called
+// by any goroutine.
+var elsewhere = new(ssa.Go)
+
+// An op is a send, receive, or close of a channel.
+type op struct {
+	pos   token.Pos
+	block *ssa.BasicBlock
+	// The goroutine of the operation: a go statement, nil for that
+	// of the function that makes the channel, or elsewhere.
+	goroutine *ssa.Go
+	send      bool        // send, or else receive or close
+	close     bool        // close
+	sel       *ssa.Select // the select statement, unless it blocks on this operation alone
+}
+
+// chanUses records the operations of a channel made by a make call,
+// through the variables, free variables and parameters that hold it.
+type chanUses struct {
+	make    *ssa.MakeChan
+	ops     []op
+	escapes bool // the channel escapes or is reassigned, so other operations may exist
+	seen    map[use]bool
+}
+
+type use struct {
+	v         ssa.Value
+	goroutine *ssa.Go
+}
+
+// checkChannels reports the goroutines that send on, or receive from,
+// a channel of the package with which no other goroutine communicates.
+func checkChannels(pass *analysis.Pass, inspect *inspector.Inspector, ssainput *buildssa.SSA) {
+	var operands map[token.Pos]ast.Expr // channel operand of each send or receive, by position
+	name := func(pos token.Pos) string {
+		if operands == nil {
+			operands = make(map[token.Pos]ast.Expr)
+			inspect.Preorder([]ast.Node{(*ast.SendStmt)(nil), (*ast.UnaryExpr)(nil)}, func(n ast.Node) {
+				switch n := n.(type) {
+				case *ast.SendStmt:
+					operands[n.Arrow] = n.Chan
+				case *ast.UnaryExpr:
+					if n.Op == token.ARROW {
+						operands[n.OpPos] = n.X
+					}
+				}
+			})
+		}
+		if x, ok := operands[pos]; ok {
+			return analysisutil.Format(pass.Fset, x)
+		}
+		return "the channel"
+	}
+
+	for _, fn := range ssainput.SrcFuncs {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if mc, ok := instr.(*ssa.MakeChan); ok {
+					u := &chanUses{make: mc, seen: make(map[use]bool)}
+					u.value(mc, nil)
+					if !u.escapes {
+						u.check(pass, name)
+					}
+				}
+			}
+		}
+	}
+}
+
+// value records the operations on the channel value v in goroutine g.
+func (u *chanUses) value(v ssa.Value, g *ssa.Go) {
+	if u.seen[use{v, g}] {
+		return
+	}
+	u.seen[use{v, g}] = true
+
+	for _, instr := range referrers(v) {
+		switch instr := instr.(type) {
+		case *ssa.Send:
+			if instr.X == v {
+				u.escapes = true
+			} else {
+				u.ops = append(u.ops, op{pos: instr.Pos(), block: instr.Block(), goroutine: g, send: true})
+			}
+		case *ssa.UnOp:
+			if instr.Op == token.ARROW {
+				u.ops = append(u.ops, op{pos: instr.Pos(), block: instr.Block(), goroutine: g})
+			} else {
+				u.escapes = true
+			}
+		case *ssa.Select:
+			var sel *ssa.Select
+			if len(instr.States) > 1 || !instr.Blocking {
+				sel = instr
+			}
+			for _, st := range instr.States {
+				if st.Send == v {
+					u.escapes = true
+				}
+				if st.Chan == v {
+					u.ops = append(u.ops, op{pos: st.Pos, block: instr.Block(), goroutine: g, send: st.Dir == types.SendOnly, sel: sel})
+				}
+			}
+		case *ssa.Call:
+			if b, ok := instr.Call.Value.(*ssa.Builtin); ok {
+				switch b.Name() {
+				case "close":
+					u.ops = append(u.ops, op{pos: instr.Pos(), block: instr.Block(), goroutine: g, close: true})
+				case "len", "cap":
+				default:
+					u.escapes = true
+				}
+			} else {
+				u.call(instr.Common(), v, g, false)
+			}
+		case *ssa.Defer:
+			u.call(instr.Common(), v, g, false)
+		case *ssa.Go:
+			u.call(instr.Common(), v, instr, false)
+		case *ssa.MakeClosure:
+			u.closure(instr, v, g, false)
+		case *ssa.Store:
+			if alloc, ok := instr.Addr.(*ssa.Alloc); ok && instr.Val == v && v == u.make {
+				u.addr(alloc, g)
+			} else {
+				u.escapes = true
+			}
+		case *ssa.BinOp, *ssa.DebugRef:
+			// comparisons, and debug information
+		default:
+			u.escapes = true
+		}
+	}
+}
+
+// addr records the operations on the channel held by the variable
+// whose address is v, in goroutine g.
+func (u *chanUses) addr(v ssa.Value, g *ssa.Go) {
+	if u.seen[use{v, g}] {
+		return
+	}
+	u.seen[use{v, g}] = true
+
+	for _, instr := range referrers(v) {
+		switch instr := instr.(type) {
+		case *ssa.Store:
+			if instr.Addr != v || instr.Val != u.make {
+				u.escapes = true // reassigned, or address stored
+			}
+		case *ssa.UnOp:
+			if instr.Op == token.MUL {
+				u.value(instr, g)
+			} else {
+				u.escapes = true
+			}
+		case *ssa.MakeClosure:
+			u.closure(instr, v, g, true)
+		case *ssa.DebugRef:
+		default:
+			u.escapes = true
+		}
+	}
+}
+
+// call records the operations on the channel (or variable, if addr)
+// v of a call in goroutine g, when v is an argument.
+func (u *chanUses) call(call *ssa.CallCommon, v ssa.Value, g *ssa.Go, addr bool) {
+	callee := call.StaticCallee()
+	if callee == nil || callee.Blocks == nil {
+		u.escapes = true
+		return
+	}
+	for i, arg := range call.Args {
+		if arg == v {
+			if addr {
+				u.addr(callee.Params[i], g)
+			} else {
+				u.value(callee.Params[i], g)
+			}
+		}
+	}
+}
+
+// closure records the operations on the channel (or variable, if
+// addr) v bound by the function literal mc, made in goroutine g.
+func (u *chanUses) closure(mc *ssa.MakeClosure, v ssa.Value, g *ssa.Go, addr bool) {
+	fn := mc.Fn.(*ssa.Function)
+
+	// The goroutines that call the function literal.
+	var goroutines []*ssa.Go
+	for _, instr := range referrers(mc) {
+		switch instr := instr.(type) {
+		case *ssa.Go:
+			if instr.Call.Value == mc {
+				goroutines = append(goroutines, instr)
+				continue
+			}
+		case ssa.CallInstruction:
+			if instr.Common().Value == mc {
+				goroutines = append(goroutines, g)
+				continue
+			}
+		case *ssa.DebugRef:
+			continue
+		}
+		goroutines = append(goroutines, elsewhere)
+	}
+
+	for i, b := range mc.Bindings {
+		if b != v {
+			continue
+		}
+		for _, g := range goroutines {
+			if addr {
+				u.addr(fn.FreeVars[i], g)
+			} else {
+				u.value(fn.FreeVars[i], g)
+			}
+		}
+	}
+}
+
+// check reports the goroutines that block forever on the channel.
+func (u *chanUses) check(pass *analysis.Pass, name func(token.Pos) string) {
+	var goroutines []*ssa.Go
+	for _, op := range u.ops {
+		if op.goroutine != nil && op.goroutine != elsewhere && !containsGo(goroutines, op.goroutine) {
+			goroutines = append(goroutines, op.goroutine)
+		}
+	}
+
+	for _, g := range goroutines {
+		var send, recv *op // blocking operations of g
+		var otherSend, otherRecv bool
+		var sends []op
+		var selects []*ssa.Select // the selects that receive from the channel, in other goroutines
+		for i := range u.ops {
+			o := &u.ops[i]
+			if o.send {
+				sends = append(sends, *o)
+			}
+			if o.goroutine == g {
+				if o.sel == nil && !o.close {
+					if o.send && send == nil {
+						send = o
+					} else if !o.send && recv == nil {
+						recv = o
+					}
+				}
+				continue
+			}
+			if o.send || o.close {
+				otherSend = true
+			} else {
+				otherRecv = true
+				selects = append(selects, o.sel)
+			}
+		}
+
+		switch {
+		case send != nil && !otherRecv:
+			pass.Reportf(opPos(send, g), "goroutine blocks forever sending on %s: no other goroutine receives from it", name(send.pos))
+
+		case recv != nil && !otherSend:
+			pass.Reportf(opPos(recv, g), "goroutine blocks forever receiving from %s: no other goroutine sends on it or closes it", name(recv.pos))
+
+		case send != nil && len(sends) == 1 && !inLoop(send.block) && isUnbuffered(u.make) && !containsSelect(selects, nil):
+			sel := selects[0]
+			pass.Report(analysis.Diagnostic{
+				Pos: opPos(send, g),
+				Message: fmt.Sprintf("goroutine blocks forever sending on unbuffered %s if the select statement at line %d does not receive from it",
+					name(send.pos), pass.Fset.Position(sel.Pos()).Line),
+				SuggestedFixes: bufferFix(pass, u.make),
+			})
+		}
+	}
+}
+
+func referrers(v ssa.Value) []ssa.Instruction {
+	if refs := v.Referrers(); refs != nil {
+		return *refs
+	}
+	return nil
+}
+
+func containsGo(list []*ssa.Go, g *ssa.Go) bool {
+	for _, x := range list {
+		if x == g {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSelect(list []*ssa.Select, sel *ssa.Select) bool {
+	for _, x := range list {
+		if x == sel {
+			return true
+		}
+	}
+	return false
+}
+
+// opPos returns the position of op, or that of its go statement.
+func opPos(op *op, g *ssa.Go) token.Pos {
+	if op.pos.IsValid() {
+		return op.pos
+	}
+	return g.Pos()
+}
+
+func isUnbuffered(mc *ssa.MakeChan) bool {
+	size, ok := mc.Size.(*ssa.Const)
+	return ok && size.Int64() == 0
+}
+
+// inLoop reports whether the block b may be executed more than once
+// by a call of its function.
+func inLoop(b *ssa.BasicBlock) bool {
+	seen := make(map[*ssa.BasicBlock]bool)
+	var visit func(b *ssa.BasicBlock) bool
+	visit = func(x *ssa.BasicBlock) bool {
+		for _, succ := range x.Succs {
+			if succ == b {
+				return true
+			}
+			if !seen[succ] {
+				seen[succ] = true
+				if visit(succ) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return visit(b)
+}
+
+// bufferFix returns a fix that adds a buffer of one value to the
+// channel made by mc, if its make call has no size argument.
+func bufferFix(pass *analysis.Pass, mc *ssa.MakeChan) []analysis.SuggestedFix {
+	for _, f := range pass.Files {
+		if f.Pos() > mc.Pos() || mc.Pos() > f.End() {
+			continue
+		}
+		var call *ast.CallExpr
+		ast.Inspect(f, func(n ast.Node) bool {
+			if c, ok := n.(*ast.CallExpr); ok && c.Lparen == mc.Pos() {
+				call = c
+			}
+			return call == nil
+		})
+		if call == nil || len(call.Args) != 1 || call.Ellipsis.IsValid() {
+			return nil
+		}
+		return []analysis.SuggestedFix{{
+			Message: "Make the channel buffered",
+			TextEdits: []analysis.TextEdit{{
+				Pos:     call.Args[0].End(),
+				End:     call.Args[0].End(),
+				NewText: []byte(", 1"),
+			}},
+		}}
+	}
+	return nil
+}
+
+// -- time.After --
+
+// checkTimeAfter reports a call of time.After in a select statement
+// in a loop.
+func checkTimeAfter(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "time" || fn.Name() != "After" {
+		return
+	}
+
+	// Find the communication clause of the call, then a loop.
+	inComm := false
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.CommClause:
+			if n.Comm != nil && n.Comm == stack[i+1] {
+				inComm = true
+			}
+		case *ast.ForStmt, *ast.RangeStmt:
+			if inComm {
+				pass.Reportf(call.Pos(), "time.After in a loop creates a timer on each iteration that is not released until it fires; reuse a time.Timer instead")
+				return
+			}
+		case *ast.FuncLit, *ast.FuncDecl:
+			return
+		}
+	}
+}
+
+// -- sync.WaitGroup --
+
+// checkWaitGroup reports a go statement of a function literal just
+// after a call of wg.Add, when the function literal does not call
+// wg.Done on all paths.
+func checkWaitGroup(pass *analysis.Pass, cfgs *ctrlflow.CFGs, stmt *ast.GoStmt, stack []ast.Node) {
+	lit, ok := analysisutil.Unparen(stmt.Call.Fun).(*ast.FuncLit)
+	if !ok {
+		return
+	}
+
+	// Is the previous statement a call of wg.Add?
+	var list []ast.Stmt
+	switch parent := stack[len(stack)-2].(type) {
+	case *ast.BlockStmt:
+		list = parent.List
+	case *ast.CaseClause:
+		list = parent.Body
+	case *ast.CommClause:
+		list = parent.Body
+	}
+	var wg ast.Expr
+	for i, s := range list {
+		if s == stmt && i > 0 {
+			if es, ok := list[i-1].(*ast.ExprStmt); ok {
+				if call, ok := es.X.(*ast.CallExpr); ok && isWaitGroupMethod(pass.TypesInfo, call, "Add") {
+					wg = call.Fun.(*ast.SelectorExpr).X
+				}
+			}
+		}
+	}
+	if wg == nil {
+		return
+	}
+	name := analysisutil.Format(pass.Fset, wg)
+
+	// Find the calls of Done, and whether the function literal
+	// uses a WaitGroup in some other way, such as passing it to a
+	// function that may call Done.
+	hasDone, deferred, other := false, false, false
+	receivers := make(map[ast.Expr]bool) // of WaitGroup method calls
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.DeferStmt:
+			if hasDoneCall(pass.TypesInfo, n.Call) {
+				deferred = true
+			}
+		case *ast.CallExpr:
+			if isWaitGroupMethod(pass.TypesInfo, n, "Done") {
+				hasDone = true
+			}
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok && pass.TypesInfo.Selections[sel] != nil && isWaitGroup(pass.TypesInfo.TypeOf(sel.X)) {
+				receivers[sel.X] = true
+			}
+		case ast.Expr:
+			if !receivers[n] && isWaitGroup(pass.TypesInfo.TypeOf(n)) {
+				other = true
+			}
+		}
+		return true
+	})
+	if other || deferred {
+		return
+	}
+	if !hasDone {
+		pass.Reportf(stmt.Pos(), "goroutine started after %s.Add never calls %s.Done", name, name)
+		return
+	}
+
+	// Find a path from the entry to a return that does not call Done.
+	g := cfgs.FuncLit(lit)
+	if ret := pathWithoutDone(pass.TypesInfo, g); ret != nil {
+		pass.Reportf(ret.Pos(), "goroutine started after %s.Add returns without calling %s.Done on this path", name, name)
+	}
+}
+
+// pathWithoutDone returns the return statement at the end of a path
+// of g from its entry that does not call a WaitGroup's Done method.
+func pathWithoutDone(info *types.Info, g *cfg.CFG) *ast.ReturnStmt {
+	seen := make(map[*cfg.Block]bool)
+	var search func(b *cfg.Block) *ast.ReturnStmt
+	search = func(b *cfg.Block) *ast.ReturnStmt {
+		if seen[b] {
+			return nil
+		}
+		seen[b] = true
+		for _, n := range b.Nodes {
+			if hasDoneCall(info, n) {
+				return nil
+			}
+		}
+		if ret := b.Return(); ret != nil {
+			return ret
+		}
+		for _, succ := range b.Succs {
+			if ret := search(succ); ret != nil {
+				return ret
+			}
+		}
+		return nil
+	}
+	return search(g.Blocks[0])
+}
+
+// hasDoneCall reports whether n calls a WaitGroup's Done method,
+// including in a function literal that it calls.
+func hasDoneCall(info *types.Info, n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if isWaitGroupMethod(info, n, "Done") {
+				found = true
+			} else if lit, ok := analysisutil.Unparen(n.Fun).(*ast.FuncLit); ok && hasDoneCall(info, lit.Body) {
+				found = true
+			}
+		case *ast.FuncLit:
+			return false // called later, if at all
+		}
+		return !found
+	})
+	return found
+}
+
+// isWaitGroupMethod reports whether call is a call of the named
+// method of sync.WaitGroup.
+func isWaitGroupMethod(info *types.Info, call *ast.CallExpr, name string) bool {
+	fn, ok := typeutil.Callee(info, call).(*types.Func)
+	if !ok || fn.Name() != name {
+		return false
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	return recv != nil && isWaitGroup(recv.Type())
+}
+
+// isWaitGroup reports whether t is sync.WaitGroup or a pointer to it.
+func isWaitGroup(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "sync" && obj.Name() == "WaitGroup"
+}
@@ -0,0 +1,159 @@
+package a
+
+import (
+	"sync"
+	"time"
+)
+
+func compute() int { return 0 }
+
+func sendNoReceiver() {
+	ch := make(chan int)
+	go func() {
+		ch <- compute() // want `goroutine blocks forever sending on ch: no other goroutine receives from it`
+	}()
+}
+
+func receiveNoSender() {
+	ch := make(chan int, 1)
+	go func() {
+		<-ch // want `goroutine blocks forever receiving from ch: no other goroutine sends on it or closes it`
+	}()
+}
+
+func rangeNoClose(done chan bool) {
+	ch := make(chan int)
+	go func(in chan int) {
+		for range in { // want `goroutine blocks forever receiving from`
+		}
+		done <- true
+	}(ch)
+}
+
+func closed() {
+	ch := make(chan int)
+	go func() {
+		for range ch {
+		}
+	}()
+	close(ch)
+}
+
+func received() int {
+	ch := make(chan int)
+	go func() {
+		ch <- compute()
+	}()
+	return <-ch
+}
+
+func escapes() chan int {
+	ch := make(chan int)
+	go func() {
+		ch <- compute()
+	}()
+	return ch
+}
+
+func timeout() int {
+	ch := make(chan int)
+	go func() {
+		ch <- compute() // want `goroutine blocks forever sending on unbuffered ch if the select statement at line 63 does not receive from it`
+	}()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		return -1
+	}
+}
+
+func timeoutBuffered() int {
+	ch := make(chan int, 1)
+	go func() {
+		ch <- compute()
+	}()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		return -1
+	}
+}
+
+func loop(ch chan int) {
+	for {
+		select {
+		case <-ch:
+		case <-time.After(time.Second): // want `time.After in a loop creates a timer on each iteration that is not released until it fires; reuse a time.Timer instead`
+			return
+		}
+	}
+}
+
+func noLoop(ch chan int) {
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+	}
+	for {
+		go func() {
+			<-time.After(time.Second)
+		}()
+	}
+}
+
+func waitGroup(work []func() error) {
+	var wg sync.WaitGroup
+	for _, w := range work {
+		wg.Add(1)
+		go func() { // want `goroutine started after wg.Add never calls wg.Done`
+			w()
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w()
+		}()
+
+		wg.Add(1)
+		go func() {
+			if w() != nil {
+				return // want `goroutine started after wg.Add returns without calling wg.Done on this path`
+			}
+			wg.Done()
+		}()
+
+		wg.Add(1)
+		go func() {
+			if w() != nil {
+				wg.Done()
+				return
+			}
+			wg.Done()
+		}()
+
+		wg.Add(1)
+		go func() {
+			if w() != nil {
+				panic("failed")
+			}
+			wg.Done()
+		}()
+
+		wg.Add(1)
+		go run(w, &wg)
+
+		wg.Add(1)
+		go func() {
+			run(w, &wg)
+		}()
+	}
+	wg.Wait()
+}
+
+func run(w func() error, wg *sync.WaitGroup) {
+	defer wg.Done()
+	w()
+}
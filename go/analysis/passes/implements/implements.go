@@ -0,0 +1,50 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package implements defines an Analyzer that provides an index of the
+// interfaces that the named types of a package and its dependencies
+// implement (golang.org/x/tools/go/types/typeutil.ImplementsIndex), so
+// that analyzers need not test every type against every interface.
+// It is only a building block for other analyzers.
+//
+// Example of use in another analysis:
+//
+//	import (
+//		"golang.org/x/tools/go/analysis"
+//		"golang.org/x/tools/go/analysis/passes/implements"
+//		"golang.org/x/tools/go/types/typeutil"
+//	)
+//
+//	var Analyzer = &analysis.Analyzer{
+//		...
+//		Requires:       []*analysis.Analyzer{implements.Analyzer},
+//	}
+//
+//	func run(pass *analysis.Pass) (interface{}, error) {
+//		index := pass.ResultOf[implements.Analyzer].(*typeutil.ImplementsIndex)
+//		for _, T := range index.Implementations(iface) {
+//			...
+//		}
+//		return nil, nil
+//	}
+package implements
+
+import (
+	"reflect"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:             "implements",
+	Doc:              "index the implementations of interfaces for later passes",
+	Run:              run,
+	RunDespiteErrors: true,
+	ResultType:       reflect.TypeOf(new(typeutil.ImplementsIndex)),
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	return typeutil.NewImplementsIndex(typeutil.Dependencies(pass.Pkg)), nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package implements_test
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/passes/implements"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+func Test(t *testing.T) {
+	testdata := analysistest.TestData()
+	result := analysistest.Run(t, testdata, implements.Analyzer, "a")[0]
+
+	index := result.Result.(*typeutil.ImplementsIndex)
+	pkg := result.Pass.Pkg
+	stringer := pkg.Imports()[0].Scope().Lookup("Stringer").Type()
+	for _, test := range []struct {
+		got, want string
+	}{
+		{fmt.Sprint(index.Implementations(stringer)), "[b.Name *a.File]"},
+		{fmt.Sprint(index.Implementations(pkg.Scope().Lookup("Sizer").Type())), "[a.Count *a.File]"},
+		{fmt.Sprint(index.Interfaces(pkg.Scope().Lookup("Count").Type())), "[a.Sizer]"},
+	} {
+		if test.got != test.want {
+			t.Errorf("got %s, want %s", test.got, test.want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package a
+
+import "b"
+
+type Sizer interface{ Size() int }
+
+type File struct{ name b.Name }
+
+func (f *File) Size() int      { return 0 }
+func (f *File) String() string { return f.name.String() }
+
+type Count int
+
+func (c Count) Size() int { return int(c) }
@@ -0,0 +1,7 @@
+package b
+
+type Stringer interface{ String() string }
+
+type Name string
+
+func (n Name) String() string { return string(n) }
@@ -0,0 +1,333 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ineffassign defines an Analyzer that reports assignments to
+// local variables whose value is never used.
+package ineffassign
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+)
+
+const Doc = `check for assignments whose value is never used
+
+The ineffassign analyzer reports assignments to local variables whose
+value is overwritten, or whose variable goes out of scope, on every
+path of the control-flow graph before it is read, such as:
+
+	err := f()
+	err = g() // the error of f is lost
+	if err != nil {
+		return err
+	}
+
+Variables that are captured by function literals, whose address is
+taken, or that are named results are not checked, as they may be read
+in ways the control-flow graph does not show.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "ineffassign",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, ctrlflow.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+
+	vars := localVars(pass, inspect)
+
+	// The CFG evaluates the communications of a select statement
+	// before choosing one, so their assignments are not writes as far
+	// as it shows.
+	comms := make(map[ast.Node]bool)
+	inspect.Preorder([]ast.Node{(*ast.CommClause)(nil)}, func(n ast.Node) {
+		if comm, ok := n.(*ast.CommClause).Comm.(*ast.AssignStmt); ok {
+			comms[comm] = true
+		}
+	})
+
+	nodeFilter := []ast.Node{
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		var g *cfg.CFG
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			g = cfgs.FuncDecl(n)
+		case *ast.FuncLit:
+			g = cfgs.FuncLit(n)
+		}
+		if g == nil {
+			return
+		}
+		checkFunc(pass, g, comms, func(v *types.Var) bool {
+			info, ok := vars[v]
+			return ok && info.fn == n && !info.escapes
+		})
+	})
+	return nil, nil
+}
+
+// A varInfo describes a local variable.
+type varInfo struct {
+	fn      ast.Node // the *ast.FuncDecl or *ast.FuncLit declaring it
+	escapes bool     // it may be read in ways the CFG does not show
+}
+
+// localVars returns the local variables of the package, noting those
+// that are named results, referred to by function literals other than
+// the one that declares them, or whose address is taken.
+func localVars(pass *analysis.Pass, inspect *inspector.Inspector) map[*types.Var]*varInfo {
+	vars := make(map[*types.Var]*varInfo)
+	type use struct {
+		v  *types.Var
+		fn ast.Node
+	}
+	var uses []use
+
+	inspect.WithStack([]ast.Node{(*ast.Ident)(nil)}, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		id := n.(*ast.Ident)
+		v, ok := pass.TypesInfo.ObjectOf(id).(*types.Var)
+		if !ok || v.IsField() || v.Pkg() != pass.Pkg || v.Parent() == nil || v.Parent() == pass.Pkg.Scope() {
+			return true
+		}
+		fn := analysisutil.EnclosingFunc(stack)
+		if pass.TypesInfo.Defs[id] == v {
+			vars[v] = &varInfo{fn: fn, escapes: isResult(stack)}
+			return true
+		}
+		uses = append(uses, use{v, fn})
+		if addressTaken(pass, v, stack) {
+			uses = append(uses, use{v, nil})
+		}
+		return true
+	})
+
+	for _, u := range uses {
+		if info, ok := vars[u.v]; ok && info.fn != u.fn {
+			info.escapes = true
+		}
+	}
+	return vars
+}
+
+// isResult reports whether the identifier at the top of the stack
+// declares a named result.
+func isResult(stack []ast.Node) bool {
+	if len(stack) < 4 {
+		return false
+	}
+	ftype, ok := stack[len(stack)-4].(*ast.FuncType)
+	return ok && ftype.Results != nil && ftype.Results == stack[len(stack)-3]
+}
+
+// addressTaken reports whether the use of v at the top of the stack
+// takes its address, explicitly or by calling a method with a pointer
+// receiver or slicing an array.
+func addressTaken(pass *analysis.Pass, v *types.Var, stack []ast.Node) bool {
+	var expr ast.Node = stack[len(stack)-1]
+	i := len(stack) - 2
+	for ; i >= 0; i-- {
+		if _, ok := stack[i].(*ast.ParenExpr); !ok {
+			break
+		}
+		expr = stack[i]
+	}
+	if i < 0 {
+		return false
+	}
+	switch parent := stack[i].(type) {
+	case *ast.UnaryExpr:
+		return parent.Op == token.AND
+	case *ast.SliceExpr:
+		_, isArray := v.Type().Underlying().(*types.Array)
+		return parent.X == expr && isArray
+	case *ast.SelectorExpr:
+		if parent.X != expr {
+			return false
+		}
+		sel, ok := pass.TypesInfo.Selections[parent]
+		if !ok || sel.Kind() == types.FieldVal {
+			return false
+		}
+		_, isPtr := v.Type().Underlying().(*types.Pointer)
+		recv := sel.Obj().(*types.Func).Type().(*types.Signature).Recv()
+		if recv == nil {
+			return false // an interface method of a type parameter
+		}
+		_, ptrRecv := recv.Type().Underlying().(*types.Pointer)
+		return ptrRecv && !isPtr
+	}
+	return false
+}
+
+// An event is a read or a write of a variable, in the order of
+// execution within a block.
+type event struct {
+	id     *ast.Ident
+	v      *types.Var
+	def    bool // a write, else a read
+	report bool // an assignment to report if its value is never used
+}
+
+// checkFunc reports the ineffectual assignments of the function whose
+// control-flow graph is g to the variables for which check is true.
+func checkFunc(pass *analysis.Pass, g *cfg.CFG, comms map[ast.Node]bool, check func(*types.Var) bool) {
+	events := make([][]event, len(g.Blocks))
+	for _, b := range g.Blocks {
+		for _, n := range b.Nodes {
+			if comms[n] {
+				events[b.Index] = appendEvents(events[b.Index], pass, n.(*ast.AssignStmt).Rhs[0], check)
+				continue
+			}
+			events[b.Index] = appendEvents(events[b.Index], pass, n, check)
+		}
+	}
+
+	// Compute the variables live on entry to each block by iterating
+	// to a fixed point.
+	liveIn := make([]map[*types.Var]bool, len(g.Blocks))
+	for i := range liveIn {
+		liveIn[i] = make(map[*types.Var]bool)
+	}
+	liveOut := func(b *cfg.Block) map[*types.Var]bool {
+		live := make(map[*types.Var]bool)
+		for _, succ := range b.Succs {
+			for v := range liveIn[succ.Index] {
+				live[v] = true
+			}
+		}
+		return live
+	}
+	// transfer applies the events of block b to the set of live
+	// variables, most recent first, calling def for each write.
+	transfer := func(b *cfg.Block, live map[*types.Var]bool, def func(e event, live bool)) {
+		evs := events[b.Index]
+		for i := len(evs) - 1; i >= 0; i-- {
+			e := evs[i]
+			if e.def {
+				def(e, live[e.v])
+				delete(live, e.v)
+			} else {
+				live[e.v] = true
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := len(g.Blocks) - 1; i >= 0; i-- {
+			b := g.Blocks[i]
+			live := liveOut(b)
+			transfer(b, live, func(event, bool) {})
+			if len(live) != len(liveIn[i]) {
+				liveIn[i] = live
+				changed = true
+			}
+		}
+	}
+
+	for _, b := range g.Blocks {
+		if !b.Live {
+			continue // reported by the unreachable analyzer
+		}
+		transfer(b, liveOut(b), func(e event, live bool) {
+			if e.report && !live {
+				pass.ReportRangef(e.id, "ineffectual assignment to %s", e.id.Name)
+			}
+		})
+	}
+}
+
+// appendEvents appends the reads and writes of the checked variables
+// by the CFG node n, not including those of function literals.
+func appendEvents(events []event, pass *analysis.Pass, n ast.Node, check func(*types.Var) bool) []event {
+	varOf := func(e ast.Expr) (*ast.Ident, *types.Var) {
+		id, ok := astutil.Unparen(e).(*ast.Ident)
+		if !ok {
+			return nil, nil
+		}
+		v, ok := pass.TypesInfo.ObjectOf(id).(*types.Var)
+		if !ok || !check(v) {
+			return nil, nil
+		}
+		return id, v
+	}
+	reads := func(n ast.Node) {
+		if n == nil {
+			return
+		}
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.Ident:
+				if id, v := varOf(n); v != nil {
+					events = append(events, event{id: id, v: v})
+				}
+			}
+			return true
+		})
+	}
+	// write records a write of the variable e, if checked, or else
+	// the reads of e, such as those of x and i in x[i] = y.
+	var writes []event
+	write := func(e ast.Expr, report bool) {
+		if id, v := varOf(e); v != nil {
+			writes = append(writes, event{id: id, v: v, def: true, report: report})
+		} else if id, ok := astutil.Unparen(e).(*ast.Ident); !ok || id.Name != "_" {
+			reads(e)
+		}
+	}
+
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		for _, rhs := range n.Rhs {
+			reads(rhs)
+		}
+		for _, lhs := range n.Lhs {
+			if n.Tok != token.ASSIGN && n.Tok != token.DEFINE {
+				reads(lhs) // x += y reads x
+			}
+			write(lhs, true)
+		}
+
+	case *ast.IncDecStmt:
+		reads(n.X)
+		write(n.X, true)
+
+	case *ast.ValueSpec:
+		for _, value := range n.Values {
+			reads(value)
+		}
+		for _, name := range n.Names {
+			// var x T is not reported, as its zero value is
+			// usually meant to be replaced.
+			write(name, n.Values != nil)
+		}
+
+	default:
+		// Other statements and expressions only read variables, as
+		// far as the CFG shows. In particular, the key and value of a
+		// range loop are treated as reads where the CFG places them,
+		// before the loop.
+		reads(n)
+	}
+	return append(events, writes...)
+}
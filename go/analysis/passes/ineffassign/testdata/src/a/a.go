@@ -0,0 +1,171 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+func f() error { return nil }
+func g() error { return nil }
+
+func overwritten() error {
+	err := f() // want "ineffectual assignment to err"
+	err = g()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func outOfScope() int {
+	n := 1
+	n++ // want "ineffectual assignment to n"
+	return 1
+}
+
+func branches(b bool) int {
+	x := 0 // want "ineffectual assignment to x"
+	if b {
+		x = 1
+	} else {
+		x = 2
+	}
+	return x
+}
+
+func onePath(b bool) int {
+	x := 0
+	if b {
+		x = 1
+	}
+	return x
+}
+
+func loop(xs []int) int {
+	sum := 0
+	for _, x := range xs {
+		sum += x
+	}
+	i := 0
+	for i < 10 {
+		i++
+	}
+	return sum
+}
+
+func compound() int {
+	x := 1
+	x += 2 // want "ineffectual assignment to x"
+	x = 3
+	return x
+}
+
+func declared() int {
+	var x int // not reported: the zero value is meant to be replaced
+	x = 1
+	var y = 2 // want "ineffectual assignment to y"
+	y = x
+	return y
+}
+
+func params(x int) int {
+	x = 2 // want "ineffectual assignment to x"
+	return 0
+}
+
+func namedResult() (err error) {
+	err = f() // named results may be read by a deferred call
+	if err != nil {
+		err = errors.New("x")
+	}
+	return
+}
+
+func captured() int {
+	x := 1
+	get := func() int { return x }
+	x = 2
+	return get()
+}
+
+func closure() func() int {
+	return func() int {
+		y := 1 // want "ineffectual assignment to y"
+		y = 2
+		return y
+	}
+}
+
+func address() int {
+	x := 1
+	p := &x
+	x = 2
+	return *p
+}
+
+func method() string {
+	var buf bytes.Buffer
+	w := &buf
+	buf = bytes.Buffer{}
+	buf.WriteString("x")
+	return w.String()
+}
+
+func array() []int {
+	var a [2]int
+	s := a[:]
+	a = [2]int{1, 2}
+	return s
+}
+
+func parts() int {
+	var p struct{ x, y int }
+	p.x = 1
+	a := []int{0}
+	i := 0
+	a[i] = 1
+	return p.x + a[0]
+}
+
+func selects(c1, c2 chan int) int {
+	x := 0
+	select {
+	case x = <-c1:
+	case x = <-c2:
+	}
+	return x
+}
+
+func gotoLoop() int {
+	i := 0
+loop:
+	if i < 10 {
+		i++
+		goto loop
+	}
+	return i
+}
+
+func noReturn() int {
+	x := 1
+	if x > 0 {
+		os.Exit(x)
+	}
+	x = 2 // want "ineffectual assignment to x"
+	return 0
+}
+
+func blank() {
+	_ = f()
+	var _ = g()
+}
+
+func swap(a, b int) (int, int) {
+	a, b = b, a
+	return a, b
+}
@@ -118,3 +118,67 @@ func Imports(pkg *types.Package, path string) bool {
 	}
 	return false
 }
+
+// StatementInBlock returns the statement at the top of the stack of
+// nodes of a function body, if it is a statement of a block and not
+// within a loop, so that a call deferred after it runs once, and only
+// after it; it also returns the statement that follows it in the block,
+// if any.
+func StatementInBlock(stack []ast.Node) (s, next ast.Stmt) {
+	top := stack[len(stack)-1]
+	if _, ok := top.(*ast.ValueSpec); ok && len(stack) >= 3 {
+		top = stack[len(stack)-3] // DeclStmt GenDecl ValueSpec
+		stack = stack[:len(stack)-2]
+	}
+	s, ok := top.(ast.Stmt)
+	if !ok {
+		return nil, nil
+	}
+	list := StatementList(stack)
+	if list == nil {
+		return nil, nil
+	}
+	for _, n := range stack {
+		switch n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			return nil, nil
+		}
+	}
+	for i, stmt := range list {
+		if stmt == s && i+1 < len(list) {
+			return s, list[i+1]
+		}
+	}
+	return s, nil
+}
+
+// StatementList returns the statements of the block, case clause or
+// communication clause of which the node at the top of the stack is
+// one, or nil if it is not one of them, as for the init statement of
+// an if statement or the post statement of a for statement.
+func StatementList(stack []ast.Node) []ast.Stmt {
+	if len(stack) < 2 {
+		return nil
+	}
+	switch parent := stack[len(stack)-2].(type) {
+	case *ast.BlockStmt:
+		return parent.List
+	case *ast.CaseClause:
+		return parent.Body
+	case *ast.CommClause:
+		return parent.Body
+	}
+	return nil
+}
+
+// EnclosingFunc returns the innermost function of the stack of nodes,
+// a *ast.FuncDecl or *ast.FuncLit, or nil if there is none.
+func EnclosingFunc(stack []ast.Node) ast.Node {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch stack[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return stack[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,435 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package closecheck defines the analysis shared by the analyzers that
+// check that resources are closed, such as bodyclose and resourceclose:
+// it finds the variables that hold the resources returned by calls, and
+// the paths through the control-flow graph of a function on which they
+// are neither closed nor passed on to code that may close them.
+package closecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/cfg"
+)
+
+// A Resource describes a variable holding a resource.
+type Resource struct {
+	Var        *types.Var
+	Stmt       ast.Node   // the defining AssignStmt or ValueSpec
+	Err        *types.Var // the error variable, if any
+	DeferAfter ast.Stmt   // the statement after which Close may be deferred, if any
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// Find returns the variables of the function node, a *ast.FuncDecl or
+// *ast.FuncLit, that are defined by the calls for which isOpener reports
+// true, in the order of their definitions:
+//
+//	f, err    := os.Open(...)
+//	resp, err  = client.Do(...)
+//	var w      = gzip.NewWriter(...)
+//
+// It calls discarded for each such call whose resource is discarded.
+// The error variable of a resource is the last variable defined by its
+// call, if it is of type error.
+func Find(pass *analysis.Pass, node ast.Node, isOpener func(*ast.CallExpr) bool, discarded func(*ast.CallExpr)) []*Resource {
+	var funcScope *types.Scope
+	switch v := node.(type) {
+	case *ast.FuncLit:
+		funcScope = pass.TypesInfo.Scopes[v.Type]
+	case *ast.FuncDecl:
+		funcScope = pass.TypesInfo.Scopes[v.Type]
+	}
+
+	var resources []*Resource
+	stack := make([]ast.Node, 0, 32)
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FuncLit:
+			if len(stack) > 0 {
+				return false // don't stray into nested functions
+			}
+		case nil:
+			stack = stack[:len(stack)-1] // pop
+			return true
+		}
+		stack = append(stack, n) // push
+
+		// Look for [{AssignStmt,ValueSpec,ExprStmt} CallExpr].
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !isOpener(call) {
+			return true
+		}
+		var lhs []ast.Expr
+		stmt := stack[len(stack)-2]
+		switch stmt := stmt.(type) {
+		case *ast.ExprStmt:
+		case *ast.ValueSpec:
+			if len(stmt.Values) != 1 {
+				return true
+			}
+			for _, name := range stmt.Names {
+				lhs = append(lhs, name)
+			}
+		case *ast.AssignStmt:
+			if len(stmt.Rhs) != 1 {
+				return true
+			}
+			lhs = stmt.Lhs
+		default:
+			return true // the resource is used otherwise, such as by another call
+		}
+		if len(lhs) == 0 || isBlank(lhs[0]) {
+			discarded(call)
+			return true
+		}
+		id, ok := lhs[0].(*ast.Ident)
+		if !ok {
+			return true // a field or element, which may be closed elsewhere
+		}
+		v, ok := pass.TypesInfo.ObjectOf(id).(*types.Var)
+		if !ok || !funcScope.Contains(v.Pos()) {
+			// If the resource variable is defined outside
+			// function scope, do not analyze it.
+			return true
+		}
+		res := &Resource{Var: v, Stmt: stmt}
+		if len(lhs) > 1 {
+			if id, ok := lhs[len(lhs)-1].(*ast.Ident); ok {
+				if err, ok := pass.TypesInfo.ObjectOf(id).(*types.Var); ok && types.Identical(err.Type(), errorType) {
+					res.Err = err
+				}
+			}
+		}
+		if s, next := analysisutil.StatementInBlock(stack[:len(stack)-1]); s != nil && res.Err != nil && isErrorCheck(pass, next, res.Err) {
+			res.DeferAfter = next
+		}
+		resources = append(resources, res)
+		return true
+	})
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Stmt.Pos() < resources[j].Stmt.Pos() })
+	return resources
+}
+
+// A Closer describes the uses of the variables holding a kind of
+// resource that close them.
+type Closer struct {
+	// Harmless reports whether the use of a resource variable, the
+	// identifier at the top of the stack, can neither close the resource
+	// nor let other code close it.
+	Harmless func(pass *analysis.Pass, stack []ast.Node) bool
+
+	// IsClose reports whether the use of a resource variable at the top
+	// of the stack is the operand of the call that closes it, such as
+	// v.Close().
+	IsClose func(stack []ast.Node) bool
+}
+
+// Unclosed calls report for each of the resources of the function node
+// for which there is a path through the CFG, from its definition to a
+// return statement, that neither closes it nor follows a branch on which
+// its error is not nil, or it is nil. It passes the return statement,
+// which may be synthetic, and whether the resource may be closed by a
+// call deferred just after the check of its error: see DeferFix.
+func (c *Closer) Unclosed(pass *analysis.Pass, node ast.Node, resources []*Resource, report func(res *Resource, ret *ast.ReturnStmt, deferable bool)) {
+	if len(resources) == 0 {
+		return // no need to inspect CFG
+	}
+
+	// Obtain the CFG.
+	cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+	var g *cfg.CFG
+	var sig *types.Signature
+	switch node := node.(type) {
+	case *ast.FuncDecl:
+		sig, _ = pass.TypesInfo.Defs[node.Name].Type().(*types.Signature)
+		g = cfgs.FuncDecl(node)
+
+	case *ast.FuncLit:
+		sig, _ = pass.TypesInfo.Types[node.Type].Type.(*types.Signature)
+		g = cfgs.FuncLit(node)
+	}
+	if sig == nil {
+		return // missing type information
+	}
+
+	for _, res := range resources {
+		if ret := c.unclosedPath(pass, g, res, sig); ret != nil {
+			deferable := res.DeferAfter != nil && !tupleContains(sig.Results(), res.Var) && c.onlyClosed(pass, node, res.Var)
+			report(res, ret, deferable)
+		}
+	}
+}
+
+// DeferFix returns a fix that defers a call of close, such as f.Close,
+// on the line after the check of the error of the resource, or none if
+// there is no such line.
+func DeferFix(pass *analysis.Pass, res *Resource, close string) []analysis.SuggestedFix {
+	// Insert the defer statement on the next line,
+	// after any comment following the statement.
+	after := res.DeferAfter
+	file := pass.Fset.File(after.End())
+	line := file.Line(after.End())
+	if line >= file.LineCount() {
+		return nil
+	}
+	start := file.LineStart(line + 1)
+	return []analysis.SuggestedFix{{
+		Message: fmt.Sprintf("Defer a call of %s", close),
+		TextEdits: []analysis.TextEdit{{
+			Pos:     start,
+			End:     start,
+			NewText: []byte(fmt.Sprintf("defer %s()\n", close)),
+		}},
+	}}
+}
+
+// OpaqueArg reports whether arg, an argument of call, is passed as an
+// interface that has no Close method, such as an io.Reader, so that the
+// callee cannot close it.
+func OpaqueArg(pass *analysis.Pass, call *ast.CallExpr, arg ast.Expr) bool {
+	sig, ok := pass.TypesInfo.TypeOf(call.Fun).(*types.Signature)
+	if !ok {
+		return false // a conversion
+	}
+	for j, a := range call.Args {
+		if analysisutil.Unparen(a) != analysisutil.Unparen(arg) {
+			continue
+		}
+		params := sig.Params()
+		var t types.Type
+		switch {
+		case sig.Variadic() && j >= params.Len()-1:
+			if call.Ellipsis.IsValid() {
+				return false
+			}
+			t = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+		case j < params.Len():
+			t = params.At(j).Type()
+		default:
+			return false
+		}
+		if _, ok := t.Underlying().(*types.Interface); !ok {
+			return false
+		}
+		close, _, _ := types.LookupFieldOrMethod(t, false, nil, "Close")
+		return close == nil
+	}
+	return false
+}
+
+// closes reports whether n contains a use of the resource variable v
+// that is not harmless.
+func (c *Closer) closes(pass *analysis.Pass, n ast.Node, v *types.Var) bool {
+	found := false
+	stack := make([]ast.Node, 0, 32)
+	ast.Inspect(n, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1] // pop
+			return true
+		}
+		stack = append(stack, n) // push
+		if id, ok := n.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == v && !c.Harmless(pass, stack) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// onlyClosed reports whether every use of v in the function node that
+// is not harmless is the call that closes it, outside any nested
+// function literal, so that deferring another call does not close the
+// resource while other code may still use it.
+func (c *Closer) onlyClosed(pass *analysis.Pass, node ast.Node, v *types.Var) bool {
+	ok := true
+	stack := make([]ast.Node, 0, 32)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1] // pop
+			return true
+		}
+		stack = append(stack, n) // push
+		if id, isIdent := n.(*ast.Ident); isIdent && pass.TypesInfo.Uses[id] == v && !c.Harmless(pass, stack) {
+			ok = ok && c.IsClose(stack) && analysisutil.EnclosingFunc(stack) == node
+		}
+		return true
+	})
+	return ok
+}
+
+// unclosedPath finds a path through the CFG, from the statement that
+// defines the resource variable to a return statement, that doesn't
+// close it, nor follows a branch on which the error of the call is not
+// nil, or the variable is nil. If it finds one, it returns the return
+// statement (which may be synthetic).
+func (c *Closer) unclosedPath(pass *analysis.Pass, g *cfg.CFG, res *Resource, sig *types.Signature) *ast.ReturnStmt {
+	v := res.Var
+	vIsNamedResult := sig != nil && tupleContains(sig.Results(), v)
+
+	// uses reports whether nodes close v, or return it as a
+	// named result.
+	uses := func(nodes []ast.Node) bool {
+		for _, n := range nodes {
+			if c.closes(pass, n, v) {
+				return true
+			}
+			if ret, ok := n.(*ast.ReturnStmt); ok && ret.Results == nil && vIsNamedResult {
+				return true
+			}
+		}
+		return false
+	}
+
+	// succs returns the successors of b, less the branch on which the
+	// error is not nil or the resource is nil.
+	succs := func(b *cfg.Block) []*cfg.Block {
+		if len(b.Succs) != 2 || len(b.Nodes) == 0 {
+			return b.Succs
+		}
+		cond, ok := b.Nodes[len(b.Nodes)-1].(ast.Expr)
+		if !ok {
+			return b.Succs
+		}
+		switch x, op := nilComparison(pass, cond); {
+		case x == res.Err && x != nil && op == token.NEQ, x == v && op == token.EQL:
+			return b.Succs[1:]
+		case x == res.Err && x != nil && op == token.EQL, x == v && op == token.NEQ:
+			return b.Succs[:1]
+		}
+		return b.Succs
+	}
+
+	// blockUses computes "uses" for each block, caching the result.
+	memo := make(map[*cfg.Block]bool)
+	blockUses := func(b *cfg.Block) bool {
+		res, ok := memo[b]
+		if !ok {
+			res = uses(b.Nodes)
+			memo[b] = res
+		}
+		return res
+	}
+
+	// Find the var's defining block in the CFG,
+	// plus the rest of the statements of that block.
+	var defblock *cfg.Block
+	var rest []ast.Node
+outer:
+	for _, b := range g.Blocks {
+		for i, n := range b.Nodes {
+			if n == res.Stmt {
+				defblock = b
+				rest = b.Nodes[i+1:]
+				break outer
+			}
+		}
+	}
+	if defblock == nil {
+		panic("internal error: can't find defining block for resource var")
+	}
+
+	// Is v closed in the remainder of its defining block?
+	if uses(rest) {
+		return nil
+	}
+
+	// Does the defining block return without closing v?
+	if ret := defblock.Return(); ret != nil {
+		return ret
+	}
+
+	// Search the CFG depth-first for a path, from defblock to a
+	// return block, in which v is never closed.
+	seen := make(map[*cfg.Block]bool)
+	var search func(blocks []*cfg.Block) *ast.ReturnStmt
+	search = func(blocks []*cfg.Block) *ast.ReturnStmt {
+		for _, b := range blocks {
+			if seen[b] {
+				continue
+			}
+			seen[b] = true
+
+			// Prune the search if the block closes v.
+			if blockUses(b) {
+				continue
+			}
+
+			// Found path to return statement?
+			if ret := b.Return(); ret != nil {
+				return ret
+			}
+
+			// Recur
+			if ret := search(succs(b)); ret != nil {
+				return ret
+			}
+		}
+		return nil
+	}
+	return search(succs(defblock))
+}
+
+// isErrorCheck reports whether stmt is an if statement, without init
+// statement or else branch, that returns if err is not nil.
+func isErrorCheck(pass *analysis.Pass, stmt ast.Stmt, err *types.Var) bool {
+	ifstmt, ok := stmt.(*ast.IfStmt)
+	if !ok || ifstmt.Init != nil || ifstmt.Else != nil || len(ifstmt.Body.List) == 0 {
+		return false
+	}
+	if _, ok := ifstmt.Body.List[len(ifstmt.Body.List)-1].(*ast.ReturnStmt); !ok {
+		return false
+	}
+	v, op := nilComparison(pass, ifstmt.Cond)
+	return v == err && op == token.NEQ
+}
+
+// nilComparison returns the variable compared to nil by the condition
+// cond, and the operator of the comparison, if it is of the form
+// v == nil or v != nil.
+func nilComparison(pass *analysis.Pass, cond ast.Expr) (*types.Var, token.Token) {
+	bin, ok := analysisutil.Unparen(cond).(*ast.BinaryExpr)
+	if !ok || (bin.Op != token.EQL && bin.Op != token.NEQ) {
+		return nil, token.ILLEGAL
+	}
+	x, y := analysisutil.Unparen(bin.X), analysisutil.Unparen(bin.Y)
+	if isNil(pass, x) {
+		x, y = y, x
+	}
+	id, ok := x.(*ast.Ident)
+	if !ok || !isNil(pass, y) {
+		return nil, token.ILLEGAL
+	}
+	v, _ := pass.TypesInfo.Uses[id].(*types.Var)
+	return v, bin.Op
+}
+
+func isNil(pass *analysis.Pass, e ast.Expr) bool {
+	tv, ok := pass.TypesInfo.Types[e]
+	return ok && tv.IsNil()
+}
+
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+func tupleContains(tuple *types.Tuple, v *types.Var) bool {
+	for i := 0; i < tuple.Len(); i++ {
+		if tuple.At(i) == v {
+			return true
+		}
+	}
+	return false
+}
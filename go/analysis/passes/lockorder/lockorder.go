@@ -0,0 +1,504 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lockorder defines an Analyzer that reports mutexes locked
+// twice and mutexes locked in inconsistent orders.
+package lockorder
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+const Doc = `check for double locks and inconsistent lock ordering
+
+The lockorder analyzer reports two kinds of deadlocks involving
+sync.Mutex and sync.RWMutex.
+
+The first is locking a mutex that is already held on every path to
+the lock, directly or by calling a function that locks it:
+
+	func (s *S) Get() int {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.get() // get also locks s.mu
+	}
+
+The second is locking two mutexes in opposite orders: if one function
+locks b while holding a, and another locks a while holding b, two
+goroutines calling them may each wait for the other. Mutexes are
+identified by the package-level variable or the struct field that
+holds them, such as the mu field of type T.
+
+Functions record the mutexes they lock as facts, so calls of functions
+of other packages are checked too.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "lockorder",
+	Doc:       Doc,
+	Requires:  []*analysis.Analyzer{buildssa.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(locks)},
+}
+
+// locks is the fact that a function may lock the mutexes of Sites,
+// and unlocks those of Unlocks, which its callers must hold.
+type locks struct{ Sites, Unlocks []lockSite }
+
+func (*locks) AFact() {}
+
+func (f *locks) String() string {
+	list := func(sites []lockSite) string {
+		var names []string
+		for _, site := range sites {
+			names = append(names, site.String())
+		}
+		return strings.Join(names, ", ")
+	}
+	var parts []string
+	if len(f.Sites) > 0 {
+		parts = append(parts, "locks("+list(f.Sites)+")")
+	}
+	if len(f.Unlocks) > 0 {
+		parts = append(parts, "unlocks("+list(f.Unlocks)+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// A lockSite describes a mutex, locked by a function, in terms the
+// caller can relate to its own mutexes.
+type lockSite struct {
+	Param  int    // the index of the parameter holding the mutex, or -1
+	Global string // else the package-level variable holding it, if any
+	Path   string // the fields selected from the parameter or variable
+	Class  string // the variable or field that holds the mutex, if known
+	Shared bool   // it is only locked by RLock
+}
+
+func (s lockSite) String() string {
+	switch {
+	case s.Param >= 0:
+		return fmt.Sprintf("param%d%s", s.Param, s.Path)
+	case s.Global != "":
+		return s.Global + s.Path
+	}
+	return s.Class
+}
+
+// A lockKey identifies a mutex within a function: the fields of path
+// selected from the value root, or from the package-level variable
+// global.
+type lockKey struct {
+	root   ssa.Value
+	global string
+	path   string
+}
+
+// A lockRef is a mutex used by a function.
+type lockRef struct {
+	key   lockKey
+	class string // the variable or field that holds the mutex, if known
+	name  string // a name for the mutex in diagnostics
+}
+
+// A heldLock is a mutex held by a function.
+type heldLock struct {
+	ref   lockRef
+	pos   token.Pos // the position of the lock
+	write bool      // a Lock, not an RLock
+}
+
+// An edge records that a mutex of class to was locked while one of
+// class from was held.
+type edge struct {
+	from, to         string
+	fromName, toName string
+	heldPos, pos     token.Pos
+}
+
+type checker struct {
+	pass  *analysis.Pass
+	pkg   *ssa.Package
+	sites map[*ssa.Function][]lockSite // sites of the functions of this package
+	held  map[*ssa.Function][]lockSite // sites the functions unlock without locking
+	done  map[*ssa.Function]bool       // functions checked, or being checked
+	edges map[[2]string]edge           // the first edge between each pair of classes
+	order [][2]string                  // keys of edges, in the order found
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ssainput := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	c := &checker{
+		pass:  pass,
+		pkg:   ssainput.Pkg,
+		sites: make(map[*ssa.Function][]lockSite),
+		held:  make(map[*ssa.Function][]lockSite),
+		done:  make(map[*ssa.Function]bool),
+		edges: make(map[[2]string]edge),
+	}
+	for _, fn := range ssainput.SrcFuncs {
+		c.function(fn)
+	}
+	for _, fn := range ssainput.SrcFuncs {
+		if obj, ok := fn.Object().(*types.Func); ok && len(c.sites[fn])+len(c.held[fn]) > 0 {
+			pass.ExportObjectFact(obj, &locks{c.sites[fn], c.held[fn]})
+		}
+	}
+
+	// Report each pair of classes locked in both orders, at the later
+	// of the two edges.
+	for _, key := range c.order {
+		e := c.edges[key]
+		other, ok := c.edges[[2]string{e.to, e.from}]
+		if !ok || other.pos > e.pos || other.pos == e.pos && e.from > e.to {
+			continue
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     e.pos,
+			Message: fmt.Sprintf("%s is locked while holding %s, in the opposite order to another lock (possible deadlock)", e.toName, e.fromName),
+			Related: []analysis.RelatedInformation{
+				{Pos: other.pos, Message: fmt.Sprintf("%s is locked while holding %s here", other.toName, other.fromName)},
+				{Pos: other.heldPos, Message: fmt.Sprintf("%s is locked here", other.fromName)},
+			},
+		})
+	}
+	return nil, nil
+}
+
+// function checks fn, once, and returns the sites of the mutexes it
+// may lock, directly or by its calls, and of those it unlocks without
+// locking them first.
+func (c *checker) function(fn *ssa.Function) (sites, unlocks []lockSite) {
+	if c.done[fn] {
+		return c.sites[fn], c.held[fn] // possibly incomplete, when recursive
+	}
+	c.done[fn] = true
+	if len(fn.Blocks) == 0 {
+		return nil, nil
+	}
+
+	// Compute the mutexes held on entry to each block: those held on
+	// every path to it. A nil map is a block not yet reached.
+	in := make([]map[lockKey]heldLock, len(fn.Blocks))
+	in[0] = make(map[lockKey]heldLock)
+	for changed := true; changed; {
+		changed = false
+		for _, b := range fn.Blocks {
+			if in[b.Index] == nil {
+				continue
+			}
+			out := c.transfer(fn, b, copyHeld(in[b.Index]), false)
+			for _, succ := range b.Succs {
+				if in[succ.Index] == nil {
+					in[succ.Index] = copyHeld(out)
+					changed = true
+					continue
+				}
+				for key := range in[succ.Index] {
+					if _, ok := out[key]; !ok {
+						delete(in[succ.Index], key)
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, b := range fn.Blocks {
+		if in[b.Index] != nil {
+			c.transfer(fn, b, copyHeld(in[b.Index]), true)
+		}
+	}
+
+	// A mutex that fn unlocks without locking it first is held by
+	// its callers, so it is not one they must not hold.
+	sites = c.sites[fn][:0]
+	for _, site := range c.sites[fn] {
+		if !containsSite(c.held[fn], site) {
+			sites = append(sites, site)
+		}
+	}
+	c.sites[fn] = sites
+	return c.sites[fn], c.held[fn]
+}
+
+// containsSite reports whether sites include the mutex of site.
+func containsSite(sites []lockSite, site lockSite) bool {
+	for _, s := range sites {
+		if s.Param == site.Param && s.Global == site.Global && s.Path == site.Path {
+			return true
+		}
+	}
+	return false
+}
+
+// unlock records that fn unlocks ref while it does not hold it.
+func (c *checker) unlock(fn *ssa.Function, ref lockRef) {
+	if site, ok := siteOf(fn, ref); ok && (site.Param >= 0 || site.Global != "") && !containsSite(c.held[fn], site) {
+		c.held[fn] = append(c.held[fn], site)
+	}
+}
+
+func copyHeld(held map[lockKey]heldLock) map[lockKey]heldLock {
+	res := make(map[lockKey]heldLock, len(held))
+	for k, v := range held {
+		res[k] = v
+	}
+	return res
+}
+
+// transfer applies the instructions of the block b of fn to the set
+// of held mutexes, and returns it. If final, it also reports double
+// locks and records the sites and the edges of fn.
+func (c *checker) transfer(fn *ssa.Function, b *ssa.BasicBlock, held map[lockKey]heldLock, final bool) map[lockKey]heldLock {
+	for _, instr := range b.Instrs {
+		call, ok := instr.(*ssa.Call)
+		if !ok {
+			continue // a deferred Unlock runs only on return
+		}
+		callee := call.Common().StaticCallee()
+		if callee == nil {
+			continue
+		}
+		if method, ok := mutexMethod(callee); ok {
+			ref := refOf(call.Common().Args[0])
+			switch method {
+			case "Lock", "RLock":
+				write := method == "Lock"
+				if final {
+					if h, ok := held[ref.key]; ok && (write || h.write) {
+						c.pass.Report(analysis.Diagnostic{
+							Pos:     call.Pos(),
+							Message: fmt.Sprintf("%s is locked while it is already held (possible deadlock)", ref.name),
+							Related: []analysis.RelatedInformation{
+								{Pos: h.pos, Message: fmt.Sprintf("%s is locked here", h.ref.name)},
+							},
+						})
+					}
+					c.acquire(fn, ref, !write, call.Pos(), held)
+				}
+				held[ref.key] = heldLock{ref, call.Pos(), write}
+			case "Unlock", "RUnlock":
+				if _, ok := held[ref.key]; !ok && final {
+					c.unlock(fn, ref)
+				}
+				delete(held, ref.key)
+			}
+			continue
+		}
+
+		sites, unlocks := c.calleeSites(callee)
+		for _, site := range unlocks {
+			if ref, ok := c.refOfSite(call.Common(), site); ok {
+				if _, ok := held[ref.key]; !ok && final {
+					c.unlock(fn, ref)
+				}
+				delete(held, ref.key)
+			}
+		}
+		if !final {
+			continue
+		}
+		for _, site := range sites {
+			ref, ok := c.refOfSite(call.Common(), site)
+			if !ok {
+				continue
+			}
+			if ref.key != (lockKey{}) {
+				if h, ok := held[ref.key]; ok && (!site.Shared || h.write) {
+					c.pass.Report(analysis.Diagnostic{
+						Pos:     call.Pos(),
+						Message: fmt.Sprintf("call of %s locks %s while it is already held (possible deadlock)", callee.Name(), ref.name),
+						Related: []analysis.RelatedInformation{
+							{Pos: h.pos, Message: fmt.Sprintf("%s is locked here", h.ref.name)},
+						},
+					})
+				}
+			}
+			c.acquire(fn, ref, site.Shared, call.Pos(), held)
+		}
+	}
+	return held
+}
+
+// acquire records that fn locks ref at pos, by RLock if shared, while
+// holding the mutexes held.
+func (c *checker) acquire(fn *ssa.Function, ref lockRef, shared bool, pos token.Pos, held map[lockKey]heldLock) {
+	if site, ok := siteOf(fn, ref); ok {
+		site.Shared = shared
+		c.addSite(fn, site)
+	}
+	if ref.class == "" {
+		return
+	}
+	// Visit the held mutexes in a deterministic order.
+	var hs []heldLock
+	for _, h := range held {
+		hs = append(hs, h)
+	}
+	sort.Slice(hs, func(i, j int) bool { return hs[i].pos < hs[j].pos })
+	for _, h := range hs {
+		if h.ref.class == "" || h.ref.class == ref.class {
+			continue
+		}
+		key := [2]string{h.ref.class, ref.class}
+		if _, ok := c.edges[key]; !ok {
+			c.edges[key] = edge{h.ref.class, ref.class, h.ref.name, ref.name, h.pos, pos}
+			c.order = append(c.order, key)
+		}
+	}
+}
+
+// addSite adds a site to those of fn, merging it with an existing
+// site of the same mutex.
+func (c *checker) addSite(fn *ssa.Function, site lockSite) {
+	for i, s := range c.sites[fn] {
+		s.Shared = site.Shared
+		if s == site {
+			c.sites[fn][i].Shared = c.sites[fn][i].Shared && site.Shared
+			return
+		}
+	}
+	c.sites[fn] = append(c.sites[fn], site)
+}
+
+// calleeSites returns the sites of the mutexes that callee may lock,
+// and of those it unlocks without locking them first.
+func (c *checker) calleeSites(callee *ssa.Function) (sites, unlocks []lockSite) {
+	if callee.Pkg == c.pkg {
+		return c.function(callee)
+	}
+	if obj, ok := callee.Object().(*types.Func); ok && obj.Pkg() != c.pass.Pkg {
+		var fact locks
+		if c.pass.ImportObjectFact(typeparams.OriginMethod(obj), &fact) {
+			return fact.Sites, fact.Unlocks
+		}
+	}
+	return nil, nil
+}
+
+// refOfSite returns the mutex of the caller that the site of a callee
+// refers to, with a zero key if the caller cannot identify it.
+func (c *checker) refOfSite(call *ssa.CallCommon, site lockSite) (lockRef, bool) {
+	switch {
+	case site.Param >= 0:
+		if site.Param >= len(call.Args) {
+			return lockRef{}, false
+		}
+		ref := refOf(call.Args[site.Param])
+		ref.key.path += site.Path
+		ref.name += site.Path
+		if site.Class != "" {
+			ref.class = site.Class
+		}
+		return ref, true
+	case site.Global != "":
+		return lockRef{
+			key:   lockKey{global: site.Global, path: site.Path},
+			class: site.Class,
+			name:  shortName(site.Global) + site.Path,
+		}, true
+	case site.Class != "":
+		return lockRef{class: site.Class, name: shortName(site.Class)}, true
+	}
+	return lockRef{}, false
+}
+
+// siteOf returns the site of a mutex locked by fn.
+func siteOf(fn *ssa.Function, ref lockRef) (lockSite, bool) {
+	site := lockSite{Param: -1, Class: ref.class}
+	if ref.key.global != "" {
+		site.Global, site.Path = ref.key.global, ref.key.path
+	} else if p, ok := ref.key.root.(*ssa.Parameter); ok {
+		for i, param := range fn.Params {
+			if param == p {
+				site.Param, site.Path = i, ref.key.path
+			}
+		}
+	}
+	return site, site.Param >= 0 || site.Global != "" || site.Class != ""
+}
+
+// refOf returns a reference to the mutex whose address is v.
+func refOf(v ssa.Value) lockRef {
+	switch v := v.(type) {
+	case *ssa.FieldAddr:
+		ref := refOf(v.X)
+		name := fieldName(v.X.Type(), v.Field)
+		ref.key.path += "." + name
+		ref.name += "." + name
+		ref.class = ""
+		if ptr, ok := v.X.Type().Underlying().(*types.Pointer); ok {
+			if named, ok := ptr.Elem().(*types.Named); ok && named.Obj().Pkg() != nil {
+				obj := named.Obj()
+				ref.class = obj.Pkg().Path() + "." + obj.Name() + "." + name
+			}
+		}
+		return ref
+	case *ssa.Global:
+		full := v.Pkg.Pkg.Path() + "." + v.Name()
+		return lockRef{key: lockKey{global: full}, class: full, name: v.Name()}
+	case *ssa.Parameter:
+		return lockRef{key: lockKey{root: v}, name: v.Name()}
+	case *ssa.FreeVar:
+		return lockRef{key: lockKey{root: v}, name: v.Name()}
+	case *ssa.Alloc:
+		name := v.Comment
+		if name == "" {
+			name = "mutex"
+		}
+		return lockRef{key: lockKey{root: v}, name: name}
+	}
+	return lockRef{key: lockKey{root: v}, name: "mutex"}
+}
+
+// fieldName returns the name of the field i of the struct pointed to
+// by t.
+func fieldName(t types.Type, i int) string {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		if s, ok := ptr.Elem().Underlying().(*types.Struct); ok && i < s.NumFields() {
+			return s.Field(i).Name()
+		}
+	}
+	return fmt.Sprintf("field%d", i)
+}
+
+// shortName returns the name of a package-level variable, or of a
+// field of a named type, qualified by its package name.
+func shortName(class string) string {
+	if i := strings.LastIndexByte(class, '/'); i >= 0 {
+		return class[i+1:]
+	}
+	return class
+}
+
+// mutexMethod returns the name of fn if it is a method of sync.Mutex
+// or sync.RWMutex.
+func mutexMethod(fn *ssa.Function) (string, bool) {
+	obj, ok := fn.Object().(*types.Func)
+	if !ok || obj.Pkg() == nil || obj.Pkg().Path() != "sync" {
+		return "", false
+	}
+	recv := fn.Signature.Recv()
+	if recv == nil {
+		return "", false
+	}
+	ptr, ok := recv.Type().(*types.Pointer)
+	if !ok {
+		return "", false
+	}
+	named, ok := ptr.Elem().(*types.Named)
+	if !ok || (named.Obj().Name() != "Mutex" && named.Obj().Name() != "RWMutex") {
+		return "", false
+	}
+	return obj.Name(), true
+}
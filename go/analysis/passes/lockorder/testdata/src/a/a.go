@@ -0,0 +1,145 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+import "sync"
+
+type S struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (s *S) double() { // want double:"locks\\(param0.mu\\)"
+	s.mu.Lock()
+	s.mu.Lock() // want "s.mu is locked while it is already held"
+	s.mu.Unlock()
+}
+
+func (s *S) Get() int { // want Get:"locks\\(param0.mu\\)"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n
+}
+
+func (s *S) GetTwice() int { // want GetTwice:"locks\\(param0.mu\\)"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Get() // want "call of Get locks s.mu while it is already held"
+}
+
+func (s *S) unlocked() int { // want unlocked:"locks\\(param0.mu\\)"
+	s.mu.Lock()
+	n := s.n
+	s.mu.Unlock()
+	return n + s.Get()
+}
+
+func (s *S) onePath(ok bool) { // want onePath:"locks\\(param0.mu\\)"
+	if ok {
+		s.mu.Lock()
+	}
+	s.mu.Lock() // not held on every path
+}
+
+func twoInstances(x, y *S) { // want twoInstances:"locks\\(param0.mu, param1.mu\\)"
+	x.mu.Lock()
+	y.mu.Lock() // a different S
+	y.mu.Unlock()
+	x.mu.Unlock()
+}
+
+var (
+	a, b sync.Mutex
+)
+
+func ab() { // want ab:"locks\\(a.a, a.b\\)"
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+}
+
+func ba() { // want ba:"locks\\(a.b, a.a\\)"
+	b.Lock()
+	defer b.Unlock()
+	lockA() // want "a is locked while holding b, in the opposite order to another lock"
+}
+
+func lockA() { // want lockA:"locks\\(a.a\\)"
+	a.Lock()
+	a.Unlock()
+}
+
+type RW struct {
+	mu sync.RWMutex
+}
+
+func (r *RW) read() { // want read:"locks\\(param0.mu\\)"
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+}
+
+func (r *RW) readTwice() { // want readTwice:"locks\\(param0.mu\\)"
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	r.read()
+}
+
+func (r *RW) upgrade() { // want upgrade:"locks\\(param0.mu\\)"
+	r.mu.RLock()
+	r.mu.Lock() // want "r.mu is locked while it is already held"
+}
+
+func local() {
+	var mu sync.Mutex
+	mu.Lock()
+	mu.Lock() // want "mu is locked while it is already held"
+}
+
+// s is captured by the function literal, so the mutex can only be
+// identified by its field.
+func literal(s *S) { // want literal:"locks\\(a.S.mu\\)"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	go func() {
+		s.mu.Lock() // another goroutine
+		s.mu.Unlock()
+	}()
+}
+
+var G sync.Mutex
+
+func LockG() { // want LockG:"locks\\(a.G\\)"
+	G.Lock()
+	defer G.Unlock()
+}
+
+type P struct {
+	mu    sync.Mutex
+	state int
+}
+
+func (p *P) Get() int { // want Get:"locks\\(param0.mu\\)"
+	p.mu.Lock()
+	if p.state == 0 {
+		return p.wait() // wait unlocks p.mu first
+	}
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// wait must be called with p.mu held.
+func (p *P) wait() int { // want wait:"unlocks\\(param0.mu\\)"
+	p.mu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+func (p *P) relock() { // want relock:"locks\\(param0.mu\\)"
+	p.mu.Lock()
+	p.wait()
+	p.mu.Lock()
+}
@@ -0,0 +1,29 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package b
+
+import "a"
+
+type T struct{ s a.S }
+
+func (t *T) get() int { // want get:"locks\\(param0.s.mu\\)"
+	return t.s.Get()
+}
+
+func (t *T) getTwice() int { // want getTwice:"locks\\(param0.s.mu\\)"
+	n := t.get()
+	return n + t.s.GetTwice()
+}
+
+func other(s *a.S) { // want other:"locks\\(param0.mu\\)"
+	s.Get()
+	s.Get()
+}
+
+func global() { // want global:"locks\\(a.G\\)"
+	a.G.Lock()
+	defer a.G.Unlock()
+	a.LockG() // want "call of LockG locks a.G while it is already held"
+}
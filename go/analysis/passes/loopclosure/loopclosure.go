@@ -8,10 +8,12 @@ package loopclosure
 
 import (
 	"go/ast"
+	"go/token"
 	"go/types"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
 	"golang.org/x/tools/go/ast/inspector"
 	"golang.org/x/tools/go/types/typeutil"
 	"golang.org/x/tools/internal/analysisinternal"
@@ -29,6 +31,21 @@ The analyzer only considers references in the last statement of the loop body
 as it is not deep enough to understand the effects of subsequent statements
 which might render the reference benign.
 
+It also reports statements of the loop body that store the address of a
+loop variable in a variable declared outside the loop, or send it on a
+channel, unless the loop is exited at once:
+
+	var ptrs []*T
+	for _, v := range s {
+		ptrs = append(ptrs, &v) // all elements point to the same variable
+	}
+
+As of Go 1.22, each iteration of a loop has its own copy of the variables
+declared by the loop, so only loop variables declared outside the loop,
+as in "for v = range s", are checked. The version of Go is that of the go
+directive of the go.mod file of the module, or that set by a build
+constraint such as //go:build go1.22 of the file.
+
 For example:
 
 	for i, v := range s {
@@ -53,16 +70,30 @@ var Analyzer = &analysis.Analyzer{
 func run(pass *analysis.Pass) (interface{}, error) {
 	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 
+	module := moduleMinor(pass)
+
 	nodeFilter := []ast.Node{
+		(*ast.File)(nil),
 		(*ast.RangeStmt)(nil),
 		(*ast.ForStmt)(nil),
 	}
+	var perIteration bool // whether loops of the current file declare variables per iteration
 	inspect.Preorder(nodeFilter, func(n ast.Node) {
-		// Find the variables updated by the loop statement.
+		if f, ok := n.(*ast.File); ok {
+			perIteration = fileMinor(f, module) >= perIterationMinor
+			return
+		}
+
+		// Find the variables updated by the loop statement that are
+		// shared by its iterations.
 		var vars []types.Object
+		var decl ast.Node // if non-nil, the variables it declares are per iteration
 		addVar := func(expr ast.Expr) {
 			if id, _ := expr.(*ast.Ident); id != nil {
 				if obj := pass.TypesInfo.ObjectOf(id); obj != nil {
+					if decl != nil && decl.Pos() <= obj.Pos() && obj.Pos() < decl.End() {
+						return
+					}
 					vars = append(vars, obj)
 				}
 			}
@@ -71,10 +102,19 @@ func run(pass *analysis.Pass) (interface{}, error) {
 		switch n := n.(type) {
 		case *ast.RangeStmt:
 			body = n.Body
+			if perIteration && n.Tok == token.DEFINE {
+				decl = n
+			}
 			addVar(n.Key)
 			addVar(n.Value)
 		case *ast.ForStmt:
 			body = n.Body
+			if perIteration && n.Init != nil {
+				// Only the variables declared outside the loop are
+				// shared, such as i in
+				//	for j := 0; j < n; i, j = i+1, j+1
+				decl = n.Init
+			}
 			switch post := n.Post.(type) {
 			case *ast.AssignStmt:
 				// e.g. for p = head; p != nil; p = p.next
@@ -90,6 +130,8 @@ func run(pass *analysis.Pass) (interface{}, error) {
 			return
 		}
 
+		checkAddresses(pass, body, vars)
+
 		// Inspect statements to find function literals that may be run outside of
 		// the current loop iteration.
 		//
@@ -153,6 +195,110 @@ func run(pass *analysis.Pass) (interface{}, error) {
 	return nil, nil
 }
 
+// checkAddresses reports the statements of the loop body that store
+// the address of one of the loop variables vars beyond the current
+// iteration, by assigning it to a variable declared outside the loop
+// body or sending it on a channel. A statement followed by a break or
+// return statement is not reported, as the loop ends after it.
+func checkAddresses(pass *analysis.Pass, body *ast.BlockStmt, vars []types.Object) {
+	// outside reports whether the root variable of an assigned
+	// expression such as x, x.f or x[i] is declared outside the body.
+	outside := func(e ast.Expr) bool {
+		for {
+			switch x := e.(type) {
+			case *ast.ParenExpr:
+				e = x.X
+			case *ast.SelectorExpr:
+				e = x.X
+			case *ast.IndexExpr:
+				e = x.X
+			case *ast.StarExpr:
+				e = x.X
+			case *ast.Ident:
+				obj := pass.TypesInfo.ObjectOf(x)
+				return obj != nil && (obj.Pos() < body.Pos() || obj.Pos() >= body.End())
+			default:
+				return false
+			}
+		}
+	}
+	// check reports the expression if it is the address of a loop variable.
+	check := func(e ast.Expr) {
+		addr, ok := analysisutil.Unparen(e).(*ast.UnaryExpr)
+		if !ok || addr.Op != token.AND {
+			return
+		}
+		id, ok := analysisutil.Unparen(addr.X).(*ast.Ident)
+		if !ok {
+			return
+		}
+		obj := pass.TypesInfo.Uses[id]
+		for _, v := range vars {
+			if v == obj {
+				pass.ReportRangef(addr, "address of loop variable %s stored beyond its iteration", id.Name)
+			}
+		}
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		var list []ast.Stmt
+		clause := false // a break statement ends the switch or select statement
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list, clause = n.Body, true
+		case *ast.CommClause:
+			list, clause = n.Body, true
+		}
+		for i, stmt := range list {
+			if i+1 < len(list) && exits(list[i+1], clause) {
+				continue
+			}
+			switch stmt := stmt.(type) {
+			case *ast.AssignStmt:
+				if stmt.Tok != token.ASSIGN || len(stmt.Lhs) != len(stmt.Rhs) {
+					continue
+				}
+				for i, lhs := range stmt.Lhs {
+					if !outside(lhs) {
+						continue
+					}
+					rhs := stmt.Rhs[i]
+					check(rhs)
+					// e.g. ptrs = append(ptrs, &v)
+					if call, ok := analysisutil.Unparen(rhs).(*ast.CallExpr); ok && len(call.Args) > 1 {
+						if id, ok := analysisutil.Unparen(call.Fun).(*ast.Ident); ok {
+							if _, ok := pass.TypesInfo.Uses[id].(*types.Builtin); ok && id.Name == "append" {
+								for _, arg := range call.Args[1:] {
+									check(arg)
+								}
+							}
+						}
+					}
+				}
+			case *ast.SendStmt:
+				check(stmt.Value)
+			}
+		}
+		return true
+	})
+}
+
+// exits reports whether the statement ends the loop. A break statement
+// of a case clause does not.
+func exits(stmt ast.Stmt, clause bool) bool {
+	switch stmt := stmt.(type) {
+	case *ast.BranchStmt:
+		return stmt.Tok == token.BREAK && stmt.Label == nil && !clause
+	case *ast.ReturnStmt:
+		return true
+	}
+	return false
+}
+
 // goInvoke returns a function expression that would be called asynchronously
 // (but not awaited) in another goroutine as a consequence of the call.
 // For example, given the g.Go call below, it returns the function literal expression.
@@ -10,6 +10,7 @@ import (
 	"golang.org/x/tools/go/analysis/analysistest"
 	"golang.org/x/tools/go/analysis/passes/loopclosure"
 	"golang.org/x/tools/internal/analysisinternal"
+	"golang.org/x/tools/internal/testenv"
 	"golang.org/x/tools/internal/typeparams"
 )
 
@@ -29,3 +30,16 @@ func Test(t *testing.T) {
 
 	analysistest.Run(t, testdata, loopclosure.Analyzer, "subtests")
 }
+
+func TestPerIteration(t *testing.T) {
+	// The build constraint of the test file requires Go 1.22.
+	testenv.NeedsGo1Point(t, 22)
+
+	defer func(parallelSubtest bool) {
+		analysisinternal.LoopclosureParallelSubtests = parallelSubtest
+	}(analysisinternal.LoopclosureParallelSubtests)
+	analysisinternal.LoopclosureParallelSubtests = true
+
+	testdata := analysistest.TestData()
+	analysistest.Run(t, testdata, loopclosure.Analyzer, "go122")
+}
@@ -135,3 +135,29 @@ func _() {
 		})
 	}
 }
+
+func _() {
+	var s []int
+	var p *int
+	var ptrs []*int
+	ch := make(chan *int)
+	for _, v := range s {
+		p = &v                  // want "address of loop variable v stored beyond its iteration"
+		ptrs = append(ptrs, &v) // want "address of loop variable v stored beyond its iteration"
+		ch <- &v                // want "address of loop variable v stored beyond its iteration"
+		q := &v                 // OK: q is declared in the loop body
+		_ = q
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0 {
+			p = &i // OK: the loop ends at once
+			break
+		}
+		switch s[i] {
+		case 1:
+			p = &i // want "address of loop variable i stored beyond its iteration"
+			break
+		}
+	}
+	_ = p
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.22
+
+// This file contains tests for the loopclosure checker of loops with
+// variables per iteration.
+
+package go122
+
+import "testing"
+
+var A int
+
+func _() {
+	var s []int
+	for i, v := range s {
+		go func() {
+			println(i, v) // OK: i and v are per iteration
+		}()
+	}
+	for i := 0; i < len(s); i++ {
+		defer func() {
+			println(i) // OK: i is per iteration
+		}()
+	}
+	var ptrs []*int
+	for _, v := range s {
+		ptrs = append(ptrs, &v) // OK: v is per iteration
+	}
+
+	// iteration variables declared outside the loop
+	for A = range s {
+		go func() {
+			println(A) // want "loop variable A captured by func literal"
+		}()
+	}
+	var v int
+	for _, v = range s {
+		ptrs = append(ptrs, &v) // want "address of loop variable v stored beyond its iteration"
+	}
+	j := 0
+	for i := 0; i < len(s); i, j = i+1, j+1 {
+		go func() {
+			println(i, j) // want "loop variable j captured by func literal"
+		}()
+	}
+}
+
+func TestPerIteration(t *testing.T) {
+	var s []int
+	for i := range s {
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+			println(i) // OK: i is per iteration
+		})
+	}
+}
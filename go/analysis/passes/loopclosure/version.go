@@ -0,0 +1,106 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loopclosure
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/analysis"
+)
+
+// perIterationMinor is the minor version of Go, 1.22, from which each
+// iteration of a loop has its own copy of the variables declared by
+// the loop.
+const perIterationMinor = 22
+
+// moduleMinor returns the minor Go version of the go directive of the
+// go.mod file of the module containing the package, or -1 if it is
+// not found.
+func moduleMinor(pass *analysis.Pass) int {
+	for _, f := range pass.Files {
+		dir := filepath.Dir(pass.Fset.File(f.Pos()).Name())
+		for {
+			data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+			if err == nil {
+				mf, err := modfile.ParseLax("go.mod", data, nil)
+				if err != nil || mf.Go == nil {
+					return -1
+				}
+				return minor("go" + mf.Go.Version)
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return -1
+}
+
+// fileMinor returns the minor Go version of the language used by the
+// file, given that of its module. As of Go 1.21, a build constraint
+// such as //go:build go1.22 sets the version of its file.
+func fileMinor(f *ast.File, module int) int {
+	for _, group := range f.Comments {
+		if group.Pos() >= f.Package {
+			break
+		}
+		for _, c := range group.List {
+			if !constraint.IsGoBuild(c.Text) {
+				continue
+			}
+			expr, err := constraint.Parse(c.Text)
+			if err != nil {
+				continue
+			}
+			if v := impliedMinor(expr); v >= 21 {
+				return v
+			}
+		}
+	}
+	return module
+}
+
+// impliedMinor returns the minimum minor Go version implied by the
+// build constraint, or -1 if it implies none.
+func impliedMinor(expr constraint.Expr) int {
+	switch expr := expr.(type) {
+	case *constraint.TagExpr:
+		return minor(expr.Tag)
+	case *constraint.AndExpr:
+		x, y := impliedMinor(expr.X), impliedMinor(expr.Y)
+		if x > y {
+			return x
+		}
+		return y
+	case *constraint.OrExpr:
+		x, y := impliedMinor(expr.X), impliedMinor(expr.Y)
+		if x < y {
+			return x
+		}
+		return y
+	}
+	return -1
+}
+
+// minor returns the minor version of a Go version such as "go1.22" or
+// "go1.21rc1", or -1 if it is not one.
+func minor(version string) int {
+	if !strings.HasPrefix(version, "go1.") {
+		return -1
+	}
+	var v int
+	if _, err := fmt.Sscanf(version[len("go1."):], "%d", &v); err != nil {
+		return -1
+	}
+	return v
+}
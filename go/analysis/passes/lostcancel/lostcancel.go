@@ -14,6 +14,7 @@ import (
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/passes/ctrlflow"
 	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
 	"golang.org/x/tools/go/ast/inspector"
 	"golang.org/x/tools/go/cfg"
 )
@@ -23,7 +24,10 @@ const Doc = `check cancel func returned by context.WithCancel is called
 The cancellation function returned by context.WithCancel, WithTimeout,
 and WithDeadline must be called or the new context will remain live
 until its parent context is cancelled.
-(The background context is never cancelled.)`
+(The background context is never cancelled.)
+
+Where it is safe to do so, the analyzer suggests a fix that defers
+a call of the cancellation function just after its definition.`
 
 var Analyzer = &analysis.Analyzer{
 	Name: "lostcancel",
@@ -80,6 +84,11 @@ func runFunc(pass *analysis.Pass, node ast.Node) {
 	// Maps each cancel variable to its defining ValueSpec/AssignStmt.
 	cancelvars := make(map[*types.Var]ast.Node)
 
+	// Maps each cancel variable to the statement of a block, which
+	// is or contains its definition, after which a call of the cancel
+	// function may be deferred.
+	deferAfter := make(map[*types.Var]ast.Stmt)
+
 	// TODO(adonovan): opt: refactor to make a single pass
 	// over the AST using inspect.WithStack and node types
 	// {FuncDecl,FuncLit,CallExpr,SelectorExpr}.
@@ -133,6 +142,11 @@ func runFunc(pass *analysis.Pass, node ast.Node) {
 			} else if v, ok := pass.TypesInfo.Defs[id].(*types.Var); ok {
 				cancelvars[v] = stmt
 			}
+			if v, ok := pass.TypesInfo.ObjectOf(id).(*types.Var); ok && cancelvars[v] != nil {
+				if s, _ := analysisutil.StatementInBlock(stack[:len(stack)-2]); s != nil {
+					deferAfter[v] = s
+				}
+			}
 		}
 		return true
 	})
@@ -174,7 +188,29 @@ func runFunc(pass *analysis.Pass, node ast.Node) {
 	for v, stmt := range cancelvars {
 		if ret := lostCancelPath(pass, g, v, stmt, sig); ret != nil {
 			lineno := pass.Fset.Position(stmt.Pos()).Line
-			pass.ReportRangef(stmt, "the %s function is not used on all paths (possible context leak)", v.Name())
+			var fixes []analysis.SuggestedFix
+			if after := deferAfter[v]; after != nil && !tupleContains(sig.Results(), v) && onlyCalled(pass, node, v) {
+				// Insert the defer statement on the next line,
+				// after any comment following the statement.
+				file := pass.Fset.File(after.End())
+				if line := file.Line(after.End()); line < file.LineCount() {
+					start := file.LineStart(line + 1)
+					fixes = []analysis.SuggestedFix{{
+						Message: fmt.Sprintf("Defer a call of %s", v.Name()),
+						TextEdits: []analysis.TextEdit{{
+							Pos:     start,
+							End:     start,
+							NewText: []byte(fmt.Sprintf("defer %s()\n", v.Name())),
+						}},
+					}}
+				}
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:            stmt.Pos(),
+				End:            stmt.End(),
+				Message:        fmt.Sprintf("the %s function is not used on all paths (possible context leak)", v.Name()),
+				SuggestedFixes: fixes,
+			})
 			pass.ReportRangef(ret, "this return statement may be reached without using the %s var defined on line %d", v.Name(), lineno)
 		}
 	}
@@ -182,6 +218,40 @@ func runFunc(pass *analysis.Pass, node ast.Node) {
 
 func isCall(n ast.Node) bool { _, ok := n.(*ast.CallExpr); return ok }
 
+// onlyCalled reports whether every use of v in the function
+// is a call of it, outside any nested function literal,
+// so that deferring another call does not change where it is used.
+func onlyCalled(pass *analysis.Pass, fn ast.Node, v *types.Var) bool {
+	ok := true
+	var inspect func(n ast.Node, inLit bool)
+	inspect = func(n ast.Node, inLit bool) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				if n != fn {
+					inspect(n.Body, true)
+					return false
+				}
+			case *ast.CallExpr:
+				if id, isIdent := n.Fun.(*ast.Ident); isIdent && pass.TypesInfo.Uses[id] == v {
+					ok = ok && !inLit
+					for _, arg := range n.Args {
+						inspect(arg, inLit)
+					}
+					return false
+				}
+			case *ast.Ident:
+				if pass.TypesInfo.Uses[n] == v {
+					ok = false
+				}
+			}
+			return ok
+		})
+	}
+	inspect(fn, false)
+	return ok
+}
+
 func hasImport(pkg *types.Package, path string) bool {
 	for _, imp := range pkg.Imports() {
 		if imp.Path() == path {
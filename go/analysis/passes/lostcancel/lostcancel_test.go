@@ -18,4 +18,5 @@ func Test(t *testing.T) {
 		tests = append(tests, "typeparams")
 	}
 	analysistest.Run(t, testdata, lostcancel.Analyzer, tests...)
+	analysistest.RunWithSuggestedFixes(t, testdata, lostcancel.Analyzer, "fix")
 }
@@ -0,0 +1,61 @@
+package fix
+
+import (
+	"context"
+	"time"
+)
+
+var bg = context.Background()
+
+func _(ok bool) {
+	ctx, cancel := context.WithCancel(bg) // want "not used on all paths"
+	if ok {
+		cancel()
+		return
+	}
+	_ = ctx
+} // want "may be reached without using the cancel var"
+
+func _(ok bool) {
+	var ctx, cancel = context.WithTimeout(bg, time.Second) // want "not used on all paths"
+	_ = ctx
+	if ok {
+		cancel()
+	}
+} // want "may be reached without using the cancel var"
+
+// No fix: the cancel function is returned.
+func _(ok bool) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(bg) // want "not used on all paths"
+	if ok {
+		return ctx, cancel
+	}
+	return ctx, nil // want "may be reached without using the cancel var"
+}
+
+// No fix: the cancel function is called by another goroutine.
+func _(ok bool) {
+	_, cancel := context.WithCancel(bg) // want "not used on all paths"
+	if ok {
+		go func() { cancel() }()
+	}
+} // want "may be reached without using the cancel var"
+
+// No fix: the context is created in a loop.
+func _(n int) {
+	for i := 0; i < n; i++ {
+		_, cancel := context.WithCancel(bg) // want "not used on all paths"
+		if i > 0 {
+			cancel()
+		}
+	}
+} // want "may be reached without using the cancel var"
+
+// No fix: the statement is in the init of an if statement.
+func _() {
+	if ctx, cancel := context.WithCancel(bg); ctx != nil { // want "not used on all paths"
+		return // want "may be reached without using the cancel var"
+	} else {
+		cancel()
+	}
+}
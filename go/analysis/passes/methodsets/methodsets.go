@@ -0,0 +1,177 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package methodsets defines an Analyzer that provides the method sets
+// and fields, including promoted ones, of the types of a package and
+// its dependencies, and an index of the selector expressions of the
+// package by the field or method that they select, so that analyzers
+// that need them share one cache. It is only a building block for
+// other analyzers.
+//
+// Example of use in another analysis:
+//
+//	import (
+//		"golang.org/x/tools/go/analysis"
+//		"golang.org/x/tools/go/analysis/passes/methodsets"
+//	)
+//
+//	var Analyzer = &analysis.Analyzer{
+//		...
+//		Requires:       []*analysis.Analyzer{methodsets.Analyzer},
+//	}
+//
+//	func run(pass *analysis.Pass) (interface{}, error) {
+//		msets := pass.ResultOf[methodsets.Analyzer].(*methodsets.MethodSets)
+//		mset := msets.MethodSet(T)
+//		...
+//		return nil, nil
+//	}
+package methodsets
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:             "methodsets",
+	Doc:              "compute method sets and promoted fields for later passes",
+	Run:              run,
+	RunDespiteErrors: true,
+	ResultType:       reflect.TypeOf(new(MethodSets)),
+}
+
+// MethodSets holds the method sets and fields of types, and the
+// selections of a package. Its methods are safe for concurrent use.
+type MethodSets struct {
+	cache      typeutil.MethodSetCache
+	selections map[types.Object][]*ast.SelectorExpr
+
+	mu     sync.Mutex
+	fields typeutil.Map // maps a type to its []Field
+}
+
+// A Field is a field of a struct type, which may be promoted from an
+// embedded field.
+type Field struct {
+	Var      *types.Var
+	Index    []int // the path of field indices to the field, as for types.LookupFieldOrMethod
+	Indirect bool  // the path goes through a pointer
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	m := &MethodSets{selections: make(map[types.Object][]*ast.SelectorExpr)}
+
+	// Compute the method sets of the package-level types of the
+	// package and its dependencies.
+	for _, pkg := range typeutil.Dependencies(pass.Pkg) {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tname, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tname.IsAlias() {
+				continue
+			}
+			if named, ok := tname.Type().(*types.Named); ok && typeparams.ForNamed(named).Len() == 0 {
+				m.cache.MethodSet(named) // and that of *named
+			}
+		}
+	}
+
+	// Index the selections of the package.
+	for sel, selection := range pass.TypesInfo.Selections {
+		obj := selection.Obj()
+		m.selections[obj] = append(m.selections[obj], sel)
+	}
+	for _, sels := range m.selections {
+		sort.Slice(sels, func(i, j int) bool { return sels[i].Pos() < sels[j].Pos() })
+	}
+	return m, nil
+}
+
+// Cache returns the cache of method sets, for use by functions that
+// accept one.
+func (m *MethodSets) Cache() *typeutil.MethodSetCache { return &m.cache }
+
+// MethodSet returns the method set of type T.
+func (m *MethodSets) MethodSet(T types.Type) *types.MethodSet { return m.cache.MethodSet(T) }
+
+// Selections returns the selector expressions of the package that
+// select the field or method obj, in order of position. For a method
+// of an interface or a generic type, obj is the method of the
+// interface or of the generic type's declaration, as reported by
+// types.Selection.Obj.
+func (m *MethodSets) Selections(obj types.Object) []*ast.SelectorExpr { return m.selections[obj] }
+
+// Fields returns the fields of the struct type T, or of the struct
+// type to which T points, that a selector x.f, for x of type T, may
+// select: its own fields and those promoted from its embedded fields,
+// less those that are ambiguous or shadowed by methods. The fields are
+// in order of depth, then of declaration. Fields returns nil for other
+// types.
+func (m *MethodSets) Fields(T types.Type) []Field {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if fields, ok := m.fields.At(T).([]Field); ok {
+		return fields
+	}
+	fields := fieldsOf(T)
+	m.fields.Set(T, fields)
+	return fields
+}
+
+// fieldsOf computes the result of Fields.
+func fieldsOf(T types.Type) []Field {
+	// Find the names of the fields of T and its embedded structs,
+	// breadth first, then keep those that LookupFieldOrMethod
+	// selects.
+	type candidate struct {
+		v     *types.Var
+		depth int
+	}
+	var candidates []candidate
+	seen := make(map[*types.Named]bool)
+	current := []types.Type{T}
+	for depth := 0; len(current) > 0; depth++ {
+		var next []types.Type
+		for _, t := range current {
+			if ptr, ok := t.Underlying().(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			if named, ok := t.(*types.Named); ok {
+				if seen[named] {
+					continue
+				}
+				seen[named] = true
+			}
+			s, ok := t.Underlying().(*types.Struct)
+			if !ok {
+				continue
+			}
+			for i := 0; i < s.NumFields(); i++ {
+				f := s.Field(i)
+				candidates = append(candidates, candidate{f, depth})
+				if f.Embedded() {
+					next = append(next, f.Type())
+				}
+			}
+		}
+		current = next
+	}
+
+	var fields []Field
+	for _, c := range candidates {
+		obj, index, indirect := types.LookupFieldOrMethod(T, true, c.v.Pkg(), c.v.Name())
+		if obj == c.v && len(index) == c.depth+1 {
+			fields = append(fields, Field{c.v, index, indirect})
+		}
+	}
+	return fields
+}
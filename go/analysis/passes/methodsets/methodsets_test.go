@@ -0,0 +1,56 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package methodsets_test
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/passes/methodsets"
+)
+
+func Test(t *testing.T) {
+	testdata := analysistest.TestData()
+	result := analysistest.Run(t, testdata, methodsets.Analyzer, "a")[0]
+
+	msets := result.Result.(*methodsets.MethodSets)
+	scope := result.Pass.Pkg.Scope()
+	T := scope.Lookup("T").Type()
+	Base := scope.Lookup("Base").Type()
+
+	// The method set of T includes those promoted from *Base.
+	if got, want := msets.MethodSet(T).Len(), 2; got != want {
+		t.Errorf("len(MethodSet(T)) = %d, want %d", got, want)
+	}
+	if msets.Cache().MethodSet(T) != msets.MethodSet(T) {
+		t.Errorf("Cache().MethodSet(T) differs from MethodSet(T)")
+	}
+
+	// T.ID is ambiguous, T.Name is a method, and T.Tags is promoted
+	// through a pointer.
+	var fields []string
+	for _, f := range msets.Fields(T) {
+		fields = append(fields, fmt.Sprintf("%s%v%t", f.Var.Name(), f.Index, f.Indirect))
+	}
+	if got, want := strings.Join(fields, " "), "Base[0]false Other[1]false Size[2]false Tags[0 2]true"; got != want {
+		t.Errorf("Fields(T) = %s, want %s", got, want)
+	}
+	if got := msets.Fields(types.Typ[types.Int]); got != nil {
+		t.Errorf("Fields(int) = %v, want nil", got)
+	}
+
+	// Describe is selected twice, and Base.ID once.
+	describe := msets.MethodSet(types.NewPointer(Base)).At(0).Obj()
+	if got := len(msets.Selections(describe)); got != 2 {
+		t.Errorf("%d selections of Describe, want 2", got)
+	}
+	id := Base.Underlying().(*types.Struct).Field(0)
+	if got := len(msets.Selections(id)); got != 1 {
+		t.Errorf("%d selections of Base.ID, want 1", got)
+	}
+}
@@ -0,0 +1,27 @@
+package a
+
+type Base struct {
+	ID   int
+	Name string
+	Tags []string
+}
+
+func (b *Base) Describe() string { return b.Name }
+
+type Other struct {
+	ID int
+}
+
+type T struct {
+	*Base
+	Other
+	Size int
+}
+
+func (T) Name() int { return 0 } // shadows Base.Name
+
+func use(t T) string {
+	_ = t.Size
+	_ = t.Base.ID
+	return t.Describe() + t.Base.Describe()
+}
@@ -51,6 +51,9 @@ and:
 	if p == nil {
 		panic(p)
 	}
+
+Generic functions are checked through their instantiations by the
+package, except for conditions, which may depend on the type arguments.
 `
 
 var Analyzer = &analysis.Analyzer{
@@ -62,16 +65,74 @@ var Analyzer = &analysis.Analyzer{
 
 func run(pass *analysis.Pass) (interface{}, error) {
 	ssainput := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
-	// TODO(48525): ssainput.SrcFuncs is missing fn._Instances(). runFunc will be skipped.
 	for _, fn := range ssainput.SrcFuncs {
-		runFunc(pass, fn)
+		runFunc(pass, fn, pass.Report)
+	}
+
+	// The bodies of generic functions are built only for their
+	// instantiations, so check those instead. Conditions are not
+	// reported, as they may hold only for some type arguments, as
+	// any(x) == nil does for non-interface types; nor are diagnostics
+	// already reported for another instantiation.
+	reported := make(map[token.Pos]bool)
+	for _, fn := range instances(pass, ssainput.SrcFuncs) {
+		runFunc(pass, fn, func(d analysis.Diagnostic) {
+			if d.Category != "cond" && !reported[d.Pos] {
+				reported[d.Pos] = true
+				pass.Report(d)
+			}
+		})
 	}
 	return nil, nil
 }
 
-func runFunc(pass *analysis.Pass, fn *ssa.Function) {
+// instances returns the instantiations of the generic functions of the
+// package that the functions call or refer to, directly or through
+// other instantiations, and their function literals.
+func instances(pass *analysis.Pass, funcs []*ssa.Function) []*ssa.Function {
+	var res []*ssa.Function
+	seen := make(map[*ssa.Function]bool)
+	var visit func(fn *ssa.Function)
+	visit = func(fn *ssa.Function) {
+		var rands []*ssa.Value
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				for _, rand := range instr.Operands(rands[:0]) {
+					f, ok := (*rand).(*ssa.Function)
+					if !ok || seen[f] || !isInstance(pass, f) {
+						continue
+					}
+					seen[f] = true
+					var add func(f *ssa.Function)
+					add = func(f *ssa.Function) {
+						res = append(res, f)
+						visit(f)
+						for _, anon := range f.AnonFuncs {
+							add(anon)
+						}
+					}
+					add(f)
+				}
+			}
+		}
+	}
+	for _, fn := range funcs {
+		visit(fn)
+	}
+	return res
+}
+
+// isInstance reports whether fn is the instantiation of a generic
+// function of the package: a function built from syntax that belongs
+// to no package.
+func isInstance(pass *analysis.Pass, fn *ssa.Function) bool {
+	return fn.Pkg == nil && fn.Parent() == nil && fn.Syntax() != nil &&
+		fn.Object() != nil && fn.Object().Pkg() == pass.Pkg
+}
+
+func runFunc(pass *analysis.Pass, fn *ssa.Function, report func(analysis.Diagnostic)) {
 	reportf := func(category string, pos token.Pos, format string, args ...interface{}) {
-		pass.Report(analysis.Diagnostic{
+		report(analysis.Diagnostic{
 			Pos:      pos,
 			Category: category,
 			Message:  fmt.Sprintf(format, args...),
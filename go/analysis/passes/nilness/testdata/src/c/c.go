@@ -2,7 +2,7 @@ package c
 
 func instantiated[X any](x *X) int {
 	if x == nil {
-		print(*x) // not reported until _Instances are added to SrcFuncs
+		print(*x) // want "nil dereference in load"
 	}
 	return 1
 }
@@ -12,3 +12,43 @@ var g int
 func init() {
 	g = instantiated[int](&g)
 }
+
+// Diagnostics of several instantiations are reported once.
+func twice[X any](x *X) {
+	if x == nil {
+		print(*x) // want "nil dereference in load"
+	}
+	f := func() {
+		var p *X
+		print(*p) // want "nil dereference in load"
+	}
+	f()
+}
+
+func isNil[T interface{}](v T) bool {
+	return interface{}(v) == nil // OK: depends on T
+}
+
+type box[T any] struct{ p *T }
+
+func (b box[T]) get() T {
+	p := b.p
+	if p != nil {
+		return *p
+	}
+	return *p // want "nil dereference in load"
+}
+
+func use() {
+	twice[int](nil)
+	twice[string](nil)
+	_ = isNil(1)
+	_ = isNil[error](nil)
+	_ = box[int]{}.get()
+}
+
+func unused[X any](x *X) {
+	if x == nil {
+		print(*x) // not reported: never instantiated
+	}
+}
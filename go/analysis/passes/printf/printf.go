@@ -13,6 +13,7 @@ import (
 	"go/constant"
 	"go/token"
 	"go/types"
+	"io/ioutil"
 	"reflect"
 	"regexp"
 	"sort"
@@ -30,6 +31,8 @@ import (
 
 func init() {
 	Analyzer.Flags.Var(isPrint, "funcs", "comma-separated list of print function names to check")
+	Analyzer.Flags.Var(wrappers, "wrappers", "comma-separated list of name:kind pairs of print wrappers, where kind is print, printf or errorf")
+	Analyzer.Flags.Var(&wrappersFile{}, "wrappersfile", "file of name kind pairs of print wrappers, one per line")
 }
 
 var Analyzer = &analysis.Analyzer{
@@ -67,6 +70,27 @@ identifier such as "errorf". Either way, if a listed name ends in f, the
 function is assumed to be Printf-like, taking a format string before the
 argument list. Otherwise it is assumed to be Print-like, taking a list
 of arguments with no format string.
+
+The -wrappers flag declares print wrappers whose kind does not follow
+from their names, such as the functions of a logging package, as a
+comma-separated list of name:kind pairs, where the name has one of the
+forms above and the kind is print, printf or errorf:
+
+	example.com/log.Info:printf,example.com/log.Wrap:errorf
+
+The -wrappersfile flag names a file that declares them one per line,
+as a name and a kind separated by spaces; lines starting with # are
+comments. The analyzer exports the declared wrappers of each package as
+facts, so their callers in other packages are checked too.
+
+The analyzer also checks the error-wrapping directive %w of fmt.Errorf
+and its wrappers. It reports %w applied to a non-error argument or used
+by a function other than Errorf, and more than one %w in a call when
+the version of Go in use (before Go 1.20) can wrap only one error. It
+reports an error formatted with %v or %s, when the call wraps no other
+error and its result is checked with errors.Is or errors.As, as the
+check cannot find the formatted error. Each of these diagnostics has a
+fix that changes the verb.
 `
 
 // Kind is a kind of fmt function behavior.
@@ -111,8 +135,11 @@ func (r *Result) Kind(fn *types.Func) Kind {
 			return KindPrint
 		}
 	}
+	if kind := declaredKind(fn); kind != KindNone {
+		return kind
+	}
 
-	return r.funcs[fn]
+	return r.funcs[typeparams.OriginMethod(fn)]
 }
 
 // isWrapper is a fact indicating that a function is a print or printf wrapper.
@@ -137,11 +164,37 @@ func run(pass *analysis.Pass) (interface{}, error) {
 	res := &Result{
 		funcs: make(map[*types.Func]Kind),
 	}
+	exportDeclared(pass, res)
 	findPrintfLike(pass, res)
 	checkCall(pass)
 	return res, nil
 }
 
+// exportDeclared exports the facts of the functions and methods of the
+// package that the -wrappers and -wrappersfile flags declare as print
+// wrappers.
+func exportDeclared(pass *analysis.Pass, res *Result) {
+	if len(wrappers) == 0 {
+		return
+	}
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			fdecl, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			fn, ok := pass.TypesInfo.Defs[fdecl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			if kind := declaredKind(fn); kind != KindNone {
+				pass.ExportObjectFact(fn, &isWrapper{Kind: kind})
+				res.funcs[fn] = kind
+			}
+		}
+	}
+}
+
 type printfWrapper struct {
 	obj     *types.Func
 	fdecl   *ast.FuncDecl
@@ -473,15 +526,19 @@ func checkCall(pass *analysis.Pass) {
 	nodeFilter := []ast.Node{
 		(*ast.CallExpr)(nil),
 	}
-	inspect.Preorder(nodeFilter, func(n ast.Node) {
+	inspect.WithStack(nodeFilter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
 		call := n.(*ast.CallExpr)
 		fn, kind := printfNameAndKind(pass, call)
 		switch kind {
 		case KindPrintf, KindErrorf:
-			checkPrintf(pass, kind, call, fn)
+			checkPrintf(pass, kind, call, fn, stack)
 		case KindPrint:
 			checkPrint(pass, call, fn)
 		}
+		return true
 	})
 }
 
@@ -490,6 +547,9 @@ func printfNameAndKind(pass *analysis.Pass, call *ast.CallExpr) (fn *types.Func,
 	if fn == nil {
 		return nil, 0
 	}
+	// The facts of a method of an instantiated type are those of the
+	// generic method.
+	fn = typeparams.OriginMethod(fn)
 
 	_, ok := isPrint[fn.FullName()]
 	if !ok {
@@ -506,6 +566,9 @@ func printfNameAndKind(pass *analysis.Pass, call *ast.CallExpr) (fn *types.Func,
 		}
 		return fn, kind
 	}
+	if kind := declaredKind(fn); kind != KindNone {
+		return fn, kind
+	}
 
 	var fact isWrapper
 	if pass.ImportObjectFact(fn, &fact) {
@@ -553,6 +616,7 @@ type formatState struct {
 	flags    []byte // the list of # + etc.
 	argNums  []int  // the successive argument numbers that are consumed, adjusted to refer to actual arg in call
 	firstArg int    // Index of first argument after the format in the Printf call.
+	offset   int    // byte offset of the directive in the format string
 	// Used only during parse.
 	pass         *analysis.Pass
 	call         *ast.CallExpr
@@ -563,7 +627,8 @@ type formatState struct {
 }
 
 // checkPrintf checks a call to a formatted print routine such as Printf.
-func checkPrintf(pass *analysis.Pass, kind Kind, call *ast.CallExpr, fn *types.Func) {
+// The stack holds the enclosing nodes of the call.
+func checkPrintf(pass *analysis.Pass, kind Kind, call *ast.CallExpr, fn *types.Func, stack []ast.Node) {
 	format, idx := formatString(pass, call)
 	if idx < 0 {
 		if false {
@@ -583,6 +648,8 @@ func checkPrintf(pass *analysis.Pass, kind Kind, call *ast.CallExpr, fn *types.F
 	argNum := firstArg
 	maxArgNum := firstArg
 	anyIndex := false
+	var wraps []*formatState    // the %w directives
+	var errVerbs []*formatState // the %v and %s directives of error arguments
 	for i, w := 0, 0; i < len(format); i += w {
 		w = 1
 		if format[i] != '%' {
@@ -592,6 +659,7 @@ func checkPrintf(pass *analysis.Pass, kind Kind, call *ast.CallExpr, fn *types.F
 		if state == nil {
 			return
 		}
+		state.offset = i
 		w = len(state.format)
 		if !okPrintfArg(pass, call, state) { // One error per format is enough.
 			return
@@ -602,9 +670,17 @@ func checkPrintf(pass *analysis.Pass, kind Kind, call *ast.CallExpr, fn *types.F
 		if state.verb == 'w' {
 			switch kind {
 			case KindNone, KindPrint, KindPrintf:
-				pass.Reportf(call.Pos(), "%s does not support error-wrapping directive %%w", state.name)
+				pass.Report(analysis.Diagnostic{
+					Pos:            call.Pos(),
+					Message:        fmt.Sprintf("%s does not support error-wrapping directive %%w", state.name),
+					SuggestedFixes: replaceVerbs("Use %v to format the error", pass, call, 'v', state),
+				})
 				return
 			}
+			wraps = append(wraps, state)
+		}
+		if (state.verb == 'v' || state.verb == 's') && len(state.argNums) > 0 && isErrorArg(pass, call, state.argNums[len(state.argNums)-1]) {
+			errVerbs = append(errVerbs, state)
 		}
 		if len(state.argNums) > 0 {
 			// Continue with the next sequential argument.
@@ -616,6 +692,9 @@ func checkPrintf(pass *analysis.Pass, kind Kind, call *ast.CallExpr, fn *types.F
 			}
 		}
 	}
+	if kind == KindErrorf {
+		checkWrapping(pass, call, fn, stack, wraps, errVerbs)
+	}
 	// Dotdotdot is hard.
 	if call.Ellipsis.IsValid() && maxArgNum >= len(call.Args)-1 {
 		return
@@ -632,6 +711,210 @@ func checkPrintf(pass *analysis.Pass, kind Kind, call *ast.CallExpr, fn *types.F
 	}
 }
 
+// checkWrapping checks the error-wrapping directives of a call to an
+// Errorf function: wraps holds its %w directives and errVerbs its %v and
+// %s directives of error arguments.
+func checkWrapping(pass *analysis.Pass, call *ast.CallExpr, fn *types.Func, stack []ast.Node, wraps, errVerbs []*formatState) {
+	// Before Go 1.20, which added errors.Join, fmt.Errorf wraps at most
+	// one error.
+	if len(wraps) > 1 && !joinsErrors(pass.Pkg) {
+		pass.Report(analysis.Diagnostic{
+			Pos:            call.Pos(),
+			End:            call.End(),
+			Message:        fmt.Sprintf("%s call has more than one error-wrapping directive %%w, which this version of Go does not support", fn.FullName()),
+			SuggestedFixes: replaceVerbs("Use %v for all but the first wrapped error", pass, call, 'v', wraps[1:]...),
+		})
+		return
+	}
+	if len(wraps) > 0 || len(errVerbs) == 0 {
+		return
+	}
+	// An error formatted by %v cannot be unwrapped, which is clearly
+	// not intended if the result is given to errors.Is or errors.As.
+	if checker := unwrappedBy(pass, call, stack); checker != "" {
+		state := errVerbs[0]
+		arg := call.Args[state.argNums[len(state.argNums)-1]]
+		pass.Report(analysis.Diagnostic{
+			Pos:            arg.Pos(),
+			End:            arg.End(),
+			Message:        fmt.Sprintf("%s format %s does not wrap error %s, but the result is checked by %s; use %%w to wrap it", state.name, state.format, analysisutil.Format(pass.Fset, arg), checker),
+			SuggestedFixes: replaceVerbs("Use %w to wrap the error", pass, call, 'w', state),
+		})
+	}
+}
+
+// isErrorArg reports whether the argument at index argNum of the call is
+// a non-nil value of error type.
+func isErrorArg(pass *analysis.Pass, call *ast.CallExpr, argNum int) bool {
+	if argNum >= len(call.Args) {
+		return false
+	}
+	tv, ok := pass.TypesInfo.Types[call.Args[argNum]]
+	return ok && tv.IsValue() && !tv.IsNil() && types.ConvertibleTo(tv.Type, errorType)
+}
+
+// joinsErrors reports whether the standard library that pkg depends on
+// has errors.Join, and so fmt.Errorf supports several %w directives.
+// It assumes so if pkg does not depend on package errors.
+func joinsErrors(pkg *types.Package) bool {
+	seen := make(map[*types.Package]bool)
+	var errorsPkg *types.Package
+	var visit func(pkgs []*types.Package)
+	visit = func(pkgs []*types.Package) {
+		for _, p := range pkgs {
+			if errorsPkg != nil || seen[p] {
+				return
+			}
+			seen[p] = true
+			if p.Path() == "errors" {
+				errorsPkg = p
+				return
+			}
+			visit(p.Imports())
+		}
+	}
+	visit(pkg.Imports())
+	return errorsPkg == nil || errorsPkg.Scope().Lookup("Join") != nil
+}
+
+// unwrappedBy returns "errors.Is" or "errors.As" if the result of the
+// call, directly or through a local variable it is assigned to, is the
+// first argument of a later call to that function in the enclosing
+// function, or "" otherwise.
+func unwrappedBy(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) string {
+	checker := func(n ast.Node) (string, ast.Expr) {
+		c, ok := n.(*ast.CallExpr)
+		if !ok || len(c.Args) != 2 {
+			return "", nil
+		}
+		fn, ok := typeutil.Callee(pass.TypesInfo, c).(*types.Func)
+		if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "errors" || fn.Name() != "Is" && fn.Name() != "As" {
+			return "", nil
+		}
+		return fn.FullName(), analysisutil.Unparen(c.Args[0])
+	}
+
+	// Find the parent of the call, and the variable it is assigned to.
+	i := len(stack) - 2
+	for i >= 0 {
+		if _, ok := stack[i].(*ast.ParenExpr); !ok {
+			break
+		}
+		i--
+	}
+	if i < 0 {
+		return ""
+	}
+	var v types.Object
+	switch parent := stack[i].(type) {
+	case *ast.CallExpr:
+		if name, arg := checker(parent); arg == ast.Expr(call) {
+			return name
+		}
+		return ""
+	case *ast.AssignStmt:
+		if len(parent.Lhs) == len(parent.Rhs) {
+			for j, rhs := range parent.Rhs {
+				if id, ok := parent.Lhs[j].(*ast.Ident); ok && analysisutil.Unparen(rhs) == ast.Expr(call) {
+					v = pass.TypesInfo.ObjectOf(id)
+				}
+			}
+		}
+	case *ast.ValueSpec:
+		if len(parent.Names) == len(parent.Values) {
+			for j, value := range parent.Values {
+				if analysisutil.Unparen(value) == ast.Expr(call) {
+					v = pass.TypesInfo.Defs[parent.Names[j]]
+				}
+			}
+		}
+	}
+	if _, ok := v.(*types.Var); !ok || v.Parent() == nil || v.Parent() == v.Pkg().Scope() {
+		return "" // not a local variable
+	}
+
+	var body *ast.BlockStmt
+	for j := i; j >= 0 && body == nil; j-- {
+		switch n := stack[j].(type) {
+		case *ast.FuncDecl:
+			body = n.Body
+		case *ast.FuncLit:
+			body = n.Body
+		}
+	}
+	if body == nil {
+		return ""
+	}
+	var name string
+	ast.Inspect(body, func(n ast.Node) bool {
+		if name != "" || n == nil || n.End() <= call.End() {
+			return false // found, or before the call
+		}
+		if checkerName, arg := checker(n); arg != nil {
+			if id, ok := arg.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == v {
+				name = checkerName
+			}
+		}
+		return true
+	})
+	return name
+}
+
+// replaceVerbs returns a fix with the given message that replaces the
+// verbs of the directives of the call by verb, or nil if the directives
+// cannot be located in the source of its format string.
+func replaceVerbs(msg string, pass *analysis.Pass, call *ast.CallExpr, verb rune, states ...*formatState) []analysis.SuggestedFix {
+	var edits []analysis.TextEdit
+	for _, state := range states {
+		lit, ok := analysisutil.Unparen(call.Args[state.firstArg-1]).(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return nil
+		}
+		offset, ok := sourceOffset(lit.Value, state.offset+len(state.format)-1)
+		if !ok || lit.Value[offset] != byte(state.verb) {
+			return nil
+		}
+		pos := lit.Pos() + token.Pos(offset)
+		edits = append(edits, analysis.TextEdit{Pos: pos, End: pos + 1, NewText: []byte(string(verb))})
+	}
+	return []analysis.SuggestedFix{{Message: msg, TextEdits: edits}}
+}
+
+// sourceOffset returns the offset in the string literal lit of the
+// byte at the given offset of its value.
+func sourceOffset(lit string, offset int) (int, bool) {
+	if len(lit) < 2 {
+		return 0, false
+	}
+	if lit[0] == '`' {
+		// Carriage returns are removed from the value of raw strings.
+		if strings.Contains(lit, "\r") {
+			return 0, false
+		}
+		return 1 + offset, 1+offset < len(lit)-1
+	}
+	s := lit[1 : len(lit)-1]
+	for i, n := 0, 0; i < len(s); {
+		if n == offset {
+			return 1 + i, true
+		}
+		if n > offset {
+			break // within an escape sequence
+		}
+		value, multibyte, tail, err := strconv.UnquoteChar(s[i:], '"')
+		if err != nil {
+			break
+		}
+		if multibyte {
+			n += utf8.RuneLen(value)
+		} else {
+			n++
+		}
+		i = len(s) - len(tail)
+	}
+	return 0, false
+}
+
 // parseFlags accepts any printf flags.
 func (s *formatState) parseFlags() {
 	for s.nbytes < len(s.format) {
@@ -910,7 +1193,15 @@ func okPrintfArg(pass *analysis.Pass, call *ast.CallExpr, state *formatState) (o
 		if reason != "" {
 			details = " (" + reason + ")"
 		}
-		pass.ReportRangef(call, "%s format %s has arg %s of wrong type %s%s, see also https://pkg.go.dev/fmt#hdr-Printing", state.name, state.format, analysisutil.Format(pass.Fset, arg), typeString, details)
+		diag := analysis.Diagnostic{
+			Pos:     call.Pos(),
+			End:     call.End(),
+			Message: fmt.Sprintf("%s format %s has arg %s of wrong type %s%s, see also https://pkg.go.dev/fmt#hdr-Printing", state.name, state.format, analysisutil.Format(pass.Fset, arg), typeString, details),
+		}
+		if state.verb == 'w' {
+			diag.SuggestedFixes = replaceVerbs("Use %v to format the non-error value", pass, call, 'v', state)
+		}
+		pass.Report(diag)
 		return false
 	}
 	if v.typ&argString != 0 && v.verb != 'T' && !bytes.Contains(state.flags, []byte{'#'}) {
@@ -1132,3 +1423,93 @@ func (ss stringSet) Set(flag string) error {
 	}
 	return nil
 }
+
+// wrappers records the print wrappers declared by the -wrappers and
+// -wrappersfile flags. As for isPrint, keys are either values returned
+// by (*types.Func).FullName or case-insensitive identifiers.
+var wrappers = wrapperSet{}
+
+// declaredKind returns the kind of print wrapper that the flags declare
+// fn to be, or KindNone.
+func declaredKind(fn *types.Func) Kind {
+	fn = typeparams.OriginMethod(fn)
+	if kind, ok := wrappers[fn.FullName()]; ok {
+		return kind
+	}
+	return wrappers[strings.ToLower(fn.Name())]
+}
+
+// wrapperSet is a flag of comma-separated name:kind pairs of print
+// wrappers. Note: names without a '.' get lower-cased.
+type wrapperSet map[string]Kind
+
+func (ws wrapperSet) String() string {
+	var list []string
+	for name, kind := range ws {
+		list = append(list, name+":"+kind.String())
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+func (ws wrapperSet) Set(flag string) error {
+	for _, pair := range strings.Split(flag, ",") {
+		i := strings.LastIndex(pair, ":")
+		if i < 0 {
+			return fmt.Errorf("%q is not of the form name:kind", pair)
+		}
+		if err := ws.add(pair[:i], pair[i+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add declares the print wrapper of the given name and kind.
+func (ws wrapperSet) add(name, kind string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("empty string")
+	}
+	if !strings.Contains(name, ".") {
+		name = strings.ToLower(name)
+	}
+	switch kind {
+	case "print":
+		ws[name] = KindPrint
+	case "printf":
+		ws[name] = KindPrintf
+	case "errorf":
+		ws[name] = KindErrorf
+	default:
+		return fmt.Errorf("invalid kind %q of %s: want print, printf or errorf", kind, name)
+	}
+	return nil
+}
+
+// wrappersFile is a flag naming files of print wrappers, whose
+// declarations it adds to wrappers.
+type wrappersFile struct{ names []string }
+
+func (wf *wrappersFile) String() string { return strings.Join(wf.names, ",") }
+
+func (wf *wrappersFile) Set(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: want name and kind", filename, i+1)
+		}
+		if err := wrappers.add(fields[0], fields[1]); err != nil {
+			return fmt.Errorf("%s:%d: %v", filename, i+1, err)
+		}
+	}
+	wf.names = append(wf.names, filename)
+	return nil
+}
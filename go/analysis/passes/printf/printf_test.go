@@ -5,6 +5,7 @@
 package printf_test
 
 import (
+	"path/filepath"
 	"testing"
 
 	"golang.org/x/tools/go/analysis/analysistest"
@@ -22,3 +23,19 @@ func Test(t *testing.T) {
 	}
 	analysistest.Run(t, testdata, printf.Analyzer, tests...)
 }
+
+func TestWrapFixes(t *testing.T) {
+	testdata := analysistest.TestData()
+	analysistest.RunWithSuggestedFixes(t, testdata, printf.Analyzer, "wrap")
+}
+
+func TestWrappers(t *testing.T) {
+	testdata := analysistest.TestData()
+	if err := printf.Analyzer.Flags.Set("wrappers", "declared.Info:printf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := printf.Analyzer.Flags.Set("wrappersfile", filepath.Join(testdata, "wrappers.txt")); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.Run(t, testdata, printf.Analyzer, "declared", "declareduse")
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the print wrappers declared by the
+// -wrappers and -wrappersfile flags.
+
+package declared
+
+// A Logger hands its messages to a sink, which hides the formatting
+// from the analyzer.
+type Logger struct {
+	sink func(format string, args []interface{})
+}
+
+// Info is declared a printf wrapper by -wrappers.
+func Info(format string, args ...interface{}) { // want Info:"printfWrapper"
+	std.sink(format, args)
+}
+
+// Wrap is declared an errorf wrapper by -wrappersfile.
+func Wrap(format string, args ...interface{}) error { // want Wrap:"errorfWrapper"
+	return nil
+}
+
+// Log is declared a print wrapper by -wrappersfile.
+func (l *Logger) Log(args ...interface{}) { // want Log:"printWrapper"
+	l.sink("", args)
+}
+
+// Debug is not declared.
+func Debug(format string, args ...interface{}) {
+	std.sink(format, args)
+}
+
+var std = &Logger{}
+
+func _() {
+	Info("%d", "x") // want "Info format %d has arg \"x\" of wrong type string"
+	Debug("%d", "x")
+}
@@ -0,0 +1,20 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the facts of declared print wrappers.
+
+package declareduse
+
+import "declared"
+
+func _(l *declared.Logger, err error) {
+	declared.Info("%d items", 3)
+	declared.Info("%s items", 3) // want "declared.Info format %s has arg 3 of wrong type int"
+	declared.Info("%d items")    // want "declared.Info format %d reads arg #1, but call has 0 args"
+	_ = declared.Wrap("wrapping: %w", err)
+	_ = declared.Wrap("wrapping: %w", "oops") // want "declared.Wrap format %w has arg \"oops\" of wrong type string"
+	l.Log("items", 3)
+	l.Log("items: %d", 3) // want "\\(\\*declared.Logger\\).Log call has possible formatting directive %d"
+	declared.Debug("%s items", 3)
+}
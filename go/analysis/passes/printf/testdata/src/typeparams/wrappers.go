@@ -22,3 +22,18 @@ func (*N[P]) PtrWrapf(p P, format string, args ...interface{}) { // want PtrWrap
 func Printf[P any](p P, format string, args ...interface{}) { // want Printf:"printfWrapper"
 	fmt.Printf(format, args...)
 }
+
+// Wrappers of instantiated generic types and functions are checked.
+func _() {
+	var n N[int]
+	n.Wrapf(1, "%d", "x")     // want `\(typeparams.N\[P\]\).Wrapf format %d has arg "x" of wrong type string`
+	n.PtrWrapf(1, "%d", "x")  // want `\(\*typeparams.N\[P\]\).PtrWrapf format %d has arg "x" of wrong type string`
+	Printf(1, "%d", "x")      // want `typeparams.Printf format %d has arg "x" of wrong type string`
+	Printf[int](1, "%d", "x") // want `typeparams.Printf format %d has arg "x" of wrong type string`
+}
+
+type Logger[T any] struct{ n N[T] }
+
+func (l Logger[T]) Logf(p T, format string, args ...interface{}) { // want Logf:"printfWrapper"
+	l.n.Wrapf(p, format, args...)
+}
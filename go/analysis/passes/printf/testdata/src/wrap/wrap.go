@@ -0,0 +1,59 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the checks of the error-wrapping
+// directive %w.
+
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var errNotFound = errors.New("not found")
+
+func nonError(n int, err error) {
+	_ = fmt.Errorf("%w", n)              // want `fmt.Errorf format %w has arg n of wrong type int`
+	_ = fmt.Errorf("count %d: %w", n, n) // want `fmt.Errorf format %w has arg n of wrong type int`
+	fmt.Printf("error: %w\n", err)       // want `fmt.Printf does not support error-wrapping directive %w`
+	_ = fmt.Errorf(`raw %w`, "x")        // want `fmt.Errorf format %w has arg "x" of wrong type string`
+	_ = fmt.Errorf("tab\t%w", "x")       // want `fmt.Errorf format %w has arg "x" of wrong type string`
+}
+
+func multiple(err1, err2 error) {
+	_ = fmt.Errorf("%w: %w", err1, err2) // OK: errors.Join exists
+}
+
+func unwrapped(err error) bool {
+	err2 := fmt.Errorf("opening config: %v", err) // want `fmt.Errorf format %v does not wrap error err, but the result is checked by errors.Is; use %w to wrap it`
+	if errors.Is(err2, os.ErrNotExist) {
+		return true
+	}
+
+	var pathErr *os.PathError
+	if errors.As(fmt.Errorf("%s", err), &pathErr) { // want `fmt.Errorf format %s does not wrap error err, but the result is checked by errors.As; use %w to wrap it`
+		return true
+	}
+
+	var err3 = fmt.Errorf("%d: %v", 1, err) // want `fmt.Errorf format %v does not wrap error err, but the result is checked by errors.Is`
+	return errors.Is(err3, errNotFound)
+}
+
+func wrappedOrUnchecked(err error) error {
+	err2 := fmt.Errorf("%v", err) // OK: not checked
+	if err2 != nil {
+		return err2
+	}
+	err3 := fmt.Errorf("%w: %v", errNotFound, err) // OK: another error is wrapped
+	if errors.Is(err3, errNotFound) {
+		return nil
+	}
+	if errors.Is(err, errNotFound) {
+		err4 := fmt.Errorf("%v", err) // OK: checked before the call
+		return err4
+	}
+	return fmt.Errorf("%v", "x") // OK: not an error
+}
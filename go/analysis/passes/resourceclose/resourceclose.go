@@ -0,0 +1,429 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package resourceclose defines an Analyzer that checks for failure to
+// close resources such as files, network connections and the rows of
+// SQL queries.
+package resourceclose
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/analysis/passes/internal/closecheck"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+const Doc = `check that resources such as files and connections are closed
+
+A value of a closer type, such as *os.File, net.Conn, *sql.Rows or
+*gzip.Writer, returned by a function that opens it, such as os.Open,
+net.Dial, (*sql.DB).Query or gzip.NewWriter, must be closed, or the
+resource that it holds leaks:
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+The analyzer reports a resource for which there is a path through the
+control-flow graph, from the call to a return statement, that neither
+closes it nor passes it on to code that may close it, for example by
+returning it or storing it. Paths on which the error of the call is not
+nil, or the resource is nil, need not close it.
+
+Where it is safe to do so, the analyzer suggests a fix that defers the
+call of Close just after the check of the error.
+
+The -types flag adds a comma-separated list of named types, such as
+example.com/db.Session, to the closer types. Pointers to them, and the
+types that implement those that are interfaces, are closer types too.
+A function that returns a new value of a closer type, from a composite
+literal or from a call of another function that opens one, opens it
+too: the analyzer records such functions as facts, so that their
+callers in other packages are checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "resourceclose",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		inspect.Analyzer,
+		ctrlflow.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(opener)},
+}
+
+// closerTypes records the qualified names of the closer types.
+// The -types flag adds to this set.
+var closerTypes = stringSetFlag{
+	"compress/gzip.Writer": true,
+	"database/sql.Rows":    true,
+	"net.Conn":             true,
+	"os.File":              true,
+}
+
+func init() {
+	Analyzer.Flags.Var(&closerTypes, "types", "comma-separated list of additional closer types, such as example.com/db.Session")
+}
+
+// opener is the fact that a function opens a resource: it returns a
+// new value of a closer type as its first result.
+type opener struct{}
+
+func (*opener) AFact() {}
+
+func (*opener) String() string { return "opener" }
+
+// openers records the functions of the standard library that open
+// resources, by the values returned by (*types.Func).FullName. As in
+// the printf analyzer, they are listed even though the analysis is
+// capable of deducing them, as the driver may not apply analyzers to
+// standard packages.
+var openers = map[string]bool{
+	"compress/gzip.NewWriter":           true,
+	"compress/gzip.NewWriterLevel":      true,
+	"crypto/tls.Dial":                   true,
+	"crypto/tls.DialWithDialer":         true,
+	"(*database/sql.Conn).QueryContext": true,
+	"(*database/sql.DB).Query":          true,
+	"(*database/sql.DB).QueryContext":   true,
+	"(*database/sql.Stmt).Query":        true,
+	"(*database/sql.Stmt).QueryContext": true,
+	"(*database/sql.Tx).Query":          true,
+	"(*database/sql.Tx).QueryContext":   true,
+	"io/ioutil.TempFile":                true,
+	"net.Dial":                          true,
+	"net.DialIP":                        true,
+	"net.DialTCP":                       true,
+	"net.DialTimeout":                   true,
+	"net.DialUDP":                       true,
+	"net.DialUnix":                      true,
+	"(*net.Dialer).Dial":                true,
+	"(*net.Dialer).DialContext":         true,
+	"(net.Listener).Accept":             true,
+	"(*net.TCPListener).AcceptTCP":      true,
+	"(*net.UnixListener).AcceptUnix":    true,
+	"os.Create":                         true,
+	"os.CreateTemp":                     true,
+	"os.Open":                           true,
+	"os.OpenFile":                       true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	closers := newCloserSet(pass.Pkg)
+	if closers.empty() {
+		return nil, nil // no closer types are visible to the package
+	}
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	local := findOpeners(pass, inspect, closers)
+	for fn := range local {
+		pass.ExportObjectFact(fn, new(opener))
+	}
+	isOpener := func(call *ast.CallExpr) bool {
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok {
+			return false
+		}
+		fn = typeparams.OriginMethod(fn)
+		sig := fn.Type().(*types.Signature)
+		if sig.Results().Len() == 0 || !closers.contains(sig.Results().At(0).Type()) {
+			return false
+		}
+		return openers[fn.FullName()] || local[fn] || pass.ImportObjectFact(fn, new(opener))
+	}
+
+	nodeTypes := []ast.Node{
+		(*ast.FuncLit)(nil),
+		(*ast.FuncDecl)(nil),
+	}
+	inspect.Preorder(nodeTypes, func(n ast.Node) {
+		runFunc(pass, n, isOpener)
+	})
+	return nil, nil
+}
+
+// A closerSet is the set of closer types visible to a package.
+type closerSet struct {
+	named  map[*types.TypeName]bool // the named closer types
+	ifaces []*types.Interface       // the closer types that are interfaces
+}
+
+// newCloserSet returns the closer types declared by the package or
+// the packages that it imports, directly or indirectly.
+func newCloserSet(pkg *types.Package) *closerSet {
+	cs := &closerSet{named: make(map[*types.TypeName]bool)}
+	seen := make(map[*types.Package]bool)
+	var visit func(pkg *types.Package)
+	visit = func(pkg *types.Package) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		for _, name := range pkg.Scope().Names() {
+			obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+			if !ok || !closerTypes[pkg.Path()+"."+name] {
+				continue
+			}
+			cs.named[obj] = true
+			if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+				cs.ifaces = append(cs.ifaces, iface)
+			}
+		}
+		for _, imp := range pkg.Imports() {
+			visit(imp)
+		}
+	}
+	visit(pkg)
+	return cs
+}
+
+func (cs *closerSet) empty() bool { return len(cs.named) == 0 }
+
+// contains reports whether T is a closer type.
+func (cs *closerSet) contains(T types.Type) bool {
+	U := T
+	if ptr, ok := U.(*types.Pointer); ok {
+		U = ptr.Elem()
+	}
+	if named, ok := U.(*types.Named); ok && cs.named[named.Obj()] {
+		return true
+	}
+	for _, iface := range cs.ifaces {
+		if !types.IsInterface(T) && types.Implements(T, iface) {
+			return true
+		}
+	}
+	return false
+}
+
+// findOpeners returns the functions and methods of the package that
+// open resources, iterating to a fixed point for those that return the
+// resources opened by others.
+func findOpeners(pass *analysis.Pass, inspect *inspector.Inspector, closers *closerSet) map[*types.Func]bool {
+	type candidate struct {
+		fn   *types.Func
+		decl *ast.FuncDecl
+	}
+	var candidates []candidate
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(n ast.Node) {
+		decl := n.(*ast.FuncDecl)
+		fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func)
+		if !ok || decl.Body == nil {
+			return
+		}
+		if results := fn.Type().(*types.Signature).Results(); results.Len() > 0 && closers.contains(results.At(0).Type()) {
+			candidates = append(candidates, candidate{fn, decl})
+		}
+	})
+
+	local := make(map[*types.Func]bool)
+	// isNew reports whether e is a new value of its closer type.
+	isNew := func(e ast.Expr) bool {
+		switch e := analysisutil.Unparen(e).(type) {
+		case *ast.CompositeLit:
+			return true
+		case *ast.UnaryExpr:
+			_, ok := analysisutil.Unparen(e.X).(*ast.CompositeLit)
+			return ok && e.Op == token.AND
+		case *ast.CallExpr:
+			if id, ok := analysisutil.Unparen(e.Fun).(*ast.Ident); ok {
+				if b, ok := pass.TypesInfo.Uses[id].(*types.Builtin); ok {
+					return b.Name() == "new"
+				}
+			}
+			fn, ok := typeutil.Callee(pass.TypesInfo, e).(*types.Func)
+			if !ok {
+				return false
+			}
+			fn = typeparams.OriginMethod(fn)
+			sig := fn.Type().(*types.Signature)
+			return sig.Results().Len() > 0 && closers.contains(sig.Results().At(0).Type()) &&
+				(openers[fn.FullName()] || local[fn] || pass.ImportObjectFact(fn, new(opener)))
+		}
+		return false
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, c := range candidates {
+			if !local[c.fn] && returnsNew(pass, c.fn, c.decl, isNew) {
+				local[c.fn] = true
+				changed = true
+			}
+		}
+	}
+	return local
+}
+
+// returnsNew reports whether a return statement of the function
+// returns a new value, according to isNew, as its first result, either
+// directly or through a variable to which it is assigned.
+func returnsNew(pass *analysis.Pass, fn *types.Func, decl *ast.FuncDecl, isNew func(ast.Expr) bool) bool {
+	fresh := make(map[types.Object]bool)
+	define := func(lhs []ast.Expr, rhs []ast.Expr) {
+		if len(lhs) == 0 {
+			return
+		}
+		if len(rhs) == 1 && len(lhs) > 1 {
+			lhs = lhs[:1] // a call with several results
+		}
+		for i := range rhs {
+			if id, ok := lhs[i].(*ast.Ident); ok && isNew(rhs[i]) {
+				if obj := pass.TypesInfo.ObjectOf(id); obj != nil {
+					fresh[obj] = true
+				}
+			}
+		}
+	}
+	var returns []*ast.ReturnStmt
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.AssignStmt:
+			if len(n.Lhs) == len(n.Rhs) || len(n.Rhs) == 1 {
+				define(n.Lhs, n.Rhs)
+			}
+		case *ast.ValueSpec:
+			var lhs []ast.Expr
+			for _, name := range n.Names {
+				lhs = append(lhs, name)
+			}
+			if len(lhs) == len(n.Values) || len(n.Values) == 1 {
+				define(lhs, n.Values)
+			}
+		case *ast.ReturnStmt:
+			returns = append(returns, n)
+		}
+		return true
+	})
+	for _, ret := range returns {
+		if len(ret.Results) == 0 {
+			if fresh[fn.Type().(*types.Signature).Results().At(0)] {
+				return true
+			}
+			continue
+		}
+		first := ret.Results[0]
+		if id, ok := analysisutil.Unparen(first).(*ast.Ident); ok && fresh[pass.TypesInfo.Uses[id]] {
+			return true
+		}
+		if isNew(first) {
+			return true
+		}
+	}
+	return false
+}
+
+// closer describes the uses of resource variables that close them.
+var closer = &closecheck.Closer{Harmless: harmless, IsClose: isCloseCall}
+
+func runFunc(pass *analysis.Pass, node ast.Node, isOpener func(*ast.CallExpr) bool) {
+	discarded := func(call *ast.CallExpr) {
+		T := pass.TypesInfo.TypeOf(call)
+		if tuple, ok := T.(*types.Tuple); ok {
+			T = tuple.At(0).Type()
+		}
+		pass.ReportRangef(call, "the %s returned by %s should be closed, not discarded, to avoid a resource leak",
+			types.TypeString(T, types.RelativeTo(pass.Pkg)), analysisutil.Format(pass.Fset, call.Fun))
+	}
+	resources := closecheck.Find(pass, node, isOpener, discarded)
+	closer.Unclosed(pass, node, resources, func(res *closecheck.Resource, ret *ast.ReturnStmt, deferable bool) {
+		v := res.Var
+		lineno := pass.Fset.Position(res.Stmt.Pos()).Line
+		var fixes []analysis.SuggestedFix
+		if deferable {
+			fixes = closecheck.DeferFix(pass, res, v.Name()+".Close")
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:            res.Stmt.Pos(),
+			End:            res.Stmt.End(),
+			Message:        fmt.Sprintf("%s is not closed on all paths (possible resource leak)", v.Name()),
+			SuggestedFixes: fixes,
+		})
+		pass.ReportRangef(ret, "this return statement may be reached without closing %s, defined on line %d", v.Name(), lineno)
+	})
+}
+
+// harmless reports whether the use of the resource variable at the top
+// of the stack cannot close it: a comparison with nil, an assignment to
+// the variable, a selection of a field or method other than Close, or
+// its use as an argument that cannot be closed, such as an io.Reader.
+func harmless(pass *analysis.Pass, stack []ast.Node) bool {
+	id := stack[len(stack)-1].(*ast.Ident)
+	var p ast.Node
+	var x ast.Expr = id // the use, with any parentheses
+	for i := len(stack) - 2; i >= 0; i-- {
+		if paren, ok := stack[i].(*ast.ParenExpr); ok {
+			x = paren
+			continue
+		}
+		p = stack[i]
+		break
+	}
+	switch p := p.(type) {
+	case *ast.BinaryExpr:
+		return p.Op == token.EQL || p.Op == token.NEQ
+	case *ast.AssignStmt:
+		for _, lhs := range p.Lhs {
+			if lhs == x {
+				return true
+			}
+		}
+	case *ast.SelectorExpr:
+		return p.Sel.Name != "Close" // another field or method
+	case *ast.CallExpr:
+		return closecheck.OpaqueArg(pass, p, x)
+	}
+	return false
+}
+
+// isCloseCall reports whether the identifier at the top of the stack
+// is the operand of a call v.Close().
+func isCloseCall(stack []ast.Node) bool {
+	if len(stack) < 3 {
+		return false
+	}
+	close, ok1 := stack[len(stack)-2].(*ast.SelectorExpr)
+	call, ok2 := stack[len(stack)-3].(*ast.CallExpr)
+	return ok1 && ok2 && close.X == stack[len(stack)-1] && close.Sel.Name == "Close" && call.Fun == close
+}
+
+// stringSetFlag is a set of qualified type names, such as os.File.
+// Unlike the flags of other analyzers, Set adds to the set.
+type stringSetFlag map[string]bool
+
+func (ss *stringSetFlag) String() string {
+	var items []string
+	for item := range *ss {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (ss *stringSetFlag) Set(s string) error {
+	for _, name := range strings.Split(s, ",") {
+		if name == "" {
+			continue
+		}
+		if !strings.Contains(name, ".") {
+			return fmt.Errorf("closer type %q is not of the form path.Name", name)
+		}
+		(*ss)[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package resourceclose_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/passes/resourceclose"
+)
+
+func Test(t *testing.T) {
+	testdata := analysistest.TestData()
+	if err := resourceclose.Analyzer.Flags.Set("types", "b.Handle"); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.RunWithSuggestedFixes(t, testdata, resourceclose.Analyzer, "a")
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"net"
+	"os"
+
+	"b"
+)
+
+func closed(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
+
+func notClosed(name string) (string, error) {
+	f, err := os.Open(name) // want "f is not closed on all paths"
+	if err != nil {
+		return "", err
+	}
+	return bufio.NewReader(f).ReadString('\n') // want "may be reached without closing f, defined on line 28"
+}
+
+func earlyReturn(addr string, msg []byte) error {
+	conn, err := net.Dial("tcp", addr) // want "conn is not closed on all paths"
+	if err != nil {
+		return err
+	}
+	if len(msg) == 0 {
+		return nil // want "may be reached without closing conn"
+	}
+	_, err = conn.Write(msg)
+	conn.Close()
+	return err
+}
+
+func rows(db *sql.DB) (int, error) {
+	rows, err := db.Query("SELECT 1") // want "rows is not closed on all paths"
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err() // want "may be reached without closing rows"
+}
+
+func compress(w io.Writer, data []byte) error {
+	zw := gzip.NewWriter(w) // want "zw is not closed on all paths"
+	_, err := zw.Write(data)
+	return err // want "may be reached without closing zw"
+}
+
+func compressed(w io.Writer, data []byte) error {
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(data); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+func discarded(name string) {
+	os.Create(name)                                // want `the \*os.File returned by os.Create should be closed, not discarded`
+	_, _ = os.Create(name)                         // want `the \*os.File returned by os.Create should be closed, not discarded`
+	var _, err = os.OpenFile(name, os.O_RDONLY, 0) // want `the \*os.File returned by os.OpenFile should be closed, not discarded`
+	_ = err
+}
+
+func returned(name string) (*os.File, error) { // want returned:"opener"
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type logger struct{ f *os.File }
+
+func stored(name string) (*logger, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &logger{f}, nil
+}
+
+func passed(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	return closeIt(f)
+}
+
+func closeIt(c io.Closer) error { return c.Close() }
+
+func deferredLiteral(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+	return nil
+}
+
+func loop(names []string) {
+	for _, name := range names {
+		f, err := os.Open(name) // want "f is not closed on all paths"
+		if err != nil {
+			continue
+		}
+		if fi, err := f.Stat(); err == nil && fi.IsDir() {
+			continue
+		}
+		f.Close()
+	}
+} // want "may be reached without closing f"
+
+// Openers of other packages are known from facts, and closer types
+// from the -types flag.
+func facts(name string) error {
+	h := b.Open(name) // want "h is not closed on all paths"
+	if h.Name() == "" {
+		return nil // want "may be reached without closing h"
+	}
+	h.Close()
+
+	dup, err := h.Dup() // want "dup is not closed on all paths"
+	if err != nil {
+		return err
+	}
+	println(dup.Name())
+
+	log, err := b.OpenLog(name) // want "log is not closed on all paths"
+	if err != nil {
+		return err
+	}
+	println(log.Name())
+
+	out := b.Stdout()
+	println(out.Name())
+	return nil // want "may be reached without closing dup" "may be reached without closing log"
+}
+
+// Local openers are found too.
+func openLocal(name string) (*os.File, error) { // want openLocal:"opener"
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func callLocal(name string) {
+	f, err := openLocal(name) // want "f is not closed on all paths"
+	if err != nil {
+		return
+	}
+	println(f.Name())
+} // want "may be reached without closing f"
@@ -0,0 +1,39 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package b
+
+import "os"
+
+// A Handle is a closer type, by the -types flag.
+type Handle struct{ name string }
+
+func (h *Handle) Close() error { return nil }
+
+func (h *Handle) Name() string { return h.name }
+
+func Open(name string) *Handle { // want Open:"opener"
+	return &Handle{name}
+}
+
+func (h *Handle) Dup() (*Handle, error) { // want Dup:"opener"
+	dup := Open(h.name)
+	return dup, nil
+}
+
+func OpenLog(name string) (*os.File, error) { // want OpenLog:"opener"
+	return os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+}
+
+func CreateLog(name string) (f *os.File, err error) { // want CreateLog:"opener"
+	f, err = os.Create(name)
+	return
+}
+
+var stdout = os.Stdout
+
+// Stdout is not an opener: it returns an existing file.
+func Stdout() *os.File {
+	return stdout
+}
@@ -0,0 +1,353 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package secrets defines an Analyzer that reports string literals that
+// look like hardcoded credentials.
+package secrets
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const Doc = `check for hardcoded secrets and credentials
+
+The secrets analyzer reports string literals that look like API keys,
+tokens, passwords or private keys. A literal is reported if it has the
+form of a well-known kind of credential, such as an AWS access key ID
+("AKIA...") or a GitHub token ("ghp_..."), or if it is the value of a
+variable, constant, field, map key or parameter whose name suggests a
+secret, such as
+
+	const apiKey = "6f1ed002ab5595859014ebf0951522d9"
+
+	req.SetBasicAuth("admin", "hunter22") // the password parameter
+
+or is compared with a variable of such a name. Values of names like
+password must be at least 6 characters long; values of names like
+token or secret must be at least 16 characters long and random-looking,
+with an entropy of at least -entropy bits per character. Test files
+are not checked.
+
+The -allow flag is a comma-separated list of regular expressions: a
+literal that matches one of them, such as a placeholder, is not
+reported. The -allownames flag is a comma-separated list of names
+whose values are not checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "secrets",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// flags
+var (
+	allow      regexpsFlag
+	allowNames stringSetFlag
+	entropy    = 3.0
+)
+
+func init() {
+	Analyzer.Flags.Var(&allow, "allow",
+		"comma-separated list of regular expressions matching literals that are not secrets")
+	Analyzer.Flags.Var(&allowNames, "allownames",
+		"comma-separated list of names whose values are not checked")
+	Analyzer.Flags.Float64Var(&entropy, "entropy", entropy,
+		"minimum entropy in bits per character of the values of token and secret names")
+}
+
+// A credential is a well-known kind of credential.
+type credential struct {
+	desc string
+	rx   *regexp.Regexp
+}
+
+var credentials = []credential{
+	{"AWS access key ID", regexp.MustCompile(`^(AKIA|ASIA)[0-9A-Z]{16}$`)},
+	{"GitHub token", regexp.MustCompile(`^(gh[opusr]_[0-9A-Za-z]{36}|github_pat_[0-9A-Za-z_]{82})$`)},
+	{"GitLab token", regexp.MustCompile(`^glpat-[0-9A-Za-z_-]{20}$`)},
+	{"Slack token", regexp.MustCompile(`^xox[abprs]-[0-9A-Za-z-]{10,}$`)},
+	{"Stripe secret key", regexp.MustCompile(`^[rs]k_live_[0-9A-Za-z]{24,}$`)},
+	{"Google API key", regexp.MustCompile(`^AIza[0-9A-Za-z_-]{35}$`)},
+	{"private key", regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`)},
+}
+
+var (
+	// passwordRx matches the names of passwords.
+	passwordRx = regexp.MustCompile(`(?i)passw(or)?d|pwd|passphrase`)
+	// secretRx matches the names of other secrets.
+	secretRx = regexp.MustCompile(`(?i)secret|token|api_?key|access_?key|private_?key|credential`)
+	// placeholderRx matches values that refer to secrets without
+	// being one, such as the names of environment variables.
+	placeholderRx = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$|^\$|\{\{|://|%`)
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	reported := make(map[*ast.BasicLit]bool)
+	report := func(lit *ast.BasicLit, format string, args ...interface{}) {
+		if !reported[lit] {
+			reported[lit] = true
+			pass.ReportRangef(lit, format, args...)
+		}
+	}
+	// named checks the literal value of the expression e, if any, given
+	// to the name.
+	named := func(name string, e ast.Expr) {
+		lit, value, ok := stringLit(e)
+		if ok && !allowNames[name] && !allowed(value) && isSecret(name, value) {
+			report(lit, "possible hardcoded secret in %s", name)
+		}
+	}
+
+	nodeFilter := []ast.Node{
+		(*ast.File)(nil),
+		(*ast.ImportSpec)(nil),
+		(*ast.Field)(nil),
+		(*ast.ValueSpec)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.KeyValueExpr)(nil),
+		(*ast.BinaryExpr)(nil),
+		(*ast.CallExpr)(nil),
+		(*ast.BasicLit)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.File:
+			// Tests often contain fake credentials.
+			if strings.HasSuffix(pass.Fset.File(n.Pos()).Name(), "_test.go") {
+				for _, lit := range fileLits(n) {
+					reported[lit] = true
+				}
+			}
+
+		case *ast.ImportSpec:
+			reported[n.Path] = true
+
+		case *ast.Field:
+			if n.Tag != nil {
+				reported[n.Tag] = true
+			}
+
+		case *ast.ValueSpec:
+			if len(n.Names) == len(n.Values) {
+				for i, name := range n.Names {
+					named(name.Name, n.Values[i])
+				}
+			}
+
+		case *ast.AssignStmt:
+			if len(n.Lhs) == len(n.Rhs) {
+				for i, lhs := range n.Lhs {
+					if name, ok := nameOf(lhs); ok {
+						named(name, n.Rhs[i])
+					}
+				}
+			}
+
+		case *ast.KeyValueExpr:
+			if name, ok := nameOf(n.Key); ok {
+				named(name, n.Value)
+			}
+
+		case *ast.BinaryExpr:
+			if n.Op == token.EQL || n.Op == token.NEQ {
+				if name, ok := nameOf(n.X); ok {
+					named(name, n.Y)
+				}
+				if name, ok := nameOf(n.Y); ok {
+					named(name, n.X)
+				}
+			}
+
+		case *ast.CallExpr:
+			// Arguments of parameters such as the password of
+			// (*http.Request).SetBasicAuth.
+			fn, ok := typeutil.Callee(pass.TypesInfo, n).(*types.Func)
+			if !ok {
+				return
+			}
+			params := fn.Type().(*types.Signature).Params()
+			for i, arg := range n.Args {
+				if i < params.Len() {
+					named(params.At(i).Name(), arg)
+				}
+			}
+
+		case *ast.BasicLit:
+			if reported[n] {
+				return
+			}
+			value, err := strconv.Unquote(n.Value)
+			if n.Kind != token.STRING || err != nil || allowed(value) {
+				return
+			}
+			for _, c := range credentials {
+				if c.rx.MatchString(value) {
+					report(n, "possible hardcoded %s", c.desc)
+					return
+				}
+			}
+		}
+	})
+	return nil, nil
+}
+
+// fileLits returns the string literals of the file.
+func fileLits(f *ast.File) []*ast.BasicLit {
+	var lits []*ast.BasicLit
+	ast.Inspect(f, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			lits = append(lits, lit)
+		}
+		return true
+	})
+	return lits
+}
+
+// nameOf returns the name of a variable, field or map key expression
+// such as x, x.f or m["k"].
+func nameOf(e ast.Expr) (string, bool) {
+	switch e := analysisutil.Unparen(e).(type) {
+	case *ast.Ident:
+		return e.Name, true
+	case *ast.SelectorExpr:
+		return e.Sel.Name, true
+	case *ast.IndexExpr:
+		if _, key, ok := stringLit(e.Index); ok {
+			return key, true
+		}
+	case *ast.BasicLit:
+		if _, key, ok := stringLit(e); ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// stringLit returns the string literal e and its value.
+func stringLit(e ast.Expr) (*ast.BasicLit, string, bool) {
+	lit, ok := analysisutil.Unparen(e).(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return nil, "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return nil, "", false
+	}
+	return lit, value, true
+}
+
+// allowed reports whether the value matches the -allow flag.
+func allowed(value string) bool {
+	for _, rx := range allow {
+		if rx.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecret reports whether the value given to the name looks like a
+// secret.
+func isSecret(name, value string) bool {
+	if strings.IndexFunc(value, unicode.IsSpace) >= 0 || placeholderRx.MatchString(value) {
+		return false
+	}
+	// Values that are themselves names of secrets, as in
+	// passwordField = "password", are not secrets.
+	if passwordRx.MatchString(value) || secretRx.MatchString(value) {
+		return false
+	}
+	switch {
+	case passwordRx.MatchString(name):
+		return len(value) >= 6
+	case secretRx.MatchString(name):
+		return len(value) >= 16 && shannonEntropy(value) >= entropy
+	}
+	return false
+}
+
+// shannonEntropy returns the entropy of the bytes of s, in bits per
+// byte.
+func shannonEntropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var h float64
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// regexpsFlag is a flag of comma-separated regular expressions.
+type regexpsFlag []*regexp.Regexp
+
+func (f *regexpsFlag) String() string {
+	var items []string
+	for _, rx := range *f {
+		items = append(items, rx.String())
+	}
+	return strings.Join(items, ",")
+}
+
+func (f *regexpsFlag) Set(s string) error {
+	var rxs []*regexp.Regexp // clobber previous value
+	if s != "" {
+		for _, expr := range strings.Split(s, ",") {
+			rx, err := regexp.Compile(expr)
+			if err != nil {
+				return err
+			}
+			rxs = append(rxs, rx)
+		}
+	}
+	*f = rxs
+	return nil
+}
+
+type stringSetFlag map[string]bool
+
+func (ss *stringSetFlag) String() string {
+	var items []string
+	for item := range *ss {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (ss *stringSetFlag) Set(s string) error {
+	m := make(map[string]bool) // clobber previous value
+	if s != "" {
+		for _, name := range strings.Split(s, ",") {
+			if name == "" {
+				continue
+			}
+			m[name] = true
+		}
+	}
+	*ss = m
+	return nil
+}
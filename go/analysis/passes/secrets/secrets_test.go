@@ -0,0 +1,19 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secrets_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/passes/secrets"
+)
+
+func Test(t *testing.T) {
+	testdata := analysistest.TestData()
+	secrets.Analyzer.Flags.Set("allow", "^EXAMPLE,example$")
+	secrets.Analyzer.Flags.Set("allownames", "tokenSample")
+	analysistest.Run(t, testdata, secrets.Analyzer, "a")
+}
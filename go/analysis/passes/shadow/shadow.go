@@ -6,27 +6,26 @@
 package shadow
 
 import (
+	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
+	"sort"
+	"strings"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/passes/inspect"
 	"golang.org/x/tools/go/ast/inspector"
 )
 
-// NOTE: Experimental. Not part of the vet suite.
-
 const Doc = `check for possible unintended shadowing of variables
 
 This analyzer check for shadowed variables.
 A shadowed variable is a variable declared in an inner scope
 with the same name and type as a variable in an outer scope,
-and where the outer variable is mentioned after the inner one
-is declared.
-
-(This definition can be refined; the module generates too many
-false positives and is not yet enabled by default.)
+and where the outer variable is read after the scope of the inner
+one ends, so that a value assigned to the inner variable may have
+been meant for the outer one.
 
 For example:
 
@@ -41,7 +40,16 @@ For example:
 		}
 		return err
 	}
-`
+
+Each report suggests renaming the inner variable and, where the types
+allow, assigning to the outer variable instead of declaring a new one.
+
+The -strict flag reports all shadowing, whether or not the outer
+variable is read later. The -names flag is a comma-separated list of
+the names of the variables to check, such as "err,ctx"; by default
+all variables are checked. The -minlines flag ignores inner variables
+whose scope spans fewer lines than it, such as those of an if
+statement that returns at once.`
 
 var Analyzer = &analysis.Analyzer{
 	Name:     "shadow",
@@ -51,119 +59,148 @@ var Analyzer = &analysis.Analyzer{
 }
 
 // flags
-var strict = false
+var (
+	strict   = false
+	names    stringSetFlag
+	minLines = 0
+)
 
 func init() {
 	Analyzer.Flags.BoolVar(&strict, "strict", strict, "whether to be strict about shadowing; can be noisy")
+	Analyzer.Flags.Var(&names, "names", "comma-separated list of names of variables to check; empty means all")
+	Analyzer.Flags.IntVar(&minLines, "minlines", minLines, "minimum number of lines of the scope of a shadowing variable to report it")
 }
 
-func run(pass *analysis.Pass) (interface{}, error) {
-	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+// A usage records how the variables of the package are used.
+//
+// A variable is considered shadowed (if strict is off) only if it is
+// read after the scope of the shadowing variable ends. In other words,
+// if a variable is shadowed but not read once the shadowing variable
+// is gone, the values assigned to the shadowing variable could not
+// have been meant for it, and the shadowing is not worth complaining
+// about. Assignments with = are not reads, but naked returns read the
+// named results.
+//
+// Cases this gets wrong (TODO):
+// - If a variable is shadowed in the body of a loop and read only in
+// a part of the loop that precedes the shadowing declaration, we
+// should complain about it but don't.
+// - A variable declared inside a function literal can falsely be
+// identified as shadowing a variable in the outer function.
+type usage struct {
+	lastRead map[types.Object]token.Pos    // the end of the last read of each variable
+	uses     map[types.Object][]*ast.Ident // the identifiers referring to each variable
+}
 
-	spans := make(map[types.Object]span)
-	for id, obj := range pass.TypesInfo.Defs {
-		// Ignore identifiers that don't denote objects
-		// (package names, symbolic variables such as t
-		// in t := x.(type) of type switch headers).
-		if obj != nil {
-			growSpan(spans, obj, id.Pos(), id.End())
-		}
+// newUsage computes the usage of the variables of the package.
+func newUsage(pass *analysis.Pass, inspect *inspector.Inspector) *usage {
+	u := &usage{
+		lastRead: make(map[types.Object]token.Pos),
+		uses:     make(map[types.Object][]*ast.Ident),
 	}
+
+	// The identifiers that are assigned but not read.
+	assigned := make(map[*ast.Ident]bool)
+	inspect.Preorder([]ast.Node{(*ast.AssignStmt)(nil)}, func(n ast.Node) {
+		if a := n.(*ast.AssignStmt); a.Tok == token.ASSIGN {
+			for _, lhs := range a.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					assigned[id] = true
+				}
+			}
+		}
+	})
+
 	for id, obj := range pass.TypesInfo.Uses {
-		growSpan(spans, obj, id.Pos(), id.End())
-	}
-	for node, obj := range pass.TypesInfo.Implicits {
-		// A type switch with a short variable declaration
-		// such as t := x.(type) doesn't declare the symbolic
-		// variable (t in the example) at the switch header;
-		// instead a new variable t (with specific type) is
-		// declared implicitly for each case. Such variables
-		// are found in the types.Info.Implicits (not Defs)
-		// map. Add them here, assuming they are declared at
-		// the type cases' colon ":".
-		if cc, ok := node.(*ast.CaseClause); ok {
-			growSpan(spans, obj, cc.Colon, cc.Colon)
+		u.uses[obj] = append(u.uses[obj], id)
+		if !assigned[id] {
+			u.read(obj, id.End())
 		}
 	}
 
+	// Naked returns read the named results.
 	nodeFilter := []ast.Node{
-		(*ast.AssignStmt)(nil),
-		(*ast.GenDecl)(nil),
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
 	}
 	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		var ftype *ast.FuncType
+		var body *ast.BlockStmt
 		switch n := n.(type) {
-		case *ast.AssignStmt:
-			checkShadowAssignment(pass, spans, n)
-		case *ast.GenDecl:
-			checkShadowDecl(pass, spans, n)
+		case *ast.FuncDecl:
+			ftype, body = n.Type, n.Body
+		case *ast.FuncLit:
+			ftype, body = n.Type, n.Body
+		}
+		if body == nil || ftype.Results == nil || len(ftype.Results.List[0].Names) == 0 {
+			return
 		}
+		ast.Inspect(body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				if len(n.Results) == 0 {
+					for _, field := range ftype.Results.List {
+						for _, name := range field.Names {
+							if obj := pass.TypesInfo.Defs[name]; obj != nil {
+								u.read(obj, n.End())
+							}
+						}
+					}
+				}
+			}
+			return true
+		})
 	})
-	return nil, nil
+	return u
 }
 
-// A span stores the minimum range of byte positions in the file in which a
-// given variable (types.Object) is mentioned. It is lexically defined: it spans
-// from the beginning of its first mention to the end of its last mention.
-// A variable is considered shadowed (if strict is off) only if the
-// shadowing variable is declared within the span of the shadowed variable.
-// In other words, if a variable is shadowed but not used after the shadowed
-// variable is declared, it is inconsequential and not worth complaining about.
-// This simple check dramatically reduces the nuisance rate for the shadowing
-// check, at least until something cleverer comes along.
-//
-// One wrinkle: A "naked return" is a silent use of a variable that the Span
-// will not capture, but the compilers catch naked returns of shadowed
-// variables so we don't need to.
-//
-// Cases this gets wrong (TODO):
-// - If a for loop's continuation statement mentions a variable redeclared in
-// the block, we should complain about it but don't.
-// - A variable declared inside a function literal can falsely be identified
-// as shadowing a variable in the outer function.
-type span struct {
-	min token.Pos
-	max token.Pos
+// read records a read of the object that ends at pos.
+func (u *usage) read(obj types.Object, pos token.Pos) {
+	if u.lastRead[obj] < pos {
+		u.lastRead[obj] = pos
+	}
 }
 
-// contains reports whether the position is inside the span.
-func (s span) contains(pos token.Pos) bool {
-	return s.min <= pos && pos < s.max
-}
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 
-// growSpan expands the span for the object to contain the source range [pos, end).
-func growSpan(spans map[types.Object]span, obj types.Object, pos, end token.Pos) {
-	if strict {
-		return // No need
+	u := newUsage(pass, inspect)
+
+	nodeFilter := []ast.Node{
+		(*ast.AssignStmt)(nil),
+		(*ast.GenDecl)(nil),
 	}
-	s, ok := spans[obj]
-	if ok {
-		if s.min > pos {
-			s.min = pos
-		}
-		if s.max < end {
-			s.max = end
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			checkShadowAssignment(pass, u, n)
+		case *ast.GenDecl:
+			checkShadowDecl(pass, u, n)
 		}
-	} else {
-		s = span{pos, end}
-	}
-	spans[obj] = s
+	})
+	return nil, nil
 }
 
 // checkShadowAssignment checks for shadowing in a short variable declaration.
-func checkShadowAssignment(pass *analysis.Pass, spans map[types.Object]span, a *ast.AssignStmt) {
+func checkShadowAssignment(pass *analysis.Pass, u *usage, a *ast.AssignStmt) {
 	if a.Tok != token.DEFINE {
 		return
 	}
 	if idiomaticShortRedecl(pass, a) {
 		return
 	}
+	reuse := reuseAssignment(pass, a)
 	for _, expr := range a.Lhs {
 		ident, ok := expr.(*ast.Ident)
 		if !ok {
 			pass.ReportRangef(expr, "invalid AST: short variable declaration of non-identifier")
 			return
 		}
-		checkShadowing(pass, spans, ident)
+		if checkShadowing(pass, u, ident, reuse) {
+			reuse = nil // offer the fix once
+		}
 	}
 }
 
@@ -223,7 +260,7 @@ func idiomaticRedecl(d *ast.ValueSpec) bool {
 }
 
 // checkShadowDecl checks for shadowing in a general variable declaration.
-func checkShadowDecl(pass *analysis.Pass, spans map[types.Object]span, d *ast.GenDecl) {
+func checkShadowDecl(pass *analysis.Pass, u *usage, d *ast.GenDecl) {
 	if d.Tok != token.VAR {
 		return
 	}
@@ -238,52 +275,217 @@ func checkShadowDecl(pass *analysis.Pass, spans map[types.Object]span, d *ast.Ge
 		if idiomaticRedecl(valueSpec) {
 			return
 		}
+		reuse := reuseDecl(pass, d)
 		for _, ident := range valueSpec.Names {
-			checkShadowing(pass, spans, ident)
+			checkShadowing(pass, u, ident, reuse)
 		}
 	}
 }
 
-// checkShadowing checks whether the identifier shadows an identifier in an outer scope.
-func checkShadowing(pass *analysis.Pass, spans map[types.Object]span, ident *ast.Ident) {
+// checkShadowing checks whether the identifier shadows an identifier in an outer scope,
+// and reports whether it does. The report suggests the reuse fix, if any.
+func checkShadowing(pass *analysis.Pass, u *usage, ident *ast.Ident, reuse *analysis.SuggestedFix) bool {
 	if ident.Name == "_" {
 		// Can't shadow the blank identifier.
-		return
+		return false
+	}
+	if len(names) > 0 && !names[ident.Name] {
+		return false
 	}
 	obj := pass.TypesInfo.Defs[ident]
 	if obj == nil {
-		return
+		return false
 	}
-	// obj.Parent.Parent is the surrounding scope. If we can find another declaration
-	// starting from there, we have a shadowed identifier.
-	_, shadowed := obj.Parent().Parent().LookupParent(obj.Name(), obj.Pos())
+	shadowed := shadowedBy(obj)
 	if shadowed == nil {
-		return
+		return false
 	}
 	// Don't complain if it's shadowing a universe-declared identifier; that's fine.
 	if shadowed.Parent() == types.Universe {
-		return
+		return false
 	}
 	if strict {
 		// The shadowed identifier must appear before this one to be an instance of shadowing.
 		if shadowed.Pos() > ident.Pos() {
-			return
+			return false
 		}
 	} else {
-		// Don't complain if the span of validity of the shadowed identifier doesn't include
-		// the shadowing identifier.
-		span, ok := spans[shadowed]
-		if !ok {
-			pass.ReportRangef(ident, "internal error: no range for %q", ident.Name)
-			return
+		// Don't complain if the shadowed identifier is not read once the
+		// scope of the shadowing identifier ends.
+		if u.lastRead[shadowed] <= obj.Parent().End() {
+			return false
 		}
-		if !span.contains(ident.Pos()) {
-			return
+	}
+	// Don't complain if the shadowing identifier is short-lived.
+	if minLines > 0 {
+		first := pass.Fset.Position(ident.Pos()).Line
+		last := pass.Fset.Position(obj.Parent().End()).Line
+		if last-first+1 < minLines {
+			return false
 		}
 	}
 	// Don't complain if the types differ: that implies the programmer really wants two different things.
-	if types.Identical(obj.Type(), shadowed.Type()) {
-		line := pass.Fset.Position(shadowed.Pos()).Line
-		pass.ReportRangef(ident, "declaration of %q shadows declaration at line %d", obj.Name(), line)
+	if !types.Identical(obj.Type(), shadowed.Type()) {
+		return false
+	}
+	line := pass.Fset.Position(shadowed.Pos()).Line
+	diag := analysis.Diagnostic{
+		Pos:     ident.Pos(),
+		End:     ident.End(),
+		Message: fmt.Sprintf("declaration of %q shadows declaration at line %d", obj.Name(), line),
+	}
+	if rename := renameFix(u, obj, ident); rename != nil {
+		diag.SuggestedFixes = append(diag.SuggestedFixes, *rename)
+	}
+	if reuse != nil {
+		diag.SuggestedFixes = append(diag.SuggestedFixes, *reuse)
+	}
+	pass.Report(diag)
+	return true
+}
+
+// shadowedBy returns the object that obj shadows, if any.
+func shadowedBy(obj types.Object) types.Object {
+	// obj.Parent.Parent is the surrounding scope. If we can find another declaration
+	// starting from there, we have a shadowed identifier.
+	_, shadowed := obj.Parent().Parent().LookupParent(obj.Name(), obj.Pos())
+	return shadowed
+}
+
+// renameFix returns the fix that renames the shadowing variable obj,
+// declared by ident, to a name that is not otherwise in use.
+func renameFix(u *usage, obj types.Object, ident *ast.Ident) *analysis.SuggestedFix {
+	var name string
+	for i := 1; ; i++ {
+		name = fmt.Sprintf("%s%d", obj.Name(), i)
+		if !declared(obj.Parent(), name) {
+			break
+		}
+	}
+	idents := append([]*ast.Ident{ident}, u.uses[obj]...)
+	sort.Slice(idents, func(i, j int) bool { return idents[i].Pos() < idents[j].Pos() })
+	var edits []analysis.TextEdit
+	for _, id := range idents {
+		edits = append(edits, analysis.TextEdit{
+			Pos:     id.Pos(),
+			End:     id.End(),
+			NewText: []byte(name),
+		})
+	}
+	return &analysis.SuggestedFix{
+		Message:   "Rename the shadowing variable",
+		TextEdits: edits,
+	}
+}
+
+// declared reports whether name is declared in scope, in a scope that
+// encloses it or in a scope that it encloses.
+func declared(scope *types.Scope, name string) bool {
+	if _, obj := scope.LookupParent(name, token.NoPos); obj != nil {
+		return true
+	}
+	var inner func(*types.Scope) bool
+	inner = func(s *types.Scope) bool {
+		for i := 0; i < s.NumChildren(); i++ {
+			child := s.Child(i)
+			if child.Lookup(name) != nil || inner(child) {
+				return true
+			}
+		}
+		return false
+	}
+	return inner(scope)
+}
+
+// reuseAssignment returns the fix that turns the short variable
+// declaration into an assignment to the variables it shadows, or nil
+// if some variable it declares does not shadow a local variable of
+// the same type.
+func reuseAssignment(pass *analysis.Pass, a *ast.AssignStmt) *analysis.SuggestedFix {
+	for _, expr := range a.Lhs {
+		ident, ok := expr.(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		if ident.Name == "_" {
+			continue
+		}
+		if obj := pass.TypesInfo.Defs[ident]; obj != nil {
+			if !reusable(pass, obj) {
+				return nil
+			}
+		} else if pass.TypesInfo.Uses[ident] == nil {
+			return nil // the symbolic variable of a type switch
+		}
+	}
+	return &analysis.SuggestedFix{
+		Message: "Assign to the shadowed variable",
+		TextEdits: []analysis.TextEdit{{
+			Pos:     a.TokPos,
+			End:     a.TokPos + token.Pos(len(a.Tok.String())),
+			NewText: []byte(token.ASSIGN.String()),
+		}},
+	}
+}
+
+// reuseDecl returns the fix that turns a declaration of the form
+//
+//	var x = v
+//
+// into an assignment to the variable x shadows, or nil if the
+// declaration is not of that form or x does not shadow a local
+// variable of the same type.
+func reuseDecl(pass *analysis.Pass, d *ast.GenDecl) *analysis.SuggestedFix {
+	if d.Lparen.IsValid() || len(d.Specs) != 1 {
+		return nil
+	}
+	spec := d.Specs[0].(*ast.ValueSpec)
+	if len(spec.Names) != 1 || len(spec.Values) != 1 {
+		return nil
+	}
+	obj := pass.TypesInfo.Defs[spec.Names[0]]
+	if obj == nil || !reusable(pass, obj) {
+		return nil
+	}
+	return &analysis.SuggestedFix{
+		Message: "Assign to the shadowed variable",
+		TextEdits: []analysis.TextEdit{{
+			Pos:     d.Pos(),
+			End:     spec.Values[0].Pos(),
+			NewText: []byte(spec.Names[0].Name + " = "),
+		}},
+	}
+}
+
+// reusable reports whether the variable obj shadows a local variable
+// of the same type, to which its values may be assigned instead.
+func reusable(pass *analysis.Pass, obj types.Object) bool {
+	v, ok := shadowedBy(obj).(*types.Var)
+	return ok && v.Parent() != types.Universe && v.Parent() != pass.Pkg.Scope() &&
+		types.Identical(obj.Type(), v.Type())
+}
+
+type stringSetFlag map[string]bool
+
+func (ss *stringSetFlag) String() string {
+	var items []string
+	for item := range *ss {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (ss *stringSetFlag) Set(s string) error {
+	m := make(map[string]bool) // clobber previous value
+	if s != "" {
+		for _, name := range strings.Split(s, ",") {
+			if name == "" {
+				continue
+			}
+			m[name] = true
+		}
 	}
+	*ss = m
+	return nil
 }
@@ -15,3 +15,22 @@ func Test(t *testing.T) {
 	testdata := analysistest.TestData()
 	analysistest.Run(t, testdata, shadow.Analyzer, "a")
 }
+
+func TestFixes(t *testing.T) {
+	testdata := analysistest.TestData()
+	analysistest.RunWithSuggestedFixes(t, testdata, shadow.Analyzer, "fix")
+}
+
+func TestNames(t *testing.T) {
+	testdata := analysistest.TestData()
+	shadow.Analyzer.Flags.Set("names", "err")
+	defer shadow.Analyzer.Flags.Set("names", "")
+	analysistest.Run(t, testdata, shadow.Analyzer, "names")
+}
+
+func TestMinLines(t *testing.T) {
+	testdata := analysistest.TestData()
+	shadow.Analyzer.Flags.Set("minlines", "5")
+	defer shadow.Analyzer.Flags.Set("minlines", "0")
+	analysistest.Run(t, testdata, shadow.Analyzer, "minlines")
+}
@@ -98,3 +98,29 @@ func shadowBlock() {
 	}
 	_ = a
 }
+
+func shadowAssigned() {
+	err := os.Remove("")
+	println(err)
+	{
+		_, err := os.Open("") // OK because err is only assigned later
+		_ = err
+	}
+	err = os.Remove("")
+}
+
+func shadowReadBefore(x int) int {
+	if x > 0 {
+		x := x + 1 // OK because x is not read after the block
+		return x
+	}
+	return 0
+}
+
+func shadowNakedReturn(f *os.File, buf []byte) (err error) {
+	if f != nil {
+		_, err := f.Read(buf) // want "declaration of .err. shadows declaration at line 120"
+		_ = err
+	}
+	return
+}
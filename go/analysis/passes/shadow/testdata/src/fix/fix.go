@@ -0,0 +1,71 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the suggested fixes of the shadowed
+// variable checker.
+
+package fix
+
+import "os"
+
+var global error
+
+func Read(f *os.File, buf []byte) error {
+	var err error
+	for {
+		n, err := f.Read(buf) // want "declaration of .err. shadows declaration at line 15"
+		if err != nil {
+			break
+		}
+		_ = n
+	}
+	return err
+}
+
+func Both(f *os.File, buf []byte) (n int, err error) {
+	if f != nil {
+		n, err := f.Read(buf) // want "declaration of .n. shadows declaration at line 26" "declaration of .err. shadows declaration at line 26"
+		_, _ = n, err
+	}
+	return n, err
+}
+
+func Decl(f *os.File) error {
+	var err error
+	if f != nil {
+		var err = f.Close() // want "declaration of .err. shadows declaration at line 35"
+		_ = err
+	}
+	return err
+}
+
+func Taken(f *os.File) error {
+	var err error
+	err1 := err
+	if f != nil {
+		err := f.Close() // want "declaration of .err. shadows declaration at line 44"
+		if err != nil {
+			return err
+		}
+	}
+	_ = err1
+	return err
+}
+
+func Global(f *os.File) {
+	if f != nil {
+		global := f.Close() // want "declaration of .global. shadows declaration at line 12"
+		_ = global
+	}
+	_ = global
+}
+
+func New(f *os.File, buf []byte) error {
+	var err error
+	if f != nil {
+		n, err := f.Read(buf) // want "declaration of .err. shadows declaration at line 65"
+		_, _ = n, err
+	}
+	return err
+}
@@ -0,0 +1,25 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the -minlines flag of the shadowed
+// variable checker.
+
+package minlines
+
+import "os"
+
+func MinLines(f *os.File, buf []byte) error {
+	var err error
+	if _, err := f.Read(buf); err != nil { // OK because the block is short
+		return err
+	}
+	if f != nil {
+		_, err := f.Read(buf) // want "declaration of .err. shadows declaration at line 13"
+		if err != nil {
+			return err
+		}
+		println()
+	}
+	return err
+}
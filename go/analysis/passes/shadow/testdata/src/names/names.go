@@ -0,0 +1,25 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the -names flag of the shadowed
+// variable checker.
+
+package names
+
+import (
+	"context"
+	"os"
+)
+
+func Names(ctx context.Context, f *os.File, buf []byte) error {
+	var err error
+	var n int
+	if f != nil {
+		ctx := context.WithValue(ctx, "k", "v") // OK because only err is checked
+		n, err := f.Read(buf)                   // want "declaration of .err. shadows declaration at line 16"
+		_, _, _ = ctx, n, err
+	}
+	_, _ = ctx, n
+	return err
+}
@@ -3,7 +3,8 @@
 // license that can be found in the LICENSE file.
 
 // Package sigchanyzer defines an Analyzer that detects
-// misuse of unbuffered signal as argument to signal.Notify.
+// misuse of unbuffered signal as argument to signal.Notify,
+// and other misuses of signal channels.
 package sigchanyzer
 
 import (
@@ -15,13 +16,22 @@ import (
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
 	"golang.org/x/tools/go/ast/inspector"
 )
 
-const Doc = `check for unbuffered channel of os.Signal
+const Doc = `check for unbuffered channel of os.Signal and misuses of signal.Notify
 
 This checker reports call expression of the form signal.Notify(c <-chan os.Signal, sig ...os.Signal),
-where c is an unbuffered channel, which can be at risk of missing the signal.`
+where c is an unbuffered channel, which can be at risk of missing the signal.
+
+It also reports calls of signal.Notify in a loop with a channel made in
+each iteration that is not passed to signal.Stop, as the signal package
+keeps every registered channel and the function may run for the life
+of the program, and select statements with a default case that are not
+in a loop and receive from a channel registered by the function: they
+receive a signal only if it has already arrived, and later signals are
+never received, as signal.Notify disables their default behavior.`
 
 // Analyzer describes sigchanyzer analysis function detector.
 var Analyzer = &analysis.Analyzer{
@@ -36,65 +46,209 @@ func run(pass *analysis.Pass) (interface{}, error) {
 
 	nodeFilter := []ast.Node{
 		(*ast.CallExpr)(nil),
+		(*ast.SelectStmt)(nil),
 	}
-	inspect.Preorder(nodeFilter, func(n ast.Node) {
-		call := n.(*ast.CallExpr)
-		if !isSignalNotify(pass.TypesInfo, call) {
-			return
+	inspect.WithStack(nodeFilter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
 		}
-		var chanDecl *ast.CallExpr
-		switch arg := call.Args[0].(type) {
-		case *ast.Ident:
-			if decl, ok := findDecl(arg).(*ast.CallExpr); ok {
-				chanDecl = decl
-			}
+		switch n := n.(type) {
 		case *ast.CallExpr:
-			// Only signal.Notify(make(chan os.Signal), os.Interrupt) is safe,
-			// conservatively treat others as not safe, see golang/go#45043
-			if isBuiltinMake(pass.TypesInfo, arg) {
-				return
+			if isSignalNotify(pass.TypesInfo, n) {
+				checkUnbuffered(pass, n)
+				checkLoop(pass, n, stack)
 			}
-			chanDecl = arg
+		case *ast.SelectStmt:
+			checkSelect(pass, n, stack)
 		}
-		if chanDecl == nil || len(chanDecl.Args) != 1 {
+		return true
+	})
+	return nil, nil
+}
+
+// checkUnbuffered reports a call of signal.Notify with an unbuffered
+// channel.
+func checkUnbuffered(pass *analysis.Pass, call *ast.CallExpr) {
+	var chanDecl *ast.CallExpr
+	switch arg := call.Args[0].(type) {
+	case *ast.Ident:
+		if decl, ok := findDecl(arg).(*ast.CallExpr); ok {
+			chanDecl = decl
+		}
+	case *ast.CallExpr:
+		// Only signal.Notify(make(chan os.Signal), os.Interrupt) is safe,
+		// conservatively treat others as not safe, see golang/go#45043
+		if isBuiltinMake(pass.TypesInfo, arg) {
 			return
 		}
+		chanDecl = arg
+	}
+	if chanDecl == nil || len(chanDecl.Args) != 1 {
+		return
+	}
 
-		// Make a copy of the channel's declaration to avoid
-		// mutating the AST. See https://golang.org/issue/46129.
-		chanDeclCopy := &ast.CallExpr{}
-		*chanDeclCopy = *chanDecl
-		chanDeclCopy.Args = append([]ast.Expr(nil), chanDecl.Args...)
-		chanDeclCopy.Args = append(chanDeclCopy.Args, &ast.BasicLit{
-			Kind:  token.INT,
-			Value: "1",
-		})
+	// Make a copy of the channel's declaration to avoid
+	// mutating the AST. See https://golang.org/issue/46129.
+	chanDeclCopy := &ast.CallExpr{}
+	*chanDeclCopy = *chanDecl
+	chanDeclCopy.Args = append([]ast.Expr(nil), chanDecl.Args...)
+	chanDeclCopy.Args = append(chanDeclCopy.Args, &ast.BasicLit{
+		Kind:  token.INT,
+		Value: "1",
+	})
 
-		var buf bytes.Buffer
-		if err := format.Node(&buf, token.NewFileSet(), chanDeclCopy); err != nil {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), chanDeclCopy); err != nil {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:     call.Pos(),
+		End:     call.End(),
+		Message: "misuse of unbuffered os.Signal channel as argument to signal.Notify",
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message: "Change to buffer channel",
+			TextEdits: []analysis.TextEdit{{
+				Pos:     chanDecl.Pos(),
+				End:     chanDecl.End(),
+				NewText: buf.Bytes(),
+			}},
+		}},
+	})
+}
+
+// checkLoop reports a call of signal.Notify in a loop with a channel
+// made in each iteration that is not stopped in the iteration.
+func checkLoop(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	body := enclosingLoop(stack)
+	if body == nil {
+		return
+	}
+	switch arg := analysisutil.Unparen(call.Args[0]).(type) {
+	case *ast.CallExpr:
+		// A channel made for the call cannot be stopped.
+		if !isBuiltinMake(pass.TypesInfo, arg) {
 			return
 		}
-		pass.Report(analysis.Diagnostic{
-			Pos:     call.Pos(),
-			End:     call.End(),
-			Message: "misuse of unbuffered os.Signal channel as argument to signal.Notify",
-			SuggestedFixes: []analysis.SuggestedFix{{
-				Message: "Change to buffer channel",
-				TextEdits: []analysis.TextEdit{{
-					Pos:     chanDecl.Pos(),
-					End:     chanDecl.End(),
-					NewText: buf.Bytes(),
-				}},
-			}},
-		})
+	case *ast.Ident:
+		v, ok := pass.TypesInfo.Uses[arg].(*types.Var)
+		if !ok || v.Pos() < body.Pos() || v.Pos() >= body.End() || stopped(pass.TypesInfo, body, v) {
+			return
+		}
+	default:
+		return
+	}
+	pass.ReportRangef(call, "signal.Notify in a loop without signal.Stop registers a new channel in each iteration")
+}
+
+// stopped reports whether body calls signal.Stop with the channel v,
+// other than in a defer statement, which runs after the loop.
+func stopped(info *types.Info, body *ast.BlockStmt, v *types.Var) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.DeferStmt:
+			return false
+		case *ast.CallExpr:
+			if isSignalFunc(info, n, "Stop") && len(n.Args) == 1 {
+				if id, ok := analysisutil.Unparen(n.Args[0]).(*ast.Ident); ok && info.Uses[id] == v {
+					found = true
+				}
+			}
+		}
+		return !found
 	})
-	return nil, nil
+	return found
+}
+
+// checkSelect reports a select statement with a default case, outside
+// any loop, that receives from a channel that the enclosing function
+// declares and registers with signal.Notify.
+func checkSelect(pass *analysis.Pass, sel *ast.SelectStmt, stack []ast.Node) {
+	if enclosingLoop(stack) != nil {
+		return
+	}
+	var body *ast.BlockStmt
+	for i := len(stack) - 2; i >= 0 && body == nil; i-- {
+		switch n := stack[i].(type) {
+		case *ast.FuncDecl:
+			body = n.Body
+		case *ast.FuncLit:
+			body = n.Body
+		}
+	}
+	if body == nil {
+		return
+	}
+	hasDefault := false
+	var recvs []*ast.Ident
+	for _, stmt := range sel.Body.List {
+		var x ast.Expr
+		switch comm := stmt.(*ast.CommClause).Comm.(type) {
+		case nil:
+			hasDefault = true
+		case *ast.ExprStmt:
+			x = comm.X
+		case *ast.AssignStmt:
+			x = comm.Rhs[0]
+		}
+		if recv, ok := analysisutil.Unparen(x).(*ast.UnaryExpr); ok && recv.Op == token.ARROW {
+			if id, ok := analysisutil.Unparen(recv.X).(*ast.Ident); ok {
+				recvs = append(recvs, id)
+			}
+		}
+	}
+	if !hasDefault {
+		return
+	}
+	for _, id := range recvs {
+		v, ok := pass.TypesInfo.Uses[id].(*types.Var)
+		if ok && v.Pos() >= body.Pos() && v.Pos() < body.End() && notified(pass.TypesInfo, body, v) {
+			pass.ReportRangef(sel, "select with a default case receives from %s only if a signal has already arrived", id.Name)
+			return
+		}
+	}
+}
+
+// notified reports whether body calls signal.Notify with the channel v.
+func notified(info *types.Info, body *ast.BlockStmt, v *types.Var) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && isSignalNotify(info, call) {
+			if id, ok := analysisutil.Unparen(call.Args[0]).(*ast.Ident); ok && info.Uses[id] == v {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// enclosingLoop returns the body of the innermost loop that encloses
+// the node at the top of the stack within its function, or nil.
+func enclosingLoop(stack []ast.Node) *ast.BlockStmt {
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.ForStmt:
+			return n.Body
+		case *ast.RangeStmt:
+			return n.Body
+		case *ast.FuncDecl, *ast.FuncLit:
+			return nil
+		}
+	}
+	return nil
 }
 
 func isSignalNotify(info *types.Info, call *ast.CallExpr) bool {
+	return isSignalFunc(info, call, "Notify")
+}
+
+// isSignalFunc reports whether call calls the named function of the
+// os/signal package.
+func isSignalFunc(info *types.Info, call *ast.CallExpr, name string) bool {
 	check := func(id *ast.Ident) bool {
 		obj := info.ObjectOf(id)
-		return obj.Name() == "Notify" && obj.Pkg().Path() == "os/signal"
+		return obj != nil && obj.Name() == name && obj.Pkg() != nil && obj.Pkg().Path() == "os/signal"
 	}
 	switch fun := call.Fun.(type) {
 	case *ast.SelectorExpr:
@@ -0,0 +1,107 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p
+
+import (
+	"os"
+	"os/signal"
+	"time"
+)
+
+func loop() {
+	for {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt) // want "signal.Notify in a loop without signal.Stop registers a new channel in each iteration"
+		select {
+		case <-c:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func loopStop() {
+	for i := 0; i < 10; i++ {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt) // ok
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+		}
+		signal.Stop(c)
+	}
+}
+
+func loopDeferStop(names []string) {
+	for range names {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt) // want "signal.Notify in a loop without signal.Stop registers a new channel in each iteration"
+		defer signal.Stop(c)
+		<-c
+	}
+}
+
+func loopMake() {
+	for {
+		signal.Notify(make(chan os.Signal, 1), os.Interrupt) // want "signal.Notify in a loop without signal.Stop registers a new channel in each iteration"
+	}
+}
+
+func loopOuter() {
+	c := make(chan os.Signal, 1)
+	for {
+		signal.Notify(c, os.Interrupt) // ok: the same channel
+		<-c
+	}
+}
+
+func poll() bool {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	select { // want "select with a default case receives from c only if a signal has already arrived"
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func pollLoop() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	for {
+		select { // ok: polling
+		case <-c:
+			return
+		default:
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+var sigs = make(chan os.Signal, 1)
+
+func init() {
+	signal.Notify(sigs, os.Interrupt)
+}
+
+func interrupted() bool {
+	select { // ok: the channel is registered elsewhere
+	case <-sigs:
+		return true
+	default:
+		return false
+	}
+}
+
+func blocking() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	select { // ok: no default case
+	case s := <-c:
+		println(s)
+	case <-time.After(time.Second):
+	}
+}
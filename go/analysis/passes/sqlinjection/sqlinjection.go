@@ -0,0 +1,482 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sqlinjection defines an Analyzer that reports SQL queries
+// built from user input.
+package sqlinjection
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+const Doc = `check for SQL queries built from user input
+
+The sqlinjection analyzer reports calls of the query methods of the
+DB, Tx, and Conn types of database/sql, such as Query and Exec, whose
+query is built, by concatenation or by fmt.Sprintf, from strings that
+derive from user input:
+
+	id := req.FormValue("id")
+	db.Query("SELECT * FROM users WHERE id = " + id)
+
+Such a query may be subverted by the user; a parameterized query, whose
+arguments are passed separately from the query, should be used instead:
+
+	db.Query("SELECT * FROM users WHERE id = ?", id)
+
+User input is what is obtained from an *http.Request, such as its
+FormValue method or its URL field, from os.Args, and from the functions
+named by the -sources flag. Functions of other packages that return user
+input, or that build a query from their parameters, are known from facts.
+The analysis is approximate: it does not track values through fields,
+slices, or maps, nor distinguish among the paths of a function.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "sqlinjection",
+	Doc:       Doc,
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(userResult), new(querySink)},
+}
+
+// flags
+var sources stringSetFlag
+
+func init() {
+	sources.Set("(*net/http.Request).FormValue,(*net/http.Request).PostFormValue," +
+		"(*net/http.Request).Cookie,(*net/http.Request).Cookies," +
+		"(net/url.Values).Get,(net/http.Header).Get,(net/http.Header).Values")
+	Analyzer.Flags.Var(&sources, "sources",
+		"comma-separated list of functions whose results are user input")
+}
+
+// userResult is the fact that a function may return user input.
+type userResult struct{}
+
+func (*userResult) AFact() {}
+
+func (*userResult) String() string { return "userResult" }
+
+// querySink is the fact that a function builds an SQL query from the
+// parameters at the given indices: user input passed to them is
+// reported at the call.
+type querySink struct{ Params []int }
+
+func (*querySink) AFact() {}
+
+func (f *querySink) String() string { return fmt.Sprintf("querySink%v", f.Params) }
+
+// A taint is a set of origins of a string: user input, and the
+// parameters of the function being analyzed, by index.
+type taint uint64
+
+const (
+	userInput taint = 1 << 63
+	maxParams       = 63
+)
+
+func paramTaint(i int) taint {
+	if i >= maxParams {
+		return 0
+	}
+	return 1 << uint(i)
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	c := &checker{
+		pass:  pass,
+		user:  make(map[*types.Func]bool),
+		sinks: make(map[*types.Func]taint),
+	}
+	var decls []*ast.FuncDecl
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(n ast.Node) {
+		if decl := n.(*ast.FuncDecl); decl.Body != nil {
+			decls = append(decls, decl)
+		}
+	})
+
+	// Compute the facts of the package's functions, which may depend
+	// on each other, then report the diagnostics.
+	for changed := true; changed; {
+		changed = false
+		for _, decl := range decls {
+			if c.function(decl) {
+				changed = true
+			}
+		}
+	}
+	c.report = true
+	for _, decl := range decls {
+		c.function(decl)
+	}
+
+	for fn := range c.user {
+		pass.ExportObjectFact(fn, new(userResult))
+	}
+	for fn, t := range c.sinks {
+		var params []int
+		for i := 0; i < maxParams; i++ {
+			if t&paramTaint(i) != 0 {
+				params = append(params, i)
+			}
+		}
+		pass.ExportObjectFact(fn, &querySink{params})
+	}
+	return nil, nil
+}
+
+type checker struct {
+	pass   *analysis.Pass
+	user   map[*types.Func]bool  // functions of the package that return user input
+	sinks  map[*types.Func]taint // functions of the package that build queries from parameters
+	report bool                  // whether to report diagnostics, once the facts are known
+
+	// The function being analyzed.
+	fn    *types.Func
+	vars  map[types.Object]taint
+	built map[types.Object]bool // variables holding queries that are built
+}
+
+// function analyzes a function declaration and its function literals,
+// and reports whether the facts of the function changed.
+func (c *checker) function(decl *ast.FuncDecl) bool {
+	fn, ok := c.pass.TypesInfo.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return false
+	}
+	c.fn = fn
+	c.vars = make(map[types.Object]taint)
+	c.built = make(map[types.Object]bool)
+	sig := fn.Type().(*types.Signature)
+	for i := 0; i < sig.Params().Len(); i++ {
+		// A number or a boolean cannot change the meaning of a query.
+		param := sig.Params().At(i)
+		if b, ok := param.Type().Underlying().(*types.Basic); !ok || b.Info()&types.IsString != 0 {
+			c.vars[param] = paramTaint(i)
+		}
+	}
+
+	// Propagate taint through assignments, until a fixed point.
+	for changed := true; changed; {
+		changed = false
+		ast.Inspect(decl.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if len(n.Lhs) == len(n.Rhs) {
+					for i, lhs := range n.Lhs {
+						if c.assign(lhs, n.Rhs[i]) {
+							changed = true
+						}
+					}
+				}
+			case *ast.ValueSpec:
+				if len(n.Names) == len(n.Values) {
+					for i, id := range n.Names {
+						if c.assign(id, n.Values[i]) {
+							changed = true
+						}
+					}
+				}
+			case *ast.RangeStmt:
+				// for _, s := range strs: s is as tainted as strs.
+				if n.Value != nil {
+					if c.assign(n.Value, n.X) {
+						changed = true
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	// Find the returned user input and the query calls.
+	changed := false
+	var results []*types.Var // named results
+	for i := 0; i < sig.Results().Len(); i++ {
+		results = append(results, sig.Results().At(i))
+	}
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Returns within function literals are not those of fn.
+			ast.Inspect(n.Body, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					if c.call(call) {
+						changed = true
+					}
+				}
+				return true
+			})
+			return false
+		case *ast.ReturnStmt:
+			t := taint(0)
+			for _, res := range n.Results {
+				t |= c.taint(res)
+			}
+			if n.Results == nil {
+				for _, v := range results {
+					t |= c.vars[v]
+				}
+			}
+			if t&userInput != 0 && !c.user[fn] {
+				c.user[fn] = true
+				changed = true
+			}
+		case *ast.CallExpr:
+			if c.call(n) {
+				changed = true
+			}
+		}
+		return true
+	})
+	return changed
+}
+
+// assign records the assignment of rhs to lhs, and
+// reports whether the taint of a variable changed.
+func (c *checker) assign(lhs, rhs ast.Expr) bool {
+	id, ok := analysisutil.Unparen(lhs).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	obj := c.pass.TypesInfo.ObjectOf(id)
+	if obj == nil {
+		return false
+	}
+	changed := false
+	if t := c.vars[obj] | c.taint(rhs); t != c.vars[obj] {
+		c.vars[obj] = t
+		changed = true
+	}
+	if !c.built[obj] && c.isBuilt(rhs) {
+		c.built[obj] = true
+		changed = true
+	}
+	return changed
+}
+
+// taint returns the origins of the value of e.
+func (c *checker) taint(e ast.Expr) taint {
+	info := c.pass.TypesInfo
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return c.taint(e.X)
+	case *ast.Ident:
+		obj := info.ObjectOf(e)
+		if v, ok := obj.(*types.Var); ok && v.Pkg() != nil && v.Pkg().Path() == "os" && v.Name() == "Args" {
+			return userInput
+		}
+		return c.vars[obj]
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD {
+			return c.taint(e.X) | c.taint(e.Y)
+		}
+	case *ast.IndexExpr:
+		return c.taint(e.X)
+	case *ast.SliceExpr:
+		return c.taint(e.X)
+	case *ast.StarExpr:
+		return c.taint(e.X)
+	case *ast.SelectorExpr:
+		if sel, ok := info.Selections[e]; ok && sel.Kind() == types.FieldVal {
+			if isHTTPRequest(info.TypeOf(e.X)) {
+				return userInput // e.g. req.URL
+			}
+			return c.taint(e.X)
+		}
+		if v, ok := info.Uses[e.Sel].(*types.Var); ok && v.Pkg() != nil && v.Pkg().Path() == "os" && v.Name() == "Args" {
+			return userInput
+		}
+	case *ast.CallExpr:
+		if tv := info.Types[e.Fun]; tv.IsType() {
+			if len(e.Args) == 1 {
+				return c.taint(e.Args[0]) // a conversion
+			}
+			return 0
+		}
+		fn, _ := typeutil.Callee(info, e).(*types.Func)
+		if fn == nil {
+			return 0
+		}
+		fn = typeparams.OriginMethod(fn) // a method of an instantiated type
+		if sources[fn.FullName()] || c.user[fn] || c.pass.ImportObjectFact(fn, new(userResult)) {
+			return userInput
+		}
+		if propagates(fn) {
+			var t taint
+			for _, arg := range e.Args {
+				t |= c.taint(arg)
+			}
+			if sel, ok := e.Fun.(*ast.SelectorExpr); ok && info.Selections[sel] != nil {
+				t |= c.taint(sel.X) // e.g. strings.Builder.String
+			}
+			return t
+		}
+	}
+	return 0
+}
+
+// isBuilt reports whether e is a string built by concatenation or
+// formatting, or a variable to which such a string is assigned.
+func (c *checker) isBuilt(e ast.Expr) bool {
+	switch e := analysisutil.Unparen(e).(type) {
+	case *ast.BinaryExpr:
+		return e.Op == token.ADD
+	case *ast.Ident:
+		return c.built[c.pass.TypesInfo.ObjectOf(e)]
+	case *ast.CallExpr:
+		fn, _ := typeutil.Callee(c.pass.TypesInfo, e).(*types.Func)
+		return fn != nil && fn.Pkg() != nil && fn.Pkg().Path() == "fmt" && strings.HasPrefix(fn.Name(), "Sprint")
+	}
+	return false
+}
+
+// propagates reports whether the string results of fn
+// derive from its arguments, and receiver if any.
+func propagates(fn *types.Func) bool {
+	if fn.Pkg() == nil {
+		return false
+	}
+	switch fn.Pkg().Path() {
+	case "fmt":
+		return strings.HasPrefix(fn.Name(), "Sprint")
+	case "strings", "bytes", "path", "path/filepath":
+		return true
+	}
+	return false
+}
+
+// call checks a call of a query method or of a function that builds
+// a query from its parameters, and reports whether the facts of the
+// function being analyzed changed.
+func (c *checker) call(call *ast.CallExpr) bool {
+	fn, _ := typeutil.Callee(c.pass.TypesInfo, call).(*types.Func)
+	if fn == nil {
+		return false
+	}
+	fn = typeparams.OriginMethod(fn) // a method of an instantiated type
+	sig := fn.Type().(*types.Signature)
+
+	// Find the parameters from which the callee builds a query.
+	var params []int
+	var name string
+	if i, ok := queryParam(fn); ok {
+		if i >= len(call.Args) || !c.isBuilt(call.Args[i]) {
+			return false
+		}
+		params = []int{i}
+	} else if t, ok := c.sinks[fn]; ok {
+		for i := 0; i < sig.Params().Len(); i++ {
+			if t&paramTaint(i) != 0 {
+				params = append(params, i)
+			}
+		}
+		name = fn.Name()
+	} else {
+		var fact querySink
+		if !c.pass.ImportObjectFact(fn, &fact) {
+			return false
+		}
+		params = fact.Params
+		name = fn.Pkg().Name() + "." + fn.Name()
+	}
+
+	changed := false
+	for _, i := range params {
+		for j, arg := range call.Args {
+			if j != i && !(sig.Variadic() && i == sig.Params().Len()-1 && j > i) {
+				continue
+			}
+			t := c.taint(arg)
+			if t&userInput != 0 && c.report {
+				if name == "" {
+					c.pass.ReportRangef(arg, "SQL query built from user input (possible SQL injection); use a parameterized query")
+				} else {
+					c.pass.ReportRangef(arg, "user input passed to %s, which builds an SQL query from it (possible SQL injection); use a parameterized query", name)
+				}
+			}
+			if t &^= userInput; t != 0 && c.sinks[c.fn]|t != c.sinks[c.fn] {
+				c.sinks[c.fn] |= t
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// queryParam returns the index of the query parameter of fn,
+// if it is a query method of database/sql.
+func queryParam(fn *types.Func) (int, bool) {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil || fn.Pkg() == nil || fn.Pkg().Path() != "database/sql" {
+		return 0, false
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return 0, false
+	}
+	switch named.Obj().Name() {
+	case "DB", "Tx", "Conn":
+	default:
+		return 0, false
+	}
+	switch fn.Name() {
+	case "Exec", "Query", "QueryRow", "Prepare":
+		return 0, true
+	case "ExecContext", "QueryContext", "QueryRowContext", "PrepareContext":
+		return 1, true
+	}
+	return 0, false
+}
+
+// isHTTPRequest reports whether t is net/http.Request or a pointer to it.
+func isHTTPRequest(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "net/http" && named.Obj().Name() == "Request"
+}
+
+type stringSetFlag map[string]bool
+
+func (ss *stringSetFlag) String() string {
+	var items []string
+	for item := range *ss {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (ss *stringSetFlag) Set(s string) error {
+	m := make(map[string]bool) // clobber previous value
+	if s != "" {
+		for _, name := range strings.Split(s, ",") {
+			if name != "" {
+				m[name] = true
+			}
+		}
+	}
+	*ss = m
+	return nil
+}
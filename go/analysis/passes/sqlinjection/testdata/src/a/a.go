@@ -0,0 +1,50 @@
+package a
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+
+	"b"
+)
+
+func handler(db *sql.DB, req *http.Request) {
+	id := req.FormValue("id")
+	db.Query("SELECT * FROM users WHERE id = " + id)                            // want `SQL query built from user input \(possible SQL injection\); use a parameterized query`
+	db.Query("SELECT * FROM users WHERE id = ?", id)                            // ok: parameterized
+	db.Exec(fmt.Sprintf("DELETE FROM users WHERE id = '%s'", id))               // want `SQL query built from user input`
+	db.QueryContext(context.Background(), "SELECT "+req.URL.Query().Get("col")) // want `SQL query built from user input`
+	db.Exec("UPDATE users SET path = '" + req.URL.Path + "'")                   // want `SQL query built from user input`
+
+	query := "SELECT * FROM users"
+	query += " WHERE name = '" + b.Name(req) + "'"
+	db.Query(query) // want `SQL query built from user input`
+
+	const table = "users"
+	db.Query("SELECT * FROM " + table) // ok: constant
+
+	b.Find(db, "users", "id = "+id) // want `user input passed to b.Find, which builds an SQL query from it`
+	b.Find(db, id, "1 = 1")         // want `user input passed to b.Find`
+	b.Count(db, id, 10)             // ok: parameterized
+	find(db, id)                    // want `user input passed to find, which builds an SQL query from it`
+	find(db, query)                 // want `user input passed to find`
+	safe(db, len(id))               // ok: not a string
+
+	tx, _ := db.Begin()
+	tx.Exec("DROP TABLE " + os.Args[1]) // want `SQL query built from user input`
+
+	func() {
+		db.Query("SELECT " + id) // want `SQL query built from user input`
+	}()
+}
+
+func find(db *sql.DB, name string) { // want find:`querySink\[1\]`
+	q := fmt.Sprintf("SELECT * FROM users WHERE name = '%s'", name)
+	db.Query(q)
+}
+
+func safe(db *sql.DB, n int) {
+	db.Query(fmt.Sprintf("SELECT * FROM users LIMIT %d", n))
+}
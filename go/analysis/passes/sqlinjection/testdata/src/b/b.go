@@ -0,0 +1,23 @@
+package b
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Name returns user input.
+func Name(req *http.Request) string { // want Name:"userResult"
+	return strings.TrimSpace(req.FormValue("name"))
+}
+
+// Find builds a query from its parameters table and where.
+func Find(db *sql.DB, table, where string) (*sql.Rows, error) { // want Find:`querySink\[1 2\]`
+	return db.Query("SELECT * FROM " + strings.ToUpper(table) + " WHERE " + where)
+}
+
+// Count uses a parameterized query.
+func Count(db *sql.DB, name string, limit int) *sql.Row {
+	return db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM users WHERE name = ? LIMIT %d", limit), name)
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the testhelper checker.
+
+package a
+
+import "testing"
+
+func CheckSum(t *testing.T, got, want int) { // want CheckSum:"helper" "test helper CheckSum does not call t.Helper()"
+	if got != want {
+		t.Errorf("sum is %d, want %d", got, want)
+	}
+}
+
+func MustOpen(tb testing.TB, name string) { // want MustOpen:"helper"
+	tb.Helper()
+	if name == "" {
+		tb.Fatal("no name")
+	}
+}
+
+func checkAll(t *testing.T, sums []int) { // want checkAll:"helper" "test helper checkAll does not call t.Helper()"
+	for _, sum := range sums {
+		CheckSum(t, sum, 0)
+	}
+}
+
+func bench(b *testing.B) { b.Fatal("no") } // want bench:"helper" "test helper bench does not call b.Helper()"
+
+func indirect(t *testing.T) { // want indirect:"helper" "test helper indirect does not call t.Helper()"
+	recursive(t, 1)
+}
+
+func recursive(t *testing.T, n int) { // want recursive:"helper" "test helper recursive does not call t.Helper()"
+	if n > 0 {
+		recursive(t, n-1)
+	}
+	t.Fatal()
+}
+
+func logs(t *testing.T) { // OK: it reports no failures
+	t.Log("hello")
+}
+
+func closure(t *testing.T) { // OK: the failure is reported by a function literal
+	t.Run("sub", func(t *testing.T) {
+		t.Fatal()
+	})
+}
+
+func TestSum(t *testing.T) { // OK: a test
+	t.Fatal()
+}
+
+func Testify(t *testing.T) { // want Testify:"helper" "test helper Testify does not call t.Helper()"
+	t.Fatal()
+}
@@ -0,0 +1,23 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the testhelper checker of test helpers
+// of other packages.
+
+package b
+
+import (
+	"testing"
+
+	"a"
+)
+
+func checkTwice(t *testing.T, sum int) { // want checkTwice:"helper" "test helper checkTwice does not call t.Helper()"
+	a.CheckSum(t, sum, 0)
+	a.MustOpen(t, "f")
+}
+
+func notHelper(t *testing.T, sum int) { // OK: it does not pass t
+	a.CheckSum(new(testing.T), sum, 0)
+}
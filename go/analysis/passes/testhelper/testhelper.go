@@ -0,0 +1,243 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package testhelper defines an Analyzer that reports test helpers that
+// do not call the Helper method of their *testing.T.
+package testhelper
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+const Doc = `check for test helpers that do not call t.Helper
+
+A test helper is a function with a parameter t of type *testing.T,
+*testing.B, *testing.F or testing.TB that reports test failures
+through it, by calling t.Error, t.Errorf, t.Fatal or t.Fatalf or by
+passing t to another test helper. Unless the helper calls t.Helper(),
+the failures are reported at the lines of the helper rather than at
+those of the test that calls it:
+
+	func checkSum(t *testing.T, got, want int) {
+		t.Helper() // report failures at the line of the caller
+		if got != want {
+			t.Errorf("sum is %d, want %d", got, want)
+		}
+	}
+
+The testhelper analyzer reports test helpers that do not call
+t.Helper(), and suggests a fix that calls it first. Test helpers of
+other packages are known from facts.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "testhelper",
+	Doc:       Doc,
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(helper)},
+}
+
+// helper is the fact that a function is a test helper.
+type helper struct{}
+
+func (*helper) AFact() {}
+
+func (*helper) String() string { return "helper" }
+
+// A candidate is a function of the package with a testing parameter.
+type candidate struct {
+	decl   *ast.FuncDecl
+	fn     *types.Func
+	param  *types.Var
+	calls  []*types.Func // the functions to which it passes param
+	fails  bool          // it reports failures through param directly
+	helper bool          // it calls param.Helper()
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	var candidates []*candidate
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(n ast.Node) {
+		decl := n.(*ast.FuncDecl)
+		fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func)
+		if !ok || decl.Body == nil || isTest(fn) {
+			return
+		}
+		i := testingParam(fn.Type().(*types.Signature))
+		if i < 0 {
+			return
+		}
+		param := fn.Type().(*types.Signature).Params().At(i)
+		if param.Name() == "" || param.Name() == "_" {
+			return
+		}
+		c := &candidate{decl: decl, fn: fn, param: param}
+		inspectCalls(pass, c)
+		candidates = append(candidates, c)
+	})
+
+	// Find the test helpers of the package, iterating to a fixed
+	// point for those that only call others.
+	helpers := make(map[*types.Func]bool)
+	isHelper := func(fn *types.Func) bool {
+		return helpers[fn] || pass.ImportObjectFact(fn, new(helper))
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, c := range candidates {
+			if helpers[c.fn] {
+				continue
+			}
+			fails := c.fails
+			for _, callee := range c.calls {
+				if isHelper(callee) {
+					fails = true
+					break
+				}
+			}
+			if fails {
+				helpers[c.fn] = true
+				changed = true
+			}
+		}
+	}
+
+	for _, c := range candidates {
+		if !helpers[c.fn] {
+			continue
+		}
+		pass.ExportObjectFact(c.fn, new(helper))
+		if c.helper {
+			continue
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     c.decl.Name.Pos(),
+			End:     c.decl.Name.End(),
+			Message: fmt.Sprintf("test helper %s does not call %s.Helper()", c.fn.Name(), c.param.Name()),
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   fmt.Sprintf("Call %s.Helper()", c.param.Name()),
+				TextEdits: []analysis.TextEdit{addHelper(c)},
+			}},
+		})
+	}
+	return nil, nil
+}
+
+// inspectCalls records the calls of the candidate's body, not
+// including those of function literals, through its testing parameter.
+func inspectCalls(pass *analysis.Pass, c *candidate) {
+	isParam := func(e ast.Expr) bool {
+		id, ok := e.(*ast.Ident)
+		return ok && pass.TypesInfo.Uses[id] == c.param
+	}
+	ast.Inspect(c.decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// The Helper method does not mark function literals.
+			return false
+		case *ast.CallExpr:
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok && isParam(sel.X) {
+				switch sel.Sel.Name {
+				case "Error", "Errorf", "Fatal", "Fatalf":
+					c.fails = true
+				case "Helper":
+					c.helper = true
+				}
+				return true
+			}
+			callee, ok := typeutil.Callee(pass.TypesInfo, n).(*types.Func)
+			if !ok {
+				return true
+			}
+			callee = typeparams.OriginMethod(callee)
+			if i := testingParam(callee.Type().(*types.Signature)); i >= 0 && i < len(n.Args) && isParam(n.Args[i]) {
+				c.calls = append(c.calls, callee)
+			}
+		}
+		return true
+	})
+}
+
+// addHelper returns the edit that calls the Helper method first in the
+// body of the candidate.
+func addHelper(c *candidate) analysis.TextEdit {
+	// A test helper has a statement that reports failures.
+	pos := c.decl.Body.List[0].Pos()
+	return analysis.TextEdit{
+		Pos:     pos,
+		End:     pos,
+		NewText: []byte(c.param.Name() + ".Helper()\n"),
+	}
+}
+
+// isTest reports whether fn is a test, benchmark or fuzz test, which
+// the testing package calls directly.
+func isTest(fn *types.Func) bool {
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() != nil || sig.Params().Len() != 1 || sig.Results().Len() != 0 {
+		return false
+	}
+	for _, prefix := range []string{"Test", "Benchmark", "Fuzz"} {
+		if !strings.HasPrefix(fn.Name(), prefix) {
+			continue
+		}
+		// As with go test, the prefix must not be followed by a
+		// lower-case letter: TestFoo, but not Testify.
+		rest := fn.Name()[len(prefix):]
+		if rest == "" {
+			return true
+		}
+		r, _ := utf8.DecodeRuneInString(rest)
+		return !unicode.IsLower(r)
+	}
+	return false
+}
+
+// testingParam returns the index of the first parameter of sig of type
+// *testing.T, *testing.B, *testing.F or testing.TB, or -1.
+func testingParam(sig *types.Signature) int {
+	for i := 0; i < sig.Params().Len(); i++ {
+		T := sig.Params().At(i).Type()
+		if ptr, ok := T.(*types.Pointer); ok {
+			T = ptr.Elem()
+			if isTesting(T, "T", "B", "F") {
+				return i
+			}
+		} else if isTesting(T, "TB") {
+			return i
+		}
+	}
+	return -1
+}
+
+// isTesting reports whether T is one of the named types of the testing
+// package.
+func isTesting(T types.Type, names ...string) bool {
+	named, ok := T.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	if obj.Pkg() == nil || obj.Pkg().Path() != "testing" {
+		return false
+	}
+	for _, name := range names {
+		if obj.Name() == name {
+			return true
+		}
+	}
+	return false
+}
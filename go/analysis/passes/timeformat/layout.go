@@ -0,0 +1,305 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timeformat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This file checks layouts against the elements of the reference time,
+// Mon Jan 2 15:04:05 MST 2006, as recognized by the time package.
+
+// An elemKind is the kind of value of an element of a layout.
+type elemKind int
+
+const (
+	yearElem elemKind = iota + 1
+	monthElem
+	dayElem
+	yearDayElem
+	weekdayElem
+	hour24Elem
+	hour12Elem
+	minuteElem
+	secondElem
+	pmElem
+	zoneElem
+	fractionElem
+)
+
+var elemNames = [...]string{
+	yearElem:     "year",
+	monthElem:    "month",
+	dayElem:      "day",
+	yearDayElem:  "day of the year",
+	weekdayElem:  "weekday",
+	hour24Elem:   "hour",
+	hour12Elem:   "hour",
+	minuteElem:   "minute",
+	secondElem:   "second",
+	pmElem:       "PM",
+	zoneElem:     "time zone",
+	fractionElem: "fractional second",
+}
+
+// An elem is an element of a layout, such as "2006" or "Jan".
+type elem struct {
+	kind       elemKind
+	start, end int // byte offsets in the layout
+}
+
+// elems returns the elements of the layout, following the rules of
+// the time package.
+func elems(layout string) []elem {
+	var res []elem
+	for i := 0; i < len(layout); {
+		kind, n := elemAt(layout, i)
+		if n == 0 {
+			i++
+			continue
+		}
+		res = append(res, elem{kind, i, i + n})
+		i += n
+	}
+	return res
+}
+
+// elemAt returns the kind and length of the element at layout[i:], or
+// a length of zero if the byte at i is literal text.
+func elemAt(layout string, i int) (elemKind, int) {
+	s := layout[i:]
+	prefix := func(p string) bool { return strings.HasPrefix(s, p) }
+	switch s[0] {
+	case 'J':
+		switch {
+		case prefix("January"):
+			return monthElem, 7
+		case prefix("Jan"):
+			return monthElem, 3
+		}
+	case 'M':
+		switch {
+		case prefix("Monday"):
+			return weekdayElem, 6
+		case prefix("Mon"):
+			return weekdayElem, 3
+		case prefix("MST"):
+			return zoneElem, 3
+		}
+	case '0':
+		if prefix("002") {
+			return yearDayElem, 3
+		}
+		if len(s) > 1 && '1' <= s[1] && s[1] <= '6' {
+			return [...]elemKind{monthElem, dayElem, hour12Elem, minuteElem, secondElem, yearElem}[s[1]-'1'], 2
+		}
+	case '1':
+		if prefix("15") {
+			return hour24Elem, 2
+		}
+		return monthElem, 1
+	case '2':
+		if prefix("2006") {
+			return yearElem, 4
+		}
+		return dayElem, 1
+	case '_':
+		switch {
+		case prefix("_2006"):
+			return 0, 0 // "_" is literal text
+		case prefix("__2"):
+			return yearDayElem, 3
+		case prefix("_2"):
+			return dayElem, 2
+		}
+	case '3':
+		return hour12Elem, 1
+	case '4':
+		return minuteElem, 1
+	case '5':
+		return secondElem, 1
+	case 'P':
+		if prefix("PM") {
+			return pmElem, 2
+		}
+	case 'p':
+		if prefix("pm") {
+			return pmElem, 2
+		}
+	case '-', 'Z':
+		for _, zone := range []string{"070000", "07:00:00", "0700", "07:00", "07"} {
+			if prefix(s[:1] + zone) {
+				return zoneElem, 1 + len(zone)
+			}
+		}
+	case '.', ',':
+		if len(s) > 1 && (s[1] == '0' || s[1] == '9') {
+			j := 1
+			for j < len(s) && s[j] == s[1] {
+				j++
+			}
+			if j == len(s) || !isDigit(s[j]) {
+				return fractionElem, j
+			}
+		}
+	}
+	return 0, 0
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// checkLayout returns a description of a likely mistake in the
+// layout, with a corrected layout if there is one.
+func checkLayout(layout string) (problem, fixed string) {
+	if fixed, ok := fromPlaceholders(layout); ok {
+		return "uses placeholders instead of the reference time Mon Jan 2 15:04:05 MST 2006", fixed
+	}
+
+	es := elems(layout)
+	text := func(e elem) string { return layout[e.start:e.end] }
+	replace := func(e elem, with string) string { return layout[:e.start] + with + layout[e.end:] }
+	// sep returns the text between the elements i-1 and i, or "" if
+	// there is no element i-1.
+	sep := func(i int) string {
+		if i == 0 {
+			return ""
+		}
+		return layout[es[i-1].end:es[i].start]
+	}
+	isTime := func(k elemKind) bool { return k == hour24Elem || k == hour12Elem || k == minuteElem || k == secondElem }
+	isDate := func(k elemKind) bool { return k == yearElem || k == monthElem || k == dayElem }
+
+	// Month and minute confusion, as in 15:01 or 2006-04-02.
+	for i, e := range es {
+		var prev, next elemKind
+		if i > 0 {
+			prev = es[i-1].kind
+		}
+		if i+1 < len(es) {
+			next = es[i+1].kind
+		}
+		switch {
+		case e.kind == monthElem && (text(e) == "01" || text(e) == "1") &&
+			(sep(i) == ":" && (prev == hour24Elem || prev == hour12Elem) || i+1 < len(es) && sep(i+1) == ":" && next == secondElem):
+			return fmt.Sprintf("uses %s (month) for the minute", text(e)), replace(e, strings.Replace(text(e), "1", "4", 1))
+
+		case e.kind == minuteElem && (isDateSep(sep(i)) && isDate(prev) || i+1 < len(es) && isDateSep(sep(i+1)) && isDate(next)):
+			return fmt.Sprintf("uses %s (minute) for the month", text(e)), replace(e, strings.Replace(text(e), "4", "1", 1))
+
+		case (e.kind == yearElem || e.kind == dayElem) && text(e) != "2006" && sep(i) == ":" && prev == minuteElem:
+			return fmt.Sprintf("uses %s (%s) for the second", text(e), elemNames[e.kind]), replace(e, "05")
+		}
+	}
+
+	// Elements of the same kind, or an inconsistent clock.
+	seen := make(map[elemKind]elem)
+	var hour, pm *elem
+	for i, e := range es {
+		k := e.kind
+		switch k {
+		case hour12Elem:
+			k = hour24Elem
+			hour = &es[i]
+		case hour24Elem:
+			hour = &es[i]
+		case pmElem:
+			pm = &es[i]
+		}
+		if prev, ok := seen[k]; ok && (isDate(k) || isTime(k)) {
+			return fmt.Sprintf("has two %s elements, %s and %s", elemNames[k], text(prev), text(e)), ""
+		}
+		seen[k] = e
+	}
+	switch {
+	case hour != nil && hour.kind == hour24Elem && pm != nil:
+		return "has PM with a 24-hour clock (15)", replace(*hour, "03")
+	case hour != nil && hour.kind == hour12Elem && pm == nil:
+		return fmt.Sprintf("has a 12-hour clock (%s) without PM", text(*hour)), replace(*hour, "15")
+	}
+	return "", ""
+}
+
+func isDateSep(s string) bool { return s == "-" || s == "/" || s == "." }
+
+// placeholders maps the placeholders of other languages' date formats
+// to the elements of the reference time. Hours are assumed to be of a
+// 24-hour clock, as there is no PM.
+var placeholders = map[string]string{
+	"YYYY": "2006", "yyyy": "2006",
+	"YY": "06", "yy": "06",
+	"MMMM": "January", "MMM": "Jan",
+	"MM": "01",
+	"DD": "02", "dd": "02",
+	"HH": "15", "hh": "15",
+	"mm":  "04",
+	"ss":  "05",
+	"SSS": "000", "SSSSSS": "000000", "SSSSSSSSS": "000000000",
+}
+
+// fromPlaceholders translates a layout that uses placeholders such as
+// YYYY-MM-DD. It reports false if the layout has none.
+func fromPlaceholders(layout string) (string, bool) {
+	// Split the words of the layout into runs of a letter.
+	type run struct {
+		text       string
+		start, end int
+	}
+	var runs []run
+	for i := 0; i < len(layout); {
+		if !isLetter(layout[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(layout) && isLetter(layout[j]) {
+			j++
+		}
+		var word []run
+		for k := i; k < j; {
+			l := k
+			for l < j && layout[l] == layout[k] {
+				l++
+			}
+			if _, ok := placeholders[layout[k:l]]; !ok {
+				word = nil // not a word of placeholders
+				break
+			}
+			word = append(word, run{layout[k:l], k, l})
+			k = l
+		}
+		runs = append(runs, word...)
+		i = j
+	}
+	if len(runs) == 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	last := 0
+	for i, r := range runs {
+		with := placeholders[r.text]
+		if r.text == "MM" || r.text == "mm" {
+			// A minute if next to an hour or second, else a month.
+			with = "01"
+			if i > 0 && isClockPlaceholder(runs[i-1].text) && layout[runs[i-1].end:r.start] == ":" ||
+				i+1 < len(runs) && isClockPlaceholder(runs[i+1].text) && layout[r.end:runs[i+1].start] == ":" ||
+				r.text == "mm" && !(i > 0 && isDateSep(layout[runs[i-1].end:r.start]) || i+1 < len(runs) && isDateSep(layout[r.end:runs[i+1].start])) {
+				with = "04"
+			}
+		}
+		b.WriteString(layout[last:r.start])
+		b.WriteString(with)
+		last = r.end
+	}
+	b.WriteString(layout[last:])
+	return b.String(), true
+}
+
+func isClockPlaceholder(s string) bool {
+	return s == "HH" || s == "hh" || s == "ss"
+}
+
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
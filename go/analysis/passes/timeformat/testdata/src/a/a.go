@@ -21,6 +21,29 @@ func hasError() {
 	a.Format(c) // want `2006-02-01 should be 2006-01-02`
 }
 
+func badLayouts(a time.Time, loc *time.Location) {
+	a.Format("YYYY-MM-DD")                             // want `layout "YYYY-MM-DD" uses placeholders instead of the reference time Mon Jan 2 15:04:05 MST 2006; use "2006-01-02"`
+	a.Format(`yyyy/mm/dd HH:mm:ss.SSS`)                // want `uses placeholders .*; use "2006/01/02 15:04:05.000"`
+	time.ParseInLocation("dd.MM.yyyy", "", loc)        // want `; use "02.01.2006"`
+	a.Format("2006-01-02 15:01:05")                    // want `layout "2006-01-02 15:01:05" uses 01 \(month\) for the minute; use "2006-01-02 15:04:05"`
+	a.Format("2006-04-02")                             // want `uses 04 \(minute\) for the month; use "2006-01-02"`
+	a.Format("15:04:06")                               // want `uses 06 \(year\) for the second; use "15:04:05"`
+	a.Format("2006-01-02 03:04:05")                    // want `has a 12-hour clock \(03\) without PM; use "2006-01-02 15:04:05"`
+	a.Format("15:04 PM")                               // want `has PM with a 24-hour clock \(15\); use "03:04 PM"`
+	a.AppendFormat(nil, "Jan 01 2006")                 // want `layout "Jan 01 2006" has two month elements, Jan and 01`
+	time.Parse("2006-01-02T15:04:05.000Z07:00", "")    // ok
+	a.Format(time.Kitchen + " Monday, January _2 MST") // ok
+
+	const layout = "YYYY"
+	a.Format(layout) // want `use "2006"`
+
+	v := "2006-02-01"
+	a.Format(v) // want `2006-02-01 should be 2006-01-02`
+
+	w := "MM/dd"
+	a.Format(w) // want `uses placeholders .*; use "01/02"`
+}
+
 func notHasError() {
 	a, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 	a.Format("2006-01-02")
@@ -29,7 +52,8 @@ func notHasError() {
 	a.Format(c)
 
 	v := "2006-02-01"
-	a.Format(v) // Allowed though variables.
+	v = "2006-01-02"
+	a.Format(v) // Allowed though variables assigned more than once.
 
 	m := map[string]string{
 		"y": "2006-02-01",
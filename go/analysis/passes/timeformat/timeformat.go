@@ -7,10 +7,12 @@
 package timeformat
 
 import (
+	"fmt"
 	"go/ast"
 	"go/constant"
 	"go/token"
 	"go/types"
+	"strconv"
 	"strings"
 
 	"golang.org/x/tools/go/analysis"
@@ -27,6 +29,18 @@ const Doc = `check for calls of (time.Time).Format or time.Parse with 2006-02-01
 The timeformat checker looks for time formats with the 2006-02-01 (yyyy-dd-mm)
 format. Internationally, "yyyy-dd-mm" does not occur in common calendar date
 standards, and so it is more likely that 2006-01-02 (yyyy-mm-dd) was intended.
+Layouts held in local variables that are assigned only where they are
+declared are checked like constant ones.
+
+It also checks constant layouts of time.Parse, time.ParseInLocation, and the
+Format and AppendFormat methods of time.Time for other likely mistakes, and
+suggests a corrected layout where it can:
+
+	placeholders such as "YYYY-MM-DD" instead of the reference time, "2006-01-02";
+	the month (01) for the minute, as in "15:01", or the minute (04) for the month;
+	the year (06) for the second, as in "15:04:06";
+	a 12-hour clock (03) without PM, or PM with a 24-hour clock (15);
+	two elements for the same value, such as "Jan" and "01".
 `
 
 var Analyzer = &analysis.Analyzer{
@@ -38,6 +52,7 @@ var Analyzer = &analysis.Analyzer{
 
 func run(pass *analysis.Pass) (interface{}, error) {
 	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	folder := typeutil.NewConstFolder(pass.TypesInfo, pass.Files)
 
 	nodeFilter := []ast.Node{
 		(*ast.CallExpr)(nil),
@@ -48,12 +63,13 @@ func run(pass *analysis.Pass) (interface{}, error) {
 		if !ok {
 			return
 		}
-		if !isTimeDotFormat(fn) && !isTimeDotParse(fn) {
+		index := layoutIndex(fn)
+		if index < 0 {
 			return
 		}
-		if len(call.Args) > 0 {
-			arg := call.Args[0]
-			badAt := badFormatAt(pass.TypesInfo, arg)
+		if len(call.Args) > index {
+			arg := call.Args[index]
+			badAt := badFormatAt(pass.TypesInfo, folder, arg)
 
 			if badAt > -1 {
 				// Check if it's a literal string, otherwise we can't suggest a fix.
@@ -77,14 +93,72 @@ func run(pass *analysis.Pass) (interface{}, error) {
 				} else {
 					pass.Reportf(arg.Pos(), badFormat+" should be "+goodFormat)
 				}
+			} else {
+				checkLayoutArg(pass, folder, arg)
 			}
 		}
 	})
 	return nil, nil
 }
 
+// checkLayoutArg reports a likely mistake in a constant layout, with a
+// fix if the layout is a literal and a corrected layout was found.
+func checkLayoutArg(pass *analysis.Pass, folder *typeutil.ConstFolder, arg ast.Expr) {
+	v := folder.Value(arg)
+	if v == nil || v.Kind() != constant.String {
+		return
+	}
+	layout := constant.StringVal(v)
+	problem, fixed := checkLayout(layout)
+	if problem == "" {
+		return
+	}
+	diag := analysis.Diagnostic{
+		Pos:     arg.Pos(),
+		End:     arg.End(),
+		Message: fmt.Sprintf("layout %q %s", layout, problem),
+	}
+	if fixed != "" {
+		diag.Message += fmt.Sprintf("; use %q", fixed)
+		if lit, ok := arg.(*ast.BasicLit); ok {
+			quoted := strconv.Quote(fixed)
+			if strings.HasPrefix(lit.Value, "`") && strconv.CanBackquote(fixed) {
+				quoted = "`" + fixed + "`"
+			}
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message: "Replace " + lit.Value + " with " + quoted,
+				TextEdits: []analysis.TextEdit{{
+					Pos:     lit.Pos(),
+					End:     lit.End(),
+					NewText: []byte(quoted),
+				}},
+			}}
+		}
+	}
+	pass.Report(diag)
+}
+
+// layoutIndex returns the index of the layout parameter of a function
+// of the time package that has one, or -1.
+func layoutIndex(fn *types.Func) int {
+	switch {
+	case isTimeDotFormat(fn), isTimeDotParse(fn):
+		return 0
+	case isTimeFunc(fn, "ParseInLocation"):
+		return 0
+	case isTimeMethod(fn, "AppendFormat"):
+		return 1
+	}
+	return -1
+}
+
 func isTimeDotFormat(f *types.Func) bool {
-	if f.Name() != "Format" || f.Pkg().Path() != "time" {
+	return isTimeMethod(f, "Format")
+}
+
+// isTimeMethod reports whether f is the named method of time.Time.
+func isTimeMethod(f *types.Func, name string) bool {
+	if f.Name() != name || f.Pkg() == nil || f.Pkg().Path() != "time" {
 		return false
 	}
 	sig, ok := f.Type().(*types.Signature)
@@ -101,7 +175,12 @@ func isTimeDotFormat(f *types.Func) bool {
 }
 
 func isTimeDotParse(f *types.Func) bool {
-	if f.Name() != "Parse" || f.Pkg().Path() != "time" {
+	return isTimeFunc(f, "Parse")
+}
+
+// isTimeFunc reports whether f is the named function of the time package.
+func isTimeFunc(f *types.Func, name string) bool {
+	if f.Name() != name || f.Pkg() == nil || f.Pkg().Path() != "time" {
 		return false
 	}
 	// Verify that there is no receiver.
@@ -110,7 +189,7 @@ func isTimeDotParse(f *types.Func) bool {
 }
 
 // badFormatAt return the start of a bad format in e or -1 if no bad format is found.
-func badFormatAt(info *types.Info, e ast.Expr) int {
+func badFormatAt(info *types.Info, folder *typeutil.ConstFolder, e ast.Expr) int {
 	tv, ok := info.Types[e]
 	if !ok { // no type info, assume good
 		return -1
@@ -121,9 +200,10 @@ func badFormatAt(info *types.Info, e ast.Expr) int {
 		return -1
 	}
 
-	if tv.Value == nil {
+	v := folder.Value(e)
+	if v == nil {
 		return -1
 	}
 
-	return strings.Index(constant.StringVal(tv.Value), badFormat)
+	return strings.Index(constant.StringVal(v), badFormat)
 }
@@ -38,4 +38,14 @@ func _[T any]() {
 	m := userdefs.MultiTypeParam[int, string]{X: 1, Y: "one"}
 	m.String() // want `result of \(typeparams/userdefs.MultiTypeParam\[int, string\]\).String call not used`
 	_ = m.String()
-}
\ No newline at end of file
+}
+func _[T fmt.Stringer, S interface {
+	~string
+	String() string
+}](t T, s S) {
+	t.String() // want `result of \(T\).String call not used`
+	s.String() // want `result of \(S\).String call not used`
+	_ = t.String()
+
+	userdefs.MustUse(3) // want "result of typeparams/userdefs.MustUse call not used"
+}
@@ -20,6 +20,12 @@
 //	)
 //
 //	func main() { singlechecker.Main(findbadness.Analyzer) }
+//
+// Like gofmt, the tool can analyze an editor's unsaved buffer,
+// read from its standard input, in the context of the package
+// that contains the file on disk:
+//
+//	findbadness -assume-filename=dir/file.go < buffer
 package singlechecker
 
 import (
@@ -62,7 +68,7 @@ func Main(a *analysis.Analyzer) {
 	analyzers = analysisflags.Parse(analyzers, false)
 
 	args := flag.Args()
-	if len(args) == 0 {
+	if len(args) == 0 && checker.AssumeFilename == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
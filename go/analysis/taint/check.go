@@ -0,0 +1,273 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package taint
+
+import (
+	"fmt"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+const (
+	sourceLabel = 1 // the label of the sources
+	maxParams   = 63
+)
+
+// paramLabel returns the label of parameter i, or 0 if it is not
+// tracked.
+func paramLabel(i int) uint64 {
+	if i >= maxParams {
+		return 0
+	}
+	return 1 << (i + 1)
+}
+
+// A checker holds the configuration of an analyzer.
+type checker struct {
+	newFact    func() SummaryFact
+	sources    map[string]bool
+	sanitizers map[string]bool
+	sinks      map[string]*Sink
+}
+
+// A state holds the state of the analysis of a package.
+type state struct {
+	*checker
+	pass      *analysis.Pass
+	summaries map[*ssa.Function]*Summary // of the source functions of the package
+	analyzed  map[*types.Package]bool    // whether the package was analyzed
+}
+
+func (c *checker) run(p *analysis.Pass) (interface{}, error) {
+	funcs := p.ResultOf[buildssa.Analyzer].(*buildssa.SSA).SrcFuncs
+	s := &state{
+		checker:   c,
+		pass:      p,
+		summaries: make(map[*ssa.Function]*Summary),
+		analyzed:  make(map[*types.Package]bool),
+	}
+	for _, fn := range funcs {
+		s.summaries[fn] = new(Summary)
+	}
+
+	// The summaries only grow, so they reach a fixed point.
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range funcs {
+			if sum := s.function(fn, false); sum != *s.summaries[fn] {
+				*s.summaries[fn] = sum
+				changed = true
+			}
+		}
+	}
+	for _, fn := range funcs {
+		s.function(fn, true)
+	}
+
+	for _, fn := range funcs {
+		if obj, ok := fn.Object().(*types.Func); ok && *s.summaries[fn] != (Summary{}) {
+			fact := c.newFact()
+			*fact.TaintSummary() = *s.summaries[fn]
+			p.ExportObjectFact(obj, fact)
+		}
+	}
+	p.ExportPackageFact(c.newFact())
+	return nil, nil
+}
+
+// function returns the summary of fn, given the current summaries of
+// the functions it calls. If report, it reports the calls of sinks
+// with tainted arguments.
+func (s *state) function(fn *ssa.Function, report bool) Summary {
+	labels := make(map[ssa.Value]uint64)
+	for i, p := range fn.Params {
+		labels[p] = paramLabel(i)
+	}
+	var changed bool
+	add := func(v ssa.Value, l uint64) {
+		if l&^labels[v] != 0 {
+			labels[v] |= l
+			changed = true
+		}
+	}
+
+	var space [10]*ssa.Value
+	for changed = true; changed; {
+		changed = false
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case *ssa.Store:
+					for addr := instr.Addr; addr != nil; addr = base(addr) {
+						add(addr, labels[instr.Val])
+					}
+				case *ssa.MapUpdate:
+					add(instr.Map, labels[instr.Key]|labels[instr.Value])
+				case *ssa.Send:
+					add(instr.Chan, labels[instr.X])
+				case *ssa.Call:
+					add(instr, s.call(instr.Common(), labels))
+				default:
+					if v, ok := instr.(ssa.Value); ok {
+						var l uint64
+						for _, op := range instr.Operands(space[:0]) {
+							if *op != nil {
+								l |= labels[*op]
+							}
+						}
+						add(v, l)
+					}
+				}
+			}
+		}
+	}
+
+	var sum Summary
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Return:
+				for _, r := range instr.Results {
+					sum.Results |= labels[r]
+				}
+			case ssa.CallInstruction:
+				sum.Sinks |= s.sinkFlows(instr, labels, report)
+			}
+		}
+	}
+	return sum
+}
+
+// base returns the address of the variable of which addr is the
+// address of a field or element, or nil.
+func base(addr ssa.Value) ssa.Value {
+	switch addr := addr.(type) {
+	case *ssa.FieldAddr:
+		return addr.X
+	case *ssa.IndexAddr:
+		return addr.X
+	}
+	return nil
+}
+
+// call returns the labels of the result of the call.
+func (s *state) call(call *ssa.CallCommon, labels map[ssa.Value]uint64) uint64 {
+	if callee := call.StaticCallee(); callee != nil {
+		name := funcName(callee)
+		switch {
+		case s.sources[name]:
+			return sourceLabel
+		case s.sanitizers[name]:
+			return 0
+		}
+		if sum := s.summary(callee); sum != nil {
+			l := sum.Results & sourceLabel
+			for i, arg := range call.Args {
+				if sum.Results&paramLabel(i) != 0 {
+					l |= labels[arg]
+				}
+			}
+			return l
+		}
+	}
+
+	// An unknown function: its result is tainted by its operands.
+	l := labels[call.Value]
+	for _, arg := range call.Args {
+		l |= labels[arg]
+	}
+	return l
+}
+
+// sinkFlows returns the labels of the parameters that flow into sinks
+// through the call, and, if report, reports the call if tainted values
+// flow into sinks.
+func (s *state) sinkFlows(call ssa.CallInstruction, labels map[ssa.Value]uint64, report bool) uint64 {
+	common := call.Common()
+	callee := common.StaticCallee()
+	if callee == nil {
+		return 0
+	}
+	name := funcName(callee)
+
+	var l uint64
+	var message string
+	if sink := s.sinks[name]; sink != nil {
+		args := sink.Args
+		if len(args) == 0 {
+			for i := range common.Args {
+				args = append(args, i)
+			}
+		}
+		for _, i := range args {
+			if i < len(common.Args) {
+				l |= labels[common.Args[i]]
+			}
+		}
+		message = sink.Message
+		if message == "" {
+			message = fmt.Sprintf("tainted value flows into %s", name)
+		}
+	} else if sum := s.summary(callee); sum != nil {
+		for i, arg := range common.Args {
+			if sum.Sinks&paramLabel(i) != 0 {
+				l |= labels[arg]
+			}
+		}
+		message = fmt.Sprintf("tainted value flows into a sink through %s", name)
+	}
+
+	if report && l&sourceLabel != 0 {
+		pos, end := ssa.SourceRange(call, s.pass.Files)
+		s.pass.Report(analysis.Diagnostic{Pos: pos, End: end, Message: message})
+	}
+	return l &^ sourceLabel
+}
+
+// summary returns the summary of the function, or nil if it is
+// unknown.
+func (s *state) summary(fn *ssa.Function) *Summary {
+	if sum := s.summaries[fn]; sum != nil {
+		return sum
+	}
+	if fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	obj, ok := fn.Object().(*types.Func)
+	if !ok || obj.Pkg() == nil || obj.Pkg() == s.pass.Pkg {
+		return nil
+	}
+	fact := s.newFact()
+	if s.pass.ImportObjectFact(obj, fact) {
+		return fact.TaintSummary()
+	}
+	if s.packageAnalyzed(obj.Pkg()) {
+		return new(Summary) // no flows
+	}
+	return nil
+}
+
+// packageAnalyzed reports whether the package was analyzed, which the
+// package fact of the analysis records.
+func (s *state) packageAnalyzed(pkg *types.Package) bool {
+	analyzed, ok := s.analyzed[pkg]
+	if !ok {
+		analyzed = s.pass.ImportPackageFact(pkg, s.newFact())
+		s.analyzed[pkg] = analyzed
+	}
+	return analyzed
+}
+
+// funcName returns the name of the function, or of its generic
+// function, as the configuration names it.
+func funcName(fn *ssa.Function) string {
+	if fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	return fn.String()
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package taint provides analyzers that report the flow of tainted
+// values, such as those of untrusted input, into sensitive functions,
+// as configured.
+//
+// A Config names the functions that are the sources of tainted
+// values, those that sanitize them, and the sinks whose arguments
+// must not be tainted. NewAnalyzer returns an analyzer that reports
+// each call of a sink with a tainted argument, such as a SQL query
+// built from the value of a form: security analyzers need only a
+// configuration, which may be read from JSON.
+//
+// Functions are named as by ssa.Function.String, that is, by the
+// path of their package and their name, with the receiver type of a
+// method in parentheses: "os.Getenv", or "(*database/sql.DB).Query".
+//
+// # Propagation
+//
+// Within a function, the analysis is a flow-insensitive propagation
+// over the SSA form of the function: a value is tainted if a value it
+// is computed from is tainted, and the memory to which a tainted value
+// is stored is tainted, as far as the analysis can tell without
+// pointer analysis: a store to a field or element of a variable taints
+// the whole variable. Global variables do not carry taint from one
+// function to another.
+//
+// Across functions, the analysis uses summaries of functions: which
+// of the parameters of a function, and whether the sources, taint its
+// results, and which of its parameters flow into sinks. The summaries
+// of the functions of a package are computed together, and exported as
+// facts for the analysis of its importers. A call of a function of an
+// unanalyzed package, or through a func value or an interface, has a
+// result that is tainted if any of its operands is.
+package taint // import "golang.org/x/tools/go/analysis/taint"
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+)
+
+// A Config describes what an analyzer considers tainted.
+type Config struct {
+	Sources    []string `json:"sources"`    // functions whose results are tainted
+	Sanitizers []string `json:"sanitizers"` // functions whose results are not
+	Sinks      []Sink   `json:"sinks"`      // functions whose arguments must not be
+}
+
+// A Sink is a function whose arguments must not be tainted.
+type Sink struct {
+	Func string `json:"func"`
+
+	// Args holds the indices of the arguments that must not be
+	// tainted, the receiver of a method being argument 0.
+	// If empty, no argument may be tainted.
+	Args []int `json:"args,omitempty"`
+
+	// Message is the message of the diagnostics of tainted
+	// arguments. If empty, it is "tainted value flows into F".
+	Message string `json:"message,omitempty"`
+}
+
+// A Summary describes the flow of taint through a function.
+//
+// The flows are described by labels: bit 0 stands for the sources,
+// and bit i+1 for parameter i of the function, the receiver of a
+// method being parameter 0. Parameters beyond the 63rd are not
+// tracked.
+type Summary struct {
+	Results uint64 // the labels of what taints the results
+	Sinks   uint64 // the labels of the parameters that flow into sinks
+}
+
+// TaintSummary returns s, so that the types that embed Summary have
+// the method of a SummaryFact.
+func (s *Summary) TaintSummary() *Summary { return s }
+
+func (s Summary) String() string {
+	return fmt.Sprintf("taint(results: %s; sinks: %s)", labelString(s.Results), labelString(s.Sinks))
+}
+
+func labelString(l uint64) string {
+	var names []string
+	if l&sourceLabel != 0 {
+		names = append(names, "source")
+	}
+	for i := 0; i < maxParams; i++ {
+		if l&paramLabel(i) != 0 {
+			names = append(names, fmt.Sprintf("p%d", i))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// A SummaryFact is a fact that holds a Summary.
+//
+// The fact type of an analyzer must be its own, so each analyzer of
+// NewAnalyzer needs a type of its own that embeds Summary, such as:
+//
+//	type sqlSummary struct{ taint.Summary }
+//
+//	func (*sqlSummary) AFact() {}
+//
+// The analyzer exports a fact of the type for each function whose
+// summary describes some flow, and a fact of the zero Summary for each
+// package it analyzes.
+type SummaryFact interface {
+	analysis.Fact
+	TaintSummary() *Summary
+}
+
+// NewAnalyzer returns an analyzer of the given name and documentation
+// that reports the calls of the sinks of the configuration with
+// tainted arguments, and whose fact type is that of fact.
+func NewAnalyzer(name, doc string, config *Config, fact SummaryFact) *analysis.Analyzer {
+	factType := reflect.TypeOf(fact).Elem()
+	c := &checker{
+		newFact:    func() SummaryFact { return reflect.New(factType).Interface().(SummaryFact) },
+		sources:    make(map[string]bool),
+		sanitizers: make(map[string]bool),
+		sinks:      make(map[string]*Sink),
+	}
+	for _, name := range config.Sources {
+		c.sources[name] = true
+	}
+	for _, name := range config.Sanitizers {
+		c.sanitizers[name] = true
+	}
+	for i := range config.Sinks {
+		c.sinks[config.Sinks[i].Func] = &config.Sinks[i]
+	}
+	return &analysis.Analyzer{
+		Name:      name,
+		Doc:       doc,
+		Run:       c.run,
+		Requires:  []*analysis.Analyzer{buildssa.Analyzer},
+		FactTypes: []analysis.Fact{fact},
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package taint_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/taint"
+)
+
+const config = `{
+	"sources": ["a.Source"],
+	"sanitizers": ["a.Clean"],
+	"sinks": [
+		{"func": "a.Sink"},
+		{"func": "a.SinkArg", "args": [1], "message": "SQL injection"}
+	]
+}`
+
+type testSummary struct{ taint.Summary }
+
+func (*testSummary) AFact() {}
+
+func Test(t *testing.T) {
+	var c taint.Config
+	if err := json.Unmarshal([]byte(config), &c); err != nil {
+		t.Fatal(err)
+	}
+	a := taint.NewAnalyzer("testtaint", "test taint analyzer", &c, new(testSummary))
+	analysistest.Run(t, analysistest.TestData(), a, "a", "b")
+}
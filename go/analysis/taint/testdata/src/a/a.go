@@ -0,0 +1,48 @@
+package a // want package:"taint\\(results: ; sinks: \\)"
+
+func Source() string { return "input" }
+
+func Clean(s string) string { return s } // want Clean:"taint\\(results: p0; sinks: \\)"
+
+func Sink(q string) {}
+
+func SinkArg(safe, q string) {}
+
+func Wrap(s string) string { return s + "!" } // want Wrap:"taint\\(results: p0; sinks: \\)"
+
+func Get() string { return Source() } // want Get:"taint\\(results: source; sinks: \\)"
+
+func Query(q string) { Sink(q) } // want Query:"taint\\(results: ; sinks: p0\\)"
+
+type DB struct{ last string }
+
+func (db *DB) Exec(q string) { // want Exec:"taint\\(results: ; sinks: p1\\)"
+	db.last = q
+	Sink(q)
+}
+
+func use() {
+	Sink(Source()) // want "tainted value flows into a.Sink"
+	Sink(Clean(Source()))
+	Sink(Wrap(Source())) // want "tainted value flows into a.Sink"
+	Query(Get())         // want "tainted value flows into a sink through a.Query"
+
+	var db DB
+	db.Exec(Source()) // want `tainted value flows into a sink through \(\*a.DB\).Exec`
+	db.Exec("constant")
+
+	SinkArg(Source(), "constant")
+	SinkArg("constant", Source()) // want "SQL injection"
+
+	m := map[string]string{}
+	m["k"] = Source()
+	Sink(m["k"]) // want "tainted value flows into a.Sink"
+
+	var s struct{ f, g string }
+	s.f = Source()
+	Sink(s.g) // want "tainted value flows into a.Sink"
+
+	id := func(x string) string { return x }
+	Sink(id(Source())) // want "tainted value flows into a.Sink"
+	Sink(id("constant"))
+}
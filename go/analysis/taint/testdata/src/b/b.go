@@ -0,0 +1,15 @@
+package b // want package:"taint\\(results: ; sinks: \\)"
+
+import "a"
+
+func F() {
+	a.Sink(a.Wrap(a.Get())) // want "tainted value flows into a.Sink"
+	a.Sink(a.Clean(a.Get()))
+}
+
+func G(s string) { a.Query(s) } // want G:"taint\\(results: ; sinks: p0\\)"
+
+func H() {
+	G(a.Source()) // want "tainted value flows into a sink through b.G"
+	G("constant")
+}
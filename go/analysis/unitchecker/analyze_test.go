@@ -0,0 +1,145 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker_test
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/findcall"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+// TestAnalyze exercises the hooks of Analyze for a unit whose files,
+// dependencies, and facts exist only in memory.
+func TestAnalyze(t *testing.T) {
+	// Dependency b, with a function F, is provided by the importer.
+	b := types.NewPackage("example.com/b", "b")
+	sig := types.NewSignatureType(nil, nil, nil, nil, nil, false)
+	b.Scope().Insert(types.NewFunc(token.NoPos, b, "F", sig))
+	b.MarkComplete()
+
+	files := map[string][]byte{
+		"/src/a/a.go": []byte("package a\n\nimport \"example.com/b\"\n\nfunc F() { b.F() }\n"),
+	}
+	written := make(map[string][]byte)
+	var reported []string
+	opts := &unitchecker.Options{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if path == b.Path() {
+				return b, nil
+			}
+			return nil, fmt.Errorf("no package %q", path)
+		}),
+		ReadFile: func(filename string) ([]byte, error) {
+			if data, ok := files[filename]; ok {
+				return data, nil
+			}
+			return nil, fmt.Errorf("no file %s", filename)
+		},
+		WriteFile: func(filename string, data []byte) error {
+			written[filename] = data
+			return nil
+		},
+		Report: func(a *analysis.Analyzer, diag analysis.Diagnostic) {
+			reported = append(reported, a.Name+": "+diag.Message)
+		},
+	}
+	cfg := &unitchecker.Config{
+		ID:         "example.com/a",
+		Compiler:   "gc",
+		ImportPath: "example.com/a",
+		GoFiles:    []string{"/src/a/a.go"},
+		ImportMap:  map[string]string{"example.com/b": "example.com/b"},
+		VetxOutput: "/out/a.vetx",
+		Analyzers:  map[string]map[string]string{"findcall": {"name": "F"}},
+	}
+	defer findcall.Analyzer.Flags.Set("name", "")
+
+	fset := token.NewFileSet()
+	results, err := unitchecker.Analyze(fset, cfg, []*analysis.Analyzer{findcall.Analyzer}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, res := range results {
+		if res.Err != nil {
+			t.Errorf("%s failed: %v", res.Analyzer, res.Err)
+		}
+		for _, diag := range res.Diagnostics {
+			got = append(got, fmt.Sprintf("%s: %s", fset.Position(diag.Pos), diag.Message))
+		}
+	}
+	if want := "/src/a/a.go:5:15: call of F(...)"; len(got) != 1 || got[0] != want {
+		t.Errorf("diagnostics = %q, want [%q]", got, want)
+	}
+	if want := "findcall: call of F(...)"; len(reported) != 1 || reported[0] != want {
+		t.Errorf("reported = %q, want [%q]", reported, want)
+	}
+	if _, ok := written[cfg.VetxOutput]; !ok {
+		t.Errorf("facts were not written to %s", cfg.VetxOutput)
+	}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+func TestTolerateMissingFacts(t *testing.T) {
+	b := types.NewPackage("b", "b")
+	b.MarkComplete()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	stale := filepath.Join(dir, "c.vetx")
+	if err := ioutil.WriteFile(src, []byte("package a\n\nimport (\n\t_ \"b\"\n\t_ \"c\"\n)\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(stale, []byte("not gob"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := types.NewPackage("c", "c")
+	c.MarkComplete()
+	opts := &unitchecker.Options{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			switch path {
+			case "b":
+				return b, nil
+			case "c":
+				return c, nil
+			}
+			return nil, fmt.Errorf("no package %q", path)
+		}),
+	}
+	cfg := &unitchecker.Config{
+		ID:          "a",
+		Compiler:    "gc",
+		ImportPath:  "a",
+		GoFiles:     []string{src},
+		ImportMap:   map[string]string{"b": "b", "c": "c"},
+		PackageVetx: map[string]string{"b": filepath.Join(dir, "missing.vetx"), "c": stale},
+		VetxOutput:  filepath.Join(dir, "a.vetx"),
+	}
+	analyzers := []*analysis.Analyzer{findcall.Analyzer}
+
+	if _, err := unitchecker.Analyze(token.NewFileSet(), cfg, analyzers, opts); err == nil {
+		t.Errorf("Analyze succeeded with missing facts")
+	}
+
+	cfg.TolerateMissingFacts = true
+	results, err := unitchecker.Analyze(token.NewFileSet(), cfg, analyzers, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := results[0].MissingFacts, []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MissingFacts = %q, want %q", got, want)
+	}
+}
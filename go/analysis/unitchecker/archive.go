@@ -0,0 +1,57 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// A sourceArchive is an open Config.SourceArchive.
+type sourceArchive struct {
+	rc    *zip.ReadCloser
+	files map[string]*zip.File // by entry name
+}
+
+func openArchive(filename string) (*sourceArchive, error) {
+	rc, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open source archive: %v", err)
+	}
+	ar := &sourceArchive{rc: rc, files: make(map[string]*zip.File)}
+	for _, f := range rc.File {
+		ar.files[f.Name] = f
+	}
+	return ar, nil
+}
+
+// entry returns the archive entry for the named file, or nil.
+// The name of the entry for a file is its name in slash form,
+// without a leading slash.
+func (ar *sourceArchive) entry(filename string) *zip.File {
+	return ar.files[strings.TrimPrefix(filepath.ToSlash(filename), "/")]
+}
+
+// readFile returns a function that reads the named file from the
+// archive, or, if it is not present, by calling fallback.
+func (ar *sourceArchive) readFile(fallback func(string) ([]byte, error)) func(string) ([]byte, error) {
+	return func(filename string) ([]byte, error) {
+		f := ar.entry(filename)
+		if f == nil {
+			return fallback(filename)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s in source archive: %v", filename, err)
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+}
+
+func (ar *sourceArchive) Close() error { return ar.rc.Close() }
@@ -0,0 +1,71 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+import (
+	"archive/zip"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/findcall"
+)
+
+func TestSourceArchive(t *testing.T) {
+	if err := findcall.Analyzer.Flags.Set("name", "println"); err != nil {
+		t.Fatal(err)
+	}
+	defer findcall.Analyzer.Flags.Set("name", "")
+
+	// The source file exists only in the archive.
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "src.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("nonexistent/a/a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("package a\n\nfunc f() { println() }\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		ID:            "a",
+		Compiler:      "gc",
+		ImportPath:    "a",
+		GoFiles:       []string{"/nonexistent/a/a.go"},
+		VetxOutput:    filepath.Join(dir, "a.vetx"),
+		SourceArchive: archive,
+	}
+
+	// Analyze the unit twice,
+	// the second time from the cache.
+	defer func(prev string) { cacheDir = prev }(cacheDir)
+	cacheDir = filepath.Join(dir, "cache")
+	for i := 0; i < 2; i++ {
+		fset := token.NewFileSet()
+		results, err := runCached(fset, cfg, []*analysis.Analyzer{findcall.Analyzer}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || len(results[0].Diagnostics) != 1 {
+			t.Fatalf("run %d: got results %+v, want one diagnostic", i, results)
+		}
+		posn := fset.Position(results[0].Diagnostics[0].Pos)
+		if got, want := posn.String(), "/nonexistent/a/a.go:3:19"; got != want {
+			t.Errorf("run %d: diagnostic at %s, want %s", i, got, want)
+		}
+	}
+}
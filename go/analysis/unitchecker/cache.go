@@ -0,0 +1,368 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+// This file defines the -cachedir mode, in which the facts and
+// diagnostics of each unit are saved in a cache, keyed by a hash of
+// all the inputs to the analysis, and replayed when the same unit is
+// analyzed again, without parsing or type-checking it.
+//
+// The layout of the cache is that of the go command's GOCACHE:
+// the entry for key k is the file dir/k[:2]/k-a.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// cacheDir is the value of the -cachedir flag.
+var cacheDir string
+
+// A cacheEntry is the JSON-encoded content of a cache file.
+type cacheEntry struct {
+	Facts   []byte // content of the VetxOutput file
+	NoFacts bool   `json:",omitempty"` // there was no VetxOutput file
+	Results []cachedResult
+}
+
+// A cachedResult is the position-independent form of a result.
+type cachedResult struct {
+	Analyzer    string
+	Err         string `json:",omitempty"`
+	Diagnostics []cachedDiagnostic
+}
+
+// A cachedDiagnostic is the position-independent form of
+// an analysis.Diagnostic; its positions are byte offsets.
+// The End of a Range is -1 if the original End was NoPos.
+type cachedDiagnostic struct {
+	Range          textEdit // New is unused
+	Category       string   `json:",omitempty"`
+	Message        string
+	SuggestedFixes []suggestedFix  `json:",omitempty"`
+	Related        []cachedRelated `json:",omitempty"`
+}
+
+type cachedRelated struct {
+	Range   textEdit // New is unused
+	Message string
+}
+
+// cacheKey returns the key of the cache entry for the analysis of
+// unit cfg by the specified analyzers.
+//
+// If the build system provides an action ID for the unit, it is
+// trusted to cover all the inputs. Otherwise the key is a hash of the
+// executable, the flags, the configuration, and the content of the
+// source files and of the export data and facts of the dependencies.
+func cacheKey(cfg *Config, analyzers []*analysis.Analyzer, readFile func(string) ([]byte, error)) (string, error) {
+	h := sha256.New()
+	if cfg.ActionID != "" {
+		fmt.Fprintf(h, "unitchecker action %s\n", cfg.ActionID)
+		return fmt.Sprintf("%x", h.Sum(nil)), nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if err := hashFile(h, exe); err != nil {
+		return "", err
+	}
+	for _, a := range analyzers {
+		fmt.Fprintf(h, "analyzer %s\n", a.Name)
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		switch f.Name {
+		case "cachedir", "fixdir":
+			return // no effect on results
+		}
+		fmt.Fprintf(h, "flag %s=%s\n", f.Name, f.Value)
+	})
+
+	// The output file does not affect the result.
+	copy := *cfg
+	copy.VetxOutput = ""
+	data, err := json.Marshal(copy)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+
+	var files []string
+	files = append(files, cfg.GoFiles...)
+	files = append(files, cfg.NonGoFiles...)
+	files = append(files, cfg.IgnoredFiles...)
+	files = append(files, sortedValues(cfg.PackageFile)...)
+	for _, file := range files {
+		data, err := readFile(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "file %s %d\n", file, len(data))
+		h.Write(data)
+	}
+	for _, file := range sortedValues(cfg.PackageVetx) {
+		data, err := readFile(file)
+		if err != nil {
+			if !cfg.TolerateMissingFacts {
+				return "", err
+			}
+			// The results will be incomplete, and not cached.
+			fmt.Fprintf(h, "missing facts %s\n", file)
+			continue
+		}
+		fmt.Fprintf(h, "facts %s %d\n", file, len(data))
+		h.Write(data)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// hashFile writes the content of the named file to h.
+func hashFile(h io.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+// sortedValues returns the values of m, ordered by key.
+func sortedValues(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = m[k]
+	}
+	return values
+}
+
+func cacheFile(key string) string {
+	return filepath.Join(cacheDir, key[:2], key+"-a")
+}
+
+// getCache returns the facts and results of the analysis of cfg from
+// the cache entry for key, and writes the facts to cfg.VetxOutput, if
+// set, using writeFile. It returns ok=false if there is no valid entry.
+func getCache(key string, fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer, readFile func(string) ([]byte, error), writeFile func(string, []byte) error) (results []Result, ok bool, err error) {
+	data, err := ioutil.ReadFile(cacheFile(key))
+	if err != nil {
+		return nil, false, nil // cache miss
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false, nil // corrupt entry; treat as a miss
+	}
+	if entry.NoFacts && cfg.VetxOutput != "" {
+		return nil, false, nil // the facts were not saved
+	}
+
+	byName := make(map[string]*analysis.Analyzer)
+	for _, a := range analyzers {
+		byName[a.Name] = a
+	}
+	files := make(map[string]*token.File)
+	for _, name := range cfg.GoFiles {
+		if _, err := addFile(fset, files, name, readFile); err != nil {
+			return nil, false, err
+		}
+	}
+	pos := func(r textEdit) (token.Pos, token.Pos, error) {
+		if r.File == "" {
+			return token.NoPos, token.NoPos, nil
+		}
+		file, err := addFile(fset, files, r.File, readFile)
+		if err != nil {
+			return token.NoPos, token.NoPos, err
+		}
+		if r.Start > file.Size() || r.End > file.Size() {
+			return token.NoPos, token.NoPos, fmt.Errorf("cached position in %s is beyond end of file", r.File)
+		}
+		if r.End < 0 {
+			return file.Pos(r.Start), token.NoPos, nil
+		}
+		return file.Pos(r.Start), file.Pos(r.End), nil
+	}
+
+	for _, cres := range entry.Results {
+		a := byName[cres.Analyzer]
+		if a == nil {
+			return nil, false, nil // stale entry
+		}
+		res := Result{Analyzer: a}
+		if cres.Err != "" {
+			res.Err = errors.New(cres.Err)
+		}
+		for _, cdiag := range cres.Diagnostics {
+			diag := analysis.Diagnostic{Category: cdiag.Category, Message: cdiag.Message}
+			if diag.Pos, diag.End, err = pos(cdiag.Range); err != nil {
+				return nil, false, err
+			}
+			for _, cfix := range cdiag.SuggestedFixes {
+				fix := analysis.SuggestedFix{Message: cfix.Message}
+				for _, cedit := range cfix.Edits {
+					start, end, err := pos(cedit)
+					if err != nil {
+						return nil, false, err
+					}
+					fix.TextEdits = append(fix.TextEdits, analysis.TextEdit{Pos: start, End: end, NewText: []byte(cedit.New)})
+				}
+				diag.SuggestedFixes = append(diag.SuggestedFixes, fix)
+			}
+			for _, crel := range cdiag.Related {
+				rel := analysis.RelatedInformation{Message: crel.Message}
+				if rel.Pos, rel.End, err = pos(crel.Range); err != nil {
+					return nil, false, err
+				}
+				diag.Related = append(diag.Related, rel)
+			}
+			res.Diagnostics = append(res.Diagnostics, diag)
+		}
+		results = append(results, res)
+	}
+
+	if cfg.VetxOutput != "" {
+		if err := writeFile(cfg.VetxOutput, entry.Facts); err != nil {
+			return nil, false, fmt.Errorf("failed to write analysis facts: %v", err)
+		}
+	}
+	return results, true, nil
+}
+
+// addFile adds the named file to fset, once, with the line
+// information of its current content.
+func addFile(fset *token.FileSet, files map[string]*token.File, filename string, readFile func(string) ([]byte, error)) (*token.File, error) {
+	if file, ok := files[filename]; ok {
+		return file, nil
+	}
+	content, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file *token.File
+	if strings.HasSuffix(filename, ".go") && bytes.Contains(content, []byte("line ")) {
+		// The file may have //line directives, as cgo-generated
+		// files do; only the scanner records their effect.
+		base := fset.Base()
+		parser.ParseFile(fset, filename, content, 0) // ignore errors
+		file = fset.File(token.Pos(base))
+	} else {
+		file = fset.AddFile(filename, -1, len(content))
+		file.SetLinesForContent(content)
+	}
+	files[filename] = file
+	return file, nil
+}
+
+// putCache saves the facts of the analysis of cfg, which have been
+// written to cfg.VetxOutput, if set, and are read by readFile, and its
+// results in the cache entry for key.
+func putCache(key string, fset *token.FileSet, cfg *Config, results []Result, readFile func(string) ([]byte, error)) error {
+	entry := cacheEntry{NoFacts: cfg.VetxOutput == ""}
+	if cfg.VetxOutput != "" {
+		facts, err := readFile(cfg.VetxOutput)
+		if err != nil {
+			return err
+		}
+		entry.Facts = facts
+	}
+	var err error
+	rng := func(start, end token.Pos) (textEdit, error) {
+		if !start.IsValid() {
+			return textEdit{}, nil
+		}
+		file := fset.File(start)
+		if file == nil {
+			return textEdit{}, fmt.Errorf("invalid position %d", start)
+		}
+		r := textEdit{File: file.Name(), Start: file.Offset(start), End: -1} // -1 => no end
+		if end.IsValid() {
+			if end < start || fset.File(end) != file {
+				return textEdit{}, fmt.Errorf("invalid range %d-%d", start, end)
+			}
+			r.End = file.Offset(end)
+		}
+		return r, nil
+	}
+	for _, res := range results {
+		cres := cachedResult{Analyzer: res.Analyzer.Name}
+		if res.Err != nil {
+			cres.Err = res.Err.Error()
+		}
+		for _, diag := range res.Diagnostics {
+			cdiag := cachedDiagnostic{Category: diag.Category, Message: diag.Message}
+			if cdiag.Range, err = rng(diag.Pos, diag.End); err != nil {
+				return fmt.Errorf("%s: diagnostic %q: %v", res.Analyzer.Name, diag.Message, err)
+			}
+			for _, fix := range diag.SuggestedFixes {
+				cfix := suggestedFix{Analyzer: res.Analyzer.Name, Message: fix.Message}
+				for _, edit := range fix.TextEdits {
+					cedit, err := rng(edit.Pos, edit.End)
+					if err != nil {
+						return fmt.Errorf("%s: suggested fix %q: %v", res.Analyzer.Name, fix.Message, err)
+					}
+					cedit.New = string(edit.NewText)
+					cfix.Edits = append(cfix.Edits, cedit)
+				}
+				cdiag.SuggestedFixes = append(cdiag.SuggestedFixes, cfix)
+			}
+			for _, rel := range diag.Related {
+				crel := cachedRelated{Message: rel.Message}
+				if crel.Range, err = rng(rel.Pos, rel.End); err != nil {
+					return fmt.Errorf("%s: related information %q: %v", res.Analyzer.Name, rel.Message, err)
+				}
+				cdiag.Related = append(cdiag.Related, crel)
+			}
+			cres.Diagnostics = append(cres.Diagnostics, cdiag)
+		}
+		entry.Results = append(entry.Results, cres)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Write the entry atomically, as another process
+	// may be reading or writing the same one.
+	filename := cacheFile(key)
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
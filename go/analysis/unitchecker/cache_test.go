@@ -0,0 +1,171 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+import (
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestCache(t *testing.T) {
+	// The analyzer reports each call, with a fix,
+	// and counts its runs.
+	runs := 0
+	a := &analysis.Analyzer{
+		Name: "calls",
+		Doc:  "report calls",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			runs++
+			for _, f := range pass.Files {
+				ast.Inspect(f, func(n ast.Node) bool {
+					if call, ok := n.(*ast.CallExpr); ok {
+						pass.Report(analysis.Diagnostic{
+							Pos:     call.Pos(),
+							Message: "call",
+							SuggestedFixes: []analysis.SuggestedFix{{
+								Message:   "delete",
+								TextEdits: []analysis.TextEdit{{Pos: call.Pos(), End: call.End()}},
+							}},
+						})
+					}
+					return true
+				})
+			}
+			return nil, nil
+		},
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	writeFile(t, src, "package a\n\nfunc f() {\n\tprintln(\"hi\")\n}\n")
+	cfg := &Config{
+		ID:         "a",
+		Compiler:   "gc",
+		ImportPath: "a",
+		GoFiles:    []string{src},
+		VetxOutput: filepath.Join(dir, "a.vetx"),
+	}
+
+	defer func(prev string) { cacheDir = prev }(cacheDir)
+	cacheDir = filepath.Join(dir, "cache")
+
+	type diag struct {
+		Posn, Fix string
+		End       bool
+	}
+	analyze := func() []diag {
+		fset := token.NewFileSet()
+		results, err := runCached(fset, cfg, []*analysis.Analyzer{a}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var diags []diag
+		for _, res := range results {
+			for _, d := range res.Diagnostics {
+				edit := d.SuggestedFixes[0].TextEdits[0]
+				diags = append(diags, diag{
+					Posn: fset.Position(d.Pos).String(),
+					Fix:  fset.Position(edit.Pos).String() + "-" + fset.Position(edit.End).String(),
+					End:  d.End.IsValid(),
+				})
+			}
+		}
+		return diags
+	}
+
+	first := analyze()
+	if runs != 1 || len(first) != 1 {
+		t.Fatalf("first analysis: %d runs, diagnostics %v; want 1 run, 1 diagnostic", runs, first)
+	}
+
+	// An identical unit is not analyzed again;
+	// its facts and diagnostics are replayed.
+	if err := os.Remove(cfg.VetxOutput); err != nil {
+		t.Fatal(err)
+	}
+	if second := analyze(); runs != 1 || !reflect.DeepEqual(second, first) {
+		t.Errorf("second analysis: %d runs, diagnostics %v; want 1 run, diagnostics %v", runs, second, first)
+	}
+	if _, err := os.Stat(cfg.VetxOutput); err != nil {
+		t.Errorf("facts were not replayed: %v", err)
+	}
+
+	// A change to the source invalidates the entry.
+	writeFile(t, src, "package a\n\nfunc f() {\n\tprintln(\"hi\")\n\tprintln()\n}\n")
+	if third := analyze(); runs != 2 || len(third) != 2 {
+		t.Errorf("after change: %d runs, diagnostics %v; want 2 runs, 2 diagnostics", runs, third)
+	}
+}
+
+func TestCacheHooks(t *testing.T) {
+	a := &analysis.Analyzer{
+		Name: "nop",
+		Doc:  "do nothing",
+		Run:  func(pass *analysis.Pass) (interface{}, error) { return nil, nil },
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	writeFile(t, src, "package a\n")
+	cfg := &Config{
+		ID:         "a",
+		Compiler:   "gc",
+		ImportPath: "a",
+		GoFiles:    []string{src},
+	}
+
+	defer func(prev string) { cacheDir = prev }(cacheDir)
+	cacheDir = filepath.Join(dir, "cache")
+
+	// The facts files are read and written by the hooks.
+	files := make(map[string][]byte)
+	opts := &Options{
+		ReadFile: func(filename string) ([]byte, error) {
+			if data, ok := files[filename]; ok {
+				return data, nil
+			}
+			return ioutil.ReadFile(filename)
+		},
+		WriteFile: func(filename string, data []byte) error {
+			files[filename] = data
+			return nil
+		},
+	}
+	analyze := func() {
+		if _, err := runCached(token.NewFileSet(), cfg, []*analysis.Analyzer{a}, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without a facts output, no facts are saved or restored.
+	analyze()
+	analyze()
+	if len(files) != 0 {
+		t.Errorf("facts were written without VetxOutput: %v", files)
+	}
+
+	// The entry without facts does not satisfy a unit that wants them.
+	cfg.VetxOutput = filepath.Join(dir, "a.vetx")
+	analyze()
+	facts, ok := files[cfg.VetxOutput]
+	if !ok {
+		t.Fatalf("facts were not written by the hook")
+	}
+	delete(files, cfg.VetxOutput)
+	analyze()
+	if replayed, ok := files[cfg.VetxOutput]; !ok || !reflect.DeepEqual(replayed, facts) {
+		t.Errorf("replayed facts %q, want %q", replayed, facts)
+	}
+	if _, err := os.Stat(cfg.VetxOutput); !os.IsNotExist(err) {
+		t.Errorf("facts were written to disk: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+// This file defines support for units that use cgo.
+//
+// A build system may provide either the original files of such a
+// unit, which import "C", or, as "go vet" does, the files produced by
+// cgo: for each original file x.go, a file x.cgo1.go whose //line
+// directives map its positions back to x.go, and the wholly
+// generated _cgo_gotypes.go. Diagnostics in the generated code are
+// of no interest to the user, so they are suppressed.
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// importsC reports whether any of the files imports "C",
+// that is, whether they are the original files of a cgo unit.
+func importsC(files []*ast.File) bool {
+	for _, f := range files {
+		for _, spec := range f.Imports {
+			if path, _ := strconv.Unquote(spec.Path.Value); path == "C" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCgoGenerated reports whether the file was generated by cgo.
+func isCgoGenerated(f *ast.File) bool {
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "// Code generated by cmd/cgo;") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// A cgoFilter is the set of names of the cgo-generated files of a unit.
+type cgoFilter map[string]bool
+
+// filter reports whether the diagnostic d should be reported, that
+// is, whether its position, adjusted by //line directives, lies
+// outside the generated files. It also removes from d any suggested
+// fixes that would edit the generated files.
+func (generated cgoFilter) filter(fset *token.FileSet, d *analysis.Diagnostic) bool {
+	if len(generated) == 0 {
+		return true
+	}
+	if generated[fset.Position(d.Pos).Filename] {
+		return false
+	}
+	var fixes []analysis.SuggestedFix
+fixes:
+	for _, fix := range d.SuggestedFixes {
+		for _, edit := range fix.TextEdits {
+			if generated[fset.PositionFor(edit.Pos, false).Filename] {
+				continue fixes
+			}
+		}
+		fixes = append(fixes, fix)
+	}
+	d.SuggestedFixes = fixes
+	return true
+}
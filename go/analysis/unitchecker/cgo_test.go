@@ -0,0 +1,83 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/findcall"
+)
+
+// TestCgoProcessed analyzes the cgo-processed form of a unit,
+// as go vet provides it.
+func TestCgoProcessed(t *testing.T) {
+	dir := t.TempDir()
+	orig := filepath.Join(dir, "a.go")
+	writeFile(t, orig, "package a\n\nimport \"C\"\n\nfunc f() { println() }\n")
+	cgo1 := filepath.Join(dir, "a.cgo1.go")
+	writeFile(t, cgo1, fmt.Sprintf(`// Code generated by cmd/cgo; DO NOT EDIT.
+
+//line %s:1:1
+package a
+
+import _ "unsafe"
+
+func f() { println() }
+`, orig))
+	gotypes := filepath.Join(dir, "_cgo_gotypes.go")
+	writeFile(t, gotypes, "// Code generated by cmd/cgo; DO NOT EDIT.\n\npackage a\n\nfunc _cgo_init() { println() }\n")
+
+	got := analyzeCgo(t, dir, []string{cgo1, gotypes})
+	want := []string{orig + ":5:19: call of println(...) (0 fixes)"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("diagnostics = %q, want %q", got, want)
+	}
+}
+
+// TestCgoOriginal analyzes the original form of a unit,
+// which imports "C".
+func TestCgoOriginal(t *testing.T) {
+	dir := t.TempDir()
+	orig := filepath.Join(dir, "a.go")
+	writeFile(t, orig, "package a\n\nimport \"C\"\n\nfunc f() { C.g(); println() }\n")
+
+	got := analyzeCgo(t, dir, []string{orig})
+	want := []string{orig + ":5:26: call of println(...) (1 fixes)"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("diagnostics = %q, want %q", got, want)
+	}
+}
+
+func analyzeCgo(t *testing.T, dir string, files []string) []string {
+	if err := findcall.Analyzer.Flags.Set("name", "println"); err != nil {
+		t.Fatal(err)
+	}
+	defer findcall.Analyzer.Flags.Set("name", "")
+
+	cfg := &Config{
+		ID:         "a",
+		Compiler:   "gc",
+		ImportPath: "a",
+		GoFiles:    files,
+		ImportMap:  map[string]string{"unsafe": "unsafe"},
+		VetxOutput: filepath.Join(dir, "a.vetx"),
+	}
+	fset := token.NewFileSet()
+	results, err := Analyze(fset, cfg, []*analysis.Analyzer{findcall.Analyzer}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []string
+	for _, res := range results {
+		for _, d := range res.Diagnostics {
+			diags = append(diags, fmt.Sprintf("%s: %s (%d fixes)", fset.Position(d.Pos), d.Message, len(d.SuggestedFixes)))
+		}
+	}
+	return diags
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+// This file defines the -fixdir mode, in which each compilation unit
+// records the suggested fixes of its diagnostics in a sidecar file,
+// and the applyfixes subcommand, which applies the fixes of all units
+// once the build is complete. A single unit cannot apply fixes itself
+// because a file may belong to several units (such as p and p.test)
+// that are analyzed concurrently.
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/edits"
+)
+
+// fixDir is the value of the -fixdir flag.
+var fixDir string
+
+// A unitFixes is the JSON-encoded content of a sidecar file,
+// holding the suggested fixes of the diagnostics of one unit.
+type unitFixes struct {
+	ID    string // unit ID, e.g. "fmt [fmt.test]"
+	Fixes []suggestedFix
+}
+
+// A suggestedFix is a machine-readable analysis.SuggestedFix.
+// Its edits must be applied together or not at all.
+type suggestedFix struct {
+	Analyzer string
+	Message  string
+	Edits    []textEdit
+}
+
+// A textEdit replaces the bytes [Start, End) of File by New.
+type textEdit struct {
+	File       string
+	Start, End int // byte offsets
+	New        string
+}
+
+// writeFixes writes the suggested fixes of the diagnostics in results
+// to a sidecar file for the unit cfg in directory dir.
+func writeFixes(dir string, fset *token.FileSet, cfg *Config, results []Result) error {
+	out := unitFixes{ID: cfg.ID}
+	for _, res := range results {
+		for _, diag := range res.Diagnostics {
+			for _, sf := range diag.SuggestedFixes {
+				fix := suggestedFix{Analyzer: res.Analyzer.Name, Message: sf.Message}
+				for _, edit := range sf.TextEdits {
+					file := fset.File(edit.Pos)
+					if file == nil || edit.End < edit.Pos || fset.File(edit.End) != file {
+						return fmt.Errorf("%s: suggested fix %q has an invalid edit", res.Analyzer.Name, sf.Message)
+					}
+					fix.Edits = append(fix.Edits, textEdit{
+						File:  file.Name(),
+						Start: file.Offset(edit.Pos),
+						End:   file.Offset(edit.End),
+						New:   string(edit.NewText),
+					})
+				}
+				if len(fix.Edits) > 0 {
+					out.Fixes = append(out.Fixes, fix)
+				}
+			}
+		}
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	// Name the file after the unit, which may be analyzed again
+	// in a later build, replacing the stale file.
+	name := fmt.Sprintf("%x.fixes.json", sha256.Sum256([]byte(cfg.ID)))
+	if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0666); err != nil {
+		return fmt.Errorf("failed to write suggested fixes: %v", err)
+	}
+	return nil
+}
+
+// applyFixes applies the suggested fixes recorded in the sidecar files
+// in dir to the source files, and reformats them.
+//
+// Fixes are considered in a deterministic order. Edits identical to
+// an already accepted one, as arise when a file belongs to several
+// units, are redundant. A fix with an edit overlapping a different,
+// already accepted edit conflicts with it and is skipped in its
+// entirety; each skipped fix is described in the returned list.
+func applyFixes(dir string) (skipped []string, err error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.fixes.json"))
+	if err != nil {
+		return nil, err
+	}
+	var fixes []suggestedFix
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var unit unitFixes
+		if err := json.Unmarshal(data, &unit); err != nil {
+			return nil, fmt.Errorf("cannot decode suggested fixes %s: %v", name, err)
+		}
+		for _, fix := range unit.Fixes {
+			// A fix without edits has no effect, and no position
+			// by which to order it.
+			if len(fix.Edits) > 0 {
+				fixes = append(fixes, fix)
+			}
+		}
+	}
+	sort.SliceStable(fixes, func(i, j int) bool {
+		x, y := fixes[i], fixes[j]
+		if x.Edits[0].File != y.Edits[0].File {
+			return x.Edits[0].File < y.Edits[0].File
+		}
+		if x.Edits[0].Start != y.Edits[0].Start {
+			return x.Edits[0].Start < y.Edits[0].Start
+		}
+		if x.Analyzer != y.Analyzer {
+			return x.Analyzer < y.Analyzer
+		}
+		return x.Message < y.Message
+	})
+
+	set := edits.Set{Format: true}
+	for _, fix := range fixes {
+		var fixEdits []edits.Edit
+		for _, edit := range fix.Edits {
+			fixEdits = append(fixEdits, edits.Edit{Filename: edit.File, Start: edit.Start, End: edit.End, New: edit.New})
+		}
+		if err := set.Add(fixEdits...); err != nil {
+			conflict, ok := err.(*edits.ConflictError)
+			if !ok {
+				return skipped, err
+			}
+			skipped = append(skipped, fmt.Sprintf("%s: %s: fix %q conflicts with another edit of bytes %d-%d",
+				conflict.Prev.Filename, fix.Analyzer, fix.Message, conflict.Prev.Start, conflict.Prev.End))
+		}
+	}
+
+	for _, file := range set.Filenames() {
+		if _, _, err := set.Content(file); err != nil {
+			return skipped, fmt.Errorf("%v; was it modified since the build?", err)
+		}
+	}
+	return skipped, set.Apply()
+}
+
+// applyFixesMain implements the applyfixes subcommand.
+func applyFixesMain(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: applyfixes dir")
+		return 2
+	}
+	skipped, err := applyFixes(args[0])
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d conflicting fixes:\n\t%s\n", len(skipped), strings.Join(skipped, "\n\t"))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+import (
+	"encoding/json"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/findcall"
+)
+
+// TestApplyFixes analyzes a package as two units, as "go vet" does for
+// a package with tests, and checks that their fixes are applied once.
+func TestApplyFixes(t *testing.T) {
+	if err := findcall.Analyzer.Flags.Set("name", "println"); err != nil {
+		t.Fatal(err)
+	}
+	defer findcall.Analyzer.Flags.Set("name", "")
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	writeFile(t, src, "package a\n\nfunc f() {\n\tprintln(\"hi\")\n}\n")
+
+	fixes := filepath.Join(dir, "fixes")
+	for _, id := range []string{"a", "a [a.test]"} {
+		cfg := &Config{
+			ID:         id,
+			Compiler:   "gc",
+			Dir:        dir,
+			ImportPath: "a",
+			GoFiles:    []string{src},
+			VetxOutput: filepath.Join(dir, strings.Replace(id, " ", "_", -1)+".vetx"),
+		}
+		fset := token.NewFileSet()
+		results, err := Analyze(fset, cfg, []*analysis.Analyzer{findcall.Analyzer}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFixes(fixes, fset, cfg, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	skipped, err := applyFixes(fixes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) > 0 {
+		t.Errorf("unexpected conflicts: %q", skipped)
+	}
+	want := "package a\n\nfunc f() {\n\tprintln_TEST_(\"hi\")\n}\n"
+	if got := readFile(t, src); got != want {
+		t.Errorf("fixed file:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestApplyFixesConflict(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	writeFile(t, src, "package a\n\nvar x, y = 1, 2\n")
+
+	// Both units rename x, differently; only one fix may win,
+	// along with the unconflicted fix that renames y.
+	units := []unitFixes{
+		{ID: "a", Fixes: []suggestedFix{
+			{Analyzer: "rename", Message: "x to xx", Edits: []textEdit{{File: src, Start: 15, End: 16, New: "xx"}}},
+		}},
+		{ID: "b", Fixes: []suggestedFix{
+			{Analyzer: "rename", Message: "x and y to z", Edits: []textEdit{
+				{File: src, Start: 18, End: 19, New: "z"},
+				{File: src, Start: 15, End: 16, New: "z"},
+			}},
+			{Analyzer: "rename", Message: "y to yy", Edits: []textEdit{{File: src, Start: 18, End: 19, New: "yy"}}},
+		}},
+	}
+	fixes := filepath.Join(dir, "fixes")
+	if err := os.Mkdir(fixes, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range units {
+		data, err := json.Marshal(unit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(fixes, unit.ID+".fixes.json"), string(data))
+	}
+
+	skipped, err := applyFixes(fixes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || !strings.Contains(skipped[0], `"x and y to z"`) {
+		t.Errorf("skipped = %q, want the fix \"x and y to z\"", skipped)
+	}
+	want := "package a\n\nvar xx, yy = 1, 2\n"
+	if got := readFile(t, src); got != want {
+		t.Errorf("fixed file:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestApplyFixesNoEdits checks that a fix without edits, which
+// writeFixes never records, is ignored.
+func TestApplyFixesNoEdits(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	writeFile(t, src, "package a\n\nvar x = 1\n")
+
+	unit := unitFixes{ID: "a", Fixes: []suggestedFix{
+		{Analyzer: "empty", Message: "nothing"},
+		{Analyzer: "rename", Message: "x to xx", Edits: []textEdit{{File: src, Start: 15, End: 16, New: "xx"}}},
+		{Analyzer: "empty", Message: "nothing either", Edits: []textEdit{}},
+	}}
+	fixes := filepath.Join(dir, "fixes")
+	if err := os.Mkdir(fixes, 0777); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(unit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(fixes, "a.fixes.json"), string(data))
+
+	skipped, err := applyFixes(fixes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) > 0 {
+		t.Errorf("unexpected conflicts: %q", skipped)
+	}
+	want := "package a\n\nvar xx = 1\n"
+	if got := readFile(t, src); got != want {
+		t.Errorf("fixed file:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func writeFile(t *testing.T, filename, content string) {
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, filename string) string {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+type nameFact struct{ Name string }
+
+func (*nameFact) AFact()           {}
+func (f *nameFact) String() string { return f.Name }
+
+// TestVetxFormat checks that facts written in either format
+// are read by the analysis of an importing unit.
+func TestVetxFormat(t *testing.T) {
+	// The analyzer exports the name of each package as a fact,
+	// and reports the facts of its imports.
+	a := &analysis.Analyzer{
+		Name:      "names",
+		Doc:       "report names of imports",
+		FactTypes: []analysis.Fact{new(nameFact)},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			pass.ExportPackageFact(&nameFact{pass.Pkg.Name()})
+			for _, imp := range pass.Pkg.Imports() {
+				var fact nameFact
+				if pass.ImportPackageFact(imp, &fact) {
+					pass.Reportf(pass.Files[0].Package, "%s has fact %s", imp.Path(), fact.Name)
+				}
+			}
+			return nil, nil
+		},
+	}
+
+	for _, format := range []string{"gob", "json"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			bsrc := filepath.Join(dir, "b.go")
+			writeFile(t, bsrc, "package b\n")
+			bcfg := &Config{
+				ID:         "b",
+				Compiler:   "gc",
+				ImportPath: "b",
+				GoFiles:    []string{bsrc},
+				VetxOutput: filepath.Join(dir, "b.vetx"),
+				VetxFormat: format,
+			}
+			if _, err := Analyze(token.NewFileSet(), bcfg, []*analysis.Analyzer{a}, nil); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadFile(bcfg.VetxOutput)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isJSON := bytes.HasPrefix(data, []byte("go/analysis facts: json\n")); isJSON != (format == "json") {
+				t.Errorf("facts are not in %s format: %q", format, data)
+			}
+
+			asrc := filepath.Join(dir, "a.go")
+			writeFile(t, asrc, "package a\n\nimport _ \"b\"\n")
+			b := types.NewPackage("b", "b")
+			b.MarkComplete()
+			acfg := &Config{
+				ID:          "a",
+				Compiler:    "gc",
+				ImportPath:  "a",
+				GoFiles:     []string{asrc},
+				ImportMap:   map[string]string{"b": "b"},
+				PackageVetx: map[string]string{"b": bcfg.VetxOutput},
+				VetxOutput:  filepath.Join(dir, "a.vetx"),
+			}
+			opts := &Options{
+				Importer: importerFunc(func(path string) (*types.Package, error) {
+					if path == "b" {
+						return b, nil
+					}
+					return nil, fmt.Errorf("no package %q", path)
+				}),
+			}
+			results, err := Analyze(token.NewFileSet(), acfg, []*analysis.Analyzer{a}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results[0].Diagnostics) != 1 || results[0].Diagnostics[0].Message != "b has fact b" {
+				t.Errorf("got diagnostics %v, want one reporting the fact of b", results[0].Diagnostics)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// TestParallelLimit checks that no more than GOMAXPROCS
+// independent analyzers run at once.
+func TestParallelLimit(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))
+
+	var (
+		mu               sync.Mutex
+		running, maxRuns int
+	)
+	var analyzers []*analysis.Analyzer
+	for i := 0; i < 8; i++ {
+		analyzers = append(analyzers, &analysis.Analyzer{
+			Name: fmt.Sprintf("a%d", i),
+			Doc:  "sleep",
+			Run: func(pass *analysis.Pass) (interface{}, error) {
+				mu.Lock()
+				running++
+				if running > maxRuns {
+					maxRuns = running
+				}
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil, nil
+			},
+		})
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	writeFile(t, src, "package a\n")
+	cfg := &Config{
+		ID:         "a",
+		Compiler:   "gc",
+		ImportPath: "a",
+		GoFiles:    []string{src},
+		VetxOutput: filepath.Join(dir, "a.vetx"),
+	}
+	results, err := Analyze(token.NewFileSet(), cfg, analyzers, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(analyzers) {
+		t.Errorf("got %d results, want %d", len(results), len(analyzers))
+	}
+	if maxRuns > 2 {
+		t.Errorf("%d analyzers ran at once, want at most GOMAXPROCS=2", maxRuns)
+	}
+}
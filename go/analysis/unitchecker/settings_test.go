@@ -0,0 +1,156 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unitchecker
+
+import (
+	"encoding/json"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/findcall"
+	"golang.org/x/tools/go/analysis/passes/printf"
+)
+
+func TestSettings(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	writeFile(t, src, "package a\n\nfunc f() {\n\tprintln(\"hi\")\n}\n")
+
+	cfgFile := filepath.Join(dir, "a.cfg")
+	cfgJSON, err := json.Marshal(map[string]interface{}{
+		"ID":         "a",
+		"Compiler":   "gc",
+		"ImportPath": "a",
+		"GoFiles":    []string{src},
+		"VetxOutput": filepath.Join(dir, "a.vetx"),
+		"analyzers": map[string]map[string]string{
+			"findcall": {"name": "println"},
+			"unknown":  {"x": "y"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, cfgFile, string(cfgJSON))
+
+	cfg, err := ReadConfig(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyzers := []*analysis.Analyzer{findcall.Analyzer}
+	defer findcall.Analyzer.Flags.Set("name", "")
+	results, err := Analyze(token.NewFileSet(), cfg, analyzers, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Diagnostics) != 1 {
+		t.Fatalf("got %d results, want one with one diagnostic", len(results))
+	}
+	if got, want := results[0].Diagnostics[0].Message, "call of println(...)"; got != want {
+		t.Errorf("diagnostic = %q, want %q", got, want)
+	}
+	// The settings do not outlive the call.
+	if got := findcall.Analyzer.Flags.Lookup("name").Value.String(); got != "" {
+		t.Errorf("after Analyze, -findcall.name = %q, want the previous value %q", got, "")
+	}
+
+	// An unknown flag of a known analyzer is an error.
+	cfg.Analyzers = map[string]map[string]string{"findcall": {"nosuchflag": "1"}}
+	if _, err := applySettings(cfg, analyzers); err == nil || !strings.Contains(err.Error(), "-findcall.nosuchflag") {
+		t.Errorf("applySettings with unknown flag: got error %v", err)
+	}
+
+	// The flags set before the error are restored.
+	cfg.Analyzers = map[string]map[string]string{"findcall": {"name": "println", "nosuchflag": "1"}}
+	if _, err := applySettings(cfg, analyzers); err == nil {
+		t.Errorf("applySettings with unknown flag succeeded")
+	}
+	if got := findcall.Analyzer.Flags.Lookup("name").Value.String(); got != "" {
+		t.Errorf("after a failed applySettings, -findcall.name = %q, want %q", got, "")
+	}
+}
+
+// TestSettingsRestored checks that settings of flags whose Set adds to
+// their value, such as printf's -funcs, do not outlive the call.
+func TestSettingsRestored(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	writeFile(t, src, "package a\n\nfunc myprintf(format string, args ...interface{}) {}\n\nfunc f() {\n\tmyprintf(\"%d\", \"x\")\n}\n")
+
+	analyzers := []*analysis.Analyzer{printf.Analyzer}
+	funcs := printf.Analyzer.Flags.Lookup("funcs").Value.String()
+	for _, test := range []struct {
+		settings map[string]map[string]string
+		want     int // diagnostics
+	}{
+		{map[string]map[string]string{"printf": {"funcs": "myprintf"}}, 1},
+		{nil, 0},
+	} {
+		cfg := &Config{ID: "a", Compiler: "gc", ImportPath: "a", GoFiles: []string{src}, Analyzers: test.settings}
+		results, err := Analyze(token.NewFileSet(), cfg, analyzers, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || len(results[0].Diagnostics) != test.want {
+			t.Errorf("with settings %v: got %+v, want %d diagnostics", test.settings, results, test.want)
+		}
+	}
+	if got := printf.Analyzer.Flags.Lookup("funcs").Value.String(); got != funcs {
+		t.Errorf("after Analyze, -printf.funcs = %q, want %q", got, funcs)
+	}
+
+	// A flag whose state cannot be copied does not support settings.
+	a := &analysis.Analyzer{
+		Name: "funcflag",
+		Doc:  "define a flag with flag.Func",
+		Run:  func(*analysis.Pass) (interface{}, error) { return nil, nil },
+	}
+	a.Flags.Func("f", "a func flag", func(string) error { return nil })
+	cfg := &Config{Analyzers: map[string]map[string]string{"funcflag": {"f": "x"}}}
+	if _, err := applySettings(cfg, []*analysis.Analyzer{a}); err == nil || !strings.Contains(err.Error(), "does not support settings") {
+		t.Errorf("applySettings of a func flag: got error %v", err)
+	}
+}
+
+// TestConcurrentSettings checks that concurrent calls of Analyze with
+// different settings of the same analyzer do not see each other's.
+func TestConcurrentSettings(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	writeFile(t, src, "package a\n\nfunc f() {\n\tprintln(\"hi\")\n\tprint(\"hi\")\n}\n")
+
+	analyzers := []*analysis.Analyzer{findcall.Analyzer}
+	defer findcall.Analyzer.Flags.Set("name", findcall.Analyzer.Flags.Lookup("name").Value.String())
+	findcall.Analyzer.Flags.Set("name", "print")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		// Without settings, the current flag applies.
+		name := "print"
+		cfg := &Config{ID: "a", Compiler: "gc", ImportPath: "a", GoFiles: []string{src}}
+		if i%2 == 0 {
+			name = "println"
+			cfg.Analyzers = map[string]map[string]string{"findcall": {"name": name}}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := Analyze(token.NewFileSet(), cfg, analyzers, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			want := "call of " + name + "(...)"
+			if len(results) != 1 || len(results[0].Diagnostics) != 1 || results[0].Diagnostics[0].Message != want {
+				t.Errorf("with -findcall.name=%s: got %+v, want one diagnostic %q", name, results, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -14,6 +14,24 @@
 //	-flags          describe flags                    (to the build tool)
 //	foo.cfg         description of compilation unit (from the build tool)
 //
+// Because each unit is analyzed separately, suggested fixes cannot be
+// applied during the build. Instead, the -fixdir=dir flag causes the
+// suggested fixes of each unit to be recorded in a file in dir, and
+// the "applyfixes dir" subcommand applies them once the build is complete:
+//
+//	$ go vet -vettool=$(which vet) -fixdir=/tmp/fixes ./...
+//	$ vet applyfixes /tmp/fixes
+//
+// The -cachedir=dir flag causes the facts and diagnostics of each unit
+// to be cached in dir and replayed, without analysis, for a unit whose
+// inputs are unchanged.
+//
+// The -ndjson flag causes each diagnostic to be printed, as soon as
+// it is found, as a JSON object on its own line of standard output.
+//
+// Build systems that analyze units in-process, rather than by running
+// a vet tool, may call Analyze directly.
+//
 // This package does not depend on go/packages.
 // If you need a standalone tool, use multichecker,
 // which supports this mode but can also load packages
@@ -27,7 +45,6 @@ package unitchecker
 //   printf checker.
 
 import (
-	"encoding/gob"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -43,6 +60,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
@@ -54,6 +72,9 @@ import (
 	"golang.org/x/tools/internal/typeparams"
 )
 
+// streamJSON is the value of the -ndjson flag.
+var streamJSON bool
+
 // A Config describes a compilation unit to be analyzed.
 // It is provided to the tool in a JSON-encoded file
 // whose name ends with ".cfg".
@@ -72,6 +93,51 @@ type Config struct {
 	VetxOnly                  bool
 	VetxOutput                string
 	SucceedOnTypecheckFailure bool
+
+	// Analyzers holds per-analyzer settings, keyed by analyzer name
+	// and then by flag name, for example
+	//
+	//	"Analyzers": {"printf": {"funcs": "Logf,Warnf"}}
+	//
+	// Each value is applied as if by the command-line flag
+	// -printf.funcs=Logf,Warnf, so that a build system can
+	// configure analyzers without passing flags to the tool.
+	// Settings for analyzers not run by the tool are ignored.
+	Analyzers map[string]map[string]string
+
+	// CgoGenerated lists those of GoFiles that were generated by
+	// cgo, in which diagnostics are suppressed. Files with the
+	// standard "Code generated by cmd/cgo" header need not be listed.
+	CgoGenerated []string
+
+	// SourceArchive optionally names a zip archive from which to read
+	// the unit's Go files, so that a remote build need not write
+	// them to the file system. The entry for a file is named by its
+	// path, as it appears in GoFiles, in slash form and without any
+	// leading slash. Files absent from the archive are read from the
+	// file system, as are NonGoFiles, which analyzers read themselves.
+	SourceArchive string
+
+	// TolerateMissingFacts enables a degraded mode for partial
+	// builds, in which a dependency whose facts file in PackageVetx
+	// cannot be read or decoded is treated as if it had no facts,
+	// instead of causing the analysis to fail. The results of the
+	// analyzers that use facts are marked as possibly incomplete,
+	// in Result.MissingFacts and in the -json and -ndjson output.
+	TolerateMissingFacts bool
+
+	// VetxFormat selects the encoding of the facts written to
+	// VetxOutput: "gob", the default, or "json", which tools not
+	// written in Go may read but which fails for fact types with
+	// unexported fields. Facts in PackageVetx may be in either
+	// encoding, which is identified by its header.
+	VetxFormat string
+
+	// ActionID optionally identifies the analysis of this unit in
+	// the build system's cache; it must change whenever any input
+	// to the analysis does, including the tool and its flags. If
+	// it is empty, the -cachedir mode computes its own key.
+	ActionID string
 }
 
 // Main is the main function of a vet-like analysis tool that must be
@@ -99,10 +165,15 @@ Usage of %[1]s:
 	%.16[1]s unit.cfg	# execute analysis specified by config file
 	%.16[1]s help    	# general help, including listing analyzers and flags
 	%.16[1]s help name	# help on specific analyzer and its flags
+	%.16[1]s applyfixes dir	# apply suggested fixes recorded by -fixdir=dir
 `, progname)
 		os.Exit(1)
 	}
 
+	flag.StringVar(&cacheDir, "cachedir", "", "reuse facts and diagnostics of unchanged units cached in `dir`")
+	flag.BoolVar(&streamJSON, "ndjson", false, "emit diagnostics as newline-delimited JSON, as they are found")
+	flag.StringVar(&fixDir, "fixdir", "", "record suggested fixes in `dir` for the applyfixes subcommand")
+
 	analyzers = analysisflags.Parse(analyzers, true)
 
 	args := flag.Args()
@@ -113,6 +184,9 @@ Usage of %[1]s:
 		analysisflags.Help(progname, analyzers, args[1:])
 		os.Exit(0)
 	}
+	if args[0] == "applyfixes" {
+		os.Exit(applyFixesMain(args[1:]))
+	}
 	if len(args) != 1 || !strings.HasSuffix(args[0], ".cfg") {
 		log.Fatalf(`invoking "go tool vet" directly is unsupported; use "go vet"`)
 	}
@@ -123,37 +197,65 @@ Usage of %[1]s:
 // and calls os.Exit with an appropriate error code.
 // It assumes flags have already been set.
 func Run(configFile string, analyzers []*analysis.Analyzer) {
-	cfg, err := readConfig(configFile)
+	cfg, err := ReadConfig(configFile)
 	if err != nil {
 		log.Fatal(err)
 	}
-
 	fset := token.NewFileSet()
-	results, err := run(fset, cfg, analyzers)
+	opts := new(Options)
+	if streamJSON && !cfg.VetxOnly {
+		opts.Report = func(a *analysis.Analyzer, diag analysis.Diagnostic) {
+			analysisflags.PrintJSONLine(fset, cfg.ID, a.Name, diag, nil)
+		}
+	}
+	results, err := runCached(fset, cfg, analyzers, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// In VetxOnly mode, the analysis is run only for facts.
 	if !cfg.VetxOnly {
-		if analysisflags.JSON {
+		for _, res := range results {
+			if len(res.MissingFacts) > 0 {
+				log.Printf("warning: %s: results of %s may be incomplete: no facts for %s",
+					cfg.ID, res.Analyzer.Name, strings.Join(res.MissingFacts, ", "))
+			}
+		}
+		if fixDir != "" {
+			if err := writeFixes(fixDir, fset, cfg, results); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if streamJSON {
+			// The diagnostics have been printed as they were found.
+			for _, res := range results {
+				if res.Err != nil {
+					analysisflags.PrintJSONLine(fset, cfg.ID, res.Analyzer.Name, analysis.Diagnostic{}, res.Err)
+				} else {
+					analysisflags.PrintJSONMissingFacts(cfg.ID, res.Analyzer.Name, res.MissingFacts)
+				}
+			}
+		} else if analysisflags.JSON {
 			// JSON output
 			tree := make(analysisflags.JSONTree)
 			for _, res := range results {
-				tree.Add(fset, cfg.ID, res.a.Name, res.diagnostics, res.err)
+				tree.Add(fset, cfg.ID, res.Analyzer.Name, res.Diagnostics, res.Err)
+				if res.Err == nil {
+					tree.AddMissingFacts(cfg.ID, res.Analyzer.Name, res.MissingFacts)
+				}
 			}
 			tree.Print()
 		} else {
 			// plain text
 			exit := 0
 			for _, res := range results {
-				if res.err != nil {
-					log.Println(res.err)
+				if res.Err != nil {
+					log.Println(res.Err)
 					exit = 1
 				}
 			}
 			for _, res := range results {
-				for _, diag := range res.diagnostics {
+				for _, diag := range res.Diagnostics {
 					analysisflags.PrintPlain(fset, diag)
 					exit = 1
 				}
@@ -165,7 +267,65 @@ func Run(configFile string, analyzers []*analysis.Analyzer) {
 	os.Exit(0)
 }
 
-func readConfig(filename string) (*Config, error) {
+// runCached is like Analyze, but in -cachedir mode it replays the facts
+// and results of a previous analysis with the same inputs, if any,
+// or saves them for the next time.
+func runCached(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer, opts *Options) ([]Result, error) {
+	if cacheDir == "" {
+		return Analyze(fset, cfg, analyzers, opts)
+	}
+	// Sources may come from the archive; the facts files never do.
+	readFile := ioutil.ReadFile
+	if opts != nil && opts.ReadFile != nil {
+		readFile = opts.ReadFile
+	}
+	writeFile := func(filename string, data []byte) error {
+		return ioutil.WriteFile(filename, data, 0666)
+	}
+	if opts != nil && opts.WriteFile != nil {
+		writeFile = opts.WriteFile
+	}
+	readSource := readFile
+	if cfg.SourceArchive != "" {
+		ar, err := openArchive(cfg.SourceArchive)
+		if err != nil {
+			return nil, err
+		}
+		defer ar.Close()
+		readSource = ar.readFile(readFile)
+	}
+	key, err := cacheKey(cfg, analyzers, readSource)
+	if err != nil {
+		return nil, err
+	}
+	if results, ok, err := getCache(key, fset, cfg, analyzers, readSource, writeFile); ok || err != nil {
+		if err == nil && opts != nil && opts.Report != nil {
+			for _, res := range results {
+				for _, diag := range res.Diagnostics {
+					opts.Report(res.Analyzer, diag)
+				}
+			}
+		}
+		return results, err
+	}
+	results, err := Analyze(fset, cfg, analyzers, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		if len(res.MissingFacts) > 0 {
+			return results, nil // don't cache incomplete results
+		}
+	}
+	if err := putCache(key, fset, cfg, results, readFile); err != nil {
+		// A failure to cache is not a failure to analyze.
+		log.Printf("failed to cache analysis of %s: %v", cfg.ID, err)
+	}
+	return results, nil
+}
+
+// ReadConfig reads the JSON-encoded Config in the named file.
+func ReadConfig(filename string) (*Config, error) {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
@@ -183,16 +343,231 @@ func readConfig(filename string) (*Config, error) {
 	return cfg, nil
 }
 
+// settingsMu serializes the calls of Analyze that apply settings with
+// all other calls, since the flags of analyzers are shared by all
+// calls, and typically bound to package-level variables.
+var settingsMu sync.RWMutex
+
+// applySettings sets the flags of analyzers, and of the analyzers they
+// require, according to cfg.Analyzers. It returns a function that
+// restores the previous state of the flags of those analyzers, which
+// it calls itself in case of error.
+//
+// Restoring a flag by setting its previous value does not undo a Set
+// that adds to the value, as for lists, or that has side effects on
+// other flags, as printf's -wrappersfile has on -wrappers. So the
+// memory of every flag of an analyzer with settings is copied first,
+// and copied back to restore it; a setting of a flag whose state
+// cannot be copied, such as one defined by flag.Func, is an error.
+func applySettings(cfg *Config, analyzers []*analysis.Analyzer) (func(), error) {
+	var restores []func()
+	restore := func() {
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+	}
+	if len(cfg.Analyzers) == 0 {
+		return restore, nil
+	}
+	byName := make(map[string]*analysis.Analyzer)
+	var visit func(a *analysis.Analyzer)
+	visit = func(a *analysis.Analyzer) {
+		if byName[a.Name] == nil {
+			byName[a.Name] = a
+			for _, req := range a.Requires {
+				visit(req)
+			}
+		}
+	}
+	for _, a := range analyzers {
+		visit(a)
+	}
+
+	// Sort for a deterministic choice of error.
+	var names []string
+	for name := range cfg.Analyzers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := byName[name]
+		if a == nil {
+			continue // not run by this tool
+		}
+		restorable := make(map[string]bool)
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			if r, ok := snapshot(f.Value); ok {
+				restores = append(restores, r)
+				restorable[f.Name] = true
+			}
+		})
+		settings := cfg.Analyzers[name]
+		var keys []string
+		for key := range settings {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			f := a.Flags.Lookup(key)
+			if f == nil {
+				restore()
+				return nil, fmt.Errorf("invalid setting for analyzer %s: no flag -%s.%s", name, name, key)
+			}
+			if !restorable[key] {
+				restore()
+				return nil, fmt.Errorf("invalid setting for analyzer %s: flag -%s.%s does not support settings", name, name, key)
+			}
+			if err := f.Value.Set(settings[key]); err != nil {
+				restore()
+				return nil, fmt.Errorf("invalid setting for analyzer %s: -%s.%s=%s: %v", name, name, key, settings[key], err)
+			}
+		}
+	}
+	return restore, nil
+}
+
+// snapshot copies the memory of the flag value v, which is a pointer
+// or a map, and returns a function that copies it back. It reports
+// false for other values, which cannot be restored. The copy is
+// shallow, except that the contents of maps are copied too.
+func snapshot(v flag.Value) (restore func(), ok bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		return snapshotMap(rv), true
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, false
+		}
+		elem := rv.Elem()
+		saved := reflect.New(elem.Type()).Elem()
+		saved.Set(elem)
+		var restoreMap func()
+		if elem.Kind() == reflect.Map {
+			restoreMap = snapshotMap(elem)
+		}
+		return func() {
+			elem.Set(saved)
+			if restoreMap != nil {
+				restoreMap()
+			}
+		}, true
+	}
+	return nil, false
+}
+
+// snapshotMap copies the entries of the map m, and returns a function
+// that replaces its entries by the copies.
+func snapshotMap(m reflect.Value) func() {
+	if m.IsNil() {
+		return func() {}
+	}
+	saved := reflect.MakeMapWithSize(m.Type(), m.Len())
+	for iter := m.MapRange(); iter.Next(); {
+		saved.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return func() {
+		for _, k := range m.MapKeys() {
+			m.SetMapIndex(k, reflect.Value{})
+		}
+		for iter := saved.MapRange(); iter.Next(); {
+			m.SetMapIndex(iter.Key(), iter.Value())
+		}
+	}
+}
+
 var importerForCompiler = func(_ *token.FileSet, compiler string, lookup importer.Lookup) types.Importer {
 	// broken legacy implementation (https://golang.org/issue/28995)
 	return importer.For(compiler, lookup)
 }
 
-func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]result, error) {
+// Options provides hooks for a build system that embeds the analysis
+// of units by calling Analyze. The zero value of each field selects
+// the behavior of "go vet".
+type Options struct {
+	// Importer, if non-nil, imports the unit's dependencies in place
+	// of reading the export data in Config.PackageFile.
+	// Its argument is a package path resolved by Config.ImportMap.
+	Importer types.Importer
+
+	// ReadFile, if non-nil, reads the unit's Go files and the facts
+	// files in Config.PackageVetx, and, to cache them, the facts
+	// written to Config.VetxOutput, in place of ioutil.ReadFile.
+	ReadFile func(filename string) ([]byte, error)
+
+	// WriteFile, if non-nil, writes the facts file
+	// Config.VetxOutput in place of ioutil.WriteFile.
+	WriteFile func(filename string, data []byte) error
+
+	// Report, if non-nil, is called for each diagnostic of one of the
+	// analyzers passed to Analyze as soon as it is reported, before
+	// the analysis is complete. Calls are serialized.
+	Report func(a *analysis.Analyzer, diag analysis.Diagnostic)
+}
+
+// Analyze parses and type-checks the unit described by cfg, applies
+// the settings of cfg.Analyzers, runs the analyzers (and those they
+// require) on it in parallel, and writes the facts they export to
+// cfg.VetxOutput, if set. It returns one Result per analyzer, in
+// order, for the caller to report.
+//
+// The settings of cfg.Analyzers are set as the flags of the analyzers
+// for the duration of the call, and then restored. As the flags are
+// shared, a call with settings does not run concurrently with other
+// calls of Analyze.
+//
+// If cfg.VetxOnly is set, analyzers that produce no facts are not run.
+// If cfg.SucceedOnTypecheckFailure is set and the unit is not well
+// typed, Analyze returns no results and no error.
+//
+// Analyze is the core of the unitchecker; it does not print anything
+// or exit, and so may be used by build systems that analyze units
+// in-process. A nil opts is equivalent to a zero Options.
+func Analyze(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer, opts *Options) ([]Result, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	readFile := opts.ReadFile
+	if readFile == nil {
+		readFile = ioutil.ReadFile
+	}
+	writeFile := opts.WriteFile
+	if writeFile == nil {
+		writeFile = func(filename string, data []byte) error {
+			return ioutil.WriteFile(filename, data, 0666)
+		}
+	}
+
+	if cfg.SourceArchive != "" {
+		ar, err := openArchive(cfg.SourceArchive)
+		if err != nil {
+			return nil, err
+		}
+		defer ar.Close()
+		readFile = ar.readFile(readFile)
+	}
+
+	if len(cfg.Analyzers) > 0 {
+		settingsMu.Lock()
+		defer settingsMu.Unlock()
+	} else {
+		settingsMu.RLock()
+		defer settingsMu.RUnlock()
+	}
+	restore, err := applySettings(cfg, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
 	// Load, parse, typecheck.
 	var files []*ast.File
 	for _, name := range cfg.GoFiles {
-		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		src, err := readFile(name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
 		if err != nil {
 			if cfg.SucceedOnTypecheckFailure {
 				// Silently succeed; let the compiler
@@ -203,6 +578,16 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 		}
 		files = append(files, f)
 	}
+	generated := make(cgoFilter)
+	for _, name := range cfg.CgoGenerated {
+		generated[name] = true
+	}
+	for i, f := range files {
+		if isCgoGenerated(f) {
+			generated[cfg.GoFiles[i]] = true
+		}
+	}
+
 	compilerImporter := importerForCompiler(fset, cfg.Compiler, func(path string) (io.ReadCloser, error) {
 		// path is a resolved package path, not an import path.
 		file, ok := cfg.PackageFile[path]
@@ -214,6 +599,9 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 		}
 		return os.Open(file)
 	})
+	if opts.Importer != nil {
+		compilerImporter = opts.Importer
+	}
 	importer := importerFunc(func(importPath string) (*types.Package, error) {
 		path, ok := cfg.ImportMap[importPath] // resolve vendoring, etc
 		if !ok {
@@ -222,8 +610,9 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 		return compilerImporter.Import(path)
 	})
 	tc := &types.Config{
-		Importer: importer,
-		Sizes:    types.SizesFor("gc", build.Default.GOARCH), // assume gccgo ≡ gc?
+		Importer:    importer,
+		Sizes:       types.SizesFor("gc", build.Default.GOARCH), // assume gccgo ≡ gc?
+		FakeImportC: importsC(files),
 	}
 	info := &types.Info{
 		Types:      make(map[ast.Expr]types.TypeAndValue),
@@ -245,7 +634,7 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 		return nil, err
 	}
 
-	// Register fact types with gob.
+	// Register fact types for decoding.
 	// In VetxOnly mode, analyzers are only for their facts,
 	// so we can skip any analysis that neither produces facts
 	// nor depends on any analysis that produces facts.
@@ -266,7 +655,7 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 			var usesFacts bool
 			for _, f := range a.FactTypes {
 				usesFacts = true
-				gob.Register(f)
+				facts.Register(f)
 			}
 			for _, req := range a.Requires {
 				if registerFacts(req) {
@@ -285,20 +674,28 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 		}
 	}
 	analyzers = filtered
+	isRoot := make(map[*analysis.Analyzer]bool)
+	for _, a := range analyzers {
+		isRoot[a] = true
+	}
+	var reportMu sync.Mutex // serializes calls to opts.Report
 
 	// Read facts from imported packages.
 	read := func(path string) ([]byte, error) {
 		if vetx, ok := cfg.PackageVetx[path]; ok {
-			return ioutil.ReadFile(vetx)
+			return readFile(vetx)
 		}
 		return nil, nil // no .vetx file, no facts
 	}
-	facts, err := facts.Decode(pkg, read)
+	facts, missing, err := decodeFacts(pkg, read, cfg.TolerateMissingFacts)
 	if err != nil {
 		return nil, err
 	}
 
-	// In parallel, execute the DAG of analyzers.
+	// In parallel, execute the DAG of analyzers. Each analyzer
+	// runs once its prerequisites are done, but the number running
+	// at once is limited to GOMAXPROCS, as they are CPU-bound.
+	limit := make(chan struct{}, runtime.GOMAXPROCS(0))
 	var exec func(a *analysis.Analyzer) *action
 	var execAll func(analyzers []*analysis.Analyzer)
 	exec = func(a *analysis.Analyzer) *action {
@@ -331,6 +728,18 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 				factFilter[reflect.TypeOf(f)] = true
 			}
 
+			report := func(d analysis.Diagnostic) {
+				if !generated.filter(fset, &d) {
+					return
+				}
+				act.diagnostics = append(act.diagnostics, d)
+				if opts.Report != nil && isRoot[a] {
+					reportMu.Lock()
+					opts.Report(a, d)
+					reportMu.Unlock()
+				}
+			}
+
 			pass := &analysis.Pass{
 				Analyzer:          a,
 				Fset:              fset,
@@ -341,7 +750,7 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 				TypesInfo:         info,
 				TypesSizes:        tc.Sizes,
 				ResultOf:          inputs,
-				Report:            func(d analysis.Diagnostic) { act.diagnostics = append(act.diagnostics, d) },
+				Report:            report,
 				ImportObjectFact:  facts.ImportObjectFact,
 				ExportObjectFact:  facts.ExportObjectFact,
 				AllObjectFacts:    func() []analysis.ObjectFact { return facts.AllObjectFacts(factFilter) },
@@ -350,8 +759,10 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 				AllPackageFacts:   func() []analysis.PackageFact { return facts.AllPackageFacts(factFilter) },
 			}
 
+			limit <- struct{}{}
 			t0 := time.Now()
 			act.result, act.err = a.Run(pass)
+			<-limit
 			if false {
 				log.Printf("analysis %s = %s", pass, time.Since(t0))
 			}
@@ -373,26 +784,62 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 	execAll(analyzers)
 
 	// Return diagnostics and errors from root analyzers.
-	results := make([]result, len(analyzers))
+	results := make([]Result, len(analyzers))
 	for i, a := range analyzers {
 		act := actions[a]
-		results[i].a = a
-		results[i].err = act.err
-		results[i].diagnostics = act.diagnostics
+		results[i].Analyzer = a
+		results[i].Err = act.err
+		results[i].Diagnostics = act.diagnostics
+		if act.usesFacts {
+			results[i].MissingFacts = missing
+		}
 	}
 
-	data := facts.Encode()
-	if err := ioutil.WriteFile(cfg.VetxOutput, data, 0666); err != nil {
-		return nil, fmt.Errorf("failed to write analysis facts: %v", err)
+	if cfg.VetxOutput != "" {
+		var data []byte
+		switch cfg.VetxFormat {
+		case "", "gob":
+			data = facts.Encode()
+		case "json":
+			data, err = facts.EncodeJSON()
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported facts format %q", cfg.VetxFormat)
+		}
+		if err := writeFile(cfg.VetxOutput, data); err != nil {
+			return nil, fmt.Errorf("failed to write analysis facts: %v", err)
+		}
 	}
 
 	return results, nil
 }
 
-type result struct {
-	a           *analysis.Analyzer
-	diagnostics []analysis.Diagnostic
-	err         error
+// A Result is the outcome of the analysis of a unit by one of the
+// analyzers passed to Analyze.
+type Result struct {
+	Analyzer    *analysis.Analyzer
+	Diagnostics []analysis.Diagnostic
+	Err         error
+
+	// MissingFacts lists the dependencies whose facts were
+	// unavailable to the analyzer, or those it requires, under
+	// Config.TolerateMissingFacts. If it is non-empty, the
+	// diagnostics may be incomplete.
+	MissingFacts []string
+}
+
+// decodeFacts decodes the facts of the imports of pkg. If partial is
+// set, it omits the facts that cannot be read or decoded, and returns
+// the paths of their packages.
+func decodeFacts(pkg *types.Package, read func(string) ([]byte, error), partial bool) (*facts.Set, []string, error) {
+	if partial {
+		set, missing := facts.DecodePartial(pkg, read)
+		return set, missing, nil
+	}
+	set, err := facts.Decode(pkg, read)
+	return set, nil, err
 }
 
 type importerFunc func(path string) (*types.Package, error)
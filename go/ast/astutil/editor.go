@@ -0,0 +1,267 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astutil
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// An Editor edits a type-checked file, as the suggested fixes of an
+// analyzer do, with the help of its type information: it builds the
+// expressions that refer to objects and types at a position in the file,
+// qualified with the names of their packages, chooses names that do not
+// conflict with those in scope, and adds the imports that the edits need
+// and deletes those that they make unused.
+//
+// The type information describes the file before the edits: the
+// positions given to an Editor are those of nodes of the original file.
+type Editor struct {
+	fset *token.FileSet
+	file *ast.File
+	pkg  *types.Package
+	info *types.Info
+
+	names   map[string]bool   // names chosen by FreshName and for imports
+	imports map[string]string // local names of the imports to add, by path
+	used    map[string]string // local names of the imports used before the edits, by path
+}
+
+// NewEditor returns an Editor of the file, of the package pkg, whose
+// type information, including Defs, Uses and Implicits, is info.
+func NewEditor(fset *token.FileSet, file *ast.File, pkg *types.Package, info *types.Info) *Editor {
+	e := &Editor{
+		fset:    fset,
+		file:    file,
+		pkg:     pkg,
+		info:    info,
+		names:   make(map[string]bool),
+		imports: make(map[string]string),
+		used:    make(map[string]string),
+	}
+	for _, spec := range file.Imports {
+		if name := e.localName(spec); name != "_" && name != "." && usesName(file, name) {
+			e.used[importPath(spec)] = name
+		}
+	}
+	return e
+}
+
+// Apply edits the file with Apply, then adds the imports that the
+// edits need and deletes those that they made unused, as FixImports
+// does.
+func (e *Editor) Apply(pre, post ApplyFunc) {
+	Apply(e.file, pre, post)
+	e.FixImports()
+}
+
+// FixImports adds to the file the imports needed by the expressions that
+// Qualify and TypeExpr returned, and deletes those that the file used
+// before the edits but uses no more. The pre and post functions of
+// Apply may not modify the imports of the file, which the Editor does
+// once the traversal of Apply is over.
+func (e *Editor) FixImports() {
+	for path, name := range e.used {
+		if !usesName(e.file, name) {
+			spec := importSpec(e.file, path)
+			if spec != nil {
+				DeleteNamedImport(e.fset, e.file, importName(spec), path)
+			}
+			delete(e.used, path)
+		}
+	}
+	for path, name := range e.imports {
+		if name == defaultName(path) {
+			name = ""
+		}
+		AddNamedImport(e.fset, e.file, name, path)
+	}
+	e.imports = make(map[string]string)
+}
+
+// Qualify returns an expression that refers to the object at the
+// position pos of the file: an identifier for an object of the package
+// or of the universe, or for one in a local scope that is visible
+// there, and a selector qualified by the name of its package for an
+// exported package-level object of another package, whose import the
+// Editor adds, under another name if that of the package is taken. It
+// returns an error for objects that no expression refers to at pos,
+// such as fields, methods, unexported objects of other packages, and
+// those that a declaration of the same name shadows.
+func (e *Editor) Qualify(obj types.Object, pos token.Pos) (ast.Expr, error) {
+	if obj.Pkg() == nil || obj.Pkg() == e.pkg {
+		if _, found := e.scopeAt(pos).LookupParent(obj.Name(), pos); found != obj {
+			return nil, fmt.Errorf("%s is not visible at %s", obj.Name(), e.fset.Position(pos))
+		}
+		return ast.NewIdent(obj.Name()), nil
+	}
+	if obj.Parent() != obj.Pkg().Scope() {
+		return nil, fmt.Errorf("%s is not a package-level object", obj.Name())
+	}
+	if !obj.Exported() {
+		return nil, fmt.Errorf("%s.%s is not exported", obj.Pkg().Path(), obj.Name())
+	}
+	name := e.importName(obj.Pkg(), pos)
+	if name == "." {
+		return ast.NewIdent(obj.Name()), nil
+	}
+	return &ast.SelectorExpr{X: ast.NewIdent(name), Sel: ast.NewIdent(obj.Name())}, nil
+}
+
+// TypeExpr returns an expression of the type at the position pos of the
+// file, in which the named types of other packages are qualified, as in
+// Qualify. It returns an error for types that no expression denotes
+// there, such as unexported types of other packages.
+func (e *Editor) TypeExpr(t types.Type, pos token.Pos) (ast.Expr, error) {
+	qualifier := func(p *types.Package) string {
+		if p == e.pkg {
+			return ""
+		}
+		if name := e.importName(p, pos); name != "." {
+			return name
+		}
+		return ""
+	}
+	s := types.TypeString(t, qualifier)
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		return nil, fmt.Errorf("type %s has no expression: %v", s, err)
+	}
+	var unexported string
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && !sel.Sel.IsExported() && unexported == "" {
+			unexported = sel.Sel.Name
+		}
+		return true
+	})
+	if unexported != "" {
+		return nil, fmt.Errorf("type %s refers to the unexported type %s of another package", s, unexported)
+	}
+	return expr, nil
+}
+
+// FreshName returns a name for a new declaration at the position pos of
+// the file, base or base followed by a number, that does not conflict
+// with the names visible there, nor with those used in the scope of
+// pos, which the declaration would shadow, nor with those that the
+// Editor chose before.
+func (e *Editor) FreshName(pos token.Pos, base string) string {
+	scope := e.scopeAt(pos)
+	taken := e.namesIn(scope.Pos(), scope.End())
+	if scope == e.pkg.Scope() || scope.Parent() == e.pkg.Scope() || !scope.Pos().IsValid() {
+		taken = e.namesIn(e.file.Pos(), e.file.End())
+	}
+	return e.fresh(base, func(name string) bool {
+		if taken[name] {
+			return true
+		}
+		_, obj := scope.LookupParent(name, pos)
+		return obj != nil
+	})
+}
+
+// importName returns the local name under which the file imports the
+// package, at the position pos, and adds an import of the package if
+// none is visible.
+func (e *Editor) importName(pkg *types.Package, pos token.Pos) string {
+	scope := e.scopeAt(pos)
+	for _, spec := range e.file.Imports {
+		if importPath(spec) != pkg.Path() {
+			continue
+		}
+		switch name := e.localName(spec); name {
+		case "_":
+		case ".":
+			return name
+		default:
+			_, obj := scope.LookupParent(name, pos)
+			if pn, ok := obj.(*types.PkgName); ok && pn.Imported() == pkg {
+				return name
+			}
+		}
+	}
+	if name, ok := e.imports[pkg.Path()]; ok {
+		return name
+	}
+	// The name of an import is in the scope of the whole file.
+	taken := e.namesIn(e.file.Pos(), e.file.End())
+	name := e.fresh(pkg.Name(), func(name string) bool {
+		return taken[name] || e.pkg.Scope().Lookup(name) != nil || types.Universe.Lookup(name) != nil
+	})
+	e.imports[pkg.Path()] = name
+	return name
+}
+
+// fresh returns base, or base followed by a number, unused by the Editor
+// and for which taken is false, and records it as used.
+func (e *Editor) fresh(base string, taken func(name string) bool) string {
+	name := base
+	for i := 1; e.names[name] || taken(name); i++ {
+		name = base + strconv.Itoa(i)
+	}
+	e.names[name] = true
+	return name
+}
+
+// scopeAt returns the innermost scope of the file at pos.
+func (e *Editor) scopeAt(pos token.Pos) *types.Scope {
+	if scope := e.pkg.Scope().Innermost(pos); scope != nil {
+		return scope
+	}
+	if scope := e.info.Scopes[e.file]; scope != nil {
+		return scope
+	}
+	return e.pkg.Scope()
+}
+
+// namesIn returns the names of the identifiers of the file in the range
+// [start, end).
+func (e *Editor) namesIn(start, end token.Pos) map[string]bool {
+	names := make(map[string]bool)
+	ast.Inspect(e.file, func(n ast.Node) bool {
+		if n == nil || n.End() < start || n.Pos() >= end {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok {
+			names[id.Name] = true
+		}
+		return true
+	})
+	return names
+}
+
+// localName returns the name under which the import is visible in the
+// file: that of the import, or that of the imported package.
+func (e *Editor) localName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	if pn, ok := e.info.Implicits[spec].(*types.PkgName); ok {
+		return pn.Name()
+	}
+	return defaultName(importPath(spec))
+}
+
+// defaultName returns the likely name of the package of the import
+// path: its last element.
+func defaultName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// usesName reports whether the file has a selector qualified by name.
+func usesName(f *ast.File, name string) (used bool) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && isTopName(sel.X, name) {
+			used = true
+		}
+		return !used
+	})
+	return used
+}
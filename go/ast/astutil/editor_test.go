@@ -0,0 +1,119 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astutil_test
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// importerFunc implements types.Importer.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+const editorSrc = `package p
+
+import "example.com/r"
+
+func f() {
+	q := 1
+	_ = q
+	r.G()
+}
+`
+
+func TestEditor(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs := make(map[string]*types.Package)
+	check := func(path, src string) (*ast.File, *types.Package, *types.Info) {
+		f, err := parser.ParseFile(fset, path+".go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{
+			Defs:      make(map[*ast.Ident]types.Object),
+			Uses:      make(map[*ast.Ident]types.Object),
+			Implicits: make(map[ast.Node]types.Object),
+			Scopes:    make(map[ast.Node]*types.Scope),
+		}
+		conf := types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+			if pkg, ok := pkgs[path]; ok {
+				return pkg, nil
+			}
+			return nil, fmt.Errorf("no package %s", path)
+		})}
+		pkg, err := conf.Check(path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs[path] = pkg
+		return f, pkg, info
+	}
+	_, q, _ := check("example.com/q", "package q; type T int; func F() []T { return nil }; func h() {}")
+	check("example.com/r", "package r; func G() {}")
+	f, p, info := check("example.com/p", editorSrc)
+
+	e := astutil.NewEditor(fset, f, p, info)
+	if _, err := e.Qualify(q.Scope().Lookup("h"), f.Pos()); err == nil {
+		t.Errorf("Qualify of an unexported function of another package succeeded")
+	}
+
+	// Replace the call of r.G by a declaration that refers to q, whose
+	// name a local variable shadows.
+	e.Apply(func(c *astutil.Cursor) bool {
+		stmt, ok := c.Node().(*ast.ExprStmt)
+		if !ok {
+			return true
+		}
+		fn, err := e.Qualify(q.Scope().Lookup("F"), stmt.Pos())
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ, err := e.TypeExpr(types.NewSlice(q.Scope().Lookup("T").Type()), stmt.Pos())
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := e.FreshName(stmt.Pos(), "q")
+		// The declaration takes the positions of the statement, so that
+		// the layout of the block is unchanged.
+		c.Replace(&ast.DeclStmt{Decl: &ast.GenDecl{
+			TokPos: stmt.Pos(),
+			Tok:    token.VAR,
+			Specs: []ast.Spec{&ast.ValueSpec{
+				Names:  []*ast.Ident{ast.NewIdent(name)},
+				Type:   typ,
+				Values: []ast.Expr{&ast.CallExpr{Fun: fn, Rparen: stmt.End() - 1}},
+			}},
+		}})
+		return false
+	}, nil)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	want := `package p
+
+import q1 "example.com/q"
+
+func f() {
+	q := 1
+	_ = q
+	var q2 []q1.T = q1.F()
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("edited file:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astutil
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// An ImportGrouping describes the conventions by which the imports of
+// a file are grouped, as goimports groups them: the imports of the
+// standard library come first, then those of other modules, then the
+// local imports, each group forming a paragraph sorted by import path.
+// Its methods add and delete imports as AddNamedImport and
+// DeleteNamedImport do, but they keep the groups of the file, and the
+// comments of its imports, intact.
+type ImportGrouping struct {
+	// LocalPrefix is a comma-separated list of the prefixes of the
+	// import paths of local imports, as in the -local flag of goimports.
+	LocalPrefix string
+}
+
+// The groups of imports, in order.
+const (
+	stdGroup = iota
+	thirdPartyGroup
+	localGroup
+)
+
+// group returns the group of the import path.
+func (g ImportGrouping) group(path string) int {
+	for _, prefix := range strings.Split(g.LocalPrefix, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix == "" {
+			continue
+		}
+		if strings.HasPrefix(path, prefix) || strings.TrimSuffix(prefix, "/") == path {
+			return localGroup
+		}
+	}
+	if isThirdParty(path) {
+		return thirdPartyGroup
+	}
+	return stdGroup
+}
+
+// AddNamedImport adds the import with the given name and path to the
+// file f, if absent, as AddNamedImport does, but in the paragraph of
+// the parenthesized import declaration of the file that holds the
+// imports of its group, at its place in the order of their paths. A
+// blank import goes preferably in a paragraph of blank imports of its
+// group, which the imports of other names avoid. If the file has no
+// paragraph for the group, the import goes at the end of the last
+// paragraph of a preceding group, or at the start of the first one:
+// the syntax tree cannot hold the blank line of a new paragraph, which
+// a formatter that groups imports, such as goimports, adds.
+func (g ImportGrouping) AddNamedImport(fset *token.FileSet, f *ast.File, name, path string) (added bool) {
+	if imports(f, name, path) {
+		return false
+	}
+	var gen *ast.GenDecl // the first parenthesized import declaration
+	for _, decl := range f.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT && d.Lparen.IsValid() && len(d.Specs) > 0 && !declImports(d, "C") {
+			gen = d
+			break
+		}
+	}
+	if gen == nil {
+		return AddNamedImport(fset, f, name, path)
+	}
+
+	// Choose the paragraph, and the index of the import in it.
+	group, blank := g.group(path), name == "_"
+	paragraphs := paragraphsOf(fset, gen)
+	best := -1
+	for j, para := range paragraphs {
+		if g.group(importPath(para[0])) != group {
+			continue
+		}
+		if best < 0 || isBlankParagraph(para) == blank && isBlankParagraph(paragraphs[best]) != blank {
+			best = j
+		}
+	}
+	var i int // the index of the import in the paragraph
+	if best >= 0 {
+		para := paragraphs[best]
+		for i < len(para) && importPath(para[i]) < path {
+			i++
+		}
+	} else {
+		// No paragraph of the group: choose the nearest one.
+		best = 0
+		for j, para := range paragraphs {
+			if g.group(importPath(para[0])) < group {
+				best, i = j, len(para)
+			}
+		}
+	}
+	para := paragraphs[best]
+	start := 0 // index in gen.Specs of the first import of the paragraph
+	for _, p := range paragraphs[:best] {
+		start += len(p)
+	}
+
+	newImport := &ast.ImportSpec{
+		Path: &ast.BasicLit{
+			Kind:  token.STRING,
+			Value: strconv.Quote(path),
+		},
+	}
+	if name != "" {
+		newImport.Name = &ast.Ident{Name: name}
+	}
+	var pos token.Pos
+	if i > 0 {
+		// After the previous import, and its comment.
+		prev := para[i-1]
+		pos = prev.Pos()
+		if prev.Comment != nil {
+			pos = prev.Comment.End()
+		}
+	} else {
+		// Before the first import of the paragraph, and its doc comment.
+		next := para[0]
+		pos = next.Pos()
+		if next.Doc != nil {
+			pos = next.Doc.Pos()
+		}
+	}
+	if newImport.Name != nil {
+		newImport.Name.NamePos = pos
+	}
+	newImport.Path.ValuePos = pos
+	newImport.EndPos = pos
+
+	at := start + i
+	gen.Specs = append(gen.Specs, nil)
+	copy(gen.Specs[at+1:], gen.Specs[at:])
+	gen.Specs[at] = newImport
+	f.Imports = append(f.Imports, newImport)
+	return true
+}
+
+// DeleteNamedImport deletes the import with the given name and path
+// from the file f, if present, as DeleteNamedImport does, along with
+// the doc comments of the deleted imports, which would otherwise
+// describe the imports that follow them.
+func (g ImportGrouping) DeleteNamedImport(fset *token.FileSet, f *ast.File, name, path string) (deleted bool) {
+	docs := make(map[*ast.CommentGroup]bool)
+	for _, imp := range f.Imports {
+		if imp.Doc != nil && importName(imp) == name && importPath(imp) == path {
+			docs[imp.Doc] = true
+		}
+	}
+	if !DeleteNamedImport(fset, f, name, path) {
+		return false
+	}
+	comments := f.Comments[:0]
+	for _, cg := range f.Comments {
+		if !docs[cg] {
+			comments = append(comments, cg)
+		}
+	}
+	f.Comments = comments
+	return true
+}
+
+// paragraphsOf returns the imports of the declaration, in paragraphs:
+// runs of imports, with their comments, on consecutive lines.
+func paragraphsOf(fset *token.FileSet, gen *ast.GenDecl) [][]*ast.ImportSpec {
+	var paragraphs [][]*ast.ImportSpec
+	lastLine := 0
+	for _, spec := range gen.Specs {
+		imp := spec.(*ast.ImportSpec)
+		start, end := imp.Pos(), imp.End()
+		if imp.Doc != nil {
+			start = imp.Doc.Pos()
+		}
+		if imp.Comment != nil {
+			end = imp.Comment.End()
+		}
+		if line := fset.Position(start).Line; len(paragraphs) == 0 || line > lastLine+1 {
+			paragraphs = append(paragraphs, nil)
+		}
+		paragraphs[len(paragraphs)-1] = append(paragraphs[len(paragraphs)-1], imp)
+		lastLine = fset.Position(end).Line
+	}
+	return paragraphs
+}
+
+// isBlankParagraph reports whether the imports of the paragraph are
+// all blank imports.
+func isBlankParagraph(para []*ast.ImportSpec) bool {
+	for _, imp := range para {
+		if importName(imp) != "_" {
+			return false
+		}
+	}
+	return true
+}
@@ -1725,6 +1725,182 @@ import "os"
 	}
 }
 
+var groupingTests = []test{
+	{
+		name: "std group",
+		pkg:  "io",
+		in: `package main
+
+import (
+	"fmt"
+	"os"
+
+	"example.com/a"
+
+	"local/b"
+)
+`,
+		out: `package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"example.com/a"
+
+	"local/b"
+)
+`,
+	},
+	{
+		name: "third-party group",
+		pkg:  "example.com/b",
+		in: `package main
+
+import (
+	"fmt"
+
+	// a does things.
+	"example.com/a"
+	// c does other things.
+	"example.com/c"
+
+	"local/b"
+)
+`,
+		out: `package main
+
+import (
+	"fmt"
+
+	// a does things.
+	"example.com/a"
+	"example.com/b"
+	// c does other things.
+	"example.com/c"
+
+	"local/b"
+)
+`,
+	},
+	{
+		name: "local group",
+		pkg:  "local/a",
+		in: `package main
+
+import (
+	"fmt"
+
+	"example.com/a"
+
+	"local/b" // b
+)
+`,
+		out: `package main
+
+import (
+	"fmt"
+
+	"example.com/a"
+
+	"local/a"
+	"local/b" // b
+)
+`,
+	},
+	{
+		name:       "blank group",
+		renamedPkg: "_",
+		pkg:        "example.com/driver",
+		in: `package main
+
+import (
+	"fmt"
+
+	"example.com/a"
+
+	_ "example.com/sql"
+)
+`,
+		out: `package main
+
+import (
+	"fmt"
+
+	"example.com/a"
+
+	_ "example.com/driver"
+	_ "example.com/sql"
+)
+`,
+	},
+	{
+		name: "no group",
+		pkg:  "example.com/a",
+		in: `package main
+
+import (
+	"fmt"
+
+	"local/b"
+)
+`,
+		out: `package main
+
+import (
+	"example.com/a"
+	"fmt"
+
+	"local/b"
+)
+`,
+	},
+}
+
+func TestImportGroupingAdd(t *testing.T) {
+	g := ImportGrouping{LocalPrefix: "local/"}
+	for _, test := range groupingTests {
+		file := parse(t, test.name, test.in)
+		if !g.AddNamedImport(fset, file, test.renamedPkg, test.pkg) {
+			t.Errorf("%s: not added", test.name)
+		}
+		if got := print(t, test.name, file); got != test.out {
+			t.Errorf("%s:\ngot: %s\nwant: %s", test.name, got, test.out)
+		}
+		if g.AddNamedImport(fset, file, test.renamedPkg, test.pkg) {
+			t.Errorf("%s: added twice", test.name)
+		}
+	}
+}
+
+func TestImportGroupingDelete(t *testing.T) {
+	file := parse(t, "delete", `package main
+
+import (
+	"fmt"
+
+	// a does things.
+	"example.com/a"
+	"example.com/b"
+)
+`)
+	if !(ImportGrouping{}).DeleteNamedImport(fset, file, "", "example.com/a") {
+		t.Fatal("not deleted")
+	}
+	want := `package main
+
+import (
+	"fmt"
+
+	"example.com/b"
+)
+`
+	if got := print(t, "delete", file); got != want {
+		t.Errorf("got: %s\nwant: %s", got, want)
+	}
+}
+
 type rewriteTest struct {
 	name   string
 	srcPkg string
@@ -0,0 +1,160 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astutil
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"sort"
+)
+
+// A Splicer edits the source of a file by splicing into it the text of
+// the nodes that replace nodes of its syntax tree, rather than by
+// printing the whole tree anew, as go/printer does, which reformats the
+// file: the source outside the edited nodes, including its comments,
+// is unchanged byte for byte. Edits that would delete comments, or
+// that overlap, are refused.
+type Splicer struct {
+	fset  *token.FileSet
+	file  *ast.File
+	tok   *token.File
+	src   []byte
+	edits []Edit
+}
+
+// An Edit replaces the source in the range [Pos, End) by NewText.
+type Edit struct {
+	Pos, End token.Pos
+	NewText  []byte
+}
+
+// NewSplicer returns a Splicer that edits the source src of the file f,
+// parsed with comments.
+func NewSplicer(fset *token.FileSet, f *ast.File, src []byte) *Splicer {
+	return &Splicer{fset: fset, file: f, tok: fset.File(f.Pos()), src: src}
+}
+
+// Replace replaces the source of the node old by that of the node new,
+// which is printed by go/printer, and indented as the line of old is.
+func (s *Splicer) Replace(old, new ast.Node) error {
+	text, err := s.format(new, s.indent(old.Pos()))
+	if err != nil {
+		return err
+	}
+	return s.add(old.Pos(), old.End(), text)
+}
+
+// Delete deletes the source of the node, and the line that holds it if
+// nothing else does.
+func (s *Splicer) Delete(n ast.Node) error {
+	start, end := s.offset(n.Pos()), s.offset(n.End())
+	lineStart, lineEnd := start, end
+	for lineStart > 0 && isSpace(s.src[lineStart-1]) {
+		lineStart--
+	}
+	for lineEnd < len(s.src) && isSpace(s.src[lineEnd]) {
+		lineEnd++
+	}
+	if (lineStart == 0 || s.src[lineStart-1] == '\n') && (lineEnd == len(s.src) || s.src[lineEnd] == '\n') {
+		if lineEnd < len(s.src) {
+			lineEnd++
+		}
+		start, end = lineStart, lineEnd
+	}
+	return s.add(s.tok.Pos(start), s.tok.Pos(end), nil)
+}
+
+// InsertBefore inserts the source of the node new, which is printed by
+// go/printer, on a line of its own before the line of the node n,
+// indented as it is.
+func (s *Splicer) InsertBefore(n, new ast.Node) error {
+	indent := s.indent(n.Pos())
+	text, err := s.format(new, indent)
+	if err != nil {
+		return err
+	}
+	lineStart := s.tok.Pos(s.lineStart(s.offset(n.Pos())))
+	text = append(append(bytes.Repeat([]byte("\t"), indent), text...), '\n')
+	return s.add(lineStart, lineStart, text)
+}
+
+// Edits returns the edits of the Splicer, in order.
+func (s *Splicer) Edits() []Edit {
+	return s.edits
+}
+
+// Bytes returns the source of the file with the edits applied.
+func (s *Splicer) Bytes() []byte {
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range s.edits {
+		start := s.offset(e.Pos)
+		buf.Write(s.src[last:start])
+		buf.Write(e.NewText)
+		last = s.offset(e.End)
+	}
+	buf.Write(s.src[last:])
+	return buf.Bytes()
+}
+
+// add records the edit of the range, unless it deletes comments or
+// overlaps another edit.
+func (s *Splicer) add(pos, end token.Pos, text []byte) error {
+	for _, cg := range s.file.Comments {
+		if pos <= cg.Pos() && cg.End() <= end && pos < end {
+			return fmt.Errorf("edit at %s would delete the comment at %s", s.fset.Position(pos), s.fset.Position(cg.Pos()))
+		}
+	}
+	i := sort.Search(len(s.edits), func(i int) bool { return s.edits[i].Pos > pos })
+	if i > 0 && s.edits[i-1].End > pos || i < len(s.edits) && s.edits[i].Pos < end {
+		return fmt.Errorf("edit at %s overlaps another", s.fset.Position(pos))
+	}
+	s.edits = append(s.edits, Edit{})
+	copy(s.edits[i+1:], s.edits[i:])
+	s.edits[i] = Edit{Pos: pos, End: end, NewText: text}
+	return nil
+}
+
+// format returns the source of the node, printed by go/printer, with
+// its lines after the first indented by the given number of tabs.
+func (s *Splicer) format(n ast.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8, Indent: indent}
+	if err := cfg.Fprint(&buf, s.fset, n); err != nil {
+		return nil, err
+	}
+	// The printer indents the first line too.
+	return bytes.TrimPrefix(buf.Bytes(), bytes.Repeat([]byte("\t"), indent)), nil
+}
+
+// indent returns the number of tabs that indent the line of pos.
+func (s *Splicer) indent(pos token.Pos) int {
+	off := s.offset(pos)
+	start := s.lineStart(off)
+	n := 0
+	for start+n < off && s.src[start+n] == '\t' {
+		n++
+	}
+	return n
+}
+
+// lineStart returns the offset of the start of the line of the offset.
+func (s *Splicer) lineStart(off int) int {
+	for off > 0 && s.src[off-1] != '\n' {
+		off--
+	}
+	return off
+}
+
+func (s *Splicer) offset(pos token.Pos) int {
+	return s.tok.Offset(pos)
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t'
+}
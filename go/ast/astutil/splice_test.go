@@ -0,0 +1,82 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astutil_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// The odd formatting is preserved by the edits.
+const spliceSrc = `package p
+
+// f does things.
+func f(x int)   int {
+	y := x   +  1 // y is x+1
+	println( "unused" )
+	if y > 0 {
+		return g(y) /* a call */
+	}
+	return  0
+}
+`
+
+func TestSplicer(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", spliceSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	ifStmt := body.List[2].(*ast.IfStmt)
+	ret := ifStmt.Body.List[0].(*ast.ReturnStmt)
+	call := ret.Results[0].(*ast.CallExpr)
+
+	s := astutil.NewSplicer(fset, f, []byte(spliceSrc))
+	if err := s.Delete(body.List[1]); err != nil {
+		t.Fatal(err)
+	}
+	// The call, with its argument, becomes h(y, 2).
+	if err := s.Replace(call, &ast.CallExpr{
+		Fun:  ast.NewIdent("h"),
+		Args: []ast.Expr{call.Args[0], &ast.BasicLit{Kind: token.INT, Value: "2"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertBefore(ret, &ast.ExprStmt{X: &ast.CallExpr{Fun: ast.NewIdent("trace")}}); err != nil {
+		t.Fatal(err)
+	}
+	want := `package p
+
+// f does things.
+func f(x int)   int {
+	y := x   +  1 // y is x+1
+	if y > 0 {
+		trace()
+		return h(y, 2) /* a call */
+	}
+	return  0
+}
+`
+	if got := string(s.Bytes()); got != want {
+		t.Errorf("edited source:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Edits that delete comments, or overlap others, are refused.
+	if err := s.Delete(ifStmt); err == nil || !strings.Contains(err.Error(), "comment") {
+		t.Errorf("deletion of a statement with a comment: got error %v", err)
+	}
+	if err := s.Replace(ret, ast.NewIdent("x")); err == nil {
+		t.Errorf("overlapping edit succeeded")
+	}
+	if n := len(s.Edits()); n != 3 {
+		t.Errorf("got %d edits, want 3", n)
+	}
+}
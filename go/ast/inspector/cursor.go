@@ -0,0 +1,158 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package inspector
+
+import (
+	"go/ast"
+)
+
+// A Cursor represents a node of the syntax trees of an Inspector, from
+// which a client may move to the neighboring nodes—its parent, its
+// children and its siblings—without traversing the trees anew, so as
+// to explore the surroundings of a node found by a traversal.
+//
+// A Cursor is a small value, which may be saved to resume a traversal
+// later from its node, with Next. The zero Cursor is not valid; the
+// Root of an Inspector is a virtual node whose children are the files.
+type Cursor struct {
+	in    *Inspector
+	index int // index of the push event of the node, or -1 for the root
+}
+
+// Root returns the cursor of the virtual root node, whose children are
+// the files of the Inspector.
+func (in *Inspector) Root() Cursor {
+	return Cursor{in, -1}
+}
+
+// Node returns the node of the cursor, or nil for the root.
+func (c Cursor) Node() ast.Node {
+	if c.index < 0 {
+		return nil
+	}
+	c.in.bindSpan(c.index, c.index+1)
+	return c.in.events[c.index].node
+}
+
+// Inspector returns the Inspector of the cursor.
+func (c Cursor) Inspector() *Inspector {
+	return c.in
+}
+
+// Parent returns the cursor of the parent of the node, which is the
+// root for a file. It returns false for the root.
+func (c Cursor) Parent() (Cursor, bool) {
+	if c.index < 0 {
+		return Cursor{}, false
+	}
+	return Cursor{c.in, c.in.events[c.index].parent}, true
+}
+
+// FirstChild returns the cursor of the first child of the node, in the
+// order of ast.Inspect, and reports whether the node has children.
+func (c Cursor) FirstChild() (Cursor, bool) {
+	events := c.in.events
+	i := c.index + 1 // the event after the push of the node
+	if i < len(events) && events[i].index > 0 && events[i].parent == c.index {
+		return Cursor{c.in, i}, true
+	}
+	return Cursor{}, false
+}
+
+// NextSibling returns the cursor of the node that follows that of c
+// among the children of their parent, and reports whether there is
+// one.
+func (c Cursor) NextSibling() (Cursor, bool) {
+	if c.index < 0 {
+		return Cursor{}, false
+	}
+	events := c.in.events
+	i := events[c.index].index // the event after the pop of the node
+	if i < len(events) && events[i].index > 0 {
+		return Cursor{c.in, i}, true
+	}
+	return Cursor{}, false
+}
+
+// Children returns the cursors of the children of the node, in the
+// order of ast.Inspect.
+func (c Cursor) Children() []Cursor {
+	var children []Cursor
+	for child, ok := c.FirstChild(); ok; child, ok = child.NextSibling() {
+		children = append(children, child)
+	}
+	return children
+}
+
+// Inspect visits the node of the cursor, unless it is the root, and the
+// nodes of its subtree in depth-first order, as Inspector.Nodes does,
+// calling f before it visits the children of each node: if f returns
+// false, the children of the node are not visited.
+//
+// The types argument, if non-empty, enables type-based filtering of
+// events: f is called only for nodes whose type matches an element of
+// the types slice, but the children of the others are visited.
+func (c Cursor) Inspect(types []ast.Node, f func(c Cursor) (descend bool)) {
+	mask := maskOf(types)
+	events := c.in.events
+	i, end := c.span()
+	c.in.bindSpan(i, end)
+	for i < end {
+		ev := events[i]
+		if ev.index > 0 {
+			// push
+			if ev.typ&mask != 0 && !f(Cursor{c.in, i}) {
+				i = ev.index // jump to corresponding pop + 1
+				continue
+			}
+		}
+		i++
+	}
+}
+
+// Next returns the cursor of the node that follows that of c in the
+// depth-first order of all the files of the Inspector, whose type
+// matches an element of the types slice, if non-empty, and reports
+// whether there is one. The nodes of the subtree of c come first.
+//
+// A traversal that stops at a node may thus resume from its cursor:
+//
+//	for c, ok := in.Root().Next(types); ok; c, ok = c.Next(types) {
+//		...
+//	}
+func (c Cursor) Next(types []ast.Node) (Cursor, bool) {
+	mask := maskOf(types)
+	events := c.in.events
+	for i := c.index + 1; i < len(events); i++ {
+		if ev := events[i]; ev.index > 0 && ev.typ&mask != 0 {
+			return Cursor{c.in, i}, true
+		}
+	}
+	return Cursor{}, false
+}
+
+// FindNode returns the cursor of the node n in the subtree of c, which
+// includes the node of c, and reports whether it was found.
+func (c Cursor) FindNode(n ast.Node) (Cursor, bool) {
+	mask := maskOf([]ast.Node{n})
+	events := c.in.events
+	start, end := c.span()
+	c.in.bindSpan(start, end)
+	for i := start; i < end; i++ {
+		if ev := events[i]; ev.index > 0 && ev.typ&mask != 0 && ev.node == n {
+			return Cursor{c.in, i}, true
+		}
+	}
+	return Cursor{}, false
+}
+
+// span returns the range of the events of the subtree of c, from the
+// push of its node to the event after its pop.
+func (c Cursor) span() (start, end int) {
+	if c.index < 0 {
+		return 0, len(c.in.events)
+	}
+	return c.index, c.in.events[c.index].index
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package inspector_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// TestCursorNavigation compares the parents and children of cursors
+// with the traversal stacks of WithStack.
+func TestCursorNavigation(t *testing.T) {
+	inspect := inspector.New(netFiles)
+
+	children := make(map[ast.Node][]ast.Node) // nil key: the root
+	parents := make(map[ast.Node]ast.Node)
+	inspect.WithStack(nil, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if push {
+			var parent ast.Node
+			if len(stack) > 1 {
+				parent = stack[len(stack)-2]
+			}
+			parents[n] = parent
+			children[parent] = append(children[parent], n)
+		}
+		return true
+	})
+
+	count := 0
+	inspect.Root().Inspect(nil, func(c inspector.Cursor) bool {
+		count++
+		n := c.Node()
+		parent, ok := c.Parent()
+		if !ok || parent.Node() != parents[n] {
+			t.Fatalf("parent of %T is %v, want %T", n, parent.Node(), parents[n])
+		}
+		var got []ast.Node
+		for _, child := range c.Children() {
+			got = append(got, child.Node())
+		}
+		compare(t, got, children[n])
+		return true
+	})
+	if count != len(parents) {
+		t.Errorf("Inspect visited %d nodes, want %d", count, len(parents))
+	}
+	var files []ast.Node
+	for _, c := range inspect.Root().Children() {
+		files = append(files, c.Node())
+	}
+	compare(t, files, children[nil])
+	if _, ok := inspect.Root().Parent(); ok {
+		t.Errorf("the root has a parent")
+	}
+}
+
+// TestCursorNext checks that a traversal resumed from each node with
+// Next visits the nodes of Preorder.
+func TestCursorNext(t *testing.T) {
+	inspect := inspector.New(netFiles)
+	types := []ast.Node{(*ast.CallExpr)(nil)}
+
+	var nodesA []ast.Node
+	inspect.Preorder(types, func(n ast.Node) {
+		nodesA = append(nodesA, n)
+	})
+	var nodesB []ast.Node
+	for c, ok := inspect.Root().Next(types); ok; c, ok = c.Next(types) {
+		nodesB = append(nodesB, c.Node())
+	}
+	compare(t, nodesA, nodesB)
+}
+
+func TestCursorFindNode(t *testing.T) {
+	const src = `package p
+
+func f() {
+	g(1)
+	h(2, 3)
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inspect := inspector.New([]*ast.File{f})
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	call := body.List[1].(*ast.ExprStmt).X.(*ast.CallExpr) // h(2, 3)
+
+	c, ok := inspect.Root().FindNode(call)
+	if !ok || c.Node() != call {
+		t.Fatalf("FindNode(h(2, 3)) = %v, %t", c.Node(), ok)
+	}
+	// The enclosing statement and its siblings.
+	stmt, _ := c.Parent()
+	if _, ok := stmt.NextSibling(); ok {
+		t.Errorf("the last statement has a next sibling")
+	}
+	block, _ := stmt.Parent()
+	if block.Node() != body {
+		t.Errorf("the parent of the statement is %T, want the body", block.Node())
+	}
+	first, ok := block.FirstChild()
+	if !ok || first.Node() != body.List[0] {
+		t.Errorf("the first child of the body is %v, want the first statement", first.Node())
+	}
+	// The arguments of the call.
+	var args []ast.Node
+	c.Inspect([]ast.Node{(*ast.BasicLit)(nil)}, func(c inspector.Cursor) bool {
+		args = append(args, c.Node())
+		return true
+	})
+	if len(args) != 2 {
+		t.Errorf("got %d arguments, want 2", len(args))
+	}
+	// Nodes outside the subtree are not found.
+	if _, ok := c.FindNode(body); ok {
+		t.Errorf("FindNode found an ancestor")
+	}
+}
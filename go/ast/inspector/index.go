@@ -0,0 +1,309 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package inspector
+
+// This file implements the index of an Inspector: the serialized form
+// of its events, from which NewFromIndex builds an Inspector without
+// traversing the syntax trees.
+//
+// The index of a file records, for each event, in order, 0 for a pop
+// and 1 + log2 of the type of the node for a push. An Inspector built
+// from an index thus knows the structure of the trees and the types of
+// their nodes, and binds the events of a file to its nodes only when a
+// traversal first needs them, walking the file then; traversals skip
+// the files that have no node of the types they look for.
+//
+// The index of an Inspector is a header, the number of its files, and
+// the number of events of each file followed by the events, as
+// unsigned varints. An entry of an IndexCache is the header followed by
+// the number of events of its file and the events.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/ast"
+	"io"
+	"io/ioutil"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// indexHeader begins every index, and changes with its format.
+const indexHeader = "go/ast/inspector index v1\n"
+
+// WriteIndex writes the index of the Inspector, which NewFromIndex reads
+// back, to w.
+func (in *Inspector) WriteIndex(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(indexHeader)
+	var buf [binary.MaxVarintLen64]byte
+	bw.Write(buf[:binary.PutUvarint(buf[:], uint64(len(in.files)))])
+	for _, fe := range in.files {
+		in.writeFileIndex(bw, fe)
+	}
+	return bw.Flush()
+}
+
+// writeFileIndex writes the index of the events of a file.
+func (in *Inspector) writeFileIndex(w *bufio.Writer, fe *fileEvents) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], uint64(fe.end-fe.start))])
+	for _, ev := range in.events[fe.start:fe.end] {
+		if ev.index > 0 {
+			w.WriteByte(byte(1 + bits.TrailingZeros64(ev.typ)))
+		} else {
+			w.WriteByte(0)
+		}
+	}
+}
+
+// NewFromIndex returns an Inspector for the specified syntax trees
+// from their index, written by WriteIndex, without traversing them:
+// the events of each file are bound to its nodes when a traversal first
+// needs them. The index is not retained, so it may be memory-mapped,
+// for instance.
+//
+// The trees must be those of the Inspector whose index it is, or trees
+// parsed from the same sources in the same mode. NewFromIndex returns
+// an error if the index is malformed or of a different number of
+// files, and the traversals of the Inspector panic if a tree does not
+// match its index.
+func NewFromIndex(files []*ast.File, index []byte) (*Inspector, error) {
+	d := &indexDecoder{data: index}
+	if !d.header() {
+		return nil, errors.New("inspector: not an index")
+	}
+	if n, ok := d.uvarint(); !ok || n != uint64(len(files)) {
+		return nil, fmt.Errorf("inspector: index of %d files, want %d", n, len(files))
+	}
+	// The index has about one byte per event.
+	in := &Inspector{events: make([]event, 0, len(d.data))}
+	for _, f := range files {
+		if err := in.readFileIndex(d, f); err != nil {
+			return nil, err
+		}
+	}
+	if len(d.data) > 0 {
+		return nil, errors.New("inspector: malformed index")
+	}
+	return in, nil
+}
+
+// readFileIndex appends the events of the index of a file, whose nodes
+// are bound later.
+func (in *Inspector) readFileIndex(d *indexDecoder, f *ast.File) error {
+	n, ok := d.uvarint()
+	if !ok || n > uint64(len(d.data)) || n == 0 {
+		return errors.New("inspector: malformed index")
+	}
+	fe := &fileEvents{file: f, start: len(in.events), end: len(in.events) + int(n), bind: new(sync.Once)}
+	if cap(in.events) < fe.end {
+		events := make([]event, len(in.events), fe.end+cap(in.events))
+		copy(events, in.events)
+		in.events = events
+	}
+	stack := make([]int, 0, 64) // the push events of the enclosing nodes
+	for _, b := range d.data[:n] {
+		if b > 0 {
+			// push
+			if b > 64 {
+				return errors.New("inspector: malformed index")
+			}
+			parent := -1
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			stack = append(stack, len(in.events))
+			ev := event{typ: 1 << (b - 1), index: len(in.events), parent: parent}
+			in.events = append(in.events, ev)
+			fe.mask |= ev.typ
+		} else {
+			// pop
+			if len(stack) == 0 {
+				return errors.New("inspector: malformed index")
+			}
+			push := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			in.events[push].index = len(in.events) + 1
+			in.events = append(in.events, event{typ: in.events[push].typ, parent: in.events[push].parent})
+		}
+	}
+	if len(stack) > 0 || in.events[fe.start].index != fe.end {
+		return errors.New("inspector: malformed index")
+	}
+	d.data = d.data[n:]
+	in.files = append(in.files, fe)
+	in.unbound++
+	return nil
+}
+
+// An indexDecoder reads an index.
+type indexDecoder struct {
+	data []byte // the rest of the index
+}
+
+func (d *indexDecoder) header() bool {
+	if len(d.data) < len(indexHeader) || string(d.data[:len(indexHeader)]) != indexHeader {
+		return false
+	}
+	d.data = d.data[len(indexHeader):]
+	return true
+}
+
+func (d *indexDecoder) uvarint() (uint64, bool) {
+	x, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, false
+	}
+	d.data = d.data[n:]
+	return x, true
+}
+
+// bind binds the events of the file to its nodes, if they were read
+// from an index and are not bound yet.
+func (in *Inspector) bind(fe *fileEvents) {
+	if fe.bind == nil {
+		return
+	}
+	fe.bind.Do(func() {
+		i := fe.start
+		var stack []ast.Node
+		ast.Inspect(fe.file, func(n ast.Node) bool {
+			if i >= fe.end {
+				i++ // more nodes than events
+				return false
+			}
+			ev := &in.events[i]
+			if n != nil {
+				if ev.index == 0 || typeOf(n) != ev.typ {
+					i = fe.end + 1
+					return false
+				}
+				ev.node = n
+				stack = append(stack, n)
+			} else {
+				if ev.index > 0 {
+					i = fe.end + 1
+					return false
+				}
+				ev.node = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+			i++
+			return true
+		})
+		if i != fe.end {
+			panic(fmt.Sprintf("inspector: syntax tree of file %s does not match its index", fe.file.Name.Name))
+		}
+		atomic.AddInt32(&in.unbound, -1)
+	})
+}
+
+// bindSpan binds the events of the files that have events in the range
+// [start, end), if needed.
+func (in *Inspector) bindSpan(start, end int) {
+	if atomic.LoadInt32(&in.unbound) == 0 {
+		return
+	}
+	i := sort.Search(len(in.files), func(i int) bool { return in.files[i].end > start })
+	for ; i < len(in.files) && in.files[i].start < end; i++ {
+		in.bind(in.files[i])
+	}
+}
+
+// An IndexCache is a persistent cache of the indexes of files, in a
+// directory, so that a long-lived driver, or repeated runs of a tool,
+// may build the Inspectors of packages without traversing their files.
+// The index of each file is cached by a key that identifies its
+// syntax tree, such as the hash of its contents and of the mode in
+// which it was parsed. An IndexCache may be used concurrently, even by
+// several processes.
+type IndexCache struct {
+	Dir string // the directory of the cache, which is created if needed
+}
+
+// New returns an Inspector for the specified syntax trees, whose keys
+// are those of the trees of the same index in files, from the indexes
+// of the cached files, and records the indexes of the others in the
+// cache. Errors while reading or writing the cache are ignored, as it
+// only saves work.
+func (c *IndexCache) New(files []*ast.File, keys []string) *Inspector {
+	if len(keys) != len(files) {
+		panic("inspector: IndexCache.New: the numbers of files and keys differ")
+	}
+	in := new(Inspector)
+	var missing []int // the files that are not cached
+	for i, f := range files {
+		data, err := ioutil.ReadFile(c.file(keys[i]))
+		if err == nil {
+			start, nfiles := len(in.events), len(in.files)
+			d := &indexDecoder{data: data}
+			if !d.header() || in.readFileIndex(d, f) != nil || len(d.data) > 0 {
+				in.events, in.files = in.events[:start], in.files[:nfiles]
+				data = nil
+			}
+		}
+		if err != nil || data == nil {
+			// Traverse the file.
+			events, fes := traverse([]*ast.File{f})
+			in.appendEvents(events, fes[0])
+			missing = append(missing, len(in.files)-1)
+		}
+	}
+	for _, i := range missing {
+		fe := in.files[i]
+		name := c.file(keys[i])
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			break
+		}
+		tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+		if err != nil {
+			break
+		}
+		w := bufio.NewWriter(tmp)
+		w.WriteString(indexHeader)
+		in.writeFileIndex(w, fe)
+		err = w.Flush()
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), name)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	return in
+}
+
+// file returns the name of the file of the index for the given key.
+func (c *IndexCache) file(key string) string {
+	return filepath.Join(c.Dir, key+".idx")
+}
+
+// appendEvents appends the events of a file, traversed alone, to those
+// of the Inspector.
+func (in *Inspector) appendEvents(events []event, fe *fileEvents) {
+	offset := len(in.events)
+	for _, ev := range events {
+		if ev.index > 0 {
+			ev.index += offset
+		}
+		if ev.parent >= 0 {
+			ev.parent += offset
+		}
+		in.events = append(in.events, ev)
+	}
+	fe.start += offset
+	fe.end += offset
+	in.files = append(in.files, fe)
+}
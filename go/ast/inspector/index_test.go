@@ -0,0 +1,139 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package inspector_test
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// events returns the events of a traversal of the Inspector with
+// WithStack, as strings.
+func events(in *inspector.Inspector) []string {
+	var events []string
+	in.WithStack(nil, func(n ast.Node, push bool, stack []ast.Node) bool {
+		events = append(events, fmt.Sprintf("%p %t %d", n, push, len(stack)))
+		return true
+	})
+	return events
+}
+
+func TestIndex(t *testing.T) {
+	inspect := inspector.New(netFiles)
+	var buf bytes.Buffer
+	if err := inspect.WriteIndex(&buf); err != nil {
+		t.Fatal(err)
+	}
+	indexed, err := inspector.NewFromIndex(netFiles, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Traversals filtered by type bind only some files.
+	types := []ast.Node{(*ast.SelectStmt)(nil)}
+	var nodesA, nodesB []ast.Node
+	inspect.Preorder(types, func(n ast.Node) { nodesA = append(nodesA, n) })
+	indexed.Preorder(types, func(n ast.Node) { nodesB = append(nodesB, n) })
+	compare(t, nodesA, nodesB)
+
+	a, b := events(inspect), events(indexed)
+	if len(a) != len(b) {
+		t.Fatalf("got %d events, want %d", len(b), len(a))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("event %d is %s, want %s", i, b[i], a[i])
+		}
+	}
+
+	// The cursors of the indexed Inspector navigate alike.
+	for _, c := range indexed.Root().Children()[:3] {
+		var got []ast.Node
+		for _, child := range c.Children() {
+			got = append(got, child.Node())
+		}
+		f := c.Node().(*ast.File)
+		want := []ast.Node{f.Name}
+		for _, decl := range f.Decls {
+			want = append(want, decl)
+		}
+		compare(t, got, want)
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	inspect := inspector.New(netFiles[:2])
+	var buf bytes.Buffer
+	if err := inspect.WriteIndex(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if _, err := inspector.NewFromIndex(netFiles[:1], data); err == nil {
+		t.Errorf("NewFromIndex succeeded with a different number of files")
+	}
+	if _, err := inspector.NewFromIndex(netFiles[:2], data[:len(data)-1]); err == nil {
+		t.Errorf("NewFromIndex succeeded with a truncated index")
+	}
+	if _, err := inspector.NewFromIndex(netFiles[:2], []byte("garbage")); err == nil {
+		t.Errorf("NewFromIndex succeeded with garbage")
+	}
+
+	// An index of other files is detected when the files are bound.
+	indexed, err := inspector.NewFromIndex([]*ast.File{netFiles[1], netFiles[0]}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("traversal of files that differ from their index did not panic")
+		}
+	}()
+	indexed.Preorder(nil, func(ast.Node) {})
+}
+
+func TestIndexCache(t *testing.T) {
+	cache := &inspector.IndexCache{Dir: t.TempDir()}
+	keys := make([]string, len(netFiles))
+	for i := range netFiles {
+		keys[i] = fmt.Sprint("file", i)
+	}
+	want := events(inspector.New(netFiles))
+	for run := 0; run < 2; run++ {
+		got := events(cache.New(netFiles, keys))
+		if len(got) != len(want) {
+			t.Fatalf("run %d: got %d events, want %d", run, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("run %d: event %d is %s, want %s", run, i, got[i], want[i])
+			}
+		}
+		entries, err := ioutil.ReadDir(cache.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(netFiles) {
+			t.Errorf("run %d: got %d cache entries, want %d", run, len(entries), len(netFiles))
+		}
+	}
+}
+
+func BenchmarkNewFromIndex(b *testing.B) {
+	var buf bytes.Buffer
+	if err := inspector.New(netFiles).WriteIndex(&buf); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := inspector.NewFromIndex(netFiles, buf.Bytes()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
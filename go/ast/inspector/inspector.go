@@ -16,6 +16,9 @@
 // benefit to amortize the inspector's construction cost.
 // If efficiency is the primary concern, do not use Inspector for
 // one-off traversals.
+//
+// A Cursor represents a node of the trees, from which a client may
+// navigate to the neighboring nodes, or resume a traversal.
 package inspector
 
 // There are four orthogonal features in a traversal:
@@ -36,25 +39,112 @@ package inspector
 
 import (
 	"go/ast"
+	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
 // An Inspector provides methods for inspecting
 // (traversing) the syntax trees of a package.
 type Inspector struct {
-	events []event
+	events  []event
+	files   []*fileEvents // the events of each file, in order
+	unbound int32         // number of files whose nodes are not yet bound, accessed atomically
+
+	identsOnce sync.Once
+	idents     map[string][]*ast.Ident // the identifiers, by name, built by Idents
 }
 
 // New returns an Inspector for the specified syntax trees.
 func New(files []*ast.File) *Inspector {
-	return &Inspector{traverse(files)}
+	events, fes := traverse(files)
+	return &Inspector{events: events, files: fes}
+}
+
+// Update replaces the syntax tree oldFile, one of those of the
+// Inspector, by newFile, typically parsed anew after an edit of its
+// source: only the events of newFile are computed, and those of the
+// other files are kept, so that a client that edits one file at a time
+// need not build the Inspector of all of them again. It panics if
+// oldFile is not a file of the Inspector.
+//
+// Update must not be called concurrently with other methods of the
+// Inspector, and it invalidates the Cursors obtained before.
+func (in *Inspector) Update(oldFile, newFile *ast.File) {
+	k := -1
+	for i, fe := range in.files {
+		if fe.file == oldFile {
+			k = i
+			break
+		}
+	}
+	if k < 0 {
+		panic("inspector: Update of a file that is not in the Inspector")
+	}
+	old := in.files[k]
+	if old.bind != nil {
+		// Mark the events of the old file as bound, if they are not.
+		unbound := false
+		old.bind.Do(func() { unbound = true })
+		if unbound {
+			atomic.AddInt32(&in.unbound, -1)
+		}
+	}
+
+	events, fes := traverse([]*ast.File{newFile})
+	fe := fes[0]
+	delta := len(events) - (old.end - old.start)
+	tail := in.events[old.end:]
+	result := make([]event, 0, len(in.events)+delta)
+	result = append(result, in.events[:old.start]...)
+	for _, ev := range events {
+		if ev.index > 0 {
+			ev.index += old.start
+		}
+		if ev.parent >= 0 {
+			ev.parent += old.start
+		}
+		result = append(result, ev)
+	}
+	for _, ev := range tail {
+		if ev.index > 0 {
+			ev.index += delta
+		}
+		if ev.parent >= 0 {
+			ev.parent += delta
+		}
+		result = append(result, ev)
+	}
+	in.events = result
+
+	fe.start += old.start
+	fe.end += old.start
+	in.files[k] = fe
+	for _, fe := range in.files[k+1:] {
+		fe.start += delta
+		fe.end += delta
+	}
+
+	// The index of identifiers is built anew when needed.
+	in.identsOnce = sync.Once{}
+	in.idents = nil
+}
+
+// fileEvents describes the events of a file.
+type fileEvents struct {
+	file       *ast.File
+	start, end int        // the range of the events of the file
+	mask       uint64     // the union of the types of its nodes
+	bind       *sync.Once // if non-nil, binds the nodes of events read from an index
 }
 
 // An event represents a push or a pop
 // of an ast.Node during a traversal.
 type event struct {
-	node  ast.Node
-	typ   uint64 // typeOf(node)
-	index int    // 1 + index of corresponding pop event, or 0 if this is a pop
+	node   ast.Node
+	typ    uint64 // typeOf(node)
+	index  int    // 1 + index of corresponding pop event, or 0 if this is a pop
+	parent int    // index of the push event of the parent node, or -1 for a file
 }
 
 // Preorder visits all the nodes of the files supplied to New in
@@ -70,15 +160,71 @@ func (in *Inspector) Preorder(types []ast.Node, f func(ast.Node)) {
 	// features seem to contribute similar slowdowns (~1.4x each).
 
 	mask := maskOf(types)
-	for i := 0; i < len(in.events); {
-		ev := in.events[i]
-		if ev.typ&mask != 0 {
-			if ev.index > 0 {
-				f(ev.node)
+	for _, fe := range in.files {
+		if fe.mask&mask == 0 {
+			continue // no node of the file matches
+		}
+		in.bind(fe)
+		for i := fe.start; i < fe.end; {
+			ev := in.events[i]
+			if ev.typ&mask != 0 {
+				if ev.index > 0 {
+					f(ev.node)
+				}
 			}
+			i++
+		}
+	}
+}
+
+// PreorderParallel visits all the nodes of the files supplied to New,
+// as Preorder does, but it visits those of distinct files concurrently,
+// so as to use several processors for the traversal of a package of
+// many files. The nodes of each file are visited in depth-first order,
+// by a single goroutine, but the order of the files is unspecified.
+// The function f must be safe to call concurrently.
+//
+// PreorderParallel returns once all the nodes are visited.
+func (in *Inspector) PreorderParallel(types []ast.Node, f func(ast.Node)) {
+	mask := maskOf(types)
+	var files []*fileEvents // those with matching nodes
+	for _, fe := range in.files {
+		if fe.mask&mask != 0 {
+			files = append(files, fe)
 		}
-		i++
 	}
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(files) {
+		workers = len(files)
+	}
+	if workers <= 1 {
+		in.Preorder(types, f)
+		return
+	}
+
+	var next int32 = -1 // index in files of the last file taken
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				j := int(atomic.AddInt32(&next, 1))
+				if j >= len(files) {
+					return
+				}
+				fe := files[j]
+				in.bind(fe)
+				for i := fe.start; i < fe.end; i++ {
+					ev := in.events[i]
+					if ev.typ&mask != 0 && ev.index > 0 {
+						f(ev.node)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 // Nodes visits the nodes of the files supplied to New in depth-first
@@ -92,21 +238,27 @@ func (in *Inspector) Preorder(types []ast.Node, f func(ast.Node)) {
 // matches an element of the types slice.
 func (in *Inspector) Nodes(types []ast.Node, f func(n ast.Node, push bool) (proceed bool)) {
 	mask := maskOf(types)
-	for i := 0; i < len(in.events); {
-		ev := in.events[i]
-		if ev.typ&mask != 0 {
-			if ev.index > 0 {
-				// push
-				if !f(ev.node, true) {
-					i = ev.index // jump to corresponding pop + 1
-					continue
+	for _, fe := range in.files {
+		if fe.mask&mask == 0 {
+			continue // no node of the file matches
+		}
+		in.bind(fe)
+		for i := fe.start; i < fe.end; {
+			ev := in.events[i]
+			if ev.typ&mask != 0 {
+				if ev.index > 0 {
+					// push
+					if !f(ev.node, true) {
+						i = ev.index // jump to corresponding pop + 1
+						continue
+					}
+				} else {
+					// pop
+					f(ev.node, false)
 				}
-			} else {
-				// pop
-				f(ev.node, false)
 			}
+			i++
 		}
-		i++
 	}
 }
 
@@ -117,31 +269,64 @@ func (in *Inspector) Nodes(types []ast.Node, f func(n ast.Node, push bool) (proc
 func (in *Inspector) WithStack(types []ast.Node, f func(n ast.Node, push bool, stack []ast.Node) (proceed bool)) {
 	mask := maskOf(types)
 	var stack []ast.Node
-	for i := 0; i < len(in.events); {
-		ev := in.events[i]
-		if ev.index > 0 {
-			// push
-			stack = append(stack, ev.node)
-			if ev.typ&mask != 0 {
-				if !f(ev.node, true, stack) {
-					i = ev.index
-					stack = stack[:len(stack)-1]
-					continue
+	for _, fe := range in.files {
+		if fe.mask&mask == 0 {
+			continue // no node of the file matches
+		}
+		in.bind(fe)
+		for i := fe.start; i < fe.end; {
+			ev := in.events[i]
+			if ev.index > 0 {
+				// push
+				stack = append(stack, ev.node)
+				if ev.typ&mask != 0 {
+					if !f(ev.node, true, stack) {
+						i = ev.index
+						stack = stack[:len(stack)-1]
+						continue
+					}
 				}
+			} else {
+				// pop
+				if ev.typ&mask != 0 {
+					f(ev.node, false, stack)
+				}
+				stack = stack[:len(stack)-1]
 			}
-		} else {
-			// pop
-			if ev.typ&mask != 0 {
-				f(ev.node, false, stack)
-			}
-			stack = stack[:len(stack)-1]
+			i++
 		}
-		i++
 	}
 }
 
-// traverse builds the table of events representing a traversal.
-func traverse(files []*ast.File) []event {
+// Idents returns the identifiers of the given name in the files
+// supplied to New, in depth-first order, so that a client that looks
+// for the uses of particular functions or fields may find them without
+// a traversal. The index of the identifiers by name is built by the
+// first call, which traverses the events once. The result must not be
+// modified.
+func (in *Inspector) Idents(name string) []*ast.Ident {
+	in.identsOnce.Do(func() {
+		in.idents = make(map[string][]*ast.Ident)
+		mask := maskOf([]ast.Node{(*ast.Ident)(nil)})
+		for _, fe := range in.files {
+			if fe.mask&mask == 0 {
+				continue
+			}
+			in.bind(fe)
+			for i := fe.start; i < fe.end; i++ {
+				if ev := in.events[i]; ev.index > 0 && ev.typ&mask != 0 {
+					id := ev.node.(*ast.Ident)
+					in.idents[id.Name] = append(in.idents[id.Name], id)
+				}
+			}
+		}
+	})
+	return in.idents[name]
+}
+
+// traverse builds the table of events representing a traversal, and
+// the description of the events of each file.
+func traverse(files []*ast.File) ([]event, []*fileEvents) {
 	// Preallocate approximate number of events
 	// based on source file extent.
 	// This makes traverse faster by 4x (!).
@@ -156,18 +341,26 @@ func traverse(files []*ast.File) []event {
 	}
 	events := make([]event, 0, capacity)
 
+	fes := make([]*fileEvents, len(files))
 	var stack []event
-	for _, f := range files {
+	for i, f := range files {
+		fe := &fileEvents{file: f, start: len(events)}
 		ast.Inspect(f, func(n ast.Node) bool {
 			if n != nil {
 				// push
+				parent := -1
+				if len(stack) > 0 {
+					parent = stack[len(stack)-1].index
+				}
 				ev := event{
-					node:  n,
-					typ:   typeOf(n),
-					index: len(events), // push event temporarily holds own index
+					node:   n,
+					typ:    typeOf(n),
+					index:  len(events), // push event temporarily holds own index
+					parent: parent,
 				}
 				stack = append(stack, ev)
 				events = append(events, ev)
+				fe.mask |= ev.typ
 			} else {
 				// pop
 				ev := stack[len(stack)-1]
@@ -180,7 +373,9 @@ func traverse(files []*ast.File) []event {
 			}
 			return true
 		})
+		fe.end = len(events)
+		fes[i] = fe
 	}
 
-	return events
+	return events, fes
 }
@@ -5,6 +5,7 @@
 package inspector_test
 
 import (
+	"bytes"
 	"go/ast"
 	"go/build"
 	"go/parser"
@@ -14,6 +15,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"golang.org/x/tools/go/ast/inspector"
@@ -71,6 +73,152 @@ func TestInspectAllNodes(t *testing.T) {
 	compare(t, nodesA, nodesB)
 }
 
+// TestPreorderParallel checks that PreorderParallel visits the nodes of
+// Preorder, each file in order.
+func TestPreorderParallel(t *testing.T) {
+	inspect := inspector.New(netFiles)
+	types := []ast.Node{(*ast.CallExpr)(nil), (*ast.Ident)(nil)}
+
+	var nodesA []ast.Node
+	inspect.Preorder(types, func(n ast.Node) {
+		nodesA = append(nodesA, n)
+	})
+
+	// The nodes of each file, by the position of the file.
+	var mu sync.Mutex
+	byFile := make(map[token.Pos][]ast.Node)
+	inspect.PreorderParallel(types, func(n ast.Node) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, f := range netFiles {
+			if f.Pos() <= n.Pos() && n.Pos() <= f.End() {
+				byFile[f.Pos()] = append(byFile[f.Pos()], n)
+				return
+			}
+		}
+		t.Errorf("node %T at %d is in no file", n, n.Pos())
+	})
+	var nodesB []ast.Node
+	for _, f := range netFiles {
+		nodesB = append(nodesB, byFile[f.Pos()]...)
+	}
+	compare(t, nodesA, nodesB)
+}
+
+func TestIdents(t *testing.T) {
+	inspect := inspector.New(netFiles)
+	for _, name := range []string{"err", "Close", "nosuchname"} {
+		var want []ast.Node
+		inspect.Preorder([]ast.Node{(*ast.Ident)(nil)}, func(n ast.Node) {
+			if n.(*ast.Ident).Name == name {
+				want = append(want, n)
+			}
+		})
+		var got []ast.Node
+		for _, id := range inspect.Idents(name) {
+			got = append(got, id)
+		}
+		compare(t, got, want)
+	}
+}
+
+func TestAll(t *testing.T) {
+	inspect := inspector.New(netFiles)
+
+	var want []ast.Node
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		want = append(want, n)
+	})
+	var got []ast.Node
+	for _, call := range inspector.All[*ast.CallExpr](inspect) {
+		got = append(got, call)
+	}
+	compare(t, got, want)
+
+	// An interface type matches the nodes that implement it.
+	want, got = nil, nil
+	inspect.Preorder(nil, func(n ast.Node) {
+		if _, ok := n.(ast.Stmt); ok {
+			want = append(want, n)
+		}
+	})
+	inspector.PreorderAs(inspect, func(stmt ast.Stmt) {
+		got = append(got, stmt)
+	})
+	compare(t, got, want)
+
+	// The funcs of WithStackAs are called for the nodes of the type only.
+	depth := 0
+	inspector.WithStackAs(inspect, func(f *ast.FuncDecl, push bool, stack []ast.Node) bool {
+		if push {
+			depth++
+		} else {
+			depth--
+		}
+		if stack[len(stack)-1] != f || depth < 0 || depth > 1 {
+			t.Fatalf("WithStackAs: bad call for %s", f.Name.Name)
+		}
+		return true
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	added, err := parser.ParseFile(token.NewFileSet(), "added.go", "package net; func added() { x := 1; _ = x }", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := inspector.New(netFiles).WriteIndex(&buf); err != nil {
+		t.Fatal(err)
+	}
+	indexed, err := inspector.NewFromIndex(netFiles, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// check checks that the Inspector is that of the files.
+	check := func(inspect *inspector.Inspector, files []*ast.File) {
+		t.Helper()
+		want, got := events(inspector.New(files)), events(inspect)
+		if len(got) != len(want) {
+			t.Fatalf("got %d events, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("event %d is %s, want %s", i, got[i], want[i])
+			}
+		}
+		var children, wantFiles []ast.Node
+		for _, c := range inspect.Root().Children() {
+			children = append(children, c.Node())
+		}
+		for _, f := range files {
+			wantFiles = append(wantFiles, f)
+		}
+		compare(t, children, wantFiles)
+	}
+
+	for _, inspect := range []*inspector.Inspector{inspector.New(netFiles), indexed} {
+		if len(inspect.Idents("added")) != 0 {
+			t.Fatal("the files already declare added")
+		}
+		files := append([]*ast.File(nil), netFiles...)
+		inspect.Update(files[1], added)
+		files[1] = added
+		check(inspect, files)
+		if len(inspect.Idents("added")) != 1 {
+			t.Errorf("Idents does not find the identifier of the new file")
+		}
+
+		inspect.Update(added, netFiles[1])
+		files[1] = netFiles[1]
+		last := len(files) - 1
+		inspect.Update(files[last], added)
+		files[last] = added
+		check(inspect, files)
+	}
+}
+
 func TestInspectGenericNodes(t *testing.T) {
 	if !typeparams.Enabled {
 		t.Skip("type parameters are not supported at this Go version")
@@ -0,0 +1,64 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package inspector
+
+// This file defines the traversals of the nodes of a type given by a
+// type parameter, which spare their clients the type switches and
+// assertions of the traversals of the nodes of the types of a slice.
+
+import (
+	"go/ast"
+)
+
+// typesOf returns the types argument of a traversal of the nodes of
+// type N: nil, matching all nodes, if N is an interface type such as
+// ast.Expr.
+func typesOf[N ast.Node]() []ast.Node {
+	var zero N
+	if ast.Node(zero) == nil {
+		return nil
+	}
+	return []ast.Node{zero}
+}
+
+// All returns the nodes of type N of the files of the Inspector, in
+// depth-first order, as Preorder visits them. N may be a node type,
+// such as *ast.CallExpr, or an interface, such as ast.Expr or ast.Stmt,
+// which All then finds by visiting all nodes.
+//
+//	for _, call := range inspector.All[*ast.CallExpr](in) {
+//		...
+//	}
+func All[N ast.Node](in *Inspector) []N {
+	var nodes []N
+	in.Preorder(typesOf[N](), func(n ast.Node) {
+		if n, ok := n.(N); ok {
+			nodes = append(nodes, n)
+		}
+	})
+	return nodes
+}
+
+// PreorderAs calls f for each node of type N of the files of the
+// Inspector, in depth-first order, as Preorder does.
+func PreorderAs[N ast.Node](in *Inspector, f func(n N)) {
+	in.Preorder(typesOf[N](), func(n ast.Node) {
+		if n, ok := n.(N); ok {
+			f(n)
+		}
+	})
+}
+
+// WithStackAs visits the nodes of type N of the files of the Inspector
+// as WithStack does, calling f before and after their children, with
+// the traversal stack.
+func WithStackAs[N ast.Node](in *Inspector, f func(n N, push bool, stack []ast.Node) (proceed bool)) {
+	in.WithStack(typesOf[N](), func(n ast.Node, push bool, stack []ast.Node) bool {
+		if n, ok := n.(N); ok {
+			return f(n, push, stack)
+		}
+		return true
+	})
+}
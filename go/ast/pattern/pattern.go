@@ -0,0 +1,422 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pattern finds the syntax that matches a pattern, written as
+// Go source with wildcards, in the manner of gogrep, so that an analyzer
+// may declare the code it looks for rather than visit the syntax trees
+// by hand.
+//
+// A pattern is an expression, or a list of statements separated by
+// semicolons or newlines, in which:
+//
+//	$x   is a wildcard that matches any expression or statement, and
+//	     binds it to the name x: the other occurrences of $x in the
+//	     pattern match only syntax identical to the first;
+//	$_   is a wildcard that matches anything, without binding it;
+//	...  as a statement, or as an element of a list of arguments or
+//	     of expressions, matches any sequence of them, even empty.
+//
+// The other identifiers of a pattern match the identifiers of the same
+// name, and the rest of its syntax matches identical syntax, regardless
+// of positions and comments. For instance, the pattern
+//
+//	$x.Lock(); ...; $x.Unlock()
+//
+// matches the statements of a block that lock a mutex, then unlock it
+// after any other statements, and
+//
+//	fmt.Sprintf("%s", $_)
+//
+// matches the calls of Sprintf that format a single value as a string.
+//
+// Wildcards may also be constrained by type, with Pattern.Constrain, so
+// that they match only the expressions whose type satisfies a predicate.
+//
+// A Pattern is compiled once, and may then be matched against the nodes
+// of an Inspector with Find, which uses its type filtering, or against
+// a single node with Match.
+package pattern // import "golang.org/x/tools/go/ast/pattern"
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// The wildcards of a pattern are replaced, before it is parsed, by
+// identifiers with reserved names.
+const (
+	wildPrefix = "__wild_"    // $x becomes __wild_x
+	anyName    = "__wild_any" // ... becomes __wild_any
+)
+
+// A Pattern is a compiled pattern.
+type Pattern struct {
+	src   string
+	expr  ast.Expr   // the pattern, if it is an expression
+	stmts []ast.Stmt // otherwise, its statements
+	names map[string]bool
+	types map[string]func(types.Type) bool
+}
+
+// Compile parses a pattern and returns, if it is valid, a Pattern that
+// matches it.
+func Compile(src string) (*Pattern, error) {
+	p := &Pattern{
+		src:   src,
+		names: make(map[string]bool),
+		types: make(map[string]func(types.Type) bool),
+	}
+	text, err := p.substitute(src)
+	if err != nil {
+		return nil, err
+	}
+	if expr, err := parser.ParseExpr(text); err == nil {
+		p.expr = expr
+		return p, nil
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p; func _() {\n"+text+"\n}", 0)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %v", src, err)
+	}
+	p.stmts = f.Decls[0].(*ast.FuncDecl).Body.List
+	if len(p.stmts) == 0 {
+		return nil, fmt.Errorf("pattern %q is empty", src)
+	}
+	return p, nil
+}
+
+// MustCompile is like Compile but panics if the pattern is invalid. It
+// simplifies the initialization of global variables holding patterns.
+func MustCompile(src string) *Pattern {
+	p, err := Compile(src)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// substitute returns the source of the pattern in which the wildcards
+// are replaced by identifiers, and records their names.
+func (p *Pattern) substitute(src string) (string, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), func(token.Position, string) {}, 0) // $ is reported as illegal
+	var buf strings.Builder
+	last := 0             // offset of the source not yet copied
+	prev := token.LBRACE  // the previous token, as at the start of a block
+	dollar := token.NoPos // position of a $ that must be followed by a name
+	for {
+		pos, tok, lit := s.Scan()
+		if dollar.IsValid() {
+			if tok != token.IDENT || pos != dollar+1 {
+				return "", fmt.Errorf("pattern %q: $ not followed by a name", p.src)
+			}
+			off := file.Offset(dollar)
+			buf.WriteString(src[last:off])
+			buf.WriteString(wildPrefix + lit)
+			last = off + 1 + len(lit)
+			if lit != "_" {
+				p.names[lit] = true
+			}
+			dollar = token.NoPos
+			prev = token.IDENT
+			continue
+		}
+		switch {
+		case tok == token.EOF:
+			buf.WriteString(src[last:])
+			return buf.String(), nil
+		case tok == token.ILLEGAL && lit == "$":
+			dollar = pos
+		case tok == token.ILLEGAL:
+			return "", fmt.Errorf("pattern %q: illegal character %s", p.src, lit)
+		case tok == token.ELLIPSIS && (prev == token.SEMICOLON || prev == token.LBRACE || prev == token.LPAREN || prev == token.COMMA):
+			// An ellipsis that follows no expression is a wildcard.
+			off := file.Offset(pos)
+			buf.WriteString(src[last:off])
+			buf.WriteString(anyName)
+			last = off + len("...")
+		}
+		prev = tok
+	}
+}
+
+// String returns the source of the pattern.
+func (p *Pattern) String() string {
+	return p.src
+}
+
+// Constrain constrains the wildcard $name of the pattern to match only
+// the expressions whose type, in the type information given to Find or
+// Match, satisfies pred, and returns the pattern. It panics if the
+// pattern has no such wildcard.
+//
+//	p := pattern.MustCompile("$x.Lock()").Constrain("x", isMutex)
+func (p *Pattern) Constrain(name string, pred func(t types.Type) bool) *Pattern {
+	if !p.names[name] {
+		panic(fmt.Sprintf("pattern %q has no wildcard $%s", p.src, name))
+	}
+	p.types[name] = pred
+	return p
+}
+
+// A Match describes the syntax that matches a pattern.
+type Match struct {
+	// Nodes holds the syntax that matches: an expression or a
+	// statement, or the sequence of statements of a block, or of a
+	// clause, that matches a list of statements.
+	Nodes []ast.Node
+
+	// Values maps the names of the wildcards of the pattern to the
+	// syntax that they match.
+	Values map[string]ast.Node
+}
+
+// Match reports whether the node matches a pattern that is an
+// expression or a single statement, and returns the match. The type
+// information info, which may be nil if the wildcards of the pattern
+// are not constrained by type, must describe the node.
+func (p *Pattern) Match(n ast.Node, info *types.Info) (*Match, bool) {
+	m := &matcher{p: p, info: info, values: make(map[string]ast.Node)}
+	var pat ast.Node = p.expr
+	if p.expr == nil {
+		if len(p.stmts) != 1 || isAny(p.stmts[0]) {
+			return nil, false
+		}
+		pat = p.stmts[0]
+	}
+	if !m.node(pat, n) {
+		return nil, false
+	}
+	return &Match{Nodes: []ast.Node{n}, Values: m.values}, true
+}
+
+// Find calls f for each match of the pattern in the syntax trees of the
+// Inspector, in depth-first order. A pattern that is a list of
+// statements, but not a single one, matches the shortest sequences of
+// statements of blocks and clauses that it matches, from each of their
+// statements. The type information info, which may be nil if the
+// wildcards of the pattern are not constrained by type, must describe
+// the trees.
+func (p *Pattern) Find(in *inspector.Inspector, info *types.Info, f func(m *Match)) {
+	if p.expr != nil || (len(p.stmts) == 1 && !isAny(p.stmts[0])) {
+		var types []ast.Node // the type of the root of the pattern, unless it is a wildcard
+		if root := p.root(); wildcard(root) == "" {
+			types = []ast.Node{root}
+		}
+		in.Preorder(types, func(n ast.Node) {
+			if m, ok := p.Match(n, info); ok {
+				f(m)
+			}
+		})
+		return
+	}
+
+	pats := make([]ast.Node, len(p.stmts))
+	for i, stmt := range p.stmts {
+		pats[i] = stmt
+	}
+	types := []ast.Node{(*ast.BlockStmt)(nil), (*ast.CaseClause)(nil), (*ast.CommClause)(nil)}
+	in.Preorder(types, func(n ast.Node) {
+		var list []ast.Stmt
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list = n.Body
+		case *ast.CommClause:
+			list = n.Body
+		}
+		nodes := make([]ast.Node, len(list))
+		for i, stmt := range list {
+			nodes[i] = stmt
+		}
+		for i := range nodes {
+			for j := i; j <= len(nodes); j++ {
+				m := &matcher{p: p, info: info, values: make(map[string]ast.Node)}
+				if j > i && m.list(pats, nodes[i:j]) {
+					f(&Match{Nodes: nodes[i:j], Values: m.values})
+					break
+				}
+			}
+		}
+	})
+}
+
+// root returns the root of a pattern that matches a single node.
+func (p *Pattern) root() ast.Node {
+	if p.expr != nil {
+		return p.expr
+	}
+	return p.stmts[0]
+}
+
+// A matcher matches a pattern against syntax, and records the values
+// of its wildcards.
+type matcher struct {
+	p      *Pattern
+	info   *types.Info
+	values map[string]ast.Node
+}
+
+// wildcard returns the name of the wildcard that the identifier of a
+// pattern is, without $, or "".
+func wildcard(n ast.Node) string {
+	if id, ok := n.(*ast.Ident); ok && id.Name != anyName && strings.HasPrefix(id.Name, wildPrefix) {
+		return id.Name[len(wildPrefix):]
+	}
+	return ""
+}
+
+// isAny reports whether the node of a pattern is an ellipsis that
+// matches any sequence of nodes.
+func isAny(n ast.Node) bool {
+	if stmt, ok := n.(*ast.ExprStmt); ok {
+		n = stmt.X
+	}
+	id, ok := n.(*ast.Ident)
+	return ok && id.Name == anyName
+}
+
+// bind matches the node against the wildcard of the given name.
+func (m *matcher) bind(name string, n ast.Node) bool {
+	if name == "_" {
+		return true
+	}
+	if prev, ok := m.values[name]; ok {
+		return (&matcher{p: m.p}).node(prev, n)
+	}
+	if pred := m.p.types[name]; pred != nil {
+		e, ok := n.(ast.Expr)
+		if !ok || m.info == nil {
+			return false
+		}
+		if t := m.info.TypeOf(e); t == nil || !pred(t) {
+			return false
+		}
+	}
+	m.values[name] = n
+	return true
+}
+
+// node reports whether the node n matches the node pat of a pattern.
+func (m *matcher) node(pat, n ast.Node) bool {
+	if isNil(pat) || isNil(n) {
+		return isNil(pat) && isNil(n)
+	}
+	if name := wildcard(pat); name != "" {
+		return m.bind(name, n)
+	}
+	if stmt, ok := pat.(*ast.ExprStmt); ok {
+		// A wildcard is a statement that matches any statement.
+		if name := wildcard(stmt.X); name != "" {
+			if _, ok := n.(*ast.ExprStmt); !ok {
+				return m.bind(name, n)
+			}
+		}
+	}
+	if id, ok := pat.(*ast.Ident); ok {
+		nid, ok := n.(*ast.Ident)
+		return ok && nid.Name == id.Name
+	}
+	pv, nv := reflect.ValueOf(pat), reflect.ValueOf(n)
+	if pv.Type() != nv.Type() {
+		return false
+	}
+	pv, nv = pv.Elem(), nv.Elem()
+	for i := 0; i < pv.NumField(); i++ {
+		if !m.field(pv.Field(i), nv.Field(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	nodeType         = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	posType          = reflect.TypeOf(token.NoPos)
+	objectType       = reflect.TypeOf((*ast.Object)(nil))
+	scopeType        = reflect.TypeOf((*ast.Scope)(nil))
+	commentGroupType = reflect.TypeOf((*ast.CommentGroup)(nil))
+)
+
+// field reports whether the field of a node matches that of a node of
+// a pattern.
+func (m *matcher) field(pv, nv reflect.Value) bool {
+	switch t := pv.Type(); {
+	case t == posType, t == objectType, t == scopeType, t == commentGroupType:
+		return true // positions, resolution and comments do not matter
+	case t.Implements(nodeType):
+		var pat, n ast.Node
+		if !pv.IsNil() {
+			pat = pv.Interface().(ast.Node)
+		}
+		if !nv.IsNil() {
+			n = nv.Interface().(ast.Node)
+		}
+		return m.node(pat, n)
+	case t.Kind() == reflect.Slice && t.Elem().Implements(nodeType):
+		pats := make([]ast.Node, pv.Len())
+		for i := range pats {
+			pats[i] = pv.Index(i).Interface().(ast.Node)
+		}
+		nodes := make([]ast.Node, nv.Len())
+		for i := range nodes {
+			nodes[i] = nv.Index(i).Interface().(ast.Node)
+		}
+		return m.list(pats, nodes)
+	default:
+		return reflect.DeepEqual(pv.Interface(), nv.Interface())
+	}
+}
+
+// list reports whether the list of nodes matches that of a pattern, in
+// which ellipses match any sequence of nodes.
+func (m *matcher) list(pats, nodes []ast.Node) bool {
+	if len(pats) == 0 {
+		return len(nodes) == 0
+	}
+	saved := make(map[string]ast.Node, len(m.values))
+	for k, v := range m.values {
+		saved[k] = v
+	}
+	restore := func() {
+		m.values = make(map[string]ast.Node, len(saved))
+		for k, v := range saved {
+			m.values[k] = v
+		}
+	}
+	if isAny(pats[0]) {
+		for k := 0; k <= len(nodes); k++ {
+			if m.list(pats[1:], nodes[k:]) {
+				return true
+			}
+			restore()
+		}
+		return false
+	}
+	if len(nodes) > 0 && m.node(pats[0], nodes[0]) && m.list(pats[1:], nodes[1:]) {
+		return true
+	}
+	restore()
+	return false
+}
+
+// isNil reports whether the node is nil, or a nil pointer.
+func isNil(n ast.Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
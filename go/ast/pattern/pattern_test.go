@@ -0,0 +1,130 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pattern_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/ast/pattern"
+)
+
+const src = `package p
+
+import (
+	"fmt"
+	"sync"
+)
+
+var mu sync.Mutex
+
+type T struct{ mu sync.Mutex }
+
+func f(t *T, s string, n int) {
+	mu.Lock()
+	fmt.Println(s)
+	mu.Unlock()
+
+	t.mu.Lock()
+	t.mu.Unlock()
+
+	mu.Lock()
+	t.mu.Unlock()
+
+	switch {
+	case n > 0:
+		t.mu.Lock()
+		n++
+		n++
+		t.mu.Unlock()
+	}
+
+	_ = fmt.Sprintf("%s", s)
+	_ = fmt.Sprintf("%s %d", s, n)
+	_ = s + s
+	_ = n + n
+	fmt.Println()
+	fmt.Println(s, n)
+}
+`
+
+func TestFind(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("p", fset, []*ast.File{f}, info); err != nil {
+		t.Fatal(err)
+	}
+	in := inspector.New([]*ast.File{f})
+
+	isString := func(t types.Type) bool { return types.Identical(t, types.Typ[types.String]) }
+	for _, test := range []struct {
+		pattern *pattern.Pattern
+		want    []string // the matches, with the values of $x
+	}{
+		{pattern.MustCompile("$x.Lock(); ...; $x.Unlock()"), []string{
+			"mu.Lock(); fmt.Println(s); mu.Unlock() x=mu",
+			"t.mu.Lock(); t.mu.Unlock() x=t.mu",
+			"t.mu.Lock(); n++; n++; t.mu.Unlock() x=t.mu",
+		}},
+		{pattern.MustCompile("fmt.Sprintf(\"%s\", $_)"), []string{
+			`fmt.Sprintf("%s", s)`,
+		}},
+		{pattern.MustCompile("$x + $x").Constrain("x", isString), []string{
+			"s + s x=s",
+		}},
+		{pattern.MustCompile("fmt.Println(...)"), []string{
+			"fmt.Println(s)", "fmt.Println()", "fmt.Println(s, n)",
+		}},
+		{pattern.MustCompile("fmt.Println($x, ...)"), []string{
+			"fmt.Println(s) x=s", "fmt.Println(s, n) x=s",
+		}},
+	} {
+		var got []string
+		test.pattern.Find(in, info, func(m *pattern.Match) {
+			var nodes []string
+			for _, n := range m.Nodes {
+				nodes = append(nodes, format1(t, fset, n))
+			}
+			s := strings.Join(nodes, "; ")
+			if x, ok := m.Values["x"]; ok {
+				s += " x=" + format1(t, fset, x)
+			}
+			got = append(got, s)
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got matches %q, want %q", test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, src := range []string{"", "$", "$ x", "f(", "x #"} {
+		if _, err := pattern.Compile(src); err == nil {
+			t.Errorf("Compile(%q) succeeded", src)
+		}
+	}
+}
+
+func format1(t *testing.T, fset *token.FileSet, n ast.Node) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, n); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
@@ -20,6 +20,14 @@
 // Since CHA conservatively assumes that all functions are address-taken
 // and all concrete types are put into interfaces, it is sound to run on
 // partial programs, such as libraries without a main or test function.
+//
+// The call graph of a generic function has a node for each of its
+// instantiations, whose calls are those of its body with its type
+// arguments, even if the program does not instantiate generics (see
+// ssa.InstantiateGenerics): CHA builds the bodies of those that the
+// program calls. The generic function itself may be a node, for
+// example if it is part of a library whose functions it calls, but it
+// has no body, and no edges.
 package cha // import "golang.org/x/tools/go/callgraph/cha"
 
 import (
@@ -38,6 +46,22 @@ func CallGraph(prog *ssa.Program) *callgraph.Graph {
 
 	allFuncs := ssautil.AllFunctions(prog)
 
+	// Unless the program instantiates generics, the instantiations
+	// of generic functions have no bodies: build those that the
+	// program calls, and those that their bodies call in turn.
+	for {
+		built := false
+		for f := range allFuncs {
+			if prog.BuildInstance(f) {
+				built = true
+			}
+		}
+		if !built {
+			break
+		}
+		allFuncs = ssautil.AllFunctions(prog)
+	}
+
 	// funcsBySig contains all functions, keyed by signature.  It is
 	// the effective set of address-taken functions used to resolve
 	// a dynamic call of a particular signature.
@@ -80,6 +104,11 @@ func CallGraph(prog *ssa.Program) *callgraph.Graph {
 	}
 
 	for f := range allFuncs {
+		if isGeneric(f) {
+			// Only the instantiations of a generic function
+			// may be called, or address-taken.
+			continue
+		}
 		if f.Signature.Recv() == nil {
 			// Package initializers can never be address-taken.
 			if f.Name() == "init" && f.Synthetic == "package initializer" {
@@ -135,3 +164,9 @@ func CallGraph(prog *ssa.Program) *callgraph.Graph {
 
 	return cg
 }
+
+// isGeneric reports whether the function is generic, as opposed to an
+// instantiation of a generic function, or a function that is neither.
+func isGeneric(f *ssa.Function) bool {
+	return len(f.TypeParams()) > 0 && len(f.TypeArgs()) == 0
+}
@@ -78,22 +78,26 @@ func TestCHAGenerics(t *testing.T) {
 		t.Skip("TestCHAGenerics requires type parameters")
 	}
 
-	filename := "testdata/generics.go"
-	prog, f, mainPkg, err := loadProgInfo(filename, ssa.InstantiateGenerics)
-	if err != nil {
-		t.Fatal(err)
-	}
+	// The graph is the same whether the instantiations of generic
+	// functions are built with the program or on demand.
+	for _, mode := range []ssa.BuilderMode{ssa.InstantiateGenerics, 0} {
+		filename := "testdata/generics.go"
+		prog, f, mainPkg, err := loadProgInfo(filename, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	want, pos := expectation(f)
-	if pos == token.NoPos {
-		t.Fatal(fmt.Errorf("No WANT: comment in %s", filename))
-	}
+		want, pos := expectation(f)
+		if pos == token.NoPos {
+			t.Fatal(fmt.Errorf("No WANT: comment in %s", filename))
+		}
 
-	cg := cha.CallGraph(prog)
+		cg := cha.CallGraph(prog)
 
-	if got := printGraph(cg, mainPkg.Pkg, "", "All calls"); got != want {
-		t.Errorf("%s: got:\n%s\nwant:\n%s",
-			prog.Fset.Position(pos), got, want)
+		if got := printGraph(cg, mainPkg.Pkg, "", "All calls"); got != want {
+			t.Errorf("%s: mode %q: got:\n%s\nwant:\n%s",
+				prog.Fset.Position(pos), mode, got, want)
+		}
 	}
 }
 
@@ -0,0 +1,290 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package deadcode reports the functions of a program that are
+// unreachable from its entry points.
+//
+// The entry points are the main and init functions of its main
+// packages (including the main packages of tests), the exported
+// functions and methods of the packages whose API is used by other
+// programs, and the functions that are used by reflection, which the
+// analysis cannot see, and which the configuration must name.
+// The functions reachable from them are found by Rapid Type Analysis
+// (see package rta), or, more precisely but at greater cost, by
+// Variable Type Analysis (see package vta).
+//
+// Each unreachable function has a confidence level: that of the
+// report that it is dead. The exported functions and methods may be
+// used by programs that the analysis does not see, and the exported
+// methods of the types whose values may be in interfaces may be
+// called by reflection, so they are reported with less confidence.
+package deadcode // import "golang.org/x/tools/go/callgraph/deadcode"
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// A Config specifies the entry points of a program, and the
+// functions to report.
+type Config struct {
+	// Mains are the main packages, whose main and init functions
+	// are entry points.
+	Mains []*ssa.Package
+
+	// Exported are the packages whose init functions, exported
+	// functions, and exported methods of exported types, are entry
+	// points.
+	Exported []*ssa.Package
+
+	// Reflected names the functions used by reflection, as
+	// ssa.Function.String does, e.g. "(*example.com/p.T).Serve".
+	// A name ending in "*" stands for all the functions with the
+	// names of which the rest is a prefix, e.g. "(*example.com/p.T).*".
+	Reflected []string
+
+	// Packages are the packages whose unreachable functions are
+	// reported. If empty, those of all packages are.
+	Packages []*ssa.Package
+
+	// VTA causes the reachable functions to be found by Variable
+	// Type Analysis, rather than Rapid Type Analysis.
+	VTA bool
+}
+
+// A Confidence is the confidence level of the report that a function
+// is dead.
+type Confidence int
+
+const (
+	// Low is the confidence in the deadness of exported methods
+	// of types whose values may be in interfaces, which may be
+	// called by reflection.
+	Low Confidence = iota
+
+	// Medium is the confidence in the deadness of other exported
+	// functions and methods, which other programs may use.
+	Medium
+
+	// High is the confidence in the deadness of the others.
+	High
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case Low:
+		return "low"
+	case Medium:
+		return "medium"
+	case High:
+		return "high"
+	}
+	return "unknown"
+}
+
+// A Func is an unreachable function.
+type Func struct {
+	Func       *ssa.Function
+	Posn       token.Position // the position of its declaration
+	Confidence Confidence
+}
+
+// Analyze returns the unreachable source functions of the built
+// program, in order of position. The result does not include
+// function literals, which are dead if the functions containing them
+// are. A generic function is reachable if any of its instantiations
+// is.
+func Analyze(prog *ssa.Program, config *Config) []*Func {
+	roots := entryPoints(prog, config)
+	reachable := make(map[*ssa.Function]bool)
+	if config.VTA {
+		cg := vta.CallGraph(ssautil.AllFunctions(prog), cha.CallGraph(prog))
+		var visit func(fn *ssa.Function)
+		visit = func(fn *ssa.Function) {
+			if !reachable[fn] {
+				reachable[fn] = true
+				if n := cg.Nodes[fn]; n != nil {
+					for _, e := range n.Out {
+						visit(e.Callee.Func)
+					}
+				}
+			}
+		}
+		for _, fn := range roots {
+			visit(fn)
+		}
+	} else if len(roots) > 0 {
+		for fn := range rta.Analyze(roots, false).Reachable {
+			reachable[fn] = true
+		}
+		for _, fn := range roots {
+			reachable[fn] = true // RTA omits the roots it does not reach
+		}
+	}
+	for fn := range reachable {
+		if origin := fn.Origin(); origin != nil {
+			reachable[origin] = true
+		}
+	}
+
+	reported := func(*ssa.Package) bool { return true }
+	if len(config.Packages) > 0 {
+		pkgs := make(map[*ssa.Package]bool)
+		for _, pkg := range config.Packages {
+			pkgs[pkg] = true
+		}
+		reported = func(pkg *ssa.Package) bool { return pkgs[pkg] }
+	}
+	var runtimeTypes typeutil.Map
+	for _, T := range prog.RuntimeTypes() {
+		runtimeTypes.Set(T, true)
+	}
+
+	var dead []*Func
+	for fn := range sourceFunctions(prog) {
+		if reachable[fn] || !reported(fn.Pkg) {
+			continue
+		}
+		dead = append(dead, &Func{
+			Func:       fn,
+			Posn:       prog.Fset.Position(fn.Pos()),
+			Confidence: confidence(fn, &runtimeTypes),
+		})
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		x, y := dead[i].Posn, dead[j].Posn
+		if x.Filename != y.Filename {
+			return x.Filename < y.Filename
+		}
+		return x.Offset < y.Offset
+	})
+	return dead
+}
+
+// entryPoints returns the entry points of the program.
+func entryPoints(prog *ssa.Program, config *Config) []*ssa.Function {
+	var roots []*ssa.Function
+	for _, pkg := range config.Mains {
+		for _, name := range []string{"init", "main"} {
+			if fn := pkg.Func(name); fn != nil {
+				roots = append(roots, fn)
+			}
+		}
+	}
+	for _, pkg := range config.Exported {
+		roots = append(roots, pkg.Func("init"))
+		for name, mem := range pkg.Members {
+			if !ast.IsExported(name) {
+				continue
+			}
+			switch mem := mem.(type) {
+			case *ssa.Function:
+				roots = append(roots, mem)
+			case *ssa.Type:
+				T := mem.Type()
+				if _, ok := T.Underlying().(*types.Interface); ok {
+					continue
+				}
+				for _, T := range []types.Type{T, types.NewPointer(T)} {
+					mset := prog.MethodSets.MethodSet(T)
+					for i, n := 0, mset.Len(); i < n; i++ {
+						if sel := mset.At(i); sel.Obj().Exported() {
+							if fn := prog.MethodValue(sel); fn != nil {
+								roots = append(roots, fn)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(config.Reflected) > 0 {
+		for fn := range ssautil.AllFunctions(prog) {
+			if isReflected(fn.String(), config.Reflected) {
+				roots = append(roots, fn)
+			}
+		}
+	}
+	return roots
+}
+
+// isReflected reports whether the function of the given name is among
+// the functions named.
+func isReflected(name string, names []string) bool {
+	for _, pattern := range names {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, pattern[:len(pattern)-1]) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceFunctions returns the declared functions and methods of the
+// packages of the program, generic or not.
+func sourceFunctions(prog *ssa.Program) map[*ssa.Function]bool {
+	funcs := make(map[*ssa.Function]bool)
+	for _, pkg := range prog.AllPackages() {
+		for _, mem := range pkg.Members {
+			switch mem := mem.(type) {
+			case *ssa.Function:
+				if isSource(mem) {
+					funcs[mem] = true
+				}
+			case *ssa.Type:
+				T := mem.Type()
+				if _, ok := T.Underlying().(*types.Interface); ok {
+					continue
+				}
+				named, ok := T.(*types.Named)
+				if !ok {
+					continue
+				}
+				for i := 0; i < named.NumMethods(); i++ {
+					if fn := prog.FuncValue(named.Method(i)); fn != nil && isSource(fn) {
+						funcs[fn] = true
+					}
+				}
+			}
+		}
+	}
+	return funcs
+}
+
+// isSource reports whether fn is a declared function or method, as
+// generic functions are despite having no body.
+func isSource(fn *ssa.Function) bool {
+	return fn.Synthetic == "" || len(fn.TypeParams()) > 0
+}
+
+// confidence returns the confidence level of the report that fn is
+// dead, given the runtime types of the program.
+func confidence(fn *ssa.Function, runtimeTypes *typeutil.Map) Confidence {
+	if obj, ok := fn.Object().(*types.Func); !ok || !obj.Exported() {
+		return High
+	}
+	if recv := fn.Signature.Recv(); recv != nil {
+		T := recv.Type()
+		if ptr, ok := T.(*types.Pointer); ok {
+			T = ptr.Elem()
+		}
+		if runtimeTypes.At(T) != nil || runtimeTypes.At(types.NewPointer(T)) != nil {
+			return Low
+		}
+	}
+	return Medium
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deadcode_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/callgraph/deadcode"
+	"golang.org/x/tools/go/ssa"
+)
+
+var srcs = []struct{ path, src string }{
+	{"example.com/lib", `package lib
+
+type T struct{}
+
+func (T) Used()       {}
+func (T) Unused()     {}
+func (*T) Reflected() {}
+
+func Exported() {}
+
+func helper() {}
+func unused() {}
+
+func G[X any](x X) { helper() }
+func H[X any](x X) {}
+
+func init() {}
+`},
+	{"example.com/cmd", `package main
+
+import "example.com/lib"
+
+func main() {
+	var i interface{ Used() } = lib.T{}
+	i.Used()
+	used()
+	lib.G(1)
+}
+
+func used() {}
+
+func dead() { func() {}() }
+`},
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// build returns the program of srcs, built, and its packages.
+func build(t *testing.T) (*ssa.Program, []*ssa.Package) {
+	fset := token.NewFileSet()
+	prog := ssa.NewProgram(fset, ssa.InstantiateGenerics)
+	var pkgs []*ssa.Package
+	tpkgs := make(map[string]*types.Package)
+	conf := &types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+		return tpkgs[path], nil
+	})}
+	for _, src := range srcs {
+		f, err := parser.ParseFile(fset, src.path+".go", src.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Instances:  make(map[*ast.Ident]types.Instance),
+			Scopes:     make(map[ast.Node]*types.Scope),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		pkg, err := conf.Check(src.path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tpkgs[src.path] = pkg
+		pkgs = append(pkgs, prog.CreatePackage(pkg, []*ast.File{f}, info, true))
+	}
+	prog.Build()
+	return prog, pkgs
+}
+
+func TestAnalyze(t *testing.T) {
+	prog, pkgs := build(t)
+	lib, cmd := pkgs[0], pkgs[1]
+	for _, test := range []struct {
+		config *deadcode.Config
+		want   []string
+	}{
+		{&deadcode.Config{Mains: []*ssa.Package{cmd}}, []string{
+			"example.com/cmd.dead 14:6 high",
+			"example.com/lib.Exported 9:6 medium",
+			"example.com/lib.unused 12:6 high",
+			"example.com/lib.H 15:6 medium",
+		}},
+		// Unlike RTA, VTA does not consider the exported methods of
+		// runtime types reachable.
+		{&deadcode.Config{Mains: []*ssa.Package{cmd}, VTA: true}, []string{
+			"example.com/cmd.dead 14:6 high",
+			"(example.com/lib.T).Unused 6:10 low",
+			"(*example.com/lib.T).Reflected 7:11 low",
+			"example.com/lib.Exported 9:6 medium",
+			"example.com/lib.unused 12:6 high",
+			"example.com/lib.H 15:6 medium",
+		}},
+		{&deadcode.Config{Mains: []*ssa.Package{cmd}, Reflected: []string{"(*example.com/lib.T).*"}, VTA: true}, []string{
+			"example.com/cmd.dead 14:6 high",
+			"example.com/lib.Exported 9:6 medium",
+			"example.com/lib.unused 12:6 high",
+			"example.com/lib.H 15:6 medium",
+		}},
+		{&deadcode.Config{Mains: []*ssa.Package{cmd}, Exported: []*ssa.Package{lib}}, []string{
+			"example.com/cmd.dead 14:6 high",
+			"example.com/lib.unused 12:6 high",
+		}},
+		{&deadcode.Config{Mains: []*ssa.Package{cmd}, Packages: []*ssa.Package{cmd}}, []string{
+			"example.com/cmd.dead 14:6 high",
+		}},
+	} {
+		var got []string
+		for _, fn := range deadcode.Analyze(prog, test.config) {
+			got = append(got, fmt.Sprintf("%s %d:%d %s", fn.Func, fn.Posn.Line, fn.Posn.Column, fn.Confidence))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Analyze(%+v) = %q, want %q", test.config, got, test.want)
+		}
+	}
+}
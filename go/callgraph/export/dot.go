@@ -0,0 +1,73 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes the graph to w in the Graphviz DOT language, with
+// the nodes of each package in a cluster labelled by its path. Edges
+// of dynamic calls are dashed, and those of go and defer statements
+// are labelled by their kind.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph callgraph {")
+
+	// The nodes, by package, in order.
+	var pkgs []string
+	byPkg := make(map[string][]*Node)
+	for _, n := range g.Nodes {
+		if byPkg[n.Package] == nil {
+			pkgs = append(pkgs, n.Package)
+		}
+		byPkg[n.Package] = append(byPkg[n.Package], n)
+	}
+	clusters := 0
+	for _, pkg := range pkgs {
+		indent := "\t"
+		if pkg != "" {
+			fmt.Fprintf(out, "\tsubgraph cluster_%d {\n", clusters)
+			clusters++
+			fmt.Fprintf(out, "\t\tlabel=%s;\n", strconv.Quote(pkg))
+			indent = "\t\t"
+		}
+		for _, n := range byPkg[pkg] {
+			fmt.Fprintf(out, "%sn%d [label=%s", indent, n.ID, strconv.Quote(n.Name))
+			if n.Synthetic {
+				fmt.Fprint(out, ",style=dotted")
+			}
+			fmt.Fprintln(out, "];")
+		}
+		if pkg != "" {
+			fmt.Fprintln(out, "\t}")
+		}
+	}
+
+	for _, e := range g.Edges {
+		var attrs []string
+		if e.Dynamic {
+			attrs = append(attrs, "style=dashed")
+		}
+		if e.Kind == "go" || e.Kind == "defer" {
+			attrs = append(attrs, "label="+e.Kind)
+		}
+		if e.Count > 1 {
+			attrs = append(attrs, "weight="+strconv.Itoa(e.Count))
+		}
+		fmt.Fprintf(out, "\tn%d -> n%d", e.Caller, e.Callee)
+		if len(attrs) > 0 {
+			fmt.Fprintf(out, " [%s]", strings.Join(attrs, ","))
+		}
+		fmt.Fprintln(out, ";")
+	}
+
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
@@ -0,0 +1,213 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package export writes call graphs in formats that other tools
+// consume: Graphviz DOT, JSON, and protocol buffers.
+//
+// New converts a callgraph.Graph into a Graph, pruning or collapsing
+// it as the Options require. The nodes and edges of a Graph are in a
+// stable order, independent of the order in which the call graph was
+// constructed, so that the outputs for the same program are
+// identical, and those for similar programs are easily compared.
+//
+// The JSON form of a Graph is that of the Graph type, and its
+// protocol buffer form is described by the file callgraph.proto,
+// whose messages have the fields of the types of the same names.
+package export // import "golang.org/x/tools/go/callgraph/export"
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Options controls the conversion of a call graph.
+type Options struct {
+	// PruneStdlib causes the functions of the standard library to
+	// be omitted, with their edges.
+	PruneStdlib bool
+
+	// CollapsePackages causes the functions of each package to be
+	// represented by a single node, named by the package path.
+	// The edges between two nodes of the same kind are merged.
+	CollapsePackages bool
+}
+
+// A Graph is a call graph, in the form in which it is written.
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+// A Node is a function, or a package if packages are collapsed.
+type Node struct {
+	ID        int    `json:"id"`                  // index in Graph.Nodes
+	Name      string `json:"name"`                // function name, or package path
+	Package   string `json:"package,omitempty"`   // package path, if any
+	Pos       string `json:"pos,omitempty"`       // file:line:column of the function, if any
+	Synthetic bool   `json:"synthetic,omitempty"` // the function is synthetic, e.g. a wrapper
+}
+
+// An Edge is a call, or the calls between two packages if packages
+// are collapsed.
+type Edge struct {
+	Caller  int    `json:"caller"`            // ID of the calling node
+	Callee  int    `json:"callee"`            // ID of the called node
+	Kind    string `json:"kind"`              // "call", "go", "defer", or "synthetic"
+	Dynamic bool   `json:"dynamic,omitempty"` // the call is through a func value or an interface
+	Pos     string `json:"pos,omitempty"`     // file:line:column of the call, if not collapsed
+	Count   int    `json:"count"`             // number of calls
+}
+
+// New returns the Graph of the call graph cg of a program whose
+// positions are those of fset.
+func New(cg *callgraph.Graph, fset *token.FileSet, opts *Options) *Graph {
+	if opts == nil {
+		opts = new(Options)
+	}
+	g := new(Graph)
+
+	// Create the nodes, and sort them.
+	nodes := make(map[*callgraph.Node]*Node)
+	byKey := make(map[string]*Node)
+	for _, n := range cg.Nodes {
+		fn := n.Func
+		if fn == nil {
+			// The root of a call graph may have no function.
+			node := &Node{Name: "<root>", Synthetic: true}
+			g.Nodes = append(g.Nodes, node)
+			nodes[n] = node
+			continue
+		}
+		pkg := packagePath(fn)
+		if opts.PruneStdlib && isStandard(pkg) {
+			continue
+		}
+		node := &Node{
+			Name:      fn.String(),
+			Package:   pkg,
+			Synthetic: fn.Synthetic != "",
+		}
+		if pos := fn.Pos(); pos.IsValid() {
+			node.Pos = fset.Position(pos).String()
+		}
+		if opts.CollapsePackages && pkg != "" {
+			node = &Node{Name: pkg, Package: pkg}
+		}
+		key := node.Name + " " + node.Pos
+		if prev := byKey[key]; prev != nil {
+			node = prev
+		} else {
+			byKey[key] = node
+			g.Nodes = append(g.Nodes, node)
+		}
+		nodes[n] = node
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		x, y := g.Nodes[i], g.Nodes[j]
+		if x.Name != y.Name {
+			return x.Name < y.Name
+		}
+		return x.Pos < y.Pos
+	})
+	for i, node := range g.Nodes {
+		node.ID = i
+	}
+
+	// Create the edges, merging those between collapsed nodes, and
+	// sort them.
+	type edgeKey struct {
+		caller, callee int
+		kind, pos      string
+		dynamic        bool
+	}
+	edges := make(map[edgeKey]*Edge)
+	for _, n := range cg.Nodes {
+		for _, e := range n.Out {
+			caller, callee := nodes[e.Caller], nodes[e.Callee]
+			if caller == nil || callee == nil {
+				continue // pruned
+			}
+			edge := &Edge{Caller: caller.ID, Callee: callee.ID, Kind: "synthetic", Count: 1}
+			if e.Site != nil {
+				switch e.Site.(type) {
+				case *ssa.Go:
+					edge.Kind = "go"
+				case *ssa.Defer:
+					edge.Kind = "defer"
+				default:
+					edge.Kind = "call"
+				}
+				edge.Dynamic = e.Site.Common().StaticCallee() == nil
+				if pos := e.Pos(); pos.IsValid() && !opts.CollapsePackages {
+					edge.Pos = fset.Position(pos).String()
+				}
+			}
+			key := edgeKey{edge.Caller, edge.Callee, edge.Kind, edge.Pos, edge.Dynamic}
+			if prev := edges[key]; prev != nil {
+				prev.Count++
+			} else {
+				edges[key] = edge
+				g.Edges = append(g.Edges, edge)
+			}
+		}
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		x, y := g.Edges[i], g.Edges[j]
+		switch {
+		case x.Caller != y.Caller:
+			return x.Caller < y.Caller
+		case x.Callee != y.Callee:
+			return x.Callee < y.Callee
+		case x.Pos != y.Pos:
+			return x.Pos < y.Pos
+		case x.Kind != y.Kind:
+			return x.Kind < y.Kind
+		}
+		return !x.Dynamic && y.Dynamic
+	})
+	return g
+}
+
+// packagePath returns the path of the package of the function, or of
+// its method or generic function, or "" if it has none.
+func packagePath(fn *ssa.Function) string {
+	if fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	if fn.Pkg != nil {
+		return fn.Pkg.Pkg.Path()
+	}
+	if obj := fn.Object(); obj != nil && obj.Pkg() != nil {
+		return obj.Pkg().Path() // e.g. a wrapper
+	}
+	return ""
+}
+
+// isStandard reports whether the package path is that of a package
+// of the standard library: that is, whether its first element has no
+// dot, as cmd/go decides, other than that of a main package.
+func isStandard(path string) bool {
+	if path == "" || path == "main" || path == "command-line-arguments" {
+		return false
+	}
+	i := strings.Index(path, "/")
+	if i < 0 {
+		i = len(path)
+	}
+	return !strings.Contains(path[:i], ".")
+}
+
+// WriteJSON writes the JSON form of the graph to w.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(g)
+}
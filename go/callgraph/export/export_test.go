@@ -0,0 +1,258 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package export_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/export"
+	"golang.org/x/tools/go/ssa"
+)
+
+// The package lib has the path of a package of the standard library.
+var srcs = []struct{ path, src string }{
+	{"lib", `package lib
+
+func Helper() {}
+`},
+	{"example.com/app", `package app
+
+import "lib"
+
+type I interface{ M() }
+
+type T int
+
+func (T) M() {}
+
+func F(i I) {
+	i.M()
+	lib.Helper()
+	go G()
+	defer G()
+}
+
+func G() { lib.Helper() }
+`},
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// build returns the program of srcs, built.
+func build(t *testing.T) *ssa.Program {
+	fset := token.NewFileSet()
+	prog := ssa.NewProgram(fset, 0)
+	pkgs := make(map[string]*types.Package)
+	conf := &types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+		return pkgs[path], nil
+	})}
+	for _, src := range srcs {
+		f, err := parser.ParseFile(fset, src.path+".go", src.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Scopes:     make(map[ast.Node]*types.Scope),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		pkg, err := conf.Check(src.path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs[src.path] = pkg
+		prog.CreatePackage(pkg, []*ast.File{f}, info, true)
+	}
+	prog.Build()
+	return prog
+}
+
+// edges returns a description of each edge of the graph.
+func edges(g *export.Graph) []string {
+	var edges []string
+	for _, e := range g.Edges {
+		desc := fmt.Sprintf("%s -> %s %s", g.Nodes[e.Caller].Name, g.Nodes[e.Callee].Name, e.Kind)
+		if e.Dynamic {
+			desc += " dynamic"
+		}
+		if e.Count > 1 {
+			desc += fmt.Sprintf(" x%d", e.Count)
+		}
+		edges = append(edges, desc)
+	}
+	return edges
+}
+
+func TestNew(t *testing.T) {
+	prog := build(t)
+	cg := cha.CallGraph(prog)
+	for _, test := range []struct {
+		opts  *export.Options
+		edges []string
+	}{
+		{nil, []string{
+			"(*example.com/app.T).M -> (example.com/app.T).M call",
+			"example.com/app.F -> (*example.com/app.T).M call dynamic",
+			"example.com/app.F -> (example.com/app.T).M call dynamic",
+			"example.com/app.F -> example.com/app.G go",
+			"example.com/app.F -> example.com/app.G defer",
+			"example.com/app.F -> lib.Helper call",
+			"example.com/app.G -> lib.Helper call",
+			"example.com/app.init -> lib.init call",
+		}},
+		{&export.Options{PruneStdlib: true}, []string{
+			"(*example.com/app.T).M -> (example.com/app.T).M call",
+			"example.com/app.F -> (*example.com/app.T).M call dynamic",
+			"example.com/app.F -> (example.com/app.T).M call dynamic",
+			"example.com/app.F -> example.com/app.G go",
+			"example.com/app.F -> example.com/app.G defer",
+		}},
+		{&export.Options{CollapsePackages: true}, []string{
+			"example.com/app -> example.com/app call",
+			"example.com/app -> example.com/app call dynamic x2",
+			"example.com/app -> example.com/app defer",
+			"example.com/app -> example.com/app go",
+			"example.com/app -> lib call x3",
+		}},
+	} {
+		g := export.New(cg, prog.Fset, test.opts)
+		if got := edges(g); !reflect.DeepEqual(got, test.edges) {
+			t.Errorf("New(%+v): got edges\n%s\nwant\n%s", test.opts, strings.Join(got, "\n"), strings.Join(test.edges, "\n"))
+		}
+		for i, n := range g.Nodes {
+			if n.ID != i {
+				t.Errorf("New(%+v): node %d has ID %d", test.opts, i, n.ID)
+			}
+		}
+
+		// The graph is the same in each form, and stable.
+		if again := export.New(cha.CallGraph(prog), prog.Fset, test.opts); !reflect.DeepEqual(again, g) {
+			t.Errorf("New(%+v) is unstable", test.opts)
+		}
+		var buf bytes.Buffer
+		if err := g.WriteJSON(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var fromJSON export.Graph
+		if err := json.Unmarshal(buf.Bytes(), &fromJSON); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&fromJSON, g) {
+			t.Errorf("New(%+v): JSON form differs: %s", test.opts, buf.Bytes())
+		}
+		buf.Reset()
+		if err := g.WriteProto(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if fromProto := decodeGraph(t, buf.Bytes()); !reflect.DeepEqual(fromProto, g) {
+			t.Errorf("New(%+v): protocol buffer form differs", test.opts)
+		}
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	prog := build(t)
+	g := export.New(cha.CallGraph(prog), prog.Fset, nil)
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"digraph callgraph {\n",
+		"\tsubgraph cluster_0 {\n\t\tlabel=\"example.com/app\";\n",
+		"\tsubgraph cluster_1 {\n\t\tlabel=\"lib\";\n\t\tn6 [label=\"lib.Helper\"];\n",
+		"\tn2 [label=\"<root>\",style=dotted];\n",
+		"\tn3 -> n1 [style=dashed];\n",
+		"\tn3 -> n4 [label=go];\n",
+		"\tn3 -> n6;\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("DOT form lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+// decodeGraph decodes the protocol buffer form of a graph.
+func decodeGraph(t *testing.T, data []byte) *export.Graph {
+	g := new(export.Graph)
+	decodeMessage(t, data, func(field int, x uint64, b []byte) {
+		switch field {
+		case 1:
+			n := new(export.Node)
+			decodeMessage(t, b, func(field int, x uint64, b []byte) {
+				switch field {
+				case 1:
+					n.ID = int(x)
+				case 2:
+					n.Name = string(b)
+				case 3:
+					n.Package = string(b)
+				case 4:
+					n.Pos = string(b)
+				case 5:
+					n.Synthetic = x != 0
+				}
+			})
+			g.Nodes = append(g.Nodes, n)
+		case 2:
+			e := new(export.Edge)
+			decodeMessage(t, b, func(field int, x uint64, b []byte) {
+				switch field {
+				case 1:
+					e.Caller = int(x)
+				case 2:
+					e.Callee = int(x)
+				case 3:
+					e.Kind = string(b)
+				case 4:
+					e.Dynamic = x != 0
+				case 5:
+					e.Pos = string(b)
+				case 6:
+					e.Count = int(x)
+				}
+			})
+			g.Edges = append(g.Edges, e)
+		}
+	})
+	return g
+}
+
+// decodeMessage calls f for each varint or length-delimited field of
+// the message.
+func decodeMessage(t *testing.T, data []byte, f func(field int, x uint64, b []byte)) {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		data = data[n:]
+		x, n := binary.Uvarint(data)
+		data = data[n:]
+		switch tag & 7 {
+		case 0:
+			f(int(tag>>3), x, nil)
+		case 2:
+			f(int(tag>>3), 0, data[:x])
+			data = data[x:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+}
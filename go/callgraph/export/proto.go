@@ -0,0 +1,90 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package export
+
+// This file encodes a Graph in the protocol buffer wire format, as
+// described by callgraph.proto, without depending on a protocol
+// buffer library.
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Wire types.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// WriteProto writes the protocol buffer form of the graph to w: the
+// encoding of a Graph message of callgraph.proto.
+func (g *Graph) WriteProto(w io.Writer) error {
+	var buf, msg protoBuffer
+	for _, n := range g.Nodes {
+		msg.reset()
+		msg.int(1, int64(n.ID))
+		msg.string(2, n.Name)
+		msg.string(3, n.Package)
+		msg.string(4, n.Pos)
+		msg.bool(5, n.Synthetic)
+		buf.bytes(1, msg)
+	}
+	for _, e := range g.Edges {
+		msg.reset()
+		msg.int(1, int64(e.Caller))
+		msg.int(2, int64(e.Callee))
+		msg.string(3, e.Kind)
+		msg.bool(4, e.Dynamic)
+		msg.string(5, e.Pos)
+		msg.int(6, int64(e.Count))
+		buf.bytes(2, msg)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// A protoBuffer accumulates the encoding of the fields of a message.
+// Fields of default value are omitted, as in proto3.
+type protoBuffer []byte
+
+func (b *protoBuffer) reset() { *b = (*b)[:0] }
+
+func (b *protoBuffer) tag(field, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (b *protoBuffer) int(field int, x int64) {
+	if x != 0 {
+		b.tag(field, wireVarint)
+		b.varint(uint64(x))
+	}
+}
+
+func (b *protoBuffer) bool(field int, x bool) {
+	if x {
+		b.int(field, 1)
+	}
+}
+
+func (b *protoBuffer) string(field int, s string) {
+	if s != "" {
+		b.tag(field, wireBytes)
+		b.varint(uint64(len(s)))
+		*b = append(*b, s...)
+	}
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	*b = append(*b, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+// bytes appends an embedded message, which is encoded even if empty.
+func (b *protoBuffer) bytes(field int, msg []byte) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(msg)))
+	*b = append(*b, msg...)
+}
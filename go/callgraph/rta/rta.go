@@ -35,6 +35,12 @@
 // address-taken functions, and runtime types.  The process continues
 // until a fixed point is achieved.
 //
+// The reachable instantiations of generic functions are analyzed as
+// other functions are, with their type arguments, even if the program
+// does not instantiate generics (see ssa.InstantiateGenerics): RTA
+// builds their bodies, and those of the instantiations that they call,
+// as they become reachable.
+//
 // The resulting call graph is less precise than one produced by pointer
 // analysis, but the algorithm is much faster.  For example, running the
 // cmd/callgraph tool on its own source takes ~2.1s for RTA and ~5.4s
@@ -45,10 +51,6 @@ package rta // import "golang.org/x/tools/go/callgraph/rta"
 // replacing all "unreachable" functions by a special intrinsic, and
 // ensure that that intrinsic is never called.
 
-// TODO(zpavlinovic): decide if the clients must use ssa.InstantiateGenerics
-// mode when building programs with generics. It might be possible to
-// extend rta to accurately support generics with just ssa.BuilderMode(0).
-
 import (
 	"fmt"
 	"go/types"
@@ -224,6 +226,8 @@ func (r *rta) visitInvoke(site ssa.CallInstruction) {
 func (r *rta) visitFunc(f *ssa.Function) {
 	var space [32]*ssa.Value // preallocate space for common case
 
+	r.prog.BuildInstance(f) // an instantiation may have no body yet
+
 	for _, b := range f.Blocks {
 		for _, instr := range b.Instrs {
 			rands := instr.Operands(space[:0])
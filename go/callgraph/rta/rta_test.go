@@ -84,25 +84,29 @@ func TestRTAGenerics(t *testing.T) {
 		t.Skip("TestRTAGenerics requires type parameters")
 	}
 
-	filename := "testdata/generics.go"
-	prog, f, mainPkg, err := loadProgInfo(filename, ssa.InstantiateGenerics)
-	if err != nil {
-		t.Fatal(err)
-	}
+	// The graph is the same whether the instantiations of generic
+	// functions are built with the program or on demand.
+	for _, mode := range []ssa.BuilderMode{ssa.InstantiateGenerics, 0} {
+		filename := "testdata/generics.go"
+		prog, f, mainPkg, err := loadProgInfo(filename, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	want, pos := expectation(f)
-	if pos == token.NoPos {
-		t.Fatalf("No WANT: comment in %s", filename)
-	}
+		want, pos := expectation(f)
+		if pos == token.NoPos {
+			t.Fatalf("No WANT: comment in %s", filename)
+		}
 
-	res := rta.Analyze([]*ssa.Function{
-		mainPkg.Func("main"),
-		mainPkg.Func("init"),
-	}, true)
+		res := rta.Analyze([]*ssa.Function{
+			mainPkg.Func("main"),
+			mainPkg.Func("init"),
+		}, true)
 
-	if got := printResult(res, mainPkg.Pkg, "", "All calls"); got != want {
-		t.Errorf("%s: got:\n%s\nwant:\n%s",
-			prog.Fset.Position(pos), got, want)
+		if got := printResult(res, mainPkg.Pkg, "", "All calls"); got != want {
+			t.Errorf("%s: mode %q: got:\n%s\nwant:\n%s",
+				prog.Fset.Position(pos), mode, got, want)
+		}
 	}
 }
 
@@ -0,0 +1,475 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+// This file implements the persistent cache of Config.CacheDir.
+//
+// The cache holds two kinds of entries, each in a file named by the
+// hexadecimal SHA-256 hash of its key:
+//
+//   - the entries of CacheDir/meta hold the response of the driver to
+//     a query, keyed by the query and the configuration, along with
+//     the hashes of the files and directory listings on which the
+//     response depends, which must be unchanged for the entry to be
+//     used;
+//   - the entries of CacheDir/export hold the export data of packages
+//     type-checked from source without errors, keyed by the contents
+//     of their files and the keys of their dependencies, in the form
+//     of the compiler's object files; WriteExportData writes them for
+//     packages already loaded.
+//
+// Entries are written to temporary files that are then renamed, so
+// that concurrent processes may share a cache. Errors while reading
+// or writing entries are logged but otherwise ignored: the cache only
+// avoids repeating work.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/internal/packagesinternal"
+)
+
+// cacheVersion is part of every key, and changes when the format of
+// the entries does.
+const cacheVersion = "go/packages cache v2"
+
+// A metadataEntry is an entry of CacheDir/meta.
+type metadataEntry struct {
+	Response *driverResponse
+	Packages []*cachedPackage  // Response.Packages, with the fields that its JSON form lacks
+	Files    map[string]string // hash of the contents of each file, or "" if the file is missing
+	Dirs     map[string]string // hash of the listing of each directory, or "" if it is missing
+}
+
+// A cachedPackage holds the fields of a Package that its JSON form
+// does not.
+type cachedPackage struct {
+	IgnoredFiles []string                         `json:",omitempty"`
+	Module       *Module                          `json:",omitempty"`
+	ForTest      string                           `json:",omitempty"`
+	DepsErrors   []*packagesinternal.PackageError `json:",omitempty"`
+}
+
+// cachedDriver returns the response of the default driver for the
+// patterns from the cache if it is valid, and otherwise runs the
+// driver and records its response in the cache.
+func (ld *loader) cachedDriver(patterns ...string) (*driverResponse, error) {
+	if ld.Mode&NeedModuleGraph != 0 {
+		// The module graph is not cached, as its modules may form
+		// cycles, which JSON cannot represent.
+		return defaultDriver(&ld.Config, patterns...)
+	}
+	filename := ld.cacheFile("meta", ld.metadataKey(patterns))
+	if response := ld.readMetadata(filename); response != nil {
+		ld.Logf("using cached response for %v", patterns)
+		ld.stats.record(func(stats *LoadStats) { stats.CachedResponses++ })
+		return response, nil
+	}
+	response, err := defaultDriver(&ld.Config, patterns...)
+	if err != nil || response.NotHandled {
+		return response, err
+	}
+	for _, pkg := range response.Packages {
+		if len(pkg.Errors) > 0 || len(pkg.depsErrors) > 0 {
+			// The errors may be transient, such as those of the
+			// network, and are not cached.
+			return response, nil
+		}
+	}
+	if err := ld.writeMetadata(filename, patterns, response); err != nil {
+		ld.Logf("writing %s: %v", filename, err)
+	}
+	return response, nil
+}
+
+// metadataKey returns the key of the response of the driver to the
+// query for the patterns in the current configuration.
+func (ld *loader) metadataKey(patterns []string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\nmeta\n", cacheVersion)
+	fmt.Fprintf(h, "mode %d tests %t\n", ld.Mode, ld.Tests)
+	fmt.Fprintf(h, "dir %q\n", ld.Dir)
+	fmt.Fprintf(h, "modfile %q modflag %q\n", ld.modFile, ld.modFlag)
+	for _, v := range ld.Env {
+		fmt.Fprintf(h, "env %q\n", v)
+	}
+	for _, flag := range ld.BuildFlags {
+		fmt.Fprintf(h, "flag %q\n", flag)
+	}
+	for _, pattern := range patterns {
+		fmt.Fprintf(h, "pattern %q\n", pattern)
+	}
+	var overlays []string
+	for name := range ld.Overlay {
+		overlays = append(overlays, name)
+	}
+	sort.Strings(overlays)
+	for _, name := range overlays {
+		fmt.Fprintf(h, "overlay %q %x\n", name, sha256.Sum256(ld.Overlay[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readMetadata returns the response of the metadata entry in the
+// file, or nil if it is missing or invalid.
+func (ld *loader) readMetadata(filename string) *driverResponse {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	var entry metadataEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Response == nil || len(entry.Packages) != len(entry.Response.Packages) {
+		ld.Logf("ignoring malformed cache entry %s", filename)
+		return nil
+	}
+	for name, hash := range entry.Files {
+		if hashFile(name) != hash {
+			return nil
+		}
+	}
+	for dir, hash := range entry.Dirs {
+		if hashDir(dir) != hash {
+			return nil
+		}
+	}
+	for i, pkg := range entry.Response.Packages {
+		c := entry.Packages[i]
+		pkg.IgnoredFiles = c.IgnoredFiles
+		pkg.Module = c.Module
+		pkg.forTest = c.ForTest
+		pkg.depsErrors = c.DepsErrors
+	}
+	return entry.Response
+}
+
+// writeMetadata writes the response of the driver to the patterns,
+// with the hashes of the files and directories on which it depends,
+// to the file of its metadata entry.
+func (ld *loader) writeMetadata(filename string, patterns []string, response *driverResponse) error {
+	entry := &metadataEntry{
+		Response: response,
+		Files:    make(map[string]string),
+		Dirs:     make(map[string]string),
+	}
+	addFile := func(name string) {
+		if name != "" {
+			entry.Files[name] = hashFile(name)
+		}
+	}
+	addDir := func(dir string) {
+		if _, ok := entry.Dirs[dir]; !ok {
+			entry.Dirs[dir] = hashDir(dir)
+		}
+	}
+	for _, pkg := range response.Packages {
+		entry.Packages = append(entry.Packages, &cachedPackage{
+			IgnoredFiles: pkg.IgnoredFiles,
+			Module:       pkg.Module,
+			ForTest:      pkg.forTest,
+			DepsErrors:   pkg.depsErrors,
+		})
+		for _, files := range [][]string{pkg.GoFiles, pkg.CompiledGoFiles, pkg.OtherFiles, pkg.EmbedFiles, pkg.IgnoredFiles} {
+			for _, name := range files {
+				addFile(name)
+			}
+		}
+		addFile(pkg.ExportFile)
+		if pkg.Module != nil {
+			addFile(pkg.Module.GoMod)
+		}
+		// New files in the directory of a package may change it.
+		for _, name := range pkg.GoFiles {
+			addDir(filepath.Dir(name))
+		}
+		for _, name := range pkg.IgnoredFiles {
+			addDir(filepath.Dir(name))
+		}
+	}
+
+	// The go.mod, go.sum and go.work files of the enclosing module
+	// or workspace determine the packages of the query.
+	for dir := ld.Dir; ; {
+		for _, name := range []string{"go.mod", "go.sum", "go.work", "go.work.sum"} {
+			addFile(filepath.Join(dir, name))
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	// New directories may match a pattern with a wildcard.
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "...") {
+			filepath.Walk(ld.Dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || !info.IsDir() {
+					return nil
+				}
+				if path != ld.Dir {
+					if name := info.Name(); strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" {
+						return filepath.SkipDir
+					}
+				}
+				addDir(path)
+				return nil
+			})
+			break
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeCacheFile(filename, data)
+}
+
+// needSyntax reports whether the syntax of the package is needed, as
+// opposed to just its types.
+func (ld *loader) needSyntax(lpkg *loaderPackage) bool {
+	return ld.Mode&(NeedSyntax|NeedTypesInfo) != 0 && (lpkg.initial || ld.Mode&NeedDeps != 0)
+}
+
+// setExportKey sets the key of the export data of the package, once
+// its dependencies are loaded.
+func (ld *loader) setExportKey(lpkg *loaderPackage) {
+	if lpkg.exportKey != "" {
+		return
+	}
+	lpkg.exportKey = ld.keyInputs.exportKey(lpkg.Package, lpkg.needsrc, func(imp *Package) string {
+		return ld.pkgs[imp.ID].exportKey
+	})
+}
+
+// exportKeyInputs holds the settings of a load on which the keys of the
+// export data of its packages depend.
+type exportKeyInputs struct {
+	sizes   types.Sizes
+	cgo     bool // typecheckCgo mode
+	overlay map[string][]byte
+}
+
+// exportKey returns the key of the export data of the package: that of
+// a package loaded from source depends on the contents of its files and
+// on the keys of its dependencies, which importKey returns, and that of
+// a package loaded from export data on the contents of its export data.
+func (k *exportKeyInputs) exportKey(pkg *Package, fromSource bool, importKey func(imp *Package) string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\nexport\n", cacheVersion)
+	fmt.Fprintf(h, "id %q path %q name %q\n", pkg.ID, pkg.PkgPath, pkg.Name)
+	fmt.Fprintf(h, "sizes %v cgo %t\n", k.sizes, k.cgo)
+	if !fromSource {
+		fmt.Fprintf(h, "exportfile %s\n", hashFile(pkg.ExportFile))
+	} else {
+		for _, name := range pkg.CompiledGoFiles {
+			hash, ok := pkg.fileHashes[name]
+			if !ok {
+				hash = hashFile(name)
+			}
+			for f, contents := range k.overlay {
+				if sameFile(f, name) {
+					hash = fmt.Sprintf("%x", sha256.Sum256(contents))
+				}
+			}
+			fmt.Fprintf(h, "file %q %s\n", filepath.Base(name), hash)
+		}
+		var paths []string
+		for path := range pkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			fmt.Fprintf(h, "import %q %s\n", path, importKey(pkg.Imports[path]))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadFromCache loads the types of the package from its export data
+// in the cache, and reports whether it succeeded.
+func (ld *loader) loadFromCache(lpkg *loaderPackage) bool {
+	for _, imp := range lpkg.Imports {
+		if ld.pkgs[imp.ID].IllTyped {
+			return false // the package was not cached
+		}
+	}
+	filename := ld.cacheFile("export", lpkg.exportKey)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+
+	ld.exportMu.Lock()
+	defer ld.exportMu.Unlock()
+
+	r, err := gcexportdata.NewReader(bytes.NewReader(data))
+	var tpkg *types.Package
+	if err == nil {
+		tpkg, err = ld.readExportData(lpkg, r)
+	}
+	if err != nil {
+		ld.Logf("reading %s: %v", filename, err)
+		// The package may have been modified, so start afresh.
+		lpkg.Types = types.NewPackage(lpkg.PkgPath, lpkg.Name)
+		return false
+	}
+	lpkg.Types = tpkg
+	lpkg.IllTyped = false
+	ld.Logf("loaded types of %s from cache", lpkg.ID)
+	return true
+}
+
+// writeToCache writes the export data of the package to the cache.
+func (ld *loader) writeToCache(lpkg *loaderPackage) {
+	filename := ld.cacheFile("export", lpkg.exportKey)
+	if _, err := os.Stat(filename); err == nil {
+		return // already cached
+	}
+
+	// Export data reads the types of the dependencies too.
+	ld.exportMu.Lock()
+	data, err := exportData(ld.Fset, lpkg.Types)
+	ld.exportMu.Unlock()
+
+	if err == nil {
+		err = writeCacheFile(filename, data)
+	}
+	if err != nil {
+		ld.Logf("writing export data of %s to cache: %v", lpkg.ID, err)
+	}
+}
+
+// exportData returns the export data of the package, in the form of an
+// object file of the compiler, as gcexportdata.NewReader expects.
+func exportData(fset *token.FileSet, pkg *types.Package) ([]byte, error) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	fmt.Fprintf(w, "go object %s\n$$B\n", cacheVersion)
+	err := gcexportdata.Write(w, fset, pkg)
+	if err == nil {
+		err = w.Flush()
+	}
+	return buf.Bytes(), err
+}
+
+// WriteExportData writes the export data of the packages of the import
+// graph whose roots are pkgs that were type-checked from source without
+// errors to the cache directory dir, in the layout of Config.CacheDir,
+// so as to prime the cache of later loads. It returns the first error
+// of writing a file, if any.
+//
+// A later load with dir as its CacheDir reads the types of the packages
+// from the cache, instead of type-checking them, when it needs their
+// types but not their syntax, and their files and dependencies are
+// unchanged. It reports the file of the export data as the ExportFile
+// of such a package if NeedExportFile is set and the driver provided
+// none: the files are in the format of the compiler's export data that
+// gcexportdata.NewReader accepts. A long-running service may thus keep
+// the export data of the packages it loads across restarts.
+//
+// The packages must have been loaded with NeedTypes and NeedImports,
+// and with NeedDeps for their dependencies to be written too.
+func WriteExportData(dir string, pkgs []*Package) error {
+	keys := make(map[*Package]string)
+	var key func(pkg *Package) string
+	key = func(pkg *Package) string {
+		if pkg.keyInputs == nil {
+			return "" // unsafe, or no types
+		}
+		k, ok := keys[pkg]
+		if !ok {
+			k = pkg.keyInputs.exportKey(pkg, pkg.fromSource, key)
+			keys[pkg] = k
+		}
+		return k
+	}
+
+	var firstErr error
+	Visit(pkgs, nil, func(pkg *Package) {
+		if firstErr != nil || !pkg.fromSource || pkg.IllTyped || pkg.Types == nil || !pkg.Types.Complete() {
+			return
+		}
+		filename := filepath.Join(dir, "export", key(pkg))
+		if _, err := os.Stat(filename); err == nil {
+			return // already cached
+		}
+		data, err := exportData(pkg.Fset, pkg.Types)
+		if err == nil {
+			err = writeCacheFile(filename, data)
+		}
+		if err != nil {
+			firstErr = fmt.Errorf("writing export data of %s: %v", pkg.ID, err)
+		}
+	})
+	return firstErr
+}
+
+// cacheFile returns the name of the file of the entry of the given
+// kind and key.
+func (ld *loader) cacheFile(kind, key string) string {
+	return filepath.Join(ld.CacheDir, kind, key)
+}
+
+// writeCacheFile atomically writes the data to the file, creating its
+// directory if needed.
+func writeCacheFile(filename string, data []byte) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// hashFile returns the hash of the contents of the file, or "" if it
+// cannot be read.
+func hashFile(name string) string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// hashDir returns the hash of the names of the entries of the
+// directory, or "" if it cannot be read.
+func hashDir(dir string) string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	for _, e := range entries { // sorted by name
+		fmt.Fprintf(h, "%q %t\n", e.Name(), e.IsDir())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
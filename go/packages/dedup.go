@@ -0,0 +1,133 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"sync"
+)
+
+// A sharedArtifacts holds the results of loading packages from source,
+// by which the loaders of LoadConfigs share those of identical packages:
+// a package whose build inputs are the same in several configurations,
+// such as one without build constraints whose dependencies are also the
+// same, is parsed and type-checked once.
+//
+// The results of a package are keyed by its export key, which covers its
+// files and those of its dependencies, and by the settings of the loader
+// that affect them. Only packages whose imports are all shared take part,
+// so that the types of a shared package refer to the types of the same
+// dependencies in each configuration.
+type sharedArtifacts struct {
+	mu        sync.Mutex
+	artifacts map[string]*artifact
+}
+
+// An artifact is the result of loading a package from source.
+type artifact struct {
+	ready chan struct{} // closed once the package is loaded
+	ok    bool          // the package was loaded, and not cancelled
+
+	types             *types.Package
+	syntax            []*ast.File
+	typesInfo         *types.Info
+	typesSizes        types.Sizes
+	errors            []Error // errors of parsing and type-checking
+	illTyped          bool
+	ignoredFuncBodies bool
+	parseMode         parser.Mode
+}
+
+// load loads the package, or shares the results of loading an identical
+// package in another configuration.
+func (s *sharedArtifacts) load(ld *loader, lpkg *loaderPackage) {
+	if lpkg.PkgPath == "unsafe" {
+		ld.loadPackage(lpkg)
+		lpkg.shared = true // types.Unsafe is the same in all configurations
+		return
+	}
+	if !lpkg.needsrc {
+		ld.loadPackage(lpkg)
+		return
+	}
+	for _, imp := range lpkg.Imports {
+		if !ld.pkgs[imp.ID].shared {
+			ld.loadPackage(lpkg)
+			return
+		}
+	}
+
+	key := ld.sharedKey(lpkg)
+	s.mu.Lock()
+	a, ok := s.artifacts[key]
+	if !ok {
+		a = &artifact{ready: make(chan struct{})}
+		s.artifacts[key] = a
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		defer close(a.ready)
+		nerrs := len(lpkg.Errors)
+		ld.loadPackage(lpkg)
+		if lpkg.Cancelled {
+			return
+		}
+		*a = artifact{
+			ready:             a.ready,
+			ok:                true,
+			types:             lpkg.Types,
+			syntax:            lpkg.Syntax,
+			typesInfo:         lpkg.TypesInfo,
+			typesSizes:        lpkg.TypesSizes,
+			errors:            lpkg.Errors[nerrs:len(lpkg.Errors):len(lpkg.Errors)],
+			illTyped:          lpkg.IllTyped,
+			ignoredFuncBodies: lpkg.IgnoredFuncBodies,
+			parseMode:         lpkg.parseMode,
+		}
+		lpkg.shared = true
+		return
+	}
+
+	<-a.ready
+	if !a.ok {
+		ld.loadPackage(lpkg) // the other load was cancelled
+		return
+	}
+	lpkg.Types = a.types
+	lpkg.Fset = ld.Fset
+	lpkg.Syntax = a.syntax
+	lpkg.TypesInfo = a.typesInfo
+	lpkg.TypesSizes = a.typesSizes
+	lpkg.Errors = append(lpkg.Errors, a.errors...)
+	lpkg.IllTyped = a.illTyped
+	lpkg.IgnoredFuncBodies = a.ignoredFuncBodies
+	lpkg.parseMode = a.parseMode
+	lpkg.importErrors = nil
+	lpkg.keyInputs = ld.keyInputs
+	lpkg.fromSource = true
+	lpkg.shared = true
+	ld.stats.record(func(stats *LoadStats) { stats.SharedPackages++ })
+}
+
+// sharedKey returns the key of the results of loading the package from
+// source, once its dependencies are loaded.
+func (ld *loader) sharedKey(lpkg *loaderPackage) string {
+	ld.setExportKey(lpkg)
+	h := sha256.New()
+	fmt.Fprintf(h, "export %s\n", lpkg.exportKey)
+	fmt.Fprintf(h, "mode %d needtypes %t initial %t\n", ld.Mode, lpkg.needtypes, lpkg.initial)
+	fmt.Fprintf(h, "ignoredepfuncbodies %t parsemode %d\n", ld.ignoreDepFuncBodies, ld.parseMode(lpkg))
+	// The errors of the metadata make the package ill-typed.
+	for _, err := range lpkg.Errors {
+		fmt.Fprintf(h, "error %q\n", err.Error())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
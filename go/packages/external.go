@@ -13,6 +13,7 @@ import (
 	"encoding/json"
 	"fmt"
 	exec "golang.org/x/sys/execabs"
+	"io"
 	"os"
 	"strings"
 )
@@ -27,6 +28,34 @@ import (
 // documentation in doc.go for the full description of the patterns that need to be supported.
 // A driver receives as a JSON-serialized driverRequest struct in standard input and will
 // produce a JSON-serialized driverResponse (see definition in packages.go) in its standard output.
+//
+// Version 2 of the protocol adds a handshake: the request holds the version of the protocol
+// and the optional features, or capabilities, that go/packages supports, and the response
+// holds the version that the driver follows, no greater than that of the request, and the
+// capabilities that it uses. A response without a version follows version 1, without
+// capabilities. The capabilities are:
+//
+//   - "overlay": the driver applies the overlay of the request to the metadata of the
+//     packages, such as their files and imports. Otherwise an overlay affects only the
+//     syntax and types of the packages.
+//   - "module": the packages of the response have their Module.
+//   - "filehashes": the packages of the response have the FileHashes of their files, which
+//     identify their contents in place of reading them for Config.CacheDir.
+//   - "stream": the standard output of the driver is a stream of JSON-serialized
+//     driverResponses, the first of which declares the capability. go/packages decodes them
+//     as they come, and concatenates their Roots and Packages.
+
+// The version of the driver protocol and its capabilities.
+const (
+	driverProtocolVersion = 2
+
+	overlayCapability    = "overlay"
+	moduleCapability     = "module"
+	fileHashesCapability = "filehashes"
+	streamCapability     = "stream"
+)
+
+var driverCapabilities = []string{overlayCapability, moduleCapability, fileHashesCapability, streamCapability}
 
 // driverRequest is used to provide the portion of Load's Config that is needed by a driver.
 type driverRequest struct {
@@ -40,6 +69,10 @@ type driverRequest struct {
 	// Overlay maps file paths (relative to the driver's working directory) to the byte contents
 	// of overlay files.
 	Overlay map[string][]byte `json:"overlay"`
+	// Version is the version of the driver protocol that the request follows.
+	Version int `json:"version"`
+	// Capabilities lists the capabilities of the protocol that go/packages supports.
+	Capabilities []string `json:"capabilities"`
 }
 
 // findExternalDriver returns the file path of a tool that supplies
@@ -66,36 +99,86 @@ func findExternalDriver(cfg *Config) driver {
 	}
 	return func(cfg *Config, words ...string) (*driverResponse, error) {
 		req, err := json.Marshal(driverRequest{
-			Mode:       cfg.Mode,
-			Env:        cfg.Env,
-			BuildFlags: cfg.BuildFlags,
-			Tests:      cfg.Tests,
-			Overlay:    cfg.Overlay,
+			Mode:         cfg.Mode,
+			Env:          cfg.Env,
+			BuildFlags:   cfg.BuildFlags,
+			Tests:        cfg.Tests,
+			Overlay:      cfg.Overlay,
+			Version:      driverProtocolVersion,
+			Capabilities: driverCapabilities,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to encode message to driver tool: %v", err)
 		}
 
-		buf := new(bytes.Buffer)
 		stderr := new(bytes.Buffer)
 		cmd := exec.CommandContext(cfg.Context, tool, words...)
 		cmd.Dir = cfg.Dir
 		cmd.Env = cfg.Env
 		cmd.Stdin = bytes.NewReader(req)
-		cmd.Stdout = buf
 		cmd.Stderr = stderr
-
-		if err := cmd.Run(); err != nil {
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("%v: %v", tool, err)
+		}
+		// Decode the response as the driver writes it, but read its
+		// output to the end, lest the driver block.
+		response, decodeErr := decodeDriverResponse(stdout)
+		io.Copy(io.Discard, stdout)
+		if err := cmd.Wait(); err != nil {
 			return nil, fmt.Errorf("%v: %v: %s", tool, err, cmd.Stderr)
 		}
 		if len(stderr.Bytes()) != 0 && os.Getenv("GOPACKAGESPRINTDRIVERERRORS") != "" {
 			fmt.Fprintf(os.Stderr, "%s stderr: <<%s>>\n", cmdDebugStr(cmd), stderr)
 		}
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		if len(cfg.Overlay) > 0 && !response.hasCapability(overlayCapability) {
+			cfg.Logf("%s does not apply overlays to package metadata", tool)
+		}
+		return response, nil
+	}
+}
 
-		var response driverResponse
-		if err := json.Unmarshal(buf.Bytes(), &response); err != nil {
+// decodeDriverResponse decodes the response of an external driver, in
+// any version of the protocol.
+func decodeDriverResponse(r io.Reader) (*driverResponse, error) {
+	dec := json.NewDecoder(r)
+	var response driverResponse
+	if err := dec.Decode(&response); err != nil {
+		return nil, err
+	}
+	if response.Version > driverProtocolVersion {
+		return nil, fmt.Errorf("unsupported version %d of the driver protocol", response.Version)
+	}
+	if response.Version < 2 {
+		response.Capabilities = nil
+	}
+	if !response.hasCapability(streamCapability) {
+		return &response, nil
+	}
+	for dec.More() {
+		var next driverResponse
+		if err := dec.Decode(&next); err != nil {
 			return nil, err
 		}
-		return &response, nil
+		response.Roots = append(response.Roots, next.Roots...)
+		response.Packages = append(response.Packages, next.Packages...)
+	}
+	return &response, nil
+}
+
+// hasCapability reports whether the driver uses the capability of the
+// protocol in the response.
+func (response *driverResponse) hasCapability(capability string) bool {
+	for _, c := range response.Capabilities {
+		if c == capability {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,127 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A GoEnv specifies settings of the environment of the go command for a
+// load, which take precedence over those of Config.Env and Config.Dir,
+// so that a service may make its loads hermetic and reproducible,
+// whatever the environment of its process. Empty fields leave the
+// environment unchanged.
+//
+// The settings are validated before the go command runs: Load and
+// the other functions that load packages return an error for invalid
+// settings.
+type GoEnv struct {
+	// Dir, if not empty, is the working directory of the go command,
+	// instead of Config.Dir. It must be the absolute name of an
+	// existing directory.
+	Dir string
+
+	// GOFLAGS are the flags of the GOFLAGS variable, such as
+	// "-mod=readonly" or "-tags=purego". Each must begin with a dash,
+	// and contain no spaces.
+	GOFLAGS []string
+
+	// GOPROXY is the list of module proxies, separated by commas or
+	// pipes, each of which must be a URL, "direct" or "off".
+	GOPROXY string
+
+	// GOCACHE and GOMODCACHE are the directories of the build cache and
+	// of the module cache. They must be absolute.
+	GOCACHE    string
+	GOMODCACHE string
+
+	// Offline forbids access to the network: modules and toolchains are
+	// not downloaded, so that a load that needs a module missing from
+	// the module cache fails, as GOPROXY=off makes it. Offline is
+	// incompatible with a GOPROXY other than "off".
+	Offline bool
+}
+
+// validate returns an error if the settings are invalid.
+func (e *GoEnv) validate() error {
+	if e.Dir != "" {
+		if !filepath.IsAbs(e.Dir) {
+			return fmt.Errorf("invalid GoEnv: Dir %q is not an absolute path", e.Dir)
+		}
+		if info, err := os.Stat(e.Dir); err != nil {
+			return fmt.Errorf("invalid GoEnv: %v", err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("invalid GoEnv: Dir %q is not a directory", e.Dir)
+		}
+	}
+	for _, flag := range e.GOFLAGS {
+		if !strings.HasPrefix(flag, "-") || strings.ContainsAny(flag, " \t\n") {
+			return fmt.Errorf("invalid GoEnv: GOFLAGS element %q is not a flag without spaces", flag)
+		}
+	}
+	if e.GOPROXY != "" {
+		for _, proxy := range strings.FieldsFunc(e.GOPROXY, func(r rune) bool { return r == ',' || r == '|' }) {
+			if proxy == "direct" || proxy == "off" {
+				continue
+			}
+			if u, err := url.Parse(proxy); err != nil || u.Scheme == "" {
+				return fmt.Errorf("invalid GoEnv: GOPROXY element %q is not a URL, \"direct\" or \"off\"", proxy)
+			}
+		}
+		if e.Offline && e.GOPROXY != "off" {
+			return fmt.Errorf("invalid GoEnv: GOPROXY %q is incompatible with Offline", e.GOPROXY)
+		}
+	}
+	for _, v := range []struct{ name, dir string }{{"GOCACHE", e.GOCACHE}, {"GOMODCACHE", e.GOMODCACHE}} {
+		if v.dir != "" && !filepath.IsAbs(v.dir) {
+			return fmt.Errorf("invalid GoEnv: %s %q is not an absolute path", v.name, v.dir)
+		}
+	}
+	return nil
+}
+
+// environ returns the environment env with the settings added, which
+// take precedence as they come last.
+func (e *GoEnv) environ(env []string) []string {
+	env = append([]string{}, env...)
+	if e.GOFLAGS != nil {
+		env = append(env, "GOFLAGS="+strings.Join(e.GOFLAGS, " "))
+	}
+	if e.GOPROXY != "" {
+		env = append(env, "GOPROXY="+e.GOPROXY)
+	}
+	if e.GOCACHE != "" {
+		env = append(env, "GOCACHE="+e.GOCACHE)
+	}
+	if e.GOMODCACHE != "" {
+		env = append(env, "GOMODCACHE="+e.GOMODCACHE)
+	}
+	if e.Offline {
+		// The local toolchain is used whatever the go.mod file asks
+		// for, rather than one from the network.
+		env = append(env, "GOPROXY=off", "GOTOOLCHAIN=local")
+	}
+	return env
+}
+
+// applyGoEnv applies the settings of Config.GoEnv, if any, to the
+// environment and working directory of the loader, and records the
+// error of invalid settings, which runDriver returns.
+func (ld *loader) applyGoEnv() {
+	if ld.GoEnv == nil {
+		return
+	}
+	if ld.configErr = ld.GoEnv.validate(); ld.configErr != nil {
+		return
+	}
+	ld.Config.Env = ld.GoEnv.environ(ld.Config.Env)
+	if ld.GoEnv.Dir != "" {
+		ld.Dir = ld.GoEnv.Dir
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 
 	exec "golang.org/x/sys/execabs"
@@ -103,7 +104,7 @@ type golistState struct {
 func (state *golistState) getEnv() (map[string]string, error) {
 	state.envOnce.Do(func() {
 		var b *bytes.Buffer
-		b, state.goEnvError = state.invokeGo("env", "-json", "GOMOD", "GOPATH")
+		b, state.goEnvError = state.invokeGo("env", "-json", "GOMOD", "GOPATH", "GOWORK")
 		if state.goEnvError != nil {
 			return
 		}
@@ -196,6 +197,10 @@ extractQueries:
 	// patterns also requires a go list call, since it's the equivalent of
 	// ".".
 	if len(restPatterns) > 0 || len(patterns) == 0 {
+		restPatterns, err := state.workspacePatterns(restPatterns)
+		if err != nil {
+			return nil, err
+		}
 		dr, err := state.createDriverResponse(restPatterns...)
 		if err != nil {
 			return nil, err
@@ -265,6 +270,12 @@ extractQueries:
 		}
 	}
 
+	if cfg.Mode&NeedModuleGraph != 0 {
+		if err := state.addModuleGraph(response.dr); err != nil {
+			return nil, err
+		}
+	}
+
 	sizeswg.Wait()
 	if sizeserr != nil {
 		return nil, sizeserr
@@ -272,6 +283,80 @@ extractQueries:
 	return response.dr, nil
 }
 
+// addModuleGraph replaces the modules of the packages of the response
+// by the nodes of the module graph of the main modules, in which the
+// Require field of each module lists those that it requires.
+func (state *golistState) addModuleGraph(response *driverResponse) error {
+	env, err := state.getEnv()
+	if err != nil {
+		return err
+	}
+	if (env["GOMOD"] == "" || env["GOMOD"] == os.DevNull) && (env["GOWORK"] == "" || env["GOWORK"] == "off") {
+		return nil // not in module mode
+	}
+
+	buf, err := state.invokeGo("list", "-m", "-json", "-e", "all")
+	if err != nil {
+		return err
+	}
+	modules := make(map[string]*Module) // by path@version, or path for main modules
+	for dec := json.NewDecoder(buf); dec.More(); {
+		m := new(Module)
+		if err := dec.Decode(m); err != nil {
+			return fmt.Errorf("JSON decoding failed: %v", err)
+		}
+		modules[moduleKey(m.Path, m.Version)] = m
+	}
+
+	buf, err = state.invokeGo("mod", "graph")
+	if err != nil {
+		return err
+	}
+	node := func(key string) *Module {
+		path, version := key, ""
+		if i := strings.Index(key, "@"); i >= 0 {
+			path, version = key[:i], key[i+len("@"):]
+		}
+		if path == "go" || path == "toolchain" {
+			return nil // not a module
+		}
+		m := modules[key]
+		if m == nil {
+			// A version of a module that is required, but not selected.
+			m = &Module{Path: path, Version: version}
+			modules[key] = m
+		}
+		return m
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if from, to := node(fields[0]), node(fields[1]); from != nil && to != nil {
+			from.Require = append(from.Require, to)
+		}
+	}
+
+	for _, pkg := range response.Packages {
+		if pkg.Module == nil {
+			continue
+		}
+		if m := modules[moduleKey(pkg.Module.Path, pkg.Module.Version)]; m != nil {
+			pkg.Module = m
+		}
+	}
+	return nil
+}
+
+// moduleKey returns the key of a module in the output of go mod graph.
+func moduleKey(path, version string) string {
+	if version == "" {
+		return path
+	}
+	return path + "@" + version
+}
+
 func (state *golistState) addNeededOverlayPackages(response *responseDeduper, pkgs []string) error {
 	if len(pkgs) == 0 {
 		return nil
@@ -548,9 +633,10 @@ func (state *golistState) createDriverResponse(words ...string) (*driverResponse
 						importingPkg = old.Error.ImportStack[len(old.Error.ImportStack)-2]
 					}
 					additionalErrors[importingPkg] = append(additionalErrors[importingPkg], Error{
-						Pos:  old.Error.Pos,
-						Msg:  old.Error.Err,
-						Kind: ListError,
+						Pos:         old.Error.Pos,
+						Msg:         old.Error.Err,
+						Kind:        ListError,
+						ImportStack: old.Error.ImportStack,
 					})
 				}
 			}
@@ -710,11 +796,17 @@ func (state *golistState) createDriverResponse(words ...string) (*driverResponse
 			if msg == "import cycle not allowed" && len(p.Error.ImportStack) != 0 {
 				msg += fmt.Sprintf(": import stack: %v", p.Error.ImportStack)
 			}
-			pkg.Errors = append(pkg.Errors, Error{
-				Pos:  p.Error.Pos,
-				Msg:  msg,
-				Kind: ListError,
-			})
+			listErr := Error{
+				Pos:         p.Error.Pos,
+				Msg:         msg,
+				Kind:        ListError,
+				Code:        errorCode(msg),
+				ImportStack: p.Error.ImportStack,
+			}
+			if p.Module != nil {
+				listErr.Module = p.Module.Path
+			}
+			pkg.Errors = append(pkg.Errors, listErr)
 		}
 
 		pkgs[pkg.ID] = pkg
@@ -733,6 +825,29 @@ func (state *golistState) createDriverResponse(words ...string) (*driverResponse
 	return &response, nil
 }
 
+// errorCode returns the code of an error of the go command, from its
+// message.
+func errorCode(msg string) ErrorCode {
+	switch {
+	case strings.Contains(msg, "import cycle not allowed"):
+		return ImportCycle
+	case strings.Contains(msg, "build constraints exclude all Go files"):
+		return BuildConstraintsExcluded
+	case strings.Contains(msg, "go.mod"),
+		strings.Contains(msg, "go.sum"),
+		strings.Contains(msg, "go.work"):
+		return ModFileError
+	case strings.Contains(msg, "cannot find package"),
+		strings.Contains(msg, "cannot find module providing package"),
+		strings.Contains(msg, "no required module provides package"),
+		strings.Contains(msg, "is not in GOROOT"),
+		strings.Contains(msg, "is not in std"),
+		strings.Contains(msg, "does not contain package"):
+		return MissingDependency
+	}
+	return UnknownCode
+}
+
 func (state *golistState) shouldAddFilenameFromError(p *jsonPackage) bool {
 	if len(p.GoFiles) > 0 || len(p.CompiledGoFiles) > 0 {
 		return false
@@ -867,7 +982,7 @@ func jsonFlag(cfg *Config, goVersion int) string {
 	if cfg.Mode&needInternalDepsErrors != 0 {
 		addFields("DepsErrors")
 	}
-	if cfg.Mode&NeedModule != 0 {
+	if cfg.Mode&(NeedModule|NeedModuleGraph) != 0 {
 		addFields("Module")
 	}
 	if cfg.Mode&NeedEmbedFiles != 0 {
@@ -936,13 +1051,25 @@ func (state *golistState) invokeGo(verb string, args ...string) (*bytes.Buffer,
 			inv.Overlay = filename
 		}
 	}
+	if verb != "env" && verb != "version" {
+		cleanup, err := state.writeModOverlays(&inv)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+	}
 	inv.Verb = verb
 	inv.Args = args
 	gocmdRunner := cfg.gocmdRunner
 	if gocmdRunner == nil {
 		gocmdRunner = &gocommand.Runner{}
 	}
+	start := cfg.stats.now()
 	stdout, stderr, friendlyErr, err := gocmdRunner.RunRaw(cfg.Context, inv)
+	cfg.stats.record(func(stats *LoadStats) {
+		stats.GoCommandCalls++
+		stats.GoCommandTime += time.Since(start)
+	})
 	if err != nil {
 		// Check for 'go' executable not being found.
 		if ee, ok := err.(*exec.Error); ok && ee.Err == exec.ErrNotFound {
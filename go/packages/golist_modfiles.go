@@ -0,0 +1,235 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/gocommand"
+)
+
+// writeModOverlays makes the invocation honor the overlaid contents of
+// the go.mod and go.sum files of the main module, or of the go.work and
+// go.work.sum files of the workspace, which the go command does not read
+// from the overlay of its -overlay flag. It writes their contents to a
+// temporary directory, which the invocation uses with the -modfile flag
+// or the GOWORK environment variable, and which cleanup removes.
+//
+// The go.mod file of the main module must exist on disk, as the go
+// command finds the module root from it, but a go.work file may exist
+// only in the overlay. The go.mod files of the modules of a workspace
+// are read from disk. A -modfile flag of the invocation or of GOFLAGS
+// takes precedence over the overlay.
+func (state *golistState) writeModOverlays(inv *gocommand.Invocation) (cleanup func(), err error) {
+	cleanup = func() {}
+	if len(state.cfg.Overlay) == 0 {
+		return cleanup, nil
+	}
+	overlay := make(map[string][]byte, len(state.cfg.Overlay))
+	for name, contents := range state.cfg.Overlay {
+		overlay[state.absPath(name)] = contents
+	}
+	getenv := func(key string) string {
+		if !inv.CleanEnv {
+			return os.Getenv(key)
+		}
+		value := ""
+		for _, kv := range inv.Env {
+			if strings.HasPrefix(kv, key+"=") {
+				value = kv[len(key+"="):] // the last one wins
+			}
+		}
+		return value
+	}
+	if getenv("GO111MODULE") == "off" {
+		return cleanup, nil
+	}
+
+	// Whether a file exists, in the overlay or on disk.
+	exists := func(name string) bool {
+		if _, ok := overlay[name]; ok {
+			return true
+		}
+		info, err := os.Stat(name)
+		return err == nil && !info.IsDir()
+	}
+	// The contents of a file, from the overlay or from disk.
+	read := func(name string) ([]byte, bool, error) {
+		if contents, ok := overlay[name]; ok {
+			return contents, true, nil
+		}
+		contents, err := ioutil.ReadFile(name)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return contents, false, err
+	}
+	// The temporary directory, created on first use.
+	var dir string
+	write := func(name string, contents []byte) (string, error) {
+		if dir == "" {
+			tmp, err := ioutil.TempDir("", "gopackages-modfiles-*")
+			if err != nil {
+				return "", err
+			}
+			dir = tmp
+			cleanup = func() { os.RemoveAll(tmp) }
+		}
+		filename := filepath.Join(dir, name)
+		return filename, ioutil.WriteFile(filename, contents, 0666)
+	}
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	// A workspace overrides the main module, and rules out -modfile.
+	workFile := getenv("GOWORK")
+	if workFile == "" {
+		workFile = findUp(state.absPath("."), "go.work", exists)
+	}
+	if workFile != "" && workFile != "off" {
+		work, workOverlaid, err := read(workFile)
+		if err != nil {
+			return cleanup, err
+		}
+		sum, sumOverlaid, err := read(workFile + ".sum")
+		if err != nil {
+			return cleanup, err
+		}
+		if !workOverlaid && !sumOverlaid {
+			return cleanup, nil
+		}
+		work, err = absWorkFile(workFile, work)
+		if err != nil {
+			return cleanup, err
+		}
+		filename, err := write("go.work", work)
+		if err != nil {
+			return cleanup, err
+		}
+		if sum != nil {
+			if _, err := write("go.work.sum", sum); err != nil {
+				return cleanup, err
+			}
+		}
+		env := inv.Env
+		if !inv.CleanEnv {
+			env = os.Environ()
+		}
+		inv.Env = append(append([]string{}, env...), "GOWORK="+filename)
+		inv.CleanEnv = true
+		return cleanup, nil
+	}
+
+	if inv.ModFile != "" || hasModFileFlag(getenv("GOFLAGS")) {
+		return cleanup, nil // the caller chose the go.mod file
+	}
+	modFile := findUp(state.absPath("."), "go.mod", exists)
+	if modFile == "" {
+		return cleanup, nil
+	}
+	mod, modOverlaid, err := read(modFile)
+	if err != nil {
+		return cleanup, err
+	}
+	sum, sumOverlaid, err := read(strings.TrimSuffix(modFile, ".mod") + ".sum")
+	if err != nil {
+		return cleanup, err
+	}
+	if !modOverlaid && !sumOverlaid {
+		return cleanup, nil
+	}
+	filename, err := write("go.mod", mod)
+	if err != nil {
+		return cleanup, err
+	}
+	if sum != nil {
+		if _, err := write("go.sum", sum); err != nil {
+			return cleanup, err
+		}
+	}
+	inv.ModFile = filename
+	return cleanup, nil
+}
+
+// absPath returns the absolute form of a file name, relative to the
+// directory of the configuration.
+func (state *golistState) absPath(name string) string {
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(state.cfg.Dir, name)
+	}
+	return filepath.Clean(name)
+}
+
+// findUp returns the name of the file with the given base name in dir
+// or its closest parent directory that has one, or "".
+func findUp(dir, base string, exists func(string) bool) string {
+	for {
+		if name := filepath.Join(dir, base); exists(name) {
+			return name
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// hasModFileFlag reports whether the flags of GOFLAGS, a
+// space-separated list of -flag=value settings, include -modfile.
+func hasModFileFlag(goflags string) bool {
+	for _, f := range strings.Fields(goflags) {
+		f = strings.TrimPrefix(strings.TrimPrefix(f, "-"), "-")
+		if strings.HasPrefix(f, "modfile=") {
+			return true
+		}
+	}
+	return false
+}
+
+// absWorkFile returns the contents of a go.work file in which the
+// relative paths of its use and replace directives are made absolute,
+// so that it may be moved from its directory.
+func absWorkFile(filename string, data []byte) ([]byte, error) {
+	work, err := modfile.ParseWork(filename, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	abs := func(path string) (string, bool) {
+		if filepath.IsAbs(path) {
+			return path, false
+		}
+		return filepath.Join(filepath.Dir(filename), path), true
+	}
+	for _, use := range work.Use {
+		if use.Path == "" {
+			continue // dropped as a duplicate
+		}
+		if path, ok := abs(use.Path); ok {
+			modulePath := use.ModulePath
+			work.DropUse(use.Path)
+			work.AddNewUse(path, modulePath)
+		}
+	}
+	for _, r := range work.Replace {
+		if r.New.Version != "" {
+			continue // not a directory
+		}
+		if path, ok := abs(r.New.Path); ok {
+			if err := work.AddReplace(r.Old.Path, r.Old.Version, path, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	work.Cleanup()
+	return modfile.Format(work.Syntax), nil
+}
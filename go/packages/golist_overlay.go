@@ -363,8 +363,9 @@ func (state *golistState) determineRootDirsModules() (map[string]string, error)
 				return nil, err
 			}
 			modules[absDir] = mod.Path
-			// The first result is the main module.
-			if i == 0 || mod.Replace != nil && mod.Replace.Path != "" {
+			// The first result is the main module, or the first
+			// results are those of the workspace.
+			if i == 0 || mod.Main || mod.Replace != nil && mod.Replace.Path != "" {
 				roots[absDir] = mod.Path
 			}
 		}
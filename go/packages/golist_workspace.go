@@ -0,0 +1,94 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/gocommand"
+)
+
+// workspacePatterns returns the patterns in which those that match the
+// packages of a directory tree, such as "./...", are replaced by one
+// for each module of the workspace in the tree, when the tree holds
+// several modules of the workspace rather than being in one of them.
+// The go command reports that the directory of such a pattern
+// "does not contain modules listed in go.work", as when the pattern
+// is "./..." in the directory of the go.work file.
+func (state *golistState) workspacePatterns(patterns []string) ([]string, error) {
+	var trees []string
+	for _, pattern := range patterns {
+		if isTreePattern(pattern) {
+			trees = append(trees, pattern)
+		}
+	}
+	if len(trees) == 0 {
+		return patterns, nil
+	}
+	env, err := state.getEnv()
+	if err != nil {
+		return nil, err
+	}
+	if env["GOWORK"] == "" || env["GOWORK"] == "off" {
+		return patterns, nil // not in workspace mode
+	}
+
+	// The directories of the modules of the workspace.
+	buf, err := state.invokeGo("list", "-m", "-json")
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for dec := json.NewDecoder(buf); dec.More(); {
+		mod := new(gocommand.ModuleJSON)
+		if err := dec.Decode(mod); err != nil {
+			return nil, err
+		}
+		if mod.Dir != "" {
+			dirs = append(dirs, filepath.Clean(mod.Dir))
+		}
+	}
+
+	var res []string
+	for _, pattern := range patterns {
+		if !isTreePattern(pattern) {
+			res = append(res, pattern)
+			continue
+		}
+		root := state.absPath(strings.TrimSuffix(pattern, "/..."))
+		var expanded []string
+		for _, dir := range dirs {
+			if inDir(root, dir) {
+				expanded = nil // the tree is in a module, as the go command expects
+				break
+			}
+			if inDir(dir, root) {
+				expanded = append(expanded, filepath.Join(dir, "..."))
+			}
+		}
+		if expanded == nil {
+			res = append(res, pattern)
+		}
+		res = append(res, expanded...)
+	}
+	return res, nil
+}
+
+// isTreePattern reports whether the pattern matches the packages of a
+// directory tree, as "./..." or "/abs/dir/..." do.
+func isTreePattern(pattern string) bool {
+	if !strings.HasSuffix(pattern, "/...") {
+		return false
+	}
+	return strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../") || filepath.IsAbs(pattern)
+}
+
+// inDir reports whether the file or directory is dir or in it.
+func inDir(file, dir string) bool {
+	return file == dir || strings.HasPrefix(file, dir+string(os.PathSeparator))
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/gocommand"
+)
+
+// A ModuleVersion identifies a version of a module to load with
+// LoadModule.
+type ModuleVersion struct {
+	Path    string // module path, such as "golang.org/x/text"
+	Version string // module version, such as "v0.3.7"
+
+	// Zip, if not empty, is the name of a zip archive of the module,
+	// in the format of the module proxy protocol, which provides the
+	// module instead of the module proxy. Its contents are extracted
+	// to the module cache, unless it already holds the version.
+	Zip string
+}
+
+// LoadModule loads the packages matching the patterns in a version of
+// a module, like Load, with the module as the main module: as it is
+// built by its own authors, whatever the module, if any, of Config.Dir.
+//
+// The module is downloaded to the module cache if needed, and its
+// packages are loaded from there, in workspace mode off, with the
+// -mod=mod flag; the changes of its go.mod and go.sum files that the
+// build system would make are discarded. Patterns are relative to the
+// root of the module: the default pattern "." is the package at the
+// root, and "./..." matches all packages of the module.
+//
+// The settings of Config.GoEnv apply to the download of the module too,
+// except its Dir, which LoadModule ignores, as it does Config.Dir. The
+// module must have a go.mod file.
+func LoadModule(cfg *Config, mod ModuleVersion, patterns ...string) ([]*Package, error) {
+	var c Config
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Context == nil {
+		c.Context = context.Background()
+	}
+	env := c.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	if c.GoEnv != nil {
+		// The settings apply to the download of the module as well,
+		// but the module is loaded in its own directory.
+		if err := c.GoEnv.validate(); err != nil {
+			return nil, err
+		}
+		env = c.GoEnv.environ(env)
+		c.GoEnv = nil
+	}
+	c.Env = append(append([]string{}, env...), "GO111MODULE=on", "GOWORK=off")
+
+	tmp, err := ioutil.TempDir("", "gopackages-module-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	runner := c.gocmdRunner
+	if runner == nil {
+		runner = &gocommand.Runner{}
+	}
+	run := func(verb string, args ...string) (*bytes.Buffer, error) {
+		return runner.Run(c.Context, gocommand.Invocation{
+			Verb:       verb,
+			Args:       args,
+			Env:        c.Env,
+			CleanEnv:   true,
+			Logf:       c.Logf,
+			WorkingDir: tmp,
+		})
+	}
+
+	if mod.Zip != "" {
+		// Serve the archive, ahead of the other proxies. The checksum
+		// database knows only published modules.
+		proxy := filepath.Join(tmp, "proxy")
+		if err := writeProxyVersion(proxy, mod); err != nil {
+			return nil, err
+		}
+		stdout, err := run("env", "-json", "GOPROXY", "GONOSUMDB")
+		if err != nil {
+			return nil, err
+		}
+		var goenv struct{ GOPROXY, GONOSUMDB string }
+		if err := json.Unmarshal(stdout.Bytes(), &goenv); err != nil {
+			return nil, fmt.Errorf("JSON decoding failed: %v", err)
+		}
+		c.Env = append(c.Env,
+			"GOPROXY="+strings.Trim(fileURL(proxy)+","+goenv.GOPROXY, ","),
+			"GONOSUMDB="+strings.Trim(goenv.GONOSUMDB+","+mod.Path, ","))
+	}
+
+	// Download the module, outside of any main module.
+	stdout, err := run("mod", "download", "-json", mod.Path+"@"+mod.Version)
+	if err != nil {
+		return nil, err
+	}
+	var download struct {
+		Dir   string
+		Error string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &download); err != nil {
+		return nil, fmt.Errorf("JSON decoding failed: %v", err)
+	}
+	if download.Error != "" {
+		return nil, fmt.Errorf("downloading %s@%s: %s", mod.Path, mod.Version, download.Error)
+	}
+	dir := download.Dir
+	gomod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, fmt.Errorf("module %s@%s: %v", mod.Path, mod.Version, err)
+	}
+
+	// The files of the module cache are read-only, so the build
+	// system uses copies of go.mod and go.sum, with -modfile.
+	main := filepath.Join(tmp, "main")
+	if err := os.Mkdir(main, 0777); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(main, "go.mod"), gomod, 0666); err != nil {
+		return nil, err
+	}
+	if gosum, err := ioutil.ReadFile(filepath.Join(dir, "go.sum")); err == nil {
+		if err := ioutil.WriteFile(filepath.Join(main, "go.sum"), gosum, 0666); err != nil {
+			return nil, err
+		}
+	}
+	c.Dir = dir
+	c.modFile = filepath.Join(main, "go.mod")
+	c.modFlag = "mod"
+	return Load(&c, patterns...)
+}
+
+// writeProxyVersion writes the zip archive of the module version to
+// the directory of a file-based module proxy, along with its go.mod
+// file and version information.
+func writeProxyVersion(proxy string, mod ModuleVersion) error {
+	escPath, err := module.EscapePath(mod.Path)
+	if err != nil {
+		return err
+	}
+	escVersion, err := module.EscapeVersion(mod.Version)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(proxy, filepath.FromSlash(escPath), "@v")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(mod.Zip)
+	if err != nil {
+		return err
+	}
+	gomod := []byte("module " + mod.Path + "\n")
+	r, err := zip.OpenReader(mod.Zip)
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", mod.Zip, err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name != mod.Path+"@"+mod.Version+"/go.mod" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		gomod, err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("reading %s: %v", mod.Zip, err)
+		}
+	}
+
+	files := map[string][]byte{
+		"list":               []byte(mod.Version + "\n"),
+		escVersion + ".info": []byte(fmt.Sprintf(`{"Version": %q}`, mod.Version)),
+		escVersion + ".mod":  gomod,
+		escVersion + ".zip":  data,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileURL returns the file URL of an absolute file name.
+func fileURL(name string) string {
+	path := filepath.ToSlash(name)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // a Windows drive letter
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"go/token"
+	"os"
+	"sync"
+)
+
+// A BuildConfig is a build configuration under which LoadConfigs loads
+// packages, in addition to the settings of its Config.
+type BuildConfig struct {
+	Name       string   // a name for the configuration, such as "windows/arm64"
+	Env        []string // environment variables, such as "GOOS=windows", added to Config.Env
+	BuildFlags []string // flags, such as "-tags=purego", added to Config.BuildFlags
+}
+
+// A MultiResult holds the packages that LoadConfigs loaded under each of
+// its build configurations.
+type MultiResult struct {
+	// Configs holds the build configurations, in the order of the call
+	// of LoadConfigs.
+	Configs []BuildConfig
+
+	// Packages holds the initial packages of each configuration, like
+	// the result of Load: Packages[i] are those of Configs[i].
+	Packages [][]*Package
+
+	byID map[string][]*Package // packages of all configurations, by ID and configuration
+}
+
+// LoadConfigs loads the packages matching the patterns under each of the
+// build configurations, as Load would with the variables and flags of
+// the configuration added to those of cfg, and returns the package graph
+// of each. An empty list of configurations loads the packages under cfg.
+//
+// The configurations are loaded concurrently and share a FileSet, the
+// Config.Fset if any, the limit of Config.ParseConcurrency, and the
+// results of parsing: a Go file that belongs to packages of several
+// configurations is read and parsed once, and the Syntax of those
+// packages holds the same *ast.File. A package whose build inputs are
+// identical in several configurations, with the same files and the
+// same dependencies, is type-checked once: although each configuration
+// has its own Package, their Syntax, Types and TypesInfo are the same,
+// which an analysis that relates the configurations must keep in mind.
+// A package with different files or dependencies in two configurations
+// has a distinct types.Package in each.
+//
+// LoadConfigs returns the error of the first configuration, in order,
+// for which the driver failed.
+func LoadConfigs(cfg *Config, configs []BuildConfig, patterns ...string) (*MultiResult, error) {
+	var base Config
+	if cfg != nil {
+		base = *cfg
+	}
+	if base.Env == nil {
+		base.Env = os.Environ()
+	}
+	if base.Fset == nil {
+		base.Fset = token.NewFileSet()
+	}
+	if len(configs) == 0 {
+		configs = []BuildConfig{{}}
+	}
+
+	// The loaders share the cache of parsed files, and the limit of
+	// their concurrency.
+	parseCache := make(map[parseKey]*parseValue)
+	parseCacheMu := new(sync.Mutex)
+	artifacts := &sharedArtifacts{artifacts: make(map[string]*artifact)}
+	var parseLimit chan struct{}
+	if base.ParseConcurrency > 0 {
+		parseLimit = make(chan struct{}, base.ParseConcurrency)
+	}
+
+	res := &MultiResult{
+		Configs:  configs,
+		Packages: make([][]*Package, len(configs)),
+		byID:     make(map[string][]*Package),
+	}
+	loaders := make([]*loader, len(configs))
+	errs := make([]error, len(configs))
+	var wg sync.WaitGroup
+	for i, bc := range configs {
+		c := base
+		c.Env = append(append([]string{}, base.Env...), bc.Env...)
+		c.BuildFlags = append(append([]string{}, base.BuildFlags...), bc.BuildFlags...)
+		ld := newLoader(&c)
+		ld.parseCache = parseCache
+		ld.parseCacheMu = parseCacheMu
+		ld.parseLimit = parseLimit
+		ld.artifacts = artifacts
+		loaders[i] = ld
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer ld.reportStats()
+			response, err := ld.runDriver(patterns...)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			ld.sizes = response.Sizes
+			res.Packages[i], errs[i] = ld.refine(response.Roots, response.Packages...)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, ld := range loaders {
+		for id, lpkg := range ld.pkgs {
+			variants := res.byID[id]
+			if variants == nil {
+				variants = make([]*Package, len(configs))
+				res.byID[id] = variants
+			}
+			variants[i] = lpkg.Package
+		}
+	}
+	return res, nil
+}
+
+// Variants returns the packages with the given ID in each configuration
+// of the result, in the order of Configs, whether initial packages or
+// their dependencies: the element for a configuration in which no
+// package has the ID, such as one whose build constraints exclude all
+// of its files, is nil. It returns nil if no configuration has the
+// package.
+//
+// The variants of a package in other configurations, by which an
+// analysis may relate the facts of one configuration to another, are
+// thus those of Variants(pkg.ID).
+func (r *MultiResult) Variants(id string) []*Package {
+	return r.byID[id]
+}
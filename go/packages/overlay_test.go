@@ -1112,3 +1112,75 @@ replace (
 		t.Fatalf(`expected import "fmt", got none`)
 	}
 }
+
+func TestOverlayGoModAndGoWork(t *testing.T) {
+	t.Parallel()
+	testenv.NeedsGo1Point(t, 18)
+
+	tmp := t.TempDir()
+	write := func(name, contents string) string {
+		t.Helper()
+		filename := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(contents), 0664); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	load := func(dir string, overlay map[string][]byte, pattern string) *packages.Package {
+		t.Helper()
+		config := &packages.Config{
+			Dir:     filepath.Join(tmp, dir),
+			Env:     append(os.Environ(), "GOPACKAGESDRIVER=off", "GOWORK=", "GOFLAGS="),
+			Mode:    packages.NeedName,
+			Overlay: overlay,
+		}
+		initial, err := packages.Load(config, pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(initial) != 1 {
+			t.Fatalf("loading %s in %s: got %v, want one package", pattern, dir, initial)
+		}
+		return initial[0]
+	}
+
+	// The overlay of go.mod changes the module path.
+	gomod := write("mod/go.mod", "module example.com/disk\n\ngo 1.18\n")
+	write("mod/p/p.go", "package p\n")
+	overlay := map[string][]byte{gomod: []byte("module example.com/overlay\n\ngo 1.18\n")}
+	if got := load("mod", overlay, "./p").PkgPath; got != "example.com/overlay/p" {
+		t.Errorf("with overlaid go.mod, PkgPath = %q, want example.com/overlay/p", got)
+	}
+	if got, err := ioutil.ReadFile(gomod); err != nil || string(got) != "module example.com/disk\n\ngo 1.18\n" {
+		t.Errorf("go.mod on disk was modified: %q, %v", got, err)
+	}
+
+	// The overlay of go.work adds a module to the workspace.
+	gowork := write("work/go.work", "go 1.18\n\nuse ./a\n")
+	write("work/a/go.mod", "module example.com/a\n\ngo 1.18\n")
+	write("work/a/a.go", "package a\n")
+	write("work/b/go.mod", "module example.com/b\n\ngo 1.18\n")
+	write("work/b/b.go", "package b\n")
+	overlay = map[string][]byte{gowork: []byte("go 1.18\n\nuse (\n\t./a\n\t./b\n)\n")}
+	if pkg := load("work", overlay, "example.com/b"); len(pkg.Errors) > 0 || pkg.Name != "b" {
+		t.Errorf("with overlaid go.work, loading example.com/b returned %s with errors %v", pkg.Name, pkg.Errors)
+	}
+	if pkg := load("work", nil, "example.com/b"); len(pkg.Errors) == 0 {
+		t.Errorf("without overlay, loading example.com/b succeeded")
+	}
+
+	// A go.work file may exist only in the overlay.
+	write("newwork/a/go.mod", "module example.com/a\n\ngo 1.18\n")
+	write("newwork/a/a.go", "package a\n")
+	write("newwork/b/go.mod", "module example.com/b\n\ngo 1.18\n")
+	write("newwork/b/b.go", "package b\n")
+	overlay = map[string][]byte{
+		filepath.Join(tmp, "newwork", "go.work"): []byte("go 1.18\n\nuse (\n\t./a\n\t./b\n)\n"),
+	}
+	if pkg := load("newwork/a", overlay, "example.com/b"); len(pkg.Errors) > 0 || pkg.Name != "b" {
+		t.Errorf("with go.work only in the overlay, loading example.com/b returned %s with errors %v", pkg.Name, pkg.Errors)
+	}
+}
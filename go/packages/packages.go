@@ -15,12 +15,15 @@ import (
 	"go/scanner"
 	"go/token"
 	"go/types"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/tools/go/gcexportdata"
@@ -90,6 +93,19 @@ const (
 
 	// NeedEmbedPatterns adds EmbedPatterns.
 	NeedEmbedPatterns
+
+	// NeedModuleGraph adds Module, with the modules that each module
+	// requires, so that the Module of any package of a main module leads
+	// to the whole module graph.
+	NeedModuleGraph
+
+	// IgnoreDepFuncBodies causes the dependencies that are type-checked
+	// from source, as with NeedDeps or for want of export data, to be
+	// type-checked without their function bodies, which most tools do
+	// not need: in their Syntax, function declarations have no body.
+	// Such packages are marked IgnoredFuncBodies.
+	// It modifies Types, Syntax and TypesInfo, and has no effect on its own.
+	IgnoreDepFuncBodies
 )
 
 const (
@@ -117,6 +133,27 @@ const (
 	NeedExportsFile = NeedExportFile
 )
 
+// A Variant is a variant of a package that Load may return for a package
+// matching a pattern, along with tests. The go command names the
+// variants of a package p, whose tests are in the executable "p.test",
+// "p", "p [p.test]", "p_test [p.test]" and "p.test".
+type Variant int
+
+const (
+	// NonTestVariant is the package as its importers use it, as "p".
+	NonTestVariant Variant = 1 << iota
+
+	// TestVariant is the package compiled with its internal test
+	// files, as "p [p.test]".
+	TestVariant
+
+	// XTestVariant is the external test package, as "p_test [p.test]".
+	XTestVariant
+
+	// TestMainVariant is the test executable, as "p.test".
+	TestMainVariant
+)
+
 // A Config specifies details about how packages should be loaded.
 // The zero value is a valid configuration.
 // Calls to Load do not modify this struct.
@@ -151,6 +188,12 @@ type Config struct {
 	//
 	Env []string
 
+	// GoEnv, if not nil, holds settings of the environment of the go
+	// command, such as GOFLAGS and GOPROXY, that take precedence over
+	// those of Env and Dir, along with the option to forbid access to
+	// the network, so as to make the load hermetic. See GoEnv.
+	GoEnv *GoEnv
+
 	// gocmdRunner guards go command calls from concurrency errors.
 	gocmdRunner *gocommand.Runner
 
@@ -164,6 +207,15 @@ type Config struct {
 	// modFlag will be used for -modfile in go command invocations.
 	modFlag string
 
+	// parallelism, if positive, is the maximum number of packages
+	// that are parsed and type-checked concurrently.
+	parallelism int
+
+	// ignoreDepFuncBodies causes the function bodies of non-initial
+	// packages not to be type-checked even if NeedDeps is set;
+	// in their syntax trees, function declarations have no body.
+	ignoreDepFuncBodies bool
+
 	// Fset provides source position information for syntax trees and types.
 	// If Fset is nil, Load will use a new fileset, but preserve Fset's value.
 	Fset *token.FileSet
@@ -182,6 +234,22 @@ type Config struct {
 	// unwanted function bodies can significantly accelerate type checking.
 	ParseFile func(fset *token.FileSet, filename string, src []byte) (*ast.File, error)
 
+	// ParseMode, if not nil, returns the mode with which the loader
+	// parses the Go files of the package, in place of
+	// parser.AllErrors|parser.ParseComments, so that a tool may parse
+	// the files of some packages, such as dependencies, without their
+	// comments, for instance, to save memory. It is called with the
+	// metadata of the package, and ignored if ParseFile is set.
+	//
+	// A package that is type-checked from source needs the declarations
+	// of its files: with parser.ImportsOnly or parser.PackageClauseOnly,
+	// its types are incomplete.
+	ParseMode func(pkg *Package) parser.Mode
+
+	// ParseConcurrency, if positive, is the maximum number of files that
+	// a call of Load reads and parses at once.
+	ParseConcurrency int
+
 	// If Tests is set, the loader includes not just the packages
 	// matching a particular pattern but also any related test packages,
 	// including test-only variants of the package and the test executable.
@@ -196,13 +264,71 @@ type Config struct {
 	// setting Tests may have no effect.
 	Tests bool
 
+	// Variants, if not zero, selects the variants of the packages
+	// matching the patterns that the loader returns, in place of Tests,
+	// which it overrides: for example, with TestVariant, loading "fmt"
+	// returns just "fmt [fmt.test]", and the test executable and the
+	// packages that only it imports are not loaded. The test variants
+	// are loaded only if Variants includes some.
+	Variants Variant
+
 	// Overlay provides a mapping of absolute file paths to file contents.
 	// If the file with the given path already exists, the parser will use the
 	// alternative file contents provided by the map.
 	//
 	// Overlays provide incomplete support for when a given file doesn't
 	// already exist on disk. See the package doc above for more details.
+	//
+	// The overlaid contents of the go.mod and go.sum files of the main
+	// module, or of the go.work and go.work.sum files of the workspace,
+	// are honored too, so that the effect of changes of dependencies may
+	// be previewed; the go.mod file must exist on disk, though.
 	Overlay map[string][]byte
+
+	// FS, if not nil, is a file system whose files are those of Dir and
+	// its subdirectories. They are overlays: they take precedence over
+	// the files on disk, and those of Overlay over them. The build
+	// system's query tool and the parser thus read the files of FS,
+	// which may, with the go command, hold the go.mod file and the
+	// packages of a module that is absent from disk. The directory Dir
+	// must exist, but may be empty. All the files of FS are read at the
+	// start of each load.
+	//
+	// A Session compares the modification times of the files of FS to
+	// those of its previous load when it reloads its packages: a file
+	// whose time changed, or that was added or removed, changed.
+	FS fs.FS
+
+	// CacheDir, if not empty, is the directory of a persistent cache
+	// that Load uses across runs, and across processes, to avoid
+	// repeating work for unchanged files. The directory is created if
+	// needed.
+	//
+	// The cache holds the response of the build system's query tool
+	// for each query, along with the hashes of the contents of the
+	// files on which it depends and of the listings of their
+	// directories: if none of them changed, Load uses the response
+	// without running the query tool.
+	//
+	// It also holds the export data of each package type-checked from
+	// source without errors, keyed by the contents of its files and
+	// the keys of its dependencies: Load reads a package from it,
+	// rather than parsing and type-checking its files, if it needs
+	// the types of the package but not its syntax.
+	//
+	// Syntax trees, and the TypesInfo that refers to them, are not
+	// cached.
+	CacheDir string
+
+	// Stats, if not nil, is called at the end of each load with its
+	// statistics, such as the time spent in the driver and the number
+	// of packages type-checked from source. Recording them has a small
+	// cost, as the loader reads the clock and samples the heap.
+	// LoadConfigs calls Stats for each configuration, concurrently.
+	Stats func(*LoadStats)
+
+	// stats records the statistics of the load for Stats, if not nil.
+	stats *statsRecorder
 }
 
 // driver is the type for functions that query the build system for the
@@ -233,6 +359,12 @@ type driverResponse struct {
 	// Imports will be connected and then type and syntax information added in a
 	// later pass (see refine).
 	Packages []*Package
+
+	// Version is the version of the driver protocol of the response, and
+	// Capabilities the capabilities of the protocol that the response
+	// uses (see external.go).
+	Version      int      `json:",omitempty"`
+	Capabilities []string `json:",omitempty"`
 }
 
 // Load loads and returns the Go packages named by the given patterns.
@@ -249,9 +381,15 @@ type driverResponse struct {
 // return an error. Clients may need to handle such errors before
 // proceeding with further analysis. The PrintErrors function is
 // provided for convenient display of all errors.
+//
+// If the Config.Context is cancelled while the packages are loading,
+// Load returns the initial packages along with the error of the
+// context, as the packages that finished loading are still of use:
+// the others are marked Cancelled.
 func Load(cfg *Config, patterns ...string) ([]*Package, error) {
 	l := newLoader(cfg)
-	response, err := defaultDriver(&l.Config, patterns...)
+	defer l.reportStats()
+	response, err := l.runDriver(patterns...)
 	if err != nil {
 		return nil, err
 	}
@@ -259,6 +397,101 @@ func Load(cfg *Config, patterns ...string) ([]*Package, error) {
 	return l.refine(response.Roots, response.Packages...)
 }
 
+// LoadEach loads the Go packages named by the given patterns, like
+// Load, but delivers each package to onPackage as soon as it is loaded
+// instead of returning the graph, so that a client that processes
+// packages independently need not keep them all in memory.
+//
+// If the Config.Mode includes NeedDeps, LoadEach delivers each package
+// of the import graph of the initial packages, and otherwise only the
+// initial packages. A package is delivered after all of its imports,
+// and onPackage is not called concurrently.
+//
+// Once onPackage returns, LoadEach clears the Syntax and TypesInfo of
+// the package, which its importers do not need, so that they may be
+// garbage collected: a client that needs them later must retain them
+// itself. The fields that its importers may need, namely Name, PkgPath,
+// Imports and Types, are cleared if unrequested only when LoadEach
+// returns.
+//
+// If onPackage returns an error, LoadEach stops loading packages and
+// returns the error.
+// If the Config.Context is cancelled, LoadEach does not deliver the
+// packages that are marked Cancelled, and returns the error of the
+// context.
+func LoadEach(cfg *Config, onPackage func(*Package) error, patterns ...string) error {
+	l := newLoader(cfg)
+	defer l.reportStats()
+	l.onPackage = onPackage
+	response, err := l.runDriver(patterns...)
+	if err != nil {
+		return err
+	}
+	l.sizes = response.Sizes
+	if _, err := l.refine(response.Roots, response.Packages...); err != nil {
+		return err
+	}
+	return l.onPackageErr
+}
+
+// runDriver returns the response of the driver to a query for the
+// patterns, from Config.CacheDir if possible. Its errors are
+// DriverErrors, unless the load is cancelled.
+func (ld *loader) runDriver(patterns ...string) (*driverResponse, error) {
+	if ld.configErr != nil {
+		return nil, ld.configErr
+	}
+	start := ld.stats.now()
+	defer ld.stats.record(func(stats *LoadStats) {
+		stats.DriverCalls++
+		stats.DriverTime += time.Since(start)
+	})
+	var response *driverResponse
+	var err error
+	if ld.CacheDir != "" {
+		response, err = ld.cachedDriver(patterns...)
+	} else {
+		response, err = defaultDriver(&ld.Config, patterns...)
+	}
+	if err != nil && ld.Context.Err() == nil {
+		return nil, &DriverError{Err: err, Code: errorCode(err.Error())}
+	}
+	if err == nil && ld.Variants != 0 {
+		ld.selectVariants(response)
+	}
+	return response, err
+}
+
+// selectVariants removes the roots of the response that are not of the
+// variants of Config.Variants.
+func (ld *loader) selectVariants(response *driverResponse) {
+	variants := make(map[string]Variant, len(response.Packages))
+	for _, pkg := range response.Packages {
+		variants[pkg.ID] = variantOf(pkg)
+	}
+	roots := response.Roots[:0]
+	for _, root := range response.Roots {
+		if variants[root]&ld.Variants != 0 {
+			roots = append(roots, root)
+		}
+	}
+	response.Roots = roots
+}
+
+// variantOf returns the variant of a package of the go command, from its
+// metadata.
+func variantOf(pkg *Package) Variant {
+	switch {
+	case pkg.Name == "main" && strings.HasSuffix(pkg.ID, ".test"):
+		return TestMainVariant
+	case strings.HasSuffix(pkg.Name, "_test") && strings.HasSuffix(pkg.PkgPath, "_test"):
+		return XTestVariant
+	case strings.HasSuffix(pkg.ID, ".test]"):
+		return TestVariant
+	}
+	return NonTestVariant
+}
+
 // defaultDriver is a driver that implements go/packages' fallback behavior.
 // It will try to request to an external driver, if one exists. If there's
 // no external driver, or the driver returns a response with NotHandled set,
@@ -344,6 +577,20 @@ type Package struct {
 	// It is set only when Types is set.
 	IllTyped bool
 
+	// IgnoredFuncBodies indicates that the package was type-checked from
+	// source without its function bodies, as the dependencies of the
+	// initial packages are without NeedDeps or with IgnoreDepFuncBodies:
+	// its Types are complete, but not its TypesInfo, if any.
+	// It is set only when Types is set.
+	IgnoredFuncBodies bool
+
+	// Cancelled indicates that the loading of the package, or of one of
+	// its dependencies, was cut short by the cancellation of the Context
+	// of the Config: its Syntax, Types and TypesInfo are missing or
+	// incomplete. The packages whose loading completed are not marked
+	// as cancelled, even when the load as a whole was cancelled.
+	Cancelled bool
+
 	// Syntax is the package's syntax trees, for the files listed in CompiledGoFiles.
 	//
 	// The NeedSyntax LoadMode bit populates this field for packages matching the patterns.
@@ -367,6 +614,16 @@ type Package struct {
 	// depsErrors is the DepsErrors field from the go list response, if any.
 	depsErrors []*packagesinternal.PackageError
 
+	// fileHashes holds the hashes of the contents of the files of the
+	// package by name, if the driver provided them.
+	fileHashes map[string]string
+
+	// keyInputs holds the settings of the load on which the key of the
+	// export data of the package depends, if its types were loaded;
+	// fromSource reports whether they were type-checked from source.
+	keyInputs  *exportKeyInputs
+	fromSource bool
+
 	// module is the module information for the package if it exists.
 	Module *Module
 }
@@ -383,6 +640,12 @@ type Module struct {
 	GoMod     string       // path to go.mod file used when loading this module, if any
 	GoVersion string       // go version used in module
 	Error     *ModuleError // error loading module
+
+	// Require holds the modules that the go.mod file of the module
+	// requires, with NeedModuleGraph. Their versions are those of the
+	// requirements, which may be lower than the selected ones.
+	// Each module of the graph of a load has a single Module.
+	Require []*Module `json:"-"`
 }
 
 // ModuleError holds errors loading a module.
@@ -409,6 +672,12 @@ func init() {
 	packagesinternal.SetModFlag = func(config interface{}, value string) {
 		config.(*Config).modFlag = value
 	}
+	packagesinternal.SetParallelism = func(config interface{}, value int) {
+		config.(*Config).parallelism = value
+	}
+	packagesinternal.SetIgnoreDepFuncBodies = func(config interface{}, value bool) {
+		config.(*Config).ignoreDepFuncBodies = value
+	}
 	packagesinternal.TypecheckCgo = int(typecheckCgo)
 	packagesinternal.DepsErrors = int(needInternalDepsErrors)
 	packagesinternal.ForTest = int(needInternalForTest)
@@ -419,6 +688,18 @@ type Error struct {
 	Pos  string // "file:line:col" or "file:line" or "" or "-"
 	Msg  string
 	Kind ErrorKind
+
+	// Code identifies the problem, if known, such as the absence of
+	// an imported package, beyond the Kind of the error.
+	Code ErrorCode `json:",omitempty"`
+
+	// ImportStack is the stack of import paths from a package matched
+	// by the patterns to the package of the error, if known.
+	ImportStack []string `json:",omitempty"`
+
+	// Module is the path of the module of the package of the error,
+	// if known.
+	Module string `json:",omitempty"`
 }
 
 // ErrorKind describes the source of the error, allowing the user to
@@ -433,6 +714,32 @@ const (
 	TypeError
 )
 
+// ErrorCode identifies the specific problem that an Error reports,
+// which its message describes, so that tools need not parse the
+// message to tell, say, a missing dependency from a broken go.mod file.
+type ErrorCode int
+
+const (
+	UnknownCode              ErrorCode = iota // no more specific code applies
+	MissingDependency                         // an imported package, or a module that provides it, cannot be found
+	BuildConstraintsExcluded                  // build constraints exclude all Go files of the package
+	ImportCycle                               // the package imports itself, directly or indirectly
+	ModFileError                              // a go.mod, go.sum or go.work file is invalid or must be updated
+)
+
+// A DriverError is the error of Load when the driver fails to list the
+// packages as a whole, such as when the go command fails because of a
+// broken go.mod file, as opposed to the errors of particular packages,
+// which are in their Errors.
+type DriverError struct {
+	Err  error
+	Code ErrorCode // the problem, if known: ModFileError for a broken go.mod file
+}
+
+func (err *DriverError) Error() string { return err.Err.Error() }
+
+func (err *DriverError) Unwrap() error { return err.Err }
+
 func (err Error) Error() string {
 	pos := err.Pos
 	if pos == "" {
@@ -459,6 +766,8 @@ type flatPackage struct {
 	IgnoredFiles    []string          `json:",omitempty"`
 	ExportFile      string            `json:",omitempty"`
 	Imports         map[string]string `json:",omitempty"`
+	Module          *Module           `json:",omitempty"`
+	FileHashes      map[string]string `json:",omitempty"`
 }
 
 // MarshalJSON returns the Package in its JSON form.
@@ -483,6 +792,8 @@ func (p *Package) MarshalJSON() ([]byte, error) {
 		EmbedPatterns:   p.EmbedPatterns,
 		IgnoredFiles:    p.IgnoredFiles,
 		ExportFile:      p.ExportFile,
+		Module:          p.Module,
+		FileHashes:      p.fileHashes,
 	}
 	if len(p.Imports) > 0 {
 		flat.Imports = make(map[string]string, len(p.Imports))
@@ -511,6 +822,8 @@ func (p *Package) UnmarshalJSON(b []byte) error {
 		EmbedFiles:      flat.EmbedFiles,
 		EmbedPatterns:   flat.EmbedPatterns,
 		ExportFile:      flat.ExportFile,
+		Module:          flat.Module,
+		fileHashes:      flat.FileHashes,
 	}
 	if len(flat.Imports) > 0 {
 		p.Imports = make(map[string]*Package, len(flat.Imports))
@@ -528,10 +841,14 @@ type loaderPackage struct {
 	*Package
 	importErrors map[string]error // maps each bad import to its error
 	loadOnce     sync.Once
-	color        uint8 // for cycle detection
-	needsrc      bool  // load from source (Mode >= LoadTypes)
-	needtypes    bool  // type information is either requested or depended on
-	initial      bool  // package was matched by a pattern
+	color        uint8       // for cycle detection
+	needsrc      bool        // load from source (Mode >= LoadTypes)
+	needtypes    bool        // type information is either requested or depended on
+	initial      bool        // package was matched by a pattern
+	exportKey    string      // key of the package's export data in Config.CacheDir, if any
+	reused       bool        // package is that of a previous Snapshot, already loaded
+	parseMode    parser.Mode // mode in which its files were parsed
+	shared       bool        // results are shared with other loaders of LoadConfigs
 }
 
 // loader holds the working state of a single call to load.
@@ -539,9 +856,21 @@ type loader struct {
 	pkgs map[string]*loaderPackage
 	Config
 	sizes        types.Sizes
-	parseCache   map[string]*parseValue
-	parseCacheMu sync.Mutex
-	exportMu     sync.Mutex // enforces mutual exclusion of exportdata operations
+	parseCache   map[parseKey]*parseValue
+	parseCacheMu *sync.Mutex
+	parseLimit   chan struct{}             // if non-nil, limits concurrent calls of ParseFile
+	artifacts    *sharedArtifacts          // if non-nil, results shared with other loaders
+	keyInputs    *exportKeyInputs          // settings on which the export keys depend
+	configErr    error                     // error of invalid settings of Config, if any
+	fsModTimes   map[string]time.Time      // modification times of the files of Config.FS
+	exportMu     sync.Mutex                // enforces mutual exclusion of exportdata operations
+	loadLimit    chan struct{}             // if non-nil, limits concurrent calls of loadPackage
+	reuse        map[string]*loaderPackage // packages of a previous Snapshot to use as they are, by ID
+
+	onPackage    func(*Package) error // if non-nil, called for each package once loaded (see LoadEach)
+	onPackageMu  sync.Mutex           // serializes calls of onPackage
+	onPackageErr error                // the first error of onPackage
+	stopped      int32                // accessed atomically; nonzero after onPackage fails
 
 	// Config.Mode contains the implied mode (see impliedLoadMode).
 	// Implied mode contains all the fields we need the data for.
@@ -552,6 +881,12 @@ type loader struct {
 	requestedMode LoadMode
 }
 
+// A parseKey identifies a file parsed in a given mode.
+type parseKey struct {
+	filename string
+	mode     parser.Mode
+}
+
 type parseValue struct {
 	f     *ast.File
 	err   error
@@ -560,7 +895,8 @@ type parseValue struct {
 
 func newLoader(cfg *Config) *loader {
 	ld := &loader{
-		parseCache: map[string]*parseValue{},
+		parseCache:   map[parseKey]*parseValue{},
+		parseCacheMu: new(sync.Mutex),
 	}
 	if cfg != nil {
 		ld.Config = *cfg
@@ -588,11 +924,31 @@ func newLoader(cfg *Config) *loader {
 	if ld.Context == nil {
 		ld.Context = context.Background()
 	}
+	ld.applyGoEnv()
 	if ld.Dir == "" {
 		if dir, err := os.Getwd(); err == nil {
 			ld.Dir = dir
 		}
 	}
+	if ld.FS != nil && ld.configErr == nil {
+		ld.configErr = ld.readFS()
+	}
+	if ld.parallelism > 0 {
+		ld.loadLimit = make(chan struct{}, ld.parallelism)
+	}
+	if ld.Mode&IgnoreDepFuncBodies != 0 {
+		ld.ignoreDepFuncBodies = true
+	}
+	ld.stats = nil
+	if ld.Stats != nil {
+		ld.stats = &statsRecorder{start: time.Now()}
+	}
+	if ld.Variants != 0 {
+		ld.Tests = ld.Variants&^NonTestVariant != 0
+	}
+	if ld.ParseConcurrency > 0 {
+		ld.parseLimit = make(chan struct{}, ld.ParseConcurrency)
+	}
 
 	// Save the actually requested fields. We'll zero them out before returning packages to the user.
 	ld.requestedMode = ld.Mode
@@ -605,7 +961,8 @@ func newLoader(cfg *Config) *loader {
 
 		// ParseFile is required even in LoadTypes mode
 		// because we load source if export data is missing.
-		if ld.ParseFile == nil {
+		// (With ParseMode, parseFile calls the parser itself.)
+		if ld.ParseFile == nil && ld.ParseMode == nil {
 			ld.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
 				const mode = parser.AllErrors | parser.ParseComments
 				return parser.ParseFile(fset, filename, src, mode)
@@ -624,6 +981,7 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 		rootMap[root] = i
 	}
 	ld.pkgs = make(map[string]*loaderPackage)
+	ld.keyInputs = &exportKeyInputs{sizes: ld.sizes, cgo: ld.Mode&typecheckCgo != 0, overlay: ld.Overlay}
 	// first pass, fixup and build the map and roots
 	var initial = make([]*loaderPackage, len(roots))
 	for _, pkg := range list {
@@ -649,6 +1007,13 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 			needtypes: needtypes,
 			needsrc:   needsrc,
 		}
+		if old := ld.reuse[pkg.ID]; old != nil {
+			// The package and its dependencies are unchanged.
+			lpkg.Package = old.Package
+			lpkg.exportKey = old.exportKey
+			lpkg.reused = true
+			lpkg.loadOnce.Do(func() {})
+		}
 		ld.pkgs[lpkg.ID] = lpkg
 		if rootIndex >= 0 {
 			initial[rootIndex] = lpkg
@@ -693,7 +1058,14 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 		stack = append(stack, lpkg) // push
 		stubs := lpkg.Imports       // the structure form has only stubs with the ID in the Imports
 		// If NeedImports isn't set, the imports fields will all be zeroed out.
-		if ld.Mode&NeedImports != 0 {
+		// Those of reused packages are already materialized.
+		if lpkg.reused {
+			for _, ipkg := range stubs {
+				if visit(ld.pkgs[ipkg.ID]) {
+					lpkg.needsrc = true
+				}
+			}
+		} else if ld.Mode&NeedImports != 0 {
 			lpkg.Imports = make(map[string]*Package, len(stubs))
 			for importPath, ipkg := range stubs {
 				var importErr error
@@ -721,7 +1093,7 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 		if lpkg.needsrc {
 			srcPkgs = append(srcPkgs, lpkg)
 		}
-		if ld.Mode&NeedTypesSizes != 0 {
+		if ld.Mode&NeedTypesSizes != 0 && !lpkg.reused {
 			lpkg.TypesSizes = ld.sizes
 		}
 		stack = stack[:len(stack)-1] // pop
@@ -751,9 +1123,17 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 			}
 		}
 	}
+	for _, lpkg := range ld.pkgs {
+		if old := ld.reuse[lpkg.ID]; lpkg.reused && (lpkg.needtypes != old.needtypes || lpkg.needsrc != old.needsrc) {
+			// The package would be loaded differently, such as when
+			// it is newly imported by a source package.
+			return nil, errReload
+		}
+	}
+
 	// Load type data and syntax if needed, starting at
 	// the initial packages (roots of the import DAG).
-	if ld.Mode&NeedTypes != 0 || ld.Mode&NeedSyntax != 0 {
+	if ld.Mode&NeedTypes != 0 || ld.Mode&NeedSyntax != 0 || ld.onPackage != nil {
 		var wg sync.WaitGroup
 		for _, lpkg := range initial {
 			wg.Add(1)
@@ -769,53 +1149,65 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 	for i, lpkg := range initial {
 		result[i] = lpkg.Package
 	}
-	for i := range ld.pkgs {
+	var err error
+	for _, lpkg := range ld.pkgs {
 		// Clear all unrequested fields,
 		// to catch programs that use more than they request.
-		if ld.requestedMode&NeedName == 0 {
-			ld.pkgs[i].Name = ""
-			ld.pkgs[i].PkgPath = ""
-		}
-		if ld.requestedMode&NeedFiles == 0 {
-			ld.pkgs[i].GoFiles = nil
-			ld.pkgs[i].OtherFiles = nil
-			ld.pkgs[i].IgnoredFiles = nil
-		}
-		if ld.requestedMode&NeedEmbedFiles == 0 {
-			ld.pkgs[i].EmbedFiles = nil
-		}
-		if ld.requestedMode&NeedEmbedPatterns == 0 {
-			ld.pkgs[i].EmbedPatterns = nil
-		}
-		if ld.requestedMode&NeedCompiledGoFiles == 0 {
-			ld.pkgs[i].CompiledGoFiles = nil
-		}
-		if ld.requestedMode&NeedImports == 0 {
-			ld.pkgs[i].Imports = nil
-		}
-		if ld.requestedMode&NeedExportFile == 0 {
-			ld.pkgs[i].ExportFile = ""
-		}
-		if ld.requestedMode&NeedTypes == 0 {
-			ld.pkgs[i].Types = nil
-			ld.pkgs[i].Fset = nil
-			ld.pkgs[i].IllTyped = false
-		}
-		if ld.requestedMode&NeedSyntax == 0 {
-			ld.pkgs[i].Syntax = nil
-		}
-		if ld.requestedMode&NeedTypesInfo == 0 {
-			ld.pkgs[i].TypesInfo = nil
-		}
-		if ld.requestedMode&NeedTypesSizes == 0 {
-			ld.pkgs[i].TypesSizes = nil
-		}
-		if ld.requestedMode&NeedModule == 0 {
-			ld.pkgs[i].Module = nil
+		ld.clearUnrequested(lpkg.Package, true)
+		if lpkg.Cancelled {
+			err = ld.Context.Err()
 		}
 	}
 
-	return result, nil
+	return result, err
+}
+
+// clearUnrequested clears the unrequested fields of the package. Until
+// the load is complete, it keeps those that the importers of the
+// package may need: its Name, PkgPath, Imports and Types.
+func (ld *loader) clearUnrequested(pkg *Package, complete bool) {
+	if ld.requestedMode&NeedName == 0 && complete {
+		pkg.Name = ""
+		pkg.PkgPath = ""
+	}
+	if ld.requestedMode&NeedFiles == 0 {
+		pkg.GoFiles = nil
+		pkg.OtherFiles = nil
+		pkg.IgnoredFiles = nil
+	}
+	if ld.requestedMode&NeedEmbedFiles == 0 {
+		pkg.EmbedFiles = nil
+	}
+	if ld.requestedMode&NeedEmbedPatterns == 0 {
+		pkg.EmbedPatterns = nil
+	}
+	if ld.requestedMode&NeedCompiledGoFiles == 0 {
+		pkg.CompiledGoFiles = nil
+	}
+	if ld.requestedMode&NeedImports == 0 && complete {
+		pkg.Imports = nil
+	}
+	if ld.requestedMode&NeedExportFile == 0 {
+		pkg.ExportFile = ""
+	}
+	if ld.requestedMode&NeedTypes == 0 && complete {
+		pkg.Types = nil
+		pkg.Fset = nil
+		pkg.IllTyped = false
+		pkg.IgnoredFuncBodies = false
+	}
+	if ld.requestedMode&NeedSyntax == 0 {
+		pkg.Syntax = nil
+	}
+	if ld.requestedMode&NeedTypesInfo == 0 {
+		pkg.TypesInfo = nil
+	}
+	if ld.requestedMode&NeedTypesSizes == 0 {
+		pkg.TypesSizes = nil
+	}
+	if ld.requestedMode&(NeedModule|NeedModuleGraph) == 0 {
+		pkg.Module = nil
+	}
 }
 
 // loadRecursive loads the specified package and its dependencies,
@@ -835,10 +1227,59 @@ func (ld *loader) loadRecursive(lpkg *loaderPackage) {
 			}(imp)
 		}
 		wg.Wait()
-		ld.loadPackage(lpkg)
+		if atomic.LoadInt32(&ld.stopped) != 0 {
+			return
+		}
+		// A package is not loaded once the load is cancelled, nor if
+		// it imports a package whose loading was cut short.
+		lpkg.Cancelled = ld.Context.Err() != nil
+		for _, ipkg := range lpkg.Imports {
+			if ipkg.Cancelled {
+				lpkg.Cancelled = true
+			}
+		}
+		if lpkg.Cancelled {
+			return
+		}
+		if ld.loadLimit != nil {
+			ld.loadLimit <- struct{}{}
+			defer func() { <-ld.loadLimit }()
+		}
+		if ld.artifacts != nil {
+			ld.artifacts.load(ld, lpkg)
+		} else {
+			ld.loadPackage(lpkg)
+		}
+		ld.stats.sampleHeap()
+		if ld.onPackage != nil && !lpkg.Cancelled && (lpkg.initial || ld.Mode&NeedDeps != 0) {
+			ld.deliver(lpkg)
+		}
 	})
 }
 
+// deliver calls onPackage for a loaded package, then releases the
+// syntax trees and type information of the package.
+func (ld *loader) deliver(lpkg *loaderPackage) {
+	ld.onPackageMu.Lock()
+	defer ld.onPackageMu.Unlock()
+	if ld.onPackageErr != nil {
+		return
+	}
+	files := lpkg.CompiledGoFiles
+	ld.clearUnrequested(lpkg.Package, false)
+	if err := ld.onPackage(lpkg.Package); err != nil {
+		ld.onPackageErr = err
+		atomic.StoreInt32(&ld.stopped, 1)
+	}
+	lpkg.Syntax = nil
+	lpkg.TypesInfo = nil
+	ld.parseCacheMu.Lock()
+	for _, filename := range files {
+		delete(ld.parseCache, parseKey{filename, lpkg.parseMode})
+	}
+	ld.parseCacheMu.Unlock()
+}
+
 // loadPackage loads the specified package.
 // It must be called only once per Package,
 // after immediate dependencies are loaded.
@@ -869,11 +1310,28 @@ func (ld *loader) loadPackage(lpkg *loaderPackage) {
 	if !lpkg.needtypes && !lpkg.needsrc {
 		return
 	}
+	lpkg.keyInputs = ld.keyInputs
+	lpkg.fromSource = lpkg.needsrc
 	if !lpkg.needsrc {
 		ld.loadFromExportData(lpkg)
+		ld.setExportKey(lpkg)
+		ld.stats.record(func(stats *LoadStats) { stats.ExportDataPackages++ })
 		return // not a source package, don't get syntax trees
 	}
 
+	// A package needed only for its types may be in the cache.
+	if ld.CacheDir != "" && ld.Mode&NeedTypes != 0 && !ld.needSyntax(lpkg) {
+		ld.setExportKey(lpkg)
+		if ld.loadFromCache(lpkg) {
+			if ld.Mode&NeedExportFile != 0 && lpkg.ExportFile == "" {
+				lpkg.ExportFile = ld.cacheFile("export", lpkg.exportKey)
+			}
+			ld.stats.record(func(stats *LoadStats) { stats.CachedPackages++ })
+			return
+		}
+	}
+	ld.stats.record(func(stats *LoadStats) { stats.SourcePackages++ })
+
 	appendError := func(err error) {
 		// Convert various error types into the one true Error.
 		var errs []Error
@@ -926,16 +1384,27 @@ func (ld *loader) loadPackage(lpkg *loaderPackage) {
 	if ld.Config.Mode&NeedTypes != 0 && len(lpkg.CompiledGoFiles) == 0 && lpkg.ExportFile != "" {
 		// The config requested loading sources and types, but sources are missing.
 		// Add an error to the package and fall back to loading from export data.
-		appendError(Error{"-", fmt.Sprintf("sources missing for package %s", lpkg.ID), ParseError})
+		appendError(Error{Pos: "-", Msg: fmt.Sprintf("sources missing for package %s", lpkg.ID), Kind: ParseError})
 		ld.loadFromExportData(lpkg)
 		return // can't get syntax trees for this package
 	}
 
-	files, errs := ld.parseFiles(lpkg.CompiledGoFiles)
+	files, errs := ld.parseFiles(lpkg.CompiledGoFiles, ld.parseMode(lpkg))
+	if ld.Context.Err() != nil {
+		// Some files may not have been parsed.
+		lpkg.Cancelled = true
+		return
+	}
 	for _, err := range errs {
 		appendError(err)
 	}
 
+	if ld.ignoreDepFuncBodies && !lpkg.initial {
+		// The files may be shared with other packages,
+		// such as test variants, so they are not modified.
+		files = withoutFuncBodies(files)
+	}
+
 	lpkg.Syntax = files
 	if ld.Config.Mode&NeedTypes == 0 {
 		return
@@ -984,7 +1453,7 @@ func (ld *loader) loadPackage(lpkg *loaderPackage) {
 		// Type-check bodies of functions only in non-initial packages.
 		// Example: for import graph A->B->C and initial packages {A,C},
 		// we can ignore function bodies in B.
-		IgnoreFuncBodies: ld.Mode&NeedDeps == 0 && !lpkg.initial,
+		IgnoreFuncBodies: (ld.Mode&NeedDeps == 0 || ld.ignoreDepFuncBodies) && !lpkg.initial,
 
 		Error: appendError,
 		Sizes: ld.sizes,
@@ -998,7 +1467,10 @@ func (ld *loader) loadPackage(lpkg *loaderPackage) {
 			return
 		}
 	}
+	start := ld.stats.now()
 	types.NewChecker(tc, ld.Fset, lpkg.Types, lpkg.TypesInfo).Files(lpkg.Syntax)
+	ld.stats.record(func(stats *LoadStats) { stats.TypeCheckTime += time.Since(start) })
+	lpkg.IgnoredFuncBodies = tc.IgnoreFuncBodies
 
 	lpkg.importErrors = nil // no longer needed
 
@@ -1034,6 +1506,11 @@ func (ld *loader) loadPackage(lpkg *loaderPackage) {
 		}
 	}
 	lpkg.IllTyped = illTyped
+
+	if ld.CacheDir != "" && !illTyped {
+		ld.setExportKey(lpkg)
+		ld.writeToCache(lpkg)
+	}
 }
 
 // An importFunc is an implementation of the single-method
@@ -1046,9 +1523,24 @@ func (f importerFunc) Import(path string) (*types.Package, error) { return f(pat
 // the number of parallel I/O calls per process.
 var ioLimit = make(chan bool, 20)
 
-func (ld *loader) parseFile(filename string) (*ast.File, error) {
+// parseMode returns the mode in which to parse the files of the package:
+// that of Config.ParseMode, or zero if ParseFile parses them.
+func (ld *loader) parseMode(lpkg *loaderPackage) parser.Mode {
+	switch {
+	case ld.ParseFile != nil:
+		lpkg.parseMode = 0
+	case ld.ParseMode != nil:
+		lpkg.parseMode = ld.ParseMode(lpkg.Package)
+	default:
+		lpkg.parseMode = parser.AllErrors | parser.ParseComments
+	}
+	return lpkg.parseMode
+}
+
+func (ld *loader) parseFile(filename string, mode parser.Mode) (*ast.File, error) {
+	key := parseKey{filename, mode}
 	ld.parseCacheMu.Lock()
-	v, ok := ld.parseCache[filename]
+	v, ok := ld.parseCache[key]
 	if ok {
 		// cache hit
 		ld.parseCacheMu.Unlock()
@@ -1056,8 +1548,12 @@ func (ld *loader) parseFile(filename string) (*ast.File, error) {
 	} else {
 		// cache miss
 		v = &parseValue{ready: make(chan struct{})}
-		ld.parseCache[filename] = v
+		ld.parseCache[key] = v
 		ld.parseCacheMu.Unlock()
+		if ld.parseLimit != nil {
+			ld.parseLimit <- struct{}{}
+		}
+		start := ld.stats.now()
 
 		var src []byte
 		for f, contents := range ld.Config.Overlay {
@@ -1073,9 +1569,18 @@ func (ld *loader) parseFile(filename string) (*ast.File, error) {
 		}
 		if err != nil {
 			v.err = err
-		} else {
+		} else if ld.ParseFile != nil {
 			v.f, v.err = ld.ParseFile(ld.Fset, filename, src)
+		} else {
+			v.f, v.err = parser.ParseFile(ld.Fset, filename, src, mode)
 		}
+		if ld.parseLimit != nil {
+			<-ld.parseLimit
+		}
+		ld.stats.record(func(stats *LoadStats) {
+			stats.ParsedFiles++
+			stats.ParseTime += time.Since(start)
+		})
 
 		close(v.ready)
 	}
@@ -1088,7 +1593,7 @@ func (ld *loader) parseFile(filename string) (*ast.File, error) {
 //
 // Because files are scanned in parallel, the token.Pos
 // positions of the resulting ast.Files are not ordered.
-func (ld *loader) parseFiles(filenames []string) ([]*ast.File, []error) {
+func (ld *loader) parseFiles(filenames []string, mode parser.Mode) ([]*ast.File, []error) {
 	var wg sync.WaitGroup
 	n := len(filenames)
 	parsed := make([]*ast.File, n)
@@ -1101,7 +1606,7 @@ func (ld *loader) parseFiles(filenames []string) ([]*ast.File, []error) {
 		}
 		wg.Add(1)
 		go func(i int, filename string) {
-			parsed[i], errors[i] = ld.parseFile(filename)
+			parsed[i], errors[i] = ld.parseFile(filename, mode)
 			wg.Done()
 		}(i, file)
 	}
@@ -1129,6 +1634,26 @@ func (ld *loader) parseFiles(filenames []string) ([]*ast.File, []error) {
 	return parsed, errors
 }
 
+// withoutFuncBodies returns copies of the files
+// whose function declarations have no body.
+func withoutFuncBodies(files []*ast.File) []*ast.File {
+	res := make([]*ast.File, len(files))
+	for i, f := range files {
+		clone := *f
+		clone.Decls = make([]ast.Decl, len(f.Decls))
+		for j, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+				fn2 := *fn
+				fn2.Body = nil
+				decl = &fn2
+			}
+			clone.Decls[j] = decl
+		}
+		res[i] = &clone
+	}
+	return res
+}
+
 // sameFile returns true if x and y have the same basename and denote
 // the same file.
 func sameFile(x, y string) bool {
@@ -1199,7 +1724,19 @@ func (ld *loader) loadFromExportData(lpkg *loaderPackage) (*types.Package, error
 	if err != nil {
 		return nil, fmt.Errorf("reading %s: %v", lpkg.ExportFile, err)
 	}
+	tpkg, err := ld.readExportData(lpkg, r)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", lpkg.ExportFile, err)
+	}
+	lpkg.Types = tpkg
+	lpkg.IllTyped = false
 
+	return tpkg, nil
+}
+
+// readExportData reads the export data of the package from r.
+// Precondition: ld.exportMu is held.
+func (ld *loader) readExportData(lpkg *loaderPackage, r io.Reader) (*types.Package, error) {
 	// Build the view.
 	//
 	// The gcexportdata machinery has no concept of package ID.
@@ -1241,7 +1778,7 @@ func (ld *loader) loadFromExportData(lpkg *loaderPackage) (*types.Package, error
 	// (May modify incomplete packages in view but not create new ones.)
 	tpkg, err := gcexportdata.Read(r, ld.Fset, view, lpkg.PkgPath)
 	if err != nil {
-		return nil, fmt.Errorf("reading %s: %v", lpkg.ExportFile, err)
+		return nil, err
 	}
 	if _, ok := view["go.shape"]; ok {
 		// Account for the pseudopackage "go.shape" that gets
@@ -1251,10 +1788,6 @@ func (ld *loader) loadFromExportData(lpkg *loaderPackage) (*types.Package, error
 	if viewLen != len(view) {
 		log.Panicf("golang.org/x/tools/go/packages: unexpected new packages during load of %s", lpkg.PkgPath)
 	}
-
-	lpkg.Types = tpkg
-	lpkg.IllTyped = false
-
 	return tpkg, nil
 }
 
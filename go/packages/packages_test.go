@@ -5,9 +5,11 @@
 package packages_test
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"go/ast"
@@ -23,9 +25,12 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"golang.org/x/tools/go/gcexportdata"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/packages/packagestest"
 	"golang.org/x/tools/internal/packagesinternal"
@@ -1574,6 +1579,51 @@ EOF
 	}
 }
 
+func TestDriverProtocolV2(t *testing.T) {
+	switch runtime.GOOS {
+	case "android", "windows", "plan9":
+		t.Skip("test requires sh")
+	}
+	// The driver streams its response in two parts, if go/packages
+	// supports version 2 of the protocol.
+	const driverScript = `#!/bin/sh
+
+if ! grep -q '"version":2' /dev/stdin; then
+	echo '{"Roots": ["v1"], "Packages": [{"ID": "v1", "Name": "v1"}]}'
+	exit
+fi
+cat <<'EOF'
+{"Version": 2, "Capabilities": ["stream", "module"], "Roots": ["a"], "Packages": [{"ID": "a", "Name": "a", "PkgPath": "example.com/a", "Module": {"Path": "example.com", "Main": true}}]}
+{"Roots": ["b"], "Packages": [{"ID": "b", "Name": "b", "PkgPath": "example.com/b", "Module": {"Path": "example.com", "Main": true}}]}
+EOF
+`
+	exported := packagestest.Export(t, packagestest.GOPATH, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"bin/gopackagesdriver": packagestest.Script(driverScript),
+		}}})
+	defer exported.Cleanup()
+	driver := exported.File("golang.org/fake", "bin/gopackagesdriver")
+	if err := os.Chmod(driver, 0755); err != nil {
+		t.Fatal(err)
+	}
+	exported.Config.Env = append(exported.Config.Env, "GOPACKAGESDRIVER="+driver)
+	exported.Config.Mode = packages.NeedName | packages.NeedModule
+
+	pkgs, err := packages.Load(exported.Config, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(pkgs) != "[a b]" {
+		t.Fatalf("got packages %v, want [a b]", pkgs)
+	}
+	for _, pkg := range pkgs {
+		if pkg.Module == nil || pkg.Module.Path != "example.com" {
+			t.Errorf("package %s has module %+v, want example.com", pkg, pkg.Module)
+		}
+	}
+}
+
 // This test that a simple x test package layout loads correctly.
 // There was a bug in go list where it returned multiple copies of the same
 // package (specifically in this case of golang.org/fake/a), and this triggered
@@ -2541,6 +2591,54 @@ func testTypecheckCgo(t *testing.T, exporter packagestest.Exporter) {
 	}
 }
 
+func TestIgnoreDepFuncBodies(t *testing.T) { testAllOrModulesParallel(t, testIgnoreDepFuncBodies) }
+func testIgnoreDepFuncBodies(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"; func A() { b.B() }`,
+			"b/b.go": `package b; func B() { println() }`,
+		}}})
+	defer exported.Cleanup()
+
+	exported.Config.Mode = packages.LoadAllSyntax
+	packagesinternal.SetIgnoreDepFuncBodies(exported.Config, true)
+	packagesinternal.SetParallelism(exported.Config, 1)
+	initial, err := packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []packages.Error
+	packages.Visit(initial, nil, func(pkg *packages.Package) { errs = append(errs, pkg.Errors...) })
+	if len(errs) > 0 {
+		t.Fatalf("errors loading packages: %v", errs)
+	}
+
+	body := func(pkg *packages.Package, name string) *ast.BlockStmt {
+		for _, decl := range pkg.Syntax[0].Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && decl.Name.Name == name {
+				return decl.Body
+			}
+		}
+		t.Fatalf("%s: no declaration of %s", pkg, name)
+		return nil
+	}
+	a := initial[0]
+	if body(a, "A") == nil {
+		t.Errorf("initial package a has no function bodies")
+	}
+	b := a.Imports["golang.org/fake/b"]
+	if body(b, "B") != nil {
+		t.Errorf("dependency b has function bodies")
+	}
+	for e := range b.TypesInfo.Types {
+		if _, ok := e.(*ast.CallExpr); ok {
+			t.Errorf("dependency b has type-checked function bodies")
+			break
+		}
+	}
+}
+
 func TestModule(t *testing.T) {
 	testAllOrModulesParallel(t, testModule)
 }
@@ -2581,6 +2679,49 @@ func testModule(t *testing.T, exporter packagestest.Exporter) {
 	}
 }
 
+func TestModuleGraph(t *testing.T) {
+	testAllOrModulesParallel(t, testModuleGraph)
+}
+func testModuleGraph(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name:  "golang.org/fake",
+		Files: map[string]interface{}{"a/a.go": `package a; import _ "example.com/dep"`},
+	}, {
+		Name:  "example.com/dep@v1.2.0",
+		Files: map[string]interface{}{"dep.go": `package dep`},
+	}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedImports | packages.NeedModuleGraph
+
+	initial, err := packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 {
+		t.Fatal("want exactly one package, got ", initial)
+	}
+	a := initial[0]
+	if exported.Exporter.Name() == "GOPATH" {
+		if a.Module != nil {
+			t.Fatal("package.Module: want nil, got ", a.Module)
+		}
+		return
+	}
+	if a.Module == nil || !a.Module.Main {
+		t.Fatalf("package.Module: want the main module, got %+v", a.Module)
+	}
+	if len(a.Module.Require) != 1 {
+		t.Fatalf("main module requires %v, want one module", a.Module.Require)
+	}
+	dep := a.Module.Require[0]
+	if dep.Path != "example.com/dep" || dep.Version != "v1.2.0" || dep.Main || dep.GoMod == "" {
+		t.Errorf("required module: got %+v, want example.com/dep v1.2.0 with its go.mod", dep)
+	}
+	if got := a.Imports["example.com/dep"].Module; got != dep {
+		t.Errorf("module of example.com/dep is %+v, want the required module", got)
+	}
+}
+
 func TestExternal_NotHandled(t *testing.T) {
 	testAllOrModulesParallel(t, testExternal_NotHandled)
 }
@@ -2876,3 +3017,998 @@ func TestExportFile(t *testing.T) {
 	cfg.Mode = packages.NeedTypes
 	packages.Load(cfg, "fmt")
 }
+
+func TestCacheDir(t *testing.T) { testAllOrModulesParallel(t, testCacheDir) }
+func testCacheDir(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"; const A = b.B`,
+			"b/b.go": `package b; const B = 1`,
+		}}})
+	defer exported.Cleanup()
+
+	var mu sync.Mutex
+	var logs []string
+	exported.Config.Mode = packages.NeedName | packages.NeedTypes | packages.NeedImports | packages.NeedDeps
+	exported.Config.CacheDir = t.TempDir()
+	exported.Config.Logf = func(format string, args ...interface{}) {
+		mu.Lock()
+		logs = append(logs, fmt.Sprintf(format, args...))
+		mu.Unlock()
+	}
+	load := func() (value string, cached []string) {
+		mu.Lock()
+		logs = nil
+		mu.Unlock()
+		initial, err := packages.Load(exported.Config, "golang.org/fake/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(initial) != 1 || initial[0].IllTyped {
+			t.Fatalf("Load returned %v, want a well-typed golang.org/fake/a", initial)
+		}
+		obj := initial[0].Types.Scope().Lookup("A")
+		if obj == nil {
+			t.Fatal("golang.org/fake/a has no A")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, msg := range logs {
+			if strings.HasPrefix(msg, "using cached response") || strings.HasPrefix(msg, "loaded types of") {
+				cached = append(cached, msg)
+			}
+		}
+		sort.Strings(cached)
+		return obj.(*types.Const).Val().String(), cached
+	}
+
+	if value, cached := load(); value != "1" || len(cached) != 0 {
+		t.Errorf("first load: A = %s, cache hits %q; want 1 and none", value, cached)
+	}
+	want := []string{
+		"loaded types of golang.org/fake/a from cache",
+		"loaded types of golang.org/fake/b from cache",
+		"using cached response for [golang.org/fake/a]",
+	}
+	if value, cached := load(); value != "1" || !reflect.DeepEqual(cached, want) {
+		t.Errorf("second load: A = %s, cache hits %q; want 1 and %q", value, cached, want)
+	}
+
+	// A change of b invalidates the types of both packages.
+	if err := ioutil.WriteFile(exported.File("golang.org/fake", "b/b.go"), []byte(`package b; const B = 2`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if value, cached := load(); value != "2" || len(cached) != 0 {
+		t.Errorf("after change: A = %s, cache hits %q; want 2 and none", value, cached)
+	}
+}
+
+func TestWriteExportData(t *testing.T) { testAllOrModulesParallel(t, testWriteExportData) }
+func testWriteExportData(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"; const A = b.B`,
+			"b/b.go": `package b; const B = 1`,
+		}}})
+	defer exported.Cleanup()
+
+	// Prime the cache with packages loaded without it.
+	exported.Config.Mode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedImports | packages.NeedDeps
+	initial, err := packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := packages.WriteExportData(dir, initial); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "export"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d export data files, want 2", len(entries))
+	}
+	// The files are export data, as gcexportdata reads it.
+	for _, e := range entries {
+		f, err := os.Open(filepath.Join(dir, "export", e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := gcexportdata.NewReader(f)
+		if err == nil {
+			_, err = gcexportdata.Read(r, token.NewFileSet(), make(map[string]*types.Package), "p")
+		}
+		f.Close()
+		if err != nil {
+			t.Errorf("reading %s: %v", e.Name(), err)
+		}
+	}
+
+	// A load that needs only types reads them from the cache.
+	var mu sync.Mutex
+	var cached []string
+	exported.Config.Mode = packages.NeedName | packages.NeedTypes | packages.NeedImports | packages.NeedDeps
+	exported.Config.CacheDir = dir
+	exported.Config.Logf = func(format string, args ...interface{}) {
+		if msg := fmt.Sprintf(format, args...); strings.HasPrefix(msg, "loaded types of") {
+			mu.Lock()
+			cached = append(cached, msg)
+			mu.Unlock()
+		}
+	}
+	initial, err = packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 || initial[0].IllTyped {
+		t.Fatalf("Load returned %v, want a well-typed golang.org/fake/a", initial)
+	}
+	if obj, ok := initial[0].Types.Scope().Lookup("A").(*types.Const); !ok || obj.Val().String() != "1" {
+		t.Errorf("A = %v, want 1", obj)
+	}
+	sort.Strings(cached)
+	want := []string{
+		"loaded types of golang.org/fake/a from cache",
+		"loaded types of golang.org/fake/b from cache",
+	}
+	if !reflect.DeepEqual(cached, want) {
+		t.Errorf("cache hits %q, want %q", cached, want)
+	}
+}
+
+func TestSession(t *testing.T) { testAllOrModulesParallel(t, testSession) }
+func testSession(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"; const A = b.B`,
+			"b/b.go": `package b; const B = 1`,
+			"c/c.go": `package c; const C = 1`,
+		}}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedTypes | packages.NeedSyntax | packages.NeedDeps
+	session, err := packages.NewSession(exported.Config, "golang.org/fake/a", "golang.org/fake/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(pkgs []*packages.Package) string {
+		var ids []string
+		for _, pkg := range pkgs {
+			ids = append(ids, pkg.ID)
+		}
+		return strings.Join(ids, " ")
+	}
+	value := func(pkg *packages.Package, name string) string {
+		obj, ok := pkg.Types.Scope().Lookup(name).(*types.Const)
+		if !ok {
+			t.Fatalf("%s has no constant %s", pkg, name)
+		}
+		return obj.Val().String()
+	}
+	reload := func(file, contents string) *packages.Snapshot {
+		t.Helper()
+		filename := exported.File("golang.org/fake", file)
+		if err := ioutil.WriteFile(filename, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+		snapshot, err := session.Reload(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snapshot
+	}
+
+	first := session.Snapshot()
+	if got, want := ids(first.Reloaded), "golang.org/fake/a golang.org/fake/b golang.org/fake/c"; got != want {
+		t.Errorf("first snapshot loaded %s, want %s", got, want)
+	}
+
+	// A change of c reloads only c.
+	second := reload("c/c.go", `package c; const C = 2`)
+	if got, want := ids(second.Reloaded), "golang.org/fake/c"; got != want {
+		t.Errorf("after change of c, reloaded %s, want %s", got, want)
+	}
+	for _, id := range []string{"golang.org/fake/a", "golang.org/fake/b"} {
+		if second.Package(id) != first.Package(id) {
+			t.Errorf("after change of c, package %s is not that of the first snapshot", id)
+		}
+	}
+	if got := value(second.Package("golang.org/fake/c"), "C"); got != "2" {
+		t.Errorf("after change of c, C = %s, want 2", got)
+	}
+
+	// A change of b reloads b and a, which imports it.
+	third := reload("b/b.go", `package b; const B = 3`)
+	if got, want := ids(third.Reloaded), "golang.org/fake/a golang.org/fake/b"; got != want {
+		t.Errorf("after change of b, reloaded %s, want %s", got, want)
+	}
+	if third.Package("golang.org/fake/c") != second.Package("golang.org/fake/c") {
+		t.Errorf("after change of b, package c is not that of the previous snapshot")
+	}
+	a := third.Package("golang.org/fake/a")
+	if got := value(a, "A"); got != "3" {
+		t.Errorf("after change of b, A = %s, want 3", got)
+	}
+	if a.Imports["golang.org/fake/b"] != third.Package("golang.org/fake/b") {
+		t.Errorf("after change of b, a does not import the new b")
+	}
+	if got, want := ids(third.Initial), "golang.org/fake/a golang.org/fake/c"; got != want {
+		t.Errorf("Initial = %s, want %s", got, want)
+	}
+
+	// A new import changes the metadata of c.
+	fourth := reload("c/c.go", `package c; import "golang.org/fake/b"; const C = b.B`)
+	if got, want := ids(fourth.Reloaded), "golang.org/fake/c"; got != want {
+		t.Errorf("after new import, reloaded %s, want %s", got, want)
+	}
+	if got := value(fourth.Package("golang.org/fake/c"), "C"); got != "3" {
+		t.Errorf("after new import, C = %s, want 3", got)
+	}
+}
+
+func TestLoadEach(t *testing.T) { testAllOrModulesParallel(t, testLoadEach) }
+func testLoadEach(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"; const A = b.B`,
+			"b/b.go": `package b; import "golang.org/fake/c"; const B = c.C`,
+			"c/c.go": `package c; const C = 1`,
+		}}})
+	defer exported.Cleanup()
+
+	for _, test := range []struct {
+		mode packages.LoadMode
+		want string
+	}{
+		{packages.NeedName | packages.NeedSyntax | packages.NeedTypes | packages.NeedDeps, "golang.org/fake/c golang.org/fake/b golang.org/fake/a"},
+		{packages.NeedName | packages.NeedFiles, "golang.org/fake/a"},
+	} {
+		exported.Config.Mode = test.mode
+		var got []string
+		var delivered []*packages.Package
+		err := packages.LoadEach(exported.Config, func(pkg *packages.Package) error {
+			if test.mode&packages.NeedSyntax != 0 && (pkg.Syntax == nil || pkg.Types == nil || !pkg.Types.Complete()) {
+				t.Errorf("mode %v: package %s was delivered without its syntax and types", test.mode, pkg)
+			}
+			if test.mode&packages.NeedFiles != 0 && len(pkg.GoFiles) == 0 {
+				t.Errorf("mode %v: package %s was delivered without its files", test.mode, pkg)
+			}
+			got = append(got, pkg.ID)
+			delivered = append(delivered, pkg)
+			return nil
+		}, "golang.org/fake/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("mode %v: delivered %s, want %s", test.mode, strings.Join(got, " "), test.want)
+		}
+		for _, pkg := range delivered {
+			if pkg.Syntax != nil {
+				t.Errorf("mode %v: syntax of %s was not released", test.mode, pkg)
+			}
+		}
+	}
+
+	// An error of the callback stops the load.
+	exported.Config.Mode = packages.NeedName | packages.NeedTypes | packages.NeedDeps
+	errStop := fmt.Errorf("stop")
+	var calls int
+	err := packages.LoadEach(exported.Config, func(pkg *packages.Package) error {
+		calls++
+		return errStop
+	}, "golang.org/fake/a")
+	if err != errStop || calls != 1 {
+		t.Errorf("LoadEach with a failing callback returned %v after %d calls, want %v after 1", err, calls, errStop)
+	}
+}
+
+func TestGoEnv(t *testing.T) { testAllOrModulesParallel(t, testGoEnv) }
+func testGoEnv(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go":   `package a`,
+			"a/foo.go": "//go:build foo\n\npackage a",
+		}}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedFiles
+
+	for _, env := range []packages.GoEnv{
+		{Dir: "relative"},
+		{GOFLAGS: []string{"tags=foo"}},
+		{GOFLAGS: []string{"-tags=foo -x"}},
+		{GOPROXY: "proxy.golang.org"},
+		{GOPROXY: "https://proxy.golang.org", Offline: true},
+		{GOCACHE: "relative"},
+		{GOMODCACHE: "relative"},
+	} {
+		env := env
+		exported.Config.GoEnv = &env
+		if _, err := packages.Load(exported.Config, "golang.org/fake/a"); err == nil || !strings.Contains(err.Error(), "invalid GoEnv") {
+			t.Errorf("Load with GoEnv %+v: got error %v, want invalid GoEnv", env, err)
+		}
+	}
+
+	exported.Config.GoEnv = &packages.GoEnv{GOFLAGS: []string{"-tags=foo"}, Offline: true}
+	pkgs, err := packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || len(pkgs[0].GoFiles) != 2 {
+		t.Errorf("got packages %v with files %v, want golang.org/fake/a with foo.go", pkgs, pkgs[0].GoFiles)
+	}
+}
+
+func TestGoEnvOffline(t *testing.T) {
+	testenv.NeedsGoBuild(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.18\n\nrequire example.com/extra v1.0.0\n",
+		"m.go":   `package m; import _ "example.com/extra"`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The module cache is empty, so the dependency must be downloaded.
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedImports,
+		Env:  append(os.Environ(), "GO111MODULE=on", "GOWORK=off"),
+		GoEnv: &packages.GoEnv{
+			Dir:        dir,
+			GOFLAGS:    []string{"-mod=mod"},
+			GOMODCACHE: t.TempDir(),
+			Offline:    true,
+		},
+	}
+	pkgs, err := packages.Load(cfg, "example.com/m")
+	if err == nil {
+		var errs []packages.Error
+		packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+			errs = append(errs, pkg.Errors...)
+		})
+		if len(errs) == 0 {
+			t.Fatalf("Load succeeded offline with a module missing from the module cache")
+		}
+		err = errs[0]
+	}
+	if !strings.Contains(err.Error(), "GOPROXY=off") {
+		t.Errorf("got error %v, want one of GOPROXY=off", err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	testenv.NeedsGoBuild(t)
+	testenv.NeedsGo1Point(t, 16)
+	// The packages of the module are only in the file system of the
+	// Config, not on disk.
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n\ngo 1.18\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"p/p.go": {Data: []byte(`package p; const P = 1`), ModTime: t0},
+		"q/q.go": {Data: []byte(`package q; import "example.com/m/p"; const Q = p.P`), ModTime: t0},
+		"r/r.go": {Data: []byte(`package r; const R = 1`), ModTime: t0},
+	}
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedTypes | packages.NeedSyntax | packages.NeedDeps,
+		Dir:  dir,
+		Env:  append(os.Environ(), "GO111MODULE=on", "GOWORK=off", "GOFLAGS="),
+		FS:   fsys,
+	}
+	value := func(pkg *packages.Package, name string) string {
+		obj, ok := pkg.Types.Scope().Lookup(name).(*types.Const)
+		if !ok {
+			t.Fatalf("%s has no constant %s", pkg.ID, name)
+		}
+		return obj.Val().String()
+	}
+
+	session, err := packages.NewSession(cfg, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := session.Snapshot()
+	var ids []string
+	for _, pkg := range snapshot.Initial {
+		ids = append(ids, pkg.ID)
+		if len(pkg.Errors) > 0 || len(pkg.Syntax) != 1 {
+			t.Errorf("%s: got errors %v and %d files, want none and 1", pkg.ID, pkg.Errors, len(pkg.Syntax))
+		}
+	}
+	sort.Strings(ids)
+	if want := []string{"example.com/m/p", "example.com/m/q", "example.com/m/r"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("got packages %v, want %v", ids, want)
+	}
+	if q := snapshot.Package("example.com/m/q"); value(q, "Q") != "1" {
+		t.Errorf("Q = %s, want 1", value(q, "Q"))
+	}
+
+	// A change of a file, by its modification time, reloads its
+	// package and those that import it.
+	fsys["p/p.go"] = &fstest.MapFile{Data: []byte(`package p; const P = 2`), ModTime: t0.Add(time.Second)}
+	snapshot, err = session.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = nil
+	for _, pkg := range snapshot.Reloaded {
+		ids = append(ids, pkg.ID)
+	}
+	if want := []string{"example.com/m/p", "example.com/m/q"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("reloaded %v, want %v", ids, want)
+	}
+	if q := snapshot.Package("example.com/m/q"); value(q, "Q") != "2" {
+		t.Errorf("after change: Q = %s, want 2", value(q, "Q"))
+	}
+}
+
+func TestLoadModule(t *testing.T) {
+	exported := packagestest.Export(t, packagestest.Modules, []packagestest.Module{{
+		Name:  "golang.org/fake",
+		Files: map[string]interface{}{"a/a.go": `package a`},
+	}, {
+		Name: "example.com/dep@v1.2.0",
+		Files: map[string]interface{}{
+			"dep.go":     `package dep; const Dep = 1`,
+			"sub/sub.go": `package sub; import "example.com/dep"; const Sub = dep.Dep`,
+		},
+	}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedFiles | packages.NeedModule
+
+	check := func(name string, mod packages.ModuleVersion, want string) {
+		t.Helper()
+		pkgs, err := packages.LoadModule(exported.Config, mod, "./...")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var got []string
+		for _, pkg := range pkgs {
+			got = append(got, pkg.PkgPath)
+			if len(pkg.Errors) > 0 {
+				t.Errorf("%s: package %s has errors %v", name, pkg, pkg.Errors)
+			}
+			if pkg.Module == nil || !pkg.Module.Main || pkg.Module.Path != mod.Path {
+				t.Errorf("%s: package %s is not in the main module %s: %+v", name, pkg, mod.Path, pkg.Module)
+			}
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s: loaded %s, want %s", name, strings.Join(got, " "), want)
+		}
+	}
+
+	// A module of the module proxy, which the main module of
+	// exported.Config.Dir does not require.
+	check("proxy", packages.ModuleVersion{Path: "example.com/dep", Version: "v1.2.0"}, "example.com/dep example.com/dep/sub")
+
+	// A module from a zip archive.
+	zipfile := filepath.Join(t.TempDir(), "v1.0.0.zip")
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, contents := range map[string]string{
+		"go.mod": "module example.com/zipped\n\ngo 1.18\n",
+		"z.go":   "package zipped\n",
+	} {
+		f, err := w.Create("example.com/zipped@v1.0.0/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(zipfile, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	check("zip", packages.ModuleVersion{Path: "example.com/zipped", Version: "v1.0.0", Zip: zipfile}, "example.com/zipped")
+}
+
+func TestLoadConfigs(t *testing.T) { testAllOrModulesParallel(t, testLoadConfigs) }
+func testLoadConfigs(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go":         `package a; import "golang.org/fake/b"; const A = b.B`,
+			"b/b.go":         `package b`,
+			"b/b_linux.go":   `package b; const B = "linux"`,
+			"b/b_windows.go": `package b; const B = "windows"`,
+		}}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedDeps
+
+	res, err := packages.LoadConfigs(exported.Config, []packages.BuildConfig{
+		{Name: "linux", Env: []string{"GOOS=linux", "GOARCH=amd64"}},
+		{Name: "windows", Env: []string{"GOOS=windows", "GOARCH=amd64"}},
+	}, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Packages) != 2 {
+		t.Fatalf("got %d package lists, want 2", len(res.Packages))
+	}
+	for i, want := range []string{`"linux"`, `"windows"`} {
+		pkgs := res.Packages[i]
+		if len(pkgs) != 1 || pkgs[0].PkgPath != "golang.org/fake/a" {
+			t.Fatalf("%s: got packages %v, want golang.org/fake/a", res.Configs[i].Name, pkgs)
+		}
+		obj, _ := pkgs[0].Types.Scope().Lookup("A").(*types.Const)
+		if obj == nil || obj.Val().ExactString() != want {
+			t.Errorf("%s: A = %v, want %s", res.Configs[i].Name, obj, want)
+		}
+	}
+
+	for _, id := range []string{"golang.org/fake/a", "golang.org/fake/b"} {
+		variants := res.Variants(id)
+		if len(variants) != 2 || variants[0] == nil || variants[1] == nil {
+			t.Fatalf("Variants(%s) = %v, want one package per configuration", id, variants)
+		}
+		if variants[0].Types == variants[1].Types {
+			t.Errorf("the variants of %s share their types", id)
+		}
+	}
+	if res.Variants("golang.org/fake/a")[0] != res.Packages[0][0] {
+		t.Errorf("the variant of golang.org/fake/a is not the initial package")
+	}
+	// The file common to both configurations is parsed once.
+	linux, windows := res.Variants("golang.org/fake/b")[0], res.Variants("golang.org/fake/b")[1]
+	shared := 0
+	for _, f := range linux.Syntax {
+		for _, g := range windows.Syntax {
+			if f == g {
+				shared++
+			}
+		}
+	}
+	if len(linux.Syntax) != 2 || len(windows.Syntax) != 2 || shared != 1 {
+		t.Errorf("got %d and %d files, %d shared; want 2 and 2, 1 shared", len(linux.Syntax), len(windows.Syntax), shared)
+	}
+	if res.Variants("golang.org/fake/nonexistent") != nil {
+		t.Errorf("Variants of a nonexistent package is not nil")
+	}
+}
+
+func TestLoadConfigsShared(t *testing.T) { testAllOrModulesParallel(t, testLoadConfigsShared) }
+func testLoadConfigsShared(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go":         `package a; import ("golang.org/fake/b"; "golang.org/fake/c"); const A = b.B + c.C`,
+			"b/b_linux.go":   `package b; const B = "linux"`,
+			"b/b_windows.go": `package b; const B = "windows"`,
+			"c/c.go":         `package c; import "golang.org/fake/d"; const C = d.D`,
+			"d/d.go":         `package d; const D = "d"`,
+		}}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps
+	var mu sync.Mutex
+	shared := 0
+	exported.Config.Stats = func(stats *packages.LoadStats) {
+		mu.Lock()
+		shared += stats.SharedPackages
+		mu.Unlock()
+	}
+
+	res, err := packages.LoadConfigs(exported.Config, []packages.BuildConfig{
+		{Name: "linux", Env: []string{"GOOS=linux", "GOARCH=amd64"}},
+		{Name: "windows", Env: []string{"GOOS=windows", "GOARCH=amd64"}},
+	}, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Packages with the same files and dependencies share their results.
+	for _, id := range []string{"golang.org/fake/c", "golang.org/fake/d"} {
+		variants := res.Variants(id)
+		if len(variants) != 2 || variants[0] == nil || variants[1] == nil {
+			t.Fatalf("Variants(%s) = %v, want one package per configuration", id, variants)
+		}
+		if variants[0] == variants[1] {
+			t.Errorf("the variants of %s are the same Package", id)
+		}
+		if variants[0].Types != variants[1].Types || variants[0].TypesInfo != variants[1].TypesInfo {
+			t.Errorf("the variants of %s do not share their types", id)
+		}
+	}
+	// Those whose files differ, or that import packages that differ, do not.
+	for _, id := range []string{"golang.org/fake/a", "golang.org/fake/b"} {
+		variants := res.Variants(id)
+		if variants[0].Types == variants[1].Types {
+			t.Errorf("the variants of %s share their types", id)
+		}
+	}
+	if shared != 2 {
+		t.Errorf("got %d shared packages, want 2", shared)
+	}
+	// The types of the shared packages are those that the others import.
+	for i := range res.Configs {
+		a := res.Packages[i][0]
+		if len(a.Errors) > 0 {
+			t.Fatalf("%s: unexpected errors: %v", res.Configs[i].Name, a.Errors)
+		}
+		found := false
+		for _, imp := range a.Types.Imports() {
+			found = found || imp == a.Imports["golang.org/fake/c"].Types
+		}
+		if !found {
+			t.Errorf("%s: a does not import the types of c", res.Configs[i].Name)
+		}
+	}
+}
+
+func TestCancelledLoad(t *testing.T) { testAllOrModulesParallel(t, testCancelledLoad) }
+func testCancelledLoad(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"; const A = b.B`,
+			"b/b.go": `package b; const B = 1`,
+		}}})
+	defer exported.Cleanup()
+
+	// Cancel the load once the files of b are type-checked, while
+	// those of a are parsed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exported.Config.Context = ctx
+	exported.Config.Mode = packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes
+	exported.Config.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		if filepath.Base(filename) == "a.go" {
+			cancel()
+		}
+		return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+	}
+	pkgs, err := packages.Load(exported.Config, "golang.org/fake/a")
+	if err != context.Canceled {
+		t.Fatalf("Load returned error %v, want %v", err, context.Canceled)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("Load returned %d packages, want 1", len(pkgs))
+	}
+	a := pkgs[0]
+	if !a.Cancelled {
+		t.Errorf("package %s is not marked cancelled", a)
+	}
+	b := a.Imports["golang.org/fake/b"]
+	if b == nil {
+		t.Fatalf("package %s has no import of golang.org/fake/b", a)
+	}
+	if b.Cancelled || b.Types == nil || !b.Types.Complete() || len(b.Syntax) != 1 {
+		t.Errorf("package %s was not loaded completely: cancelled %v, types %v, %d files", b, b.Cancelled, b.Types, len(b.Syntax))
+	}
+}
+
+func TestErrorCodes(t *testing.T) { testAllOrModulesParallel(t, testErrorCodes) }
+func testErrorCodes(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go":         `package a; import _ "golang.org/fake/nonexistent"`,
+			"b/b_windows.go": `package b`,
+			"c/c.go":         `package c; import _ "golang.org/fake/d"`,
+			"d/d.go":         `package d; import _ "golang.org/fake/c"`,
+		}}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedImports | packages.NeedDeps
+	exported.Config.Env = append(exported.Config.Env, "GOOS=linux")
+
+	codes := make(map[string][]packages.Error)
+	pkgs, err := packages.Load(exported.Config, "golang.org/fake/a", "golang.org/fake/b", "golang.org/fake/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		codes[pkg.ID] = append(codes[pkg.ID], pkg.Errors...)
+	})
+	for id, want := range map[string]packages.ErrorCode{
+		"golang.org/fake/nonexistent": packages.MissingDependency,
+		"golang.org/fake/b":           packages.BuildConstraintsExcluded,
+		"golang.org/fake/c":           packages.ImportCycle,
+	} {
+		errs := codes[id]
+		if len(errs) == 0 {
+			t.Errorf("package %s has no errors", id)
+			continue
+		}
+		if errs[0].Code != want || errs[0].Kind != packages.ListError {
+			t.Errorf("package %s has error %q with kind %v and code %v, want kind %v and code %v", id, errs[0].Msg, errs[0].Kind, errs[0].Code, packages.ListError, want)
+		}
+	}
+	if errs := codes["golang.org/fake/nonexistent"]; len(errs) > 0 {
+		if stack := errs[0].ImportStack; len(stack) == 0 || stack[0] != "golang.org/fake/a" {
+			t.Errorf("missing package has import stack %v, want one from golang.org/fake/a", stack)
+		}
+	}
+
+	// A broken go.mod file fails the driver.
+	if exporter == packagestest.Modules {
+		gomod := exported.File("golang.org/fake", "go.mod")
+		if err := ioutil.WriteFile(gomod, []byte("module golang.org/fake\n\nrequire (\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := packages.Load(exported.Config, "golang.org/fake/a")
+		var derr *packages.DriverError
+		if !errors.As(err, &derr) || derr.Code != packages.ModFileError {
+			t.Errorf("Load with a broken go.mod file returned %v (%T), want a DriverError with code %v", err, err, packages.ModFileError)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) { testAllOrModulesParallel(t, testParseMode) }
+func testParseMode(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": "package a\n\nimport \"golang.org/fake/b\"\n\n// A is a.\nconst A = b.B\n",
+			"b/b.go": "package b\n\n// B is b.\nconst B = 1\n",
+		}}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes
+	exported.Config.ParseConcurrency = 1
+	exported.Config.ParseMode = func(pkg *packages.Package) parser.Mode {
+		if pkg.PkgPath == "golang.org/fake/a" {
+			return parser.ParseComments
+		}
+		return 0 // no comments for dependencies
+	}
+	pkgs, err := packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := pkgs[0]
+	b := a.Imports["golang.org/fake/b"]
+	if len(a.Errors) > 0 || len(b.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v %v", a.Errors, b.Errors)
+	}
+	if len(a.Syntax) != 1 || len(a.Syntax[0].Comments) == 0 {
+		t.Errorf("the files of %s were parsed without comments", a)
+	}
+	if len(b.Syntax) != 1 || len(b.Syntax[0].Comments) != 0 {
+		t.Errorf("the files of %s were parsed with comments", b)
+	}
+	if obj := a.Types.Scope().Lookup("A"); obj == nil {
+		t.Errorf("package %s has no A", a)
+	}
+}
+
+func TestIgnoreDepFuncBodiesMode(t *testing.T) {
+	testAllOrModulesParallel(t, testIgnoreDepFuncBodiesMode)
+}
+func testIgnoreDepFuncBodiesMode(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"; func A() int { return b.B() }`,
+			"b/b.go": `package b; func B() int { return undefined }`,
+		}}})
+	defer exported.Cleanup()
+
+	for _, ignore := range []bool{false, true} {
+		exported.Config.Mode = packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes
+		if ignore {
+			exported.Config.Mode |= packages.IgnoreDepFuncBodies
+		}
+		pkgs, err := packages.Load(exported.Config, "golang.org/fake/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := pkgs[0]
+		b := a.Imports["golang.org/fake/b"]
+		if a.IgnoredFuncBodies || len(a.Errors) > 0 {
+			t.Errorf("ignore=%t: initial package %s has IgnoredFuncBodies %t and errors %v", ignore, a, a.IgnoredFuncBodies, a.Errors)
+		}
+		if b.IgnoredFuncBodies != ignore {
+			t.Errorf("ignore=%t: dependency %s has IgnoredFuncBodies %t", ignore, b, b.IgnoredFuncBodies)
+		}
+		// The error in the body of B is reported only if it is type-checked.
+		if got := len(b.Errors) > 0; got == ignore {
+			t.Errorf("ignore=%t: dependency %s has errors %v", ignore, b, b.Errors)
+		}
+		fn := b.Syntax[0].Decls[0].(*ast.FuncDecl)
+		if got := fn.Body == nil; got != ignore {
+			t.Errorf("ignore=%t: got body %v for function %s", ignore, fn.Body, fn.Name)
+		}
+		if b.Types.Scope().Lookup("B") == nil {
+			t.Errorf("ignore=%t: dependency %s has no B", ignore, b)
+		}
+	}
+}
+
+func TestVariants(t *testing.T) { testAllOrModulesParallel(t, testVariants) }
+func testVariants(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go":        `package a; const A = 1`,
+			"a/a_test.go":   `package a; const T = A`,
+			"a/a_x_test.go": `package a_test; import "golang.org/fake/a"; const X = a.T`,
+		}}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedImports
+
+	for _, test := range []struct {
+		variants packages.Variant
+		want     string
+	}{
+		{packages.NonTestVariant, "golang.org/fake/a"},
+		{packages.TestVariant, "golang.org/fake/a [golang.org/fake/a.test]"},
+		{packages.XTestVariant, "golang.org/fake/a_test [golang.org/fake/a.test]"},
+		{packages.TestMainVariant, "golang.org/fake/a.test"},
+		{packages.NonTestVariant | packages.XTestVariant, "golang.org/fake/a golang.org/fake/a_test [golang.org/fake/a.test]"},
+	} {
+		exported.Config.Variants = test.variants
+		pkgs, err := packages.Load(exported.Config, "golang.org/fake/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, pkg := range pkgs {
+			got = append(got, pkg.ID)
+			if len(pkg.Errors) > 0 {
+				t.Errorf("variants %v: package %s has errors %v", test.variants, pkg, pkg.Errors)
+			}
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("variants %v: got %s, want %s", test.variants, strings.Join(got, " "), test.want)
+		}
+	}
+}
+
+func TestLoadStats(t *testing.T) { testAllOrModulesParallel(t, testLoadStats) }
+func testLoadStats(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"; const A = b.B`,
+			"b/b.go": `package b; const B = 1`,
+		}}})
+	defer exported.Cleanup()
+	exported.Config.Mode = packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes
+	var stats []*packages.LoadStats
+	exported.Config.Stats = func(s *packages.LoadStats) { stats = append(stats, s) }
+	if _, err := packages.Load(exported.Config, "golang.org/fake/a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("Stats was called %d times, want 1", len(stats))
+	}
+	s := stats[0]
+	if s.DriverCalls != 1 || s.GoCommandCalls == 0 || s.DriverTime <= 0 || s.GoCommandTime <= 0 {
+		t.Errorf("got %d driver calls in %v and %d go command calls in %v, want 1 driver call", s.DriverCalls, s.DriverTime, s.GoCommandCalls, s.GoCommandTime)
+	}
+	if s.Packages != 2 || s.SourcePackages != 2 || s.ExportDataPackages != 0 || s.CachedPackages != 0 {
+		t.Errorf("got %d packages, %d from source, %d from export data and %d from the cache, want 2 from source", s.Packages, s.SourcePackages, s.ExportDataPackages, s.CachedPackages)
+	}
+	if s.ParsedFiles != 2 || s.ParseTime <= 0 || s.TypeCheckTime <= 0 {
+		t.Errorf("got %d files parsed in %v and type-checked in %v, want 2", s.ParsedFiles, s.ParseTime, s.TypeCheckTime)
+	}
+	if s.PeakHeap == 0 || s.Duration < s.DriverTime {
+		t.Errorf("got peak heap %d and duration %v", s.PeakHeap, s.Duration)
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	t.Parallel()
+	testenv.NeedsGo1Point(t, 18)
+
+	tmp := t.TempDir()
+	for name, contents := range map[string]string{
+		"go.work":  "go 1.18\n\nuse (\n\t./a\n\t./b\n)\n",
+		"a/go.mod": "module example.com/a\n\ngo 1.18\n",
+		"a/a.go":   "package a\n\nimport \"example.com/b\"\n\nconst A = b.B\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.18\n",
+		"b/b.go":   "package b\n\nconst B = 1\n",
+		"b/c/c.go": "package c\n",
+	} {
+		filename := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(contents), 0664); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func(dir string, overlay map[string][]byte, patterns ...string) map[string]*packages.Package {
+		t.Helper()
+		config := &packages.Config{
+			Dir:     filepath.Join(tmp, dir),
+			Env:     append(os.Environ(), "GOPACKAGESDRIVER=off", "GOWORK=", "GOFLAGS="),
+			Mode:    packages.NeedName | packages.NeedImports | packages.NeedModule,
+			Overlay: overlay,
+		}
+		initial, err := packages.Load(config, patterns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs := make(map[string]*packages.Package)
+		for _, pkg := range initial {
+			pkgs[pkg.PkgPath] = pkg
+		}
+		return pkgs
+	}
+
+	// A pattern in the directory of the go.work file matches the
+	// packages of all its modules, each with its module.
+	pkgs := load(".", nil, "./...")
+	for path, mod := range map[string]string{
+		"example.com/a":   "example.com/a",
+		"example.com/b":   "example.com/b",
+		"example.com/b/c": "example.com/b",
+	} {
+		pkg := pkgs[path]
+		if pkg == nil {
+			t.Errorf("./... did not match %s: got %v", path, pkgs)
+			continue
+		}
+		if len(pkg.Errors) > 0 {
+			t.Errorf("package %s has errors %v", path, pkg.Errors)
+		}
+		if pkg.Module == nil || pkg.Module.Path != mod || !pkg.Module.Main {
+			t.Errorf("package %s has module %+v, want main module %s", path, pkg.Module, mod)
+		}
+	}
+	if len(pkgs) != 3 {
+		t.Errorf("./... matched %d packages, want 3", len(pkgs))
+	}
+	if imp := pkgs["example.com/a"].Imports["example.com/b"]; imp == nil || imp.Module == nil || imp.Module.Path != "example.com/b" {
+		t.Errorf("the import of example.com/b by example.com/a is not in its module: %+v", imp)
+	}
+
+	// A pattern in a module of the workspace names packages of others.
+	if pkg := load("a", nil, "example.com/b/c")["example.com/b/c"]; pkg == nil || len(pkg.Errors) > 0 {
+		t.Errorf("loading example.com/b/c in module a: got %v", pkg)
+	}
+
+	// The overlay of the go.work file removes a module.
+	overlay := map[string][]byte{filepath.Join(tmp, "go.work"): []byte("go 1.18\n\nuse ./b\n")}
+	pkgs = load(".", overlay, "./...")
+	if len(pkgs) != 2 || pkgs["example.com/b"] == nil || pkgs["example.com/b/c"] == nil {
+		t.Errorf("with overlaid go.work, ./... matched %v, want the packages of example.com/b", pkgs)
+	}
+}
+
+func TestReverseIndex(t *testing.T) {
+	// d <- b <- a, d <- c <- a, c <- e
+	d := &packages.Package{ID: "d"}
+	b := &packages.Package{ID: "b", Imports: map[string]*packages.Package{"d": d}}
+	c := &packages.Package{ID: "c", Imports: map[string]*packages.Package{"d": d, "vendor/d": d}}
+	a := &packages.Package{ID: "a", Imports: map[string]*packages.Package{"b": b, "c": c}}
+	e := &packages.Package{ID: "e", Imports: map[string]*packages.Package{"c": c}}
+	idx := packages.NewReverseIndex([]*packages.Package{a, e})
+
+	for _, test := range []struct {
+		id, direct, transitive string
+	}{
+		{"d", "[b c]", "[a b c e]"},
+		{"c", "[a e]", "[a e]"},
+		{"b", "[a]", "[a]"},
+		{"a", "[]", "[]"},
+		{"nonexistent", "[]", "[]"},
+	} {
+		if got := fmt.Sprint(idx.Importers(test.id)); got != test.direct {
+			t.Errorf("Importers(%s) = %s, want %s", test.id, got, test.direct)
+		}
+		if got := fmt.Sprint(idx.TransitiveImporters(test.id)); got != test.transitive {
+			t.Errorf("TransitiveImporters(%s) = %s, want %s", test.id, got, test.transitive)
+		}
+	}
+	if got := fmt.Sprint(packages.ReverseDeps([]*packages.Package{a}, "d")); got != "[a b c]" {
+		t.Errorf("ReverseDeps(a, d) = %s, want [a b c]", got)
+	}
+	if idx.Package("c") != c || idx.Package("nonexistent") != nil {
+		t.Errorf("Package returned the wrong packages")
+	}
+}
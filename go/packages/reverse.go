@@ -0,0 +1,86 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import "sort"
+
+// A ReverseIndex records the importers of each package of an import
+// graph, so as to answer which packages import a given one, directly
+// or transitively.
+//
+// Packages are identified by their ID, which for the go command is the
+// import path of the package, but for test variants such as
+// "fmt [fmt.test]". A ReverseIndex is safe for concurrent use.
+type ReverseIndex struct {
+	pkgs      map[string]*Package   // packages of the graph, by ID
+	importers map[string][]*Package // direct importers of each package, by ID, sorted by ID
+}
+
+// NewReverseIndex returns the index of the import graph whose roots are
+// pkgs, which must have their Imports, as with NeedImports and NeedDeps.
+func NewReverseIndex(pkgs []*Package) *ReverseIndex {
+	idx := &ReverseIndex{
+		pkgs:      make(map[string]*Package),
+		importers: make(map[string][]*Package),
+	}
+	Visit(pkgs, nil, func(pkg *Package) {
+		idx.pkgs[pkg.ID] = pkg
+		seen := make(map[string]bool, len(pkg.Imports))
+		for _, imp := range pkg.Imports {
+			if !seen[imp.ID] { // a package may be imported under several paths
+				seen[imp.ID] = true
+				idx.importers[imp.ID] = append(idx.importers[imp.ID], pkg)
+			}
+		}
+	})
+	for _, importers := range idx.importers {
+		sortByID(importers)
+	}
+	return idx
+}
+
+// Package returns the package of the graph with the given ID, or nil.
+func (idx *ReverseIndex) Package(id string) *Package {
+	return idx.pkgs[id]
+}
+
+// Importers returns the packages of the graph that import the package
+// with the given ID directly, sorted by ID.
+func (idx *ReverseIndex) Importers(id string) []*Package {
+	return append([]*Package(nil), idx.importers[id]...)
+}
+
+// TransitiveImporters returns the packages of the graph that import the
+// package with the given ID, directly or indirectly, sorted by ID: those
+// that a change of the package may affect.
+func (idx *ReverseIndex) TransitiveImporters(id string) []*Package {
+	seen := make(map[string]bool)
+	var res []*Package
+	var visit func(id string)
+	visit = func(id string) {
+		for _, pkg := range idx.importers[id] {
+			if !seen[pkg.ID] {
+				seen[pkg.ID] = true
+				res = append(res, pkg)
+				visit(pkg.ID)
+			}
+		}
+	}
+	visit(id)
+	sortByID(res)
+	return res
+}
+
+// ReverseDeps returns the packages of the import graph whose roots are
+// pkgs that import the package with the ID target, directly or
+// indirectly, sorted by ID. For several queries over the same graph,
+// use a ReverseIndex.
+func ReverseDeps(pkgs []*Package, target string) []*Package {
+	return NewReverseIndex(pkgs).TransitiveImporters(target)
+}
+
+func sortByID(pkgs []*Package) {
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].ID < pkgs[j].ID })
+}
@@ -0,0 +1,248 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"encoding/json"
+	"errors"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// A Session loads the packages matching a set of patterns, like Load,
+// and reloads them incrementally as their files change.
+//
+// Each load of a session produces a Snapshot of the package graph.
+// Reload queries the driver again, but only loads and type-checks anew
+// the packages that changed and those that depend on them: those of the
+// previous snapshot whose metadata and files are unchanged, as are those
+// of all of their dependencies, are part of the new snapshot as they
+// are, and so keep their identity.
+//
+// The packages of a session always have their Name, PkgPath and
+// Imports, whatever the mode of its Config, and share its FileSet.
+// A Session is not safe for concurrent use.
+type Session struct {
+	cfg      Config
+	patterns []string
+	snapshot *Snapshot
+}
+
+// A Snapshot is the package graph of a Session at one point in time.
+//
+// The packages of a snapshot may be shared by later snapshots, and must
+// not be modified.
+type Snapshot struct {
+	// Initial holds the packages matched by the patterns of the
+	// session, like the result of Load.
+	Initial []*Package
+
+	// Reloaded holds the packages that were loaded anew for the
+	// snapshot, sorted by ID: all of them for the first snapshot of a
+	// session, and those that changed or depend on one that did for
+	// later ones.
+	Reloaded []*Package
+
+	pkgs     map[string]*snapshotPackage
+	roots    map[string]bool      // IDs of the initial packages
+	modTimes map[string]time.Time // modification times of the files of Config.FS
+}
+
+// A snapshotPackage records the state of a package of a Snapshot.
+type snapshotPackage struct {
+	*loaderPackage
+	metadata string   // the JSON form of the package in the driver's response
+	files    []string // the files of the package
+	imports  []string // the IDs of the packages it imports
+}
+
+// errReload is the error of refine when a reused package would be
+// loaded differently than in its snapshot.
+var errReload = errors.New("reused package is loaded differently")
+
+// NewSession loads the packages matching the patterns, as Load does,
+// and returns a Session whose first snapshot holds them.
+func NewSession(cfg *Config, patterns ...string) (*Session, error) {
+	s := &Session{patterns: patterns}
+	if cfg != nil {
+		s.cfg = *cfg
+	}
+	if s.cfg.Mode == 0 {
+		s.cfg.Mode = NeedName | NeedFiles | NeedCompiledGoFiles // Preserve zero behavior of Mode, as in Load.
+	}
+	// Reloading needs the import graph, and export data that the
+	// PkgPath of each package identifies.
+	s.cfg.Mode |= NeedName | NeedImports
+	if s.cfg.Fset == nil {
+		s.cfg.Fset = token.NewFileSet()
+	}
+	snapshot, err := s.load(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.snapshot = snapshot
+	return s, nil
+}
+
+// Snapshot returns the current snapshot of the session.
+func (s *Session) Snapshot() *Snapshot {
+	return s.snapshot
+}
+
+// Reload reloads the packages of the session after a change of the
+// named files, and returns the new snapshot of the session. The files
+// may be of any package of the session, or new ones. The changes of the
+// files of Config.FS are found from their modification times, and need
+// not be named.
+//
+// If the driver fails, Reload returns the error and the session keeps
+// its current snapshot.
+func (s *Session) Reload(changed ...string) (*Snapshot, error) {
+	files := make(map[string]bool, len(changed))
+	for _, name := range changed {
+		files[s.cleanPath(name)] = true
+	}
+	snapshot, err := s.load(s.snapshot, files)
+	if err != nil {
+		return nil, err
+	}
+	s.snapshot = snapshot
+	return snapshot, nil
+}
+
+// load queries the driver for the patterns of the session and loads
+// their packages, reusing those of the previous snapshot, if any, that
+// are unaffected by the changed files.
+func (s *Session) load(prev *Snapshot, changed map[string]bool) (*Snapshot, error) {
+	ld := newLoader(&s.cfg)
+	defer ld.reportStats()
+	response, err := ld.runDriver(s.patterns...)
+	if err != nil {
+		return nil, err
+	}
+	ld.sizes = response.Sizes
+
+	// Record the metadata of the packages before refine replaces the
+	// stubs of their imports.
+	next := &Snapshot{
+		pkgs:     make(map[string]*snapshotPackage, len(response.Packages)),
+		roots:    make(map[string]bool, len(response.Roots)),
+		modTimes: ld.fsModTimes,
+	}
+	for _, root := range response.Roots {
+		next.roots[root] = true
+	}
+	for _, pkg := range response.Packages {
+		data, err := json.Marshal(pkg)
+		if err != nil {
+			return nil, err
+		}
+		spkg := &snapshotPackage{metadata: string(data)}
+		for _, list := range [][]string{pkg.GoFiles, pkg.CompiledGoFiles, pkg.OtherFiles, pkg.EmbedFiles} {
+			for _, name := range list {
+				spkg.files = append(spkg.files, s.cleanPath(name))
+			}
+		}
+		for _, imp := range pkg.Imports {
+			spkg.imports = append(spkg.imports, imp.ID)
+		}
+		next.pkgs[pkg.ID] = spkg
+	}
+
+	if prev != nil {
+		changedFiles(prev.modTimes, next.modTimes, changed)
+		ld.reuse = reusable(prev, next, changed)
+	}
+	initial, err := ld.refine(response.Roots, response.Packages...)
+	if err == errReload {
+		return s.load(nil, nil) // load all packages anew
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	next.Initial = initial
+	for id, lpkg := range ld.pkgs {
+		next.pkgs[id].loaderPackage = lpkg
+		if !lpkg.reused {
+			next.Reloaded = append(next.Reloaded, lpkg.Package)
+		}
+	}
+	sort.Slice(next.Reloaded, func(i, j int) bool { return next.Reloaded[i].ID < next.Reloaded[j].ID })
+	return next, nil
+}
+
+// reusable returns the packages of the previous snapshot that the next
+// one may use as they are, by ID: those whose metadata, files and
+// dependencies are unchanged.
+func reusable(prev, next *Snapshot, changed map[string]bool) map[string]*loaderPackage {
+	reuse := make(map[string]*loaderPackage)
+	memo := make(map[string]bool)
+	var reusable func(id string) bool
+	reusable = func(id string) bool {
+		if ok, seen := memo[id]; seen {
+			return ok
+		}
+		memo[id] = false // packages in import cycles are not reused
+		npkg, ppkg := next.pkgs[id], prev.pkgs[id]
+		if npkg == nil || ppkg == nil || ppkg.loaderPackage == nil || npkg.metadata != ppkg.metadata || next.roots[id] != prev.roots[id] {
+			return false
+		}
+		for _, name := range npkg.files {
+			if changed[name] {
+				return false
+			}
+		}
+		for _, imp := range npkg.imports {
+			if !reusable(imp) {
+				return false
+			}
+		}
+		memo[id] = true
+		reuse[id] = ppkg.loaderPackage
+		return true
+	}
+	for id := range next.pkgs {
+		reusable(id)
+	}
+	return reuse
+}
+
+// cleanPath returns the clean absolute form of a file name, which is
+// relative to the directory of the session if not absolute.
+func (s *Session) cleanPath(name string) string {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	if s.cfg.Dir != "" {
+		return filepath.Join(s.cfg.Dir, name)
+	}
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return filepath.Clean(name)
+}
+
+// Package returns the package of the snapshot with the given ID, or nil.
+func (s *Snapshot) Package(id string) *Package {
+	if spkg := s.pkgs[id]; spkg != nil && spkg.loaderPackage != nil {
+		return spkg.Package
+	}
+	return nil
+}
+
+// Packages returns all the packages of the snapshot, sorted by ID.
+func (s *Snapshot) Packages() []*Package {
+	var pkgs []*Package
+	for _, spkg := range s.pkgs {
+		if spkg.loaderPackage != nil {
+			pkgs = append(pkgs, spkg.Package)
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].ID < pkgs[j].ID })
+	return pkgs
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// LoadStats holds statistics of a load, which Config.Stats receives, to
+// help find out why a load is slow.
+//
+// The times of parsing and type-checking are the sums of those of all
+// files and packages, which the loader processes in parallel: they may
+// exceed the Duration of the load.
+type LoadStats struct {
+	Duration time.Duration // the duration of the whole load
+
+	DriverCalls     int           // queries of the driver, including those served from the cache
+	DriverTime      time.Duration // time spent in queries of the driver
+	CachedResponses int           // responses of the driver read from Config.CacheDir
+	GoCommandCalls  int           // invocations of the go command, by the go list driver
+	GoCommandTime   time.Duration // time spent in invocations of the go command
+
+	Packages           int // packages of the graph
+	SourcePackages     int // packages parsed, and type-checked if needed, from source
+	ExportDataPackages int // packages whose types were read from export data
+	CachedPackages     int // packages whose types were read from Config.CacheDir
+	SharedPackages     int // packages whose results LoadConfigs shared with another configuration
+
+	ParsedFiles   int           // files read and parsed
+	ParseTime     time.Duration // time spent reading and parsing files
+	TypeCheckTime time.Duration // time spent type-checking packages
+
+	// PeakHeap is the largest size of the heap, in bytes, as observed
+	// after loading each package.
+	PeakHeap uint64
+}
+
+// A statsRecorder records the statistics of a load.
+type statsRecorder struct {
+	start time.Time
+	mu    sync.Mutex
+	stats LoadStats
+}
+
+// record calls update with the statistics of the load, if recorded.
+func (r *statsRecorder) record(update func(stats *LoadStats)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	update(&r.stats)
+	r.mu.Unlock()
+}
+
+// sampleHeap records the current size of the heap, if statistics are
+// recorded.
+func (r *statsRecorder) sampleHeap() {
+	if r == nil {
+		return
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	r.record(func(stats *LoadStats) {
+		if m.HeapAlloc > stats.PeakHeap {
+			stats.PeakHeap = m.HeapAlloc
+		}
+	})
+}
+
+// now returns the current time, or the zero time if statistics are not
+// recorded, so as not to read the clock in vain.
+func (r *statsRecorder) now() time.Time {
+	if r == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// reportStats calls Config.Stats with the statistics of the load, if
+// recorded.
+func (ld *loader) reportStats() {
+	r := ld.stats
+	if r == nil {
+		return
+	}
+	r.sampleHeap()
+	r.mu.Lock()
+	stats := r.stats
+	r.mu.Unlock()
+	stats.Duration = time.Since(r.start)
+	stats.Packages = len(ld.pkgs)
+	ld.Stats(&stats)
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// readFS adds the files of Config.FS to the overlay of the loader, under
+// Config.Dir, unless Config.Overlay has files of the same names, and
+// records their modification times.
+func (ld *loader) readFS() error {
+	dir, err := filepath.Abs(ld.Dir)
+	if err != nil {
+		return fmt.Errorf("reading Config.FS: %v", err)
+	}
+	overlay := make(map[string][]byte, len(ld.Overlay))
+	for name, contents := range ld.Overlay {
+		overlay[name] = contents
+	}
+	modTimes := make(map[string]time.Time)
+	err = fs.WalkDir(ld.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(dir, filepath.FromSlash(path))
+		modTimes[name] = info.ModTime()
+		for f := range ld.Overlay {
+			if sameFile(f, name) {
+				return nil
+			}
+		}
+		contents, err := fs.ReadFile(ld.FS, path)
+		if err != nil {
+			return err
+		}
+		overlay[name] = contents
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reading Config.FS: %v", err)
+	}
+	ld.Overlay = overlay
+	ld.fsModTimes = modTimes
+	return nil
+}
+
+// changedFiles adds to changed the files of the FS of the current load
+// whose modification times differ from those of the previous one, and
+// those of either that the other lacks.
+func changedFiles(prev, next map[string]time.Time, changed map[string]bool) {
+	for name, t := range next {
+		if pt, ok := prev[name]; !ok || !pt.Equal(t) {
+			changed[name] = true
+		}
+	}
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			changed[name] = true
+		}
+	}
+}
@@ -120,6 +120,7 @@ type analysis struct {
 	globalobj   map[ssa.Value]nodeid        // maps v to sole member of pts(v), if singleton
 	localval    map[ssa.Value]nodeid        // node for each local ssa.Value
 	localobj    map[ssa.Value]nodeid        // maps v to sole member of pts(v), if singleton
+	sharedobj   map[ssa.Value]nodeid        // object of each allocation, shared by contours if NoHeapCloning
 	atFuncs     map[*ssa.Function]bool      // address-taken functions (for presolver)
 	mapValues   []nodeid                    // values of makemap objects (indirect in HVN)
 	work        nodeset                     // solver's worklist
@@ -217,6 +218,11 @@ func Analyze(config *Config) (result *Result, err error) {
 	if config.Mains == nil {
 		return nil, fmt.Errorf("no main/test packages to analyze (check $GOROOT/$GOPATH)")
 	}
+	if prev := config.Previous; prev != nil {
+		if result := prev.reuse(config); result != nil {
+			return result, nil
+		}
+	}
 	defer func() {
 		if p := recover(); p != nil {
 			err = fmt.Errorf("internal error in pointer analysis: %v (please report this bug)", p)
@@ -231,6 +237,7 @@ func Analyze(config *Config) (result *Result, err error) {
 		prog:        config.prog(),
 		globalval:   make(map[ssa.Value]nodeid),
 		globalobj:   make(map[ssa.Value]nodeid),
+		sharedobj:   make(map[ssa.Value]nodeid),
 		flattenMemo: make(map[types.Type][]*fieldInfo),
 		trackTypes:  make(map[types.Type]bool),
 		atFuncs:     make(map[*ssa.Function]bool),
@@ -353,6 +360,10 @@ func Analyze(config *Config) (result *Result, err error) {
 		}
 	}
 
+	if config.Incremental {
+		a.recordDependencies()
+	}
+
 	return a.result, nil
 }
 
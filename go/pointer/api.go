@@ -38,6 +38,26 @@ type Config struct {
 	// has not yet been reduced by presolver optimisation.
 	Reflection bool
 
+	// ContextDepth and ContextSize determine the context
+	// sensitivity of the analysis: the small functions that it
+	// analyzes anew for each static call to them, in a contour of
+	// their own, rather than once for all calls. A function is
+	// small if it has a single block of at most ContextSize
+	// instructions; zero means 10. ContextDepth bounds the nesting
+	// of such contours, as k does in k-CFA. Zero means 1: only the
+	// small functions that call nothing but built-ins have contours
+	// of their own, as do intrinsics and synthetic wrappers, however
+	// deeply nested. See the package documentation for the costs.
+	ContextDepth int
+	ContextSize  int
+
+	// NoHeapCloning causes the objects allocated by new, make and
+	// composite literals in the contours of a small function to be
+	// shared by all of them, rather than allocated anew in each,
+	// which saves memory at the expense of the precision that the
+	// contours would otherwise give.
+	NoHeapCloning bool
+
 	// BuildCallGraph determines whether to construct a callgraph.
 	// If enabled, the graph will be available in Result.CallGraph.
 	BuildCallGraph bool
@@ -68,6 +88,40 @@ type Config struct {
 	// If Log is non-nil, log messages are written to it.
 	// Logging is extremely verbose.
 	Log io.Writer
+
+	// Incremental causes the result to record the functions and
+	// types on which it depends, so that it may be reused by a
+	// later analysis, through Previous.
+	Incremental bool
+
+	// Previous is the result of an earlier analysis, under
+	// Incremental, that the analysis reuses instead of solving the
+	// problem anew if it can: if it has the same options and main
+	// packages, requests no queries, and if the SSA form of every
+	// function that the earlier analysis analyzed, and the set of
+	// runtime types, are unchanged. The result of the earlier
+	// analysis is then translated to the functions of the new
+	// program. Otherwise the analysis runs from scratch. The
+	// typical use is by a tool that analyzes a program anew after
+	// edits, most of which do not affect the code reachable from
+	// its main packages; see the package documentation.
+	Previous *Result
+}
+
+// contextDepth returns the effective Config.ContextDepth.
+func (c *Config) contextDepth() int {
+	if c.ContextDepth <= 0 {
+		return 1
+	}
+	return c.ContextDepth
+}
+
+// contextSize returns the effective Config.ContextSize.
+func (c *Config) contextSize() int {
+	if c.ContextSize <= 0 {
+		return 10
+	}
+	return c.ContextSize
 }
 
 type track uint32
@@ -166,6 +220,9 @@ type Result struct {
 	Queries         map[ssa.Value]Pointer // pts(v) for each v in Config.Queries.
 	IndirectQueries map[ssa.Value]Pointer // pts(*v) for each v in Config.IndirectQueries.
 	Warnings        []Warning             // warnings of unsoundness
+	Reused          bool                  // the result is that of Config.Previous, translated
+
+	deps *dependencies // what the result depends on, under Config.Incremental
 }
 
 // A Pointer is an equivalence class of pointer-like values.
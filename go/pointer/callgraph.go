@@ -18,6 +18,7 @@ type cgnode struct {
 	obj        nodeid      // start of this contour's object block
 	sites      []*callsite // ordered list of callsites within this function
 	callersite *callsite   // where called from, if known; nil for shared contours
+	depth      int         // number of nested contours of small functions; see shouldUseContext
 }
 
 // contour returns a description of this node's contour.
@@ -45,13 +45,49 @@ site and context, so the objects returned by two distinct calls to f:
 
 	func f() *T { return new(T) }
 
-are distinguished up to the limits of the calling context.
+are distinguished up to the limits of the calling context, unless
+Config.NoHeapCloning is set.
 
 It is a WHOLE PROGRAM analysis: it requires SSA-form IR for the
 complete Go program and summaries for native code.
 
 See the (Hind, PASTE'01) survey paper for an explanation of these terms.
 
+# CONTEXT SENSITIVITY AND COST
+
+The Config.ContextDepth, Config.ContextSize and Config.NoHeapCloning
+options trade the memory and time of the analysis for precision.
+The cost grows with the number of contours, which may grow
+exponentially with the depth, since each contour of a function that
+calls small functions has contours of its own for each of them.
+Roughly:
+
+	ContextDepth  ContextSize  NoHeapCloning  Memory and time   Precision gained
+	0 (1)         0 (10)       false          baseline          accessors, wrappers
+	0 (1)         0 (10)       true           less              none for the heap of accessors
+	0 (1)         larger       false          more              larger leaf functions
+	2             0 (10)       false          much more         small helpers of helpers
+	3 or more     any          false          often prohibitive deeper chains of helpers
+
+These are qualitative: the actual costs depend on the shape of the
+program, and are best measured on it.
+
+Programs that allocate much in small constructors benefit most from
+heap cloning; programs dominated by interface calls benefit little
+from greater depth, as dynamic calls are never analyzed in a context
+of their own.
+
+# INCREMENTAL ANALYSIS
+
+A tool that analyzes a program repeatedly as it is edited may set
+Config.Incremental, and pass the result of each analysis to the next
+as Config.Previous. An analysis that requests no queries reuses the
+call graph and the warnings of the previous result if none of the
+functions that the previous analysis reached has changed, nor the
+set of runtime types; Result.Reused reports whether it did. Edits to
+other code, such as that of tests or of unused packages, thus cost
+only the comparison of the reached functions, not a new solution.
+
 # SOUNDNESS
 
 The analysis is fully sound when invoked on pure Go programs that do not
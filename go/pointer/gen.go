@@ -530,37 +530,42 @@ func (a *analysis) genBuiltinCall(instr ssa.CallInstruction, cgn *cgnode) {
 }
 
 // shouldUseContext defines the context-sensitivity policy.  It
-// returns true if we should analyse all static calls to fn anew.
+// returns true if we should analyse the static call to fn from the
+// caller anew, and the depth of the new contour.
 //
 // Obviously this interface rather limits how much freedom we have to
 // choose a policy.  The current policy, rather arbitrarily, is true
-// for intrinsics and accessor methods (actually: short, single-block,
-// call-free functions).  This is just a starting point.
-func (a *analysis) shouldUseContext(fn *ssa.Function) bool {
+// for intrinsics and accessor methods (actually: short, single-block
+// functions, which call nothing but built-ins unless the contour is
+// shallower than Config.ContextDepth).  This is just a starting point.
+func (a *analysis) shouldUseContext(caller *cgnode, fn *ssa.Function) (bool, int) {
 	if a.findIntrinsic(fn) != nil {
-		return true // treat intrinsics context-sensitively
+		return true, caller.depth // treat intrinsics context-sensitively
 	}
 	if len(fn.Blocks) != 1 {
-		return false // too expensive
+		return false, 0 // too expensive
 	}
 	blk := fn.Blocks[0]
-	if len(blk.Instrs) > 10 {
-		return false // too expensive
+	if len(blk.Instrs) > a.config.contextSize() {
+		return false, 0 // too expensive
 	}
 	if fn.Synthetic != "" && (fn.Pkg == nil || fn != fn.Pkg.Func("init")) {
-		return true // treat synthetic wrappers context-sensitively
+		return true, caller.depth // treat synthetic wrappers context-sensitively
 	}
+	depth := caller.depth + 1
+	maxDepth := a.config.contextDepth()
 	for _, instr := range blk.Instrs {
 		switch instr := instr.(type) {
 		case ssa.CallInstruction:
 			// Disallow function calls (except to built-ins)
-			// because of the danger of unbounded recursion.
-			if _, ok := instr.Common().Value.(*ssa.Builtin); !ok {
-				return false
+			// from the deepest contours because of the
+			// danger of unbounded recursion.
+			if _, ok := instr.Common().Value.(*ssa.Builtin); !ok && depth >= maxDepth {
+				return false, 0
 			}
 		}
 	}
-	return true
+	return depth <= maxDepth, depth
 }
 
 // genStaticCall generates constraints for a statically dispatched function call.
@@ -591,8 +596,9 @@ func (a *analysis) genStaticCall(caller *cgnode, site *callsite, call *ssa.CallC
 
 	// Ascertain the context (contour/cgnode) for a particular call.
 	var obj nodeid
-	if a.shouldUseContext(fn) {
+	if ok, depth := a.shouldUseContext(caller, fn); ok {
 		obj = a.makeFunctionObject(fn, site) // new contour
+		a.nodes[obj].obj.cgn.depth = depth
 	} else {
 		obj = a.objectNode(nil, fn) // shared contour
 	}
@@ -814,6 +820,13 @@ func (a *analysis) objectNode(cgn *cgnode, v ssa.Value) nodeid {
 
 	// Local object.
 	obj, ok := a.localobj[v]
+	shared := a.config.NoHeapCloning && cgn != nil && cgn.callersite != nil
+	if !ok && shared {
+		// Another contour of the function may have allocated it.
+		if obj, ok = a.sharedobj[v]; ok {
+			a.localobj[v] = obj
+		}
+	}
 	if !ok {
 		switch v := v.(type) {
 		case *ssa.Alloc:
@@ -883,6 +896,12 @@ func (a *analysis) objectNode(cgn *cgnode, v ssa.Value) nodeid {
 			fmt.Fprintf(a.log, "\tlocalobj[%s] = n%d\n", v.Name(), obj)
 		}
 		a.localobj[v] = obj
+		if shared {
+			switch v.(type) {
+			case *ssa.Alloc, *ssa.MakeSlice, *ssa.MakeChan, *ssa.MakeMap:
+				a.sharedobj[v] = obj
+			}
+		}
 	}
 	return obj
 }
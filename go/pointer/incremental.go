@@ -0,0 +1,272 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pointer
+
+// This file implements the reuse of the result of an earlier analysis
+// (Config.Previous) by an analysis of a program that differs from the
+// earlier one only in code that the earlier analysis did not reach.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// The dependencies of a result are what an analysis must find
+// unchanged to reuse it.
+type dependencies struct {
+	options string            // the options of the configuration
+	mains   []string          // the import paths of the main packages, sorted
+	funcs   map[string]string // hash of each function analyzed, by name
+	rtypes  []string          // the runtime types of the program, sorted
+	fset    *token.FileSet    // of the program analyzed
+}
+
+// options returns a description of the options of the configuration
+// on which the result of the analysis depends.
+func (c *Config) options() string {
+	return fmt.Sprintf("reflection=%t callgraph=%t depth=%d size=%d noheapcloning=%t",
+		c.Reflection, c.BuildCallGraph, c.contextDepth(), c.contextSize(), c.NoHeapCloning)
+}
+
+// hasQueries reports whether the configuration requests queries.
+func (c *Config) hasQueries() bool {
+	return len(c.Queries) > 0 || len(c.IndirectQueries) > 0 || len(c.extendedQueries) > 0
+}
+
+func mainPaths(mains []*ssa.Package) []string {
+	var paths []string
+	for _, main := range mains {
+		paths = append(paths, main.Pkg.Path())
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func runtimeTypes(prog *ssa.Program) []string {
+	var rtypes []string
+	for _, T := range prog.RuntimeTypes() {
+		rtypes = append(rtypes, T.String())
+	}
+	sort.Strings(rtypes)
+	return rtypes
+}
+
+// recordDependencies records the dependencies of the result of the
+// analysis: the functions of its contours, and their types.
+func (a *analysis) recordDependencies() {
+	deps := &dependencies{
+		options: a.config.options(),
+		mains:   mainPaths(a.config.Mains),
+		funcs:   make(map[string]string),
+		rtypes:  runtimeTypes(a.prog),
+		fset:    a.prog.Fset,
+	}
+	for _, cgn := range a.cgnodes {
+		if cgn.obj == 0 {
+			continue // the synthetic root
+		}
+		if name := cgn.fn.String(); deps.funcs[name] == "" {
+			deps.funcs[name] = hashFunction(cgn.fn)
+		}
+	}
+	a.result.deps = deps
+}
+
+// hashFunction returns the hash of the SSA form of the function, and
+// of the underlying types of the named types of its values.
+func hashFunction(fn *ssa.Function) string {
+	h := sha256.New()
+	fn.WriteTo(h)
+	seen := make(map[types.Type]bool)
+	writeTypes(h, fn.Signature, seen)
+	var space [10]*ssa.Value
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if v, ok := instr.(ssa.Value); ok {
+				writeTypes(h, v.Type(), seen)
+			}
+			for _, op := range instr.Operands(space[:0]) {
+				if *op != nil {
+					writeTypes(h, (*op).Type(), seen)
+				}
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeTypes writes the underlying type of each named type on which
+// the type T depends, once.
+func writeTypes(w io.Writer, T types.Type, seen map[types.Type]bool) {
+	if seen[T] {
+		return
+	}
+	seen[T] = true
+	switch T := T.(type) {
+	case *types.Named:
+		fmt.Fprintf(w, "type %s %s\n", T, T.Underlying())
+		writeTypes(w, T.Underlying(), seen)
+	case *types.Pointer:
+		writeTypes(w, T.Elem(), seen)
+	case *types.Slice:
+		writeTypes(w, T.Elem(), seen)
+	case *types.Array:
+		writeTypes(w, T.Elem(), seen)
+	case *types.Chan:
+		writeTypes(w, T.Elem(), seen)
+	case *types.Map:
+		writeTypes(w, T.Key(), seen)
+		writeTypes(w, T.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < T.NumFields(); i++ {
+			writeTypes(w, T.Field(i).Type(), seen)
+		}
+	case *types.Tuple:
+		for i := 0; i < T.Len(); i++ {
+			writeTypes(w, T.At(i).Type(), seen)
+		}
+	case *types.Signature:
+		writeTypes(w, T.Params(), seen)
+		writeTypes(w, T.Results(), seen)
+	}
+}
+
+// reuse returns the result r of an earlier analysis, translated to
+// the program of the configuration, or nil if the analysis of the
+// configuration may not reuse it.
+func (r *Result) reuse(config *Config) *Result {
+	deps := r.deps
+	if deps == nil || config.hasQueries() || deps.options != config.options() ||
+		!equalStrings(deps.mains, mainPaths(config.Mains)) {
+		return nil
+	}
+	prog := config.prog()
+	if !equalStrings(deps.rtypes, runtimeTypes(prog)) {
+		return nil
+	}
+
+	// The functions of the new program that the old one analyzed
+	// must be unchanged, and unambiguously named.
+	funcs := make(map[string]*ssa.Function)
+	ambiguous := make(map[string]bool)
+	for fn := range ssautil.AllFunctions(prog) {
+		name := fn.String()
+		if _, ok := funcs[name]; ok {
+			ambiguous[name] = true
+		}
+		funcs[name] = fn
+	}
+	for name, hash := range deps.funcs {
+		if fn := funcs[name]; fn == nil || ambiguous[name] || hashFunction(fn) != hash {
+			return nil
+		}
+	}
+
+	result := &Result{
+		Queries:         make(map[ssa.Value]Pointer),
+		IndirectQueries: make(map[ssa.Value]Pointer),
+		Reused:          true,
+		deps: &dependencies{
+			options: deps.options,
+			mains:   deps.mains,
+			funcs:   deps.funcs,
+			rtypes:  deps.rtypes,
+			fset:    prog.Fset,
+		},
+	}
+	for _, w := range r.Warnings {
+		result.Warnings = append(result.Warnings, Warning{
+			Pos:     translatePos(deps.fset, prog.Fset, w.Pos),
+			Message: w.Message,
+		})
+	}
+	if cg := r.CallGraph; cg != nil {
+		root := prog.NewFunction("<root>", new(types.Signature), "root of callgraph")
+		newcg := callgraph.New(root)
+		translate := func(n *callgraph.Node) *callgraph.Node {
+			if n == cg.Root {
+				return newcg.Root
+			}
+			return newcg.CreateNode(funcs[n.Func.String()])
+		}
+
+		// Create the nodes and edges in the order of the old ones.
+		var nodes []*callgraph.Node
+		for _, n := range cg.Nodes {
+			nodes = append(nodes, n)
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+		for _, n := range nodes {
+			translate(n)
+		}
+		for _, n := range nodes {
+			caller := translate(n)
+			for _, e := range n.Out {
+				callgraph.AddEdge(caller, translateSite(e.Site, caller.Func), translate(e.Callee))
+			}
+		}
+		result.CallGraph = newcg
+	}
+	return result
+}
+
+// translateSite returns the call instruction of the function fn that
+// corresponds to the site, in a function whose SSA form is the same
+// as that of fn.
+func translateSite(site ssa.CallInstruction, fn *ssa.Function) ssa.CallInstruction {
+	if site == nil {
+		return nil // a call from the root
+	}
+	b := site.Block()
+	for i, instr := range b.Instrs {
+		if instr == site {
+			return fn.Blocks[b.Index].Instrs[i].(ssa.CallInstruction)
+		}
+	}
+	panic(fmt.Sprintf("call %s is not in its block", site))
+}
+
+// translatePos returns the position in the file set to that
+// corresponds to the position in the file set from, in a file of the
+// same name and size.
+func translatePos(from, to *token.FileSet, pos token.Pos) token.Pos {
+	if from == to || !pos.IsValid() {
+		return pos
+	}
+	f := from.File(pos)
+	if f == nil {
+		return token.NoPos
+	}
+	result := token.NoPos
+	to.Iterate(func(g *token.File) bool {
+		if g.Name() == f.Name() && g.Size() == f.Size() {
+			result = g.Pos(f.Offset(pos))
+			return false
+		}
+		return true
+	})
+	return result
+}
+
+func equalStrings(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pointer_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/pointer"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// buildMain returns the built SSA package of the import-free main
+// package of the source.
+func buildMain(t *testing.T, src string) *ssa.Package {
+	var conf loader.Config
+	f, err := conf.ParseFile("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("main", f)
+	lprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(lprog, 0)
+	prog.Build()
+	return prog.Package(lprog.Created[0].Pkg)
+}
+
+const contextSrc = `package main
+
+var a, b int
+var pa, pb, qa, qb *int
+
+func id(x *int) *int { return x }
+
+func wrap(x *int) *int { return id(x) }
+
+func alloc() *int { return new(int) }
+
+func main() {
+	pa = wrap(&a)
+	pb = wrap(&b)
+	qa = alloc()
+	qb = alloc()
+}
+`
+
+func TestContextSensitivity(t *testing.T) {
+	main := buildMain(t, contextSrc)
+
+	// The values of the calls of main: wrap(&a), alloc() and alloc().
+	var calls []ssa.Value
+	for _, instr := range main.Func("main").Blocks[0].Instrs {
+		if call, ok := instr.(*ssa.Call); ok {
+			calls = append(calls, call)
+		}
+	}
+	wrapA, allocA, allocB := calls[0], calls[2], calls[3]
+
+	for _, test := range []struct {
+		depth         int
+		noHeapCloning bool
+		wrapA         string // pts(wrap(&a))
+		allocAlias    bool   // whether the results of alloc may alias
+	}{
+		{0, false, "[main.a main.b]", false},
+		{2, false, "[main.a]", false},
+		{0, true, "[main.a main.b]", true},
+	} {
+		config := &pointer.Config{
+			Mains:         []*ssa.Package{main},
+			ContextDepth:  test.depth,
+			NoHeapCloning: test.noHeapCloning,
+		}
+		for _, v := range calls {
+			config.AddQuery(v)
+		}
+		result, err := pointer.Analyze(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var labels []string
+		for _, l := range result.Queries[wrapA].PointsTo().Labels() {
+			labels = append(labels, l.String())
+		}
+		sort.Strings(labels)
+		if got := fmt.Sprint(labels); got != test.wrapA {
+			t.Errorf("depth %d: pts(wrap(&a)) = %s, want %s", test.depth, got, test.wrapA)
+		}
+		if got := result.Queries[allocA].MayAlias(result.Queries[allocB]); got != test.allocAlias {
+			t.Errorf("NoHeapCloning=%t: results of alloc may alias: %t, want %t", test.noHeapCloning, got, test.allocAlias)
+		}
+	}
+}
+
+const incrementalSrc = `package main
+
+type I interface{ M() }
+
+type T int
+
+func (T) M() {}
+
+func f(i I) { i.M() }
+
+func unused() { println("unused") }
+
+func main() {
+	f(T(0))
+}
+`
+
+// edges returns the edges of the call graph.
+func edges(cg *callgraph.Graph) string {
+	var edges []string
+	callgraph.GraphVisitEdges(cg, func(e *callgraph.Edge) error {
+		edges = append(edges, fmt.Sprintf("%s --> %s", e.Caller.Func, e.Callee.Func))
+		return nil
+	})
+	sort.Strings(edges)
+	return strings.Join(edges, "\n")
+}
+
+func TestIncremental(t *testing.T) {
+	analyze := func(src string, prev *pointer.Result) *pointer.Result {
+		result, err := pointer.Analyze(&pointer.Config{
+			Mains:          []*ssa.Package{buildMain(t, src)},
+			BuildCallGraph: true,
+			Incremental:    true,
+			Previous:       prev,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	first := analyze(incrementalSrc, nil)
+	if first.Reused {
+		t.Fatalf("the first analysis reused a result")
+	}
+
+	// A change to an unreachable function does not affect the result.
+	second := analyze(strings.Replace(incrementalSrc, `"unused"`, `"still unused"`, 1), first)
+	if !second.Reused {
+		t.Errorf("the analysis of an unreachable change did not reuse the result")
+	}
+	if got, want := edges(second.CallGraph), edges(first.CallGraph); got != want {
+		t.Errorf("reused call graph:\n%s\nwant:\n%s", got, want)
+	}
+	for _, n := range second.CallGraph.Nodes {
+		for _, e := range n.Out {
+			if e.Site != nil && e.Site.Parent() != n.Func {
+				t.Errorf("edge %s has a site of another function", e)
+			}
+		}
+	}
+
+	// A change to a reachable function does.
+	third := analyze(strings.Replace(incrementalSrc, "func f(i I) { i.M() }", "func f(i I) { i.M(); i.M() }", 1), second)
+	if third.Reused {
+		t.Errorf("the analysis of a reachable change reused the result")
+	}
+}
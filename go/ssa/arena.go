@@ -0,0 +1,77 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssa
+
+// This file defines the arena from which the builder allocates the
+// basic blocks, and the most common instructions, of the functions of
+// a package: in chunks, rather than one by one, which spares the
+// allocator and the garbage collector much work on large programs.
+//
+// An arena belongs to a single builder, and thus to a single
+// goroutine. The values of a chunk are freed together, once none is
+// referenced; as all of them belong to the functions of the package,
+// this seldom retains memory longer than it needs to be.
+
+// chunkSize is the number of values of each chunk of an arena.
+const chunkSize = 64
+
+// An arena allocates instructions and basic blocks. A nil *arena
+// allocates them one by one.
+type arena struct {
+	unOps  chunk[UnOp]
+	stores chunk[Store]
+	jumps  chunk[Jump]
+	ifs    chunk[If]
+	blocks chunk[BasicBlock]
+}
+
+// A chunk holds the values of type T that an arena has yet to
+// allocate.
+type chunk[T any] []T
+
+// new returns a new zero value of type T.
+func (c *chunk[T]) new() *T {
+	if len(*c) == 0 {
+		*c = make([]T, chunkSize)
+	}
+	v := &(*c)[0]
+	*c = (*c)[1:]
+	return v
+}
+
+func (a *arena) unOp() *UnOp {
+	if a == nil {
+		return new(UnOp)
+	}
+	return a.unOps.new()
+}
+
+func (a *arena) store() *Store {
+	if a == nil {
+		return new(Store)
+	}
+	return a.stores.new()
+}
+
+func (a *arena) jump() *Jump {
+	if a == nil {
+		return new(Jump)
+	}
+	return a.jumps.new()
+}
+
+func (a *arena) ifInstr() *If {
+	if a == nil {
+		return new(If)
+	}
+	return a.ifs.new()
+}
+
+func (a *arena) block() *BasicBlock {
+	if a == nil {
+		return new(BasicBlock)
+	}
+	return a.blocks.new()
+}
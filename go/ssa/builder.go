@@ -109,7 +109,9 @@ import (
 	"go/token"
 	"go/types"
 	"os"
+	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"golang.org/x/tools/internal/typeparams"
 )
@@ -148,6 +150,9 @@ type builder struct {
 	created  *creator // functions created during building
 	finished int      // Invariant: create[i].built holds for i in [0,finished)
 	rtypes   int      // Invariant: all of the runtime types for create[i] have been added for i in [0,rtypes)
+
+	parallel bool   // build the created functions in parallel
+	arena    *arena // allocator of the instructions of the functions built, or nil
 }
 
 // cond emits to fn code to evaluate boolean condition e and jump
@@ -2323,6 +2328,7 @@ func (b *builder) buildFunctionBody(fn *Function) {
 		defer logStack("build function %s @ %s", fn, fn.Prog.Fset.Position(fn.pos))()
 	}
 	fn.startBody()
+	fn.arena = b.arena
 	fn.createSyntacticParams(recvField, functype)
 	b.stmt(fn, body)
 	if cb := fn.currentBlock; cb != nil && (cb == fn.Blocks[0] || cb == fn.Recover || cb.Preds != nil) {
@@ -2344,12 +2350,54 @@ func (b *builder) buildFunctionBody(fn *Function) {
 //
 // May add types that require runtime type information to builder.
 func (b *builder) buildCreated() {
+	if b.parallel && b.created.Len()-b.finished > 1 {
+		b.buildCreatedParallel()
+		return
+	}
 	for ; b.finished < b.created.Len(); b.finished++ {
 		fn := b.created.At(b.finished)
 		b.buildFunction(fn)
 	}
 }
 
+// buildCreatedParallel does the BUILD phase, as buildCreated does, but
+// it builds the functions in parallel, each in a goroutine that builds
+// the functions created by the functions it built, with a builder and
+// a creator of its own. The functions are added to b.created once all
+// are built.
+func (b *builder) buildCreatedParallel() {
+	pending := (*b.created)[b.finished:]
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+	created := make([]creator, workers)
+	var next int32 = -1 // index in pending of the last function taken
+	var wg sync.WaitGroup
+	for w := range created {
+		wg.Add(1)
+		go func(cr *creator) {
+			defer wg.Done()
+			wb := &builder{created: cr, arena: new(arena)}
+			for {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= len(pending) {
+					break
+				}
+				wb.buildFunction(pending[i])
+			}
+			wb.buildCreated()
+		}(&created[w])
+	}
+	wg.Wait()
+	for _, cr := range created {
+		for _, fn := range cr {
+			b.created.Add(fn)
+		}
+	}
+	b.finished = b.created.Len()
+}
+
 // Adds any needed runtime type information for the created functions.
 //
 // May add newly CREATEd functions that may need to be built or runtime type information.
@@ -2403,6 +2451,50 @@ func (prog *Program) Build() {
 	wg.Wait()
 }
 
+// BuildFunction builds the SSA code of the body of fn, if it was not
+// built already, and of the functions created while building it, such
+// as its function literals and wrappers, without building the rest of
+// its package. For a function literal, it builds the enclosing
+// declared function; for a package initializer, the whole package
+// (see Package.Build); and for an instantiation of a generic function,
+// it is BuildInstance.
+//
+// BuildFunction thus lets clients that need the bodies of only some
+// functions build them on demand. It must not be called while the
+// package of fn is being built, or while other goroutines use fn.
+//
+// Thread-safe.
+func (prog *Program) BuildFunction(fn *Function) {
+	for fn.parent != nil {
+		fn = fn.parent
+	}
+	if fn._Origin != nil {
+		prog.BuildInstance(fn)
+		return
+	}
+	p := fn.Pkg
+	if p == nil {
+		return // synthetic, and built on creation
+	}
+	if fn == p.init {
+		p.Build()
+		return
+	}
+
+	prog.buildMu.Lock()
+	defer prog.buildMu.Unlock()
+
+	if fn.built || p.info == nil {
+		return // built already, or with its package
+	}
+	b := builder{created: &creator{}}
+	b.created.Add(fn)
+	for !b.done() {
+		b.buildCreated()
+		b.needsRuntimeTypes()
+	}
+}
+
 // Build builds SSA code for all functions and vars in package p.
 //
 // Precondition: CreatePackage must have been called for all of p's
@@ -2431,9 +2523,14 @@ func (p *Package) build() {
 		defer logStack("build %s", p)()
 	}
 
-	b := builder{created: &p.created}
+	b := builder{
+		created:  &p.created,
+		parallel: p.Prog.mode&(ParallelFunctions|LogSource) == ParallelFunctions,
+		arena:    new(arena),
+	}
 	init := p.init
 	init.startBody()
+	init.arena = b.arena
 
 	var done *BasicBlock
 
@@ -2533,10 +2630,38 @@ func (p *Package) build() {
 		b.needsRuntimeTypes() // Add all of the runtime type information. May CREATE Functions.
 	}
 
+	created := p.created
 	p.info = nil    // We no longer need ASTs or go/types deductions.
 	p.created = nil // We no longer need created functions.
 
 	if p.Prog.mode&SanityCheckFunctions != 0 {
 		sanityCheckPackage(p)
 	}
+	if p.discardBodies {
+		for _, fn := range created {
+			if fn.Pkg == p {
+				discardBody(fn)
+			}
+		}
+	}
+}
+
+// discardBody discards the body of the function, and those of its
+// anonymous functions: their blocks, and the instructions that refer
+// to their parameters and free variables.
+func discardBody(fn *Function) {
+	for _, anon := range fn.AnonFuncs {
+		discardBody(anon)
+	}
+	fn.Blocks = nil
+	fn.Recover = nil
+	fn.Locals = nil
+	fn.AnonFuncs = nil
+	fn.referrers = nil
+	for _, p := range fn.Params {
+		p.referrers = nil
+	}
+	for _, fv := range fn.FreeVars {
+		fv.referrers = nil
+	}
 }
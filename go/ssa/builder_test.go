@@ -877,3 +877,126 @@ func TestGenericFunctionSelector(t *testing.T) {
 		}
 	}
 }
+
+// TestParallelFunctions checks that the functions built in parallel are
+// those built serially.
+func TestParallelFunctions(t *testing.T) {
+	const input = `package p
+
+type T struct{ x int }
+
+func (t T) Get() int   { return t.x }
+func (t *T) Set(x int) { t.x = x }
+
+type I interface{ Get() int }
+
+func Map[E, F any](s []E, f func(E) F) []F {
+	var r []F
+	for _, e := range s {
+		r = append(r, f(e))
+	}
+	return r
+}
+
+func f(s []int) []int {
+	k := 2
+	return Map(s, func(x int) int { return x * k })
+}
+
+func g(i I) func() int { return i.Get }
+
+func h(ts []T) (n int) {
+	for i := range ts {
+		defer func() { n += ts[i].Get() }()
+		if n > 10 {
+			panic("too many")
+		}
+	}
+	var set func(*T, int) = (*T).Set
+	set(&ts[0], n)
+	return
+}
+`
+	build := func(mode ssa.BuilderMode) string {
+		var conf loader.Config
+		f, err := conf.ParseFile("<input>", input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf.CreateFromFiles("p", f)
+		lprog, err := conf.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog := ssautil.CreateProgram(lprog, mode)
+		prog.Build()
+
+		var funcs []string
+		for fn := range ssautil.AllFunctions(prog) {
+			var buf bytes.Buffer
+			fn.WriteTo(&buf)
+			funcs = append(funcs, buf.String())
+		}
+		sort.Strings(funcs)
+		return strings.Join(funcs, "")
+	}
+	for _, mode := range []ssa.BuilderMode{0, ssa.InstantiateGenerics} {
+		want := build(mode)
+		for i := 0; i < 5; i++ {
+			if got := build(mode | ssa.ParallelFunctions | ssa.SanityCheckFunctions); got != want {
+				t.Fatalf("mode %s: functions built in parallel:\n%s\nwant:\n%s", mode, got, want)
+			}
+		}
+	}
+}
+
+// TestDiscardBodies checks that the bodies of the functions of a
+// package are discarded, but not its members.
+func TestDiscardBodies(t *testing.T) {
+	fset := token.NewFileSet()
+	prog := ssa.NewProgram(fset, ssa.SanityCheckFunctions)
+	pkgs := make(map[string]*types.Package)
+	create := func(path, src string) *ssa.Package {
+		f, err := parser.ParseFile(fset, path+".go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Scopes:     make(map[ast.Node]*types.Scope),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		typeparams.InitInstanceInfo(info)
+		conf := types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+			return pkgs[path], nil
+		})}
+		pkg, err := conf.Check(path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs[path] = pkg
+		return prog.CreatePackage(pkg, []*ast.File{f}, info, true)
+	}
+	dep := create("dep", `package dep; type T int; func (T) M() {}; func F() func() { return func() {} }`)
+	dep.SetDiscardBodies(true)
+	main := create("main", `package main; import "dep"; func main() { dep.F()(); var i interface{ M() } = dep.T(0); i.M() }`)
+	prog.Build()
+
+	if fn := dep.Func("F"); fn.Blocks != nil || fn.AnonFuncs != nil {
+		t.Errorf("the body of %s was not discarded", fn)
+	}
+	if dep.Type("T") == nil || prog.LookupMethod(dep.Type("T").Type(), dep.Pkg, "M") == nil {
+		t.Errorf("the members of dep are incomplete")
+	}
+	if fn := main.Func("main"); fn.Blocks == nil {
+		t.Errorf("the body of %s was discarded", fn)
+	}
+}
+
+// importerFunc implements types.Importer.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
@@ -26,7 +26,8 @@ func emitNew(f *Function, typ types.Type, pos token.Pos) *Alloc {
 // emitLoad emits to f an instruction to load the address addr into a
 // new temporary, and returns the value so defined.
 func emitLoad(f *Function, addr Value) *UnOp {
-	v := &UnOp{Op: token.MUL, X: addr}
+	v := f.arena.unOp()
+	v.Op, v.X = token.MUL, addr
 	v.setType(deref(addr.Type()))
 	f.emit(v)
 	return v
@@ -254,7 +255,8 @@ func emitConv(f *Function, val Value, typ types.Type) Value {
 // emitStore emits to f an instruction to store value val at location
 // addr, applying implicit conversions as required by assignability rules.
 func emitStore(f *Function, addr, val Value, pos token.Pos) *Store {
-	s := &Store{
+	s := f.arena.store()
+	*s = Store{
 		Addr: addr,
 		Val:  emitConv(f, val, deref(addr.Type())),
 		pos:  pos,
@@ -267,7 +269,7 @@ func emitStore(f *Function, addr, val Value, pos token.Pos) *Store {
 // Postcondition: f.currentBlock is nil.
 func emitJump(f *Function, target *BasicBlock) {
 	b := f.currentBlock
-	b.emit(new(Jump))
+	b.emit(f.arena.jump())
 	addEdge(b, target)
 	f.currentBlock = nil
 }
@@ -277,7 +279,9 @@ func emitJump(f *Function, target *BasicBlock) {
 // Postcondition: f.currentBlock is nil.
 func emitIf(f *Function, cond Value, tblock, fblock *BasicBlock) {
 	b := f.currentBlock
-	b.emit(&If{Cond: cond})
+	v := f.arena.ifInstr()
+	v.Cond = cond
+	b.emit(v)
 	addEdge(b, tblock)
 	addEdge(b, fblock)
 	f.currentBlock = nil
@@ -0,0 +1,511 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package escape classifies the allocations of functions in SSA form
+// by their lifetimes: those that do not outlive the call of their
+// function, those that may (and so escape to the heap), and those that
+// may be shared with other goroutines.
+//
+// Clients such as performance analyzers may use the results to find
+// allocations that need not be made on the heap, or that are made in
+// each iteration of a loop although they could be hoisted out, and
+// checkers may use them to find values that unintentionally outlive
+// their functions.
+//
+// # Analysis
+//
+// The analysis is flow-insensitive and unification-based, like
+// Steensgaard's points-to analysis: the values of each function that
+// may refer to the same memory, such as a pointer and the address of
+// one of its fields, are merged into one class, and the values that
+// may be stored in the memory of a class into the class of its
+// contents. A class escapes to the heap if a value of it is stored in a
+// global variable, returned, stored in memory that escapes, panicked
+// with, or passed to an unknown function; and it escapes to other
+// goroutines if a value of it is sent on a channel, or passed to a go
+// statement. The memory to which parameters refer belongs to the
+// callers, and what is stored in it escapes to the heap.
+//
+// Calls of the analyzed functions, and of the closures they create, use
+// summaries of the callees: the escape of each parameter and free
+// variable, and whether a parameter may be returned. The analysis is
+// sound only if the program uses no unsafe conversions, and can be
+// imprecise: the merging of classes means that an allocation escapes if
+// any allocation of its class does.
+package escape // import "golang.org/x/tools/go/ssa/escape"
+
+import (
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// A Kind describes the lifetime of an allocation.
+type Kind int
+
+const (
+	Local     Kind = iota // does not outlive the call of its function
+	Heap                  // may outlive the call of its function
+	Goroutine             // may be shared with other goroutines
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Local:
+		return "local"
+	case Heap:
+		return "heap"
+	case Goroutine:
+		return "goroutine"
+	}
+	return "unknown"
+}
+
+// IsAllocation reports whether v is an allocation: an Alloc,
+// MakeSlice, MakeMap, MakeChan, MakeClosure, or MakeInterface.
+// (A MakeInterface of a value that fits in an interface may not
+// allocate in practice.)
+func IsAllocation(v ssa.Value) bool {
+	switch v.(type) {
+	case *ssa.Alloc, *ssa.MakeSlice, *ssa.MakeMap, *ssa.MakeChan, *ssa.MakeClosure, *ssa.MakeInterface:
+		return true
+	}
+	return false
+}
+
+// A Result holds the results of the analysis.
+type Result struct {
+	kinds     map[ssa.Value]Kind
+	allocs    map[*ssa.Function][]ssa.Value
+	summaries map[*ssa.Function]*summary
+}
+
+// Kind returns the kind of the allocation v of an analyzed function,
+// or Heap if v is not such an allocation.
+func (r *Result) Kind(v ssa.Value) Kind {
+	if k, ok := r.kinds[v]; ok {
+		return k
+	}
+	return Heap
+}
+
+// Allocations returns the allocations of the analyzed function fn, in
+// order of position.
+func (r *Result) Allocations(fn *ssa.Function) []ssa.Value { return r.allocs[fn] }
+
+// Param returns the escape of parameter i of the analyzed function fn,
+// the receiver of a method being parameter 0, excluding its return,
+// and whether it may be returned.
+func (r *Result) Param(fn *ssa.Function, i int) (k Kind, returned bool) {
+	sum := r.summaries[fn]
+	if sum == nil || i >= len(sum.params) {
+		return Heap, true
+	}
+	return sum.params[i], sum.returned[i]
+}
+
+// A summary describes the escape of the parameters and free variables
+// of a function.
+type summary struct {
+	params   []Kind
+	returned []bool
+	freeVars []Kind
+}
+
+func (s *summary) equal(t *summary) bool {
+	if len(s.params) != len(t.params) || len(s.freeVars) != len(t.freeVars) {
+		return false
+	}
+	for i := range s.params {
+		if s.params[i] != t.params[i] || s.returned[i] != t.returned[i] {
+			return false
+		}
+	}
+	for i := range s.freeVars {
+		if s.freeVars[i] != t.freeVars[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Analyze analyzes the functions, which must have been built, and the
+// anonymous functions within them.
+func Analyze(funcs []*ssa.Function) *Result {
+	r := &Result{
+		kinds:     make(map[ssa.Value]Kind),
+		allocs:    make(map[*ssa.Function][]ssa.Value),
+		summaries: make(map[*ssa.Function]*summary),
+	}
+	var all []*ssa.Function
+	var add func(fn *ssa.Function)
+	add = func(fn *ssa.Function) {
+		if fn.Blocks == nil || r.summaries[fn] != nil {
+			return
+		}
+		r.summaries[fn] = &summary{
+			params:   make([]Kind, len(fn.Params)),
+			returned: make([]bool, len(fn.Params)),
+			freeVars: make([]Kind, len(fn.FreeVars)),
+		}
+		all = append(all, fn)
+		for _, anon := range fn.AnonFuncs {
+			add(anon)
+		}
+	}
+	for _, fn := range funcs {
+		add(fn)
+	}
+
+	// The summaries only grow, so they reach a fixed point.
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range all {
+			a := analyze(fn, r.summaries)
+			if sum := a.summary(); !sum.equal(r.summaries[fn]) {
+				r.summaries[fn] = sum
+				changed = true
+			}
+		}
+	}
+
+	for _, fn := range all {
+		a := analyze(fn, r.summaries)
+		a.returnsEscape()
+		var allocs []ssa.Value
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if v, ok := instr.(ssa.Value); ok && IsAllocation(v) {
+					allocs = append(allocs, v)
+					r.kinds[v] = a.class(v).find().kind
+				}
+			}
+		}
+		sort.SliceStable(allocs, func(i, j int) bool { return allocs[i].Pos() < allocs[j].Pos() })
+		r.allocs[fn] = allocs
+	}
+	return r
+}
+
+// A class is a set of values that may refer to the same memory.
+type class struct {
+	parent   *class // union-find parent, or nil
+	kind     Kind
+	contents *class // the class of the values stored in the memory, or nil
+}
+
+func (c *class) find() *class {
+	for c.parent != nil {
+		if c.parent.parent != nil {
+			c.parent = c.parent.parent
+		}
+		c = c.parent
+	}
+	return c
+}
+
+// pointee returns the class of the contents of the memory of c.
+func (c *class) pointee() *class {
+	c = c.find()
+	if c.contents == nil {
+		c.contents = new(class)
+	}
+	return c.contents.find()
+}
+
+// unify merges the classes of x and y, and of their contents.
+func unify(x, y *class) {
+	x, y = x.find(), y.find()
+	if x == y {
+		return
+	}
+	y.parent = x
+	if y.kind > x.kind {
+		x.kind = y.kind
+	}
+	switch {
+	case x.contents == nil:
+		x.contents = y.contents
+	case y.contents != nil:
+		unify(x.contents, y.contents)
+	}
+}
+
+// escape records that the memory of c has at least the lifetime k.
+func escape(c *class, k Kind) {
+	if c = c.find(); c.kind < k {
+		c.kind = k
+	}
+}
+
+// An analysis holds the state of the analysis of a function.
+type analysis struct {
+	fn        *ssa.Function
+	summaries map[*ssa.Function]*summary
+	classes   map[ssa.Value]*class
+	results   *class // the class of the results
+}
+
+// analyze analyzes fn given the summaries of the other functions.
+func analyze(fn *ssa.Function, summaries map[*ssa.Function]*summary) *analysis {
+	a := &analysis{
+		fn:        fn,
+		summaries: summaries,
+		classes:   make(map[ssa.Value]*class),
+		results:   new(class),
+	}
+	// The memory of the parameters and free variables belongs to
+	// the callers.
+	for _, p := range fn.Params {
+		escape(a.class(p).pointee(), Heap)
+	}
+	for _, fv := range fn.FreeVars {
+		escape(a.class(fv).pointee(), Heap)
+	}
+	var space [10]*ssa.Value
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			for _, op := range instr.Operands(space[:0]) {
+				if g, ok := (*op).(*ssa.Global); ok {
+					escape(a.class(g), Heap)
+				}
+			}
+			a.instr(instr)
+		}
+	}
+	a.propagate()
+	return a
+}
+
+// class returns the class of v.
+func (a *analysis) class(v ssa.Value) *class {
+	c := a.classes[v]
+	if c == nil {
+		c = new(class)
+		a.classes[v] = c
+	}
+	return c.find()
+}
+
+// unify merges the classes of x and y.
+func (a *analysis) unify(x, y ssa.Value) {
+	if x != nil && y != nil {
+		unify(a.class(x), a.class(y))
+	}
+}
+
+// store records that v may be stored in the memory of addr.
+func (a *analysis) store(addr, v ssa.Value) {
+	if v != nil {
+		unify(a.class(addr).pointee(), a.class(v))
+	}
+}
+
+// load records that v may be loaded from the memory of addr.
+func (a *analysis) load(v, addr ssa.Value) {
+	unify(a.class(v), a.class(addr).pointee())
+}
+
+func (a *analysis) escape(v ssa.Value, k Kind) {
+	if v != nil {
+		escape(a.class(v), k)
+	}
+}
+
+func (a *analysis) instr(instr ssa.Instruction) {
+	switch instr := instr.(type) {
+	case *ssa.Store:
+		a.store(instr.Addr, instr.Val)
+	case *ssa.MapUpdate:
+		a.store(instr.Map, instr.Key)
+		a.store(instr.Map, instr.Value)
+	case *ssa.Send:
+		a.store(instr.Chan, instr.X)
+		a.escape(instr.X, Goroutine)
+	case *ssa.Panic:
+		a.escape(instr.X, Heap)
+	case *ssa.Return:
+		for _, r := range instr.Results {
+			unify(a.results, a.class(r))
+		}
+
+	case *ssa.UnOp:
+		if instr.Op == token.MUL || instr.Op == token.ARROW {
+			a.load(instr, instr.X)
+		}
+	case *ssa.Lookup:
+		if _, ok := instr.X.Type().Underlying().(*types.Map); ok {
+			a.load(instr, instr.X)
+		}
+	case *ssa.Next:
+		a.load(instr, instr.Iter)
+	case *ssa.Select:
+		for _, st := range instr.States {
+			if st.Dir == types.SendOnly {
+				a.store(st.Chan, st.Send)
+				a.escape(st.Send, Goroutine)
+			} else {
+				a.load(instr, st.Chan)
+			}
+		}
+
+	case *ssa.FieldAddr:
+		a.unify(instr, instr.X)
+	case *ssa.IndexAddr:
+		a.unify(instr, instr.X)
+	case *ssa.Field:
+		a.unify(instr, instr.X)
+	case *ssa.Index:
+		a.unify(instr, instr.X)
+	case *ssa.Slice:
+		a.unify(instr, instr.X)
+	case *ssa.SliceToArrayPointer:
+		a.unify(instr, instr.X)
+	case *ssa.ChangeType:
+		a.unify(instr, instr.X)
+	case *ssa.Convert:
+		a.unify(instr, instr.X)
+	case *ssa.ChangeInterface:
+		a.unify(instr, instr.X)
+	case *ssa.MakeInterface:
+		a.unify(instr, instr.X)
+	case *ssa.TypeAssert:
+		a.unify(instr, instr.X)
+	case *ssa.Extract:
+		a.unify(instr, instr.Tuple)
+	case *ssa.Range:
+		a.unify(instr, instr.X)
+	case *ssa.Phi:
+		for _, e := range instr.Edges {
+			a.unify(instr, e)
+		}
+
+	case *ssa.MakeClosure:
+		sum := a.summaries[instr.Fn.(*ssa.Function)]
+		for i, b := range instr.Bindings {
+			a.store(instr, b)
+			if sum != nil && i < len(sum.freeVars) {
+				a.escape(b, sum.freeVars[i])
+			} else {
+				a.escape(b, Heap)
+			}
+		}
+
+	case *ssa.Go:
+		a.call(instr.Common(), nil)
+		a.escape(instr.Call.Value, Goroutine)
+		for _, arg := range instr.Call.Args {
+			a.escape(arg, Goroutine)
+		}
+	case *ssa.Defer:
+		a.call(instr.Common(), nil)
+	case *ssa.Call:
+		a.call(instr.Common(), instr)
+	}
+}
+
+// call records the effects of a call, whose result, if any, is
+// result.
+func (a *analysis) call(common *ssa.CallCommon, result ssa.Value) {
+	if b, ok := common.Value.(*ssa.Builtin); ok {
+		switch b.Name() {
+		case "append":
+			if len(common.Args) == 2 {
+				unify(a.class(common.Args[0]).pointee(), a.class(common.Args[1]).pointee())
+			}
+			if result != nil {
+				a.unify(result, common.Args[0])
+			}
+		case "copy":
+			unify(a.class(common.Args[0]).pointee(), a.class(common.Args[1]).pointee())
+		case "ssa:wrapnilchk":
+			if result != nil {
+				a.unify(result, common.Args[0])
+			}
+		}
+		return
+	}
+
+	var sum *summary
+	if callee := common.StaticCallee(); callee != nil {
+		sum = a.summaries[callee]
+	}
+	if sum == nil {
+		// An unknown function.
+		if common.IsInvoke() {
+			a.escape(common.Value, Heap)
+		}
+		for _, arg := range common.Args {
+			a.escape(arg, Heap)
+		}
+		return
+	}
+	for i, arg := range common.Args {
+		if i < len(sum.params) {
+			a.escape(arg, sum.params[i])
+			if sum.returned[i] && result != nil {
+				a.unify(result, arg)
+			}
+		}
+	}
+}
+
+// propagate makes the contents of the memory of each class escape at
+// least as the class does.
+func (a *analysis) propagate() {
+	var classes []*class
+	seen := make(map[*class]bool)
+	addClass := func(c *class) {
+		for c != nil && !seen[c.find()] {
+			c = c.find()
+			seen[c] = true
+			classes = append(classes, c)
+			c = c.contents
+		}
+	}
+	addClass(a.results)
+	for _, c := range a.classes {
+		addClass(c)
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, c := range classes {
+			c = c.find()
+			if c.contents != nil {
+				if p := c.contents.find(); p.kind < c.kind {
+					p.kind = c.kind
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// summary returns the summary of the function.
+func (a *analysis) summary() *summary {
+	fn := a.fn
+	sum := &summary{
+		params:   make([]Kind, len(fn.Params)),
+		returned: make([]bool, len(fn.Params)),
+		freeVars: make([]Kind, len(fn.FreeVars)),
+	}
+	results := a.results.find()
+	for i, p := range fn.Params {
+		c := a.class(p)
+		sum.params[i] = c.kind
+		sum.returned[i] = c == results
+	}
+	for i, fv := range fn.FreeVars {
+		sum.freeVars[i] = a.class(fv).kind
+	}
+	return sum
+}
+
+// returnsEscape makes the results of the function escape to the heap.
+func (a *analysis) returnsEscape() {
+	escape(a.results, Heap)
+	a.propagate()
+}
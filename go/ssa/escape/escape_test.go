@@ -0,0 +1,150 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package escape_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/escape"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+const src = `package p
+
+type T struct{ p *int }
+
+var global *int
+
+func local() int { x := new(int); *x = 1; return *x }
+
+func returned() *int { return new(int) }
+
+func stored() { global = new(int) }
+
+func sent(c chan *int) { c <- new(int) }
+
+func spawned() {
+	x := new(int)
+	go func() { *x = 1 }()
+}
+
+func use(p *int) int { return *p }
+
+func passed() { x := new(int); use(x) }
+
+func leak(p *int) { global = p }
+
+func leaked() { x := new(int); leak(x) }
+
+func id(p *int) *int { return p }
+
+func identity() { x := new(int); id(x) }
+
+func identityReturned() *int { x := new(int); return id(x) }
+
+func nested() {
+	var t T
+	t.p = new(int)
+	global = t.p
+}
+
+func closure() int {
+	x := 0
+	f := func() { x++ }
+	f()
+	return x
+}
+
+func unknown(f func(*int)) { f(new(int)) }
+
+func slice() int { s := make([]int, 10); return len(s) }
+
+func loop(n int) {
+	for i := 0; i < n; i++ {
+		use(new(int))
+	}
+}
+
+func boxed(x int) { _ = interface{}(x) }
+
+func panicked(x int) { panic(x) }
+
+func recursive(p *int, n int) *int {
+	if n == 0 {
+		return p
+	}
+	return recursive(p, n-1)
+}
+
+func viaRecursive() { recursive(new(int), 3) }
+`
+
+func TestAnalyze(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, _, err := ssautil.BuildPackage(&types.Config{}, fset, types.NewPackage("p", ""), []*ast.File{f}, ssa.SanityCheckFunctions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var funcs []*ssa.Function
+	for _, mem := range pkg.Members {
+		if fn, ok := mem.(*ssa.Function); ok {
+			funcs = append(funcs, fn)
+		}
+	}
+	r := escape.Analyze(funcs)
+
+	for name, want := range map[string]string{
+		"local":            "local",
+		"returned":         "heap",
+		"stored":           "heap",
+		"sent":             "goroutine",
+		"spawned":          "goroutine goroutine goroutine",
+		"passed":           "local",
+		"leaked":           "heap",
+		"identity":         "local",
+		"identityReturned": "heap",
+		"nested":           "local heap",
+		"closure":          "local local",
+		"unknown":          "heap",
+		"slice":            "local",
+		"loop":             "local",
+		"boxed":            "local",
+		"panicked":         "heap",
+		"viaRecursive":     "local",
+	} {
+		var kinds []string
+		for _, v := range r.Allocations(pkg.Func(name)) {
+			kinds = append(kinds, r.Kind(v).String())
+		}
+		if got := strings.Join(kinds, " "); got != want {
+			t.Errorf("%s: got %s, want %s", name, got, want)
+		}
+	}
+
+	for _, test := range []struct {
+		fn       string
+		kind     escape.Kind
+		returned bool
+	}{
+		{"use", escape.Local, false},
+		{"leak", escape.Heap, false},
+		{"id", escape.Local, true},
+		{"recursive", escape.Local, true},
+	} {
+		if k, returned := r.Param(pkg.Func(test.fn), 0); k != test.kind || returned != test.returned {
+			t.Errorf("%s: Param(0) = %s, %t, want %s, %t", test.fn, k, returned, test.kind, test.returned)
+		}
+	}
+}
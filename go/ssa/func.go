@@ -267,6 +267,7 @@ func mayNeedRuntimeTypes(fn *Function) []types.Type {
 //
 // The function is not done being built until done() is called.
 func (f *Function) finishBody() {
+	f.arena = nil
 	f.objects = nil
 	f.currentBlock = nil
 	f.lblocks = nil
@@ -363,6 +364,17 @@ func (pkg *Package) SetDebugMode(debug bool) {
 	pkg.debug = debug
 }
 
+// SetDiscardBodies sets whether the bodies of the functions of package
+// pkg, their blocks and instructions, are discarded once it is built,
+// so as to save memory for the packages, such as the dependencies of
+// those of interest, that a whole-program analysis needs to build but
+// does not otherwise examine. Their functions then have no Blocks, as
+// external functions do, but the members, method sets and runtime
+// types of the package are complete. It must be called before Build.
+func (pkg *Package) SetDiscardBodies(discard bool) {
+	pkg.discardBodies = discard
+}
+
 // debugInfo reports whether debug info is wanted for this function.
 func (f *Function) debugInfo() bool {
 	return f.Pkg != nil && f.Pkg.debug
@@ -642,7 +654,8 @@ func WriteFunction(buf *bytes.Buffer, f *Function) {
 // not automatically become the current block for subsequent calls to emit.
 // comment is an optional string for more readable debugging output.
 func (f *Function) newBasicBlock(comment string) *BasicBlock {
-	b := &BasicBlock{
+	b := f.arena.block()
+	*b = BasicBlock{
 		Index:   len(f.Blocks),
 		Comment: comment,
 		parent:  f,
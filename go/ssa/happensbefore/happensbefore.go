@@ -0,0 +1,714 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package happensbefore builds a static model of the synchronization
+// of the goroutines of a program in SSA form: a graph of its
+// synchronization events, whose paths describe the orders in which the
+// events may happen, for analyzers of data races and deadlocks.
+//
+// # Goroutines
+//
+// The model has a static goroutine for the roots of the program, such
+// as its init and main functions, and one for each go statement
+// reachable from them, whose functions are those reachable from the
+// callees of the statement in the call graph, without going through
+// other go statements. Several instances of a static goroutine may run
+// at once, as a go statement within a loop creates; the Multiple field
+// of a Goroutine reports whether they may.
+//
+// # Events
+//
+// The events of a goroutine are its go statements; its operations on
+// channels (sends, receives, including those of select statements, and
+// closes); its calls of the Lock and Unlock methods of sync.Mutex and
+// sync.RWMutex (and of sync.Locker), and of the Add, Done and Wait
+// methods of sync.WaitGroup; its calls of its other functions; and any
+// other instructions that the Config names, such as the memory
+// accesses of interest to a race detector. The events of a function
+// called by several goroutines are distinct for each goroutine.
+//
+// # Edges
+//
+// An edge from event a to event b means that a may happen before b:
+// either b follows a in a function of their goroutine (which includes
+// the events of a function called between the call and the events that
+// follow it), or b is an event of a goroutine that a starts, or a
+// synchronizes with b: a send or close on a channel with a receive of
+// it, a receive from an unbuffered channel with a send on it, an Unlock
+// of a mutex with a Lock of it, or a WaitGroup's Done with its Wait.
+// The objects of two operations are matched if their values may alias,
+// as decided by a simple analysis of the origins of the values (see
+// Graph.MayAlias).
+//
+// The model is conservative in the sense of reporting few unordered
+// events: the edges of a path may not belong to a single execution,
+// so HappensBefore may report events that are ordered in some
+// executions but not others as ordered. Two events of different
+// goroutines that Concurrent reports are, however, never ordered by
+// the synchronization that the model describes.
+package happensbefore // import "golang.org/x/tools/go/ssa/happensbefore"
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sync"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A Kind is the kind of an event.
+type Kind int
+
+const (
+	Spawn   Kind = iota // a go statement
+	Send                // a send on a channel
+	Recv                // a receive from a channel
+	Close               // a close of a channel
+	Lock                // a call of Lock of a sync.Mutex, sync.RWMutex or sync.Locker
+	Unlock              // a call of Unlock of a sync.Mutex, sync.RWMutex or sync.Locker
+	RLock               // a call of RLock of a sync.RWMutex
+	RUnlock             // a call of RUnlock of a sync.RWMutex
+	Add                 // a call of Add of a sync.WaitGroup
+	Done                // a call of Done of a sync.WaitGroup
+	Wait                // a call of Wait of a sync.WaitGroup
+	Call                // a call of another function of the goroutine
+	Access              // another instruction that the Config names
+)
+
+var kindNames = [...]string{
+	Spawn:   "spawn",
+	Send:    "send",
+	Recv:    "recv",
+	Close:   "close",
+	Lock:    "lock",
+	Unlock:  "unlock",
+	RLock:   "rlock",
+	RUnlock: "runlock",
+	Add:     "add",
+	Done:    "done",
+	Wait:    "wait",
+	Call:    "call",
+	Access:  "access",
+}
+
+func (k Kind) String() string {
+	if 0 <= k && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "unknown"
+}
+
+// A Config describes the program to model.
+type Config struct {
+	// CallGraph is the call graph of the program.
+	CallGraph *callgraph.Graph
+
+	// Roots are the functions of the first goroutine, such as the
+	// init and main functions of the main package.
+	Roots []*ssa.Function
+
+	// Instrs, if not nil, reports whether an instruction that is
+	// not otherwise an event is an Access event.
+	Instrs func(ssa.Instruction) bool
+}
+
+// A Goroutine is a static goroutine of the program.
+type Goroutine struct {
+	ID       int
+	Go       *ssa.Go         // the go statement that starts it, or nil for the first goroutine
+	Parent   *Goroutine      // the goroutine of the go statement, or nil
+	Funcs    []*ssa.Function // the functions that it executes, its entry points first
+	Multiple bool            // several instances of the goroutine may run at once
+}
+
+// An Event is a synchronization event, or another event of interest,
+// of a goroutine.
+type Event struct {
+	ID        int // index in Graph.Events
+	Kind      Kind
+	Goroutine *Goroutine
+	Instr     ssa.Instruction
+	Index     int       // the index of the state of a select statement, or -1
+	Object    ssa.Value // the channel, mutex or wait group, if any
+
+	succs, preds []*Event
+}
+
+// Succs returns the events that e may immediately happen before.
+func (e *Event) Succs() []*Event { return e.succs }
+
+// Preds returns the events that may immediately happen before e.
+func (e *Event) Preds() []*Event { return e.preds }
+
+// A Graph is a happens-before graph of the events of a program.
+// Its methods are safe for concurrent use.
+type Graph struct {
+	Goroutines []*Goroutine
+	Events     []*Event
+
+	byInstr map[ssa.Instruction][]*Event
+	origins *origins
+
+	mu    sync.Mutex
+	reach map[*Event][]uint64 // bit sets of the events reachable from an event, by ID
+}
+
+// Build builds the happens-before graph of the program that the
+// configuration describes.
+func Build(config *Config) *Graph {
+	b := &builder{
+		config:     config,
+		g:          &Graph{byInstr: make(map[ssa.Instruction][]*Event)},
+		goroutines: make(map[*ssa.Go]*Goroutine),
+		events:     make(map[funcKey][]*Event),
+	}
+	b.g.origins = newOrigins(config.CallGraph)
+	b.build()
+	return b.g
+}
+
+// EventsOf returns the events of the instruction, one for each
+// goroutine (and select state) of which it is an event.
+func (g *Graph) EventsOf(instr ssa.Instruction) []*Event { return g.byInstr[instr] }
+
+// HappensBefore reports whether event a may happen before event b:
+// whether there is a path of one or more edges from a to b.
+func (g *Graph) HappensBefore(a, b *Event) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	set := g.reach[a]
+	if set == nil {
+		set = make([]uint64, (len(g.Events)+63)/64)
+		stack := append([]*Event(nil), a.succs...)
+		for len(stack) > 0 {
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if set[e.ID/64]&(1<<(e.ID%64)) != 0 {
+				continue
+			}
+			set[e.ID/64] |= 1 << (e.ID % 64)
+			stack = append(stack, e.succs...)
+		}
+		if g.reach == nil {
+			g.reach = make(map[*Event][]uint64)
+		}
+		g.reach[a] = set
+	}
+	return set[b.ID/64]&(1<<(b.ID%64)) != 0
+}
+
+// Concurrent reports whether events a and b, of different goroutines,
+// are unordered: whether neither may happen before the other. Events of
+// the same goroutine are not concurrent, even if several instances of
+// the goroutine may run.
+func (g *Graph) Concurrent(a, b *Event) bool {
+	return a.Goroutine != b.Goroutine && !g.HappensBefore(a, b) && !g.HappensBefore(b, a)
+}
+
+// MayAlias reports whether the values x and y may refer to the same
+// variable, channel or other object, as far as a simple analysis of
+// their origins can tell: the origins of a value are the allocations,
+// global variables and unknown values (such as the results of calls)
+// from which it is derived, through the fields and elements of
+// variables, loads from variables, conversions, phis, free variables
+// and, in the call graph, parameters.
+func (g *Graph) MayAlias(x, y ssa.Value) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.origins.mayAlias(x, y)
+}
+
+// A funcKey identifies the events of a function in a goroutine.
+type funcKey struct {
+	g  *Goroutine
+	fn *ssa.Function
+}
+
+// A builder holds the state of the construction of a graph.
+type builder struct {
+	config     *Config
+	g          *Graph
+	goroutines map[*ssa.Go]*Goroutine
+	events     map[funcKey][]*Event // in instruction order
+}
+
+func (b *builder) build() {
+	// Create the goroutines and their events.
+	root := &Goroutine{}
+	b.addGoroutine(root, b.config.Roots)
+	for i := 0; i < len(b.g.Goroutines); i++ {
+		g := b.g.Goroutines[i]
+		for _, fn := range g.Funcs {
+			b.addEvents(g, fn)
+		}
+	}
+
+	// Add the edges of program order, and of go statements.
+	for _, g := range b.g.Goroutines {
+		for _, fn := range g.Funcs {
+			events := b.events[funcKey{g, fn}]
+			for _, e := range events {
+				for _, f := range events {
+					if follows(e, f) {
+						edge(e, f)
+					}
+				}
+				switch e.Kind {
+				case Call:
+					for _, callee := range b.callees(g, e.Instr.(ssa.CallInstruction)) {
+						for _, c := range b.events[funcKey{g, callee}] {
+							edge(e, c)
+							for _, f := range events {
+								if follows(e, f) {
+									edge(c, f)
+								}
+							}
+						}
+					}
+				case Spawn:
+					child := b.goroutines[e.Instr.(*ssa.Go)]
+					for _, fn := range child.Funcs {
+						for _, c := range b.events[funcKey{child, fn}] {
+							edge(e, c)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Add the edges of synchronization.
+	for _, e := range b.g.Events {
+		for _, f := range b.g.Events {
+			if e.Goroutine != f.Goroutine && synchronizes(e.Kind, f.Kind) && b.g.origins.mayAlias(e.Object, f.Object) {
+				if e.Kind == Recv && f.Kind == Send && !b.g.origins.unbuffered(e.Object) {
+					continue
+				}
+				edge(e, f)
+			}
+		}
+	}
+}
+
+// synchronizes reports whether an event of kind x happens before the
+// matching events of kind y.
+func synchronizes(x, y Kind) bool {
+	switch x {
+	case Send, Close:
+		return y == Recv
+	case Recv:
+		return y == Send // if unbuffered
+	case Unlock:
+		return y == Lock || y == RLock
+	case RUnlock:
+		return y == Lock
+	case Done:
+		return y == Wait
+	}
+	return false
+}
+
+func edge(e, f *Event) {
+	for _, s := range e.succs {
+		if s == f {
+			return
+		}
+	}
+	e.succs = append(e.succs, f)
+	f.preds = append(f.preds, e)
+}
+
+// follows reports whether event f may follow event e in their
+// function.
+func follows(e, f *Event) bool {
+	be, bf := e.Instr.Block(), f.Instr.Block()
+	if be == bf && instrIndex(e.Instr) < instrIndex(f.Instr) {
+		return true
+	}
+	// Is bf reachable from be by one or more edges?
+	seen := make(map[*ssa.BasicBlock]bool)
+	stack := append([]*ssa.BasicBlock(nil), be.Succs...)
+	for len(stack) > 0 {
+		b := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if b == bf {
+			return true
+		}
+		if !seen[b] {
+			seen[b] = true
+			stack = append(stack, b.Succs...)
+		}
+	}
+	return false
+}
+
+func instrIndex(instr ssa.Instruction) int {
+	for i, x := range instr.Block().Instrs {
+		if x == instr {
+			return i
+		}
+	}
+	return -1
+}
+
+// addGoroutine adds the goroutine whose entry points are entries, and
+// the goroutines of the go statements of its functions.
+func (b *builder) addGoroutine(g *Goroutine, entries []*ssa.Function) {
+	g.ID = len(b.g.Goroutines)
+	b.g.Goroutines = append(b.g.Goroutines, g)
+
+	seen := make(map[*ssa.Function]bool)
+	var visit func(fn *ssa.Function)
+	visit = func(fn *ssa.Function) {
+		if seen[fn] || fn.Blocks == nil {
+			return
+		}
+		seen[fn] = true
+		g.Funcs = append(g.Funcs, fn)
+		if n := b.config.CallGraph.Nodes[fn]; n != nil {
+			for _, e := range n.Out {
+				if _, ok := e.Site.(*ssa.Go); !ok && e.Callee.Func != nil {
+					visit(e.Callee.Func)
+				}
+			}
+		}
+	}
+	for _, fn := range entries {
+		visit(fn)
+	}
+
+	for _, fn := range g.Funcs {
+		for _, blk := range fn.Blocks {
+			for _, instr := range blk.Instrs {
+				site, ok := instr.(*ssa.Go)
+				if !ok || b.goroutines[site] != nil {
+					continue
+				}
+				child := &Goroutine{
+					Go:       site,
+					Parent:   g,
+					Multiple: g.Multiple || inLoop(blk),
+				}
+				b.goroutines[site] = child
+				var callees []*ssa.Function
+				if n := b.config.CallGraph.Nodes[fn]; n != nil {
+					for _, e := range n.Out {
+						if e.Site == site && e.Callee.Func != nil {
+							callees = append(callees, e.Callee.Func)
+						}
+					}
+				}
+				b.addGoroutine(child, callees)
+			}
+		}
+	}
+}
+
+// inLoop reports whether the block is in a cycle of the CFG.
+func inLoop(b *ssa.BasicBlock) bool {
+	seen := make(map[*ssa.BasicBlock]bool)
+	stack := append([]*ssa.BasicBlock(nil), b.Succs...)
+	for len(stack) > 0 {
+		x := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if x == b {
+			return true
+		}
+		if !seen[x] {
+			seen[x] = true
+			stack = append(stack, x.Succs...)
+		}
+	}
+	return false
+}
+
+// callees returns the functions of goroutine g that the call may call.
+func (b *builder) callees(g *Goroutine, site ssa.CallInstruction) []*ssa.Function {
+	var callees []*ssa.Function
+	if n := b.config.CallGraph.Nodes[site.Parent()]; n != nil {
+		for _, e := range n.Out {
+			if e.Site == site && e.Callee.Func != nil && e.Callee.Func.Blocks != nil {
+				callees = append(callees, e.Callee.Func)
+			}
+		}
+	}
+	return callees
+}
+
+// addEvents adds the events of function fn of goroutine g.
+func (b *builder) addEvents(g *Goroutine, fn *ssa.Function) {
+	key := funcKey{g, fn}
+	add := func(kind Kind, instr ssa.Instruction, index int, obj ssa.Value) {
+		e := &Event{
+			ID:        len(b.g.Events),
+			Kind:      kind,
+			Goroutine: g,
+			Instr:     instr,
+			Index:     index,
+			Object:    obj,
+		}
+		b.g.Events = append(b.g.Events, e)
+		b.g.byInstr[instr] = append(b.g.byInstr[instr], e)
+		b.events[key] = append(b.events[key], e)
+	}
+	for _, blk := range fn.Blocks {
+		for _, instr := range blk.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Go:
+				add(Spawn, instr, -1, nil)
+				continue
+			case *ssa.Send:
+				add(Send, instr, -1, instr.Chan)
+				continue
+			case *ssa.UnOp:
+				if instr.Op == token.ARROW {
+					add(Recv, instr, -1, instr.X)
+					continue
+				}
+			case *ssa.Select:
+				for i, st := range instr.States {
+					kind := Recv
+					if st.Dir == types.SendOnly {
+						kind = Send
+					}
+					add(kind, instr, i, st.Chan)
+				}
+				continue
+			case ssa.CallInstruction:
+				if kind, obj, ok := syncCall(instr.Common()); ok {
+					add(kind, instr, -1, obj)
+					continue
+				}
+				if len(b.callees(g, instr)) > 0 {
+					add(Call, instr, -1, nil)
+					continue
+				}
+			}
+			if b.config.Instrs != nil && b.config.Instrs(instr) {
+				add(Access, instr, -1, nil)
+			}
+		}
+	}
+}
+
+// syncCall returns the kind and object of the event of a call of
+// close or of a method of the sync package, if it is one.
+func syncCall(call *ssa.CallCommon) (Kind, ssa.Value, bool) {
+	if b, ok := call.Value.(*ssa.Builtin); ok {
+		if b.Name() == "close" {
+			return Close, call.Args[0], true
+		}
+		return 0, nil, false
+	}
+
+	var obj *types.Func
+	var recv ssa.Value
+	if call.IsInvoke() {
+		obj, recv = call.Method, call.Value
+	} else if callee := call.StaticCallee(); callee != nil && len(call.Args) > 0 {
+		obj, _ = callee.Object().(*types.Func)
+		recv = call.Args[0]
+	}
+	if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != "sync" {
+		return 0, nil, false
+	}
+	sig := obj.Type().(*types.Signature)
+	if sig.Recv() == nil {
+		return 0, nil, false
+	}
+	T := sig.Recv().Type()
+	if ptr, ok := T.(*types.Pointer); ok {
+		T = ptr.Elem()
+	}
+	named, ok := T.(*types.Named)
+	if !ok {
+		return 0, nil, false
+	}
+	switch named.Obj().Name() + "." + obj.Name() {
+	case "Mutex.Lock", "RWMutex.Lock", "Locker.Lock":
+		return Lock, recv, true
+	case "Mutex.Unlock", "RWMutex.Unlock", "Locker.Unlock":
+		return Unlock, recv, true
+	case "RWMutex.RLock":
+		return RLock, recv, true
+	case "RWMutex.RUnlock":
+		return RUnlock, recv, true
+	case "WaitGroup.Add":
+		return Add, recv, true
+	case "WaitGroup.Done":
+		return Done, recv, true
+	case "WaitGroup.Wait":
+		return Wait, recv, true
+	}
+	return 0, nil, false
+}
+
+// An origin is an object from which a value may be derived: an
+// allocation, a global variable, or an unknown value, and the path of
+// fields, elements and loads from it to the value.
+type origin struct {
+	v    ssa.Value
+	path string
+}
+
+// origins computes and caches the origins of values.
+type origins struct {
+	cg    *callgraph.Graph
+	cache map[ssa.Value][]origin
+}
+
+func newOrigins(cg *callgraph.Graph) *origins {
+	return &origins{cg: cg, cache: make(map[ssa.Value][]origin)}
+}
+
+func (o *origins) mayAlias(x, y ssa.Value) bool {
+	if x == nil || y == nil {
+		return false
+	}
+	xs, ys := o.of(x), o.of(y)
+	for _, a := range xs {
+		for _, b := range ys {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unbuffered reports whether the channel ch is made without a buffer.
+func (o *origins) unbuffered(ch ssa.Value) bool {
+	for _, org := range o.of(ch) {
+		mc, ok := org.v.(*ssa.MakeChan)
+		if !ok || org.path != "" {
+			return false
+		}
+		c, ok := mc.Size.(*ssa.Const)
+		if !ok || c.Value == nil || constant.Sign(c.Value) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// of returns the origins of v.
+func (o *origins) of(v ssa.Value) []origin {
+	if orgs, ok := o.cache[v]; ok {
+		return orgs
+	}
+	o.cache[v] = nil // break cycles
+	set := make(map[origin]bool)
+	var orgs []origin
+	add := func(org origin) {
+		if !set[org] {
+			set[org] = true
+			orgs = append(orgs, org)
+		}
+	}
+	extend := func(x ssa.Value, suffix string) {
+		for _, org := range o.of(x) {
+			add(origin{org.v, org.path + suffix})
+		}
+	}
+
+	switch v := v.(type) {
+	case *ssa.FieldAddr:
+		extend(v.X, "."+fieldName(v.X, v.Field))
+	case *ssa.Field:
+		extend(v.X, "."+fieldName(v.X, v.Field))
+	case *ssa.IndexAddr:
+		extend(v.X, "[]")
+	case *ssa.Index:
+		extend(v.X, "[]")
+	case *ssa.UnOp:
+		if v.Op == token.MUL {
+			extend(v.X, "*")
+		} else {
+			add(origin{v, ""})
+		}
+	case *ssa.ChangeType:
+		extend(v.X, "")
+	case *ssa.Convert:
+		extend(v.X, "")
+	case *ssa.ChangeInterface:
+		extend(v.X, "")
+	case *ssa.MakeInterface:
+		extend(v.X, "")
+	case *ssa.TypeAssert:
+		if v.CommaOk {
+			add(origin{v, ""})
+		} else {
+			extend(v.X, "")
+		}
+	case *ssa.Slice:
+		extend(v.X, "")
+	case *ssa.Phi:
+		for _, e := range v.Edges {
+			extend(e, "")
+		}
+	case *ssa.FreeVar:
+		fn := v.Parent()
+		i := -1
+		for j, fv := range fn.FreeVars {
+			if fv == v {
+				i = j
+			}
+		}
+		if refs := fn.Referrers(); refs != nil {
+			for _, instr := range *refs {
+				if mc, ok := instr.(*ssa.MakeClosure); ok && mc.Fn == fn && 0 <= i && i < len(mc.Bindings) {
+					extend(mc.Bindings[i], "")
+				}
+			}
+		}
+	case *ssa.Parameter:
+		fn := v.Parent()
+		i := -1
+		for j, p := range fn.Params {
+			if p == v {
+				i = j
+			}
+		}
+		var sites []ssa.CallInstruction
+		if n := o.cg.Nodes[fn]; n != nil {
+			for _, e := range n.In {
+				if e.Site != nil {
+					sites = append(sites, e.Site)
+				}
+			}
+		}
+		if len(sites) == 0 {
+			add(origin{v, ""})
+		}
+		for _, site := range sites {
+			common := site.Common()
+			args := common.Args
+			if common.IsInvoke() {
+				args = append([]ssa.Value{common.Value}, args...)
+			}
+			if 0 <= i && i < len(args) {
+				extend(args[i], "")
+			}
+		}
+	default:
+		add(origin{v, ""})
+	}
+	o.cache[v] = orgs
+	return orgs
+}
+
+// fieldName returns the name of field i of the struct (or pointer to
+// struct) x.
+func fieldName(x ssa.Value, i int) string {
+	T := x.Type().Underlying()
+	if ptr, ok := T.(*types.Pointer); ok {
+		T = ptr.Elem().Underlying()
+	}
+	if s, ok := T.(*types.Struct); ok && i < s.NumFields() {
+		return s.Field(i).Name()
+	}
+	return "?"
+}
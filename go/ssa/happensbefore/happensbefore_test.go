@@ -0,0 +1,271 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package happensbefore_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/callgraph/static"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/happensbefore"
+)
+
+// syncSrc is a stand-in for the sync package, whose methods are
+// recognized by their names.
+const syncSrc = `package sync
+
+type Mutex struct{ state int32 }
+
+func (m *Mutex) Lock()   { m.state = 1 }
+func (m *Mutex) Unlock() { m.state = 0 }
+
+type WaitGroup struct{ n int }
+
+func (wg *WaitGroup) Add(n int) { wg.n += n }
+func (wg *WaitGroup) Done()     { wg.n-- }
+func (wg *WaitGroup) Wait()     {}
+`
+
+const mainSrc = `package main
+
+import "sync"
+
+var x, y, z, w, v int
+
+func main() {
+	unbuffered()
+	buffered()
+	locked()
+	waited()
+	racy()
+	closed()
+}
+
+func unbuffered() {
+	c := make(chan int)
+	go func() {
+		x = 1 // x1
+		c <- 0
+		x = 2 // x2
+	}()
+	<-c
+	x = 3 // x3
+}
+
+func buffered() {
+	c := make(chan int, 1)
+	go func() {
+		c <- 0
+		y = 1 // y1
+	}()
+	<-c
+	y = 2 // y2
+}
+
+func locked() {
+	var mu sync.Mutex
+	go func() {
+		mu.Lock()
+		set()
+		mu.Unlock()
+	}()
+	mu.Lock()
+	z = 2 // z2
+	mu.Unlock()
+}
+
+func set() { z = 1 } // z1 in a function called by the goroutine
+
+func waited() {
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			w = 1 // w1
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	w = 2 // w2
+}
+
+func racy() {
+	c, d := make(chan int), make(chan int)
+	go func() {
+		v = 1 // v1
+		c <- 0
+	}()
+	<-d
+	v = 2 // v2
+}
+
+func closed() {
+	done := make(chan struct{})
+	go func() {
+		x = 4 // x4
+		close(done)
+	}()
+	select {
+	case <-done:
+	}
+	x = 5 // x5
+}
+`
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// build returns the SSA form of the main and sync packages.
+func build(t *testing.T) (*ssa.Program, *ssa.Package) {
+	fset := token.NewFileSet()
+	prog := ssa.NewProgram(fset, ssa.SanityCheckFunctions)
+	pkgs := make(map[string]*types.Package)
+	var mainPkg *ssa.Package
+	for _, src := range []string{syncSrc, mainSrc} {
+		f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf := types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+			if p := pkgs[path]; p != nil {
+				return p, nil
+			}
+			return nil, fmt.Errorf("no package %q", path)
+		})}
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Scopes:     make(map[ast.Node]*types.Scope),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs[f.Name.Name] = pkg
+		mainPkg = prog.CreatePackage(pkg, []*ast.File{f}, info, true)
+	}
+	prog.Build()
+	return prog, mainPkg
+}
+
+func TestHappensBefore(t *testing.T) {
+	prog, mainPkg := build(t)
+
+	// Model the stores to the global variables, named by comments.
+	fset := prog.Fset
+	file := fset.File(mainPkg.Func("main").Pos())
+	lines := make(map[int]string)
+	for i, line := range strings.Split(mainSrc, "\n") {
+		if j := strings.Index(line, "// "); j >= 0 {
+			lines[i+1] = strings.Fields(line[j+len("// "):])[0]
+		}
+	}
+	g := happensbefore.Build(&happensbefore.Config{
+		CallGraph: static.CallGraph(prog),
+		Roots:     []*ssa.Function{mainPkg.Func("init"), mainPkg.Func("main")},
+		Instrs: func(instr ssa.Instruction) bool {
+			store, ok := instr.(*ssa.Store)
+			if !ok {
+				return false
+			}
+			_, ok = store.Addr.(*ssa.Global)
+			return ok
+		},
+	})
+	events := make(map[string]*happensbefore.Event)
+	for _, e := range g.Events {
+		if e.Kind == happensbefore.Access {
+			name := lines[file.Line(e.Instr.Pos())]
+			events[name] = e
+		}
+	}
+	for _, name := range []string{"x1", "x2", "x3", "x4", "x5", "y1", "y2", "z1", "z2", "w1", "w2", "v1", "v2"} {
+		if events[name] == nil {
+			t.Fatalf("no event for %s", name)
+		}
+	}
+
+	for _, test := range []struct {
+		a, b       string
+		before     bool // a happens before b
+		concurrent bool
+	}{
+		{"x1", "x3", true, false},
+		{"x2", "x3", false, true}, // unbuffered: the send happens before the receive completes, not after x2
+		{"x1", "x2", true, false},
+		{"y1", "y2", false, true}, // buffered: the receive does not wait for y1
+		{"z1", "z2", true, false}, // ordered one way or the other by the mutex
+		{"z2", "z1", true, false},
+		{"w1", "w2", true, false},
+		{"v1", "v2", false, true}, // different channels
+		{"x4", "x5", true, false},
+		{"x3", "x4", true, false}, // spawned after
+	} {
+		a, b := events[test.a], events[test.b]
+		if got := g.HappensBefore(a, b); got != test.before {
+			t.Errorf("HappensBefore(%s, %s) = %t, want %t", test.a, test.b, got, test.before)
+		}
+		if got := g.Concurrent(a, b); got != test.concurrent {
+			t.Errorf("Concurrent(%s, %s) = %t, want %t", test.a, test.b, got, test.concurrent)
+		}
+	}
+
+	// The goroutine of waited is started in a loop.
+	w1 := events["w1"]
+	if !w1.Goroutine.Multiple || w1.Goroutine.Parent != g.Goroutines[0] {
+		t.Errorf("goroutine of w1: Multiple = %t, Parent = %v", w1.Goroutine.Multiple, w1.Goroutine.Parent)
+	}
+	if events["x1"].Goroutine.Multiple {
+		t.Errorf("goroutine of x1 is Multiple")
+	}
+
+	// Check the kinds of the synchronization events, and that each
+	// event is found by its instruction.
+	count := make(map[happensbefore.Kind]int)
+	for _, e := range g.Events {
+		count[e.Kind]++
+		found := false
+		for _, f := range g.EventsOf(e.Instr) {
+			found = found || f == e
+		}
+		if !found {
+			t.Errorf("EventsOf(%v) lacks %v event", e.Instr, e.Kind)
+		}
+		for _, s := range e.Succs() {
+			found := false
+			for _, p := range s.Preds() {
+				found = found || p == e
+			}
+			if !found {
+				t.Errorf("Preds of %v event lack %v event", s.Kind, e.Kind)
+			}
+		}
+	}
+	for kind, want := range map[happensbefore.Kind]int{
+		happensbefore.Spawn:  6,
+		happensbefore.Send:   3,
+		happensbefore.Recv:   4,
+		happensbefore.Close:  1,
+		happensbefore.Lock:   2,
+		happensbefore.Unlock: 2,
+		happensbefore.Add:    1,
+		happensbefore.Done:   1,
+		happensbefore.Wait:   1,
+	} {
+		if got := count[kind]; got != want {
+			t.Errorf("%d %v events, want %d", got, kind, want)
+		}
+	}
+}
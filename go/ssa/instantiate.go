@@ -159,3 +159,49 @@ func createInstance(fn *Function, targs []types.Type, info *types.Info, syntax a
 	cr.Add(instance)
 	return instance
 }
+
+// BuildInstance builds the body of the instantiation fn of a generic
+// function, which the program created without one because it does not
+// instantiate generics (see InstantiateGenerics), and reports whether
+// it did: it does not if fn is not such an instantiation, or if the
+// syntax of the generic function is unavailable, as it is for packages
+// created from export data. The instantiations that the body calls are
+// created without bodies, as fn was.
+//
+// BuildInstance thus lets clients that need the bodies of only some
+// instantiations, such as call graph builders, build them on demand.
+// It must not be called while other goroutines use fn.
+//
+// Thread-safe.
+func (prog *Program) BuildInstance(fn *Function) bool {
+	if prog.mode&InstantiateGenerics != 0 || fn._Origin == nil || fn.parent != nil {
+		return false
+	}
+
+	prog.buildMu.Lock()
+	defer prog.buildMu.Unlock()
+
+	if fn.Blocks != nil {
+		return false // already built
+	}
+	prog.methodsMu.Lock()
+	insts := prog.instances[fn._Origin]
+	prog.methodsMu.Unlock()
+	if insts == nil || insts.syntax == nil {
+		return false
+	}
+
+	// fn was built as an external function, with the Params of its
+	// signature; build it anew from the syntax of its origin.
+	fn.syntax = insts.syntax
+	fn.Params = nil
+	fn.built = false
+
+	b := builder{created: &creator{}}
+	b.created.Add(fn)
+	for !b.done() {
+		b.buildCreated()
+		b.needsRuntimeTypes()
+	}
+	return true
+}
@@ -126,3 +126,64 @@ func LoadPointer(addr *unsafe.Pointer) (val unsafe.Pointer)
 		}
 	}
 }
+
+// TestBuildInstance ensures that BuildInstance builds the bodies of the
+// instantiations of a program that does not instantiate generics.
+func TestBuildInstance(t *testing.T) {
+	if !typeparams.Enabled {
+		return
+	}
+	const input = `
+package p
+
+func id[T any](x T) T { return g(x) }
+
+func g[T any](x T) T { return x }
+
+func f() int { return id(1) }
+`
+	var conf loader.Config
+	f, err := conf.ParseFile("<input>", input)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	conf.CreateFromFiles("p", f)
+	lprog, err := conf.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	prog := NewProgram(lprog.Fset, SanityCheckFunctions)
+	p := prog.CreatePackage(lprog.Package("p").Pkg, lprog.Package("p").Files, &lprog.Package("p").Info, true)
+	p.Build()
+
+	// f calls id[int], which has no body.
+	call := p.Func("f").Blocks[0].Instrs[0].(*Call)
+	inst := call.Call.StaticCallee()
+	if inst.Origin() != p.Func("id") || len(inst.TypeArgs()) != 1 || inst.Blocks != nil {
+		t.Fatalf("got callee %s, with origin %s and %d blocks; want bodiless id[int]", inst, inst.Origin(), len(inst.Blocks))
+	}
+	if !prog.BuildInstance(inst) {
+		t.Fatalf("BuildInstance(%s) = false", inst)
+	}
+	if prog.BuildInstance(inst) {
+		t.Errorf("BuildInstance(%s) built it twice", inst)
+	}
+	if len(inst.Params) != 1 || inst.Blocks == nil {
+		t.Errorf("got %d params and %d blocks for %s", len(inst.Params), len(inst.Blocks), inst)
+	}
+
+	// The instantiation of g that id[int] calls has no body yet.
+	var callee *Function
+	for _, instr := range inst.Blocks[0].Instrs {
+		if call, ok := instr.(*Call); ok {
+			callee = call.Call.StaticCallee()
+		}
+	}
+	if callee == nil || callee.Origin() != p.Func("g") || callee.Blocks != nil {
+		t.Errorf("got callee %v of %s; want bodiless g[int]", callee, inst)
+	}
+	if prog.BuildInstance(p.Func("f")) || prog.BuildInstance(p.Func("id")) {
+		t.Errorf("BuildInstance built a function that is not an instantiation")
+	}
+}
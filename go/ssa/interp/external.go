@@ -79,6 +79,7 @@ func init() {
 		"math.IsNaN":                      ext۰math۰IsNaN,
 		"math.Ldexp":                      ext۰math۰Ldexp,
 		"math.Log":                        ext۰math۰Log,
+		"math.Max":                        ext۰math۰Max,
 		"math.Min":                        ext۰math۰Min,
 		"math.NaN":                        ext۰math۰NaN,
 		"math.Sqrt":                       ext۰math۰Sqrt,
@@ -95,7 +96,9 @@ func init() {
 		"runtime.GOROOT":                  ext۰runtime۰GOROOT,
 		"runtime.Goexit":                  ext۰runtime۰Goexit,
 		"runtime.Gosched":                 ext۰runtime۰Gosched,
+		"runtime.KeepAlive":               ext۰runtime۰KeepAlive,
 		"runtime.NumCPU":                  ext۰runtime۰NumCPU,
+		"runtime.SetFinalizer":            ext۰runtime۰SetFinalizer,
 		"sort.Float64s":                   ext۰sort۰Float64s,
 		"sort.Ints":                       ext۰sort۰Ints,
 		"sort.Strings":                    ext۰sort۰Strings,
@@ -170,6 +173,10 @@ func ext۰math۰Min(fr *frame, args []value) value {
 	return math.Min(args[0].(float64), args[1].(float64))
 }
 
+func ext۰math۰Max(fr *frame, args []value) value {
+	return math.Max(args[0].(float64), args[1].(float64))
+}
+
 func ext۰math۰NaN(fr *frame, args []value) value {
 	return math.NaN()
 }
@@ -216,7 +223,9 @@ func ext۰sort۰Strings(fr *frame, args []value) value {
 func ext۰sort۰Float64s(fr *frame, args []value) value {
 	x := args[0].([]value)
 	sort.Slice(x, func(i, j int) bool {
-		return x[i].(float64) < x[j].(float64)
+		// As sort.Float64s does, order NaNs before other values.
+		a, b := x[i].(float64), x[j].(float64)
+		return a < b || (math.IsNaN(a) && !math.IsNaN(b))
 	})
 	return nil
 }
@@ -293,6 +302,16 @@ func ext۰runtime۰NumCPU(fr *frame, args []value) value {
 	return runtime.NumCPU()
 }
 
+func ext۰runtime۰KeepAlive(fr *frame, args []value) value {
+	return nil
+}
+
+func ext۰runtime۰SetFinalizer(fr *frame, args []value) value {
+	// The interpreter's values are not collected individually,
+	// so finalizers never run.
+	return nil
+}
+
 func ext۰time۰Sleep(fr *frame, args []value) value {
 	time.Sleep(time.Duration(args[0].(int64)))
 	return nil
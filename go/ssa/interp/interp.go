@@ -505,10 +505,15 @@ func callSSA(i *interpreter, caller *frame, callpos token.Pos, fn *ssa.Function,
 			}
 			return ext(fr, args)
 		}
+		if fn.Origin() != nil {
+			// An instantiation of a program that does not
+			// instantiate generics is built when first called.
+			i.prog.BuildInstance(fn)
+		}
 		if fn.Blocks == nil {
 			var reason string // empty by default
 			if strings.HasPrefix(fn.Synthetic, "instantiation") {
-				reason = " (interp requires ssa.BuilderMode to include InstantiateGenerics on generics)"
+				reason = " (the syntax of the generic function is unavailable)"
 			}
 			panic("no code for function: " + name + reason)
 		}
@@ -643,8 +648,10 @@ func setGlobal(i *interpreter, pkg *ssa.Package, name string, v value) {
 //
 // The SSA program must include the "runtime" package.
 //
-// Type parameterized functions must have been built with
-// InstantiateGenerics in the ssa.BuilderMode to be interpreted.
+// The instantiations of type parameterized functions are built when
+// first called, unless the program was built with InstantiateGenerics
+// in its ssa.BuilderMode; either way, the syntax of the generic
+// functions must be available.
 func Interpret(mainpkg *ssa.Package, mode Mode, sizes types.Sizes, filename string, args []string) (exitCode int) {
 	i := &interpreter{
 		prog:       mainpkg.Prog,
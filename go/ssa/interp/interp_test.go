@@ -131,7 +131,7 @@ var testdataTests = []string{
 
 func init() {
 	if typeparams.Enabled {
-		testdataTests = append(testdataTests, "fixedbugs/issue52835.go")
+		testdataTests = append(testdataTests, "fixedbugs/issue52835.go", "typeparams.go")
 	}
 }
 
@@ -142,6 +142,11 @@ var testdataArchs = map[string]string{
 }
 
 func run(t *testing.T, input string) bool {
+	return runMode(t, input, ssa.InstantiateGenerics|ssa.SanityCheckFunctions)
+}
+
+// runMode is like run, but builds the program in the given mode.
+func runMode(t *testing.T, input string, bmode ssa.BuilderMode) bool {
 	// The recover2 test case is broken on Go 1.14+. See golang/go#34089.
 	// TODO(matloob): Fix this.
 	if filepath.Base(input) == "recover2.go" {
@@ -189,7 +194,6 @@ func run(t *testing.T, input string) bool {
 		return false
 	}
 
-	bmode := ssa.InstantiateGenerics | ssa.SanityCheckFunctions
 	// bmode |= ssa.PrintFunctions // enable for debugging
 	prog := ssautil.CreateProgram(iprog, bmode)
 	prog.Build()
@@ -248,6 +252,26 @@ func TestTestdataFiles(t *testing.T) {
 	printFailures(failures)
 }
 
+// TestInstancesOnDemand runs the interpreter on generic code in a
+// program that does not instantiate generics, whose instantiations it
+// builds when they are first called.
+func TestInstancesOnDemand(t *testing.T) {
+	if !typeparams.Enabled {
+		t.Skip("type parameters are not enabled")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var failures []string
+	for _, input := range []string{"typeparams.go", "fixedbugs/issue52835.go"} {
+		if !runMode(t, filepath.Join(cwd, "testdata", input), ssa.SanityCheckFunctions) {
+			failures = append(failures, input)
+		}
+	}
+	printFailures(failures)
+}
+
 // TestGorootTest runs the interpreter on $GOROOT/test/*.go.
 func TestGorootTest(t *testing.T) {
 	var failures []string
@@ -271,15 +295,14 @@ func TestTypeparamTest(t *testing.T) {
 	// Skip known failures for the given reason.
 	// TODO(taking): Address these.
 	skip := map[string]string{
-		"chans.go":       "interp tests do not support runtime.SetFinalizer",
+		"chans.go":       "interp tests do not support context or time.Minute",
 		"issue23536.go":  "unknown reason",
 		"issue376214.go": "unknown issue with variadic cast on bytes",
 		"issue48042.go":  "interp tests do not handle reflect.Value.SetInt",
 		"issue47716.go":  "interp tests do not handle unsafe.Sizeof",
 		"issue50419.go":  "interp tests do not handle dispatch to String() correctly",
 		"issue51733.go":  "interp does not handle unsafe casts",
-		"ordered.go":     "math.NaN() comparisons not being handled correctly",
-		"orderedmap.go":  "interp tests do not support runtime.SetFinalizer",
+		"orderedmap.go":  "interp tests do not support bytes or context",
 		"stringer.go":    "unknown reason",
 		"issue48317.go":  "interp tests do not support encoding/json",
 		"issue48318.go":  "interp tests do not support encoding/json",
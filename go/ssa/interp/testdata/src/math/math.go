@@ -13,3 +13,5 @@ func Signbit(x float64) bool {
 }
 
 func Sqrt(x float64) float64
+
+func Max(x, y float64) float64
@@ -20,3 +20,7 @@ const GOOS = "linux"
 const GOARCH = "amd64"
 
 func GC()
+
+func KeepAlive(x interface{})
+
+func SetFinalizer(obj interface{}, finalizer interface{})
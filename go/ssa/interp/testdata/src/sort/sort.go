@@ -3,3 +3,18 @@ package sort
 func Strings(x []string)
 func Ints(x []int)
 func Float64s(x []float64)
+
+type Interface interface {
+	Len() int
+	Less(i, j int) bool
+	Swap(i, j int)
+}
+
+// Sort sorts data in ascending order, by insertion.
+func Sort(data Interface) {
+	for i := 1; i < data.Len(); i++ {
+		for j := i; j > 0 && data.Less(j, j-1); j-- {
+			data.Swap(j, j-1)
+		}
+	}
+}
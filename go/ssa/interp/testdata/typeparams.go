@@ -0,0 +1,129 @@
+package main
+
+// Tests of generic functions and types, and of the externals that
+// generic code commonly uses.
+
+import (
+	"math"
+	"runtime"
+	"sort"
+)
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+func Sum[T Number](xs ...T) T {
+	var s T
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
+
+func Map[T, U interface{}](xs []T, f func(T) U) []U {
+	var us []U
+	for _, x := range xs {
+		us = append(us, f(x))
+	}
+	return us
+}
+
+type List[T interface{}] struct {
+	head *node[T]
+	len  int
+}
+
+type node[T interface{}] struct {
+	val  T
+	next *node[T]
+}
+
+func (l *List[T]) Push(v T) {
+	l.head = &node[T]{v, l.head}
+	l.len++
+}
+
+func (l *List[T]) Each(f func(T)) {
+	for n := l.head; n != nil; n = n.next {
+		f(n.val)
+	}
+}
+
+type Stringer interface {
+	String() string
+}
+
+type name string
+
+func (n name) String() string { return string(n) }
+
+func Join[T Stringer](xs []T) string {
+	var s string
+	for i, x := range xs {
+		if i > 0 {
+			s += ","
+		}
+		s += x.String()
+	}
+	return s
+}
+
+type byLen []string
+
+func (s byLen) Len() int           { return len(s) }
+func (s byLen) Less(i, j int) bool { return len(s[i]) < len(s[j]) }
+func (s byLen) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type myInt int
+
+func main() {
+	if got := Sum(1, 2, 3); got != 6 {
+		panic(got)
+	}
+	if got := Sum[myInt](4, 5); got != 9 {
+		panic(got)
+	}
+	if got := Sum(0.5, 0.25); got != 0.75 {
+		panic(got)
+	}
+
+	lens := Map([]string{"a", "bb", "ccc"}, func(s string) int { return len(s) })
+	if len(lens) != 3 || lens[0] != 1 || lens[2] != 3 {
+		panic(lens)
+	}
+
+	var l List[string]
+	l.Push("x")
+	l.Push("y")
+	var s string
+	l.Each(func(v string) { s += v })
+	if s != "yx" || l.len != 2 {
+		panic(s)
+	}
+
+	if got := Join([]name{"a", "b"}); got != "a,b" {
+		panic(got)
+	}
+
+	// Finalizers never run, but may be set.
+	runtime.SetFinalizer(&l, func(*List[string]) {})
+	runtime.KeepAlive(&l)
+
+	// NaNs sort first, as in sort.Float64s.
+	fs := []float64{2, math.NaN(), 1}
+	sort.Float64s(fs)
+	if !math.IsNaN(fs[0]) || fs[1] != 1 || fs[2] != 2 {
+		panic(fs)
+	}
+
+	words := byLen{"ccc", "a", "bb"}
+	sort.Sort(words)
+	if words[0] != "a" || words[1] != "bb" || words[2] != "ccc" {
+		panic(words)
+	}
+
+	if got := math.Max(1, 2); got != 2 {
+		panic(got)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssa
+
+// This file defines dominator and post-dominator trees, dominance
+// frontiers, and control dependence, for clients.
+
+// A DomTree is the dominator tree or the post-dominator tree of the
+// blocks of a function.
+//
+// Block b dominates block c if every path from the entry of the
+// function to c goes through b; b post-dominates c if every path from
+// c to an exit of the function goes through b. The exits are the
+// blocks without successors, which return or panic. The roots of a
+// dominator tree are the entry block and the Recover block, if any;
+// those of a post-dominator tree are the exits, and the blocks from
+// which paths lead to different exits, as if the exits were followed
+// by a single virtual one. Blocks from which no exit is reachable,
+// such as those of a loop without exits, are not in the
+// post-dominator tree.
+//
+// A DomTree does not change if the function does.
+type DomTree struct {
+	post     bool
+	roots    []*BasicBlock
+	idom     []*BasicBlock   // indexed by Block.Index
+	children [][]*BasicBlock // indexed by Block.Index
+	pre, end []int32         // preorder number, and that beyond the descendants; -1 if not in the tree
+	order    []*BasicBlock   // the blocks of the tree in preorder
+	frontier [][]*BasicBlock // indexed by Block.Index
+}
+
+// DomTree returns the dominator tree of the blocks of f, which agrees
+// with BasicBlock.Idom and Dominates.
+func (f *Function) DomTree() *DomTree {
+	t := newDomTree(f, false)
+	for _, b := range f.Blocks {
+		t.idom[b.Index] = b.Idom()
+	}
+	roots := []*BasicBlock{f.Blocks[0]}
+	if f.Recover != nil {
+		roots = append(roots, f.Recover)
+	}
+	t.finish(f, roots)
+	return t
+}
+
+// PostDomTree returns the post-dominator tree of the blocks of f.
+func (f *Function) PostDomTree() *DomTree {
+	t := newDomTree(f, true)
+	n := len(f.Blocks)
+
+	// Compute the immediate post-dominators by the iterative
+	// algorithm of Cooper, Harvey and Kennedy, A Simple, Fast
+	// Dominance Algorithm, over the reverse CFG, whose root is a
+	// virtual exit node, numbered n, whose successors are the exits.
+	postorder := make([]int, 0, n+1) // block indices
+	num := make([]int, n+1)          // postorder numbers, by index; -1 if unvisited
+	for i := range num {
+		num[i] = -1
+	}
+	var visit func(b *BasicBlock)
+	visit = func(b *BasicBlock) {
+		num[b.Index] = 0 // visiting
+		for _, p := range b.Preds {
+			if num[p.Index] < 0 {
+				visit(p)
+			}
+		}
+		num[b.Index] = len(postorder)
+		postorder = append(postorder, b.Index)
+	}
+	for _, b := range f.Blocks {
+		if len(b.Succs) == 0 {
+			if num[b.Index] < 0 {
+				visit(b)
+			}
+		}
+	}
+	num[n] = len(postorder)
+	postorder = append(postorder, n)
+
+	idom := make([]int, n+1)
+	for i := range idom {
+		idom[i] = -1
+	}
+	idom[n] = n
+	intersect := func(x, y int) int {
+		for x != y {
+			for num[x] < num[y] {
+				x = idom[x]
+			}
+			for num[y] < num[x] {
+				y = idom[y]
+			}
+		}
+		return x
+	}
+	for changed := true; changed; {
+		changed = false
+		// In reverse postorder, omitting the virtual exit.
+		for i := len(postorder) - 2; i >= 0; i-- {
+			b := f.Blocks[postorder[i]]
+			d := -1
+			if len(b.Succs) == 0 {
+				d = n
+			}
+			for _, s := range b.Succs {
+				if idom[s.Index] < 0 {
+					continue // not yet processed
+				}
+				if d < 0 {
+					d = s.Index
+				} else {
+					d = intersect(d, s.Index)
+				}
+			}
+			if d != idom[b.Index] {
+				idom[b.Index] = d
+				changed = true
+			}
+		}
+	}
+
+	var roots []*BasicBlock
+	for _, b := range f.Blocks {
+		if d := idom[b.Index]; d == n {
+			roots = append(roots, b)
+		} else if d >= 0 {
+			t.idom[b.Index] = f.Blocks[d]
+		}
+	}
+	t.finish(f, roots)
+	return t
+}
+
+func newDomTree(f *Function, post bool) *DomTree {
+	n := len(f.Blocks)
+	return &DomTree{
+		post:     post,
+		idom:     make([]*BasicBlock, n),
+		children: make([][]*BasicBlock, n),
+		pre:      make([]int32, n),
+		end:      make([]int32, n),
+		frontier: make([][]*BasicBlock, n),
+	}
+}
+
+// finish computes the children, numbering and dominance frontiers of
+// the tree of the given roots, given the immediate dominators.
+func (t *DomTree) finish(f *Function, roots []*BasicBlock) {
+	t.roots = roots
+	for _, b := range f.Blocks {
+		if d := t.idom[b.Index]; d != nil {
+			t.children[d.Index] = append(t.children[d.Index], b)
+		}
+		t.pre[b.Index] = -1
+		t.end[b.Index] = -1
+	}
+	var number func(b *BasicBlock)
+	number = func(b *BasicBlock) {
+		t.pre[b.Index] = int32(len(t.order))
+		t.order = append(t.order, b)
+		for _, c := range t.children[b.Index] {
+			number(c)
+		}
+		t.end[b.Index] = int32(len(t.order))
+	}
+	for _, root := range roots {
+		number(root)
+	}
+
+	// Compute the dominance frontiers by the algorithm of Cooper,
+	// Harvey and Kennedy: each block b with several predecessors is
+	// in the frontier of the blocks that dominate a predecessor of
+	// b, up to the immediate dominator of b.
+	for _, b := range f.Blocks {
+		preds := t.preds(b)
+		if len(preds) < 2 {
+			continue
+		}
+		for _, p := range preds {
+			for r := p; r != nil && r != t.idom[b.Index] && t.pre[r.Index] >= 0; r = t.idom[r.Index] {
+				if !containsBlock(t.frontier[r.Index], b) {
+					t.frontier[r.Index] = append(t.frontier[r.Index], b)
+				}
+			}
+		}
+	}
+}
+
+// preds returns the predecessors of b in the graph of the tree: the
+// CFG, or for a post-dominator tree, the reverse CFG.
+func (t *DomTree) preds(b *BasicBlock) []*BasicBlock {
+	if t.post {
+		return b.Succs
+	}
+	return b.Preds
+}
+
+func containsBlock(blocks []*BasicBlock, b *BasicBlock) bool {
+	for _, x := range blocks {
+		if x == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Post reports whether t is a post-dominator tree.
+func (t *DomTree) Post() bool { return t.post }
+
+// Roots returns the roots of the tree.
+func (t *DomTree) Roots() []*BasicBlock { return t.roots }
+
+// Idom returns the immediate (post-)dominator of b: its parent in the
+// tree, or nil if b is a root or is not in the tree.
+func (t *DomTree) Idom(b *BasicBlock) *BasicBlock { return t.idom[b.Index] }
+
+// Children returns the blocks that b immediately (post-)dominates: its
+// children in the tree.
+func (t *DomTree) Children(b *BasicBlock) []*BasicBlock { return t.children[b.Index] }
+
+// Contains reports whether b is in the tree.
+func (t *DomTree) Contains(b *BasicBlock) bool { return t.pre[b.Index] >= 0 }
+
+// Dominates reports whether b (post-)dominates c. Every block of the
+// tree (post-)dominates itself.
+func (t *DomTree) Dominates(b, c *BasicBlock) bool {
+	p := t.pre[c.Index]
+	return t.pre[b.Index] >= 0 && t.pre[b.Index] <= p && p < t.end[b.Index]
+}
+
+// Frontier returns the (post-)dominance frontier of b: the blocks
+// that b does not strictly (post-)dominate, but of which it
+// (post-)dominates a predecessor (for a post-dominator tree, a
+// successor).
+func (t *DomTree) Frontier(b *BasicBlock) []*BasicBlock { return t.frontier[b.Index] }
+
+// Preorder returns an iterator over the blocks of the tree in
+// preorder, the descendants of a block following it:
+//
+//	t.Preorder()(func(b *ssa.BasicBlock) bool {
+//		...
+//		return true // to continue
+//	})
+func (t *DomTree) Preorder() func(yield func(*BasicBlock) bool) {
+	return func(yield func(*BasicBlock) bool) {
+		for _, b := range t.order {
+			if !yield(b) {
+				return
+			}
+		}
+	}
+}
+
+// Dominators returns an iterator over the blocks that (post-)dominate
+// b, from b itself to the root of its tree. It visits nothing if b is
+// not in the tree.
+func (t *DomTree) Dominators(b *BasicBlock) func(yield func(*BasicBlock) bool) {
+	return func(yield func(*BasicBlock) bool) {
+		if !t.Contains(b) {
+			return
+		}
+		for ; b != nil; b = t.idom[b.Index] {
+			if !yield(b) {
+				return
+			}
+		}
+	}
+}
+
+// ControlDeps is the control dependence graph of the blocks of a
+// function.
+//
+// Block b is control dependent on block c if c has a successor from
+// which every path to an exit goes through b, and another from which
+// some path does not: that is, if the branch at the end of c decides
+// whether b executes. A block on which no block depends, such as the
+// entry, executes whenever the function does, if it returns. Control
+// dependence is post-dominance frontier.
+type ControlDeps struct {
+	deps, dependents [][]*BasicBlock // indexed by Block.Index
+}
+
+// ControlDeps returns the control dependence graph of the blocks of f.
+func (f *Function) ControlDeps() *ControlDeps {
+	pdt := f.PostDomTree()
+	cd := &ControlDeps{
+		deps:       make([][]*BasicBlock, len(f.Blocks)),
+		dependents: make([][]*BasicBlock, len(f.Blocks)),
+	}
+	for _, b := range f.Blocks {
+		for _, c := range pdt.Frontier(b) {
+			cd.deps[b.Index] = append(cd.deps[b.Index], c)
+			cd.dependents[c.Index] = append(cd.dependents[c.Index], b)
+		}
+	}
+	return cd
+}
+
+// Deps returns the blocks on which b is control dependent, whose
+// branches decide whether b executes.
+func (cd *ControlDeps) Deps(b *BasicBlock) []*BasicBlock { return cd.deps[b.Index] }
+
+// Dependents returns the blocks that are control dependent on b.
+func (cd *ControlDeps) Dependents(b *BasicBlock) []*BasicBlock { return cd.dependents[b.Index] }
+
+// Transitive returns an iterator over the blocks on which b is
+// transitively control dependent, nearest first, each once. A block
+// in a loop may depend on itself.
+func (cd *ControlDeps) Transitive(b *BasicBlock) func(yield func(*BasicBlock) bool) {
+	return func(yield func(*BasicBlock) bool) {
+		seen := make(map[*BasicBlock]bool)
+		queue := append([]*BasicBlock(nil), cd.deps[b.Index]...)
+		for len(queue) > 0 {
+			c := queue[0]
+			queue = queue[1:]
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			if !yield(c) {
+				return
+			}
+			queue = append(queue, cd.deps[c.Index]...)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssa_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+const postdomSrc = `package p
+
+func branches(x int) int {
+	if x > 0 {
+		x++
+	} else if x < -10 {
+		return 0
+	}
+	for x < 10 {
+		if x == 5 {
+			break
+		}
+		x *= 2
+	}
+	switch x {
+	case 1:
+		panic(x)
+	case 2, 3:
+		x--
+	}
+	return x
+}
+
+func forever(x int) {
+	if x > 0 {
+		return
+	}
+	for {
+		x++
+	}
+}
+
+func recovers(f func()) (err error) {
+	defer func() {
+		if recover() != nil {
+			err = nil
+		}
+	}()
+	if f != nil {
+		f()
+	}
+	return nil
+}
+`
+
+// TestDomTrees compares the dominator and post-dominator trees and the
+// control dependence graph of some functions with those computed
+// naively from their definitions.
+func TestDomTrees(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", postdomSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, _, err := ssautil.BuildPackage(&types.Config{}, fset, types.NewPackage("p", ""), []*ast.File{f}, ssa.SanityCheckFunctions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"branches", "forever", "recovers"} {
+		fn := pkg.Func(name)
+		dt, pdt, cd := fn.DomTree(), fn.PostDomTree(), fn.ControlDeps()
+		if dt.Post() || !pdt.Post() {
+			t.Errorf("%s: wrong kinds of trees", name)
+		}
+		pdom := naivePostDom(fn)
+		for _, b := range fn.Blocks {
+			for _, c := range fn.Blocks {
+				if got, want := dt.Dominates(b, c), b.Dominates(c); got != want {
+					t.Errorf("%s: DomTree.Dominates(%d, %d) = %t, want %t", name, b.Index, c.Index, got, want)
+				}
+				if got, want := pdt.Dominates(b, c), pdom[c.Index][b.Index]; got != want {
+					t.Errorf("%s: PostDomTree.Dominates(%d, %d) = %t, want %t", name, b.Index, c.Index, got, want)
+				}
+			}
+
+			// b is control dependent on c if it post-dominates
+			// a successor of c, but does not strictly
+			// post-dominate c.
+			var want []int
+			for _, c := range fn.Blocks {
+				strict := pdom[c.Index][b.Index] && b != c
+				for _, s := range c.Succs {
+					if pdom[s.Index][b.Index] && !strict {
+						want = append(want, c.Index)
+						break
+					}
+				}
+			}
+			if got := blockIndices(cd.Deps(b)); !sameInts(got, want) {
+				t.Errorf("%s: Deps(%d) = %v, want %v", name, b.Index, got, want)
+			}
+			transitive := make(map[*ssa.BasicBlock]bool)
+			cd.Transitive(b)(func(c *ssa.BasicBlock) bool {
+				if transitive[c] {
+					t.Errorf("%s: Transitive(%d) visits %d twice", name, b.Index, c.Index)
+				}
+				transitive[c] = true
+				return true
+			})
+			for _, c := range cd.Deps(b) {
+				if !containsBlock(cd.Dependents(c), b) {
+					t.Errorf("%s: Dependents(%d) lacks %d", name, c.Index, b.Index)
+				}
+				if !transitive[c] {
+					t.Errorf("%s: Transitive(%d) lacks %d", name, b.Index, c.Index)
+				}
+			}
+
+			// The dominators of a block are its ancestors.
+			var ancestors []int
+			pdt.Dominators(b)(func(c *ssa.BasicBlock) bool {
+				ancestors = append(ancestors, c.Index)
+				return true
+			})
+			var want2 []int
+			for _, c := range fn.Blocks {
+				if pdom[b.Index][c.Index] {
+					want2 = append(want2, c.Index)
+				}
+			}
+			if !sameInts(ancestors, want2) {
+				t.Errorf("%s: post-dominators of %d = %v, want %v", name, b.Index, ancestors, want2)
+			}
+		}
+
+		// Preorder visits each block of the tree once, after its
+		// immediate dominator.
+		seen := make(map[*ssa.BasicBlock]bool)
+		pdt.Preorder()(func(b *ssa.BasicBlock) bool {
+			if seen[b] {
+				t.Errorf("%s: Preorder visits %d twice", name, b.Index)
+			}
+			if d := pdt.Idom(b); d != nil && !seen[d] {
+				t.Errorf("%s: Preorder visits %d before its parent %d", name, b.Index, d.Index)
+			}
+			seen[b] = true
+			return true
+		})
+		for _, b := range fn.Blocks {
+			if seen[b] != pdt.Contains(b) {
+				t.Errorf("%s: Preorder visits %d: %t, but Contains: %t", name, b.Index, seen[b], pdt.Contains(b))
+			}
+		}
+	}
+
+	// The blocks of the infinite loop are not in the post-dominator tree.
+	fn := pkg.Func("forever")
+	var loops int
+	for _, b := range fn.Blocks {
+		if !fn.PostDomTree().Contains(b) {
+			loops++
+		}
+	}
+	if loops == 0 {
+		t.Errorf("forever: all blocks are in the post-dominator tree")
+	}
+}
+
+// naivePostDom returns the post-dominance relation of the blocks of
+// fn, as computed by a naive iterative dataflow analysis: pdom[b][c]
+// reports whether c post-dominates b.
+func naivePostDom(fn *ssa.Function) [][]bool {
+	n := len(fn.Blocks)
+	pdom := make([][]bool, n)
+	for _, b := range fn.Blocks {
+		pdom[b.Index] = make([]bool, n)
+		for i := range pdom[b.Index] {
+			pdom[b.Index][i] = true
+		}
+	}
+	// Blocks that reach no exit are post-dominated by nothing.
+	reaches := make([]bool, n)
+	for changed := true; changed; {
+		changed = false
+		for _, b := range fn.Blocks {
+			if reaches[b.Index] {
+				continue
+			}
+			r := len(b.Succs) == 0
+			for _, s := range b.Succs {
+				r = r || reaches[s.Index]
+			}
+			if r {
+				reaches[b.Index] = true
+				changed = true
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range fn.Blocks {
+			next := make([]bool, n)
+			switch {
+			case !reaches[b.Index]:
+				// none
+			case len(b.Succs) == 0:
+				next[b.Index] = true
+			default:
+				for i := range next {
+					next[i] = true
+				}
+				for _, s := range b.Succs {
+					if !reaches[s.Index] {
+						continue
+					}
+					for i := range next {
+						next[i] = next[i] && pdom[s.Index][i]
+					}
+				}
+				next[b.Index] = true
+			}
+			for i := range next {
+				if next[i] != pdom[b.Index][i] {
+					pdom[b.Index][i] = next[i]
+					changed = true
+				}
+			}
+		}
+	}
+	return pdom
+}
+
+func blockIndices(blocks []*ssa.BasicBlock) []int {
+	var indices []int
+	for _, b := range blocks {
+		indices = append(indices, b.Index)
+	}
+	return indices
+}
+
+func containsBlock(blocks []*ssa.BasicBlock, b *ssa.BasicBlock) bool {
+	for _, c := range blocks {
+		if c == b {
+			return true
+		}
+	}
+	return false
+}
+
+// sameInts reports whether x and y have the same elements.
+func sameInts(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	count := make(map[int]int)
+	for _, i := range x {
+		count[i]++
+	}
+	for _, i := range y {
+		count[i]--
+	}
+	for _, c := range count {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
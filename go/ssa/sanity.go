@@ -428,7 +428,7 @@ func (s *sanity) checkFunction(fn *Function) bool {
 		}
 	}
 	if src, syn := fn.Synthetic == "", fn.Syntax() != nil; src != syn {
-		if strings.HasPrefix(fn.Synthetic, "instantiation") && fn.Prog.mode&InstantiateGenerics != 0 {
+		if strings.HasPrefix(fn.Synthetic, "instantiation") && (fn.Prog.mode&InstantiateGenerics != 0 || syn) {
 			// ok
 		} else {
 			s.errorf("got fromSource=%t, hasSyntax=%t; want same values", src, syn)
@@ -0,0 +1,566 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package slicing computes program slices over the SSA form of a
+// program: the instructions and other values that influence a given
+// value or instruction (its backward slice), or that it influences
+// (its forward slice).
+//
+// A slice follows data dependences: the operands of an instruction,
+// the stores into the memory that a load reads, the arguments of the
+// parameters of a function and the results of the functions called,
+// and so on. It does not follow control dependences. Memory is
+// tracked by variable, as far as the analysis can tell without pointer
+// analysis: a store to a field or element of a variable affects the
+// loads of any part of the variable. Global variables are not tracked
+// from one function to another.
+//
+// The slice is interprocedural, through the calls of the call graph
+// with which it is computed; it is not context-sensitive, so a value
+// returned by a function is considered to flow to all of its callers.
+// Without a call graph, the slice is confined to the function of the
+// seed, and the result of a call depends on all of its operands.
+package slicing // import "golang.org/x/tools/go/ssa/slicing"
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A Slice is a set of the instructions and other values of a program,
+// such as parameters.
+type Slice struct {
+	nodes map[ssa.Node]bool
+}
+
+// Contains reports whether the slice contains n.
+func (s *Slice) Contains(n ssa.Node) bool { return s.nodes[n] }
+
+// Len returns the number of instructions and values in the slice.
+func (s *Slice) Len() int { return len(s.nodes) }
+
+// Nodes returns the instructions and values of the slice, in order of
+// position.
+func (s *Slice) Nodes() []ssa.Node {
+	nodes := make([]ssa.Node, 0, len(s.nodes))
+	for n := range s.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		x, y := nodes[i], nodes[j]
+		if x.Pos() != y.Pos() {
+			return x.Pos() < y.Pos()
+		}
+		return x.String() < y.String()
+	})
+	return nodes
+}
+
+// A Range is the source range of an instruction or value of a slice.
+type Range struct {
+	Node       ssa.Node
+	Start, End token.Pos
+}
+
+// Ranges returns the source ranges of the instructions and values of
+// the slice that have positions, in order of position, as computed by
+// ssa.SourceRange from the syntax of files.
+func (s *Slice) Ranges(files []*ast.File) []Range {
+	var ranges []Range
+	for _, n := range s.Nodes() {
+		if start, end := ssa.SourceRange(n, files); start.IsValid() {
+			ranges = append(ranges, Range{n, start, end})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		x, y := ranges[i], ranges[j]
+		if x.Start != y.Start {
+			return x.Start < y.Start
+		}
+		return x.End < y.End
+	})
+	return ranges
+}
+
+// Backward returns the backward slice of seed, a value or an
+// instruction: seed, and the instructions and values that influence
+// it, through the calls of cg, which may be nil.
+func Backward(seed ssa.Node, cg *callgraph.Graph) *Slice {
+	s := newSlicer(cg)
+	s.add(seed, allResults)
+	if call, ok := seed.(*ssa.Call); ok {
+		// The arguments of the call influence the call, if not
+		// its results.
+		for _, arg := range callArgs(call.Common()) {
+			s.addValue(arg, allResults)
+		}
+	}
+	for len(s.queue) > 0 {
+		it := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+		s.backward(it)
+	}
+	return &Slice{s.nodes}
+}
+
+// Forward returns the forward slice of seed, a value or an
+// instruction: seed, and the instructions and values that it
+// influences, through the calls of cg, which may be nil.
+func Forward(seed ssa.Node, cg *callgraph.Graph) *Slice {
+	s := newSlicer(cg)
+	s.add(seed, allResults)
+	for len(s.queue) > 0 {
+		it := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+		s.forward(it.n)
+	}
+	return &Slice{s.nodes}
+}
+
+// allResults is the result index of an item that stands for all the
+// results of a call.
+const allResults = -1
+
+// An item is an element of the work list: a node, and for a call of
+// a backward slice, the index of the result that is of interest.
+type item struct {
+	n      ssa.Node
+	result int
+}
+
+// A slicer holds the state of the computation of a slice.
+type slicer struct {
+	cg      *callgraph.Graph
+	callees map[ssa.CallInstruction][]*ssa.Function
+	nodes   map[ssa.Node]bool
+	visited map[item]bool
+	queue   []item
+}
+
+func newSlicer(cg *callgraph.Graph) *slicer {
+	s := &slicer{
+		cg:      cg,
+		callees: make(map[ssa.CallInstruction][]*ssa.Function),
+		nodes:   make(map[ssa.Node]bool),
+		visited: make(map[item]bool),
+	}
+	if cg != nil {
+		for _, n := range cg.Nodes {
+			for _, e := range n.Out {
+				if e.Site != nil && e.Callee.Func != nil && e.Callee.Func.Blocks != nil {
+					s.callees[e.Site] = append(s.callees[e.Site], e.Callee.Func)
+				}
+			}
+		}
+	}
+	return s
+}
+
+// add adds n to the slice, and to the work list, unless it is a
+// constant or a function or built-in, which have no dependences.
+func (s *slicer) add(n ssa.Node, result int) {
+	switch n := n.(type) {
+	case nil, *ssa.Const, *ssa.Builtin, *ssa.DebugRef:
+		return
+	case *ssa.Function:
+		if n.Parent() == nil {
+			return
+		}
+	}
+	s.nodes[n] = true
+	if it := (item{n, result}); !s.visited[it] {
+		s.visited[it] = true
+		s.queue = append(s.queue, it)
+	}
+}
+
+// addValue is like add, for a value, which may be nil.
+func (s *slicer) addValue(v ssa.Value, result int) {
+	if n, ok := v.(ssa.Node); ok {
+		s.add(n, result)
+	}
+}
+
+// backward adds the dependences of the item to the slice.
+func (s *slicer) backward(it item) {
+	switch n := it.n.(type) {
+	case *ssa.Parameter:
+		// The arguments of the calls.
+		fn := n.Parent()
+		i := paramIndex(n)
+		for _, site := range s.callers(fn) {
+			if args := callArgs(site.Common()); 0 <= i && i < len(args) {
+				s.addValue(args[i], allResults)
+			}
+		}
+		return
+
+	case *ssa.FreeVar:
+		// The bindings of the closures.
+		fn := n.Parent()
+		i := freeVarIndex(n)
+		if refs := fn.Referrers(); refs != nil {
+			for _, instr := range *refs {
+				if mc, ok := instr.(*ssa.MakeClosure); ok && mc.Fn == fn && 0 <= i && i < len(mc.Bindings) {
+					s.add(mc, allResults)
+					s.addValue(mc.Bindings[i], allResults)
+				}
+			}
+		}
+		return
+
+	case *ssa.Extract:
+		if call, ok := n.Tuple.(*ssa.Call); ok {
+			s.add(call, n.Index)
+			return
+		}
+
+	case *ssa.Call:
+		if callees := s.callees[n]; len(callees) > 0 {
+			// The results of the callees, and the function
+			// value, which determines the callee.
+			for _, callee := range callees {
+				for _, ret := range returns(callee) {
+					s.nodes[ret] = true
+					for i, r := range ret.Results {
+						if it.result == allResults || i == it.result {
+							s.addValue(r, allResults)
+						}
+					}
+				}
+			}
+			if n.Call.StaticCallee() == nil {
+				s.addValue(n.Call.Value, allResults)
+			}
+			return
+		}
+	}
+
+	instr, ok := it.n.(ssa.Instruction)
+	if !ok {
+		return
+	}
+	var space [10]*ssa.Value
+	for _, op := range instr.Operands(space[:0]) {
+		s.addValue(*op, allResults)
+	}
+
+	// The stores into the memory read.
+	switch instr := instr.(type) {
+	case *ssa.UnOp:
+		if instr.Op == token.MUL || instr.Op == token.ARROW {
+			s.addWrites(instr.X)
+		}
+	case *ssa.Lookup:
+		s.addWrites(instr.X)
+	case *ssa.Range:
+		s.addWrites(instr.X)
+	case *ssa.Select:
+		for _, st := range instr.States {
+			if st.Dir == types.RecvOnly {
+				s.addWrites(st.Chan)
+			}
+		}
+	}
+}
+
+// forward adds the instructions and values that n influences to the
+// slice.
+func (s *slicer) forward(n ssa.Node) {
+	// The memory written by a seed.
+	switch n := n.(type) {
+	case *ssa.Store:
+		s.addReads(n.Addr)
+	case *ssa.MapUpdate:
+		s.addReads(n.Map)
+	case *ssa.Send:
+		s.addReads(n.Chan)
+	}
+
+	v, ok := n.(ssa.Value)
+	if !ok {
+		return
+	}
+
+	refs := v.Referrers()
+	if refs == nil {
+		return
+	}
+	for _, instr := range *refs {
+		switch instr := instr.(type) {
+		case *ssa.DebugRef:
+			// not an instruction of the slice
+
+		case *ssa.Store:
+			s.nodes[instr] = true
+			if instr.Val == v {
+				s.addReads(instr.Addr)
+			}
+
+		case *ssa.MapUpdate:
+			s.nodes[instr] = true
+			if instr.Key == v || instr.Value == v {
+				s.addReads(instr.Map)
+			}
+
+		case *ssa.Send:
+			s.nodes[instr] = true
+			if instr.X == v {
+				s.addReads(instr.Chan)
+			}
+
+		case *ssa.Select:
+			s.add(instr, allResults)
+			for _, st := range instr.States {
+				if st.Dir == types.SendOnly && st.Send == v {
+					s.addReads(st.Chan)
+				}
+			}
+
+		case *ssa.Return:
+			// The results of the calls of the function.
+			s.nodes[instr] = true
+			for i, r := range instr.Results {
+				if r == v {
+					s.addCallResults(instr.Parent(), i)
+				}
+			}
+
+		case ssa.CallInstruction:
+			s.nodes[instr.(ssa.Node)] = true
+			common := instr.Common()
+			callees := s.callees[instr]
+			if len(callees) == 0 || common.Value == v && !common.IsInvoke() {
+				// An unknown callee, or the function
+				// value, which determines the callee.
+				if call, ok := instr.(*ssa.Call); ok {
+					s.add(call, allResults)
+				}
+				continue
+			}
+			// The parameters of the callees.
+			for i, arg := range callArgs(common) {
+				if arg != v {
+					continue
+				}
+				for _, callee := range callees {
+					if i < len(callee.Params) {
+						s.add(callee.Params[i], allResults)
+					}
+				}
+			}
+
+		case *ssa.MakeClosure:
+			s.add(instr, allResults)
+			fn := instr.Fn.(*ssa.Function)
+			for i, b := range instr.Bindings {
+				if b == v && i < len(fn.FreeVars) {
+					s.add(fn.FreeVars[i], allResults)
+				}
+			}
+
+		default:
+			s.add(instr.(ssa.Node), allResults)
+		}
+	}
+}
+
+// addCallResults adds the values of result i of the calls of fn to
+// the forward slice.
+func (s *slicer) addCallResults(fn *ssa.Function, i int) {
+	for _, site := range s.callers(fn) {
+		call, ok := site.(*ssa.Call)
+		if !ok {
+			continue // go or defer
+		}
+		if call.Call.Signature().Results().Len() == 1 {
+			s.add(call, allResults)
+			continue
+		}
+		s.nodes[call] = true
+		if refs := call.Referrers(); refs != nil {
+			for _, instr := range *refs {
+				if ext, ok := instr.(*ssa.Extract); ok && ext.Index == i {
+					s.add(ext, allResults)
+				}
+			}
+		}
+	}
+}
+
+// addWrites adds the instructions that write the memory of the
+// location loc, a variable or a part of it, a map, or a channel, to
+// the backward slice.
+func (s *slicer) addWrites(loc ssa.Value) {
+	locs := locations(loc)
+	for _, l := range locs {
+		refs := l.Referrers()
+		if refs == nil {
+			continue
+		}
+		for _, instr := range *refs {
+			switch instr := instr.(type) {
+			case *ssa.Store:
+				if locs.contains(instr.Addr) {
+					s.add(instr, allResults)
+				}
+			case *ssa.MapUpdate:
+				if locs.contains(instr.Map) {
+					s.add(instr, allResults)
+				}
+			case *ssa.Send:
+				if locs.contains(instr.Chan) {
+					s.add(instr, allResults)
+				}
+			case *ssa.Select:
+				for _, st := range instr.States {
+					if st.Dir == types.SendOnly && locs.contains(st.Chan) {
+						s.nodes[instr] = true
+						s.addValue(st.Send, allResults)
+					}
+				}
+			}
+		}
+	}
+}
+
+// addReads adds the instructions that read the memory of the
+// location loc to the forward slice.
+func (s *slicer) addReads(loc ssa.Value) {
+	locs := locations(loc)
+	for _, l := range locs {
+		refs := l.Referrers()
+		if refs == nil {
+			continue
+		}
+		for _, instr := range *refs {
+			switch instr := instr.(type) {
+			case *ssa.UnOp:
+				if instr.Op == token.MUL || instr.Op == token.ARROW {
+					s.add(instr, allResults)
+				}
+			case *ssa.Lookup:
+				if locs.contains(instr.X) {
+					s.add(instr, allResults)
+				}
+			case *ssa.Range:
+				s.add(instr, allResults)
+			case *ssa.Select:
+				for _, st := range instr.States {
+					if st.Dir == types.RecvOnly && locs.contains(st.Chan) {
+						s.add(instr, allResults)
+					}
+				}
+			}
+		}
+	}
+}
+
+// A locationSet is the set of the addresses of a variable and its
+// parts.
+type locationSet []ssa.Value
+
+func (locs locationSet) contains(v ssa.Value) bool {
+	for _, l := range locs {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
+
+// locations returns the location loc, the variable of which it is a
+// part, if any, and all the parts of the variable that are addressed.
+func locations(loc ssa.Value) locationSet {
+	root := loc
+	for {
+		switch x := root.(type) {
+		case *ssa.FieldAddr:
+			root = x.X
+			continue
+		case *ssa.IndexAddr:
+			root = x.X
+			continue
+		}
+		break
+	}
+
+	locs := locationSet{root}
+	for i := 0; i < len(locs); i++ {
+		refs := locs[i].Referrers()
+		if refs == nil {
+			continue
+		}
+		for _, instr := range *refs {
+			switch instr := instr.(type) {
+			case *ssa.FieldAddr:
+				locs = append(locs, instr)
+			case *ssa.IndexAddr:
+				locs = append(locs, instr)
+			}
+		}
+	}
+	return locs
+}
+
+// callers returns the call sites of fn in the call graph.
+func (s *slicer) callers(fn *ssa.Function) []ssa.CallInstruction {
+	if s.cg == nil {
+		return nil
+	}
+	node := s.cg.Nodes[fn]
+	if node == nil {
+		return nil
+	}
+	var sites []ssa.CallInstruction
+	for _, e := range node.In {
+		if e.Site != nil {
+			sites = append(sites, e.Site)
+		}
+	}
+	return sites
+}
+
+// callArgs returns the arguments of a call, in the order of the
+// parameters of the callee: the receiver of an invoke-mode call first.
+func callArgs(common *ssa.CallCommon) []ssa.Value {
+	if common.IsInvoke() {
+		return append([]ssa.Value{common.Value}, common.Args...)
+	}
+	return common.Args
+}
+
+// returns returns the return instructions of fn.
+func returns(fn *ssa.Function) []*ssa.Return {
+	var rets []*ssa.Return
+	for _, b := range fn.Blocks {
+		if ret, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return); ok {
+			rets = append(rets, ret)
+		}
+	}
+	return rets
+}
+
+func paramIndex(p *ssa.Parameter) int {
+	for i, q := range p.Parent().Params {
+		if q == p {
+			return i
+		}
+	}
+	return -1
+}
+
+func freeVarIndex(fv *ssa.FreeVar) int {
+	for i, q := range fv.Parent().FreeVars {
+		if q == fv {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slicing_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/slicing"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+const src = `package p
+
+func source() int { return 1 }
+
+func double(x int) int { return x * 2 }
+
+func f() int {
+	a := source()
+	b := 3
+	c := double(a)
+	return c + b
+}
+
+func g() {
+	var s struct{ x, y int }
+	s.x = source()
+	s.y = 2
+	sink(s.x)
+}
+
+func h() (int, int) { return source(), 2 }
+
+func k() {
+	x, y := h()
+	sink(x)
+	sink(y)
+}
+
+func sink(int) {}
+`
+
+func TestSlice(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, _, err := ssautil.BuildPackage(&types.Config{}, fset, types.NewPackage("p", ""), []*ast.File{f}, ssa.SanityCheckFunctions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg := cha.CallGraph(pkg.Prog)
+
+	// texts returns the source text of the ranges of the slice.
+	texts := func(s *slicing.Slice) []string {
+		var texts []string
+		for _, r := range s.Ranges([]*ast.File{f}) {
+			if r.End.IsValid() {
+				texts = append(texts, src[fset.Position(r.Start).Offset:fset.Position(r.End).Offset])
+			}
+		}
+		return texts
+	}
+	// call returns the first call of fn named name.
+	call := func(fn, name string) *ssa.Call {
+		for _, b := range pkg.Func(fn).Blocks {
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(*ssa.Call); ok {
+					if callee := call.Call.StaticCallee(); callee != nil && callee.Name() == name {
+						return call
+					}
+				}
+			}
+		}
+		t.Fatalf("no call of %s in %s", name, fn)
+		return nil
+	}
+	// ret returns the return instruction of fn.
+	ret := func(fn string) *ssa.Return {
+		blocks := pkg.Func(fn).Blocks
+		return blocks[len(blocks)-1].Instrs[len(blocks[len(blocks)-1].Instrs)-1].(*ssa.Return)
+	}
+
+	for _, test := range []struct {
+		name string
+		s    *slicing.Slice
+		want []string
+	}{
+		{"backward", slicing.Backward(ret("f"), cg), []string{
+			"return 1", "x", "return x * 2", "x * 2", "source()", "double(a)", "return c + b", "c + b",
+		}},
+		{"backward, intraprocedural", slicing.Backward(ret("f"), nil), []string{
+			"source()", "double(a)", "return c + b", "c + b",
+		}},
+		// The store into s.y affects the load of s.x, as both are
+		// parts of s.
+		{"backward, memory", slicing.Backward(call("g", "sink"), cg), []string{
+			"return 1", "s", "s.x", "s.x", "source()", "s.y", "s.y", "sink(s.x)", "s.x", "s.x",
+		}},
+		{"backward, tuple", slicing.Backward(call("k", "sink"), cg), []string{
+			"return 1", "return source(), 2", "source()", "h()", "sink(x)",
+		}},
+		{"forward", slicing.Forward(pkg.Func("double").Params[0], cg), []string{
+			"x", "return x * 2", "x * 2", "double(a)", "return c + b", "c + b",
+		}},
+		{"forward, memory", slicing.Forward(call("g", "source"), cg), []string{
+			"s.x", "source()", "sink(s.x)", "s.x", "int",
+		}},
+	} {
+		if got := texts(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
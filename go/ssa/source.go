@@ -167,6 +167,128 @@ func (f *Function) ValueForExpr(e ast.Expr) (value Value, isAddr bool) {
 	return
 }
 
+// SourceRange returns the range [start, end) of the source
+// expression or statement from which x, a Value or an Instruction,
+// arose, as precisely as the available information permits.
+//
+// If x is a value of a function built with debug information, the
+// range is that of the expression of its DebugRef, if any, other than
+// a mere identifier. Otherwise, the range is that of the innermost
+// syntax node, within files or the retained syntax of the function,
+// whose designated token is at x.Pos(): the whole call for the Lparen
+// of a call, the whole selector for the Sel of a field selection, and
+// so on. A value with no position of its own that arose from an
+// implicit conversion has the range of the converted operand.
+//
+// Diagnostics of SSA-based analyses should use this range, rather
+// than that of the enclosing statement.
+//
+// If no such syntax is found, it returns the identifier of a DebugRef
+// of x, if any, or else x.Pos() and token.NoPos.
+func SourceRange(x interface{ Pos() token.Pos }, files []*ast.File) (start, end token.Pos) {
+	if ref, ok := x.(*DebugRef); ok {
+		return ref.Expr.Pos(), ref.Expr.End()
+	}
+
+	var fn *Function
+	switch x := x.(type) {
+	case *Function:
+		if syntax := x.Syntax(); syntax != nil {
+			return syntax.Pos(), syntax.End()
+		}
+	case Instruction:
+		fn = x.Parent()
+	case Value:
+		fn = x.Parent()
+	}
+
+	// A reference to a variable or field by name is less precise than
+	// the syntax at x.Pos(), so it is only a last resort.
+	var ident ast.Expr
+	if v, ok := x.(Value); ok && fn != nil && fn.debugInfo() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if ref, ok := instr.(*DebugRef); ok && ref.X == v {
+					if _, ok := ref.Expr.(*ast.Ident); !ok {
+						return ref.Expr.Pos(), ref.Expr.End()
+					}
+					if ident == nil {
+						ident = ref.Expr
+					}
+				}
+			}
+		}
+	}
+	fallback := func() (token.Pos, token.Pos) {
+		if ident != nil {
+			return ident.Pos(), ident.End()
+		}
+		return x.Pos(), token.NoPos
+	}
+
+	pos := x.Pos()
+	if !pos.IsValid() {
+		// Implicit conversions have the range of their operand.
+		switch x := x.(type) {
+		case *ChangeType:
+			return SourceRange(x.X, files)
+		case *Convert:
+			return SourceRange(x.X, files)
+		case *ChangeInterface:
+			return SourceRange(x.X, files)
+		case *MakeInterface:
+			return SourceRange(x.X, files)
+		case *SliceToArrayPointer:
+			return SourceRange(x.X, files)
+		}
+		return fallback()
+	}
+
+	var root ast.Node
+	for _, file := range files {
+		if file.Pos() <= pos && pos < file.End() {
+			root = file
+			break
+		}
+	}
+	if root == nil && fn != nil {
+		if syntax := fn.Syntax(); syntax != nil {
+			if _, ok := syntax.(extentNode); !ok {
+				root = syntax
+			}
+		}
+	}
+	if root == nil {
+		return fallback()
+	}
+
+	// Find the innermost node containing pos, and its parent.
+	var n, parent ast.Node
+	ast.Inspect(root, func(node ast.Node) bool {
+		if node == nil || !(node.Pos() <= pos && pos < node.End()) {
+			return false
+		}
+		n, parent = node, n
+		return true
+	})
+	if n == nil {
+		return fallback()
+	}
+
+	// The designated token of some nodes belongs to a child.
+	switch parent := parent.(type) {
+	case *ast.SelectorExpr:
+		if parent.Sel == n {
+			n = parent // a field or method selection
+		}
+	case *ast.FuncLit:
+		if parent.Type == n {
+			n = parent // a function literal
+		}
+	}
+	return n.Pos(), n.End()
+}
+
 // --- Lookup functions for source-level named entities (types.Objects) ---
 
 // Package returns the SSA Package corresponding to the specified
@@ -15,6 +15,7 @@ import (
 	"go/types"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
@@ -427,3 +428,84 @@ func TestEnclosingFunction(t *testing.T) {
 		}
 	}
 }
+
+const sourceRangeSrc = `package p
+
+type T struct{ f int }
+
+var t T
+
+func g(x int) int { return x }
+
+func h() interface{} {
+	var i interface{} = g(1) + 2
+	t.f = g(t.f)
+	println(i)
+	fn := func() {}
+	fn()
+	return i
+}
+`
+
+func TestSourceRange(t *testing.T) {
+	want := []string{
+		"Call g(1)",
+		"BinOp g(1) + 2",
+		"MakeInterface g(1) + 2", // implicit conversion
+		"FieldAddr t.f",
+		"FieldAddr t.f",
+		"UnOp t.f",
+		"Call g(t.f)",
+		"Store t.f",
+		"Call println(i)",
+		"Call fn()",
+		"Return return i",
+	}
+	for _, debug := range []bool{false, true} {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", sourceRangeSrc, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mode := ssa.BuilderMode(0)
+		if debug {
+			mode = ssa.GlobalDebug
+		}
+		pkg, _, err := ssautil.BuildPackage(&types.Config{}, fset, types.NewPackage("p", ""), []*ast.File{f}, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := func(x interface{ Pos() token.Pos }, files []*ast.File) string {
+			start, end := ssa.SourceRange(x, files)
+			if !end.IsValid() {
+				return "<none>"
+			}
+			return sourceRangeSrc[fset.Position(start).Offset:fset.Position(end).Offset]
+		}
+
+		var got, withoutFiles []string
+		h := pkg.Func("h")
+		for _, b := range h.Blocks {
+			for _, instr := range b.Instrs {
+				if _, ok := instr.(*ssa.DebugRef); ok {
+					continue
+				}
+				kind := strings.TrimPrefix(fmt.Sprintf("%T", instr), "*ssa.")
+				got = append(got, kind+" "+text(instr, []*ast.File{f}))
+				withoutFiles = append(withoutFiles, kind+" "+text(instr, nil))
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("debug=%t: got ranges %q, want %q", debug, got, want)
+		}
+		// Only a function built with debug information retains the
+		// syntax in which to find the ranges.
+		if debug && !reflect.DeepEqual(withoutFiles, want) {
+			t.Errorf("debug=%t: got ranges %q without files, want %q", debug, withoutFiles, want)
+		}
+
+		if got, want := text(h.AnonFuncs[0], nil), "func() {}"; got != want {
+			t.Errorf("debug=%t: got range %q for the function literal, want %q", debug, got, want)
+		}
+	}
+}
@@ -37,6 +37,8 @@ type Program struct {
 	thunks        map[selectionKey]*Function // thunks for T.Method expressions
 	instances     map[*Function]*instanceSet // instances of generic functions
 	parameterized tpWalker                   // determines whether a type is parameterized.
+
+	buildMu sync.Mutex // serializes BuildInstance and BuildFunction
 }
 
 // A Package is a single analyzed Go package containing Members for
@@ -55,6 +57,8 @@ type Package struct {
 	init    *Function               // Func("init"); the package's init function
 	debug   bool                    // include full debug info in this package
 
+	discardBodies bool // discard the bodies of the functions once built
+
 	// The following fields are set transiently, then cleared
 	// after building.
 	buildOnce sync.Once   // ensures package building occurs once
@@ -338,6 +342,7 @@ type Function struct {
 	lblocks      map[types.Object]*lblock // labelled blocks
 	info         *types.Info              // *types.Info to build from. nil for wrappers.
 	subst        *subster                 // type substitution cache
+	arena        *arena                   // allocator of instructions, or nil
 }
 
 // BasicBlock represents an SSA basic block.
@@ -355,7 +360,7 @@ type Function struct {
 //
 // Each BasicBlock is also a node in the dominator tree of the CFG.
 // The tree may be navigated using Idom()/Dominees() and queried using
-// Dominates().
+// Dominates(). See also Function.DomTree, PostDomTree and ControlDeps.
 //
 // The order of Preds and Succs is significant (to Phi and If
 // instructions, respectively).
@@ -1469,6 +1474,19 @@ func (v *Function) Referrers() *[]Instruction {
 	return nil
 }
 
+// TypeParams returns the type parameters of the function if it is
+// generic, or those that were instantiated if it is an instantiation.
+func (v *Function) TypeParams() []*typeparams.TypeParam { return v._TypeParams }
+
+// TypeArgs returns the type arguments of the function if it is an
+// instantiation, or of the enclosing function in which it is an
+// anonymous function, and nil otherwise.
+func (v *Function) TypeArgs() []types.Type { return v._TypeArgs }
+
+// Origin returns the generic function of which the function is an
+// instantiation, or nil if it is not one.
+func (v *Function) Origin() *Function { return v._Origin }
+
 func (v *Parameter) Type() types.Type          { return v.typ }
 func (v *Parameter) Name() string              { return v.name }
 func (v *Parameter) Object() types.Object      { return v.object }
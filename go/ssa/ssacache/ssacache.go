@@ -0,0 +1,323 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ssacache defines compact summaries of the SSA form of
+// packages, sufficient for the call-graph clients of whole programs,
+// and a cache of them in a directory, keyed by the contents of the
+// packages and of their dependencies, so that a tool that analyzes a
+// program need not build the SSA form of its dependencies on every
+// run.
+//
+// A Summary records, for each function of a package, its calls, the
+// functions it refers to otherwise than by calling them, and, for each
+// named type of the package, its methods. A function is named in a
+// summary by its String, and a position by its token.Position String.
+package ssacache // import "golang.org/x/tools/go/ssa/ssacache"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// version is part of every key, and changes when the format of
+// summaries does.
+const version = "go/ssa/ssacache v1"
+
+// A Summary summarizes the SSA form of a package.
+type Summary struct {
+	Path      string      // import path of the package
+	Functions []*Function // functions of the package, in order of position
+	Types     []*Type     // named types of the package that have methods
+}
+
+// A Function summarizes the SSA form of a function.
+type Function struct {
+	Name      string   // the function's String
+	Pos       string   `json:",omitempty"` // position of its declaration, if any
+	Synthetic string   `json:",omitempty"` // provenance of a synthetic function
+	Calls     []*Call  `json:",omitempty"` // call sites, in order of instructions
+	Refs      []string `json:",omitempty"` // functions referred to other than by a call, sorted
+}
+
+// A Call summarizes a call site: a static call of Callee, a dynamic
+// call of a function value, or an invocation of the Method of an
+// interface, of which exactly one is set.
+type Call struct {
+	Pos     string `json:",omitempty"`
+	Kind    string // "call", "go" or "defer"
+	Callee  string `json:",omitempty"` // the callee of a static call
+	Dynamic string `json:",omitempty"` // the type of the function value of a dynamic call
+	Method  string `json:",omitempty"` // the full name of the method of an invocation
+}
+
+// A Type summarizes the methods of a named type.
+type Type struct {
+	Name    string            // the type's String
+	Methods map[string]string // function of each method of T or *T, by name
+}
+
+// Summarize returns the summary of the package, which must be built.
+func Summarize(pkg *ssa.Package) *Summary {
+	prog := pkg.Prog
+	s := &Summary{Path: pkg.Pkg.Path()}
+
+	var fns []*ssa.Function
+	seen := make(map[*ssa.Function]bool)
+	var add func(fn *ssa.Function)
+	add = func(fn *ssa.Function) {
+		if fn != nil && !seen[fn] {
+			seen[fn] = true
+			fns = append(fns, fn)
+			for _, anon := range fn.AnonFuncs {
+				add(anon)
+			}
+		}
+	}
+	var names []string
+	for name := range pkg.Members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch mem := pkg.Members[name].(type) {
+		case *ssa.Function:
+			add(mem)
+		case *ssa.Type:
+			named, ok := mem.Type().(*types.Named)
+			if !ok || types.IsInterface(named) || named.TypeParams().Len() > 0 {
+				continue
+			}
+			t := &Type{Name: named.String(), Methods: make(map[string]string)}
+			for _, sel := range typeutil.IntuitiveMethodSet(named, &prog.MethodSets) {
+				fn := prog.MethodValue(sel)
+				t.Methods[fn.Name()] = fn.String()
+				if fn.Pkg == pkg && fn.Synthetic == "" {
+					add(fn)
+				}
+			}
+			if len(t.Methods) > 0 {
+				s.Types = append(s.Types, t)
+			}
+		}
+	}
+	sort.SliceStable(fns, func(i, j int) bool { return fns[i].Pos() < fns[j].Pos() })
+	for _, fn := range fns {
+		s.Functions = append(s.Functions, summarize(prog.Fset, fn))
+	}
+	return s
+}
+
+// summarize returns the summary of the function.
+func summarize(fset *token.FileSet, fn *ssa.Function) *Function {
+	f := &Function{
+		Name:      fn.String(),
+		Pos:       position(fset, fn.Pos()),
+		Synthetic: fn.Synthetic,
+	}
+	refs := make(map[string]bool)
+	var buf [10]*ssa.Value // avoid alloc in common case
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			ops := instr.Operands(buf[:0])
+			if site, ok := instr.(ssa.CallInstruction); ok {
+				f.Calls = append(f.Calls, summarizeCall(fset, site))
+				if !site.Common().IsInvoke() {
+					ops = ops[1:] // the operand of the callee
+				}
+			}
+			for _, op := range ops {
+				if g, ok := (*op).(*ssa.Function); ok {
+					refs[g.String()] = true
+				}
+			}
+		}
+	}
+	for name := range refs {
+		f.Refs = append(f.Refs, name)
+	}
+	sort.Strings(f.Refs)
+	return f
+}
+
+func summarizeCall(fset *token.FileSet, site ssa.CallInstruction) *Call {
+	c := &Call{Pos: position(fset, site.Pos()), Kind: "call"}
+	switch site.(type) {
+	case *ssa.Go:
+		c.Kind = "go"
+	case *ssa.Defer:
+		c.Kind = "defer"
+	}
+	common := site.Common()
+	switch {
+	case common.IsInvoke():
+		c.Method = common.Method.FullName()
+	case common.StaticCallee() != nil:
+		c.Callee = common.StaticCallee().String()
+	default:
+		c.Dynamic = common.Value.Type().String()
+	}
+	return c
+}
+
+func position(fset *token.FileSet, pos token.Pos) string {
+	if !pos.IsValid() {
+		return ""
+	}
+	return fset.Position(pos).String()
+}
+
+// Keys returns the key of each of the packages and of their
+// dependencies: the hash of the contents of its compiled Go files, or,
+// for a package without them, of its export data, and of the keys of
+// its dependencies, in the builder mode. The packages must have been
+// loaded with at least the packages.NeedFiles, packages.NeedCompiledGoFiles,
+// packages.NeedImports and packages.NeedDeps modes.
+func Keys(pkgs []*packages.Package, mode ssa.BuilderMode) (map[*packages.Package]string, error) {
+	keys := make(map[*packages.Package]string)
+	var err error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if err != nil {
+			return
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\n%s\nmode %s\n", version, runtime.Version(), mode)
+		fmt.Fprintf(h, "id %q path %q name %q\n", pkg.ID, pkg.PkgPath, pkg.Name)
+		files := pkg.CompiledGoFiles
+		if len(files) == 0 && pkg.ExportFile != "" {
+			files = []string{pkg.ExportFile}
+		}
+		for _, name := range files {
+			data, ferr := ioutil.ReadFile(name)
+			if ferr != nil {
+				err = ferr
+				return
+			}
+			fmt.Fprintf(h, "file %q %x\n", filepath.Base(name), sha256.Sum256(data))
+		}
+		var paths []string
+		for path := range pkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			fmt.Fprintf(h, "import %q %s\n", path, keys[pkg.Imports[path]])
+		}
+		keys[pkg] = hex.EncodeToString(h.Sum(nil))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// A Cache holds summaries in the files of a directory, named by their
+// keys. Summaries are written to temporary files that are then
+// renamed, so that concurrent processes may share a cache.
+type Cache struct {
+	Dir string
+}
+
+// Get returns the summary of the key, or nil if the cache has none.
+func (c *Cache) Get(key string) *Summary {
+	data, err := ioutil.ReadFile(filepath.Join(c.Dir, key))
+	if err != nil {
+		return nil
+	}
+	var s Summary
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil // a malformed entry is a missing one
+	}
+	return &s
+}
+
+// Put records the summary of the key.
+func (c *Cache) Put(key string, s *Summary) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.Dir, key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Load returns the summaries of the packages and of all their
+// dependencies, in dependency order, from the cache if it has them,
+// and otherwise by building the SSA form of the packages that it
+// lacks, in the builder mode, and recording their summaries. The
+// packages must have been loaded as required by Keys, and those whose
+// summaries the cache lacks with syntax and type information, as by
+// the packages.LoadAllSyntax mode.
+func (c *Cache) Load(pkgs []*packages.Package, mode ssa.BuilderMode) ([]*Summary, error) {
+	keys, err := Keys(pkgs, mode)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		order   []*packages.Package
+		missing []*packages.Package
+	)
+	summaries := make(map[*packages.Package]*Summary)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		order = append(order, pkg)
+		if s := c.Get(keys[pkg]); s != nil {
+			summaries[pkg] = s
+		} else {
+			missing = append(missing, pkg)
+		}
+	})
+
+	if len(missing) > 0 {
+		for _, pkg := range missing {
+			if pkg.IllTyped || pkg.Types == nil || pkg.TypesInfo == nil || pkg.Syntax == nil {
+				return nil, fmt.Errorf("cannot summarize package %s: no well-typed syntax", pkg.PkgPath)
+			}
+		}
+		prog, _ := ssautil.AllPackages(pkgs, mode)
+		for _, pkg := range missing {
+			ssapkg := prog.Package(pkg.Types)
+			ssapkg.Build()
+			s := Summarize(ssapkg)
+			if err := c.Put(keys[pkg], s); err != nil {
+				return nil, err
+			}
+			summaries[pkg] = s
+		}
+	}
+
+	result := make([]*Summary, len(order))
+	for i, pkg := range order {
+		result[i] = summaries[pkg]
+	}
+	return result, nil
+}
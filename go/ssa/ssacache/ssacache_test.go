@@ -0,0 +1,137 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssacache_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssacache"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+const src = `package p
+
+type I interface{ M() }
+
+type T int
+
+func (T) M() {}
+
+func f(i I, g func()) {
+	i.M()
+	g()
+	defer h()
+	go func() {}()
+}
+
+func h() {
+	f(T(0), h)
+}
+`
+
+func TestSummarize(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, _, err := ssautil.BuildPackage(&types.Config{}, fset, types.NewPackage("p", ""), []*ast.File{f}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ssacache.Summarize(pkg)
+
+	var names []string
+	fns := make(map[string]*ssacache.Function)
+	for _, fn := range s.Functions {
+		names = append(names, fn.Name)
+		fns[fn.Name] = fn
+	}
+	if want := []string{"p.init", "(p.T).M", "p.f", "p.f$1", "p.h"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("functions: got %v, want %v", names, want)
+	}
+
+	var calls []ssacache.Call
+	for _, c := range fns["p.f"].Calls {
+		c := *c
+		c.Pos = ""
+		calls = append(calls, c)
+	}
+	wantCalls := []ssacache.Call{
+		{Kind: "call", Method: "(p.I).M"},
+		{Kind: "call", Dynamic: "func()"},
+		{Kind: "defer", Callee: "p.h"},
+		{Kind: "go", Callee: "p.f$1"},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("calls of p.f: got %+v, want %+v", calls, wantCalls)
+	}
+	if got, want := fns["p.h"].Refs, []string{"p.h"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("refs of p.h: got %v, want %v", got, want)
+	}
+
+	if len(s.Types) != 1 || s.Types[0].Name != "p.T" || s.Types[0].Methods["M"] != "(p.T).M" {
+		t.Errorf("types: got %+v", s.Types)
+	}
+
+	// The summary survives the cache.
+	c := &ssacache.Cache{Dir: t.TempDir()}
+	if c.Get("k") != nil {
+		t.Errorf("Get of a missing key returned a summary")
+	}
+	if err := c.Put("k", s); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Get("k"); !reflect.DeepEqual(got, s) {
+		t.Errorf("Get returned %+v, want %+v", got, s)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	dep := &packages.Package{ID: "dep", PkgPath: "dep", CompiledGoFiles: []string{write("dep.go", "package dep")}}
+	pkg := &packages.Package{ID: "p", PkgPath: "p", CompiledGoFiles: []string{write("p.go", "package p")},
+		Imports: map[string]*packages.Package{"dep": dep}}
+
+	keys, err := ssacache.Keys([]*packages.Package{pkg}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[pkg] == keys[dep] {
+		t.Fatalf("got keys %v", keys)
+	}
+	if again, _ := ssacache.Keys([]*packages.Package{pkg}, 0); again[pkg] != keys[pkg] {
+		t.Errorf("the key of an unchanged package changed")
+	}
+	if other, _ := ssacache.Keys([]*packages.Package{pkg}, ssa.InstantiateGenerics); other[pkg] == keys[pkg] {
+		t.Errorf("the key does not depend on the mode")
+	}
+
+	// A change to a dependency changes the key of its importers.
+	write("dep.go", "package dep // changed")
+	changed, err := ssacache.Keys([]*packages.Package{pkg}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed[pkg] == keys[pkg] || changed[dep] == keys[dep] {
+		t.Errorf("the keys did not change with a dependency")
+	}
+}
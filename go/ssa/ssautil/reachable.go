@@ -0,0 +1,87 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssautil
+
+import "golang.org/x/tools/go/ssa"
+
+// BuildReachable builds the SSA code of the functions of prog
+// reachable from the roots, as approximated cheaply, and returns the
+// set of them, including the roots. Unlike Program.Build, it builds
+// no other function of the packages of the roots, and none of the
+// packages they import, which saves time and memory for tools that
+// only care about the code reachable from main, or from the tests.
+//
+// A function is reachable if it is a root; if a reachable function
+// refers to it, by calling it or otherwise; or if it is a method of a
+// runtime type whose name is that of a method that a reachable
+// function calls through an interface. Calls by reflection are not
+// accounted for, and a package initializer is only reachable, and
+// built with its package (see Program.BuildFunction), if a root
+// refers to it.
+//
+// BuildReachable must not be called while packages of prog are being
+// built.
+func BuildReachable(prog *ssa.Program, roots []*ssa.Function) map[*ssa.Function]bool {
+	r := reachability{
+		prog:    prog,
+		seen:    make(map[*ssa.Function]bool),
+		invoked: make(map[string]bool),
+	}
+	for _, fn := range roots {
+		r.add(fn)
+	}
+	for len(r.queue) > 0 {
+		for len(r.queue) > 0 {
+			fn := r.queue[len(r.queue)-1]
+			r.queue = r.queue[:len(r.queue)-1]
+			r.visit(fn)
+		}
+
+		// The methods of the runtime types that may be invoked.
+		for _, T := range prog.RuntimeTypes() {
+			mset := prog.MethodSets.MethodSet(T)
+			for i, n := 0, mset.Len(); i < n; i++ {
+				if sel := mset.At(i); r.invoked[sel.Obj().Name()] {
+					if fn := prog.MethodValue(sel); fn != nil {
+						r.add(fn)
+					}
+				}
+			}
+		}
+	}
+	return r.seen
+}
+
+type reachability struct {
+	prog    *ssa.Program
+	seen    map[*ssa.Function]bool
+	queue   []*ssa.Function // reachable functions not yet visited
+	invoked map[string]bool // names of the methods called through interfaces
+}
+
+func (r *reachability) add(fn *ssa.Function) {
+	if !r.seen[fn] {
+		r.seen[fn] = true
+		r.queue = append(r.queue, fn)
+	}
+}
+
+// visit builds fn and adds the functions that it refers to.
+func (r *reachability) visit(fn *ssa.Function) {
+	r.prog.BuildFunction(fn)
+	var buf [10]*ssa.Value // avoid alloc in common case
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if call, ok := instr.(ssa.CallInstruction); ok && call.Common().IsInvoke() {
+				r.invoked[call.Common().Method.Name()] = true
+			}
+			for _, op := range instr.Operands(buf[:0]) {
+				if fn, ok := (*op).(*ssa.Function); ok {
+					r.add(fn)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssautil_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+const reachableSrc = `package main
+
+type I interface{ M() }
+
+type T int
+
+func (T) M() { m() }
+func (T) N() {}
+
+type U int
+
+func (U) M() {}
+
+func m() {}
+
+func f(i I) { i.M() }
+
+func h() {}
+
+func unused() {}
+
+func main() {
+	f(T(0))
+	g := func() { h() }
+	g()
+}
+`
+
+func TestBuildReachable(t *testing.T) {
+	var conf loader.Config
+	f, err := conf.ParseFile("main.go", reachableSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("main", f)
+	lprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(lprog, ssa.SanityCheckFunctions)
+	main := prog.Package(lprog.Created[0].Pkg)
+
+	reachable := make(map[string]*ssa.Function)
+	for fn := range ssautil.BuildReachable(prog, []*ssa.Function{main.Func("main")}) {
+		reachable[fn.String()] = fn
+	}
+	for _, name := range []string{"main.main", "main.main$1", "main.f", "main.h", "main.m", "(main.T).M"} {
+		if fn := reachable[name]; fn == nil {
+			t.Errorf("%s is not reachable", name)
+		} else if fn.Blocks == nil {
+			t.Errorf("reachable %s was not built", name)
+		}
+	}
+	for _, name := range []string{"main.unused", "main.init", "(main.T).N", "(main.U).M"} {
+		if reachable[name] != nil {
+			t.Errorf("%s is reachable", name)
+		}
+	}
+
+	// The unreachable functions were not built.
+	if fn := main.Func("unused"); fn.Blocks != nil {
+		t.Errorf("unreachable %s was built", fn)
+	}
+	if fn := main.Func("init"); fn.Blocks != nil {
+		t.Errorf("unreachable %s was built", fn)
+	}
+
+	// Building the package builds the rest.
+	body := main.Func("main").Blocks
+	prog.Build()
+	if fn := main.Func("unused"); fn.Blocks == nil {
+		t.Errorf("%s was not built by Build", fn)
+	}
+	if fn := main.Func("main"); &fn.Blocks[0] != &body[0] {
+		t.Errorf("%s was built again by Build", fn)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package objectpath
+
+// This file defines the correspondence of objects and types across
+// separately loaded programs, such as two versions of a module.
+
+import (
+	"fmt"
+	"go/types"
+
+	"golang.org/x/tools/internal/typeparams"
+)
+
+// A Key identifies an object independent of the program that it
+// belongs to: by the path of its package and its path within the
+// package. Objects of separately loaded programs correspond to the
+// same declaration if they have the same Key.
+//
+// The path of a package-level object is its name, but the path of a
+// field, method or parameter encodes its index among its siblings, so
+// a declaration whose index changes from one version of a package to
+// another, for instance because a field is added before it, has
+// different Keys in the two.
+type Key struct {
+	PkgPath string
+	Path    Path
+}
+
+func (k Key) String() string { return k.PkgPath + "." + string(k.Path) }
+
+// KeyFor returns the Key of obj, or an error if obj has no path (see
+// For).
+func KeyFor(obj types.Object) (Key, error) {
+	p, err := For(obj)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{obj.Pkg().Path(), p}, nil
+}
+
+// Resolve returns the object of package pkg that the Key denotes.
+func Resolve(pkg *types.Package, k Key) (types.Object, error) {
+	if pkg.Path() != k.PkgPath {
+		return nil, fmt.Errorf("key %s does not belong to package %s", k, pkg.Path())
+	}
+	return Object(pkg, k.Path)
+}
+
+// SameObject reports whether the objects x and y, which may belong to
+// separately loaded programs, correspond to the same declaration: if
+// they are the same object, or have the same Key. It reports false for
+// distinct objects without paths, such as local variables.
+func SameObject(x, y types.Object) bool {
+	if x == y {
+		return true
+	}
+	if x == nil || y == nil {
+		return false
+	}
+	kx, err := KeyFor(x)
+	if err != nil {
+		return false
+	}
+	ky, err := KeyFor(y)
+	return err == nil && kx == ky
+}
+
+// SameType reports whether the types x and y, which may belong to
+// separately loaded programs, are the same type: whether they would be
+// identical (see types.Identical) if the defined types and type
+// parameters of the two programs that SameObject relates were the
+// same. The types of different versions of a package are the same if
+// their declarations correspond, even if the declarations' underlying
+// types differ.
+func SameType(x, y types.Type) bool {
+	return sameType(x, y, nil)
+}
+
+// A tparamPair records two type parameters of the signatures being
+// compared that are the same, for the duration of the comparison.
+type tparamPair struct {
+	x, y *typeparams.TypeParam
+	prev *tparamPair
+}
+
+func sameType(x, y types.Type, tparams *tparamPair) bool {
+	x, y = typeparams.Unalias(x), typeparams.Unalias(y)
+	if x == y {
+		return true
+	}
+	switch x := x.(type) {
+	case *types.Basic:
+		if y, ok := y.(*types.Basic); ok {
+			return x.Kind() == y.Kind()
+		}
+
+	case *types.Array:
+		if y, ok := y.(*types.Array); ok {
+			return x.Len() == y.Len() && sameType(x.Elem(), y.Elem(), tparams)
+		}
+
+	case *types.Slice:
+		if y, ok := y.(*types.Slice); ok {
+			return sameType(x.Elem(), y.Elem(), tparams)
+		}
+
+	case *types.Struct:
+		if y, ok := y.(*types.Struct); ok && x.NumFields() == y.NumFields() {
+			for i := 0; i < x.NumFields(); i++ {
+				f, g := x.Field(i), y.Field(i)
+				if f.Embedded() != g.Embedded() ||
+					x.Tag(i) != y.Tag(i) ||
+					!sameName(f, g) ||
+					!sameType(f.Type(), g.Type(), tparams) {
+					return false
+				}
+			}
+			return true
+		}
+
+	case *types.Pointer:
+		if y, ok := y.(*types.Pointer); ok {
+			return sameType(x.Elem(), y.Elem(), tparams)
+		}
+
+	case *types.Tuple:
+		if y, ok := y.(*types.Tuple); ok && x.Len() == y.Len() {
+			for i := 0; i < x.Len(); i++ {
+				if !sameType(x.At(i).Type(), y.At(i).Type(), tparams) {
+					return false
+				}
+			}
+			return true
+		}
+
+	case *types.Signature:
+		if y, ok := y.(*types.Signature); ok && x.Variadic() == y.Variadic() {
+			xt, yt := typeparams.ForSignature(x), typeparams.ForSignature(y)
+			if xt.Len() != yt.Len() {
+				return false
+			}
+			// Signatures are the same modulo the renaming of
+			// their type parameters.
+			for i := 0; i < xt.Len(); i++ {
+				tparams = &tparamPair{xt.At(i), yt.At(i), tparams}
+			}
+			for i := 0; i < xt.Len(); i++ {
+				if !sameType(xt.At(i).Constraint(), yt.At(i).Constraint(), tparams) {
+					return false
+				}
+			}
+			return sameType(x.Params(), y.Params(), tparams) &&
+				sameType(x.Results(), y.Results(), tparams)
+		}
+
+	case *typeparams.Union:
+		if y, ok := y.(*typeparams.Union); ok {
+			xterms, err1 := typeparams.UnionTermSet(x)
+			yterms, err2 := typeparams.UnionTermSet(y)
+			return err1 == nil && err2 == nil && sameTerms(xterms, yterms, tparams)
+		}
+
+	case *types.Interface:
+		if y, ok := y.(*types.Interface); ok && x.NumMethods() == y.NumMethods() {
+			// The methods of a complete interface are sorted
+			// by Id, which depends only on names and package paths.
+			for i := 0; i < x.NumMethods(); i++ {
+				m, n := x.Method(i), y.Method(i)
+				if !sameName(m, n) || !sameType(m.Type(), n.Type(), tparams) {
+					return false
+				}
+			}
+			xterms, err1 := typeparams.InterfaceTermSet(x)
+			yterms, err2 := typeparams.InterfaceTermSet(y)
+			return err1 == nil && err2 == nil && sameTerms(xterms, yterms, tparams)
+		}
+
+	case *types.Map:
+		if y, ok := y.(*types.Map); ok {
+			return sameType(x.Key(), y.Key(), tparams) && sameType(x.Elem(), y.Elem(), tparams)
+		}
+
+	case *types.Chan:
+		if y, ok := y.(*types.Chan); ok {
+			return x.Dir() == y.Dir() && sameType(x.Elem(), y.Elem(), tparams)
+		}
+
+	case *types.Named:
+		if y, ok := y.(*types.Named); ok && SameObject(x.Obj(), y.Obj()) {
+			xargs, yargs := typeparams.NamedTypeArgs(x), typeparams.NamedTypeArgs(y)
+			if xargs.Len() != yargs.Len() {
+				return false
+			}
+			for i := 0; i < xargs.Len(); i++ {
+				if !sameType(xargs.At(i), yargs.At(i), tparams) {
+					return false
+				}
+			}
+			return true
+		}
+
+	case *typeparams.TypeParam:
+		if y, ok := y.(*typeparams.TypeParam); ok {
+			for p := tparams; p != nil; p = p.prev {
+				if p.x == x || p.y == y {
+					return p.x == x && p.y == y
+				}
+			}
+			// The type parameters of generic types and methods.
+			return SameObject(x.Obj(), y.Obj())
+		}
+	}
+	return false
+}
+
+// sameName reports whether the fields or methods x and y have the same
+// name, which for unexported names includes the package path.
+func sameName(x, y types.Object) bool {
+	if x.Name() != y.Name() {
+		return false
+	}
+	if x.Exported() {
+		return true
+	}
+	return x.Pkg() != nil && y.Pkg() != nil && x.Pkg().Path() == y.Pkg().Path()
+}
+
+// sameTerms reports whether the normalized term lists x and y contain
+// the same terms, in any order.
+func sameTerms(x, y []*typeparams.Term, tparams *tparamPair) bool {
+	if len(x) != len(y) {
+		return false
+	}
+outer:
+	for _, s := range x {
+		for _, t := range y {
+			if s.Tilde() == t.Tilde() && sameType(s.Type(), t.Type(), tparams) {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package objectpath_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+// Two versions of a package.
+const (
+	keySrc1 = `package p
+
+type T struct {
+	X int
+	y string
+}
+
+func (T) M(x int) *T { return nil }
+
+type U T
+
+type I interface{ M(int) *T }
+
+func F(t T, s []U) {}
+
+var V map[string]*T
+`
+	keySrc2 = `package p
+
+type T struct {
+	X int
+	y string
+	Z bool // added
+}
+
+func (T) M(x int) *T { return nil }
+
+func (T) N() {} // added
+
+type U struct{}
+
+type I interface{ M(int) *T }
+
+func F(t T, s []U) {}
+
+var V map[string]*T
+`
+	keySrcGeneric = `package p
+
+type List[E any] struct{ next *List[E] }
+
+func Map[A, B any](l *List[A], f func(A) B) *List[B] { return nil }
+
+func Ident[T any](x T) T { return x }
+`
+)
+
+func checkKeyPkg(t *testing.T, src string) *types.Package {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("example.com/p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestSameObject(t *testing.T) {
+	p1, p2 := checkKeyPkg(t, keySrc1), checkKeyPkg(t, keySrc2)
+	lookup := func(pkg *types.Package, name string) types.Object { return pkg.Scope().Lookup(name) }
+	field := func(pkg *types.Package, i int) types.Object {
+		return lookup(pkg, "T").Type().Underlying().(*types.Struct).Field(i)
+	}
+	method := func(pkg *types.Package, name string) types.Object {
+		obj, _, _ := types.LookupFieldOrMethod(lookup(pkg, "T").Type(), false, pkg, name)
+		return obj
+	}
+
+	for _, name := range []string{"T", "U", "I", "F", "V"} {
+		if !objectpath.SameObject(lookup(p1, name), lookup(p2, name)) {
+			t.Errorf("SameObject(%s) = false", name)
+		}
+	}
+	if !objectpath.SameObject(field(p1, 0), field(p2, 0)) {
+		t.Errorf("SameObject(T.X) = false")
+	}
+	if objectpath.SameObject(field(p1, 0), field(p2, 1)) {
+		t.Errorf("SameObject(T.X, T.y) = true")
+	}
+	// The index of M among the methods of T is unchanged.
+	if !objectpath.SameObject(method(p1, "M"), method(p2, "M")) {
+		t.Errorf("SameObject(T.M) = false")
+	}
+	if objectpath.SameObject(lookup(p1, "T"), lookup(p2, "U")) {
+		t.Errorf("SameObject(T, U) = true")
+	}
+
+	// Keys resolve to their objects in either version.
+	k, err := objectpath.KeyFor(field(p1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := k.String(), "example.com/p.T.UF1"; got != want {
+		t.Errorf("KeyFor(T.y) = %s, want %s", got, want)
+	}
+	if obj, err := objectpath.Resolve(p2, k); err != nil || obj != field(p2, 1) {
+		t.Errorf("Resolve(%s) = %v, %v, want %v", k, obj, err, field(p2, 1))
+	}
+	if _, err := objectpath.Resolve(types.NewPackage("example.com/q", "q"), k); err == nil {
+		t.Errorf("Resolve(%s) in another package succeeded", k)
+	}
+}
+
+func TestSameType(t *testing.T) {
+	p1, p2 := checkKeyPkg(t, keySrc1), checkKeyPkg(t, keySrc2)
+	typeOf := func(pkg *types.Package, name string) types.Type { return pkg.Scope().Lookup(name).Type() }
+
+	for _, test := range []struct {
+		name string
+		same bool
+	}{
+		{"T", true}, // whose underlying types differ
+		{"U", true},
+		{"I", true},
+		{"F", true},
+		{"V", true},
+	} {
+		x, y := typeOf(p1, test.name), typeOf(p2, test.name)
+		if got := objectpath.SameType(x, y); got != test.same {
+			t.Errorf("SameType(%s, %s) = %t, want %t", x, y, got, test.same)
+		}
+	}
+	for _, test := range []struct {
+		x, y types.Type
+		same bool
+	}{
+		{typeOf(p1, "T").Underlying(), typeOf(p2, "T").Underlying(), false},
+		{typeOf(p1, "U").Underlying(), typeOf(p2, "U").Underlying(), false},
+		{typeOf(p1, "I").Underlying(), typeOf(p2, "I").Underlying(), true},
+		{typeOf(p1, "T"), typeOf(p2, "U"), false},
+		{types.NewSlice(typeOf(p1, "T")), types.NewSlice(typeOf(p2, "T")), true},
+		{types.NewSlice(typeOf(p1, "T")), types.NewPointer(typeOf(p2, "T")), false},
+		{types.Typ[types.Int], types.Typ[types.Int64], false},
+	} {
+		if got := objectpath.SameType(test.x, test.y); got != test.same {
+			t.Errorf("SameType(%s, %s) = %t, want %t", test.x, test.y, got, test.same)
+		}
+	}
+
+	if !typeparams.Enabled {
+		return
+	}
+	g1, g2 := checkKeyPkg(t, keySrcGeneric), checkKeyPkg(t, keySrcGeneric)
+	for _, name := range []string{"List", "Map", "Ident"} {
+		x, y := typeOf(g1, name), typeOf(g2, name)
+		if !objectpath.SameType(x, y) {
+			t.Errorf("SameType(%s, %s) = false", x, y)
+		}
+	}
+	if objectpath.SameType(typeOf(g1, "Ident"), typeOf(g2, "Map")) {
+		t.Errorf("SameType(Ident, Map) = true")
+	}
+	list1, list2 := typeOf(g1, "List"), typeOf(g2, "List")
+	inst := func(T types.Type, arg types.Type) types.Type {
+		inst, err := typeparams.Instantiate(nil, T, []types.Type{arg}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return inst
+	}
+	if !objectpath.SameType(inst(list1, types.Typ[types.Int]), inst(list2, types.Typ[types.Int])) {
+		t.Errorf("SameType(List[int], List[int]) = false")
+	}
+	if objectpath.SameType(inst(list1, types.Typ[types.Int]), inst(list2, types.Typ[types.String])) {
+		t.Errorf("SameType(List[int], List[string]) = true")
+	}
+}
@@ -289,7 +289,7 @@ func For(obj types.Object) (Path, error) {
 		}
 
 		// Inspect declared methods of defined types.
-		if T, ok := o.Type().(*types.Named); ok {
+		if T, ok := typeparams.Unalias(o.Type()).(*types.Named); ok {
 			path = append(path, opType)
 			// Note that method index here is always with respect
 			// to canonical ordering of methods, regardless of how
@@ -397,7 +397,9 @@ func concreteMethod(meth *types.Func) (Path, bool) {
 	path := make([]byte, 0, len(name)+8)
 	path = append(path, name...)
 	path = append(path, opType)
-	canonical := canonicalize(named)
+	// The receiver of an origin method may be an instance of its type
+	// (such as S[A] above), whose methods are not the origin methods.
+	canonical := canonicalize(typeparams.NamedTypeOrigin(named).(*types.Named))
 	for i, m := range canonical {
 		if m == meth {
 			path = appendOpArg(path, opMethod, i)
@@ -413,7 +415,7 @@ func concreteMethod(meth *types.Func) (Path, bool) {
 // The seen map is used to short circuit cycles through type parameters. If
 // nil, it will be allocated as necessary.
 func find(obj types.Object, T types.Type, path []byte, seen map[*types.TypeName]bool) []byte {
-	switch T := T.(type) {
+	switch T := typeparams.Unalias(T).(type) {
 	case *types.Basic, *types.Named:
 		// Named types belonging to pkg were handled already,
 		// so T must belong to another package. No path.
@@ -586,6 +588,8 @@ func Object(pkg *types.Package, p Path) (types.Object, error) {
 
 		// Inv: t != nil, obj == nil
 
+		t = typeparams.Unalias(t)
+
 		switch code {
 		case opElem:
 			hasElem, ok := t.(hasElem) // Pointer, Slice, Array, Chan, Map
@@ -67,3 +67,111 @@ func interfaceMethod(f *types.Func) bool {
 	recv := f.Type().(*types.Signature).Recv()
 	return recv != nil && types.IsInterface(recv.Type())
 }
+
+// A CalleeInfo describes the target of a call, as reported by CalleeOf.
+type CalleeInfo struct {
+	// Obj is the named target of the call, as reported by Callee: a
+	// function, method, builtin or variable, or nil.
+	Obj types.Object
+
+	// Builtin is the builtin function called, such as len or
+	// unsafe.Sizeof, if any.
+	Builtin *types.Builtin
+
+	// Origin is the function or method called, if any, or for a call
+	// of an instance of a generic function or of a method of an
+	// instance of a generic type, the generic function or method.
+	Origin *types.Func
+
+	// TypeArgs are the type arguments, explicit or inferred, of a
+	// call of an instance of a generic function, or those of the
+	// receiver type of a call of a method of a generic type.
+	TypeArgs []types.Type
+
+	// Selection is the selection of the field or method x.f of a
+	// call x.f(...), or of the method T.f of a call T.f(x, ...) of a
+	// method expression, if any. It is nil for calls of qualified
+	// identifiers.
+	Selection *types.Selection
+
+	// Recv is the expression of the receiver of a method call: x in
+	// x.f(...), or in T.f(x, ...) for a method expression, if any.
+	Recv ast.Expr
+
+	// Static reports whether the call has a static callee: whether
+	// it calls a function, or a method of a concrete type (not one
+	// of an interface or of a type parameter). StaticCallee returns
+	// Obj, if so.
+	Static bool
+}
+
+// CalleeOf returns a description of the target of a call, which
+// includes those of the calls of builtins, of instances of generic
+// functions and methods, and of method values and method expressions.
+// Unlike Callee, it requires info.Instances to describe calls of
+// generic functions fully.
+func CalleeOf(info *types.Info, call *ast.CallExpr) CalleeInfo {
+	var c CalleeInfo
+	c.Obj = Callee(info, call)
+	if c.Obj == nil {
+		return c
+	}
+
+	fun := astutil.Unparen(call.Fun)
+	switch fun.(type) {
+	case *ast.IndexExpr, *typeparams.IndexListExpr:
+		fun, _, _, _ = typeparams.UnpackIndexExpr(fun)
+	}
+
+	switch obj := c.Obj.(type) {
+	case *types.Builtin:
+		c.Builtin = obj
+
+	case *types.Func:
+		c.Origin = typeparams.OriginMethod(obj)
+		c.Static = !interfaceMethod(obj)
+
+		// The identifier of a generic function is instantiated.
+		var id *ast.Ident
+		switch fun := fun.(type) {
+		case *ast.Ident:
+			id = fun
+		case *ast.SelectorExpr:
+			id = fun.Sel
+		}
+		if inst, ok := typeparams.GetInstances(info)[id]; ok && inst.TypeArgs != nil {
+			for i := 0; i < inst.TypeArgs.Len(); i++ {
+				c.TypeArgs = append(c.TypeArgs, inst.TypeArgs.At(i))
+			}
+		}
+
+		// The receiver type of a method may be an instance.
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			T := recv.Type()
+			if ptr, ok := T.(*types.Pointer); ok {
+				T = ptr.Elem()
+			}
+			if named, ok := T.(*types.Named); ok {
+				targs := typeparams.NamedTypeArgs(named)
+				for i := 0; i < targs.Len(); i++ {
+					c.TypeArgs = append(c.TypeArgs, targs.At(i))
+				}
+			}
+		}
+	}
+
+	if sel, ok := fun.(*ast.SelectorExpr); ok {
+		if selection, ok := info.Selections[sel]; ok {
+			c.Selection = selection
+			switch selection.Kind() {
+			case types.MethodVal:
+				c.Recv = sel.X
+			case types.MethodExpr:
+				if len(call.Args) > 0 {
+					c.Recv = call.Args[0]
+				}
+			}
+		}
+	}
+	return c
+}
@@ -169,3 +169,102 @@ func testStaticCallee(t *testing.T, contents []string) {
 		}
 	}
 }
+
+func TestCalleeOf(t *testing.T) {
+	if !typeparams.Enabled {
+		t.Skip("type parameters are not enabled")
+	}
+	const src = `package p
+
+import "unsafe"
+
+type T int
+
+func (T) m(int)     {}
+func (*T) pm()      {}
+func f(int)         {}
+func g[E any](E) E  { var e E; return e }
+
+type L[E any] struct{ next *L[E] }
+
+func (L[E]) Len() int { return 0 }
+
+type I interface{ m(int) }
+
+var v func()
+
+func calls(t T, i I, l L[string]) {
+	f(1)                 // f static
+	println()            // println builtin
+	_ = unsafe.Sizeof(t) // Sizeof builtin
+	g(1)                 // g static [int]
+	g[string]("")        // g static [string]
+	t.m(1)               // m static recv=t
+	T.m(t, 2)            // m static recv=t expr
+	(*T).pm(&t)          // pm static recv=&t expr
+	(t.m)(3)             // m static recv=t
+	i.m(4)               // m dynamic recv=i
+	l.Len()              // Len static [string] recv=l
+	v()                  // v dynamic
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	typeparams.InitInstanceInfo(info)
+	conf := types.Config{Importer: closure{"unsafe": types.Unsafe}}
+	if _, err := conf.Check("p", fset, []*ast.File{f}, info); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, comment := range f.Comments {
+		want := strings.TrimSpace(comment.Text())
+		// Find the call on the line of the comment.
+		line := fset.Position(comment.Pos()).Line
+		var call *ast.CallExpr
+		ast.Inspect(f, func(n ast.Node) bool {
+			if c, ok := n.(*ast.CallExpr); ok && call == nil && fset.Position(c.Pos()).Line == line {
+				call = c
+			}
+			return true
+		})
+		if call == nil {
+			t.Fatalf("no call on line %d", line)
+		}
+
+		c := typeutil.CalleeOf(info, call)
+		var got []string
+		if c.Obj != nil {
+			got = append(got, c.Obj.Name())
+		}
+		switch {
+		case c.Builtin != nil:
+			got = append(got, "builtin")
+		case c.Static:
+			got = append(got, "static")
+		default:
+			got = append(got, "dynamic")
+		}
+		if c.TypeArgs != nil {
+			got = append(got, fmt.Sprint(c.TypeArgs))
+		}
+		if c.Recv != nil {
+			got = append(got, "recv="+types.ExprString(c.Recv))
+		}
+		if c.Selection != nil && c.Selection.Kind() == types.MethodExpr {
+			got = append(got, "expr")
+		}
+		if s := strings.Join(got, " "); s != want {
+			t.Errorf("line %d: CalleeOf = %q, want %q", line, s, want)
+		}
+		if c.Static && c.TypeArgs == nil && c.Origin != typeutil.StaticCallee(info, call) {
+			t.Errorf("line %d: Origin = %v, StaticCallee = %v", line, c.Origin, typeutil.StaticCallee(info, call))
+		}
+	}
+}
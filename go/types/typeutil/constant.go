@@ -0,0 +1,252 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package typeutil
+
+// This file defines the folding of expressions to constants, through
+// local variables.
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// A ConstFolder folds expressions to constant values, as the type
+// checker does for constant expressions, but also through the local
+// variables whose only assignment is their declaration, such as s in
+//
+//	s := "2006-01-02"
+//	t.Format(s)
+//
+// so that the values of arguments assigned one line earlier may be
+// checked like those of literals.
+//
+// A ConstFolder requires the Types, Defs, Uses and Selections maps of
+// the types.Info.
+type ConstFolder struct {
+	info     *types.Info
+	init     map[*types.Var]ast.Expr // the initializers of local variables
+	modified map[*types.Var]bool     // local variables assigned after their declarations
+}
+
+// NewConstFolder returns a ConstFolder for the expressions of the
+// files, which must include the declarations of the local variables
+// through which it folds.
+func NewConstFolder(info *types.Info, files []*ast.File) *ConstFolder {
+	f := &ConstFolder{
+		info:     info,
+		init:     make(map[*types.Var]ast.Expr),
+		modified: make(map[*types.Var]bool),
+	}
+	// local returns the local variable that the identifier e
+	// denotes, if any, or nil.
+	local := func(e ast.Expr) *types.Var {
+		id, ok := astutil.Unparen(e).(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		obj := info.ObjectOf(id)
+		v, ok := obj.(*types.Var)
+		if !ok || v.IsField() || v.Pkg() == nil || v.Parent() == v.Pkg().Scope() {
+			return nil
+		}
+		return v
+	}
+	modify := func(e ast.Expr) {
+		if v := local(e); v != nil {
+			f.modified[v] = true
+		}
+	}
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ValueSpec:
+				if len(n.Names) == len(n.Values) {
+					for i, id := range n.Names {
+						if v := local(id); v != nil {
+							f.init[v] = n.Values[i]
+						}
+					}
+				}
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok && n.Tok == token.DEFINE && info.Defs[id] != nil {
+						if v := local(id); v != nil && len(n.Lhs) == len(n.Rhs) {
+							f.init[v] = n.Rhs[i]
+						}
+						continue // a new variable
+					}
+					modify(lhs)
+				}
+			case *ast.IncDecStmt:
+				modify(n.X)
+			case *ast.RangeStmt:
+				if n.Tok == token.ASSIGN {
+					modify(n.Key)
+					if n.Value != nil {
+						modify(n.Value)
+					}
+				}
+			case *ast.UnaryExpr:
+				if n.Op == token.AND {
+					modify(n.X) // may be assigned through the pointer
+				}
+			case *ast.SelectorExpr:
+				// A call of a method with a pointer receiver
+				// takes the address of its receiver implicitly.
+				if sel, ok := info.Selections[n]; ok && sel.Kind() == types.MethodVal {
+					if _, ok := sel.Obj().Type().(*types.Signature).Recv().Type().(*types.Pointer); ok && !isPointer(sel.Recv()) {
+						modify(n.X)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return f
+}
+
+func isPointer(T types.Type) bool {
+	_, ok := T.Underlying().(*types.Pointer)
+	return ok
+}
+
+// Value returns the constant value of the expression e, or nil if it
+// has none that the ConstFolder can find.
+func (f *ConstFolder) Value(e ast.Expr) constant.Value {
+	return f.value(e, make(map[*types.Var]bool))
+}
+
+func (f *ConstFolder) value(e ast.Expr, visiting map[*types.Var]bool) constant.Value {
+	e = astutil.Unparen(e)
+	if tv, ok := f.info.Types[e]; ok && tv.Value != nil {
+		return tv.Value
+	}
+	switch e := e.(type) {
+	case *ast.Ident:
+		v, ok := f.info.Uses[e].(*types.Var)
+		if !ok || f.modified[v] || visiting[v] {
+			return nil
+		}
+		init, ok := f.init[v]
+		if !ok {
+			return nil
+		}
+		visiting[v] = true
+		defer delete(visiting, v)
+		return f.value(init, visiting)
+
+	case *ast.UnaryExpr:
+		x := f.value(e.X, visiting)
+		if x == nil {
+			return nil
+		}
+		switch e.Op {
+		case token.ADD, token.SUB, token.XOR:
+			if isNumeric(x) {
+				return constant.UnaryOp(e.Op, x, 0)
+			}
+		case token.NOT:
+			if x.Kind() == constant.Bool {
+				return constant.UnaryOp(e.Op, x, 0)
+			}
+		}
+
+	case *ast.BinaryExpr:
+		x, y := f.value(e.X, visiting), f.value(e.Y, visiting)
+		if x == nil || y == nil {
+			return nil
+		}
+		switch e.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			if sameKinds(x, y) {
+				return constant.MakeBool(constant.Compare(x, e.Op, y))
+			}
+		case token.SHL, token.SHR:
+			if x.Kind() == constant.Int && y.Kind() == constant.Int {
+				if s, ok := constant.Uint64Val(y); ok && s < 1024 {
+					return constant.Shift(x, e.Op, uint(s))
+				}
+			}
+		case token.LAND, token.LOR:
+			if x.Kind() == constant.Bool && y.Kind() == constant.Bool {
+				return constant.BinaryOp(x, e.Op, y)
+			}
+		case token.ADD:
+			if sameKinds(x, y) && x.Kind() != constant.Bool {
+				return constant.BinaryOp(x, e.Op, y)
+			}
+		case token.QUO:
+			if !isNumeric(x) || !isNumeric(y) || constant.Sign(y) == 0 {
+				return nil
+			}
+			// Integer operands divide to an integer.
+			if T, ok := f.info.TypeOf(e).Underlying().(*types.Basic); ok && T.Info()&types.IsInteger != 0 {
+				return constant.BinaryOp(x, token.QUO_ASSIGN, y)
+			}
+			return constant.BinaryOp(x, e.Op, y)
+		case token.SUB, token.MUL:
+			if isNumeric(x) && isNumeric(y) {
+				return constant.BinaryOp(x, e.Op, y)
+			}
+		case token.REM, token.AND, token.OR, token.XOR, token.AND_NOT:
+			if x.Kind() == constant.Int && y.Kind() == constant.Int && (e.Op != token.REM || constant.Sign(y) != 0) {
+				return constant.BinaryOp(x, e.Op, y)
+			}
+		}
+
+	case *ast.CallExpr:
+		// A conversion T(x), or len(x) of a string.
+		if len(e.Args) != 1 {
+			return nil
+		}
+		x := f.value(e.Args[0], visiting)
+		if x == nil {
+			return nil
+		}
+		if tv, ok := f.info.Types[e.Fun]; ok && tv.IsType() {
+			T, ok := tv.Type.Underlying().(*types.Basic)
+			switch {
+			case !ok:
+			case T.Info()&types.IsString != 0 && x.Kind() == constant.String:
+				return x
+			case T.Info()&types.IsInteger != 0 && isNumeric(x):
+				if i := constant.ToInt(x); i.Kind() == constant.Int {
+					return i
+				}
+			case T.Info()&types.IsFloat != 0 && isNumeric(x):
+				if r := constant.ToFloat(x); r.Kind() == constant.Float {
+					return r
+				}
+			case T.Info()&types.IsBoolean != 0 && x.Kind() == constant.Bool:
+				return x
+			}
+			return nil
+		}
+		if id, ok := astutil.Unparen(e.Fun).(*ast.Ident); ok && x.Kind() == constant.String {
+			if b, ok := f.info.Uses[id].(*types.Builtin); ok && b.Name() == "len" {
+				return constant.MakeInt64(int64(len(constant.StringVal(x))))
+			}
+		}
+	}
+	return nil
+}
+
+func isNumeric(x constant.Value) bool {
+	switch x.Kind() {
+	case constant.Int, constant.Float, constant.Complex:
+		return true
+	}
+	return false
+}
+
+// sameKinds reports whether x and y may be operands of one operation:
+// whether both are numeric, or have the same kind.
+func sameKinds(x, y constant.Value) bool {
+	return isNumeric(x) && isNumeric(y) || x.Kind() == y.Kind()
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package typeutil_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+func TestConstFolder(t *testing.T) {
+	const src = `package p
+
+type Layout string
+
+func (l *Layout) Set(s string) { *l = Layout(s) }
+
+var global = "global"
+
+func use(...interface{}) {}
+
+func f(param string) {
+	const c = "const"
+	s := "2006-01-02"
+	var t = s + " 15:04"
+	var u Layout = Layout(t) + "!"
+	n, m := 6, 7
+	modified := "a"
+	modified = "b"
+	inc := 1
+	inc++
+	addr := 2
+	p := &addr
+	var l Layout = "layout"
+	l.Set("x")
+	ratio := n / 4
+	fratio := 7.0 / 2
+	ok := n < m && len(s) == 10
+	shift := 1 << n
+	redeclared := 1
+	redeclared, other := 2, 3
+	use(
+		c,          // "const"
+		s,          // "2006-01-02"
+		t,          // "2006-01-02 15:04"
+		u,          // "2006-01-02 15:04!"
+		n*m,        // 42
+		-n,         // -6
+		modified,   // nil
+		inc,        // nil
+		addr,       // nil
+		p,          // nil
+		l,          // nil
+		ratio,      // 1
+		fratio,     // 7/2
+		ok,         // true
+		shift,      // 64
+		redeclared, // nil
+		other,      // 3
+		global,     // nil
+		param,      // nil
+		s+param,    // nil
+		int64(n),   // 6
+		n/(m-7),    // nil
+	)
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	if _, err := new(types.Config).Check("p", fset, []*ast.File{f}, info); err != nil {
+		t.Fatal(err)
+	}
+	folder := typeutil.NewConstFolder(info, []*ast.File{f})
+
+	// Check each argument of the call of use against its comment.
+	comments := make(map[int]string)
+	for _, c := range f.Comments {
+		comments[fset.Position(c.Pos()).Line] = strings.TrimSpace(c.Text())
+	}
+	var call *ast.CallExpr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if c, ok := n.(*ast.CallExpr); ok {
+			if id, ok := c.Fun.(*ast.Ident); ok && id.Name == "use" {
+				call = c
+			}
+		}
+		return true
+	})
+	for _, arg := range call.Args {
+		want := comments[fset.Position(arg.Pos()).Line]
+		got := "nil"
+		if v := folder.Value(arg); v != nil {
+			got = v.ExactString()
+		}
+		if got != want {
+			t.Errorf("Value(%s) = %s, want %s", types.ExprString(arg), got, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package typeutil
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/internal/typeparams"
+)
+
+// An ImplementsIndex records which named types implement which
+// interfaces, among the package-level types of a set of packages, so
+// that the implementations of an interface, and the interfaces of a
+// type, need not be found by testing every type against every
+// interface.
+//
+// The concrete types of the index are the package-level defined types
+// that are neither interfaces nor generic; its interfaces are the
+// package-level defined interfaces that have methods, are not generic
+// and are not constraints. (The empty interface, which every type
+// implements, is not indexed.)
+//
+// An ImplementsIndex does not change once built, and is safe for
+// concurrent use.
+type ImplementsIndex struct {
+	concrete []*types.Named
+	indexed  map[*types.Named]bool // the elements of concrete
+	ifaces   []*types.Named
+	byMethod map[string][]int // concrete types whose pointer types have a method of this name, by index
+
+	impls      Map // maps an interface type (the underlying type of an iface) to []types.Type
+	interfaces Map // maps a concrete type T, or *T, to []*types.Named
+}
+
+// NewImplementsIndex returns the index of the package-level types of
+// the packages. To index the types visible to a package, pass its
+// Dependencies.
+func NewImplementsIndex(pkgs []*types.Package) *ImplementsIndex {
+	x := &ImplementsIndex{
+		indexed:  make(map[*types.Named]bool),
+		byMethod: make(map[string][]int),
+	}
+	h := MakeHasher()
+	x.impls.SetHasher(h)
+	x.interfaces.SetHasher(h)
+
+	for _, pkg := range pkgs {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tname, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tname.IsAlias() {
+				continue
+			}
+			named, ok := tname.Type().(*types.Named)
+			if !ok || typeparams.ForNamed(named).Len() > 0 {
+				continue
+			}
+			if iface, ok := named.Underlying().(*types.Interface); ok {
+				if iface.NumMethods() > 0 && typeparams.IsMethodSet(iface) {
+					x.ifaces = append(x.ifaces, named)
+				}
+				continue
+			}
+			mset := types.NewMethodSet(types.NewPointer(named))
+			for i := 0; i < mset.Len(); i++ {
+				name := mset.At(i).Obj().Name()
+				x.byMethod[name] = append(x.byMethod[name], len(x.concrete))
+			}
+			x.concrete = append(x.concrete, named)
+			x.indexed[named] = true
+		}
+	}
+
+	for _, I := range x.ifaces {
+		impls := x.implementations(I.Underlying().(*types.Interface))
+		x.impls.Set(I.Underlying(), impls)
+		for _, T := range impls {
+			add := func(T types.Type) {
+				ifaces, _ := x.interfaces.At(T).([]*types.Named)
+				x.interfaces.Set(T, append(ifaces, I))
+			}
+			add(T)
+			if _, ok := T.(*types.Pointer); !ok {
+				add(types.NewPointer(T)) // *T has the methods of T
+			}
+		}
+	}
+	return x
+}
+
+// Implementations returns the concrete types of the index whose values
+// implement the interface I, a defined or literal interface type: for
+// each type T that implements I, T; for each other type T such that
+// *T implements I, *T. The types are in the order of their packages
+// and names.
+func (x *ImplementsIndex) Implementations(I types.Type) []types.Type {
+	iface, ok := I.Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	if impls, ok := x.impls.At(iface).([]types.Type); ok {
+		return impls
+	}
+	if iface.NumMethods() == 0 {
+		return nil
+	}
+	return x.implementations(iface)
+}
+
+// implementations computes the result of Implementations for a
+// non-empty interface.
+func (x *ImplementsIndex) implementations(iface *types.Interface) []types.Type {
+	// Test only the types that have the least common method of I.
+	var candidates []int
+	for i := 0; i < iface.NumMethods(); i++ {
+		c := x.byMethod[iface.Method(i).Name()]
+		if i == 0 || len(c) < len(candidates) {
+			candidates = c
+		}
+	}
+	var impls []types.Type
+	for _, i := range candidates {
+		T := x.concrete[i]
+		if types.Implements(T, iface) {
+			impls = append(impls, T)
+		} else if ptr := types.NewPointer(T); types.Implements(ptr, iface) {
+			impls = append(impls, ptr)
+		}
+	}
+	return impls
+}
+
+// Interfaces returns the interfaces of the index that the type T
+// implements, in the order of their packages and names. T is
+// typically a concrete type of the index, or a pointer to one, but
+// may be any type.
+func (x *ImplementsIndex) Interfaces(T types.Type) []*types.Named {
+	if ifaces, ok := x.interfaces.At(T).([]*types.Named); ok {
+		return ifaces
+	}
+	if x.isIndexed(T) {
+		return nil // implements none
+	}
+
+	// T is not a concrete type of the index.
+	var ifaces []*types.Named
+	mset := types.NewMethodSet(T)
+	has := make(map[string]bool)
+	for i := 0; i < mset.Len(); i++ {
+		has[mset.At(i).Obj().Name()] = true
+	}
+	for _, I := range x.ifaces {
+		iface := I.Underlying().(*types.Interface)
+		if has[iface.Method(0).Name()] && types.Implements(T, iface) {
+			ifaces = append(ifaces, I)
+		}
+	}
+	return ifaces
+}
+
+// isIndexed reports whether T is a concrete type of the index, or a
+// pointer to one.
+func (x *ImplementsIndex) isIndexed(T types.Type) bool {
+	if ptr, ok := T.(*types.Pointer); ok {
+		T = ptr.Elem()
+	}
+	named, ok := T.(*types.Named)
+	return ok && x.indexed[named]
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package typeutil_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+func TestImplementsIndex(t *testing.T) {
+	const src = `package p
+
+type Reader interface{ Read([]byte) (int, error) }
+type Closer interface{ Close() error }
+type ReadCloser interface {
+	Reader
+	Closer
+}
+type Empty interface{}
+
+type File struct{}
+
+func (*File) Read([]byte) (int, error) { return 0, nil }
+func (*File) Close() error             { return nil }
+
+type Bytes []byte
+
+func (Bytes) Read([]byte) (int, error) { return 0, nil }
+
+type Nop int
+
+func (Nop) Close() error { return nil }
+
+type Other struct{}
+
+func (Other) Read() {} // wrong signature
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(name string) types.Type { return pkg.Scope().Lookup(name).Type() }
+
+	x := typeutil.NewImplementsIndex([]*types.Package{pkg})
+
+	for _, test := range []struct {
+		I    types.Type
+		want string
+	}{
+		{lookup("Reader"), "[p.Bytes *p.File]"},
+		{lookup("Closer"), "[*p.File p.Nop]"},
+		{lookup("ReadCloser"), "[*p.File]"},
+		{lookup("Empty"), "[]"},
+		{lookup("Reader").Underlying(), "[p.Bytes *p.File]"},
+		{lookup("File"), "[]"}, // not an interface
+	} {
+		got := fmt.Sprint(x.Implementations(test.I))
+		if got != test.want {
+			t.Errorf("Implementations(%s) = %s, want %s", test.I, got, test.want)
+		}
+	}
+
+	// An interface that is not in the index.
+	method := types.NewFunc(token.NoPos, pkg, "Close", types.NewSignature(nil, nil, types.NewTuple(types.NewVar(token.NoPos, nil, "", types.Universe.Lookup("error").Type())), false))
+	closer := types.NewInterfaceType([]*types.Func{method}, nil).Complete()
+	if got, want := fmt.Sprint(x.Implementations(closer)), "[*p.File p.Nop]"; got != want {
+		t.Errorf("Implementations(%s) = %s, want %s", closer, got, want)
+	}
+
+	for _, test := range []struct {
+		T    types.Type
+		want string
+	}{
+		{lookup("File"), "[]"},
+		{types.NewPointer(lookup("File")), "[p.Closer p.ReadCloser p.Reader]"},
+		{lookup("Bytes"), "[p.Reader]"},
+		{types.NewPointer(lookup("Bytes")), "[p.Reader]"},
+		{lookup("Other"), "[]"},
+		{lookup("ReadCloser"), "[p.Closer p.ReadCloser p.Reader]"}, // not in the index
+		{types.NewSlice(lookup("Nop")), "[]"},
+	} {
+		got := fmt.Sprint(x.Interfaces(test.T))
+		if got != test.want {
+			t.Errorf("Interfaces(%s) = %s, want %s", test.T, got, test.want)
+		}
+	}
+}
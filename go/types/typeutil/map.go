@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"go/types"
 	"reflect"
+	"sync"
 
 	"golang.org/x/tools/internal/typeparams"
 )
@@ -23,7 +24,12 @@ import (
 //
 // Just as with map[K]V, a nil *Map is a valid empty map.
 //
-// Not thread-safe.
+// Like a Go map, a Map may be read by many goroutines at once (by At,
+// Len, Iterate, Keys, String and KeysString), but must not be read
+// while another goroutine modifies it (by Set or Delete). So a Map that
+// is fully populated before it is shared, such as one computed by one
+// action of an analysis and used by others in parallel, needs no
+// further synchronization.
 type Map struct {
 	hasher Hasher             // shared by many Maps
 	table  map[uint32][]entry // maps hash to bucket; entry.key==nil means unused
@@ -49,10 +55,8 @@ type entry struct {
 // type is deleted from the map, the Hasher never shrinks, since other
 // types in the map may reference the deleted type indirectly.
 //
-// Hashers are not thread-safe, and read-only operations such as
-// Map.Lookup require updates to the hasher, so a full Mutex lock (not a
-// read-lock) is require around all Map operations if a shared
-// hasher is accessed from multiple threads.
+// A Hasher is safe for use by multiple goroutines, so Maps that share
+// one may be used by different goroutines at once.
 //
 // If SetHasher is not called, the Map will create a private hasher at
 // the first call to Insert.
@@ -201,10 +205,11 @@ func (m *Map) KeysString() string {
 // A Hasher maps each type to its hash value.
 // For efficiency, a hasher uses memoization; thus its memory
 // footprint grows monotonically over time.
-// Hashers are not thread-safe.
+// Hashers are safe for concurrent use.
 // Hashers have reference semantics.
 // Call MakeHasher to create a Hasher.
 type Hasher struct {
+	mu   *sync.Mutex // guards memo and ptrMap
 	memo map[types.Type]uint32
 
 	// ptrMap records pointer identity.
@@ -225,6 +230,7 @@ type Hasher struct {
 // MakeHasher returns a new Hasher instance.
 func MakeHasher() Hasher {
 	return Hasher{
+		mu:         new(sync.Mutex),
 		memo:       make(map[types.Type]uint32),
 		ptrMap:     make(map[interface{}]uint32),
 		sigTParams: nil,
@@ -234,6 +240,13 @@ func MakeHasher() Hasher {
 // Hash computes a hash value for the given type t such that
 // Identical(t, t') => Hash(t) == Hash(t').
 func (h Hasher) Hash(t types.Type) uint32 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hash(t)
+}
+
+// hash is like Hash, but requires h.mu to be held.
+func (h Hasher) hash(t types.Type) uint32 {
 	hash, ok := h.memo[t]
 	if !ok {
 		hash = h.hashFor(t)
@@ -255,15 +268,16 @@ func hashString(s string) uint32 {
 // hashFor computes the hash of t.
 func (h Hasher) hashFor(t types.Type) uint32 {
 	// See Identical for rationale.
-	switch t := t.(type) {
+	// Aliases (such as any) are identical to the types they denote.
+	switch t := typeparams.Unalias(t).(type) {
 	case *types.Basic:
 		return uint32(t.Kind())
 
 	case *types.Array:
-		return 9043 + 2*uint32(t.Len()) + 3*h.Hash(t.Elem())
+		return 9043 + 2*uint32(t.Len()) + 3*h.hash(t.Elem())
 
 	case *types.Slice:
-		return 9049 + 2*h.Hash(t.Elem())
+		return 9049 + 2*h.hash(t.Elem())
 
 	case *types.Struct:
 		var hash uint32 = 9059
@@ -274,12 +288,12 @@ func (h Hasher) hashFor(t types.Type) uint32 {
 			}
 			hash += hashString(t.Tag(i))
 			hash += hashString(f.Name()) // (ignore f.Pkg)
-			hash += h.Hash(f.Type())
+			hash += h.hash(f.Type())
 		}
 		return hash
 
 	case *types.Pointer:
-		return 9067 + 2*h.Hash(t.Elem())
+		return 9067 + 2*h.hash(t.Elem())
 
 	case *types.Signature:
 		var hash uint32 = 9091
@@ -300,6 +314,7 @@ func (h Hasher) hashFor(t types.Type) uint32 {
 		tparams := typeparams.ForSignature(t)
 		if h.sigTParams == nil && tparams.Len() != 0 {
 			h = Hasher{
+				mu: h.mu,
 				// There may be something more efficient than discarding the existing
 				// memo, but it would require detecting whether types are 'tainted' by
 				// references to type parameters.
@@ -313,7 +328,7 @@ func (h Hasher) hashFor(t types.Type) uint32 {
 
 		for i := 0; i < tparams.Len(); i++ {
 			tparam := tparams.At(i)
-			hash += 7 * h.Hash(tparam.Constraint())
+			hash += 7 * h.hash(tparam.Constraint())
 		}
 
 		return hash + 3*h.hashTuple(t.Params()) + 5*h.hashTuple(t.Results())
@@ -332,7 +347,7 @@ func (h Hasher) hashFor(t types.Type) uint32 {
 			// Method order is not significant.
 			// Ignore m.Pkg().
 			m := t.Method(i)
-			hash += 3*hashString(m.Name()) + 5*h.Hash(m.Type())
+			hash += 3*hashString(m.Name()) + 5*h.hash(m.Type())
 		}
 
 		// Hash type restrictions.
@@ -345,17 +360,21 @@ func (h Hasher) hashFor(t types.Type) uint32 {
 		return hash
 
 	case *types.Map:
-		return 9109 + 2*h.Hash(t.Key()) + 3*h.Hash(t.Elem())
+		return 9109 + 2*h.hash(t.Key()) + 3*h.hash(t.Elem())
 
 	case *types.Chan:
-		return 9127 + 2*uint32(t.Dir()) + 3*h.Hash(t.Elem())
+		return 9127 + 2*uint32(t.Dir()) + 3*h.hash(t.Elem())
 
 	case *types.Named:
+		// Instances of a generic type are identical if their type
+		// arguments are, in order: the hash of each argument is
+		// weighted by its position, so that, say, Pair[int, string]
+		// and Pair[string, int] usually differ.
 		hash := h.hashPtr(t.Obj())
 		targs := typeparams.NamedTypeArgs(t)
 		for i := 0; i < targs.Len(); i++ {
 			targ := targs.At(i)
-			hash += 2 * h.Hash(targ)
+			hash += (2*uint32(i) + 3) * h.hash(targ)
 		}
 		return hash
 
@@ -374,7 +393,7 @@ func (h Hasher) hashTuple(tuple *types.Tuple) uint32 {
 	n := tuple.Len()
 	hash := 9137 + 2*uint32(n)
 	for i := 0; i < n; i++ {
-		hash += 3 * h.Hash(tuple.At(i).Type())
+		hash += 3 * h.hash(tuple.At(i).Type())
 	}
 	return hash
 }
@@ -394,7 +413,7 @@ func (h Hasher) hashTermSet(terms []*typeparams.Term) uint32 {
 	hash := 9157 + 2*uint32(len(terms))
 	for _, term := range terms {
 		// term order is not significant.
-		termHash := h.Hash(term.Type())
+		termHash := h.hash(term.Type())
 		if term.Tilde() {
 			termHash *= 9161
 		}
@@ -14,6 +14,7 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
+	"sync"
 	"testing"
 
 	"golang.org/x/tools/go/types/typeutil"
@@ -382,6 +383,116 @@ func Quux[Q interface{ quux() }]() {}
 	}
 }
 
+// TestHashTypeArgs checks that the hashes of instances of a generic
+// type depend on the order of their type arguments.
+func TestHashTypeArgs(t *testing.T) {
+	if !typeparams.Enabled {
+		t.Skip("type params are not enabled at this Go version")
+	}
+
+	const src = `
+package p
+
+type Pair[K, V any] struct {
+	k K
+	v V
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf types.Config
+	pkg, err := conf.Check("", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Pair := pkg.Scope().Lookup("Pair").Type()
+	tInt, tStr := types.Typ[types.Int], types.Typ[types.String]
+	var (
+		intStr1 = instantiate(t, Pair, tInt, tStr)
+		intStr2 = instantiate(t, Pair, tInt, tStr)
+		strInt  = instantiate(t, Pair, tStr, tInt)
+	)
+
+	h := typeutil.MakeHasher()
+	if h.Hash(intStr1) != h.Hash(intStr2) {
+		t.Errorf("Hash(%s) differs for identical instances", intStr1)
+	}
+	if h.Hash(intStr1) == h.Hash(strInt) {
+		t.Errorf("Hash(%s) == Hash(%s)", intStr1, strInt)
+	}
+
+	var m typeutil.Map
+	m.Set(intStr1, 1)
+	m.Set(strInt, 2)
+	if got := m.At(intStr2); got != 1 {
+		t.Errorf("At(%s) = %v, want 1", intStr2, got)
+	}
+	if m.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", m.Len())
+	}
+}
+
+// TestMapConcurrent checks that a populated Map may be read, and that
+// a Hasher may be shared, by concurrent goroutines. It is most useful
+// with the race detector.
+func TestMapConcurrent(t *testing.T) {
+	var keys []types.Type
+	for _, elem := range []types.Type{tStr, tInt, types.Typ[types.Bool]} {
+		keys = append(keys,
+			types.NewPointer(elem),
+			types.NewSlice(elem),
+			types.NewChan(types.SendRecv, elem),
+			types.NewMap(tStr, elem))
+	}
+
+	h := typeutil.MakeHasher()
+	var shared typeutil.Map
+	shared.SetHasher(h)
+	for i, key := range keys {
+		shared.Set(key, i)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Read the shared map, with equivalent keys.
+			for i, key := range keys {
+				var copy types.Type
+				switch key := key.(type) {
+				case *types.Pointer:
+					copy = types.NewPointer(key.Elem())
+				case *types.Slice:
+					copy = types.NewSlice(key.Elem())
+				case *types.Chan:
+					copy = types.NewChan(key.Dir(), key.Elem())
+				case *types.Map:
+					copy = types.NewMap(key.Key(), key.Elem())
+				}
+				if got := shared.At(copy); got != i {
+					t.Errorf("At(%s) = %v, want %d", copy, got, i)
+				}
+			}
+
+			// Populate a private map with the shared hasher.
+			var m typeutil.Map
+			m.SetHasher(h)
+			for i, key := range keys {
+				m.Set(types.NewSlice(key), i)
+			}
+			if m.Len() != len(keys) {
+				t.Errorf("Len() = %d, want %d", m.Len(), len(keys))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func instantiate(t *testing.T, origin types.Type, targs ...types.Type) types.Type {
 	inst, err := typeparams.Instantiate(nil, origin, targs, true)
 	if err != nil {
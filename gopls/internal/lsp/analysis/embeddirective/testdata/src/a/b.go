@@ -1,14 +0,0 @@
-package a
-
-import (
-	_ "embed"
-	"fmt"
-)
-
-//go:embed embedText // ok
-var s string
-
-// This is main function
-func main() {
-	fmt.Println(s)
-}
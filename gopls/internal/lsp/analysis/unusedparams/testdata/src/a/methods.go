@@ -0,0 +1,94 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type server struct{ name string }
+
+// ServeHTTP implements http.Handler, which needs the request.
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	fmt.Fprintln(w, s.name)
+}
+
+func (s *server) greet(w http.ResponseWriter, greeting string) { // want "potentially unused parameter: 'greeting'"
+	fmt.Fprintln(w, s.name)
+}
+
+func (s server) n(f bool) {
+	fmt.Println(s.name)
+}
+
+var _ parent = server{}
+
+// Counter is exported, so Write may implement io.Writer for another
+// package, though this one does not import io.
+type Counter struct {
+	n int
+	*counter
+}
+
+func (c *Counter) Write(p []byte) (int, error) {
+	c.n++
+	return c.n, nil
+}
+
+func (c *Counter) reset(n int) { // want "potentially unused parameter: 'n'"
+	c.n = 0
+}
+
+type counter struct{ total int }
+
+// Add is promoted to Counter.
+func (c *counter) Add(delta int) {
+	c.total++
+}
+
+// gauge is not embedded in an exported type.
+type gauge struct{ value int }
+
+func (g *gauge) Sub(delta int) { // want "potentially unused parameter: 'delta'"
+	g.value--
+}
+
+func count(s []int) (n int) { // want "named result 'n' is never assigned"
+	for range s {
+		fmt.Println()
+	}
+	return
+}
+
+func sum(s []int) (total int) {
+	for _, v := range s {
+		total += v
+	}
+	return
+}
+
+func explicit(s []int) (n int, err error) {
+	fmt.Println(s)
+	return len(s), nil
+}
+
+func recovered(f func()) (err error) {
+	defer func() {
+		if recover() != nil {
+			err = errors.New("panic")
+		}
+	}()
+	f()
+	return
+}
+
+func literal() {
+	_ = func() (ok bool) { // want "named result 'ok' is never assigned"
+		fmt.Println()
+		return
+	}
+}
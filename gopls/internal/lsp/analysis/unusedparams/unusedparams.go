@@ -3,27 +3,36 @@
 // license that can be found in the LICENSE file.
 
 // Package unusedparams defines an analyzer that checks for unused
-// parameters of functions.
+// parameters and named results of functions.
 package unusedparams
 
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
 	"go/types"
 	"strings"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/implements"
 	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/internal/typeparams"
 )
 
 const Doc = `check for unused parameters of functions
 
 The unusedparams analyzer checks functions to see if there are
-any parameters that are not being used.
+any parameters that are not being used, and suggests renaming them
+to "_". It also reports named results that are never assigned by a
+function that has a return statement without values.
 
 To reduce false positives it ignores:
-- methods
+- exported methods of exported types, which may implement interfaces of
+  other packages
+- methods that may implement an interface of the package or its dependencies
 - parameters that do not have a name or are underscored
 - functions in test files
 - functions with empty bodies or those with just a return stmt`
@@ -31,7 +40,7 @@ To reduce false positives it ignores:
 var Analyzer = &analysis.Analyzer{
 	Name:     "unusedparams",
 	Doc:      Doc,
-	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Requires: []*analysis.Analyzer{inspect.Analyzer, implements.Analyzer},
 	Run:      run,
 }
 
@@ -43,34 +52,48 @@ type paramData struct {
 
 func run(pass *analysis.Pass) (interface{}, error) {
 	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	index := pass.ResultOf[implements.Analyzer].(*typeutil.ImplementsIndex)
 	nodeFilter := []ast.Node{
 		(*ast.FuncDecl)(nil),
 		(*ast.FuncLit)(nil),
 	}
 
+	var local []*types.Interface      // computed lazily by mayImplement
+	var exported map[*types.Func]bool // computed lazily by exportedMethods
 	inspect.Preorder(nodeFilter, func(n ast.Node) {
-		var fieldList *ast.FieldList
+		var fieldList, results *ast.FieldList
 		var body *ast.BlockStmt
 
 		// Get the fieldList and body from the function node.
 		switch f := n.(type) {
 		case *ast.FuncDecl:
-			fieldList, body = f.Type.Params, f.Body
-			// TODO(golang/go#36602): add better handling for methods, if we enable methods
-			// we will get false positives if a struct is potentially implementing
-			// an interface.
-			if f.Recv != nil {
-				return
+			fieldList, results, body = f.Type.Params, f.Type.Results, f.Body
+			// A method may need its parameters to implement an interface.
+			if fn, ok := pass.TypesInfo.Defs[f.Name].(*types.Func); ok && f.Recv != nil {
+				if fn.Exported() {
+					if exported == nil {
+						exported = exportedMethods(pass.Pkg)
+					}
+					if exported[fn] {
+						return
+					}
+				}
+				if local == nil {
+					local = localInterfaces(pass)
+				}
+				if mayImplement(fn, index, local) {
+					return
+				}
 			}
 			// Ignore functions in _test.go files to reduce false positives.
 			if file := pass.Fset.File(n.Pos()); file != nil && strings.HasSuffix(file.Name(), "_test.go") {
 				return
 			}
 		case *ast.FuncLit:
-			fieldList, body = f.Type.Params, f.Body
+			fieldList, results, body = f.Type.Params, f.Type.Results, f.Body
 		}
 		// If there are no arguments or the function is empty, then return.
-		if fieldList.NumFields() == 0 || body == nil || len(body.List) == 0 {
+		if fieldList.NumFields()+results.NumFields() == 0 || body == nil || len(body.List) == 0 {
 			return
 		}
 
@@ -147,6 +170,163 @@ func run(pass *analysis.Pass) (interface{}, error) {
 				}},
 			})
 		}
+
+		checkResults(pass, results, body)
 	})
 	return nil, nil
 }
+
+// checkResults reports the named results of a function with the body
+// that are never assigned, if it has a return statement without
+// values, which returns their zero values.
+func checkResults(pass *analysis.Pass, results *ast.FieldList, body *ast.BlockStmt) {
+	if results == nil {
+		return
+	}
+	unassigned := make(map[types.Object]*ast.Ident)
+	for _, f := range results.List {
+		for _, id := range f.Names {
+			if obj := pass.TypesInfo.Defs[id]; obj != nil && id.Name != "_" {
+				unassigned[obj] = id
+			}
+		}
+	}
+	if len(unassigned) == 0 {
+		return
+	}
+
+	// Is there a return statement without values, not counting
+	// those of function literals?
+	bare := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if ret, ok := n.(*ast.ReturnStmt); ok && len(ret.Results) == 0 {
+			bare = true
+		}
+		_, lit := n.(*ast.FuncLit)
+		return !bare && !lit
+	})
+
+	// Find the assignments, including those of function literals.
+	assign := func(e ast.Expr) {
+		if id, ok := astutil.Unparen(e).(*ast.Ident); ok {
+			delete(unassigned, pass.TypesInfo.Uses[id])
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				assign(lhs)
+			}
+		case *ast.IncDecStmt:
+			assign(n.X)
+		case *ast.RangeStmt:
+			if n.Key != nil {
+				assign(n.Key)
+			}
+			if n.Value != nil {
+				assign(n.Value)
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				assign(n.X) // may be assigned through the pointer
+			}
+		case *ast.SelectorExpr:
+			assign(n.X) // may be assigned by a method, or a field
+		case *ast.IndexExpr:
+			assign(n.X) // an element may be assigned
+		}
+		return true
+	})
+	if !bare {
+		return
+	}
+	for _, f := range results.List {
+		for _, id := range f.Names {
+			if unassigned[pass.TypesInfo.Defs[id]] == id {
+				pass.Reportf(id.Pos(), "named result '%s' is never assigned", id.Name)
+			}
+		}
+	}
+}
+
+// exportedMethods returns the exported methods of the exported
+// package-level types of pkg, including those promoted from embedded
+// fields. An interface of any other package may need them.
+func exportedMethods(pkg *types.Package) map[*types.Func]bool {
+	res := make(map[*types.Func]bool)
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !obj.Exported() || types.IsInterface(obj.Type()) {
+			continue
+		}
+		mset := types.NewMethodSet(types.NewPointer(obj.Type()))
+		for i := 0; i < mset.Len(); i++ {
+			if fn, ok := mset.At(i).Obj().(*types.Func); ok && fn.Exported() {
+				res[fn] = true
+			}
+		}
+	}
+	return res
+}
+
+// localInterfaces returns the interfaces with methods used by the
+// package that are not declared at package level, and so are not in
+// the index of the implements analyzer.
+func localInterfaces(pass *analysis.Pass) []*types.Interface {
+	res := []*types.Interface{} // non-nil
+	for _, tv := range pass.TypesInfo.Types {
+		if named, ok := tv.Type.(*types.Named); ok && isIndexed(named) {
+			continue
+		}
+		if iface, ok := tv.Type.Underlying().(*types.Interface); ok && iface.NumMethods() > 0 {
+			res = append(res, iface)
+		}
+	}
+	return res
+}
+
+// isIndexed reports whether the named type is in the index of the
+// implements analyzer: whether it is a package-level type that is not
+// an instance of a generic type.
+func isIndexed(named *types.Named) bool {
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() && typeparams.NamedTypeArgs(named).Len() == 0
+}
+
+// mayImplement reports whether the method fn may be needed to
+// implement one of the interfaces of the package and its dependencies,
+// or of the local interfaces.
+func mayImplement(fn *types.Func, index *typeutil.ImplementsIndex, local []*types.Interface) bool {
+	recv := fn.Type().(*types.Signature).Recv().Type()
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok || typeparams.ForNamed(named).Len() > 0 {
+		return true // be conservative
+	}
+	// *T has the methods of T, so implements the interfaces of T.
+	for _, I := range index.Interfaces(types.NewPointer(named)) {
+		if hasMethod(I.Underlying().(*types.Interface), fn.Name()) {
+			return true
+		}
+	}
+	for _, iface := range local {
+		if hasMethod(iface, fn.Name()) &&
+			(types.Implements(named, iface) || types.Implements(types.NewPointer(named), iface)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMethod(iface *types.Interface, name string) bool {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -255,7 +255,7 @@ var GeneratedAPIJSON = &APIJSON{
 						},
 						{
 							Name:    "\"composites\"",
-							Doc:     "check for unkeyed composite literals\n\nThis analyzer reports a diagnostic for composite literals of struct\ntypes imported from another package that do not use the field-keyed\nsyntax. Such literals are fragile because the addition of a new field\n(even if unexported) to the struct will cause compilation to fail.\n\nAs an example,\n\n\terr = &net.DNSConfigError{err}\n\nshould be replaced by:\n\n\terr = &net.DNSConfigError{Err: err}\n",
+							Doc:     "check for unkeyed composite literals\n\nThis analyzer reports a diagnostic for composite literals of struct\ntypes imported from another package that do not use the field-keyed\nsyntax. Such literals are fragile because the addition of a new field\n(even if unexported) to the struct will cause compilation to fail.\n\nAs an example,\n\n\terr = &net.DNSConfigError{err}\n\nshould be replaced by:\n\n\terr = &net.DNSConfigError{Err: err}\n\nThe analyzer suggests a fix that adds the field names, when the fields\nare exported.\n\nWith the -crossmodule flag, the analyzer reports only the literals of\nstruct types of other modules, as the types of the module of a package\nchange along with it. It still reports the literals of types of the\nsame module that have fewer values than the struct has fields, as\nhappens when a field is added to the struct; the suggested fix names\nthe fields of the values that the literal has, in order.\n",
 							Default: "true",
 						},
 						{
@@ -269,8 +269,8 @@ var GeneratedAPIJSON = &APIJSON{
 							Default: "true",
 						},
 						{
-							Name:    "\"embed\"",
-							Doc:     "check for //go:embed directive import\n\nThis analyzer checks that the embed package is imported when source code contains //go:embed comment directives.\nThe embed package must be imported for //go:embed directives to function.import _ \"embed\".",
+							Name:    "\"embeddirective\"",
+							Doc:     "check //go:embed directives\n\nThe embeddirective analyzer reports //go:embed directives that\n\n - do not immediately precede the declaration of a single package-level\n   variable without an initializer;\n - are in a file that does not import \"embed\";\n - apply to a variable whose type is not string, []byte or embed.FS, or\n   to a variable of type string or []byte with more than one file;\n - have a pattern that is malformed or matches no file that may be\n   embedded.\n\nDiagnostics are reported at the directive.",
 							Default: "true",
 						},
 						{
@@ -280,7 +280,7 @@ var GeneratedAPIJSON = &APIJSON{
 						},
 						{
 							Name:    "\"fieldalignment\"",
-							Doc:     "find structs that would use less memory if their fields were sorted\n\nThis analyzer find structs that can be rearranged to use less memory, and provides\na suggested edit with the most compact order.\n\nNote that there are two different diagnostics reported. One checks struct size,\nand the other reports \"pointer bytes\" used. Pointer bytes is how many bytes of the\nobject that the garbage collector has to potentially scan for pointers, for example:\n\n\tstruct { uint32; string }\n\nhave 16 pointer bytes because the garbage collector has to scan up through the string's\ninner pointer.\n\n\tstruct { string; *uint32 }\n\nhas 24 pointer bytes because it has to scan further through the *uint32.\n\n\tstruct { string; uint32 }\n\nhas 8 because it can stop immediately after the string pointer.\n\nBe aware that the most compact order is not always the most efficient.\nIn rare cases it may cause two variables each updated by its own goroutine\nto occupy the same CPU cache line, inducing a form of memory contention\nknown as \"false sharing\" that slows down both goroutines.\n",
+							Doc:     "find structs that would use less memory if their fields were sorted\n\nThis analyzer find structs that can be rearranged to use less memory, and provides\na suggested edit with the most compact order. The edit moves the comments of each\nfield with it, and keeps the groups of fields separated by blank lines when sorting\nthe fields of each group is as compact.\n\nNote that there are two different diagnostics reported. One checks struct size,\nand the other reports \"pointer bytes\" used. Pointer bytes is how many bytes of the\nobject that the garbage collector has to potentially scan for pointers, for example:\n\n\tstruct { uint32; string }\n\nhave 16 pointer bytes because the garbage collector has to scan up through the string's\ninner pointer.\n\n\tstruct { string; *uint32 }\n\nhas 24 pointer bytes because it has to scan further through the *uint32.\n\n\tstruct { string; uint32 }\n\nhas 8 because it can stop immediately after the string pointer.\n\nBe aware that the most compact order is not always the most efficient.\nIn rare cases it may cause two variables each updated by its own goroutine\nto occupy the same CPU cache line, inducing a form of memory contention\nknown as \"false sharing\" that slows down both goroutines.\n",
 							Default: "false",
 						},
 						{
@@ -300,12 +300,12 @@ var GeneratedAPIJSON = &APIJSON{
 						},
 						{
 							Name:    "\"loopclosure\"",
-							Doc:     "check references to loop variables from within nested functions\n\nThis analyzer checks for references to loop variables from within a function\nliteral inside the loop body. It checks for patterns where access to a loop\nvariable is known to escape the current loop iteration:\n 1. a call to go or defer at the end of the loop body\n 2. a call to golang.org/x/sync/errgroup.Group.Go at the end of the loop body\n\nThe analyzer only considers references in the last statement of the loop body\nas it is not deep enough to understand the effects of subsequent statements\nwhich might render the reference benign.\n\nFor example:\n\n\tfor i, v := range s {\n\t\tgo func() {\n\t\t\tprintln(i, v) // not what you might expect\n\t\t}()\n\t}\n\nSee: https://golang.org/doc/go_faq.html#closures_and_goroutines",
+							Doc:     "check references to loop variables from within nested functions\n\nThis analyzer checks for references to loop variables from within a function\nliteral inside the loop body. It checks for patterns where access to a loop\nvariable is known to escape the current loop iteration:\n 1. a call to go or defer at the end of the loop body\n 2. a call to golang.org/x/sync/errgroup.Group.Go at the end of the loop body\n\nThe analyzer only considers references in the last statement of the loop body\nas it is not deep enough to understand the effects of subsequent statements\nwhich might render the reference benign.\n\nIt also reports statements of the loop body that store the address of a\nloop variable in a variable declared outside the loop, or send it on a\nchannel, unless the loop is exited at once:\n\n\tvar ptrs []*T\n\tfor _, v := range s {\n\t\tptrs = append(ptrs, &v) // all elements point to the same variable\n\t}\n\nAs of Go 1.22, each iteration of a loop has its own copy of the variables\ndeclared by the loop, so only loop variables declared outside the loop,\nas in \"for v = range s\", are checked. The version of Go is that of the go\ndirective of the go.mod file of the module, or that set by a build\nconstraint such as //go:build go1.22 of the file.\n\nFor example:\n\n\tfor i, v := range s {\n\t\tgo func() {\n\t\t\tprintln(i, v) // not what you might expect\n\t\t}()\n\t}\n\nSee: https://golang.org/doc/go_faq.html#closures_and_goroutines",
 							Default: "true",
 						},
 						{
 							Name:    "\"lostcancel\"",
-							Doc:     "check cancel func returned by context.WithCancel is called\n\nThe cancellation function returned by context.WithCancel, WithTimeout,\nand WithDeadline must be called or the new context will remain live\nuntil its parent context is cancelled.\n(The background context is never cancelled.)",
+							Doc:     "check cancel func returned by context.WithCancel is called\n\nThe cancellation function returned by context.WithCancel, WithTimeout,\nand WithDeadline must be called or the new context will remain live\nuntil its parent context is cancelled.\n(The background context is never cancelled.)\n\nWhere it is safe to do so, the analyzer suggests a fix that defers\na call of the cancellation function just after its definition.",
 							Default: "true",
 						},
 						{
@@ -315,17 +315,17 @@ var GeneratedAPIJSON = &APIJSON{
 						},
 						{
 							Name:    "\"nilness\"",
-							Doc:     "check for redundant or impossible nil comparisons\n\nThe nilness checker inspects the control-flow graph of each function in\na package and reports nil pointer dereferences, degenerate nil\npointers, and panics with nil values. A degenerate comparison is of the form\nx==nil or x!=nil where x is statically known to be nil or non-nil. These are\noften a mistake, especially in control flow related to errors. Panics with nil\nvalues are checked because they are not detectable by\n\n\tif r := recover(); r != nil {\n\nThis check reports conditions such as:\n\n\tif f == nil { // impossible condition (f is a function)\n\t}\n\nand:\n\n\tp := &v\n\t...\n\tif p != nil { // tautological condition\n\t}\n\nand:\n\n\tif p == nil {\n\t\tprint(*p) // nil dereference\n\t}\n\nand:\n\n\tif p == nil {\n\t\tpanic(p)\n\t}\n",
+							Doc:     "check for redundant or impossible nil comparisons\n\nThe nilness checker inspects the control-flow graph of each function in\na package and reports nil pointer dereferences, degenerate nil\npointers, and panics with nil values. A degenerate comparison is of the form\nx==nil or x!=nil where x is statically known to be nil or non-nil. These are\noften a mistake, especially in control flow related to errors. Panics with nil\nvalues are checked because they are not detectable by\n\n\tif r := recover(); r != nil {\n\nThis check reports conditions such as:\n\n\tif f == nil { // impossible condition (f is a function)\n\t}\n\nand:\n\n\tp := &v\n\t...\n\tif p != nil { // tautological condition\n\t}\n\nand:\n\n\tif p == nil {\n\t\tprint(*p) // nil dereference\n\t}\n\nand:\n\n\tif p == nil {\n\t\tpanic(p)\n\t}\n\nGeneric functions are checked through their instantiations by the\npackage, except for conditions, which may depend on the type arguments.\n",
 							Default: "false",
 						},
 						{
 							Name:    "\"printf\"",
-							Doc:     "check consistency of Printf format strings and arguments\n\nThe check applies to known functions (for example, those in package fmt)\nas well as any detected wrappers of known functions.\n\nA function that wants to avail itself of printf checking but is not\nfound by this analyzer's heuristics (for example, due to use of\ndynamic calls) can insert a bogus call:\n\n\tif false {\n\t\t_ = fmt.Sprintf(format, args...) // enable printf checking\n\t}\n\nThe -funcs flag specifies a comma-separated list of names of additional\nknown formatting functions or methods. If the name contains a period,\nit must denote a specific function using one of the following forms:\n\n\tdir/pkg.Function\n\tdir/pkg.Type.Method\n\t(*dir/pkg.Type).Method\n\nOtherwise the name is interpreted as a case-insensitive unqualified\nidentifier such as \"errorf\". Either way, if a listed name ends in f, the\nfunction is assumed to be Printf-like, taking a format string before the\nargument list. Otherwise it is assumed to be Print-like, taking a list\nof arguments with no format string.\n",
+							Doc:     "check consistency of Printf format strings and arguments\n\nThe check applies to known functions (for example, those in package fmt)\nas well as any detected wrappers of known functions.\n\nA function that wants to avail itself of printf checking but is not\nfound by this analyzer's heuristics (for example, due to use of\ndynamic calls) can insert a bogus call:\n\n\tif false {\n\t\t_ = fmt.Sprintf(format, args...) // enable printf checking\n\t}\n\nThe -funcs flag specifies a comma-separated list of names of additional\nknown formatting functions or methods. If the name contains a period,\nit must denote a specific function using one of the following forms:\n\n\tdir/pkg.Function\n\tdir/pkg.Type.Method\n\t(*dir/pkg.Type).Method\n\nOtherwise the name is interpreted as a case-insensitive unqualified\nidentifier such as \"errorf\". Either way, if a listed name ends in f, the\nfunction is assumed to be Printf-like, taking a format string before the\nargument list. Otherwise it is assumed to be Print-like, taking a list\nof arguments with no format string.\n\nThe -wrappers flag declares print wrappers whose kind does not follow\nfrom their names, such as the functions of a logging package, as a\ncomma-separated list of name:kind pairs, where the name has one of the\nforms above and the kind is print, printf or errorf:\n\n\texample.com/log.Info:printf,example.com/log.Wrap:errorf\n\nThe -wrappersfile flag names a file that declares them one per line,\nas a name and a kind separated by spaces; lines starting with # are\ncomments. The analyzer exports the declared wrappers of each package as\nfacts, so their callers in other packages are checked too.\n\nThe analyzer also checks the error-wrapping directive %w of fmt.Errorf\nand its wrappers. It reports %w applied to a non-error argument or used\nby a function other than Errorf, and more than one %w in a call when\nthe version of Go in use (before Go 1.20) can wrap only one error. It\nreports an error formatted with %v or %s, when the call wraps no other\nerror and its result is checked with errors.Is or errors.As, as the\ncheck cannot find the formatted error. Each of these diagnostics has a\nfix that changes the verb.\n",
 							Default: "true",
 						},
 						{
 							Name:    "\"shadow\"",
-							Doc:     "check for possible unintended shadowing of variables\n\nThis analyzer check for shadowed variables.\nA shadowed variable is a variable declared in an inner scope\nwith the same name and type as a variable in an outer scope,\nand where the outer variable is mentioned after the inner one\nis declared.\n\n(This definition can be refined; the module generates too many\nfalse positives and is not yet enabled by default.)\n\nFor example:\n\n\tfunc BadRead(f *os.File, buf []byte) error {\n\t\tvar err error\n\t\tfor {\n\t\t\tn, err := f.Read(buf) // shadows the function variable 'err'\n\t\t\tif err != nil {\n\t\t\t\tbreak // causes return of wrong value\n\t\t\t}\n\t\t\tfoo(buf)\n\t\t}\n\t\treturn err\n\t}\n",
+							Doc:     "check for possible unintended shadowing of variables\n\nThis analyzer check for shadowed variables.\nA shadowed variable is a variable declared in an inner scope\nwith the same name and type as a variable in an outer scope,\nand where the outer variable is read after the scope of the inner\none ends, so that a value assigned to the inner variable may have\nbeen meant for the outer one.\n\nFor example:\n\n\tfunc BadRead(f *os.File, buf []byte) error {\n\t\tvar err error\n\t\tfor {\n\t\t\tn, err := f.Read(buf) // shadows the function variable 'err'\n\t\t\tif err != nil {\n\t\t\t\tbreak // causes return of wrong value\n\t\t\t}\n\t\t\tfoo(buf)\n\t\t}\n\t\treturn err\n\t}\n\nEach report suggests renaming the inner variable and, where the types\nallow, assigning to the outer variable instead of declaring a new one.\n\nThe -strict flag reports all shadowing, whether or not the outer\nvariable is read later. The -names flag is a comma-separated list of\nthe names of the variables to check, such as \"err,ctx\"; by default\nall variables are checked. The -minlines flag ignores inner variables\nwhose scope spans fewer lines than it, such as those of an if\nstatement that returns at once.",
 							Default: "false",
 						},
 						{
@@ -380,7 +380,7 @@ var GeneratedAPIJSON = &APIJSON{
 						},
 						{
 							Name:    "\"timeformat\"",
-							Doc:     "check for calls of (time.Time).Format or time.Parse with 2006-02-01\n\nThe timeformat checker looks for time formats with the 2006-02-01 (yyyy-dd-mm)\nformat. Internationally, \"yyyy-dd-mm\" does not occur in common calendar date\nstandards, and so it is more likely that 2006-01-02 (yyyy-mm-dd) was intended.\n",
+							Doc:     "check for calls of (time.Time).Format or time.Parse with 2006-02-01\n\nThe timeformat checker looks for time formats with the 2006-02-01 (yyyy-dd-mm)\nformat. Internationally, \"yyyy-dd-mm\" does not occur in common calendar date\nstandards, and so it is more likely that 2006-01-02 (yyyy-mm-dd) was intended.\nLayouts held in local variables that are assigned only where they are\ndeclared are checked like constant ones.\n\nIt also checks constant layouts of time.Parse, time.ParseInLocation, and the\nFormat and AppendFormat methods of time.Time for other likely mistakes, and\nsuggests a corrected layout where it can:\n\n\tplaceholders such as \"YYYY-MM-DD\" instead of the reference time, \"2006-01-02\";\n\tthe month (01) for the minute, as in \"15:01\", or the minute (04) for the month;\n\tthe year (06) for the second, as in \"15:04:06\";\n\ta 12-hour clock (03) without PM, or PM with a 24-hour clock (15);\n\ttwo elements for the same value, such as \"Jan\" and \"01\".\n",
 							Default: "true",
 						},
 						{
@@ -400,7 +400,7 @@ var GeneratedAPIJSON = &APIJSON{
 						},
 						{
 							Name:    "\"unusedparams\"",
-							Doc:     "check for unused parameters of functions\n\nThe unusedparams analyzer checks functions to see if there are\nany parameters that are not being used.\n\nTo reduce false positives it ignores:\n- methods\n- parameters that do not have a name or are underscored\n- functions in test files\n- functions with empty bodies or those with just a return stmt",
+							Doc:     "check for unused parameters of functions\n\nThe unusedparams analyzer checks functions to see if there are\nany parameters that are not being used, and suggests renaming them\nto \"_\". It also reports named results that are never assigned by a\nfunction that has a return statement without values.\n\nTo reduce false positives it ignores:\n- exported methods of exported types, which may implement interfaces of\n  other packages\n- methods that may implement an interface of the package or its dependencies\n- parameters that do not have a name or are underscored\n- functions in test files\n- functions with empty bodies or those with just a return stmt",
 							Default: "false",
 						},
 						{
@@ -882,7 +882,7 @@ var GeneratedAPIJSON = &APIJSON{
 		},
 		{
 			Name:    "composites",
-			Doc:     "check for unkeyed composite literals\n\nThis analyzer reports a diagnostic for composite literals of struct\ntypes imported from another package that do not use the field-keyed\nsyntax. Such literals are fragile because the addition of a new field\n(even if unexported) to the struct will cause compilation to fail.\n\nAs an example,\n\n\terr = &net.DNSConfigError{err}\n\nshould be replaced by:\n\n\terr = &net.DNSConfigError{Err: err}\n",
+			Doc:     "check for unkeyed composite literals\n\nThis analyzer reports a diagnostic for composite literals of struct\ntypes imported from another package that do not use the field-keyed\nsyntax. Such literals are fragile because the addition of a new field\n(even if unexported) to the struct will cause compilation to fail.\n\nAs an example,\n\n\terr = &net.DNSConfigError{err}\n\nshould be replaced by:\n\n\terr = &net.DNSConfigError{Err: err}\n\nThe analyzer suggests a fix that adds the field names, when the fields\nare exported.\n\nWith the -crossmodule flag, the analyzer reports only the literals of\nstruct types of other modules, as the types of the module of a package\nchange along with it. It still reports the literals of types of the\nsame module that have fewer values than the struct has fields, as\nhappens when a field is added to the struct; the suggested fix names\nthe fields of the values that the literal has, in order.\n",
 			Default: true,
 		},
 		{
@@ -896,8 +896,8 @@ var GeneratedAPIJSON = &APIJSON{
 			Default: true,
 		},
 		{
-			Name:    "embed",
-			Doc:     "check for //go:embed directive import\n\nThis analyzer checks that the embed package is imported when source code contains //go:embed comment directives.\nThe embed package must be imported for //go:embed directives to function.import _ \"embed\".",
+			Name:    "embeddirective",
+			Doc:     "check //go:embed directives\n\nThe embeddirective analyzer reports //go:embed directives that\n\n - do not immediately precede the declaration of a single package-level\n   variable without an initializer;\n - are in a file that does not import \"embed\";\n - apply to a variable whose type is not string, []byte or embed.FS, or\n   to a variable of type string or []byte with more than one file;\n - have a pattern that is malformed or matches no file that may be\n   embedded.\n\nDiagnostics are reported at the directive.",
 			Default: true,
 		},
 		{
@@ -907,7 +907,7 @@ var GeneratedAPIJSON = &APIJSON{
 		},
 		{
 			Name: "fieldalignment",
-			Doc:  "find structs that would use less memory if their fields were sorted\n\nThis analyzer find structs that can be rearranged to use less memory, and provides\na suggested edit with the most compact order.\n\nNote that there are two different diagnostics reported. One checks struct size,\nand the other reports \"pointer bytes\" used. Pointer bytes is how many bytes of the\nobject that the garbage collector has to potentially scan for pointers, for example:\n\n\tstruct { uint32; string }\n\nhave 16 pointer bytes because the garbage collector has to scan up through the string's\ninner pointer.\n\n\tstruct { string; *uint32 }\n\nhas 24 pointer bytes because it has to scan further through the *uint32.\n\n\tstruct { string; uint32 }\n\nhas 8 because it can stop immediately after the string pointer.\n\nBe aware that the most compact order is not always the most efficient.\nIn rare cases it may cause two variables each updated by its own goroutine\nto occupy the same CPU cache line, inducing a form of memory contention\nknown as \"false sharing\" that slows down both goroutines.\n",
+			Doc:  "find structs that would use less memory if their fields were sorted\n\nThis analyzer find structs that can be rearranged to use less memory, and provides\na suggested edit with the most compact order. The edit moves the comments of each\nfield with it, and keeps the groups of fields separated by blank lines when sorting\nthe fields of each group is as compact.\n\nNote that there are two different diagnostics reported. One checks struct size,\nand the other reports \"pointer bytes\" used. Pointer bytes is how many bytes of the\nobject that the garbage collector has to potentially scan for pointers, for example:\n\n\tstruct { uint32; string }\n\nhave 16 pointer bytes because the garbage collector has to scan up through the string's\ninner pointer.\n\n\tstruct { string; *uint32 }\n\nhas 24 pointer bytes because it has to scan further through the *uint32.\n\n\tstruct { string; uint32 }\n\nhas 8 because it can stop immediately after the string pointer.\n\nBe aware that the most compact order is not always the most efficient.\nIn rare cases it may cause two variables each updated by its own goroutine\nto occupy the same CPU cache line, inducing a form of memory contention\nknown as \"false sharing\" that slows down both goroutines.\n",
 		},
 		{
 			Name:    "httpresponse",
@@ -926,12 +926,12 @@ var GeneratedAPIJSON = &APIJSON{
 		},
 		{
 			Name:    "loopclosure",
-			Doc:     "check references to loop variables from within nested functions\n\nThis analyzer checks for references to loop variables from within a function\nliteral inside the loop body. It checks for patterns where access to a loop\nvariable is known to escape the current loop iteration:\n 1. a call to go or defer at the end of the loop body\n 2. a call to golang.org/x/sync/errgroup.Group.Go at the end of the loop body\n\nThe analyzer only considers references in the last statement of the loop body\nas it is not deep enough to understand the effects of subsequent statements\nwhich might render the reference benign.\n\nFor example:\n\n\tfor i, v := range s {\n\t\tgo func() {\n\t\t\tprintln(i, v) // not what you might expect\n\t\t}()\n\t}\n\nSee: https://golang.org/doc/go_faq.html#closures_and_goroutines",
+			Doc:     "check references to loop variables from within nested functions\n\nThis analyzer checks for references to loop variables from within a function\nliteral inside the loop body. It checks for patterns where access to a loop\nvariable is known to escape the current loop iteration:\n 1. a call to go or defer at the end of the loop body\n 2. a call to golang.org/x/sync/errgroup.Group.Go at the end of the loop body\n\nThe analyzer only considers references in the last statement of the loop body\nas it is not deep enough to understand the effects of subsequent statements\nwhich might render the reference benign.\n\nIt also reports statements of the loop body that store the address of a\nloop variable in a variable declared outside the loop, or send it on a\nchannel, unless the loop is exited at once:\n\n\tvar ptrs []*T\n\tfor _, v := range s {\n\t\tptrs = append(ptrs, &v) // all elements point to the same variable\n\t}\n\nAs of Go 1.22, each iteration of a loop has its own copy of the variables\ndeclared by the loop, so only loop variables declared outside the loop,\nas in \"for v = range s\", are checked. The version of Go is that of the go\ndirective of the go.mod file of the module, or that set by a build\nconstraint such as //go:build go1.22 of the file.\n\nFor example:\n\n\tfor i, v := range s {\n\t\tgo func() {\n\t\t\tprintln(i, v) // not what you might expect\n\t\t}()\n\t}\n\nSee: https://golang.org/doc/go_faq.html#closures_and_goroutines",
 			Default: true,
 		},
 		{
 			Name:    "lostcancel",
-			Doc:     "check cancel func returned by context.WithCancel is called\n\nThe cancellation function returned by context.WithCancel, WithTimeout,\nand WithDeadline must be called or the new context will remain live\nuntil its parent context is cancelled.\n(The background context is never cancelled.)",
+			Doc:     "check cancel func returned by context.WithCancel is called\n\nThe cancellation function returned by context.WithCancel, WithTimeout,\nand WithDeadline must be called or the new context will remain live\nuntil its parent context is cancelled.\n(The background context is never cancelled.)\n\nWhere it is safe to do so, the analyzer suggests a fix that defers\na call of the cancellation function just after its definition.",
 			Default: true,
 		},
 		{
@@ -941,16 +941,16 @@ var GeneratedAPIJSON = &APIJSON{
 		},
 		{
 			Name: "nilness",
-			Doc:  "check for redundant or impossible nil comparisons\n\nThe nilness checker inspects the control-flow graph of each function in\na package and reports nil pointer dereferences, degenerate nil\npointers, and panics with nil values. A degenerate comparison is of the form\nx==nil or x!=nil where x is statically known to be nil or non-nil. These are\noften a mistake, especially in control flow related to errors. Panics with nil\nvalues are checked because they are not detectable by\n\n\tif r := recover(); r != nil {\n\nThis check reports conditions such as:\n\n\tif f == nil { // impossible condition (f is a function)\n\t}\n\nand:\n\n\tp := &v\n\t...\n\tif p != nil { // tautological condition\n\t}\n\nand:\n\n\tif p == nil {\n\t\tprint(*p) // nil dereference\n\t}\n\nand:\n\n\tif p == nil {\n\t\tpanic(p)\n\t}\n",
+			Doc:  "check for redundant or impossible nil comparisons\n\nThe nilness checker inspects the control-flow graph of each function in\na package and reports nil pointer dereferences, degenerate nil\npointers, and panics with nil values. A degenerate comparison is of the form\nx==nil or x!=nil where x is statically known to be nil or non-nil. These are\noften a mistake, especially in control flow related to errors. Panics with nil\nvalues are checked because they are not detectable by\n\n\tif r := recover(); r != nil {\n\nThis check reports conditions such as:\n\n\tif f == nil { // impossible condition (f is a function)\n\t}\n\nand:\n\n\tp := &v\n\t...\n\tif p != nil { // tautological condition\n\t}\n\nand:\n\n\tif p == nil {\n\t\tprint(*p) // nil dereference\n\t}\n\nand:\n\n\tif p == nil {\n\t\tpanic(p)\n\t}\n\nGeneric functions are checked through their instantiations by the\npackage, except for conditions, which may depend on the type arguments.\n",
 		},
 		{
 			Name:    "printf",
-			Doc:     "check consistency of Printf format strings and arguments\n\nThe check applies to known functions (for example, those in package fmt)\nas well as any detected wrappers of known functions.\n\nA function that wants to avail itself of printf checking but is not\nfound by this analyzer's heuristics (for example, due to use of\ndynamic calls) can insert a bogus call:\n\n\tif false {\n\t\t_ = fmt.Sprintf(format, args...) // enable printf checking\n\t}\n\nThe -funcs flag specifies a comma-separated list of names of additional\nknown formatting functions or methods. If the name contains a period,\nit must denote a specific function using one of the following forms:\n\n\tdir/pkg.Function\n\tdir/pkg.Type.Method\n\t(*dir/pkg.Type).Method\n\nOtherwise the name is interpreted as a case-insensitive unqualified\nidentifier such as \"errorf\". Either way, if a listed name ends in f, the\nfunction is assumed to be Printf-like, taking a format string before the\nargument list. Otherwise it is assumed to be Print-like, taking a list\nof arguments with no format string.\n",
+			Doc:     "check consistency of Printf format strings and arguments\n\nThe check applies to known functions (for example, those in package fmt)\nas well as any detected wrappers of known functions.\n\nA function that wants to avail itself of printf checking but is not\nfound by this analyzer's heuristics (for example, due to use of\ndynamic calls) can insert a bogus call:\n\n\tif false {\n\t\t_ = fmt.Sprintf(format, args...) // enable printf checking\n\t}\n\nThe -funcs flag specifies a comma-separated list of names of additional\nknown formatting functions or methods. If the name contains a period,\nit must denote a specific function using one of the following forms:\n\n\tdir/pkg.Function\n\tdir/pkg.Type.Method\n\t(*dir/pkg.Type).Method\n\nOtherwise the name is interpreted as a case-insensitive unqualified\nidentifier such as \"errorf\". Either way, if a listed name ends in f, the\nfunction is assumed to be Printf-like, taking a format string before the\nargument list. Otherwise it is assumed to be Print-like, taking a list\nof arguments with no format string.\n\nThe -wrappers flag declares print wrappers whose kind does not follow\nfrom their names, such as the functions of a logging package, as a\ncomma-separated list of name:kind pairs, where the name has one of the\nforms above and the kind is print, printf or errorf:\n\n\texample.com/log.Info:printf,example.com/log.Wrap:errorf\n\nThe -wrappersfile flag names a file that declares them one per line,\nas a name and a kind separated by spaces; lines starting with # are\ncomments. The analyzer exports the declared wrappers of each package as\nfacts, so their callers in other packages are checked too.\n\nThe analyzer also checks the error-wrapping directive %w of fmt.Errorf\nand its wrappers. It reports %w applied to a non-error argument or used\nby a function other than Errorf, and more than one %w in a call when\nthe version of Go in use (before Go 1.20) can wrap only one error. It\nreports an error formatted with %v or %s, when the call wraps no other\nerror and its result is checked with errors.Is or errors.As, as the\ncheck cannot find the formatted error. Each of these diagnostics has a\nfix that changes the verb.\n",
 			Default: true,
 		},
 		{
 			Name: "shadow",
-			Doc:  "check for possible unintended shadowing of variables\n\nThis analyzer check for shadowed variables.\nA shadowed variable is a variable declared in an inner scope\nwith the same name and type as a variable in an outer scope,\nand where the outer variable is mentioned after the inner one\nis declared.\n\n(This definition can be refined; the module generates too many\nfalse positives and is not yet enabled by default.)\n\nFor example:\n\n\tfunc BadRead(f *os.File, buf []byte) error {\n\t\tvar err error\n\t\tfor {\n\t\t\tn, err := f.Read(buf) // shadows the function variable 'err'\n\t\t\tif err != nil {\n\t\t\t\tbreak // causes return of wrong value\n\t\t\t}\n\t\t\tfoo(buf)\n\t\t}\n\t\treturn err\n\t}\n",
+			Doc:  "check for possible unintended shadowing of variables\n\nThis analyzer check for shadowed variables.\nA shadowed variable is a variable declared in an inner scope\nwith the same name and type as a variable in an outer scope,\nand where the outer variable is read after the scope of the inner\none ends, so that a value assigned to the inner variable may have\nbeen meant for the outer one.\n\nFor example:\n\n\tfunc BadRead(f *os.File, buf []byte) error {\n\t\tvar err error\n\t\tfor {\n\t\t\tn, err := f.Read(buf) // shadows the function variable 'err'\n\t\t\tif err != nil {\n\t\t\t\tbreak // causes return of wrong value\n\t\t\t}\n\t\t\tfoo(buf)\n\t\t}\n\t\treturn err\n\t}\n\nEach report suggests renaming the inner variable and, where the types\nallow, assigning to the outer variable instead of declaring a new one.\n\nThe -strict flag reports all shadowing, whether or not the outer\nvariable is read later. The -names flag is a comma-separated list of\nthe names of the variables to check, such as \"err,ctx\"; by default\nall variables are checked. The -minlines flag ignores inner variables\nwhose scope spans fewer lines than it, such as those of an if\nstatement that returns at once.",
 		},
 		{
 			Name:    "shift",
@@ -1004,7 +1004,7 @@ var GeneratedAPIJSON = &APIJSON{
 		},
 		{
 			Name:    "timeformat",
-			Doc:     "check for calls of (time.Time).Format or time.Parse with 2006-02-01\n\nThe timeformat checker looks for time formats with the 2006-02-01 (yyyy-dd-mm)\nformat. Internationally, \"yyyy-dd-mm\" does not occur in common calendar date\nstandards, and so it is more likely that 2006-01-02 (yyyy-mm-dd) was intended.\n",
+			Doc:     "check for calls of (time.Time).Format or time.Parse with 2006-02-01\n\nThe timeformat checker looks for time formats with the 2006-02-01 (yyyy-dd-mm)\nformat. Internationally, \"yyyy-dd-mm\" does not occur in common calendar date\nstandards, and so it is more likely that 2006-01-02 (yyyy-mm-dd) was intended.\nLayouts held in local variables that are assigned only where they are\ndeclared are checked like constant ones.\n\nIt also checks constant layouts of time.Parse, time.ParseInLocation, and the\nFormat and AppendFormat methods of time.Time for other likely mistakes, and\nsuggests a corrected layout where it can:\n\n\tplaceholders such as \"YYYY-MM-DD\" instead of the reference time, \"2006-01-02\";\n\tthe month (01) for the minute, as in \"15:01\", or the minute (04) for the month;\n\tthe year (06) for the second, as in \"15:04:06\";\n\ta 12-hour clock (03) without PM, or PM with a 24-hour clock (15);\n\ttwo elements for the same value, such as \"Jan\" and \"01\".\n",
 			Default: true,
 		},
 		{
@@ -1024,7 +1024,7 @@ var GeneratedAPIJSON = &APIJSON{
 		},
 		{
 			Name: "unusedparams",
-			Doc:  "check for unused parameters of functions\n\nThe unusedparams analyzer checks functions to see if there are\nany parameters that are not being used.\n\nTo reduce false positives it ignores:\n- methods\n- parameters that do not have a name or are underscored\n- functions in test files\n- functions with empty bodies or those with just a return stmt",
+			Doc:  "check for unused parameters of functions\n\nThe unusedparams analyzer checks functions to see if there are\nany parameters that are not being used, and suggests renaming them\nto \"_\". It also reports named results that are never assigned by a\nfunction that has a return statement without values.\n\nTo reduce false positives it ignores:\n- exported methods of exported types, which may implement interfaces of\n  other packages\n- methods that may implement an interface of the package or its dependencies\n- parameters that do not have a name or are underscored\n- functions in test files\n- functions with empty bodies or those with just a return stmt",
 		},
 		{
 			Name:    "unusedresult",
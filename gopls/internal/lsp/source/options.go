@@ -26,6 +26,7 @@ import (
 	"golang.org/x/tools/go/analysis/passes/composite"
 	"golang.org/x/tools/go/analysis/passes/copylock"
 	"golang.org/x/tools/go/analysis/passes/deepequalerrors"
+	"golang.org/x/tools/go/analysis/passes/embeddirective"
 	"golang.org/x/tools/go/analysis/passes/errorsas"
 	"golang.org/x/tools/go/analysis/passes/fieldalignment"
 	"golang.org/x/tools/go/analysis/passes/httpresponse"
@@ -49,7 +50,6 @@ import (
 	"golang.org/x/tools/go/analysis/passes/unsafeptr"
 	"golang.org/x/tools/go/analysis/passes/unusedresult"
 	"golang.org/x/tools/go/analysis/passes/unusedwrite"
-	"golang.org/x/tools/gopls/internal/lsp/analysis/embeddirective"
 	"golang.org/x/tools/gopls/internal/lsp/analysis/fillreturns"
 	"golang.org/x/tools/gopls/internal/lsp/analysis/fillstruct"
 	"golang.org/x/tools/gopls/internal/lsp/analysis/infertypeargs"
@@ -979,6 +979,12 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 
 	case "analyses":
 		result.setBoolMap(&o.Analyses)
+		// The embeddirective analyzer was called "embed".
+		if enabled, ok := o.Analyses["embed"]; ok {
+			delete(o.Analyses, "embed")
+			o.Analyses[embeddirective.Analyzer.Name] = enabled
+			result.Error = &SoftError{fmt.Sprintf("analyzer %q is deprecated, use %q instead", "embed", embeddirective.Analyzer.Name)}
+		}
 
 	case "hints":
 		result.setBoolMap(&o.Hints)
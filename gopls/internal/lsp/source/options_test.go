@@ -54,6 +54,15 @@ func TestSetOption(t *testing.T) {
 			value: map[string]interface{}{"generate": true},
 			check: func(o Options) bool { return o.Codelenses["generate"] },
 		},
+		{
+			name:      "analyses",
+			value:     map[string]interface{}{"embed": false},
+			wantError: true, // "embed" is deprecated
+			check: func(o Options) bool {
+				enabled, ok := o.Analyses["embeddirective"]
+				return ok && !enabled && len(o.Analyses) == 1
+			},
+		},
 		{
 			name:  "allExperiments",
 			value: true,
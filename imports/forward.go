@@ -9,6 +9,7 @@ package imports // import "golang.org/x/tools/imports"
 import (
 	"io/ioutil"
 	"log"
+	"strings"
 
 	"golang.org/x/tools/internal/gocommand"
 	intimp "golang.org/x/tools/internal/imports"
@@ -24,6 +25,27 @@ type Options struct {
 	TabWidth  int  // Tab width (8 if nil *Options provided)
 
 	FormatOnly bool // Disable the insertion and deletion of imports
+
+	// LocalPrefixes, if not nil, are the import path prefixes of the
+	// local packages, which are sorted into a group after third-party
+	// packages, in place of those of the LocalPrefix variable.
+	LocalPrefixes []string
+
+	// Group, if not nil, returns the group of an import path, in place
+	// of the default grouping: the standard library, then third-party
+	// packages, then local packages. The imports are sorted by group,
+	// then by path, and the groups are separated by blank lines.
+	Group func(importPath string) int
+
+	// Regroup causes the imports to be grouped anew, ignoring the blank
+	// lines of the source, which otherwise delimit runs of imports that
+	// are sorted separately.
+	Regroup bool
+
+	// Rewrite maps the paths of banned imports to the paths of the
+	// packages to import in their place, which must provide the same
+	// API, such as forks. The rewrite applies even if FormatOnly is set.
+	Rewrite map[string]string
 }
 
 // Debug controls verbose logging.
@@ -52,11 +74,18 @@ func Process(filename string, src []byte, opt *Options) ([]byte, error) {
 	if opt == nil {
 		opt = &Options{Comments: true, TabIndent: true, TabWidth: 8}
 	}
+	localPrefix := LocalPrefix
+	if opt.LocalPrefixes != nil {
+		localPrefix = strings.Join(opt.LocalPrefixes, ",")
+	}
 	intopt := &intimp.Options{
 		Env: &intimp.ProcessEnv{
 			GocmdRunner: &gocommand.Runner{},
 		},
-		LocalPrefix: LocalPrefix,
+		LocalPrefix: localPrefix,
+		Group:       opt.Group,
+		Regroup:     opt.Regroup,
+		Rewrite:     opt.Rewrite,
 		AllErrors:   opt.AllErrors,
 		Comments:    opt.Comments,
 		FormatOnly:  opt.FormatOnly,
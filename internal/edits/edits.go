@@ -0,0 +1,268 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package edits accumulates the textual edits of files that refactoring
+// tools make, such as renamings, template-based rewrites and suggested
+// fixes, detects the edits that conflict, and either prints the changes
+// as unified diffs or applies them, replacing each file atomically.
+package edits
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/diff"
+	"golang.org/x/tools/internal/diff/myers"
+)
+
+// An Edit replaces the bytes [Start, End) of the named file by New.
+type Edit struct {
+	Filename   string
+	Start, End int
+	New        string
+}
+
+// A ConflictError reports that an edit conflicts with a previous one:
+// their ranges overlap, or they insert text at the same offset.
+type ConflictError struct {
+	Edit, Prev Edit
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: edit of bytes %d-%d conflicts with another edit of bytes %d-%d",
+		e.Edit.Filename, e.Edit.Start, e.Edit.End, e.Prev.Start, e.Prev.End)
+}
+
+// A Set is a set of edits of files, without conflicts.
+// The zero Set is empty and ready to use.
+type Set struct {
+	// Format determines whether the new contents of the Go files are
+	// formatted, if they are well formed.
+	Format bool
+
+	edits    map[string][]Edit // by filename
+	contents map[string][]byte // the original contents of the files, by filename
+}
+
+// Add adds edits to s all together, unless one of them conflicts with
+// an edit of s or with another of them, in which case it adds none of
+// them and returns a *ConflictError. An edit identical to another is
+// redundant, and is not added again.
+func (s *Set) Add(edits ...Edit) error {
+	var added []Edit
+edits:
+	for i, edit := range edits {
+		if edit.Start < 0 || edit.Start > edit.End {
+			return fmt.Errorf("%s: invalid edit of bytes %d-%d", edit.Filename, edit.Start, edit.End)
+		}
+		for _, prev := range append(s.edits[edit.Filename], edits[:i]...) {
+			if prev.Filename != edit.Filename {
+				continue
+			}
+			if prev == edit {
+				continue edits // redundant
+			}
+			if edit.Start < prev.End && prev.Start < edit.End ||
+				edit.Start == prev.Start && edit.End == prev.End {
+				return &ConflictError{Edit: edit, Prev: prev}
+			}
+		}
+		added = append(added, edit)
+	}
+	if s.edits == nil {
+		s.edits = make(map[string][]Edit)
+	}
+	for _, edit := range added {
+		s.edits[edit.Filename] = append(s.edits[edit.Filename], edit)
+	}
+	return nil
+}
+
+// SetContent records the original content of the named file, to which
+// the edits apply; by default, it is read from the file.
+func (s *Set) SetContent(filename string, content []byte) {
+	if s.contents == nil {
+		s.contents = make(map[string][]byte)
+	}
+	s.contents[filename] = content
+}
+
+// Filenames returns the names of the edited files, in order.
+func (s *Set) Filenames() []string {
+	var filenames []string
+	for filename := range s.edits {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
+// Edits returns the edits of the named file, in the order of their
+// offsets.
+func (s *Set) Edits(filename string) []Edit {
+	edits := append([]Edit(nil), s.edits[filename]...)
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].Start != edits[j].Start {
+			return edits[i].Start < edits[j].Start
+		}
+		return edits[i].End < edits[j].End
+	})
+	return edits
+}
+
+// content returns the original content of the named file.
+func (s *Set) content(filename string) ([]byte, error) {
+	if content, ok := s.contents[filename]; ok {
+		return content, nil
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s.SetContent(filename, content)
+	return content, nil
+}
+
+// Content returns the original and new contents of the named file.
+func (s *Set) Content(filename string) (before, after []byte, err error) {
+	before, err = s.content(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	var out bytes.Buffer
+	cur := 0 // current position in the file
+	for _, edit := range s.Edits(filename) {
+		if edit.End > len(before) {
+			return nil, nil, fmt.Errorf("%s: edit of bytes %d-%d is beyond end of file", filename, edit.Start, edit.End)
+		}
+		out.Write(before[cur:edit.Start])
+		out.WriteString(edit.New)
+		cur = edit.End
+	}
+	out.Write(before[cur:])
+	after = out.Bytes()
+
+	if s.Format && strings.HasSuffix(filename, ".go") {
+		if formatted, err := format.Source(after); err == nil {
+			after = formatted
+		}
+	}
+	return before, after, nil
+}
+
+// Contents returns the new contents of the edited files, by filename.
+func (s *Set) Contents() (map[string][]byte, error) {
+	contents := make(map[string][]byte)
+	for _, filename := range s.Filenames() {
+		_, after, err := s.Content(filename)
+		if err != nil {
+			return nil, err
+		}
+		contents[filename] = after
+	}
+	return contents, nil
+}
+
+// Diff writes to w the changes to the edited files, one file at a time,
+// as unified diffs of the files relative to dir, that may be applied with
+// patch -p0 in dir. The names of the files outside dir, or of all files
+// if dir is empty, are as they are.
+func (s *Set) Diff(w io.Writer, dir string) error {
+	for _, filename := range s.Filenames() {
+		before, after, err := s.Content(filename)
+		if err != nil {
+			return err
+		}
+		if err := WriteUnifiedDiff(w, dir, filename, before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply writes the new contents of the edited files, replacing each one
+// atomically. It tries to write all the files, and returns the error of
+// the first one that it fails to write, if any.
+func (s *Set) Apply() error {
+	var first error
+	nerrs := 0
+	for _, filename := range s.Filenames() {
+		_, after, err := s.Content(filename)
+		if err == nil {
+			err = WriteFile(filename, after)
+		}
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			nerrs++
+		}
+	}
+	if nerrs > 1 {
+		return fmt.Errorf("failed to write %d files: %v", nerrs, first)
+	}
+	return first
+}
+
+// WriteUnifiedDiff writes to w the changes to the named file, from
+// before to after, as a unified diff of the file relative to dir, as
+// Set.Diff does. It writes nothing if there are no changes.
+func WriteUnifiedDiff(w io.Writer, dir, filename string, before, after []byte) error {
+	if bytes.Equal(before, after) {
+		return nil
+	}
+	name := filename
+	if dir != "" {
+		if rel, err := filepath.Rel(dir, filename); err == nil && !strings.HasPrefix(rel, "..") {
+			name = filepath.ToSlash(rel)
+		}
+	}
+	edits, err := myers.ComputeEdits("", string(before), string(after))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, fmt.Sprint(diff.ToUnified(name, name, string(before), edits)))
+	return err
+}
+
+// WriteFile replaces the content of the named file atomically, so that
+// a concurrent reader sees either its old or its new content: it writes
+// a temporary file in the same directory, and renames it to the file.
+// The new file has the permissions of the old one, if it exists, and
+// replaces the target of a symbolic link, not the link.
+func WriteFile(filename string, content []byte) error {
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(filename); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package edits_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/edits"
+)
+
+func TestAdd(t *testing.T) {
+	var set edits.Set
+	set.SetContent("a.go", []byte("0123456789"))
+	for _, test := range []struct {
+		edits    []edits.Edit
+		conflict bool
+	}{
+		{[]edits.Edit{{"a.go", 1, 3, "x"}}, false},
+		{[]edits.Edit{{"a.go", 1, 3, "x"}, {"a.go", 5, 5, "y"}}, false}, // the first is redundant
+		{[]edits.Edit{{"a.go", 2, 4, "z"}}, true},                       // overlaps 1-3
+		{[]edits.Edit{{"a.go", 5, 5, "w"}}, true},                       // insertion at the same offset
+		{[]edits.Edit{{"a.go", 7, 8, "v"}, {"a.go", 6, 9, "u"}}, true},  // conflicts with itself
+		{[]edits.Edit{{"a.go", 3, 5, ""}}, false},                       // adjacent
+	} {
+		err := set.Add(test.edits...)
+		if _, ok := err.(*edits.ConflictError); ok != test.conflict {
+			t.Errorf("Add(%v) = %v, want conflict %t", test.edits, err, test.conflict)
+		}
+	}
+	_, after, err := set.Content("a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(after), "0xy56789"; got != want {
+		t.Errorf("got content %q, want %q", got, want)
+	}
+}
+
+func TestBeyondEnd(t *testing.T) {
+	var set edits.Set
+	set.SetContent("a.go", []byte("012"))
+	if err := set.Add(edits.Edit{Filename: "a.go", Start: 2, End: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := set.Content("a.go"); err == nil || !strings.Contains(err.Error(), "beyond end of file") {
+		t.Errorf("got error %v, want an edit beyond end of file", err)
+	}
+}
+
+func TestDiffAndApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "sub", "b.txt")
+	if err := os.MkdirAll(filepath.Dir(b), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(a, []byte("package a\nvar  x = 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	set := edits.Set{Format: true}
+	if err := set.Add(
+		edits.Edit{Filename: a, Start: 15, End: 16, New: "y"},
+		edits.Edit{Filename: b, Start: 4, End: 7, New: "2"},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	var diff bytes.Buffer
+	if err := set.Diff(&diff, dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"--- a.go\n+++ a.go\n",
+		"-var  x = 1\n+\n+var y = 1\n", // formatted
+		"--- sub/b.txt\n+++ sub/b.txt\n",
+		"-two\n+2\n",
+	} {
+		if !strings.Contains(diff.String(), want) {
+			t.Errorf("diff does not contain %q:\n%s", want, diff.String())
+		}
+	}
+
+	if err := set.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{
+		a: "package a\n\nvar y = 1\n",
+		b: "one\n2\n",
+	} {
+		got, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", file, got, want)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if fi, err := os.Stat(a); err != nil || fi.Mode().Perm() != 0600 {
+			t.Errorf("got mode %v (%v), want the original mode -rw-------", fi.Mode(), err)
+		}
+	}
+	// No temporary file is left.
+	if names, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(names) > 0 {
+		t.Errorf("temporary files left: %v", names)
+	}
+}
+
+func TestWriteFileSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links are not generally available on Windows")
+	}
+	dir, err := ioutil.TempDir("", "edits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "target.go")
+	link := filepath.Join(dir, "link.go")
+	if err := ioutil.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := edits.WriteFile(link, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the link was replaced (%v)", err)
+	}
+	if got, _ := ioutil.ReadFile(target); string(got) != "new" {
+		t.Errorf("got target content %q, want %q", got, "new")
+	}
+}
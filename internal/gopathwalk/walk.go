@@ -9,12 +9,14 @@ package gopathwalk
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/tools/internal/fastwalk"
@@ -62,10 +64,71 @@ func Walk(roots []Root, add func(root Root, dir string), opts Options) {
 // If skip returns false on a directory it will be processed.
 // add will be called concurrently.
 // skip will be called concurrently.
+//
+// The roots are walked concurrently, except that a root that is within,
+// or contains, an earlier root is walked after it, so that skip can
+// tell the directories of the earlier root from its own.
 func WalkSkip(roots []Root, add func(root Root, dir string), skip func(root Root, dir string) bool, opts Options) {
+	WalkSkipContext(context.Background(), roots, add, skip, opts)
+}
+
+// WalkSkipContext is like WalkSkip, but it starts no more walks once ctx
+// is done, and returns the roots that it walked. The walk of a root is
+// not interrupted, so that add is called for all of its directories:
+// callers such as caches may then treat the returned roots as complete.
+func WalkSkipContext(ctx context.Context, roots []Root, add func(root Root, dir string), skip func(root Root, dir string) bool, opts Options) []Root {
+	var walked []Root
+	for _, wave := range walkOrder(roots) {
+		if ctx.Err() != nil {
+			break
+		}
+		var wg sync.WaitGroup
+		for _, root := range wave {
+			wg.Add(1)
+			go func(root Root) {
+				defer wg.Done()
+				walkDir(root, add, skip, opts)
+			}(root)
+		}
+		wg.Wait()
+		walked = append(walked, wave...)
+	}
+	return walked
+}
+
+// walkOrder divides the roots into successive waves of roots that may
+// be walked concurrently: each root is in the wave after the last one
+// with a root that it is within or that it contains.
+func walkOrder(roots []Root) [][]Root {
+	var waves [][]Root
 	for _, root := range roots {
-		walkDir(root, add, skip, opts)
+		i := 0
+		for j, wave := range waves {
+			for _, r := range wave {
+				if nested(r.Path, root.Path) || nested(root.Path, r.Path) {
+					i = j + 1
+				}
+			}
+		}
+		if i == len(waves) {
+			waves = append(waves, nil)
+		}
+		waves[i] = append(waves[i], root)
+	}
+	return waves
+}
+
+// nested reports whether the directory dir is parent or one of its
+// subdirectories.
+func nested(parent, dir string) bool {
+	parent, dir = filepath.Clean(parent), filepath.Clean(dir)
+	if dir == parent {
+		return true
+	}
+	if !strings.HasSuffix(parent, string(filepath.Separator)) {
+		parent += string(filepath.Separator)
 	}
+	return strings.HasPrefix(dir, parent)
 }
 
 // walkDir creates a walker and starts fastwalk with this walker.
@@ -5,12 +5,14 @@
 package gopathwalk
 
 import (
+	"context"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -155,6 +157,67 @@ func TestSkipFunction(t *testing.T) {
 	}
 }
 
+func TestWalkOrder(t *testing.T) {
+	root := func(path string) Root { return Root{Path: filepath.FromSlash(path)} }
+	roots := []Root{
+		root("/goroot/src"),
+		root("/work/main"),
+		root("/mod/a@v1"),
+		root("/mod/b@v1"),
+		root("/work/main/sub"),
+		root("/mod"),
+		root("/work/mainx"),
+	}
+	want := [][]Root{
+		{root("/goroot/src"), root("/work/main"), root("/mod/a@v1"), root("/mod/b@v1"), root("/work/mainx")},
+		{root("/work/main/sub"), root("/mod")},
+	}
+	if got := walkOrder(roots); !reflect.DeepEqual(got, want) {
+		t.Errorf("walkOrder(%v) = %v, want %v", roots, got, want)
+	}
+}
+
+// TestWalkSkipContext tests that a cancelled walk finishes the roots
+// that it began, and walks no others.
+func TestWalkSkipContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goimports-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := mapToDir(dir, map[string]string{
+		"a/f.go":     "package a",
+		"a/sub/f.go": "package sub",
+		"b/f.go":     "package b",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(dir, "src")
+	a := Root{filepath.Join(src, "a"), RootGOPATH}
+	all := Root{src, RootGOPATH} // contains a, so walked after it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var found []string
+	var mu sync.Mutex
+	walked := WalkSkipContext(ctx, []Root{a, all},
+		func(root Root, dir string) {
+			cancel()
+			mu.Lock()
+			defer mu.Unlock()
+			found = append(found, filepath.ToSlash(dir[len(src)+1:]))
+		}, func(Root, string) bool { return false },
+		Options{ModulesEnabled: true})
+	sort.Strings(found)
+	if want := []string{"a/sub"}; !reflect.DeepEqual(found, want) {
+		t.Errorf("found %v, want %v", found, want)
+	}
+	if want := []Root{a}; !reflect.DeepEqual(walked, want) {
+		t.Errorf("walked %v, want %v", walked, want)
+	}
+}
+
 func mapToDir(destDir string, files map[string]string) error {
 	for path, contents := range files {
 		file := filepath.Join(destDir, "src", path)
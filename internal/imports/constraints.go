@@ -0,0 +1,204 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imports
+
+// This file decides which of the sibling files of the file being fixed
+// may be built together with it, according to their build constraints:
+// their //go:build (or // +build) lines and the GOOS and GOARCH implied
+// by their names. The globals of the other siblings, such as those of a
+// _windows.go file when fixing a _linux.go file, are not visible to the
+// file, and their imports suit other build configurations.
+
+import (
+	"go/ast"
+	"go/build/constraint"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// knownOS and knownArch are the known values of GOOS and GOARCH, as in
+// go/build/syslist.go.
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true,
+	"freebsd": true, "hurd": true, "illumos": true, "ios": true,
+	"js": true, "linux": true, "nacl": true, "netbsd": true,
+	"openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+	"windows": true, "zos": true,
+}
+
+var knownArch = map[string]bool{
+	"386": true, "amd64": true, "amd64p32": true, "arm": true,
+	"armbe": true, "arm64": true, "arm64be": true, "loong64": true,
+	"mips": true, "mipsle": true, "mips64": true, "mips64le": true,
+	"mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+	"ppc64le": true, "riscv": true, "riscv64": true, "s390": true,
+	"s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+}
+
+// unixOS is the set of GOOS values matched by the "unix" build tag.
+var unixOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true,
+	"freebsd": true, "hurd": true, "illumos": true, "ios": true,
+	"linux": true, "netbsd": true, "openbsd": true, "solaris": true,
+}
+
+// maxFreeTags bounds the number of tags other than GOOS and GOARCH
+// values whose settings compatible tries, beyond which it assumes that
+// the files are compatible.
+const maxFreeTags = 8
+
+// fileConstraint returns the build constraint of the file f named
+// filename, or nil if it has none. Only the comments of f that precede
+// its package clause are considered, so f must have been parsed with
+// parser.ParseComments for its //go:build lines to be found.
+func fileConstraint(f *ast.File, filename string) constraint.Expr {
+	var goBuild constraint.Expr
+	var plusBuild []constraint.Expr
+	for _, cg := range f.Comments {
+		if cg.Pos() >= f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			switch {
+			case constraint.IsGoBuild(c.Text):
+				if x, err := constraint.Parse(c.Text); err == nil && goBuild == nil {
+					goBuild = x
+				}
+			case constraint.IsPlusBuild(c.Text):
+				if x, err := constraint.Parse(c.Text); err == nil {
+					plusBuild = append(plusBuild, x)
+				}
+			}
+		}
+	}
+
+	x := goBuild
+	if x == nil {
+		for _, y := range plusBuild {
+			x = and(x, y)
+		}
+	}
+
+	// Add the constraint implied by the name, as go/build.goodOSArchFile does.
+	name := strings.TrimSuffix(filepath.Base(filename), ".go")
+	if i := strings.Index(name, "_"); i >= 0 {
+		l := strings.Split(name[i:], "_")
+		if n := len(l); n > 0 && l[n-1] == "test" {
+			l = l[:n-1]
+		}
+		n := len(l)
+		if n >= 2 && knownOS[l[n-2]] && knownArch[l[n-1]] {
+			x = and(x, &constraint.TagExpr{Tag: l[n-2]})
+			x = and(x, &constraint.TagExpr{Tag: l[n-1]})
+		} else if n >= 1 && (knownOS[l[n-1]] || knownArch[l[n-1]]) {
+			x = and(x, &constraint.TagExpr{Tag: l[n-1]})
+		}
+	}
+	return x
+}
+
+// and returns the conjunction of x, which may be nil, and y.
+func and(x, y constraint.Expr) constraint.Expr {
+	if x == nil {
+		return y
+	}
+	return &constraint.AndExpr{X: x, Y: y}
+}
+
+// compatible reports whether some build configuration satisfies both
+// build constraints x and y, either of which may be nil.
+func compatible(x, y constraint.Expr) bool {
+	switch {
+	case x == nil && y == nil:
+		return true
+	case x == nil:
+		return satisfiable(y)
+	case y == nil:
+		return satisfiable(x)
+	}
+	return satisfiable(and(x, y))
+}
+
+// satisfiable reports whether some build configuration satisfies x. The
+// values of GOOS and GOARCH are exclusive, and the tags that are neither
+// may be set freely: the compiler and release tags are thus treated as
+// independent, which may only make more files compatible.
+func satisfiable(x constraint.Expr) bool {
+	archs := map[string]bool{"": true} // "" stands for the unmentioned ones
+	free := map[string]bool{}
+	collectTags(x, func(tag string) {
+		switch {
+		case knownOS[tag], tag == "unix":
+		case knownArch[tag]:
+			archs[tag] = true
+		default:
+			free[tag] = true
+		}
+	})
+	if len(free) > maxFreeTags {
+		return true
+	}
+	var freeTags []string
+	for tag := range free {
+		freeTags = append(freeTags, tag)
+	}
+	sort.Strings(freeTags)
+
+	for goos := range knownOS {
+		for goarch := range archs {
+			for set := 0; set < 1<<len(freeTags); set++ {
+				ok := x.Eval(func(tag string) bool {
+					switch {
+					case knownOS[tag]:
+						return matchOS(goos, tag)
+					case tag == "unix":
+						return unixOS[goos]
+					case knownArch[tag]:
+						return tag == goarch
+					}
+					i := sort.SearchStrings(freeTags, tag)
+					return set&(1<<i) != 0
+				})
+				if ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// matchOS reports whether the GOOS tag is satisfied when GOOS is goos,
+// as in go/build.
+func matchOS(goos, tag string) bool {
+	switch {
+	case tag == goos:
+		return true
+	case tag == "linux":
+		return goos == "android"
+	case tag == "solaris":
+		return goos == "illumos"
+	case tag == "darwin":
+		return goos == "ios"
+	}
+	return false
+}
+
+// collectTags calls fn for each tag of x.
+func collectTags(x constraint.Expr, fn func(string)) {
+	switch x := x.(type) {
+	case *constraint.TagExpr:
+		fn(x.Tag)
+	case *constraint.NotExpr:
+		collectTags(x.X, fn)
+	case *constraint.AndExpr:
+		collectTags(x.X, fn)
+		collectTags(x.Y, fn)
+	case *constraint.OrExpr:
+		collectTags(x.X, fn)
+		collectTags(x.Y, fn)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imports
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestCompatibleConstraints(t *testing.T) {
+	parse := func(src, filename string) *ast.File {
+		f, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	for _, test := range []struct {
+		x, xname, y, yname string
+		want               bool
+	}{
+		{"", "a.go", "", "b.go", true},
+		{"", "a_linux.go", "", "b_windows.go", false},
+		{"", "a_linux.go", "", "b_linux_amd64.go", true},
+		{"", "a_linux_arm64.go", "", "b_amd64.go", false},
+		{"", "a_linux_test.go", "", "b_android.go", true},
+		{"", "a_linux.go", "//go:build unix\n", "b.go", true},
+		{"", "a_windows.go", "//go:build unix\n", "b.go", false},
+		{"", "a_windows.go", "//go:build !windows\n", "b.go", false},
+		{"//go:build purego\n", "a.go", "//go:build !purego\n", "b.go", false},
+		{"//go:build purego\n", "a.go", "//go:build purego || amd64\n", "b.go", true},
+		{"// +build linux darwin\n", "a.go", "// +build windows\n", "b.go", false},
+		{"// +build linux darwin\n", "a.go", "// +build darwin\n", "b.go", true},
+		{"//go:build (linux && cgo) || plan9\n", "a.go", "//go:build !plan9 && !cgo\n", "b.go", false},
+	} {
+		x := fileConstraint(parse(test.x+"\npackage p", test.xname), test.xname)
+		y := fileConstraint(parse(test.y+"\npackage p", test.yname), test.yname)
+		if got := compatible(x, y); got != test.want {
+			t.Errorf("compatible(%q in %s, %q in %s) = %t, want %t", test.x, test.xname, test.y, test.yname, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imports
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The persistent cache of exports records the package name and the
+// exports of each directory whose exports have been loaded, so that a
+// later process, such as the next run of goimports, that looks for the
+// package of an unknown identifier need not parse its files again.
+//
+// An entry is keyed by the directory and the build context that selects
+// its files, and records the state of the directory: the names, sizes
+// and modification times of its Go files. It is valid as long as the
+// directory has the same state. A file modified during the last few
+// seconds could be modified again without a change of its modification
+// time, so the exports of a directory with such a file are not cached.
+//
+// The cache lives in the IndexDir of the ProcessEnv, as the index of the
+// module cache does (see mod_index.go), and is shared by the processes
+// that use it.
+
+// exportsCacheVersion is the version of the format of the entries,
+// which must be incremented when it changes.
+const exportsCacheVersion = 1
+
+// recentModification is the age under which the modification of a file
+// prevents the caching of the exports of its directory.
+const recentModification = 2 * time.Second
+
+// An exportsCacheEntry is the encoded form of an entry of the cache.
+type exportsCacheEntry struct {
+	Version int
+	Dir     string
+	State   string // the hash of the state of the directory
+	Name    string
+	Exports []string
+}
+
+// exportsCacheFile returns the file of the entry of the exports of dir,
+// including those of its test files if includeTest.
+func (e *ProcessEnv) exportsCacheFile(dir string, includeTest bool) (string, error) {
+	bctx, err := e.buildContext()
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s\x00%t\x00%s/%s\x00%t\x00%s\x00%s", dir, includeTest,
+		bctx.GOOS, bctx.GOARCH, bctx.CgoEnabled,
+		strings.Join(bctx.BuildTags, ","), strings.Join(bctx.ReleaseTags, ","))
+	h := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+	return filepath.Join(e.IndexDir, "exports", h[:2], h[:32]+".json"), nil
+}
+
+// dirState returns the hash of the state of the directory whose entries
+// are files, or "" if it must not be cached.
+func dirState(files []os.FileInfo) string {
+	var gofiles []os.FileInfo
+	for _, fi := range files {
+		if strings.HasSuffix(fi.Name(), ".go") {
+			gofiles = append(gofiles, fi)
+		}
+	}
+	sort.Slice(gofiles, func(i, j int) bool { return gofiles[i].Name() < gofiles[j].Name() })
+	recent := time.Now().Add(-recentModification)
+	h := sha256.New()
+	for _, fi := range gofiles {
+		if fi.ModTime().After(recent) {
+			return ""
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", fi.Name(), fi.Size(), fi.ModTime().UnixNano())
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// readExportsCache returns the cached package name and exports of dir,
+// whose state is state, if the cache has them.
+func (e *ProcessEnv) readExportsCache(dir, state string, includeTest bool) (string, []string, bool) {
+	file, err := e.exportsCacheFile(dir, includeTest)
+	if err != nil {
+		return "", nil, false
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", nil, false
+	}
+	var entry exportsCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Version != exportsCacheVersion ||
+		entry.Dir != dir || entry.State != state {
+		return "", nil, false
+	}
+	return entry.Name, entry.Exports, true
+}
+
+// writeExportsCache records the package name and exports of dir, whose
+// state is state, in the cache.
+func (e *ProcessEnv) writeExportsCache(dir, state string, includeTest bool, name string, exports []string) error {
+	file, err := e.exportsCacheFile(dir, includeTest)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&exportsCacheEntry{
+		Version: exportsCacheVersion,
+		Dir:     dir,
+		State:   state,
+		Name:    name,
+		Exports: exports,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write the entry atomically, as other processes may be reading it.
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), "exports-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
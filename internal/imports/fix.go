@@ -99,7 +99,8 @@ type packageInfo struct {
 }
 
 // parseOtherFiles parses all the Go files in srcDir except filename, including
-// test files if filename looks like a test.
+// test files if filename looks like a test. Their comments are retained, for
+// their build constraints.
 func parseOtherFiles(fset *token.FileSet, srcDir, filename string) []*ast.File {
 	// This could use go/packages but it doesn't buy much, and it fails
 	// with https://golang.org/issue/26296 in LoadFiles mode in some cases.
@@ -120,7 +121,7 @@ func parseOtherFiles(fset *token.FileSet, srcDir, filename string) []*ast.File {
 			continue
 		}
 
-		f, err := parser.ParseFile(fset, filepath.Join(srcDir, fi.Name()), nil, 0)
+		f, err := parser.ParseFile(fset, filepath.Join(srcDir, fi.Name()), nil, parser.ParseComments)
 		if err != nil {
 			continue
 		}
@@ -249,7 +250,8 @@ type pass struct {
 	srcDir               string         // the directory containing f.
 	env                  *ProcessEnv    // the environment to use for go commands, etc.
 	loadRealPackageNames bool           // if true, load package names from disk rather than guessing them.
-	otherFiles           []*ast.File    // sibling files.
+	otherFiles           []*ast.File    // sibling files that may be built with f.
+	otherConfigFiles     []*ast.File    // sibling files that are never built with f.
 
 	// Intermediate state, generated by load.
 	existingImports map[string]*ImportInfo
@@ -334,12 +336,19 @@ func (p *pass) load() ([]*ImportFix, bool) {
 		}
 		p.candidates = append(p.candidates, collectImports(otherFile)...)
 	}
+	// The imports of the siblings for other build configurations only
+	// become candidates later, but their names are loaded now.
+	var otherConfigImports []*ImportInfo
+	for _, otherFile := range p.otherConfigFiles {
+		otherConfigImports = append(otherConfigImports, collectImports(otherFile)...)
+	}
 
 	// Resolve all the import paths we've seen to package names, and store
 	// f's imports by the identifier they introduce.
 	imports := collectImports(p.f)
 	if p.loadRealPackageNames {
-		err := p.loadPackageNames(append(imports, p.candidates...))
+		all := append(append(imports, p.candidates...), otherConfigImports...)
+		err := p.loadPackageNames(all)
 		if err != nil {
 			if p.env.Logf != nil {
 				p.env.Logf("loading package names: %v", err)
@@ -470,10 +479,10 @@ func apply(fset *token.FileSet, f *ast.File, fixes []*ImportFix) {
 	}
 }
 
-// assumeSiblingImportsValid assumes that siblings' use of packages is valid,
-// adding the exports they use.
-func (p *pass) assumeSiblingImportsValid() {
-	for _, f := range p.otherFiles {
+// assumeSiblingImportsValid assumes that the use of packages by the sibling
+// files is valid, adding the exports they use.
+func (p *pass) assumeSiblingImportsValid(files []*ast.File) {
+	for _, f := range files {
 		refs := collectReferences(f)
 		imports := collectImports(f)
 		importsByName := map[string]*ImportInfo{}
@@ -548,18 +557,33 @@ func getFixes(fset *token.FileSet, f *ast.File, filename string, env *ProcessEnv
 		return fixes, nil
 	}
 
-	otherFiles := parseOtherFiles(fset, srcDir, filename)
+	// The siblings of f that are never built with it, because of their
+	// build constraints, such as those for other values of GOOS, don't
+	// provide it with globals. Their imports suit other configurations,
+	// so they are considered after those of the stdlib, but still before
+	// the other packages, to keep the files of a package consistent.
+	var otherFiles, otherConfigFiles []*ast.File
+	fileCons := fileConstraint(f, filename)
+	for _, other := range parseOtherFiles(fset, srcDir, filename) {
+		if compatible(fileCons, fileConstraint(other, fset.File(other.Pos()).Name())) {
+			otherFiles = append(otherFiles, other)
+		} else {
+			otherConfigFiles = append(otherConfigFiles, other)
+		}
+	}
 
 	// Second pass: add information from other files in the same package,
 	// like their package vars and imports.
 	p.otherFiles = otherFiles
+	p.otherConfigFiles = otherConfigFiles
 	if fixes, done := p.load(); done {
 		return fixes, nil
 	}
 
 	// Now we can try adding imports from the stdlib.
-	p.assumeSiblingImportsValid()
+	p.assumeSiblingImportsValid(p.otherFiles)
 	addStdlibCandidates(p, p.missingRefs)
+	p.assumeSiblingImportsValid(p.otherConfigFiles)
 	if fixes, done := p.fix(); done {
 		return fixes, nil
 	}
@@ -569,6 +593,7 @@ func getFixes(fset *token.FileSet, f *ast.File, filename string, env *ProcessEnv
 	p = &pass{fset: fset, f: f, srcDir: srcDir, env: env}
 	p.loadRealPackageNames = true
 	p.otherFiles = otherFiles
+	p.otherConfigFiles = otherConfigFiles
 	if fixes, done := p.load(); done {
 		return fixes, nil
 	}
@@ -576,7 +601,8 @@ func getFixes(fset *token.FileSet, f *ast.File, filename string, env *ProcessEnv
 	if err := addStdlibCandidates(p, p.missingRefs); err != nil {
 		return nil, err
 	}
-	p.assumeSiblingImportsValid()
+	p.assumeSiblingImportsValid(p.otherFiles)
+	p.assumeSiblingImportsValid(p.otherConfigFiles)
 	if fixes, done := p.fix(); done {
 		return fixes, nil
 	}
@@ -820,6 +846,20 @@ type ProcessEnv struct {
 
 	WorkingDir string
 
+	// Offline, if set, prevents any invocation of the go command, and so
+	// any access to the network. The environment is then derived from
+	// Env, the OS environment and the go env file, and the modules from
+	// the go.mod and go.work files, rather than from `go env` and
+	// `go list -m`.
+	Offline bool
+
+	// IndexDir, if non-empty, is the directory of a persistent index of
+	// the packages of the module cache, and of a cache of the exports of
+	// packages, which are shared by the processes that use them, so that
+	// the module cache need not be walked, nor the packages parsed, each
+	// time a process starts.
+	IndexDir string
+
 	// If Logf is non-nil, debug logging is enabled through this function.
 	Logf func(format string, args ...interface{})
 
@@ -851,6 +891,8 @@ func (e *ProcessEnv) CopyConfig() *ProcessEnv {
 		BuildFlags:  e.BuildFlags,
 		Logf:        e.Logf,
 		WorkingDir:  e.WorkingDir,
+		Offline:     e.Offline,
+		IndexDir:    e.IndexDir,
 		resolver:    nil,
 		Env:         map[string]string{},
 	}
@@ -881,6 +923,14 @@ func (e *ProcessEnv) init() error {
 		e.Env = map[string]string{}
 	}
 
+	if e.Offline {
+		if err := e.initOffline(); err != nil {
+			return err
+		}
+		e.initialized = true
+		return nil
+	}
+
 	goEnv := map[string]string{}
 	stdout, err := e.invokeGo(context.TODO(), "env", append([]string{"-json"}, RequiredGoEnvVars...)...)
 	if err != nil {
@@ -1442,6 +1492,17 @@ func loadExportsFromFiles(ctx context.Context, env *ProcessEnv, dir string, incl
 	if err != nil {
 		return "", nil, err
 	}
+	var state string
+	if env.IndexDir != "" {
+		if state = dirState(all); state != "" {
+			if pkgName, exports, ok := env.readExportsCache(dir, state, includeTest); ok {
+				if env.Logf != nil {
+					env.Logf("loaded cached exports in dir %v (package %v)", dir, pkgName)
+				}
+				return pkgName, exports, nil
+			}
+		}
+	}
 	var files []os.FileInfo
 	for _, fi := range all {
 		name := fi.Name()
@@ -1499,6 +1560,11 @@ func loadExportsFromFiles(ctx context.Context, env *ProcessEnv, dir string, incl
 		sort.Strings(sortedExports)
 		env.Logf("loaded exports in dir %v (package %v): %v", dir, pkgName, strings.Join(sortedExports, ", "))
 	}
+	if state != "" && pkgName != "" {
+		if err := env.writeExportsCache(dir, state, includeTest, pkgName, exports); err != nil && env.Logf != nil {
+			env.Logf("caching exports of dir %v: %v", dir, err)
+		}
+	}
 	return pkgName, exports, nil
 }
 
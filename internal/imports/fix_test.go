@@ -2006,6 +2006,196 @@ const _ = runtime.GOOS
 	}
 }
 
+// Tests the Group, Regroup and Rewrite options.
+func TestGroupingOptions(t *testing.T) {
+	// Groups: the standard library, then the packages of the
+	// organization, then the others.
+	group := func(path string) int {
+		switch {
+		case !strings.Contains(strings.Split(path, "/")[0], "."):
+			return 0
+		case strings.HasPrefix(path, "example.org/"):
+			return 1
+		}
+		return 2
+	}
+	tests := []struct {
+		name       string
+		group      func(string) int
+		regroup    bool
+		rewrite    map[string]string
+		formatOnly bool
+		src        string
+		want       string
+	}{
+		{
+			name:       "group",
+			group:      group,
+			formatOnly: true,
+			src: `package main
+
+import (
+	"foo.com/a"
+	"example.org/b"
+	"fmt"
+)
+`,
+			want: `package main
+
+import (
+	"fmt"
+
+	"example.org/b"
+
+	"foo.com/a"
+)
+`,
+		},
+		{
+			name:       "runs_kept",
+			group:      group,
+			formatOnly: true,
+			src: `package main
+
+import (
+	"foo.com/a"
+	"fmt"
+
+	"example.org/b"
+	"os"
+)
+`,
+			want: `package main
+
+import (
+	"fmt"
+
+	"foo.com/a"
+
+	"os"
+
+	"example.org/b"
+)
+`,
+		},
+		{
+			name:       "regroup",
+			group:      group,
+			regroup:    true,
+			formatOnly: true,
+			src: `package main
+
+import (
+	"foo.com/a"
+	"fmt"
+
+	"example.org/b"
+	"os" // for Exit
+)
+`,
+			want: `package main
+
+import (
+	"fmt"
+	"os" // for Exit
+
+	"example.org/b"
+
+	"foo.com/a"
+)
+`,
+		},
+		{
+			name:       "regroup_default_groups",
+			regroup:    true,
+			formatOnly: true,
+			src: `package main
+
+import (
+	"foo.com/a"
+
+	"fmt"
+
+	"os"
+)
+`,
+			want: `package main
+
+import (
+	"fmt"
+	"os"
+
+	"foo.com/a"
+)
+`,
+		},
+		{
+			name:       "rewrite",
+			rewrite:    map[string]string{"github.com/old/yaml": "example.org/yaml", "github.com/old/errs": "example.org/errors"},
+			formatOnly: true,
+			src: `package main
+
+import (
+	"fmt"
+
+	e "github.com/old/errs"
+	"github.com/old/errs"
+	"github.com/old/yaml"
+)
+`,
+			want: `package main
+
+import (
+	"fmt"
+
+	e "example.org/errors"
+	errs "example.org/errors"
+	"example.org/yaml"
+)
+`,
+		},
+		{
+			name:    "rewrite_added",
+			rewrite: map[string]string{"foo.com/bar": "example.org/bar"},
+			src:     "package main \n const Y = bar.X",
+			want: `package main
+
+import "example.org/bar"
+
+const Y = bar.X
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testConfig{
+				modules: []packagestest.Module{
+					{
+						Name:  "test.com",
+						Files: fm{"t.go": tt.src},
+					},
+					{
+						Name:  "foo.com",
+						Files: fm{"bar/bar.go": "package bar \n const X = 1"},
+					},
+				},
+			}.test(t, func(t *goimportTest) {
+				options := &Options{
+					Group:      tt.group,
+					Regroup:    tt.regroup,
+					Rewrite:    tt.rewrite,
+					TabWidth:   8,
+					TabIndent:  true,
+					Comments:   true,
+					FormatOnly: tt.formatOnly,
+				}
+				t.assertProcessEquals("test.com", "t.go", nil, options, tt.want)
+			})
+		})
+	}
+}
+
 // Tests that "package documentation" files are ignored.
 func TestIgnoreDocumentationPackage(t *testing.T) {
 	const input = `package x
@@ -2264,6 +2454,71 @@ func LogSomethingElse() {
 	}.processTest(t, "foo.com", "p/needs_import.go", nil, nil, want)
 }
 
+// Tests that sibling files for other build configurations provide neither
+// globals nor preferred imports, but are still used to keep the imports of
+// the files of a package consistent.
+func TestSiblingImports_BuildConstraints(t *testing.T) {
+	// windows is never built with the linux file: its global errors and
+	// its log import don't apply there.
+	const windows = `package p
+
+import log "foo.com/winlog"
+
+var errors = log.Errors()
+
+func _() { log.Print() }
+`
+	// unix is built with the linux file, and provides its global fs.
+	const unix = `//go:build unix
+
+package p
+
+var fs = 1
+`
+	// darwin is never built with the linux file, but its import of
+	// unix is the only one there is.
+	const darwin = `package p
+
+import "foo.com/sys/unix"
+
+func _() { unix.Getpid() }
+`
+	const input = `package p
+
+func _() {
+	log.Print(errors.New("x"), fs)
+	unix.Getpid()
+}
+`
+	const want = `package p
+
+import (
+	"errors"
+	"log"
+
+	"foo.com/sys/unix"
+)
+
+func _() {
+	log.Print(errors.New("x"), fs)
+	unix.Getpid()
+}
+`
+
+	testConfig{
+		module: packagestest.Module{
+			Name: "foo.com",
+			Files: fm{
+				"p/a_windows.go": windows,
+				"p/a_unix.go":    unix,
+				"p/a_darwin.go":  darwin,
+				"p/a_linux.go":   input,
+				"winlog/log.go":  "package log\nfunc Errors() error { return nil }\nfunc Print() {}\n",
+			},
+		},
+	}.processTest(t, "foo.com", "p/a_linux.go", nil, nil, want)
+}
+
 // Tests #29180: a sibling import of the right package with the wrong name is used.
 func TestSiblingImport_Misnamed(t *testing.T) {
 	const sibling = `package main
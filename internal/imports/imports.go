@@ -34,6 +34,23 @@ type Options struct {
 	// into another group after 3rd-party packages.
 	LocalPrefix string
 
+	// Group, if not nil, returns the group of an import path, in place
+	// of the default grouping: the standard library, then third-party
+	// packages, then those of LocalPrefix. The imports are sorted by
+	// group, then by path, and the groups are separated by blank lines.
+	Group func(importPath string) int
+
+	// Regroup causes the imports to be grouped anew, ignoring the blank
+	// lines of the source, which otherwise delimit runs of imports that
+	// are sorted separately.
+	Regroup bool
+
+	// Rewrite maps the paths of banned imports to the paths of the
+	// packages to import in their place, which must provide the same
+	// API. An import whose package would be renamed by the rewrite is
+	// given the name that its path implied.
+	Rewrite map[string]string
+
 	Fragment  bool // Accept fragment of a source file (no package statement)
 	AllErrors bool // Report all errors (not just the first 10 on different lines)
 
@@ -103,6 +120,32 @@ func ApplyFixes(fixes []*ImportFix, filename string, src []byte, opt *Options, e
 	return formatFile(fileSet, file, src, nil, opt)
 }
 
+// group returns the group of the import path.
+func (opt *Options) group(importPath string) int {
+	if opt.Group != nil {
+		return opt.Group(importPath)
+	}
+	return importGroup(opt.LocalPrefix, importPath)
+}
+
+// rewriteImports replaces the imports of f whose paths are keys of
+// rewrite by imports of the corresponding values.
+func rewriteImports(f *ast.File, rewrite map[string]string) {
+	for _, imp := range f.Imports {
+		from := importPath(imp)
+		to, ok := rewrite[from]
+		if !ok || to == from {
+			continue
+		}
+		if imp.Name == nil {
+			if name := ImportPathToAssumedName(from); name != ImportPathToAssumedName(to) {
+				imp.Name = &ast.Ident{NamePos: imp.Path.Pos(), Name: name}
+			}
+		}
+		imp.Path.Value = strconv.Quote(to)
+	}
+}
+
 // formatFile formats the file syntax tree.
 // It may mutate the token.FileSet.
 //
@@ -110,8 +153,9 @@ func ApplyFixes(fixes []*ImportFix, filename string, src []byte, opt *Options, e
 // with the original source (formatFile's src parameter) and the
 // formatted file, and returns the postpocessed result.
 func formatFile(fset *token.FileSet, file *ast.File, src []byte, adjust func(orig []byte, src []byte) []byte, opt *Options) ([]byte, error) {
+	rewriteImports(file, opt.Rewrite)
 	mergeImports(file)
-	sortImports(opt.LocalPrefix, fset.File(file.Pos()), file)
+	sortImports(opt.group, opt.Regroup, fset.File(file.Pos()), file)
 	var spacesBefore []string // import paths we need spaces before
 	for _, impSection := range astutil.Imports(fset, file) {
 		// Within each block of contiguous imports, see if any
@@ -121,7 +165,7 @@ func formatFile(fset *token.FileSet, file *ast.File, src []byte, adjust func(ori
 		lastGroup := -1
 		for _, importSpec := range impSection {
 			importPath, _ := strconv.Unquote(importSpec.Path.Value)
-			groupNum := importGroup(opt.LocalPrefix, importPath)
+			groupNum := opt.group(importPath)
 			if groupNum != lastGroup && lastGroup != -1 {
 				spacesBefore = append(spacesBefore, importPath)
 			}
@@ -42,6 +42,11 @@ type ModuleResolver struct {
 	// moduleCacheCache stores information about the module cache.
 	moduleCacheCache *dirInfoCache
 	otherCache       *dirInfoCache
+
+	// indexDirs maps the directories of the module cache that the
+	// persistent index was built from to their modification times, or
+	// is nil if there is no index.
+	indexDirs map[string]int64
 }
 
 func newModuleResolver(e *ProcessEnv) *ModuleResolver {
@@ -71,12 +76,28 @@ func (r *ModuleResolver) init() error {
 		WorkingDir: r.env.WorkingDir,
 	}
 
+	if gmc := r.env.Env["GOMODCACHE"]; gmc != "" {
+		r.moduleCacheDir = gmc
+	} else {
+		gopaths := filepath.SplitList(goenv["GOPATH"])
+		if len(gopaths) == 0 {
+			return fmt.Errorf("empty GOPATH")
+		}
+		r.moduleCacheDir = filepath.Join(gopaths[0], "/pkg/mod")
+	}
+
 	vendorEnabled := false
 	var mainModVendor *gocommand.ModuleJSON
 
-	// Module vendor directories are ignored in workspace mode:
-	// https://go.googlesource.com/proposal/+/master/design/45713-workspace.md
-	if len(r.env.Env["GOWORK"]) == 0 {
+	if r.env.Offline {
+		// Read the go.mod and go.work files instead of running go list.
+		vendorEnabled, mainModVendor, err = r.initModsOffline()
+		if err != nil {
+			return err
+		}
+	} else if len(r.env.Env["GOWORK"]) == 0 {
+		// Module vendor directories are ignored in workspace mode:
+		// https://go.googlesource.com/proposal/+/master/design/45713-workspace.md
 		vendorEnabled, mainModVendor, err = gocommand.VendorEnabled(context.TODO(), inv, r.env.GocmdRunner)
 		if err != nil {
 			return err
@@ -93,7 +114,7 @@ func (r *ModuleResolver) init() error {
 		}
 		r.modsByModPath = []*gocommand.ModuleJSON{mainModVendor, r.dummyVendorMod}
 		r.modsByDir = []*gocommand.ModuleJSON{mainModVendor, r.dummyVendorMod}
-	} else {
+	} else if !r.env.Offline {
 		// Vendor mode is off, so run go list -m ... to find everything.
 		err := r.initAllMods()
 		// We expect an error when running outside of a module with
@@ -105,16 +126,6 @@ func (r *ModuleResolver) init() error {
 		}
 	}
 
-	if gmc := r.env.Env["GOMODCACHE"]; gmc != "" {
-		r.moduleCacheDir = gmc
-	} else {
-		gopaths := filepath.SplitList(goenv["GOPATH"])
-		if len(gopaths) == 0 {
-			return fmt.Errorf("empty GOPATH")
-		}
-		r.moduleCacheDir = filepath.Join(gopaths[0], "/pkg/mod")
-	}
-
 	sort.Slice(r.modsByModPath, func(i, j int) bool {
 		count := func(x int) int {
 			return strings.Count(r.modsByModPath[x].Path, "/")
@@ -174,6 +185,9 @@ func (r *ModuleResolver) init() error {
 			listeners: map[*int]cacheListener{},
 		}
 	}
+	if r.env.IndexDir != "" && r.indexDirs == nil {
+		r.loadIndex()
+	}
 	r.initialized = true
 	return nil
 }
@@ -222,6 +236,7 @@ func (r *ModuleResolver) ClearForNewMod() {
 		env:              r.env,
 		moduleCacheCache: r.moduleCacheCache,
 		otherCache:       r.otherCache,
+		indexDirs:        r.indexDirs,
 		scanSema:         r.scanSema,
 	}
 	r.init()
@@ -506,17 +521,39 @@ func (r *ModuleResolver) scan(ctx context.Context, callback *scanCallback) error
 		}
 		defer func() { r.scanSema <- struct{}{} }()
 		// We have the lock on r.scannedRoots, and no other scans can run.
-		for _, root := range roots {
-			if ctx.Err() != nil {
-				return
-			}
-
+		// The roots are walked concurrently, except for the module cache
+		// when it is indexed, which is walked last, as it holds the other
+		// roots of the module cache.
+		indexFresh := r.indexFresh()
+		var walk []gopathwalk.Root
+		var modCache *gopathwalk.Root
+		for i, root := range roots {
 			if r.scannedRoots[root] {
 				continue
 			}
-			gopathwalk.WalkSkip([]gopathwalk.Root{root}, add, skip, gopathwalk.Options{Logf: r.env.Logf, ModulesEnabled: true})
+			switch {
+			case root.Type == gopathwalk.RootModuleCache && indexFresh && r.dirInModuleCache(root.Path):
+				// The index holds every package of the module cache.
+				r.scannedRoots[root] = true
+			case root.Type == gopathwalk.RootModuleCache && root.Path == r.moduleCacheDir && r.env.IndexDir != "":
+				modCache = &roots[i]
+			default:
+				walk = append(walk, root)
+			}
+		}
+		// The walks that have begun are not cancelled, as explained
+		// above, but no new one starts once ctx is done.
+		walked := gopathwalk.WalkSkipContext(ctx, walk, add, skip, gopathwalk.Options{Logf: r.env.Logf, ModulesEnabled: true})
+		for _, root := range walked {
 			r.scannedRoots[root] = true
 		}
+		if ctx.Err() != nil {
+			return
+		}
+		if modCache != nil {
+			r.walkModuleCache(*modCache, add, skip)
+			r.scannedRoots[*modCache] = true
+		}
 		close(scanDone)
 	}()
 	select {
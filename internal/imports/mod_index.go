@@ -0,0 +1,227 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imports
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/internal/gopathwalk"
+)
+
+// The persistent index of the module cache records the packages found
+// by a walk of the module cache, so that a process, such as goimports,
+// that must otherwise walk the whole module cache to find the packages
+// it may import can read them from a single file.
+//
+// Because the contents of a module in the module cache never change
+// (see mod_cache.go), the index can only be made stale by the addition
+// or removal of a module. Either changes the modification time of the
+// directory that holds the module, so the index records the
+// modification times of the directories of the module cache above the
+// modules, and is fresh as long as none of them have changed. A stale
+// index is still used, for the modules that remain, and the walk that
+// follows adds only the new modules to it.
+//
+// The index describes the module cache, not the modules in scope: the
+// packages it records are canonicalized by the resolver as the
+// packages found by a walk are.
+
+// modIndexVersion is the version of the format of the index, which
+// must be incremented when it changes.
+const modIndexVersion = 1
+
+// A modIndex is the encoded form of the index.
+type modIndex struct {
+	Version  int
+	Root     string           // the module cache directory
+	Dirs     map[string]int64 // the directories above the modules, relative to Root, to their modification times
+	Packages []modIndexPackage
+}
+
+// A modIndexPackage records a directoryPackageInfo of the module cache.
+type modIndexPackage struct {
+	Dir        string // relative to Root
+	ImportPath string // the non-canonical import path
+	ModuleDir  string // relative to Root
+	ModuleName string
+	Name       string `json:",omitempty"` // the package name, if it was loaded
+}
+
+// indexFile returns the file of the index of r's module cache.
+func (r *ModuleResolver) indexFile() string {
+	h := sha256.Sum256([]byte(r.moduleCacheDir))
+	return filepath.Join(r.env.IndexDir, fmt.Sprintf("modcache-%x.json", h[:8]))
+}
+
+// loadIndex adds the packages of the index, if there is one, to the
+// cache of the module cache.
+func (r *ModuleResolver) loadIndex() {
+	data, err := ioutil.ReadFile(r.indexFile())
+	if err != nil {
+		return // no index yet
+	}
+	var idx modIndex
+	if err := json.Unmarshal(data, &idx); err != nil || idx.Version != modIndexVersion || idx.Root != r.moduleCacheDir {
+		if r.env.Logf != nil {
+			r.env.Logf("ignoring module cache index %s", r.indexFile())
+		}
+		return
+	}
+	abs := func(rel string) string { return filepath.Join(idx.Root, filepath.FromSlash(rel)) }
+
+	fresh := dirsUnchanged(idx.Root, idx.Dirs)
+	exists := make(map[string]bool) // module directory -> whether it exists
+	for _, p := range idx.Packages {
+		dir, modDir := abs(p.Dir), abs(p.ModuleDir)
+		if !fresh {
+			// The module may have been removed.
+			ok, seen := exists[modDir]
+			if !seen {
+				_, err := os.Stat(modDir)
+				ok = err == nil
+				exists[modDir] = ok
+			}
+			if !ok {
+				continue
+			}
+		}
+		if _, ok := r.moduleCacheCache.Load(dir); ok {
+			continue // already known, perhaps in more detail
+		}
+		info := directoryPackageInfo{
+			status:                 directoryScanned,
+			dir:                    dir,
+			rootType:               gopathwalk.RootModuleCache,
+			nonCanonicalImportPath: p.ImportPath,
+			moduleDir:              modDir,
+			moduleName:             p.ModuleName,
+		}
+		if p.Name != "" {
+			info.status = nameLoaded
+			info.packageName = p.Name
+		}
+		r.moduleCacheCache.Store(dir, info)
+	}
+	r.indexDirs = idx.Dirs
+}
+
+// indexFresh reports whether r has an index that records every package
+// of the module cache.
+func (r *ModuleResolver) indexFresh() bool {
+	return r.indexDirs != nil && dirsUnchanged(r.moduleCacheDir, r.indexDirs)
+}
+
+// dirsUnchanged reports whether the directories, relative to root,
+// still have the modification times that dirs records.
+func dirsUnchanged(root string, dirs map[string]int64) bool {
+	for rel, mtime := range dirs {
+		fi, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || fi.ModTime().UnixNano() != mtime {
+			return false
+		}
+	}
+	return true
+}
+
+// walkModuleCache walks the module cache root as scan walks the other
+// roots, and then writes the index of the packages of the module cache.
+func (r *ModuleResolver) walkModuleCache(root gopathwalk.Root, add func(gopathwalk.Root, string), skip func(gopathwalk.Root, string) bool) {
+	var mu sync.Mutex
+	dirs := make(map[string]int64)
+	record := func(dir string) {
+		rel, err := filepath.Rel(root.Path, dir)
+		if err != nil || strings.Contains(rel, "@") {
+			return // within a module, which does not change
+		}
+		// Stat the directory before it is read, so that a module added
+		// during the walk makes the index stale.
+		if fi, err := os.Stat(dir); err == nil {
+			mu.Lock()
+			dirs[filepath.ToSlash(rel)] = fi.ModTime().UnixNano()
+			mu.Unlock()
+		}
+	}
+	record(root.Path)
+	recordSkip := func(root gopathwalk.Root, dir string) bool {
+		record(dir)
+		return skip(root, dir)
+	}
+	gopathwalk.WalkSkip([]gopathwalk.Root{root}, add, recordSkip, gopathwalk.Options{Logf: r.env.Logf, ModulesEnabled: true})
+
+	r.indexDirs = dirs
+	if err := r.saveIndex(); err != nil && r.env.Logf != nil {
+		r.env.Logf("writing module cache index: %v", err)
+	}
+}
+
+// saveIndex writes the index of the packages of the module cache that
+// are in r's cache.
+func (r *ModuleResolver) saveIndex() error {
+	idx := modIndex{
+		Version: modIndexVersion,
+		Root:    r.moduleCacheDir,
+		Dirs:    r.indexDirs,
+	}
+	rel := func(dir string) string {
+		if rel, err := filepath.Rel(idx.Root, dir); err == nil {
+			return filepath.ToSlash(rel)
+		}
+		return ""
+	}
+	for _, dir := range r.moduleCacheCache.Keys() {
+		info, ok := r.moduleCacheCache.Load(dir)
+		if !ok || !r.dirInModuleCache(dir) {
+			continue
+		}
+		if scanned, err := info.reachedStatus(directoryScanned); !scanned || err != nil {
+			continue
+		}
+		p := modIndexPackage{
+			Dir:        rel(info.dir),
+			ImportPath: info.nonCanonicalImportPath,
+			ModuleDir:  rel(info.moduleDir),
+			ModuleName: info.moduleName,
+		}
+		if loaded, err := info.reachedStatus(nameLoaded); loaded && err == nil {
+			p.Name = info.packageName
+		}
+		idx.Packages = append(idx.Packages, p)
+	}
+	sort.Slice(idx.Packages, func(i, j int) bool {
+		return idx.Packages[i].Dir < idx.Packages[j].Dir
+	})
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	// Write the index atomically, as other processes may be reading it.
+	if err := os.MkdirAll(r.env.IndexDir, 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(r.env.IndexDir, "modcache-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), r.indexFile())
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
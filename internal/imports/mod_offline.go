@@ -0,0 +1,314 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imports
+
+// This file defines the offline mode of the ProcessEnv and the
+// ModuleResolver, in which the go command is never run.
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/gocommand"
+)
+
+// initOffline sets the variables of RequiredGoEnvVars that are missing
+// from e.Env as the go command would report them, without running it:
+// from the OS environment, the go env file written by `go env -w`, and
+// the defaults of the go command. GOMOD and GOWORK are found by
+// searching the working directory and its parents.
+func (e *ProcessEnv) initOffline() error {
+	fileEnv := readGoEnvFile()
+	lookup := func(k string) string {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+		return fileEnv[k]
+	}
+	for _, k := range RequiredGoEnvVars {
+		if _, ok := e.Env[k]; ok || k == "GOMOD" || k == "GOWORK" {
+			continue
+		}
+		v := lookup(k)
+		if v == "" {
+			switch k {
+			case "GOROOT":
+				v = build.Default.GOROOT
+			case "GOPATH":
+				v = build.Default.GOPATH
+			}
+		}
+		e.Env[k] = v
+	}
+
+	dir := e.WorkingDir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		dir = wd
+	}
+	modulesOff := e.Env["GO111MODULE"] == "off"
+	if _, ok := e.Env["GOMOD"]; !ok {
+		gomod := ""
+		if !modulesOff {
+			gomod = findInParents(dir, "go.mod")
+		}
+		e.Env["GOMOD"] = gomod
+	}
+	if _, ok := e.Env["GOWORK"]; !ok {
+		gowork := lookup("GOWORK")
+		switch {
+		case modulesOff || gowork == "off":
+			gowork = ""
+		case gowork == "":
+			gowork = findInParents(dir, "go.work")
+		}
+		e.Env["GOWORK"] = gowork
+	}
+	return nil
+}
+
+// readGoEnvFile returns the variables of the go env file, or nil if
+// there is none.
+func readGoEnvFile() map[string]string {
+	file := os.Getenv("GOENV")
+	if file == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil
+		}
+		file = filepath.Join(dir, "go", "env")
+	}
+	if file == "off" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	env := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '='); i > 0 {
+			env[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return env
+}
+
+// findInParents returns the path of the file of the given name in dir
+// or its closest parent that has one, or "" if none does.
+func findInParents(dir, name string) string {
+	for {
+		f := filepath.Join(dir, name)
+		if info, err := os.Stat(f); err == nil && !info.IsDir() {
+			return f
+		}
+		d := filepath.Dir(dir)
+		if len(d) >= len(dir) {
+			return "" // reached top of file system
+		}
+		dir = d
+	}
+}
+
+var modFlagRegexp = regexp.MustCompile(`-mod[ =](\w+)`)
+
+// A replacement is a replace directive of a go.mod or go.work file.
+type replacement struct {
+	*modfile.Replace
+	dir string // the directory of the file, against which relative paths are resolved
+}
+
+// initModsOffline finds the main modules and the modules that they
+// require from the go.work and go.mod files, as gocommand.VendorEnabled
+// and initAllMods find them using the go command. If vendoring is
+// enabled, it returns the main module and adds no modules to r;
+// otherwise it adds the main modules and the required modules that
+// have been downloaded.
+//
+// The required modules are those of the require directives of the
+// main modules, at the versions they state, rather than the selected
+// versions of the full build list, which would require the go.mod
+// files of every module of the build list.
+func (r *ModuleResolver) initModsOffline() (bool, *gocommand.ModuleJSON, error) {
+	var (
+		mains    []*gocommand.ModuleJSON
+		files    []*modfile.File
+		replaces []replacement // in order of precedence
+	)
+	addMain := func(gomod string) error {
+		data, err := ioutil.ReadFile(gomod)
+		if err != nil {
+			return err
+		}
+		f, err := modfile.Parse(gomod, data, nil)
+		if err != nil {
+			// The go directive of a go.mod file written by a later
+			// go command may not be in a form that Parse accepts,
+			// but ParseLax normalizes it (and ignores the replace
+			// directives), so parse its normalized form.
+			lax, laxErr := modfile.ParseLax(gomod, data, nil)
+			if laxErr != nil {
+				return err
+			}
+			if f, err = modfile.Parse(gomod, modfile.Format(lax.Syntax), nil); err != nil {
+				return err
+			}
+		}
+		if f.Module == nil {
+			return fmt.Errorf("%s: no module declaration", gomod)
+		}
+		main := &gocommand.ModuleJSON{
+			Path:  f.Module.Mod.Path,
+			Main:  true,
+			Dir:   filepath.Dir(gomod),
+			GoMod: gomod,
+		}
+		if f.Go != nil {
+			main.GoVersion = f.Go.Version
+		}
+		mains = append(mains, main)
+		files = append(files, f)
+		return nil
+	}
+
+	gowork := r.env.Env["GOWORK"]
+	if gowork == "off" {
+		gowork = ""
+	}
+	if gowork != "" {
+		data, err := ioutil.ReadFile(gowork)
+		if err != nil {
+			return false, nil, err
+		}
+		wf, err := modfile.ParseWork(gowork, data, nil)
+		if err != nil {
+			return false, nil, err
+		}
+		workDir := filepath.Dir(gowork)
+		for _, use := range wf.Use {
+			dir := filepath.FromSlash(use.Path)
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(workDir, dir)
+			}
+			if err := addMain(filepath.Join(dir, "go.mod")); err != nil {
+				return false, nil, err
+			}
+		}
+		for _, rep := range wf.Replace {
+			replaces = append(replaces, replacement{rep, workDir})
+		}
+	} else if gomod := r.env.Env["GOMOD"]; gomod != "" && gomod != os.DevNull {
+		if err := addMain(gomod); err != nil {
+			return false, nil, err
+		}
+	}
+	if len(mains) == 0 {
+		return false, nil, nil
+	}
+
+	// Module vendor directories are ignored in workspace mode.
+	if gowork == "" {
+		modFlag := r.env.ModFlag
+		if matches := modFlagRegexp.FindStringSubmatch(r.env.Env["GOFLAGS"]); modFlag == "" && len(matches) != 0 {
+			modFlag = matches[1]
+		}
+		main := mains[0]
+		switch modFlag {
+		case "vendor":
+			return true, main, nil
+		case "":
+			// Check 1.14's automatic vendor mode.
+			if fi, err := os.Stat(filepath.Join(main.Dir, "vendor")); err == nil && fi.IsDir() {
+				if main.GoVersion != "" && semver.Compare("v"+main.GoVersion, "v1.14") >= 0 {
+					return true, main, nil
+				}
+			}
+		}
+	}
+
+	for i, f := range files {
+		for _, rep := range f.Replace {
+			replaces = append(replaces, replacement{rep, mains[i].Dir})
+		}
+	}
+	seen := make(map[string]bool)
+	for _, main := range mains {
+		seen[main.Path] = true
+		r.mains = append(r.mains, main)
+		r.modsByModPath = append(r.modsByModPath, main)
+		r.modsByDir = append(r.modsByDir, main)
+	}
+	for _, f := range files {
+		for _, req := range f.Require {
+			if seen[req.Mod.Path] {
+				continue
+			}
+			seen[req.Mod.Path] = true
+			mod := &gocommand.ModuleJSON{
+				Path:     req.Mod.Path,
+				Version:  req.Mod.Version,
+				Indirect: req.Indirect,
+			}
+			mod.Dir = r.moduleCacheDirFor(req.Mod)
+			for _, rep := range replaces {
+				if rep.Old.Path != req.Mod.Path || rep.Old.Version != "" && rep.Old.Version != req.Mod.Version {
+					continue
+				}
+				mod.Replace = &gocommand.ModuleJSON{Path: rep.New.Path, Version: rep.New.Version}
+				if rep.New.Version == "" {
+					// A local replacement.
+					dir := filepath.FromSlash(rep.New.Path)
+					if !filepath.IsAbs(dir) {
+						dir = filepath.Join(rep.dir, dir)
+					}
+					mod.Replace.Dir = dir
+				} else {
+					mod.Replace.Dir = r.moduleCacheDirFor(rep.New)
+				}
+				mod.Dir = mod.Replace.Dir
+				break
+			}
+			if mod.Dir == "" {
+				continue
+			}
+			if fi, err := os.Stat(mod.Dir); err != nil || !fi.IsDir() {
+				if r.env.Logf != nil {
+					r.env.Logf("module %v has not been downloaded and will be ignored", mod.Path)
+				}
+				continue
+			}
+			mod.Dir = filepath.Clean(mod.Dir)
+			r.modsByModPath = append(r.modsByModPath, mod)
+			r.modsByDir = append(r.modsByDir, mod)
+		}
+	}
+	return false, nil, nil
+}
+
+// moduleCacheDirFor returns the directory of the module cache that
+// holds the module version m, whether or not it has been downloaded, or
+// "" if m cannot be in the module cache.
+func (r *ModuleResolver) moduleCacheDirFor(m module.Version) string {
+	path, err := module.EscapePath(m.Path)
+	if err != nil {
+		return ""
+	}
+	version, err := module.EscapeVersion(m.Version)
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(r.moduleCacheDir, filepath.FromSlash(path)+"@"+version)
+}
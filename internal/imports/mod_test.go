@@ -18,6 +18,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/mod/module"
 	"golang.org/x/tools/internal/gocommand"
@@ -897,6 +898,250 @@ package x
 	mt.assertScanFinds("rsc.io/quote", "quote")
 }
 
+// Tests that an offline resolver finds the modules that go.mod requires,
+// and their replacements, without running the go command.
+func TestModOffline(t *testing.T) {
+	mt := setup(t, nil, `
+-- go.mod --
+module x
+
+require (
+	rsc.io/quote v1.5.1
+	zz v1.0.0
+)
+
+replace rsc.io/quote v1.5.1 => rsc.io/quote v1.5.2
+
+replace zz v1.0.0 => ./z
+
+-- x.go --
+package x
+import _ "rsc.io/quote"
+-- z/go.mod --
+module zz
+-- z/z.go --
+package z
+`, "")
+	defer mt.cleanup()
+
+	// A nil GocmdRunner panics if the go command is run.
+	env := &ProcessEnv{
+		Env: map[string]string{
+			"GOPATH":      mt.env.Env["GOPATH"],
+			"GOROOT":      mt.env.Env["GOROOT"],
+			"GOMODCACHE":  "",
+			"GO111MODULE": "auto",
+			"GOWORK":      "off",
+		},
+		WorkingDir: mt.env.WorkingDir,
+		Offline:    true,
+		Logf:       mt.env.Logf,
+	}
+	resolver, err := env.GetResolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := env.Env["GOMOD"], filepath.Join(mt.env.WorkingDir, "go.mod"); got != want {
+		t.Errorf("GOMOD = %q, want %q", got, want)
+	}
+	mt.env, mt.resolver = env, resolver.(*ModuleResolver)
+
+	mt.assertModuleFoundInDir("x", "x", "main$")
+	mt.assertModuleFoundInDir("rsc.io/quote", "quote", `pkg.mod.*/quote@v1.5.2$`)
+	mt.assertModuleFoundInDir("zz", "z", "main/z$")
+}
+
+// Tests that a resolver reads the packages of the module cache from the
+// index that an earlier one wrote, until a module is added.
+func TestModIndex(t *testing.T) {
+	mt := setup(t, nil, `
+-- go.mod --
+module x
+
+require rsc.io/quote v1.5.2
+-- x.go --
+package x
+import _ "rsc.io/quote"
+`, "")
+	defer mt.cleanup()
+
+	indexDir := filepath.Join(mt.gopath, "index")
+	newResolver := func() {
+		env := mt.env.CopyConfig()
+		env.IndexDir = indexDir
+		resolver, err := env.GetResolver()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := resolver.(*ModuleResolver).init(); err != nil {
+			t.Fatal(err)
+		}
+		mt.env, mt.resolver = env, resolver.(*ModuleResolver)
+	}
+
+	newResolver()
+	mt.assertScanFinds("rsc.io/quote", "quote")
+	if _, err := os.Stat(mt.resolver.indexFile()); err != nil {
+		t.Fatalf("index not written: %v", err)
+	}
+
+	newResolver()
+	if !mt.resolver.indexFresh() {
+		t.Errorf("index is stale")
+	}
+	quote := filepath.Join(mt.resolver.moduleCacheDir, "rsc.io", "quote@v1.5.2")
+	if info, ok := mt.resolver.moduleCacheCache.Load(quote); !ok || info.packageName != "quote" {
+		t.Errorf("index does not record package quote in %s", quote)
+	}
+	mt.assertScanFinds("rsc.io/quote", "quote")
+
+	// Add a module to the module cache, as go mod download would.
+	newMod := filepath.Join(mt.resolver.moduleCacheDir, "example.com", "new@v1.0.0")
+	if err := os.MkdirAll(newMod, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"go.mod": "module example.com/new\n",
+		"new.go": "package new\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(newMod, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newResolver()
+	if mt.resolver.indexFresh() {
+		t.Errorf("index is fresh after a module was added")
+	}
+	mt.assertScanFinds("example.com/new", "new")
+	mt.assertScanFinds("rsc.io/quote", "quote")
+	if !mt.resolver.indexFresh() {
+		t.Errorf("index is stale after a scan")
+	}
+}
+
+// Tests that the exports of packages are cached persistently, as long
+// as their files are unchanged.
+func TestExportsCache(t *testing.T) {
+	mt := setup(t, nil, `
+-- go.mod --
+module x
+-- x.go --
+package x
+-- y/y.go --
+package y
+func Y() {}
+`, "")
+	defer mt.cleanup()
+
+	env := mt.env.CopyConfig()
+	env.IndexDir = filepath.Join(mt.gopath, "index")
+	dir := filepath.Join(mt.env.WorkingDir, "y")
+	file := filepath.Join(dir, "y.go")
+	write := func(content string, mtime time.Time) {
+		if err := ioutil.WriteFile(file, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func() []string {
+		t.Helper()
+		name, exports, err := loadExportsFromFiles(context.Background(), env, dir, false)
+		if err != nil || name != "y" {
+			t.Fatalf("loadExportsFromFiles = %q, %v, %v; want y", name, exports, err)
+		}
+		sort.Strings(exports)
+		return exports
+	}
+	state := func() string {
+		t.Helper()
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dirState(files)
+	}
+
+	hourAgo := time.Now().Add(-time.Hour)
+	write("package y\nfunc Y() {}\n", hourAgo)
+	if got := load(); !reflect.DeepEqual(got, []string{"Y"}) {
+		t.Errorf("exports = %v, want [Y]", got)
+	}
+	if _, exports, ok := env.readExportsCache(dir, state(), false); !ok || !reflect.DeepEqual(exports, []string{"Y"}) {
+		t.Fatalf("cached exports = %v, %v; want [Y]", exports, ok)
+	}
+
+	// The cached exports are used while the file is unchanged.
+	if err := env.writeExportsCache(dir, state(), false, "y", []string{"Cached"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := load(); !reflect.DeepEqual(got, []string{"Cached"}) {
+		t.Errorf("exports = %v, want the cached [Cached]", got)
+	}
+
+	// A change of the file invalidates them.
+	write("package y\nfunc Z() {}\n", hourAgo.Add(time.Minute))
+	if got := load(); !reflect.DeepEqual(got, []string{"Z"}) {
+		t.Errorf("exports after a change = %v, want [Z]", got)
+	}
+
+	// The exports of a file modified just now are not cached.
+	write("package y\nfunc W() {}\n", time.Now())
+	if s := state(); s != "" {
+		t.Errorf("state of a directory modified just now = %q, want none", s)
+	}
+	if got := load(); !reflect.DeepEqual(got, []string{"W"}) {
+		t.Errorf("exports after a recent change = %v, want [W]", got)
+	}
+}
+
+// Tests that a cancelled scan walks no more roots, and that the roots
+// it walked to the end are not walked again.
+func TestScanCancel(t *testing.T) {
+	mt := setup(t, nil, `
+-- go.mod --
+module x
+
+require rsc.io/quote v1.5.2
+-- x.go --
+package x
+
+import _ "rsc.io/quote"
+-- y/y.go --
+package y
+`, "")
+	defer mt.cleanup()
+
+	// Cancel the scan during the walk of the main module, which is
+	// walked with the modules that the module cache holds, before it.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mt.env.SkipPathInScan = func(string) bool {
+		cancel()
+		return false
+	}
+	callback := &scanCallback{
+		rootFound:         func(gopathwalk.Root) bool { return true },
+		dirFound:          func(*pkg) bool { return true },
+		packageNameLoaded: func(*pkg) bool { return false },
+	}
+	if err := mt.resolver.scan(ctx, callback); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the detached walk to stop.
+	<-mt.resolver.scanSema
+	defer func() { mt.resolver.scanSema <- struct{}{} }()
+	for _, root := range mt.resolver.roots {
+		want := root.Path != mt.resolver.moduleCacheDir
+		if got := mt.resolver.scannedRoots[root]; got != want {
+			t.Errorf("root %v scanned: got %t, want %t", root, got, want)
+		}
+	}
+}
+
 // assertFound asserts that the package at importPath is found to have pkgName,
 // and that scanning for pkgName finds it at importPath.
 func (t *modTest) assertFound(importPath, pkgName string) (string, *pkg) {
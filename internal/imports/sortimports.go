@@ -15,11 +15,12 @@ import (
 	"strconv"
 )
 
-// sortImports sorts runs of consecutive import lines in import blocks in f.
+// sortImports sorts runs of consecutive import lines in import blocks in f,
+// or, if regroup, all the imports of each block, by their groups, then paths.
 // It also removes duplicate imports when it is possible to do so without data loss.
 //
 // It may mutate the token.File.
-func sortImports(localPrefix string, tokFile *token.File, f *ast.File) {
+func sortImports(group func(importPath string) int, regroup bool, tokFile *token.File, f *ast.File) {
 	for i, d := range f.Decls {
 		d, ok := d.(*ast.GenDecl)
 		if !ok || d.Tok != token.IMPORT {
@@ -42,13 +43,13 @@ func sortImports(localPrefix string, tokFile *token.File, f *ast.File) {
 		i := 0
 		specs := d.Specs[:0]
 		for j, s := range d.Specs {
-			if j > i && tokFile.Line(s.Pos()) > 1+tokFile.Line(d.Specs[j-1].End()) {
+			if j > i && !regroup && tokFile.Line(s.Pos()) > 1+tokFile.Line(d.Specs[j-1].End()) {
 				// j begins a new run.  End this one.
-				specs = append(specs, sortSpecs(localPrefix, tokFile, f, d.Specs[i:j])...)
+				specs = append(specs, sortSpecs(group, tokFile, f, d.Specs[i:j])...)
 				i = j
 			}
 		}
-		specs = append(specs, sortSpecs(localPrefix, tokFile, f, d.Specs[i:])...)
+		specs = append(specs, sortSpecs(group, tokFile, f, d.Specs[i:])...)
 		d.Specs = specs
 
 		// Deduping can leave a blank line before the rparen; clean that up.
@@ -149,7 +150,7 @@ type posSpan struct {
 
 // sortSpecs sorts the import specs within each import decl.
 // It may mutate the token.File.
-func sortSpecs(localPrefix string, tokFile *token.File, f *ast.File, specs []ast.Spec) []ast.Spec {
+func sortSpecs(group func(importPath string) int, tokFile *token.File, f *ast.File, specs []ast.Spec) []ast.Spec {
 	// Can't short-circuit here even if specs are already sorted,
 	// since they might yet need deduplication.
 	// A lone import, however, may be safely ignored.
@@ -198,7 +199,7 @@ func sortSpecs(localPrefix string, tokFile *token.File, f *ast.File, specs []ast
 	// Reassign the import paths to have the same position sequence.
 	// Reassign each comment to abut the end of its spec.
 	// Sort the comments by new position.
-	sort.Sort(byImportSpec{localPrefix, specs})
+	sort.Sort(byImportSpec{group, specs})
 
 	// Dedup. Thanks to our sorting, we can just consider
 	// adjacent pairs of imports.
@@ -261,8 +262,8 @@ func sortSpecs(localPrefix string, tokFile *token.File, f *ast.File, specs []ast
 }
 
 type byImportSpec struct {
-	localPrefix string
-	specs       []ast.Spec // slice of *ast.ImportSpec
+	group func(importPath string) int
+	specs []ast.Spec // slice of *ast.ImportSpec
 }
 
 func (x byImportSpec) Len() int      { return len(x.specs) }
@@ -271,8 +272,8 @@ func (x byImportSpec) Less(i, j int) bool {
 	ipath := importPath(x.specs[i])
 	jpath := importPath(x.specs[j])
 
-	igroup := importGroup(x.localPrefix, ipath)
-	jgroup := importGroup(x.localPrefix, jpath)
+	igroup := x.group(ipath)
+	jgroup := x.group(jpath)
 	if igroup != jgroup {
 		return igroup < jgroup
 	}
@@ -28,3 +28,11 @@ var ForTest int    // must be set as a LoadMode to call GetForTest
 
 var SetModFlag = func(config interface{}, value string) {}
 var SetModFile = func(config interface{}, value string) {}
+
+// SetParallelism limits the number of packages
+// that are parsed and type-checked concurrently.
+var SetParallelism = func(config interface{}, value int) {}
+
+// SetIgnoreDepFuncBodies causes the function bodies of
+// dependencies not to be type-checked, even with NeedDeps.
+var SetIgnoreDepFuncBodies = func(config interface{}, value bool) {}
@@ -0,0 +1,14 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.22
+// +build !go1.22
+
+package typeparams
+
+import "go/types"
+
+// Unalias returns t: before Go 1.22, go/types does not represent
+// aliases by types.
+func Unalias(t types.Type) types.Type { return t }
@@ -0,0 +1,15 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.22
+// +build go1.22
+
+package typeparams
+
+import "go/types"
+
+// Unalias returns the type that t denotes, following aliases, which
+// go/types may represent by *types.Alias types. It returns t if t is
+// not an alias.
+func Unalias(t types.Type) types.Type { return types.Unalias(t) }
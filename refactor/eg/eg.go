@@ -4,6 +4,13 @@
 
 // Package eg implements the example-based refactoring tool whose
 // command-line is defined in golang.org/x/tools/cmd/eg.
+//
+// Other tools, such as migration tools and analyzers that suggest
+// fixes, may apply templates too: LoadTemplates returns the
+// Transformers of template files, which MatchPackages and
+// TransformPackages apply to packages loaded by go/packages. The
+// Filter and PostProcess hooks of a Transformer select the matches
+// that it replaces and adjust their replacements.
 package eg // import "golang.org/x/tools/refactor/eg"
 
 import (
@@ -14,7 +21,8 @@ import (
 	"go/printer"
 	"go/token"
 	"go/types"
-	"os"
+
+	"golang.org/x/tools/internal/edits"
 )
 
 const Help = `
@@ -104,11 +112,13 @@ IMPORTS
 Although the matching algorithm is fully aware of scoping rules, the
 replacement algorithm is not, so the replacement code may contain
 incorrect identifier syntax for imported objects if there are dot
-imports, named imports or locally shadowed package names in the input
-program.
+imports or locally shadowed package names in the input program.
 
-Imports are added as needed, but they are not removed as needed.
-Run 'goimports' on the modified file for now.
+The replacement code refers to an imported package by the name under
+which the modified file imports it.  Imports are added as needed, under
+another name than the package's if that is taken by another import or
+declaration, and the imports of the packages used by the pattern are
+removed if the file no longer uses them.
 
 Dot imports are forbidden in the template.
 
@@ -136,21 +146,41 @@ match f'; (4) use eg to rename f' to f in all calls; (5) delete f'.
 // TODO(adonovan): expand upon the above documentation as an HTML page.
 
 // A Transformer represents a single example-based transformation.
+//
+// Its hooks, if set, let a client choose the matches that it replaces
+// and adjust their replacements.
 type Transformer struct {
+	// Filter, if not nil, is called for each match of the pattern, and
+	// reports whether the match is to be reported by Match and replaced
+	// by Transform.
+	Filter func(m *Match) bool
+
+	// PostProcess, if not nil, is called by Transform for each match to
+	// be replaced, with its replacement: a copy of the expression of the
+	// after function, in which the wildcards are substituted. It returns
+	// the expression that replaces the match, typically repl itself or a
+	// modification of it. The nodes that it adds have no type information.
+	PostProcess func(m *Match, repl ast.Expr) ast.Expr
+
 	fset           *token.FileSet
+	template       string // name of the template file
 	verbose        bool
 	info           *types.Info // combined type info for template/input/output ASTs
 	seenInfos      map[*types.Info]bool
 	wildcards      map[*types.Var]bool                // set of parameters in func before()
 	env            map[string]ast.Expr                // maps parameter name to wildcard binding
 	importedObjs   map[types.Object]*ast.SelectorExpr // objects imported by after().
+	beforeImports  map[string]bool                    // paths of the packages of objects used by before().
 	before, after  ast.Expr
 	afterStmts     []ast.Stmt
 	allowWildcards bool
 
 	// Working state of Transform():
-	nsubsts    int            // number of substitutions made
-	currentPkg *types.Package // package of current call
+	nsubsts     int                       // number of substitutions made
+	currentPkg  *types.Package            // package of current call
+	currentInfo *types.Info               // type info of current call
+	currentFile *ast.File                 // file of current call
+	importNames map[*types.Package]string // names of imported packages in current file
 }
 
 // NewTransformer returns a transformer based on the specified template,
@@ -235,11 +265,13 @@ func NewTransformer(fset *token.FileSet, tmplPkg *types.Package, tmplFile *ast.F
 
 	tr := &Transformer{
 		fset:           fset,
+		template:       fset.File(tmplFile.Pos()).Name(),
 		verbose:        verbose,
 		wildcards:      wildcards,
 		allowWildcards: true,
 		seenInfos:      make(map[*types.Info]bool),
 		importedObjs:   make(map[types.Object]*ast.SelectorExpr),
+		beforeImports:  make(map[string]bool),
 		before:         before,
 		after:          after,
 		afterStmts:     afterStmts,
@@ -271,22 +303,31 @@ func NewTransformer(fset *token.FileSet, tmplPkg *types.Package, tmplFile *ast.F
 		return true // recur
 	})
 
+	// Compute set of packages whose imports before() may leave unneeded.
+	ast.Inspect(before, func(n ast.Node) bool {
+		if n, ok := n.(*ast.SelectorExpr); ok {
+			if _, ok := tr.info.Selections[n]; !ok {
+				// qualified ident
+				if obj := tr.info.Uses[n.Sel]; obj != nil && obj.Pkg() != nil {
+					tr.beforeImports[obj.Pkg().Path()] = true
+				}
+				return false // prune
+			}
+		}
+		return true // recur
+	})
+
 	return tr, nil
 }
 
-// WriteAST is a convenience function that writes AST f to the specified file.
-func WriteAST(fset *token.FileSet, filename string, f *ast.File) (err error) {
-	fh, err := os.Create(filename)
-	if err != nil {
+// WriteAST is a convenience function that writes AST f to the specified
+// file, which it replaces atomically.
+func WriteAST(fset *token.FileSet, filename string, f *ast.File) error {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
 		return err
 	}
-
-	defer func() {
-		if err2 := fh.Close(); err != nil {
-			err = err2 // prefer earlier error
-		}
-	}()
-	return format.Node(fh, fset, f)
+	return edits.WriteFile(filename, buf.Bytes())
 }
 
 // -- utilities --------------------------------------------------------
@@ -89,6 +89,10 @@ func Test(t *testing.T) {
 		"testdata/J.template",
 		"testdata/J1.go",
 
+		"testdata/K.template",
+		"testdata/K1.go",
+		"testdata/K2.go",
+
 		"testdata/bad_type.template",
 		"testdata/no_before.template",
 		"testdata/no_after_return.template",
@@ -17,6 +17,68 @@ import (
 	"golang.org/x/tools/go/ast/astutil"
 )
 
+// A Match is an occurrence of the pattern of a Transformer, the
+// expression of its before function, in a file.
+type Match struct {
+	Pkg  *types.Package
+	Info *types.Info
+	File *ast.File
+	Expr ast.Expr            // the matching expression of File
+	Env  map[string]ast.Expr // the expression matched by each wildcard, by name
+}
+
+// newMatch returns the Match of e in the current file, whose wildcards
+// are bound by tr.env.
+func (tr *Transformer) newMatch(e ast.Expr) *Match {
+	return &Match{
+		Pkg:  tr.currentPkg,
+		Info: tr.currentInfo,
+		File: tr.currentFile,
+		Expr: e,
+		Env:  tr.env,
+	}
+}
+
+// Match returns the matches of the pattern in the specified parsed
+// file, whose type information is supplied in info, that the Filter
+// accepts, in the order of their positions, outer matches before the
+// matches that they contain. Unlike Transform, it does not change the
+// file.
+func (tr *Transformer) Match(info *types.Info, pkg *types.Package, file *ast.File) []*Match {
+	if !tr.seenInfos[info] {
+		tr.seenInfos[info] = true
+		mergeTypeInfo(tr.info, info)
+	}
+	tr.currentPkg = pkg
+	tr.currentInfo = info
+	tr.currentFile = file
+
+	var matches []*Match
+	ast.Inspect(file, func(n ast.Node) bool {
+		e, ok := n.(ast.Expr)
+		if !ok {
+			return true
+		}
+		if _, ok := e.(*ast.ParenExpr); ok {
+			return true // the parenthesized expression may match
+		}
+		tr.env = make(map[string]ast.Expr)
+		if tr.matchExpr(tr.before, e) {
+			if m := tr.newMatch(e); tr.Filter == nil || tr.Filter(m) {
+				matches = append(matches, m)
+			}
+		}
+		return true
+	})
+
+	tr.env = nil
+	tr.currentPkg = nil
+	tr.currentInfo = nil
+	tr.currentFile = nil
+
+	return matches
+}
+
 // matchExpr reports whether pattern x matches y.
 //
 // If tr.allowWildcards, Idents in x that refer to parameters are
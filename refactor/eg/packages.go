@@ -0,0 +1,186 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eg
+
+// This file defines the application of templates to the packages
+// loaded by go/packages, for the eg command and other clients.
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// LoadTemplates parses and type-checks the named template files, and
+// returns their Transformers, in the same order.
+//
+// The templates are parsed with cfg.Fset, which must be that of pkgs,
+// the packages that they are to be applied to. Their imports are
+// resolved among pkgs and their dependencies, and the packages missing
+// from those, such as the new API of a migration, are loaded with the
+// Dir, Env and BuildFlags of cfg.
+func LoadTemplates(cfg *packages.Config, pkgs []*packages.Package, verbose bool, filenames ...string) ([]*Transformer, error) {
+	var tFiles []*ast.File
+	for _, name := range filenames {
+		tAbs, err := filepath.Abs(name)
+		if err != nil {
+			return nil, err
+		}
+		template, err := ioutil.ReadFile(tAbs)
+		if err != nil {
+			return nil, err
+		}
+		tFile, err := parser.ParseFile(cfg.Fset, tAbs, template, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		tFiles = append(tFiles, tFile)
+	}
+
+	imp := pkgsImporter(pkgs)
+	var missing []string
+	for _, tFile := range tFiles {
+		for _, spec := range tFile.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			if _, err := imp.Import(path); err != nil && !contains(missing, path) {
+				missing = append(missing, path)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		extra, err := packages.Load(&packages.Config{
+			Fset:       cfg.Fset,
+			Mode:       packages.NeedName | packages.NeedTypes | packages.NeedImports | packages.NeedDeps,
+			Dir:        cfg.Dir,
+			Env:        cfg.Env,
+			BuildFlags: cfg.BuildFlags,
+		}, missing...)
+		if err != nil {
+			return nil, err
+		}
+		if packages.PrintErrors(extra) > 0 {
+			return nil, fmt.Errorf("packages imported by the templates contain errors")
+		}
+		imp = append(imp, extra...)
+	}
+
+	var xforms []*Transformer
+	for _, tFile := range tFiles {
+		tInfo := types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		}
+		conf := types.Config{
+			Importer: imp,
+		}
+		tPkg, err := conf.Check("egtemplate", cfg.Fset, []*ast.File{tFile}, &tInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		xform, err := NewTransformer(cfg.Fset, tPkg, tFile, &tInfo, verbose)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", cfg.Fset.File(tFile.Pos()).Name(), err)
+		}
+		xforms = append(xforms, xform)
+	}
+	return xforms, nil
+}
+
+// A TransformedFile is a file that Transformers changed.
+type TransformedFile struct {
+	Pkg      *packages.Package // the package of the file
+	Filename string
+	File     *ast.File // the changed syntax tree of the file, an element of Pkg.Syntax
+	Matches  int       // the number of replacements
+}
+
+// TransformPackages applies xforms in turn to each file of pkgs, and
+// returns the files that they changed, in the order of pkgs and of
+// their files. A file that belongs to several packages, such as a
+// package and its test variant, is transformed only once, and the
+// template files of xforms are not transformed.
+//
+// The packages must have been loaded, with the FileSet of xforms, in a
+// mode that includes packages.NeedSyntax, NeedTypes, NeedTypesInfo and
+// NeedCompiledGoFiles.
+func TransformPackages(pkgs []*packages.Package, xforms []*Transformer) []*TransformedFile {
+	var files []*TransformedFile
+	forEachFile(pkgs, xforms, func(pkg *packages.Package, filename string, file *ast.File) {
+		n := 0
+		for _, xform := range xforms {
+			n += xform.Transform(pkg.TypesInfo, pkg.Types, file)
+		}
+		if n > 0 {
+			files = append(files, &TransformedFile{pkg, filename, file, n})
+		}
+	})
+	return files
+}
+
+// MatchPackages returns the matches of the pattern of tr in the files of
+// pkgs, which are visited as by TransformPackages, without changing them.
+func MatchPackages(pkgs []*packages.Package, tr *Transformer) []*Match {
+	var matches []*Match
+	forEachFile(pkgs, []*Transformer{tr}, func(pkg *packages.Package, filename string, file *ast.File) {
+		matches = append(matches, tr.Match(pkg.TypesInfo, pkg.Types, file)...)
+	})
+	return matches
+}
+
+// forEachFile calls fn for each file of pkgs once, except for the
+// template files of xforms.
+func forEachFile(pkgs []*packages.Package, xforms []*Transformer, fn func(pkg *packages.Package, filename string, file *ast.File)) {
+	seen := make(map[string]bool) // files of packages and their test variants
+	for _, xform := range xforms {
+		seen[xform.template] = true // don't rewrite the template files
+	}
+	for _, pkg := range pkgs {
+		for i, filename := range pkg.CompiledGoFiles {
+			if i >= len(pkg.Syntax) || seen[filename] {
+				continue
+			}
+			seen[filename] = true
+			fn(pkg, filename, pkg.Syntax[i])
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// A pkgsImporter imports the packages of a set of packages and of
+// their dependencies.
+type pkgsImporter []*packages.Package
+
+func (p pkgsImporter) Import(path string) (tpkg *types.Package, err error) {
+	packages.Visit([]*packages.Package(p), func(pkg *packages.Package) bool {
+		if pkg.PkgPath == path {
+			tpkg = pkg.Types
+			return false
+		}
+		return true
+	}, nil)
+	if tpkg != nil {
+		return tpkg, nil
+	}
+	return nil, fmt.Errorf("package %q not found", path)
+}
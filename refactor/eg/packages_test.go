@@ -0,0 +1,126 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eg_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/packages/packagestest"
+	"golang.org/x/tools/internal/testenv"
+	"golang.org/x/tools/refactor/eg"
+)
+
+const hooksTemplate = `package template
+
+import (
+	"errors"
+	"fmt"
+)
+
+func before(s string) error { return fmt.Errorf("%s", s) }
+func after(s string) error  { return errors.New(s) }
+`
+
+const hooksInput = `package p
+
+import "fmt"
+
+func f(msg string) error {
+	if msg == "" {
+		return fmt.Errorf("%s", "empty")
+	}
+	return fmt.Errorf("%s", msg)
+}
+`
+
+func loadHooksTest(t *testing.T) (*packages.Config, []*packages.Package, []*eg.Transformer) {
+	exported := packagestest.Export(t, packagestest.Modules, []packagestest.Module{{
+		Name: "example.com/m",
+		Files: map[string]interface{}{
+			"p/p.go":           hooksInput,
+			"template/tmpl.go": hooksTemplate,
+		},
+	}})
+	t.Cleanup(exported.Cleanup)
+
+	cfg := exported.Config
+	cfg.Fset = token.NewFileSet()
+	cfg.Mode = packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax | packages.NeedImports | packages.NeedDeps | packages.NeedCompiledGoFiles
+	pkgs, err := packages.Load(cfg, "example.com/m/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		t.Fatal("errors loading the packages")
+	}
+	xforms, err := eg.LoadTemplates(cfg, pkgs, false, exported.File("example.com/m", "template/tmpl.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg, pkgs, xforms
+}
+
+func TestMatchPackages(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	cfg, pkgs, xforms := loadHooksTest(t)
+	matches := eg.MatchPackages(pkgs, xforms[0])
+	var got []string
+	for _, m := range matches {
+		var buf bytes.Buffer
+		format.Node(&buf, cfg.Fset, m.Env["s"])
+		got = append(got, buf.String())
+	}
+	if want := `"empty" msg`; strings.Join(got, " ") != want {
+		t.Errorf("got matches binding s to %s, want %s", got, want)
+	}
+
+	// Matching doesn't change the files.
+	var buf bytes.Buffer
+	format.Node(&buf, cfg.Fset, pkgs[0].Syntax[0])
+	if buf.String() != hooksInput {
+		t.Errorf("MatchPackages changed the file:\n%s", buf.String())
+	}
+}
+
+func TestTransformPackagesHooks(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	cfg, pkgs, xforms := loadHooksTest(t)
+	xform := xforms[0]
+	// Replace only the calls with a variable message, and wrap their
+	// replacements in parentheses.
+	xform.Filter = func(m *eg.Match) bool {
+		_, ok := m.Env["s"].(*ast.Ident)
+		return ok
+	}
+	xform.PostProcess = func(m *eg.Match, repl ast.Expr) ast.Expr {
+		return &ast.ParenExpr{X: repl}
+	}
+	files := eg.TransformPackages(pkgs, xforms)
+	if len(files) != 1 {
+		t.Fatalf("got %d transformed files, want 1", len(files))
+	}
+	if files[0].Matches != 1 {
+		t.Errorf("got %d matches, want 1", files[0].Matches)
+	}
+	var buf bytes.Buffer
+	format.Node(&buf, cfg.Fset, files[0].File)
+	for _, want := range []string{
+		`return fmt.Errorf("%s", "empty")`,
+		`return (errors.New(msg))`,
+		`"errors"`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("transformed file does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -14,10 +14,13 @@ import (
 	"go/token"
 	"go/types"
 	"os"
+	"path"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/tools/go/ast/astutil"
 )
@@ -42,6 +45,11 @@ func (tr *Transformer) transformItem(rv reflect.Value) (reflect.Value, bool, map
 	tr.env = make(map[string]ast.Expr) // inefficient!  Use a slice of k/v pairs
 
 	if tr.matchExpr(tr.before, e) {
+		m := tr.newMatch(e)
+		if tr.Filter != nil && !tr.Filter(m) {
+			tr.env = savedEnv
+			return rv, changed, newEnv
+		}
 		if tr.verbose {
 			fmt.Fprintf(os.Stderr, "%s matches %s",
 				astString(tr.fset, tr.before), astString(tr.fset, e))
@@ -60,6 +68,9 @@ func (tr *Transformer) transformItem(rv reflect.Value) (reflect.Value, bool, map
 		// We update all positions to n.Pos() to aid comment placement.
 		rv = tr.subst(tr.env, reflect.ValueOf(tr.after),
 			reflect.ValueOf(e.Pos()))
+		if tr.PostProcess != nil {
+			rv = reflect.ValueOf(tr.PostProcess(m, rv.Interface().(ast.Expr)))
+		}
 		changed = true
 		newEnv = tr.env
 	}
@@ -73,8 +84,10 @@ func (tr *Transformer) transformItem(rv reflect.Value) (reflect.Value, bool, map
 // of replacements that were made.
 //
 // It mutates the AST in place (the identity of the root node is
-// unchanged), and may add nodes for which no type information is
-// available in info.
+// unchanged), and records in info the type information of the nodes
+// that it adds, so that the file may be transformed by several
+// Transformers in turn. Only the matches that the Filter accepts are
+// replaced, by the result of PostProcess if it is set.
 //
 // Derived from rewriteFile in $GOROOT/src/cmd/gofmt/rewrite.go.
 func (tr *Transformer) Transform(info *types.Info, pkg *types.Package, file *ast.File) int {
@@ -83,7 +96,11 @@ func (tr *Transformer) Transform(info *types.Info, pkg *types.Package, file *ast
 		mergeTypeInfo(tr.info, info)
 	}
 	tr.currentPkg = pkg
+	tr.currentInfo = info
+	tr.currentFile = file
 	tr.nsubsts = 0
+	var missing []*types.Package
+	tr.importNames, missing = tr.chooseImportNames(info, pkg, file)
 
 	if tr.verbose {
 		fmt.Fprintf(os.Stderr, "before: %s\n", astString(tr.fset, tr.before))
@@ -102,37 +119,148 @@ func (tr *Transformer) Transform(info *types.Info, pkg *types.Package, file *ast
 		panic("BUG")
 	}
 
-	// Add any necessary imports.
-	// TODO(adonovan): remove no-longer needed imports too.
+	// Add any necessary imports, and remove those of the packages of
+	// before() that are no longer needed.
 	if tr.nsubsts > 0 {
-		pkgs := make(map[string]*types.Package)
-		for obj := range tr.importedObjs {
-			pkgs[obj.Pkg().Path()] = obj.Pkg()
-		}
-
-		for _, imp := range file.Imports {
-			path, _ := strconv.Unquote(imp.Path.Value)
-			delete(pkgs, path)
-		}
-		delete(pkgs, pkg.Path()) // don't import self
-
 		// NB: AddImport may completely replace the AST!
 		// It thus renders info and tr.info no longer relevant to file.
-		var paths []string
-		for path := range pkgs {
-			paths = append(paths, path)
+		sort.Slice(missing, func(i, j int) bool {
+			return missing[i].Path() < missing[j].Path()
+		})
+		for _, p := range missing {
+			if name := tr.importNames[p]; name != importPathToAssumedName(p.Path()) {
+				astutil.AddNamedImport(tr.fset, file, name, p.Path())
+			} else {
+				astutil.AddImport(tr.fset, file, p.Path())
+			}
 		}
-		sort.Strings(paths)
-		for _, path := range paths {
-			astutil.AddImport(tr.fset, file, path)
+		for _, imp := range append([]*ast.ImportSpec(nil), file.Imports...) {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			if !tr.beforeImports[path] || imp.Name != nil && (imp.Name.Name == "_" || imp.Name.Name == ".") {
+				continue
+			}
+			name := importPathToAssumedName(path)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			} else if pkgName, ok := info.Implicits[imp].(*types.PkgName); ok {
+				name = pkgName.Imported().Name()
+			}
+			if !refersTo(file, name) {
+				astutil.DeleteNamedImport(tr.fset, file, importName(imp), path)
+			}
 		}
 	}
 
 	tr.currentPkg = nil
+	tr.currentInfo = nil
+	tr.currentFile = nil
+	tr.importNames = nil
 
 	return tr.nsubsts
 }
 
+// chooseImportNames returns the names by which the file of package pkg
+// refers, or is to refer, to the packages of the objects that after()
+// uses, and the packages among them that the file does not import.
+//
+// A package that the file imports is referred to by the name by which
+// the file imports it. The name of a package that it does not import is
+// the package's name, unless that conflicts with the name of an import
+// or package-level declaration, in which case a number is appended.
+func (tr *Transformer) chooseImportNames(info *types.Info, pkg *types.Package, file *ast.File) (map[*types.Package]string, []*types.Package) {
+	imported := make(map[string]string) // path -> local name
+	used := make(map[string]bool)       // names of the file's imports
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		var name string
+		if imp.Name != nil {
+			name = imp.Name.Name
+		} else if pkgName, ok := info.Implicits[imp].(*types.PkgName); ok {
+			name = pkgName.Imported().Name()
+		} else {
+			name = importPathToAssumedName(path)
+		}
+		if name == "_" || name == "." {
+			continue // can't be used to refer to the package
+		}
+		imported[path] = name
+		used[name] = true
+	}
+
+	var pkgs []*types.Package
+	seen := make(map[*types.Package]bool)
+	for obj := range tr.importedObjs {
+		if p := obj.Pkg(); p != pkg && !seen[p] {
+			seen[p] = true
+			pkgs = append(pkgs, p)
+		}
+	}
+	// Choose the names in a deterministic order.
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Path() < pkgs[j].Path() })
+
+	names := make(map[*types.Package]string)
+	var missing []*types.Package
+	for _, p := range pkgs {
+		if name, ok := imported[p.Path()]; ok {
+			names[p] = name
+			continue
+		}
+		name := p.Name()
+		for i := 2; used[name] || pkg.Scope().Lookup(name) != nil; i++ {
+			name = fmt.Sprintf("%s%d", p.Name(), i)
+		}
+		used[name] = true
+		names[p] = name
+		missing = append(missing, p)
+	}
+	return names, missing
+}
+
+// refersTo reports whether the file contains a qualified identifier
+// whose qualifier is name.
+func refersTo(file *ast.File, name string) bool {
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == name {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// importName returns the name of an import, or "" if it has none.
+func importName(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+	return ""
+}
+
+// importPathToAssumedName returns the package name that a reader
+// would assume from an import path, as goimports does: its last element,
+// without a major version suffix or "go-" prefix, up to the first
+// character that can't be part of an identifier.
+func importPathToAssumedName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil {
+			if dir := path.Dir(importPath); dir != "." {
+				base = path.Base(dir)
+			}
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_' || '0' <= r && r <= '9' || r >= utf8.RuneSelf && unicode.IsLetter(r))
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
 // setValue is a wrapper for x.SetValue(y); it protects
 // the caller from panics if x cannot be changed to y.
 func setValue(x, y reflect.Value) {
@@ -283,10 +411,10 @@ func (tr *Transformer) subst(env map[string]ast.Expr, pattern, pos reflect.Value
 	// The template cannot contain dot imports, so all identifiers
 	// for imported objects are explicitly qualified.
 	//
-	// We assume (unsoundly) that there are no dot or named
-	// imports in the input code, nor are any imported package
-	// names shadowed, so the usual normal qualified identifier
-	// syntax may be used.
+	// The qualifier is the name by which the file imports the
+	// package (see chooseImportNames). We assume (unsoundly) that
+	// the imported package names are not shadowed locally, so
+	// the usual normal qualified identifier syntax may be used.
 	// TODO(adonovan): fix: avoid this assumption.
 	//
 	// A refactoring may be applied to a package referenced by the
@@ -309,6 +437,9 @@ func (tr *Transformer) subst(env map[string]ast.Expr, pattern, pos reflect.Value
 				tr.importedObjs = nil // break cycle
 				r := tr.subst(nil, reflect.ValueOf(id), pos)
 				tr.importedObjs = saved
+				if name, ok := tr.importNames[obj.Pkg()]; ok && id != sel.Sel {
+					r.Interface().(*ast.SelectorExpr).X.(*ast.Ident).Name = name
+				}
 				return r
 			}
 		}
@@ -349,6 +480,9 @@ func (tr *Transformer) subst(env map[string]ast.Expr, pattern, pos reflect.Value
 		// so this case catches them all.
 		if e := rvToExpr(v); e != nil {
 			updateTypeInfo(tr.info, e, p.Interface().(ast.Expr))
+			if tr.currentInfo != nil {
+				copyTypeInfo(tr.currentInfo, tr.info, e)
+			}
 		}
 		return v
 
@@ -398,3 +532,26 @@ func updateTypeInfo(info *types.Info, new, old ast.Expr) {
 		info.Types[new] = tv
 	}
 }
+
+// copyTypeInfo copies the type information for the AST e from src to
+// those maps of dst that are present.
+func copyTypeInfo(dst, src *types.Info, e ast.Expr) {
+	switch e := e.(type) {
+	case *ast.Ident:
+		if obj, ok := src.Defs[e]; ok && dst.Defs != nil {
+			dst.Defs[e] = obj
+		}
+		if obj, ok := src.Uses[e]; ok && dst.Uses != nil {
+			dst.Uses[e] = obj
+		}
+
+	case *ast.SelectorExpr:
+		if sel, ok := src.Selections[e]; ok && dst.Selections != nil {
+			dst.Selections[e] = sel
+		}
+	}
+
+	if tv, ok := src.Types[e]; ok && dst.Types != nil {
+		dst.Types[e] = tv
+	}
+}
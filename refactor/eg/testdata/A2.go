@@ -1,7 +1,7 @@
 package A2
 
 // This refactoring causes addition of "errors" import.
-// TODO(adonovan): fix: it should also remove "fmt".
+// It also removes the "fmt" import, which is no longer used.
 
 import myfmt "fmt"
 
@@ -0,0 +1,13 @@
+package K1
+
+// The replacement refers to "strconv" by the name that the file imports
+// it as, and "fmt" is still used.
+
+import (
+	"fmt"
+	conv "strconv"
+)
+
+func example() {
+	fmt.Println(fmt.Sprint(1), conv.Quote("x"))
+}
@@ -0,0 +1,12 @@
+package K2
+
+// The name strconv is taken, so "strconv" is imported as strconv2,
+// and "fmt" is no longer used.
+
+import "fmt"
+
+var strconv = "local"
+
+func example() string {
+	return fmt.Sprint(len(strconv))
+}
@@ -0,0 +1,417 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rename
+
+// This file defines Batch, which performs many renamings, such as those
+// of a style policy or an API migration, in one pass over the packages
+// of the main modules, checking them together, and returns their edits
+// rather than writing the files.
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/edits"
+)
+
+// A Renaming is one of the renamings of a batch.
+type Renaming struct {
+	From string // the object to rename, as specified by the -from flag, e.g. `"encoding/json".Decoder.Decode`
+	To   string // its new name
+}
+
+// An Edit replaces the text [Start, End) of a file, in byte offsets,
+// by New.
+type Edit = edits.Edit
+
+// A Conflict is a reason why a renaming would break the program, and
+// its position.
+type Conflict struct {
+	Posn    token.Position
+	Message string
+}
+
+// A BatchResult is the outcome of a batch of renamings.
+type BatchResult struct {
+	Edits       []Edit     // the edits of the files, by file, then offset
+	Occurrences int        // the number of renamed identifiers
+	Conflicts   []Conflict // in the order they were found
+
+	set edits.Set // the edits and the original contents of the edited files
+}
+
+// Batch performs the renamings in the packages of the main modules and
+// their tests, loaded once with go/packages, configured by cfg, which
+// may be nil. Each renaming is checked for conflicts as ModuleMain
+// checks it, against the program before any of the renamings, and the
+// renamings are checked against one another: an object may be renamed
+// only once, and two objects of the same block, or fields of the same
+// struct, or methods of the same type, may not be given the same name.
+// Renamings that depend on one another, such as swaps, thus conflict.
+//
+// If there are conflicts, and Force is not set, Batch returns a result
+// with the conflicts but no edits, and ConflictError. Batch does not
+// change the files, which the Apply method of the result does; its Diff
+// method prints the changes.
+func Batch(cfg *packages.Config, renamings []Renaming) (*BatchResult, error) {
+	res := new(BatchResult)
+
+	// A package and its test variant are checked separately, and
+	// would report their conflicts twice.
+	defer func(saved func(token.Position, string)) { reportError = saved }(reportError)
+	reported := make(map[string]bool)
+	reportError = func(posn token.Position, message string) {
+		if key := posn.String() + "\x00" + message; !reported[key] {
+			reported[key] = true
+			res.Conflicts = append(res.Conflicts, Conflict{posn, message})
+		}
+	}
+
+	// -- Parse the specifiers and load the main modules ------------------
+
+	specs := make([]*spec, len(renamings))
+	for i, rn := range renamings {
+		if !isValidIdentifier(rn.To) {
+			return nil, fmt.Errorf("renaming %s to %q: not a valid identifier", rn.From, rn.To)
+		}
+		spec, err := parseFromSpec(rn.From)
+		if err != nil {
+			return nil, err
+		}
+		if !isValidIdentifier(spec.fromName) {
+			return nil, fmt.Errorf("-from: invalid identifier %q", spec.fromName)
+		}
+		if spec.fromName == rn.To {
+			return nil, fmt.Errorf("renaming %s: the old and new names are the same: %s", rn.From, rn.To)
+		}
+		specs[i] = spec
+	}
+
+	conf := moduleConfig(cfg)
+	mods, err := mainModules(&conf)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, mod := range mods {
+		patterns = append(patterns, mod+"/...")
+	}
+	prog, err := loadModulePackages(&conf, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]bool)
+	for pkg := range prog.packages {
+		paths[pkg.Path()] = true
+	}
+	for i, spec := range specs {
+		if spec.filename != "" {
+			if _, err := os.Stat(spec.filename); err != nil {
+				return nil, fmt.Errorf("no such file: %s", spec.filename)
+			}
+		} else if !paths[spec.pkg] {
+			// The package may be a relative path.
+			if spec.pkg, err = packagePath(&conf, spec.pkg); err != nil {
+				return nil, fmt.Errorf("renaming %s: %v", renamings[i].From, err)
+			}
+		}
+	}
+
+	// -- Check the renamings ---------------------------------------------
+
+	names := make(map[types.Object]string) // the new names of the objects to update
+	oldNames := make(map[types.Object]string)
+	affected := make(map[*types.Package]*loader.PackageInfo)
+	hadConflicts := false
+	for i, spec := range specs {
+		rn := renamings[i]
+		fromObjects, err := prog.findFromObjects(spec)
+		if err != nil {
+			return nil, err
+		}
+		pkgs := prog.affected(fromObjects)
+		r := newRenamer(prog.iprog, pkgs, fromObjects, spec.fromName, rn.To)
+		for _, from := range fromObjects {
+			r.check(from)
+		}
+		hadConflicts = hadConflicts || r.hadConflicts
+		for _, obj := range sortedObjects(r.objsToUpdate) {
+			if prev, ok := names[obj]; ok && prev != rn.To {
+				reportError(prog.iprog.Fset.Position(obj.Pos()),
+					fmt.Sprintf("this %s %q would be renamed to both %q and %q",
+						objectKind(obj), obj.Name(), prev, rn.To))
+				hadConflicts = true
+				continue
+			}
+			names[obj] = rn.To
+			oldNames[obj] = spec.fromName
+		}
+		for pkg, info := range pkgs {
+			affected[pkg] = info
+		}
+	}
+
+	// Objects of the same block, struct or type given the same name.
+	type slot struct {
+		container, name string
+	}
+	taken := make(map[slot]types.Object)
+	objs := make(map[types.Object]bool)
+	for obj := range names {
+		objs[obj] = true
+	}
+	for _, obj := range sortedObjects(objs) {
+		container := renamingContainer(prog.iprog.Fset, affected, obj)
+		if container == "" {
+			continue
+		}
+		k := slot{container, names[obj]}
+		other, ok := taken[k]
+		if !ok {
+			taken[k] = obj
+			continue
+		}
+		if other.Pos() == obj.Pos() {
+			continue // the same object, in another variant of its package
+		}
+		reportError(prog.iprog.Fset.Position(obj.Pos()),
+			fmt.Sprintf("renaming this %s %q to %q", objectKind(obj), obj.Name(), names[obj]))
+		reportError(prog.iprog.Fset.Position(other.Pos()),
+			fmt.Sprintf("\tconflicts with the renaming of this %s %q to the same name", objectKind(other), other.Name()))
+		hadConflicts = true
+	}
+
+	if hadConflicts && !Force {
+		return res, ConflictError
+	}
+
+	// -- Compute the edits -----------------------------------------------
+
+	fset := prog.iprog.Fset
+	type key struct {
+		filename string
+		start    int
+	}
+	edits := make(map[key]Edit)
+	var order []key
+	addEdit := func(pos token.Pos, end token.Pos, text string) {
+		posn, endPosn := fset.Position(pos), fset.Position(end)
+		k := key{posn.Filename, posn.Offset}
+		if prev, ok := edits[k]; ok {
+			// The comment is edited by another renaming.
+			prev.New = text
+			edits[k] = prev
+			return
+		}
+		edits[k] = Edit{Filename: posn.Filename, Start: posn.Offset, End: endPosn.Offset, New: text}
+		order = append(order, k)
+	}
+	r := &renamer{iprog: prog.iprog} // for docComment
+	renamed := make(map[key]bool)
+	comments := make(map[key]string) // the new texts of the edited comments
+	docRegexps := make(map[string]*regexp.Regexp)
+	filesToUpdate := make(map[*token.File]bool)
+	for _, info := range affected {
+		for id, obj := range info.Defs {
+			if to, ok := names[obj]; ok {
+				if k := (key{fset.Position(id.Pos()).Filename, fset.Position(id.Pos()).Offset}); !renamed[k] {
+					renamed[k] = true
+					res.Occurrences++
+					addEdit(id.Pos(), id.End(), to)
+					filesToUpdate[fset.File(id.Pos())] = true
+
+					// Perform the rename in doc comments too.
+					from := oldNames[obj]
+					re := docRegexps[from]
+					if re == nil {
+						re = regexp.MustCompile(`\b` + from + `\b`)
+						docRegexps[from] = re
+					}
+					if doc := r.docComment(id); doc != nil {
+						for _, comment := range doc.List {
+							ck := key{fset.Position(comment.Pos()).Filename, fset.Position(comment.Pos()).Offset}
+							text, ok := comments[ck]
+							if !ok {
+								text = comment.Text
+							}
+							if newText := re.ReplaceAllString(text, to); newText != text {
+								comments[ck] = newText
+								addEdit(comment.Pos(), comment.End(), newText)
+							}
+						}
+					}
+				}
+			}
+		}
+		for id, obj := range info.Uses {
+			if to, ok := names[obj]; ok {
+				if k := (key{fset.Position(id.Pos()).Filename, fset.Position(id.Pos()).Offset}); !renamed[k] {
+					renamed[k] = true
+					res.Occurrences++
+					addEdit(id.Pos(), id.End(), to)
+					filesToUpdate[fset.File(id.Pos())] = true
+				}
+			}
+		}
+	}
+
+	// Renaming not supported if generated files are affected.
+	var generatedFileNames []string
+	isGenerated := make(map[*token.File]bool)
+	for _, info := range affected {
+		for _, f := range info.Files {
+			tokenFile := fset.File(f.Pos())
+			if filesToUpdate[tokenFile] && !isGenerated[tokenFile] && generated(f, tokenFile) {
+				isGenerated[tokenFile] = true
+				generatedFileNames = append(generatedFileNames, tokenFile.Name())
+			}
+		}
+	}
+	if !Force && len(generatedFileNames) > 0 {
+		sort.Strings(generatedFileNames)
+		return nil, fmt.Errorf("refusing to modify generated file%s containing DO NOT EDIT marker: %v", plural(len(generatedFileNames)), generatedFileNames)
+	}
+
+	for _, k := range order {
+		res.Edits = append(res.Edits, edits[k])
+	}
+	sort.Slice(res.Edits, func(i, j int) bool {
+		x, y := res.Edits[i], res.Edits[j]
+		if x.Filename != y.Filename {
+			return x.Filename < y.Filename
+		}
+		return x.Start < y.Start
+	})
+
+	// Read the files, which must not have changed since they were loaded.
+	read := make(map[string]bool)
+	for tokenFile := range filesToUpdate {
+		filename := tokenFile.Name()
+		if read[filename] {
+			continue
+		}
+		read[filename] = true
+		src, ok := conf.Overlay[filename]
+		if !ok {
+			if src, err = ioutil.ReadFile(filename); err != nil {
+				return nil, err
+			}
+		}
+		if len(src) != tokenFile.Size() {
+			return nil, fmt.Errorf("%s changed during the renaming", filename)
+		}
+		res.set.SetContent(filename, src)
+	}
+	// The edits of distinct identifiers and comments don't overlap.
+	if err := res.set.Add(res.Edits...); err != nil {
+		return nil, err
+	}
+	if Verbose {
+		log.Printf("Renamed %d occurrence%s in %d file%s.", res.Occurrences, plural(res.Occurrences),
+			len(read), plural(len(read)))
+	}
+	return res, nil
+}
+
+// sortedObjects returns the objects of the set, in the order of their
+// positions.
+func sortedObjects(set map[types.Object]bool) []types.Object {
+	objs := make([]types.Object, 0, len(set))
+	for obj := range set {
+		objs = append(objs, obj)
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].Pos() != objs[j].Pos() {
+			return objs[i].Pos() < objs[j].Pos()
+		}
+		return objs[i].Pkg().Path() < objs[j].Pkg().Path()
+	})
+	return objs
+}
+
+// renamingContainer returns a description of what contains obj, among
+// whose members the names must be distinct: the struct of a field, the
+// named type of a method, or the block of the other objects. The
+// description is the same for all the variants of a package. It
+// returns "" if there is no such container, as for the methods of an
+// unnamed interface.
+func renamingContainer(fset *token.FileSet, packages map[*types.Package]*loader.PackageInfo, obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Var:
+		if obj.IsField() {
+			info := packages[obj.Pkg()]
+			if info == nil {
+				return ""
+			}
+			for _, f := range info.Files {
+				if f.Pos() <= obj.Pos() && obj.Pos() < f.End() {
+					path, _ := astutil.PathEnclosingInterval(f, obj.Pos(), obj.Pos())
+					for _, n := range path {
+						if st, ok := n.(*ast.StructType); ok {
+							return fmt.Sprintf("struct at %s", fset.Position(st.Pos()))
+						}
+					}
+				}
+			}
+			return ""
+		}
+	case *types.Func:
+		if recv := recv(obj); recv != nil {
+			if named, ok := deref(recv.Type()).(*types.Named); ok {
+				return fmt.Sprintf("type at %s", fset.Position(named.Obj().Pos()))
+			}
+			return ""
+		}
+	}
+	if isPackageLevel(obj) {
+		return "package " + obj.Pkg().Path()
+	}
+	if scope := obj.Parent(); scope != nil && scope.Pos().IsValid() {
+		return fmt.Sprintf("block at %s", fset.Position(scope.Pos()))
+	}
+	return ""
+}
+
+// Contents returns the new contents of the files that the renamings
+// change, by filename.
+func (res *BatchResult) Contents() map[string][]byte {
+	// This can't fail: the contents were checked against the files.
+	contents, _ := res.set.Contents()
+	return contents
+}
+
+// Apply writes the changed files.
+func (res *BatchResult) Apply() error {
+	contents := res.Contents()
+	var nerrs int
+	for _, filename := range res.set.Filenames() {
+		if err := writeFile(filename, contents[filename]); err != nil {
+			log.Print(err)
+			nerrs++
+		}
+	}
+	if nerrs > 0 {
+		return fmt.Errorf("failed to rewrite %d file%s", nerrs, plural(nerrs))
+	}
+	return nil
+}
+
+// Diff writes to w the changes to the files, as unified diffs of the
+// files relative to the working directory, that may be applied with
+// patch -p0.
+func (res *BatchResult) Diff(w io.Writer) error {
+	return res.set.Diff(w, wd)
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rename
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages/packagestest"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestBatch(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	exported := packagestest.Export(t, packagestest.Modules, []packagestest.Module{{
+		Name:  "example.com/m",
+		Files: moduleFiles,
+	}})
+	defer exported.Cleanup()
+
+	for _, test := range []struct {
+		name      string
+		renamings []Renaming
+		want      map[string][]string // substrings of the rewritten files, by name
+		wantErr   string              // regexp to match the conflicts, if any
+	}{
+		{
+			name: "several",
+			renamings: []Renaming{
+				{`"example.com/m/p".X`, "Y"},
+				{`"example.com/m/p"::y`, "z"},
+				{`"example.com/m/p".I.M`, "N"},
+			},
+			want: map[string][]string{
+				"p/p.go":      {"// Y returns y.", "func Y() int { return z }", "var z = 1", "interface{ N() }"},
+				"p/p_test.go": {"_ = Y() + z"},
+				"p/x_test.go": {"fmt.Println(p.Y())"},
+				"q/q.go":      {"var Z = p.Y()", "func (U) N() {}"},
+			},
+		},
+		{
+			name: "same_name",
+			renamings: []Renaming{
+				{`"example.com/m/p".X`, "W"},
+				{`"example.com/m/p"::y`, "W"},
+			},
+			wantErr: `renaming this var "y" to "W"\n\tconflicts with the renaming of this func "X" to the same name`,
+		},
+		{
+			name: "twice",
+			renamings: []Renaming{
+				{`"example.com/m/p".X`, "A"},
+				{`"example.com/m/p".X`, "B"},
+			},
+			wantErr: `this func "X" would be renamed to both "A" and "B"`,
+		},
+		{
+			name: "individual_conflict",
+			renamings: []Renaming{
+				{`"example.com/m/p".X`, "Y"},
+				{`"example.com/m/p"::y`, "X"},
+			},
+			wantErr: `renaming this var "y" to "X".*\n.*conflicts with func in same block`,
+		},
+	} {
+		res, err := Batch(exported.Config, test.renamings)
+		if test.wantErr != "" {
+			if err != ConflictError {
+				t.Errorf("%s: got error %v, want ConflictError", test.name, err)
+				continue
+			}
+			var conflicts bytes.Buffer
+			for _, c := range res.Conflicts {
+				conflicts.WriteString(c.Message + "\n")
+			}
+			if !regexp.MustCompile(test.wantErr).MatchString(conflicts.String()) {
+				t.Errorf("%s: got conflicts %q, want match for %q", test.name, conflicts.String(), test.wantErr)
+			}
+			if len(res.Edits) > 0 {
+				t.Errorf("%s: got %d edits despite the conflicts", test.name, len(res.Edits))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		got := make(map[string]string)
+		for filename, content := range res.Contents() {
+			got[filepath.ToSlash(filename)] = string(content)
+		}
+		for file, wants := range test.want {
+			filename := filepath.ToSlash(exported.File("example.com/m", file))
+			content, ok := got[filename]
+			delete(got, filename)
+			if !ok {
+				t.Errorf("%s: file %s not rewritten", test.name, file)
+				continue
+			}
+			for _, want := range wants {
+				if !strings.Contains(content, want) {
+					t.Errorf("%s: rewritten file %s does not contain %q:\n%s", test.name, file, want, content)
+				}
+			}
+		}
+		for filename := range got {
+			t.Errorf("%s: unexpected rewrite of file %s", test.name, filename)
+		}
+	}
+}
+
+func TestBatchDiff(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	exported := packagestest.Export(t, packagestest.Modules, []packagestest.Module{{
+		Name:  "example.com/m",
+		Files: moduleFiles,
+	}})
+	defer exported.Cleanup()
+
+	res, err := Batch(exported.Config, []Renaming{
+		{`"example.com/m/p"::y`, "z"},
+		{`"example.com/m/q".Z`, "V"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Occurrences != 4 {
+		t.Errorf("got %d occurrences, want 4", res.Occurrences)
+	}
+	var out bytes.Buffer
+	if err := res.Diff(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"-var y = 1\n+var z = 1\n",
+		"-func TestX(t *testing.T) { _ = X() + y }\n+func TestX(t *testing.T) { _ = X() + z }\n",
+		"-var Z = p.X()\n+var V = p.X()\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("diff does not contain %q:\n%s", want, out.String())
+		}
+	}
+	if n := strings.Count(out.String(), "+++ "); n != 3 {
+		t.Errorf("got %d diffs, want 3:\n%s", n, out.String())
+	}
+}
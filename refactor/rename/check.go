@@ -12,6 +12,7 @@ import (
 	"go/token"
 	"go/types"
 
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/loader"
 	"golang.org/x/tools/refactor/satisfy"
 )
@@ -22,6 +23,24 @@ func (r *renamer) errorf(pos token.Pos, format string, args ...interface{}) {
 	reportError(r.iprog.Fset.Position(pos), fmt.Sprintf(format, args...))
 }
 
+// enclosing returns the package of the 'from' object and the path to
+// its declaration.  Unlike iprog.PathEnclosingInterval, it returns the
+// package of the object even if its file belongs to several packages,
+// as a package and its test variant loaded by ModuleMain share files.
+func (r *renamer) enclosing(from types.Object) (*loader.PackageInfo, []ast.Node) {
+	if info := r.packages[from.Pkg()]; info != nil {
+		for _, f := range info.Files {
+			tf := r.iprog.Fset.File(f.Pos())
+			if tf.Base() <= int(from.Pos()) && int(from.Pos()) < tf.Base()+tf.Size() {
+				path, _ := astutil.PathEnclosingInterval(f, from.Pos(), from.Pos())
+				return info, path
+			}
+		}
+	}
+	info, path, _ := r.iprog.PathEnclosingInterval(from.Pos(), from.Pos())
+	return info, path
+}
+
 // check performs safety checks of the renaming of the 'from' object to r.to.
 func (r *renamer) check(from types.Object) {
 	if r.objsToUpdate[from] {
@@ -69,7 +88,7 @@ func (r *renamer) checkInFileBlock(from *types.PkgName) {
 	r.checkInLexicalScope(from, r.packages[from.Pkg()])
 
 	// Finally, modify ImportSpec syntax to add or remove the Name as needed.
-	info, path, _ := r.iprog.PathEnclosingInterval(from.Pos(), from.Pos())
+	info, path := r.enclosing(from)
 	if from.Imported().Name() == r.to {
 		// ImportSpec.Name not needed
 		path[1].(*ast.ImportSpec).Name = nil
@@ -168,7 +187,7 @@ func (r *renamer) checkInLocalScope(from types.Object) {
 
 	// Finally, if this was a type switch, change the variable y.
 	if isCaseVar {
-		_, path, _ := r.iprog.PathEnclosingInterval(from.Pos(), from.Pos())
+		_, path := r.enclosing(from)
 		path[0].(*ast.Ident).Name = r.to // path is [Ident AssignStmt TypeSwitchStmt...]
 	}
 }
@@ -412,7 +431,7 @@ func (r *renamer) checkStructField(from *types.Var) {
 	// go/types offers no easy way to get from a field (or interface
 	// method) to its declaring struct (or interface), so we must
 	// ascend the AST.
-	info, path, _ := r.iprog.PathEnclosingInterval(from.Pos(), from.Pos())
+	info, path := r.enclosing(from)
 	// path matches this pattern:
 	// [Ident SelectorExpr? StarExpr? Field FieldList StructType ParenExpr* ... File]
 
@@ -0,0 +1,412 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rename
+
+// This file defines ModuleMain, which renames the objects of the
+// packages of the main modules, loaded with go/packages, rather than
+// those of the packages of a GOPATH workspace.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/edits"
+	"golang.org/x/tools/internal/gocommand"
+)
+
+// ModuleMain is like Main, but in module mode: it loads the packages
+// with go/packages, configured by cfg, which may be nil, instead of a
+// build.Context. A local renaming affects only the package specified by
+// -from or -offset and its tests; a potentially global one affects all
+// packages of the main modules (those of the go.work workspace, if any)
+// that depend on the package of the renamed object, which must itself
+// be in a main module.
+//
+// With Diff, the changes are printed as unified diffs, of the files
+// relative to the working directory, that may be applied with patch -p0;
+// DiffCmd is not used.
+func ModuleMain(cfg *packages.Config, offsetFlag, fromFlag, to string) error {
+	// -- Parse the -from or -offset specifier ----------------------------
+
+	if (offsetFlag == "") == (fromFlag == "") {
+		return fmt.Errorf("exactly one of the -from and -offset flags must be specified")
+	}
+
+	if !isValidIdentifier(to) {
+		return fmt.Errorf("-to %q: not a valid identifier", to)
+	}
+
+	if Diff {
+		defer func(saved func(string, []byte) error) { writeFile = saved }(writeFile)
+		writeFile = unifiedDiff
+	}
+
+	// A package and its test variant are checked separately, and
+	// would report their conflicts twice.
+	defer func(saved func(token.Position, string)) { reportError = saved }(reportError)
+	report := reportError
+	reported := make(map[string]bool)
+	reportError = func(posn token.Position, message string) {
+		if key := posn.String() + "\x00" + message; !reported[key] {
+			reported[key] = true
+			report(posn, message)
+		}
+	}
+
+	conf := moduleConfig(cfg)
+
+	var spec *spec
+	var err error
+	if fromFlag != "" {
+		spec, err = parseModuleFromFlag(&conf, fromFlag)
+	} else {
+		spec, err = parseModuleOffsetFlag(&conf, offsetFlag)
+	}
+	if err != nil {
+		return err
+	}
+
+	if spec.fromName == to {
+		return fmt.Errorf("the old and new names are the same: %s", to)
+	}
+
+	// -- Load the package, or the packages of a file ---------------------
+
+	pattern := spec.pkg
+	if spec.filename != "" {
+		abs, err := filepath.Abs(spec.filename)
+		if err != nil {
+			return err
+		}
+		pattern = "file=" + abs
+	}
+	prog, err := loadModulePackages(&conf, pattern)
+	if err != nil {
+		return err
+	}
+	fromObjects, err := prog.findFromObjects(spec)
+	if err != nil {
+		return err
+	}
+
+	// -- Load the main modules, for global renamings ---------------------
+
+	if requiresGlobalRename(fromObjects, to) {
+		if Verbose {
+			log.Print("Potentially global renaming; loading the main modules...")
+		}
+		mods, err := mainModules(&conf)
+		if err != nil {
+			return err
+		}
+		var patterns []string
+		for _, mod := range mods {
+			patterns = append(patterns, mod+"/...")
+		}
+		prog, err = loadModulePackages(&conf, patterns...)
+		if err != nil {
+			return err
+		}
+		fromObjects, err = prog.findFromObjects(spec)
+		if err != nil {
+			return err
+		}
+	}
+
+	// -- Do the renaming -------------------------------------------------
+
+	return renameObjects(prog.iprog, prog.affected(fromObjects), fromObjects, spec.fromName, to)
+}
+
+// moduleConfig returns the configuration of the loading of the
+// packages to rename, based on cfg, which may be nil.
+func moduleConfig(cfg *packages.Config) packages.Config {
+	var conf packages.Config
+	if cfg != nil {
+		conf = *cfg
+	}
+	conf.Mode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+		packages.NeedImports | packages.NeedDeps | packages.NeedTypes |
+		packages.NeedSyntax | packages.NeedTypesInfo | packages.NeedModule
+	conf.Tests = true
+	if conf.Fset == nil {
+		conf.Fset = token.NewFileSet()
+	}
+	return conf
+}
+
+// parseModuleFromFlag interprets the "-from" flag value as a renaming
+// specification, as parseFromFlag does, but in module mode.
+func parseModuleFromFlag(cfg *packages.Config, fromFlag string) (*spec, error) {
+	spec, err := parseFromSpec(fromFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.filename != "" {
+		if _, err := os.Stat(spec.filename); err != nil {
+			return nil, fmt.Errorf("no such file: %s", spec.filename)
+		}
+	} else {
+		// Sanitize the package, which may be a relative path.
+		spec.pkg, err = packagePath(cfg, spec.pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !isValidIdentifier(spec.fromName) {
+		return nil, fmt.Errorf("-from: invalid identifier %q", spec.fromName)
+	}
+
+	if Verbose {
+		log.Printf("-from spec: %+v", spec)
+	}
+
+	return spec, nil
+}
+
+// packagePath returns the path of the package denoted by pkg, which
+// may be a relative path.
+func packagePath(cfg *packages.Config, pkg string) (string, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:       packages.NeedName,
+		Context:    cfg.Context,
+		Dir:        cfg.Dir,
+		Env:        cfg.Env,
+		BuildFlags: cfg.BuildFlags,
+	}, pkg)
+	if err != nil {
+		return "", err
+	}
+	if len(pkgs) != 1 || pkgs[0].PkgPath == "" || len(pkgs[0].GoFiles) == 0 && len(pkgs[0].Errors) > 0 {
+		return "", fmt.Errorf("can't find package %q", pkg)
+	}
+	return pkgs[0].PkgPath, nil
+}
+
+// parseModuleOffsetFlag interprets the "-offset" flag value as a renaming
+// specification, as parseOffsetFlag does, but in module mode.
+func parseModuleOffsetFlag(cfg *packages.Config, offsetFlag string) (*spec, error) {
+	spec, err := parseOffsetSpec(offsetFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the file and check there's an identifier at that offset.
+	src, err := ioutil.ReadFile(spec.filename)
+	if err != nil {
+		return nil, fmt.Errorf("no such file: %s", spec.filename)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, spec.filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("-offset %q: cannot parse file: %s", offsetFlag, err)
+	}
+
+	id := identAtOffset(fset, f, spec.offset)
+	if id == nil {
+		return nil, fmt.Errorf("-offset %q: no identifier at this position", offsetFlag)
+	}
+
+	spec.fromName = id.Name
+
+	return spec, nil
+}
+
+// A moduleProgram is a program loaded by go/packages, presented as a
+// loader.Program for the rest of the renamer.
+type moduleProgram struct {
+	iprog    *loader.Program
+	packages map[*types.Package]*packages.Package
+}
+
+// loadModulePackages loads the packages matching the patterns, with
+// their tests and all their dependencies, from source.
+func loadModulePackages(cfg *packages.Config, patterns ...string) (*moduleProgram, error) {
+	if Verbose {
+		for _, pattern := range patterns {
+			log.Printf("Loading packages: %s", pattern)
+		}
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	prog := &moduleProgram{
+		iprog: &loader.Program{
+			Fset:        cfg.Fset,
+			AllPackages: make(map[*types.Package]*loader.PackageInfo),
+		},
+		packages: make(map[*types.Package]*packages.Package),
+	}
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		if p.Types == nil || p.TypesInfo == nil {
+			return
+		}
+		// go/packages does not record whether type errors are soft,
+		// so all errors are considered hard.
+		var errs []error
+		for _, err := range p.Errors {
+			errs = append(errs, err)
+		}
+		prog.iprog.AllPackages[p.Types] = &loader.PackageInfo{
+			Pkg:                   p.Types,
+			Importable:            true,
+			TransitivelyErrorFree: !p.IllTyped,
+			Files:                 p.Syntax,
+			Errors:                errs,
+			Info:                  *p.TypesInfo,
+		}
+		prog.packages[p.Types] = p
+	})
+	if err := programErrors(prog.iprog); err != nil {
+		packages.PrintErrors(pkgs)
+		return nil, err
+	}
+	return prog, nil
+}
+
+// findFromObjects returns the objects that the spec denotes, as
+// findFromObjects does, and the objects that they declare in the other
+// variants of their packages, which share their syntax.
+func (prog *moduleProgram) findFromObjects(spec *spec) ([]types.Object, error) {
+	fromObjects, err := findFromObjects(prog.iprog, spec)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[types.Object]bool)
+	var objects []types.Object
+	add := func(obj types.Object) {
+		if !seen[obj] {
+			seen[obj] = true
+			objects = append(objects, obj)
+		}
+	}
+	for _, from := range fromObjects {
+		if p := prog.packages[from.Pkg()]; p == nil || p.Module == nil || !p.Module.Main {
+			return nil, fmt.Errorf("cannot rename %s: package %s is not in a main module",
+				from.Name(), from.Pkg().Path())
+		}
+		add(from)
+		for pkg, info := range prog.iprog.AllPackages {
+			if pkg == from.Pkg() || pkg.Path() != from.Pkg().Path() {
+				continue
+			}
+			for _, obj := range info.Defs {
+				if sameDecl(obj, from) {
+					add(obj)
+				}
+			}
+			for _, obj := range info.Implicits {
+				if sameDecl(obj, from) {
+					add(obj)
+				}
+			}
+		}
+	}
+	return objects, nil
+}
+
+// sameDecl reports whether obj, of a variant of the package of from, is
+// declared by the same syntax as from.
+func sameDecl(obj, from types.Object) bool {
+	return obj != nil && obj.Pos() == from.Pos() && obj.Name() == from.Name() &&
+		objectKind(obj) == objectKind(from)
+}
+
+// affected returns the packages to inspect or modify: those of the
+// main modules that are the packages of the 'from' objects, or depend
+// on them, leaving out test main packages.
+func (prog *moduleProgram) affected(fromObjects []types.Object) map[*types.Package]*loader.PackageInfo {
+	fromPaths := make(map[string]bool)
+	for _, obj := range fromObjects {
+		fromPaths[obj.Pkg().Path()] = true
+	}
+
+	memo := make(map[*packages.Package]bool)
+	var depends func(p *packages.Package) bool
+	depends = func(p *packages.Package) bool {
+		result, ok := memo[p]
+		if !ok {
+			memo[p] = false // (import cycles are errors)
+			result = fromPaths[p.PkgPath]
+			for _, imp := range p.Imports {
+				if result {
+					break
+				}
+				result = depends(imp)
+			}
+			memo[p] = result
+		}
+		return result
+	}
+
+	affected := make(map[*types.Package]*loader.PackageInfo)
+	for pkg, p := range prog.packages {
+		if p.Module == nil || !p.Module.Main || p.Name == "main" && strings.HasSuffix(p.PkgPath, ".test") {
+			continue
+		}
+		if depends(p) {
+			affected[pkg] = prog.iprog.AllPackages[pkg]
+		}
+	}
+	return affected
+}
+
+// mainModules returns the paths of the main modules, which are several
+// in workspace mode.
+func mainModules(cfg *packages.Config) ([]string, error) {
+	ctx := cfg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	stdout, err := new(gocommand.Runner).Run(ctx, gocommand.Invocation{
+		Verb:       "list",
+		Args:       []string{"-m", "-json"},
+		Env:        cfg.Env,
+		WorkingDir: cfg.Dir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for dec := json.NewDecoder(stdout); ; {
+		var mod gocommand.ModuleJSON
+		if err := dec.Decode(&mod); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("JSON decoding failed: %v", err)
+		}
+		paths = append(paths, mod.Path)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no main module")
+	}
+	return paths, nil
+}
+
+// unifiedDiff prints the changes to the named file as a unified diff,
+// for the -d flag of ModuleMain.
+func unifiedDiff(filename string, content []byte) error {
+	before, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return edits.WriteUnifiedDiff(stdout, wd, filename, before, content)
+}
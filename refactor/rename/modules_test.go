@@ -0,0 +1,213 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rename
+
+import (
+	"bytes"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages/packagestest"
+	"golang.org/x/tools/internal/testenv"
+)
+
+var moduleFiles = map[string]interface{}{
+	"p/p.go": `package p
+
+// X returns y.
+func X() int { return y }
+
+var y = 1
+
+type I interface{ M() }
+`,
+	"p/p_test.go": `package p
+
+import "testing"
+
+func TestX(t *testing.T) { _ = X() + y }
+`,
+	"p/x_test.go": `package p_test
+
+import (
+	"fmt"
+	"testing"
+
+	"example.com/m/p"
+)
+
+func TestXX(t *testing.T) { fmt.Println(p.X()) }
+`,
+	"q/q.go": `package q
+
+import "example.com/m/p"
+
+var Z = p.X()
+
+type U struct{}
+
+func (U) M() {}
+
+var _ p.I = U{}
+`,
+}
+
+func TestModuleMain(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	exported := packagestest.Export(t, packagestest.Modules, []packagestest.Module{{
+		Name:  "example.com/m",
+		Files: moduleFiles,
+	}})
+	defer exported.Cleanup()
+
+	defer func(savedWriteFile func(string, []byte) error, savedReportError func(token.Position, string)) {
+		writeFile = savedWriteFile
+		reportError = savedReportError
+	}(writeFile, reportError)
+
+	for _, test := range []struct {
+		from, to string
+		want     map[string][]string // substrings of the rewritten files, by name
+		wantErr  string              // regexp to match the error and conflicts, if any
+	}{
+		// Global renaming, in the tests and the other packages.
+		{
+			from: `"example.com/m/p".X`, to: "Y",
+			want: map[string][]string{
+				"p/p.go":      {"// Y returns y.", "func Y() int"},
+				"p/p_test.go": {"_ = Y() + y"},
+				"p/x_test.go": {"fmt.Println(p.Y())"},
+				"q/q.go":      {"var Z = p.Y()"},
+			},
+		},
+		// Local renaming, in the in-package tests.
+		{
+			from: `"example.com/m/p"::y`, to: "z",
+			want: map[string][]string{
+				"p/p.go":      {"return z", "var z = 1"},
+				"p/p_test.go": {"_ = X() + z"},
+			},
+		},
+		// Renaming of an interface method and its implementations.
+		{
+			from: `"example.com/m/p".I.M`, to: "N",
+			want: map[string][]string{
+				"p/p.go": {"interface{ N() }"},
+				"q/q.go": {"func (U) N() {}"},
+			},
+		},
+		// Renaming of an object of a test file.
+		{
+			from: `p/p_test.go::t`, to: "tt",
+			want: map[string][]string{
+				"p/p_test.go": {"func TestX(tt *testing.T)"},
+			},
+		},
+		// Conflicts.
+		{
+			from: `"example.com/m/p"::y`, to: "X",
+			wantErr: `renaming this var "y" to "X".*\n.*conflicts with func in same block`,
+		},
+		{
+			from: `"example.com/m/q".U.M`, to: "N",
+			wantErr: `renaming this method "M" to "N".*\n.*would make example.com/m/q.U no longer assignable to interface I`,
+		},
+		// Objects outside the main module.
+		{
+			from: `"fmt".Println`, to: "Print",
+			wantErr: `cannot rename Println: package fmt is not in a main module`,
+		},
+	} {
+		got := make(map[string]string)
+		writeFile = func(filename string, content []byte) error {
+			got[filepath.ToSlash(filename)] = string(content)
+			return nil
+		}
+		var conflicts bytes.Buffer
+		reportError = func(posn token.Position, message string) {
+			conflicts.WriteString(message + "\n")
+		}
+
+		from := test.from
+		if strings.HasSuffix(from, ".go::t") {
+			from = exported.File("example.com/m", "p/p_test.go") + "::t"
+		}
+		err := ModuleMain(exported.Config, "", from, test.to)
+		if test.wantErr != "" {
+			if err == nil {
+				t.Errorf("-from %q -to %q: got success, want error %q", test.from, test.to, test.wantErr)
+				continue
+			}
+			if got := conflicts.String() + err.Error(); !regexp.MustCompile(test.wantErr).MatchString(got) {
+				t.Errorf("-from %q -to %q: got error %q, want match for %q", test.from, test.to, got, test.wantErr)
+			}
+			if n := strings.Count(conflicts.String(), "renaming this"); n > 1 {
+				t.Errorf("-from %q -to %q: conflict reported %d times", test.from, test.to, n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("-from %q -to %q: unexpected error: %s", test.from, test.to, err)
+			continue
+		}
+		for file, wants := range test.want {
+			filename := filepath.ToSlash(exported.File("example.com/m", file))
+			content, ok := got[filename]
+			delete(got, filename)
+			if !ok {
+				t.Errorf("-from %q -to %q: file %s not rewritten", test.from, test.to, file)
+				continue
+			}
+			for _, want := range wants {
+				if !strings.Contains(content, want) {
+					t.Errorf("-from %q -to %q: rewritten file %s does not contain %q:\n%s", test.from, test.to, file, want, content)
+				}
+			}
+		}
+		for filename := range got {
+			t.Errorf("-from %q -to %q: unexpected rewrite of file %s", test.from, test.to, filename)
+		}
+	}
+}
+
+func TestModuleMainDiff(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	exported := packagestest.Export(t, packagestest.Modules, []packagestest.Module{{
+		Name:  "example.com/m",
+		Files: moduleFiles,
+	}})
+	defer exported.Cleanup()
+
+	defer func() {
+		Diff = false
+		stdout = os.Stdout
+	}()
+	Diff = true
+	var out bytes.Buffer
+	stdout = &out
+
+	if err := ModuleMain(exported.Config, "", `"example.com/m/p"::y`, "z"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"--- " + filepath.ToSlash(exported.File("example.com/m", "p/p.go")) + "\n",
+		"-var y = 1\n+var z = 1\n",
+		"-func TestX(t *testing.T) { _ = X() + y }\n+func TestX(t *testing.T) { _ = X() + z }\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("diff does not contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Count(out.String(), "+++ ") != 2 {
+		t.Errorf("got %d diffs, want 2:\n%s", strings.Count(out.String(), "+++ "), out.String())
+	}
+}
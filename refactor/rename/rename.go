@@ -5,6 +5,7 @@
 // Package rename contains the implementation of the 'gorename' command
 // whose main function is in golang.org/x/tools/cmd/gorename.
 // See the Usage constant for the command documentation.
+// Programs that perform many renamings at once may use Batch.
 package rename // import "golang.org/x/tools/refactor/rename"
 
 import (
@@ -30,6 +31,7 @@ import (
 
 	"golang.org/x/tools/go/loader"
 	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/internal/edits"
 	"golang.org/x/tools/refactor/importgraph"
 	"golang.org/x/tools/refactor/satisfy"
 )
@@ -80,7 +82,8 @@ Flags:
            (In due course this bug will be fixed by moving certain
            analyses into the type-checker.)
 
--d         display diffs instead of rewriting files
+-d         display diffs instead of rewriting files.  In module mode,
+           the diffs may be applied with patch -p0.
 
 -v         enables verbose logging.
 
@@ -89,6 +92,12 @@ affected.  For a local renaming, this is just the package specified by
 -from or -offset, but for a potentially exported name, gorename scans
 the workspace ($GOROOT and $GOPATH).
 
+In module mode, that is, when the go command finds a go.mod or go.work
+file, gorename loads the packages with the go command instead, and the
+workspace it scans for a potentially exported name is the set of main
+modules: the module of the current directory, or the modules of the
+go.work file.  Only objects of the main modules may be renamed.
+
 gorename rejects renamings of concrete methods that would change the
 assignability relation between types and interfaces. If the interface
 change was intentional, initiate the renaming at the interface method.
@@ -228,7 +237,7 @@ func Main(ctxt *build.Context, offsetFlag, fromFlag, to string) error {
 
 	if Diff {
 		defer func(saved func(string, []byte) error) { writeFile = saved }(writeFile)
-		writeFile = diff
+		writeFile = externalDiff
 	}
 
 	var spec *spec
@@ -310,39 +319,27 @@ func Main(ctxt *build.Context, offsetFlag, fromFlag, to string) error {
 
 	// -- Do the renaming -------------------------------------------------
 
-	r := renamer{
-		iprog:        iprog,
-		objsToUpdate: make(map[types.Object]bool),
-		from:         spec.fromName,
-		to:           to,
-		packages:     make(map[*types.Package]*loader.PackageInfo),
-	}
-
-	// A renaming initiated at an interface method indicates the
-	// intention to rename abstract and concrete methods as needed
-	// to preserve assignability.
-	for _, obj := range fromObjects {
-		if obj, ok := obj.(*types.Func); ok {
-			recv := obj.Type().(*types.Signature).Recv()
-			if recv != nil && isInterface(recv.Type().Underlying()) {
-				r.changeMethods = true
-				break
-			}
-		}
-	}
-
 	// Only the initially imported packages (iprog.Imported) and
 	// their external tests (iprog.Created) should be inspected or
 	// modified, as only they have type-checked functions bodies.
 	// The rest are just dependencies, needed only for package-level
 	// type information.
+	packages := make(map[*types.Package]*loader.PackageInfo)
 	for _, info := range iprog.Imported {
-		r.packages[info.Pkg] = info
+		packages[info.Pkg] = info
 	}
 	for _, info := range iprog.Created { // (tests)
-		r.packages[info.Pkg] = info
+		packages[info.Pkg] = info
 	}
 
+	return renameObjects(iprog, packages, fromObjects, spec.fromName, to)
+}
+
+// renameObjects renames the 'from' objects to 'to' in the specified
+// packages of iprog, which are those inspected and modified, if the
+// renaming is free of conflicts or Force is set.
+func renameObjects(iprog *loader.Program, packages map[*types.Package]*loader.PackageInfo, fromObjects []types.Object, from, to string) error {
+	r := newRenamer(iprog, packages, fromObjects, from, to)
 	for _, from := range fromObjects {
 		r.check(from)
 	}
@@ -352,6 +349,32 @@ func Main(ctxt *build.Context, offsetFlag, fromFlag, to string) error {
 	return r.update()
 }
 
+// newRenamer returns a renamer of the 'from' objects to 'to' in the
+// specified packages of iprog.
+func newRenamer(iprog *loader.Program, packages map[*types.Package]*loader.PackageInfo, fromObjects []types.Object, from, to string) *renamer {
+	r := &renamer{
+		iprog:        iprog,
+		objsToUpdate: make(map[types.Object]bool),
+		from:         from,
+		to:           to,
+		packages:     packages,
+	}
+
+	// A renaming initiated at an interface method indicates the
+	// intention to rename abstract and concrete methods as needed
+	// to preserve assignability.
+	for _, obj := range fromObjects {
+		if obj, ok := obj.(*types.Func); ok {
+			recv := obj.Type().(*types.Signature).Recv()
+			if recv != nil && isInterface(recv.Type().Underlying()) {
+				r.changeMethods = true
+				break
+			}
+		}
+	}
+	return r
+}
+
 // loadProgram loads the specified set of packages (plus their tests)
 // and all their dependencies, from source, through the specified build
 // context.  Only packages in pkgs will have their functions bodies typechecked.
@@ -395,23 +418,35 @@ func loadProgram(ctxt *build.Context, pkgs map[string]bool) (*loader.Program, er
 		return nil, err
 	}
 
+	if err := programErrors(prog); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+// programErrors returns an error naming the packages of prog that have
+// hard errors, if any.
+func programErrors(prog *loader.Program) error {
 	var errpkgs []string
+	seen := make(map[string]bool) // (a package and its test variant have the same path)
 	// Report hard errors in indirectly imported packages.
 	for _, info := range prog.AllPackages {
-		if containsHardErrors(info.Errors) {
+		if containsHardErrors(info.Errors) && !seen[info.Pkg.Path()] {
+			seen[info.Pkg.Path()] = true
 			errpkgs = append(errpkgs, info.Pkg.Path())
 		}
 	}
 	if errpkgs != nil {
+		sort.Strings(errpkgs)
 		var more string
 		if len(errpkgs) > 3 {
 			more = fmt.Sprintf(" and %d more", len(errpkgs)-3)
 			errpkgs = errpkgs[:3]
 		}
-		return nil, fmt.Errorf("couldn't load packages due to errors: %s%s",
+		return fmt.Errorf("couldn't load packages due to errors: %s%s",
 			strings.Join(errpkgs, ", "), more)
 	}
-	return prog, nil
+	return nil
 }
 
 func containsHardErrors(errors []error) bool {
@@ -456,13 +491,18 @@ func (r *renamer) update() error {
 	// belong to multiple packages and be parsed more than once.
 	// token.File captures this distinction; filename does not.
 
+	// The packages loaded by ModuleMain may also share syntax trees:
+	// a package and its test variant have the same files.
+
 	var nidents int
 	var filesToUpdate = make(map[*token.File]bool)
+	var renamed = make(map[*ast.Ident]bool)
 	docRegexp := regexp.MustCompile(`\b` + r.from + `\b`)
 	for _, info := range r.packages {
 		// Mutate the ASTs and note the filenames.
 		for id, obj := range info.Defs {
-			if r.objsToUpdate[obj] {
+			if r.objsToUpdate[obj] && !renamed[id] {
+				renamed[id] = true
 				nidents++
 				id.Name = r.to
 				filesToUpdate[r.iprog.Fset.File(id.Pos())] = true
@@ -476,7 +516,8 @@ func (r *renamer) update() error {
 		}
 
 		for id, obj := range info.Uses {
-			if r.objsToUpdate[obj] {
+			if r.objsToUpdate[obj] && !renamed[id] {
+				renamed[id] = true
 				nidents++
 				id.Name = r.to
 				filesToUpdate[r.iprog.Fset.File(id.Pos())] = true
@@ -486,10 +527,12 @@ func (r *renamer) update() error {
 
 	// Renaming not supported if cgo files are affected.
 	var generatedFileNames []string
+	var isGenerated = make(map[*token.File]bool)
 	for _, info := range r.packages {
 		for _, f := range info.Files {
 			tokenFile := r.iprog.Fset.File(f.Pos())
-			if filesToUpdate[tokenFile] && generated(f, tokenFile) {
+			if filesToUpdate[tokenFile] && !isGenerated[tokenFile] && generated(f, tokenFile) {
+				isGenerated[tokenFile] = true
 				generatedFileNames = append(generatedFileNames, tokenFile.Name())
 			}
 		}
@@ -500,18 +543,23 @@ func (r *renamer) update() error {
 
 	// Write affected files.
 	var nerrs, npkgs int
+	var written = make(map[*token.File]bool)
+	var updatedPkgs = make(map[string]bool)
 	for _, info := range r.packages {
-		first := true
 		for _, f := range info.Files {
 			tokenFile := r.iprog.Fset.File(f.Pos())
 			if filesToUpdate[tokenFile] {
-				if first {
+				if !updatedPkgs[info.Pkg.Path()] {
 					npkgs++
-					first = false
+					updatedPkgs[info.Pkg.Path()] = true
 					if Verbose {
 						log.Printf("Updating package %s", info.Pkg.Path())
 					}
 				}
+				if written[tokenFile] {
+					continue
+				}
+				written[tokenFile] = true
 
 				filename := tokenFile.Name()
 				var buf bytes.Buffer
@@ -579,10 +627,10 @@ func plural(n int) string {
 var writeFile = reallyWriteFile
 
 func reallyWriteFile(filename string, content []byte) error {
-	return ioutil.WriteFile(filename, content, 0644)
+	return edits.WriteFile(filename, content)
 }
 
-func diff(filename string, content []byte) error {
+func externalDiff(filename string, content []byte) error {
 	renamed := fmt.Sprintf("%s.%d.renamed", filename, os.Getpid())
 	if err := ioutil.WriteFile(renamed, content, 0644); err != nil {
 		return err
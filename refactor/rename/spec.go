@@ -69,6 +69,49 @@ type spec struct {
 // parseFromFlag interprets the "-from" flag value as a renaming specification.
 // See Usage in rename.go for valid formats.
 func parseFromFlag(ctxt *build.Context, fromFlag string) (*spec, error) {
+	spec, err := parseFromSpec(fromFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.filename != "" {
+		if !buildutil.FileExists(ctxt, spec.filename) {
+			return nil, fmt.Errorf("no such file: %s", spec.filename)
+		}
+
+		bp, err := buildutil.ContainingPackage(ctxt, wd, spec.filename)
+		if err != nil {
+			return nil, err
+		}
+		spec.pkg = bp.ImportPath
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sanitize the package.
+	bp, err := ctxt.Import(spec.pkg, cwd, build.FindOnly)
+	if err != nil {
+		return nil, fmt.Errorf("can't find package %q", spec.pkg)
+	}
+	spec.pkg = bp.ImportPath
+
+	if !isValidIdentifier(spec.fromName) {
+		return nil, fmt.Errorf("-from: invalid identifier %q", spec.fromName)
+	}
+
+	if Verbose {
+		log.Printf("-from spec: %+v", spec)
+	}
+
+	return spec, nil
+}
+
+// parseFromSpec parses the "-from" flag value, without resolving the
+// package or file that it names.
+func parseFromSpec(fromFlag string) (*spec, error) {
 	var spec spec
 	var main string // sans "::x" suffix
 	switch parts := strings.Split(fromFlag, "::"); len(parts) {
@@ -90,16 +133,6 @@ func parseFromFlag(ctxt *build.Context, fromFlag string) (*spec, error) {
 			return nil, fmt.Errorf("-from: filename %q must have a ::name suffix", main)
 		}
 		spec.filename = main
-		if !buildutil.FileExists(ctxt, spec.filename) {
-			return nil, fmt.Errorf("no such file: %s", spec.filename)
-		}
-
-		bp, err := buildutil.ContainingPackage(ctxt, wd, spec.filename)
-		if err != nil {
-			return nil, err
-		}
-		spec.pkg = bp.ImportPath
-
 	} else {
 		// main is one of:
 		//  "importpath"
@@ -113,27 +146,6 @@ func parseFromFlag(ctxt *build.Context, fromFlag string) (*spec, error) {
 	if spec.searchFor != "" {
 		spec.fromName = spec.searchFor
 	}
-
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil, err
-	}
-
-	// Sanitize the package.
-	bp, err := ctxt.Import(spec.pkg, cwd, build.FindOnly)
-	if err != nil {
-		return nil, fmt.Errorf("can't find package %q", spec.pkg)
-	}
-	spec.pkg = bp.ImportPath
-
-	if !isValidIdentifier(spec.fromName) {
-		return nil, fmt.Errorf("-from: invalid identifier %q", spec.fromName)
-	}
-
-	if Verbose {
-		log.Printf("-from spec: %+v", spec)
-	}
-
 	return &spec, nil
 }
 
@@ -205,14 +217,11 @@ func parseImportPath(e ast.Expr) string {
 
 // parseOffsetFlag interprets the "-offset" flag value as a renaming specification.
 func parseOffsetFlag(ctxt *build.Context, offsetFlag string) (*spec, error) {
-	var spec spec
-	// Validate -offset, e.g. file.go:#123
-	parts := strings.Split(offsetFlag, ":#")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("-offset %q: invalid offset specification", offsetFlag)
+	spec, err := parseOffsetSpec(offsetFlag)
+	if err != nil {
+		return nil, err
 	}
 
-	spec.filename = parts[0]
 	if !buildutil.FileExists(ctxt, spec.filename) {
 		return nil, fmt.Errorf("no such file: %s", spec.filename)
 	}
@@ -223,16 +232,6 @@ func parseOffsetFlag(ctxt *build.Context, offsetFlag string) (*spec, error) {
 	}
 	spec.pkg = bp.ImportPath
 
-	for _, r := range parts[1] {
-		if !isDigit(r) {
-			return nil, fmt.Errorf("-offset %q: non-numeric offset", offsetFlag)
-		}
-	}
-	spec.offset, err = strconv.Atoi(parts[1])
-	if err != nil {
-		return nil, fmt.Errorf("-offset %q: non-numeric offset", offsetFlag)
-	}
-
 	// Parse the file and check there's an identifier at that offset.
 	fset := token.NewFileSet()
 	f, err := buildutil.ParseFile(fset, ctxt, nil, wd, spec.filename, parser.ParseComments)
@@ -247,6 +246,31 @@ func parseOffsetFlag(ctxt *build.Context, offsetFlag string) (*spec, error) {
 
 	spec.fromName = id.Name
 
+	return spec, nil
+}
+
+// parseOffsetSpec parses the "-offset" flag value, without reading the
+// file that it names.
+func parseOffsetSpec(offsetFlag string) (*spec, error) {
+	var spec spec
+	// Validate -offset, e.g. file.go:#123
+	parts := strings.Split(offsetFlag, ":#")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("-offset %q: invalid offset specification", offsetFlag)
+	}
+
+	spec.filename = parts[0]
+
+	for _, r := range parts[1] {
+		if !isDigit(r) {
+			return nil, fmt.Errorf("-offset %q: non-numeric offset", offsetFlag)
+		}
+	}
+	var err error
+	spec.offset, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("-offset %q: non-numeric offset", offsetFlag)
+	}
 	return &spec, nil
 }
 
@@ -283,11 +307,13 @@ func findFromObjects(iprog *loader.Program, spec *spec) ([]types.Object, error)
 	// info := iprog.AllPackages[pkg]
 
 	// Workaround: lookup by value.
+	// A program loaded by ModuleMain has a package and its test
+	// variant, which has the in-package tests too, for the same
+	// path; the variant with the most files is searched.
 	var info *loader.PackageInfo
-	var pkg *types.Package
-	for pkg, info = range iprog.AllPackages {
-		if pkg.Path() == spec.pkg {
-			break
+	for pkg, pinfo := range iprog.AllPackages {
+		if pkg.Path() == spec.pkg && (info == nil || len(pinfo.Files) > len(info.Files)) {
+			info = pinfo
 		}
 	}
 	if info == nil {
@@ -8,9 +8,28 @@
 // interface, and this fact is necessary for the package to be
 // well-typed.
 //
-// THIS PACKAGE IS EXPERIMENTAL AND MAY CHANGE AT ANY TIME.
+// Refactoring tools, such as gopls and gorename, use these constraints
+// to preserve the well-typedness of the code that they edit: renaming
+// a method of a type, for example, requires the renaming of the
+// corresponding method of each interface that the type must satisfy,
+// and vice versa.
 //
-// It is provided only for the gopls tool. It requires well-typed inputs.
+// The constraints include those that generic code imposes: the type
+// arguments of each instantiation of a generic function or type must
+// satisfy the constraints of its type parameters. The constraints found
+// within the bodies of generic functions may mention their type
+// parameters.
+//
+// A Finder accumulates the constraints of one or more packages, and
+// optionally the positions of the constructs that impose them:
+//
+//	f := satisfy.Finder{Positions: make(map[satisfy.Constraint][]token.Pos)}
+//	f.Find(pkg.TypesInfo, pkg.Syntax)
+//	for c := range f.Result {
+//		fmt.Printf("%s satisfies %s at %v\n", c.RHS, c.LHS, f.Positions[c])
+//	}
+//
+// It requires well-typed inputs.
 package satisfy // import "golang.org/x/tools/refactor/satisfy"
 
 // NOTES:
@@ -33,6 +52,7 @@ package satisfy // import "golang.org/x/tools/refactor/satisfy"
 // - in explicit conversions T(x)
 // - in sends ch <- x, from x to the channel element type
 // - in type assertions x.(T) and switch x.(type) { case T: }
+// - from type arguments to the constraints of their type parameters
 //
 // The results of this pass provide information equivalent to the
 // ssa.MakeInterface and ssa.ChangeInterface instructions.
@@ -42,6 +62,7 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"sort"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/types/typeutil"
@@ -67,7 +88,17 @@ type Constraint struct {
 // will need to preserve at least this part of the relation to ensure
 // continued compilation.
 type Finder struct {
-	Result    map[Constraint]bool
+	// Result is the set of constraints found. Find populates it,
+	// creating it if it is nil.
+	Result map[Constraint]bool
+
+	// Positions, if not nil, maps each constraint of Result to the
+	// positions of the constructs that impose it, in the order they
+	// were found: the operand of an assignment, a call argument, an
+	// instantiated identifier, and so on. A client that needs the
+	// positions must create the map before calling Find.
+	Positions map[Constraint][]token.Pos
+
 	msetcache typeutil.MethodSetCache
 
 	// per-Find state
@@ -83,7 +114,8 @@ type Finder struct {
 //
 // The package must be free of type errors, and
 // info.{Defs,Uses,Selections,Types} must have been populated by the
-// type-checker.
+// type-checker, as must info.Instances for the constraints of the
+// instantiations of generic functions and types to be found.
 func (f *Finder) Find(info *types.Info, files []*ast.File) {
 	if f.Result == nil {
 		f.Result = make(map[Constraint]bool)
@@ -109,9 +141,46 @@ func (f *Finder) Find(info *types.Info, files []*ast.File) {
 			}
 		}
 	}
+	f.instances(files)
 	f.info = nil
 }
 
+// instances records the constraints that the type arguments of each
+// instantiation within files must satisfy: the constraints of the
+// corresponding type parameters. A constraint that mentions other type
+// parameters of the generic function or type is recorded as is, and is
+// thus quantified over them.
+func (f *Finder) instances(files []*ast.File) {
+	var ids []*ast.Ident
+	for id := range typeparams.GetInstances(f.info) {
+		for _, file := range files {
+			if file.Pos() <= id.Pos() && id.Pos() <= file.End() {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	// Record the positions in a deterministic order.
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Pos() < ids[j].Pos() })
+
+	for _, id := range ids {
+		var tparams *typeparams.TypeParamList
+		switch T := f.info.Uses[id].Type().(type) {
+		case *types.Signature:
+			tparams = typeparams.ForSignature(T)
+		case *types.Named:
+			tparams = typeparams.ForNamed(T)
+		}
+		targs := typeparams.GetInstances(f.info)[id].TypeArgs
+		if tparams == nil || targs == nil || tparams.Len() != targs.Len() {
+			continue // can't happen in a well-typed package
+		}
+		for i := 0; i < tparams.Len(); i++ {
+			f.assign(tparams.At(i).Constraint(), targs.At(i), id.Pos())
+		}
+	}
+}
+
 var (
 	tInvalid     = types.Typ[types.Invalid]
 	tUntypedBool = types.Typ[types.UntypedBool]
@@ -133,11 +202,11 @@ func (f *Finder) exprN(e ast.Expr) types.Type {
 	case *ast.IndexExpr:
 		// y, ok := x[i]
 		x := f.expr(e.X)
-		f.assign(f.expr(e.Index), coreType(x).(*types.Map).Key())
+		f.assign(f.expr(e.Index), coreType(x).(*types.Map).Key(), e.Index.Pos())
 
 	case *ast.TypeAssertExpr:
 		// y, ok := x.(T)
-		f.typeAssert(f.expr(e.X), typ.At(0).Type())
+		f.typeAssert(f.expr(e.X), typ.At(0).Type(), e.Pos())
 
 	case *ast.UnaryExpr: // must be receive <-
 		// y, ok := <-x
@@ -158,12 +227,13 @@ func (f *Finder) call(sig *types.Signature, args []ast.Expr) {
 	if _, ok := args[len(args)-1].(*ast.Ellipsis); ok {
 		for i, arg := range args {
 			// The final arg is a slice, and so is the final param.
-			f.assign(sig.Params().At(i).Type(), f.expr(arg))
+			f.assign(sig.Params().At(i).Type(), f.expr(arg), arg.Pos())
 		}
 		return
 	}
 
 	var argtypes []types.Type
+	var argpos []token.Pos
 
 	// Gather the effective actual parameter types.
 	if tuple, ok := f.info.Types[args[0]].Type.(*types.Tuple); ok {
@@ -172,28 +242,30 @@ func (f *Finder) call(sig *types.Signature, args []ast.Expr) {
 		// unpack the tuple
 		for i := 0; i < tuple.Len(); i++ {
 			argtypes = append(argtypes, tuple.At(i).Type())
+			argpos = append(argpos, args[0].Pos())
 		}
 	} else {
 		for _, arg := range args {
 			argtypes = append(argtypes, f.expr(arg))
+			argpos = append(argpos, arg.Pos())
 		}
 	}
 
 	// Assign the actuals to the formals.
 	if !sig.Variadic() {
 		for i, argtype := range argtypes {
-			f.assign(sig.Params().At(i).Type(), argtype)
+			f.assign(sig.Params().At(i).Type(), argtype, argpos[i])
 		}
 	} else {
 		// The first n-1 parameters are assigned normally.
 		nnormals := sig.Params().Len() - 1
 		for i, argtype := range argtypes[:nnormals] {
-			f.assign(sig.Params().At(i).Type(), argtype)
+			f.assign(sig.Params().At(i).Type(), argtype, argpos[i])
 		}
 		// Remaining args are assigned to elements of varargs slice.
 		tElem := sig.Params().At(nnormals).Type().(*types.Slice).Elem()
 		for i := nnormals; i < len(argtypes); i++ {
-			f.assign(tElem, argtypes[i])
+			f.assign(tElem, argtypes[i], argpos[i])
 		}
 	}
 }
@@ -215,14 +287,14 @@ func (f *Finder) builtin(obj *types.Builtin, sig *types.Signature, args []ast.Ex
 			// append(x, y, z)
 			tElem := coreType(s).(*types.Slice).Elem()
 			for _, arg := range args[1:] {
-				f.assign(tElem, f.expr(arg))
+				f.assign(tElem, f.expr(arg), arg.Pos())
 			}
 		}
 
 	case "delete":
 		m := f.expr(args[0])
 		k := f.expr(args[1])
-		f.assign(coreType(m).(*types.Map).Key(), k)
+		f.assign(coreType(m).(*types.Map).Key(), k, args[1].Pos())
 
 	default:
 		// ordinary call
@@ -249,7 +321,7 @@ func (f *Finder) valueSpec(spec *ast.ValueSpec) {
 		for _, value := range spec.Values {
 			v := f.expr(value)
 			if T != nil {
-				f.assign(T, v)
+				f.assign(T, v, value.Pos())
 			}
 		}
 
@@ -257,7 +329,7 @@ func (f *Finder) valueSpec(spec *ast.ValueSpec) {
 		tuple := f.exprN(spec.Values[0])
 		for i := range spec.Names {
 			if T != nil {
-				f.assign(T, f.extract(tuple, i))
+				f.assign(T, f.extract(tuple, i), spec.Values[0].Pos())
 			}
 		}
 	}
@@ -265,19 +337,27 @@ func (f *Finder) valueSpec(spec *ast.ValueSpec) {
 
 // assign records pairs of distinct types that are related by
 // assignability, where the left-hand side is an interface and both
-// sides have methods.
+// sides have methods, along with the position pos of the construct
+// that requires it.
 //
 // It should be called for all assignability checks, type assertions,
-// explicit conversions and comparisons between two types, unless the
-// types are uninteresting (e.g. lhs is a concrete type, or the empty
-// interface; rhs has no methods).
-func (f *Finder) assign(lhs, rhs types.Type) {
+// explicit conversions and comparisons between two types, and for the
+// satisfaction of the constraints of type parameters by their type
+// arguments, unless the types are uninteresting (e.g. lhs is a
+// concrete type, or the empty interface; rhs has no methods).
+func (f *Finder) assign(lhs, rhs types.Type, pos token.Pos) {
 	if types.Identical(lhs, rhs) {
 		return
 	}
 	if !isInterface(lhs) {
 		return
 	}
+	if _, ok := lhs.(*typeparams.TypeParam); ok {
+		// A value is assignable to a type parameter only if it
+		// has that type, or is nil or untyped; conversions to a
+		// type parameter are not interface satisfaction.
+		return
+	}
 
 	if f.msetcache.MethodSet(lhs).Len() == 0 {
 		return
@@ -286,12 +366,16 @@ func (f *Finder) assign(lhs, rhs types.Type) {
 		return
 	}
 	// record the pair
-	f.Result[Constraint{lhs, rhs}] = true
+	c := Constraint{lhs, rhs}
+	f.Result[c] = true
+	if f.Positions != nil {
+		f.Positions[c] = append(f.Positions[c], pos)
+	}
 }
 
 // typeAssert must be called for each type assertion x.(T) where x has
 // interface type I.
-func (f *Finder) typeAssert(I, T types.Type) {
+func (f *Finder) typeAssert(I, T types.Type, pos token.Pos) {
 	// Type assertions are slightly subtle, because they are allowed
 	// to be "impossible", e.g.
 	//
@@ -307,16 +391,16 @@ func (f *Finder) typeAssert(I, T types.Type) {
 	// to I before a refactoring, it should remain so after.
 
 	if types.AssignableTo(T, I) {
-		f.assign(I, T)
+		f.assign(I, T, pos)
 	}
 }
 
 // compare must be called for each comparison x==y.
-func (f *Finder) compare(x, y types.Type) {
+func (f *Finder) compare(x, y types.Type, pos token.Pos) {
 	if types.AssignableTo(x, y) {
-		f.assign(y, x)
+		f.assign(y, x, pos)
 	} else if types.AssignableTo(y, x) {
-		f.assign(x, y)
+		f.assign(x, y, pos)
 	}
 }
 
@@ -337,6 +421,9 @@ func (f *Finder) expr(e ast.Expr) types.Type {
 	case *ast.Ident:
 		// (referring idents only)
 		if obj, ok := f.info.Uses[e]; ok {
+			if inst, ok := typeparams.GetInstances(f.info)[e]; ok {
+				return inst.Type // e.g. f in f(x), with inferred type arguments
+			}
 			return obj.Type()
 		}
 		if e.Name == "_" { // e.g. "for _ = range x"
@@ -361,17 +448,17 @@ func (f *Finder) expr(e ast.Expr) types.Type {
 		case *types.Struct:
 			for i, elem := range e.Elts {
 				if kv, ok := elem.(*ast.KeyValueExpr); ok {
-					f.assign(f.info.Uses[kv.Key.(*ast.Ident)].Type(), f.expr(kv.Value))
+					f.assign(f.info.Uses[kv.Key.(*ast.Ident)].Type(), f.expr(kv.Value), kv.Value.Pos())
 				} else {
-					f.assign(T.Field(i).Type(), f.expr(elem))
+					f.assign(T.Field(i).Type(), f.expr(elem), elem.Pos())
 				}
 			}
 
 		case *types.Map:
 			for _, elem := range e.Elts {
 				elem := elem.(*ast.KeyValueExpr)
-				f.assign(T.Key(), f.expr(elem.Key))
-				f.assign(T.Elem(), f.expr(elem.Value))
+				f.assign(T.Key(), f.expr(elem.Key), elem.Key.Pos())
+				f.assign(T.Elem(), f.expr(elem.Value), elem.Value.Pos())
 			}
 
 		case *types.Array, *types.Slice:
@@ -381,9 +468,9 @@ func (f *Finder) expr(e ast.Expr) types.Type {
 			for _, elem := range e.Elts {
 				if kv, ok := elem.(*ast.KeyValueExpr); ok {
 					// ignore the key
-					f.assign(tElem, f.expr(kv.Value))
+					f.assign(tElem, f.expr(kv.Value), kv.Value.Pos())
 				} else {
-					f.assign(tElem, f.expr(elem))
+					f.assign(tElem, f.expr(elem), elem.Pos())
 				}
 			}
 
@@ -397,6 +484,8 @@ func (f *Finder) expr(e ast.Expr) types.Type {
 	case *ast.SelectorExpr:
 		if _, ok := f.info.Selections[e]; ok {
 			f.expr(e.X) // selection
+		} else if inst, ok := typeparams.GetInstances(f.info)[e.Sel]; ok {
+			return inst.Type // qualified identifier of an instance
 		} else {
 			return f.info.Uses[e.Sel].Type() // qualified identifier
 		}
@@ -409,7 +498,7 @@ func (f *Finder) expr(e ast.Expr) types.Type {
 			x := f.expr(e.X)
 			i := f.expr(e.Index)
 			if ux, ok := coreType(x).(*types.Map); ok {
-				f.assign(ux.Key(), i)
+				f.assign(ux.Key(), i, e.Index.Pos())
 			}
 		}
 
@@ -430,13 +519,13 @@ func (f *Finder) expr(e ast.Expr) types.Type {
 
 	case *ast.TypeAssertExpr:
 		x := f.expr(e.X)
-		f.typeAssert(x, f.info.Types[e.Type].Type)
+		f.typeAssert(x, f.info.Types[e.Type].Type, e.Pos())
 
 	case *ast.CallExpr:
 		if tvFun := f.info.Types[e.Fun]; tvFun.IsType() {
 			// conversion
 			arg0 := f.expr(e.Args[0])
-			f.assign(tvFun.Type, arg0)
+			f.assign(tvFun.Type, arg0, e.Args[0].Pos())
 		} else {
 			// function call
 			if id, ok := unparen(e.Fun).(*ast.Ident); ok {
@@ -459,7 +548,7 @@ func (f *Finder) expr(e ast.Expr) types.Type {
 		x := f.expr(e.X)
 		y := f.expr(e.Y)
 		if e.Op == token.EQL || e.Op == token.NEQ {
-			f.compare(x, y)
+			f.compare(x, y, e.OpPos)
 		}
 
 	case *ast.KeyValueExpr:
@@ -506,7 +595,7 @@ func (f *Finder) stmt(s ast.Stmt) {
 	case *ast.SendStmt:
 		ch := f.expr(s.Chan)
 		val := f.expr(s.Value)
-		f.assign(coreType(ch).(*types.Chan).Elem(), val)
+		f.assign(coreType(ch).(*types.Chan).Elem(), val, s.Value.Pos())
 
 	case *ast.IncDecStmt:
 		f.expr(s.X)
@@ -521,10 +610,13 @@ func (f *Finder) stmt(s ast.Stmt) {
 			}
 			for i := range s.Lhs {
 				var lhs, rhs types.Type
+				var pos token.Pos
 				if rhsTuple == nil {
 					rhs = f.expr(s.Rhs[i]) // 1:1 assignment
+					pos = s.Rhs[i].Pos()
 				} else {
 					rhs = f.extract(rhsTuple, i) // n:1 assignment
+					pos = s.Rhs[0].Pos()
 				}
 
 				if id, ok := s.Lhs[i].(*ast.Ident); ok {
@@ -537,7 +629,7 @@ func (f *Finder) stmt(s ast.Stmt) {
 				if lhs == nil {
 					lhs = f.expr(s.Lhs[i]) // assignment
 				}
-				f.assign(lhs, rhs)
+				f.assign(lhs, rhs, pos)
 			}
 
 		default:
@@ -557,13 +649,13 @@ func (f *Finder) stmt(s ast.Stmt) {
 		switch len(s.Results) {
 		case formals.Len(): // 1:1
 			for i, result := range s.Results {
-				f.assign(formals.At(i).Type(), f.expr(result))
+				f.assign(formals.At(i).Type(), f.expr(result), result.Pos())
 			}
 
 		case 1: // n:1
 			tuple := f.exprN(s.Results[0])
 			for i := 0; i < formals.Len(); i++ {
-				f.assign(formals.At(i).Type(), f.extract(tuple, i))
+				f.assign(formals.At(i).Type(), f.extract(tuple, i), s.Results[0].Pos())
 			}
 		}
 
@@ -596,7 +688,7 @@ func (f *Finder) stmt(s ast.Stmt) {
 		for _, cc := range s.Body.List {
 			cc := cc.(*ast.CaseClause)
 			for _, cond := range cc.List {
-				f.compare(tag, f.info.Types[cond].Type)
+				f.compare(tag, f.info.Types[cond].Type, cond.Pos())
 			}
 			for _, s := range cc.Body {
 				f.stmt(s)
@@ -619,7 +711,7 @@ func (f *Finder) stmt(s ast.Stmt) {
 			for _, cond := range cc.List {
 				tCase := f.info.Types[cond].Type
 				if tCase != tUntypedNil {
-					f.typeAssert(I, tCase)
+					f.typeAssert(I, tCase, cond.Pos())
 				}
 			}
 			for _, s := range cc.Body {
@@ -663,7 +755,7 @@ func (f *Finder) stmt(s ast.Stmt) {
 					xelem = ux.Key()
 				}
 				if xelem != nil {
-					f.assign(k, xelem)
+					f.assign(k, xelem, s.X.Pos())
 				}
 			}
 			if s.Value != nil {
@@ -682,7 +774,7 @@ func (f *Finder) stmt(s ast.Stmt) {
 					xelem = ux.Elem()
 				}
 				if xelem != nil {
-					f.assign(val, xelem)
+					f.assign(val, xelem, s.X.Pos())
 				}
 			}
 		}
@@ -190,7 +190,109 @@ var global GI[string]
 	}
 }
 
+// This test exercises the constraints of the type parameters of
+// generic functions and types on their type arguments.
+func TestInstantiationConstraints(t *testing.T) {
+	if !typeparams.Enabled {
+		t.Skip("!typeparams.Enabled")
+	}
+
+	const src = `package foo
+
+type I interface { f() }
+
+type impl struct{}
+func (impl) f() {}
+
+type A struct{impl}
+type B struct{impl}
+type C struct{impl}
+type D struct{impl}
+
+func g[T I](T) {}
+
+type Box[T I] struct{ x T }
+
+func h[T any, PT interface{ *T; I }](PT) {}
+
+func _() {
+	g(A{})          // I <- A (inferred)
+	g[B](B{})       // I <- B (explicit)
+	_ = Box[C]{}    // I <- C
+	h(&D{})         // interface{*T; I} <- *D
+}
+
+func _[T I](x T) {
+	g(x)               // I <- T
+	var _ Box[T]       // I <- T
+	var _ I = x        // I <- T
+}
+`
+	got := constraints(t, src)
+	want := []string{
+		"interface{*T; p.I} <- *p.D", // implicitly "forall T" quantified
+		"p.I <- T",
+		"p.I <- p.A",
+		"p.I <- p.B",
+		"p.I <- p.C",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("found unexpected constraints: got %s, want %s", got, want)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	const src = `package foo
+
+type I interface { f() }
+
+type A struct{}
+func (A) f() {}
+
+func g(I) {}
+
+func _() {
+	var _ I = A{}
+	g(A{})
+	var i I
+	_ = i == A{}
+}
+`
+	fset, finder := find(t, src, true)
+	var got []string
+	for c, posns := range finder.Positions {
+		if !finder.Result[c] {
+			t.Errorf("position of constraint %v <- %v not in Result", c.LHS, c.RHS)
+		}
+		for _, pos := range posns {
+			posn := fset.Position(pos)
+			got = append(got, fmt.Sprintf("%v <- %v at %d:%d", c.LHS, c.RHS, posn.Line, posn.Column))
+		}
+	}
+	sort.Strings(got)
+	want := []string{
+		"p.I <- p.A at 11:12",
+		"p.I <- p.A at 12:4",
+		"p.I <- p.A at 14:8",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("found unexpected positions: got %s, want %s", got, want)
+	}
+}
+
 func constraints(t *testing.T, src string) []string {
+	_, finder := find(t, src, false)
+	var constraints []string
+	for c := range finder.Result {
+		constraints = append(constraints, fmt.Sprintf("%v <- %v", c.LHS, c.RHS))
+	}
+	sort.Strings(constraints)
+	return constraints
+}
+
+// find type-checks src and returns the Finder of its constraints,
+// which records their positions if positions is set.
+func find(t *testing.T, src string, positions bool) (*token.FileSet, *satisfy.Finder) {
 	// parse
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, "p.go", src, 0)
@@ -216,11 +318,9 @@ func constraints(t *testing.T, src string) []string {
 
 	// gather constraints
 	var finder satisfy.Finder
-	finder.Find(info, files)
-	var constraints []string
-	for c := range finder.Result {
-		constraints = append(constraints, fmt.Sprintf("%v <- %v", c.LHS, c.RHS))
+	if positions {
+		finder.Positions = make(map[satisfy.Constraint][]token.Pos)
 	}
-	sort.Strings(constraints)
-	return constraints
+	finder.Find(info, files)
+	return fset, &finder
 }